@@ -0,0 +1,87 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// AIGatewayConsumerKey is a virtual API key issued to a consumer of the AIGatewayRoutes in the same namespace.
+//
+// The clients send the key as "Authorization: Bearer sk-..." to the AIGatewayRoutes with RequireConsumerKey set.
+// The ai-gateway validates the key, rejects the requests to the models or the routes not allowed by the key, and
+// identifies the consumer of the request in the metrics, the dynamic metadata of the request costs and the token
+// quotas. The key itself is never sent to the backends, and only its SHA-256 hash is passed to the external processor.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Consumer",type=string,JSONPath=`.spec.consumer`
+// +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.conditions[-1:].type`
+type AIGatewayConsumerKey struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Spec defines the details of AIGatewayConsumerKey.
+	Spec AIGatewayConsumerKeySpec `json:"spec,omitempty"`
+	// Status defines the status details of the AIGatewayConsumerKey.
+	Status AIGatewayConsumerKeyStatus `json:"status,omitempty"`
+}
+
+// AIGatewayConsumerKeyList contains a list of AIGatewayConsumerKeys.
+//
+// +kubebuilder:object:root=true
+type AIGatewayConsumerKeyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AIGatewayConsumerKey `json:"items"`
+}
+
+// AIGatewayConsumerKeySpec details the AIGatewayConsumerKey configuration.
+type AIGatewayConsumerKeySpec struct {
+	// Consumer is the identity of the consumer the key is issued to. The same consumer can have multiple keys,
+	// e.g. to rotate them.
+	//
+	// The consumer is set to the "x-ai-eg-consumer" request header, so that it can be used as the consumer header
+	// of the token quotas, the response cache and the usage ledger.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9]([-_.A-Za-z0-9]*[A-Za-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=253
+	Consumer string `json:"consumer"`
+
+	// SecretRef is the reference to the secret containing the key, which must start with "sk-".
+	// ai-gateway must be given the permission to read this secret.
+	// The key of the secret should be "apiKey".
+	//
+	// The secret must be in the same namespace as the AIGatewayConsumerKey, so the namespace must not be set.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:XValidation:rule="!has(self.__namespace__)",message="secretRef must refer to a secret in the namespace of the AIGatewayConsumerKey"
+	SecretRef *gwapiv1.SecretObjectReference `json:"secretRef"`
+
+	// Models is the list of the models the key can be used for. If not set, the key can be used for all the models.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=128
+	Models []string `json:"models,omitempty"`
+
+	// AIGatewayRouteNames is the list of the names of the AIGatewayRoutes in the same namespace the key can be used
+	// for. If not set, the key can be used for all the AIGatewayRoutes in the namespace requiring the consumer keys.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=64
+	AIGatewayRouteNames []string `json:"aiGatewayRouteNames,omitempty"`
+
+	// ExpiresAt is the time when the key expires. If not set, the key never expires.
+	//
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Revoked revokes the key, which is rejected from then on. This can be used to suspend a key without deleting it.
+	//
+	// +optional
+	Revoked bool `json:"revoked,omitempty"`
+}
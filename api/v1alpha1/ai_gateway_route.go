@@ -7,6 +7,7 @@ package v1alpha1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
 	gwapiv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
@@ -59,6 +60,8 @@ type AIGatewayRouteList struct {
 }
 
 // AIGatewayRouteSpec details the AIGatewayRoute configuration.
+//
+// +kubebuilder:validation:XValidation:rule="(has(self.requireConsumerKey) && self.requireConsumerKey) || !has(self.tokenQuotas) || self.tokenQuotas.all(q, has(q.consumerHeader))",message="consumerHeader must be set on the tokenQuotas unless requireConsumerKey is set"
 type AIGatewayRouteSpec struct {
 	// TargetRefs are the names of the Gateway resources this AIGatewayRoute is being attached to.
 	//
@@ -199,6 +202,131 @@ type AIGatewayRouteSpec struct {
 	// +optional
 	// +kubebuilder:validation:MaxItems=36
 	LLMRequestCosts []LLMRequestCost `json:"llmRequestCosts,omitempty"`
+
+	// TokenQuotas are the per-consumer token budgets enforced by the AI Gateway filter itself
+	// for the requests of this AIGatewayRoute. Unlike LLMRequestCosts, this does not require
+	// an external rate limit service to be deployed.
+	//
+	// A request is rejected with 429 Too Many Requests in the OpenAI error format when the consumer
+	// has already exhausted any of the budgets. The actual token usage of the response is deducted
+	// from the budgets once the response is completed, so a single request can exceed the remaining budget.
+	//
+	// The quota state is stored in the memory of each external processor by default, or in a Redis-compatible
+	// store shared by the external processors when configured.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=16
+	TokenQuotas []AIGatewayRouteTokenQuota `json:"tokenQuotas,omitempty"`
+
+	// RequireConsumerKey requires the requests to this AIGatewayRoute to carry a valid AIGatewayConsumerKey
+	// of the same namespace as "Authorization: Bearer sk-...". The requests without the key, or with an unknown,
+	// expired or revoked key are rejected with 401 Unauthorized, and the requests to the models or the routes
+	// not allowed by the key are rejected with 403 Forbidden, both in the OpenAI error format.
+	//
+	// The consumer of the key is set to the "x-ai-eg-consumer" request header, and the TokenQuotas without
+	// the consumerHeader are keyed by it.
+	//
+	// +optional
+	RequireConsumerKey bool `json:"requireConsumerKey,omitempty"`
+
+	// Authorization authorizes the requests to the models of this AIGatewayRoute, e.g. to let a team use gpt-4o
+	// but not o1. The requests not allowed are rejected with 403 Forbidden in the OpenAI error format, and the
+	// models not allowed are left out of the /v1/models response.
+	//
+	// +optional
+	Authorization *AIGatewayRouteAuthorization `json:"authorization,omitempty"`
+}
+
+// AIGatewayRouteAuthorization authorizes the requests to the models of an AIGatewayRoute with a CEL expression
+// over the JWT claims and the consumer of the caller.
+type AIGatewayRouteAuthorization struct {
+	// ClaimsHeader is the name of the request header carrying the JWT claims of the caller, either as the JSON
+	// object of the claims or its base64url encoding as written by the forward_payload_header of the Envoy JWT
+	// authentication filter. The requests without the header have no claims.
+	//
+	// The header must be set by a filter that overwrites the value sent by the client, as the claims are trusted as is.
+	//
+	// Default is "x-jwt-claims".
+	//
+	// +optional
+	// +kubebuilder:default=x-jwt-claims
+	// +kubebuilder:validation:MinLength=1
+	ClaimsHeader *string `json:"claimsHeader,omitempty"`
+
+	// CEL is the CEL expression that must evaluate to true for the request to be allowed.
+	// The expression can use the following variables:
+	//
+	//	* model: the name of the model of the request.
+	//	* route: the name of this AIGatewayRoute.
+	//	* consumer: the consumer of the AIGatewayConsumerKey of the request if RequireConsumerKey is set, or empty.
+	//	* claims: the map of the JWT claims of the caller.
+	//	* request_headers: the map of the request headers keyed by the lower-cased header name.
+	//
+	// The missing claims and headers fail the evaluation, which denies the request, so they should be accessed with
+	// the optional syntax. For example, the following expressions are valid:
+	//
+	//	* "claims[?'team'].orValue('') == 'a' ? model != 'o1' : false"
+	//	* "'interns' in claims[?'groups'].orValue([]) ? model.endsWith('-mini') : true"
+	//	* "consumer == 'batch-jobs' && model.startsWith('text-embedding-')"
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	CEL string `json:"cel"`
+}
+
+// AIGatewayRouteTokenQuota is the token budget per consumer of an AIGatewayRoute.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.tokensPerMinute) || has(self.tokensPerDay)",message="either tokensPerMinute or tokensPerDay must be set"
+type AIGatewayRouteTokenQuota struct {
+	// Name is the name of the quota, which must be unique within the AIGatewayRoute.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// ConsumerHeader is the name of the request header that identifies the consumer, e.g. the API key ID
+	// or the team name. A claim of the JWT can be used by populating it into a header with the claimToHeaders
+	// of the JWT authentication of the Envoy Gateway SecurityPolicy.
+	//
+	// The header is taken from the request as is, so the client can set it to any value unless it's overwritten
+	// by an authentication filter in front of the AI Gateway. The requests without the header are not subject to
+	// this quota unless RequireConsumerHeader is set.
+	//
+	// When this is not set, the quota is keyed by the consumer authenticated with the consumer key, which requires
+	// RequireConsumerKey of the AIGatewayRoute to be set.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	ConsumerHeader string `json:"consumerHeader,omitempty"`
+
+	// RequireConsumerHeader rejects the requests without the ConsumerHeader with 400 Bad Request in the OpenAI
+	// error format instead of exempting them from this quota, so that the client cannot evade the quota by
+	// omitting the header.
+	//
+	// +optional
+	RequireConsumerHeader bool `json:"requireConsumerHeader,omitempty"`
+
+	// Type specifies which tokens are counted against the budgets.
+	// This must be one of "InputToken", "OutputToken", or "TotalToken".
+	//
+	// Default is "TotalToken".
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=InputToken;OutputToken;TotalToken
+	// +kubebuilder:default=TotalToken
+	Type *LLMRequestCostType `json:"type,omitempty"`
+
+	// TokensPerMinute is the number of tokens a consumer can use per minute.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TokensPerMinute *int64 `json:"tokensPerMinute,omitempty"`
+
+	// TokensPerDay is the number of tokens a consumer can use per day in UTC.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TokensPerDay *int64 `json:"tokensPerDay,omitempty"`
 }
 
 // AIGatewayRouteRule is a rule that defines the routing behavior of the AIGatewayRoute.
@@ -262,6 +390,830 @@ type AIGatewayRouteRule struct {
 	// +optional
 	// +kubebuilder:validation:Format=date-time
 	ModelsCreatedAt *metav1.Time `json:"modelsCreatedAt,omitempty"`
+
+	// LongContextFallbackModel is the model name to which a chat completion request is upgraded when
+	// the estimated prompt tokens plus the requested "max_tokens" do not fit into the context window
+	// of the backends of this rule. See AIServiceBackendSpec.TokenLimits for how the limits are declared.
+	//
+	// The request is routed again with this model name as if the client had requested it, and the "model"
+	// field of the request body is rewritten accordingly. Fallbacks can be chained across rules.
+	//
+	// When this is not set, or no rule can fit the request, the ai-gateway returns an OpenAI-compatible
+	// "context_length_exceeded" error without calling the upstream.
+	//
+	// +optional
+	LongContextFallbackModel *string `json:"longContextFallbackModel,omitempty"`
+
+	// Shadow configures the mirroring of a sampled percentage of the chat completion traffic of this rule
+	// to a shadow AIServiceBackend. This is useful to evaluate a migration from one provider to another on real prompts.
+	//
+	// The shadow request is sent by the ai-gateway asynchronously, and its response never reaches the client.
+	// The results of the primary and the shadow backends, such as the latency, the token usage and the finish reason,
+	// are paired and recorded as metrics and structured logs. The sampled requests are not mirrored while too many
+	// shadow requests are in flight, so that a slow shadow backend never affects the primary traffic.
+	//
+	// +optional
+	Shadow *AIGatewayRouteRuleShadow `json:"shadow,omitempty"`
+
+	// SessionAffinity pins the requests with the same session key, such as the turns of the same conversation,
+	// to the same backend of this rule so that they can benefit from the provider-side prompt caches.
+	//
+	// The session key is hashed by the consistent hashing (Maglev) load balancer of Envoy, so the mapping from a key
+	// to a backend is stable across configuration reloads as long as the set of backends does not change. The weights
+	// and the priorities of the backends are still respected when distributing the keys. When the pinned backend
+	// becomes unhealthy, its keys are moved to the other backends. Until then, a request failing on the pinned
+	// backend is retried on another backend, as the retries of the route avoid the previously attempted backend.
+	// When no retry policy is configured for the route, one retry on 5xx responses, resets and connection failures
+	// is added. Requests without a session key are balanced as usual.
+	//
+	// +optional
+	SessionAffinity *AIGatewayRouteRuleSessionAffinity `json:"sessionAffinity,omitempty"`
+
+	// Hedging sends a duplicate request to another backend of this rule when the backend of the original request
+	// has not sent the response headers within the configured delay. The first backend to respond wins, and the
+	// other requests are cancelled. This trades the cost of the duplicate requests for the tail latency of
+	// interactive routes.
+	//
+	// Note that this measures the time to the response headers, not the time to the first token. A streaming
+	// backend that sends the response headers right away and then stalls before the first token is not hedged.
+	//
+	// The prompt tokens of the cancelled requests are estimated and added to the token usage of the request
+	// in the cost metadata, since the providers usually bill them regardless of the cancellation. Note that this
+	// is an estimate: the prompt is counted with the tokenizer of the gateway rather than the one of the provider,
+	// and the completion tokens the cancelled requests may have generated before the cancellation are not counted.
+	//
+	// +optional
+	Hedging *AIGatewayRouteRuleHedging `json:"hedging,omitempty"`
+
+	// RequestPolicy limits the parameters of the chat completion requests of this rule, such as "max_tokens"
+	// and "n", to protect the backends from the runaway costs. Each limit either clamps the parameter to the limit
+	// or rejects the request with a 400 Bad Request in the OpenAI error format. The policy also validates the size
+	// of the request body, the images and the tool schemas, which always rejects the violating requests.
+	//
+	// The policy of the rule selected by the requested model is enforced before the request is translated
+	// for the backend, and before the long context fallback of the rule is considered.
+	//
+	// +optional
+	RequestPolicy *AIGatewayRouteRuleRequestPolicy `json:"requestPolicy,omitempty"`
+
+	// ResponseCache caches the successful responses of the chat completion requests of this rule, and serves
+	// the identical requests from the cache without calling the backends. This is useful for the workloads that
+	// resend the same prompts, such as evaluations and batch classifications.
+	//
+	// The requests are identified by the hash of the normalized request body, the model and the consumer.
+	// Both the JSON and the streaming responses are cached, and the streaming responses are replayed at once.
+	// The responses are kept in the memory of each external processor up to its configured maximum size,
+	// and the least recently used responses are evicted first.
+	//
+	// +optional
+	ResponseCache *AIGatewayRouteRuleResponseCache `json:"responseCache,omitempty"`
+
+	// Embeddings configures the batching and the caching of the embeddings requests of this rule, which
+	// is useful for the ingestion pipelines that send large arrays of inputs, many of which have been embedded before.
+	//
+	// +optional
+	Embeddings *AIGatewayRouteRuleEmbeddings `json:"embeddings,omitempty"`
+
+	// Redaction detects the personally identifiable information (PII) such as the email addresses and the card
+	// numbers in the requests of this rule, and masks, hashes, tokenizes it or rejects the request before the
+	// request is sent to the backends. This applies to the messages of the chat completion requests and the inputs
+	// of the embeddings requests.
+	//
+	// +optional
+	Redaction *AIGatewayRouteRuleRedaction `json:"redaction,omitempty"`
+
+	// Guardrails is the list of the guardrails screening the prompts and the completions of the chat completion
+	// requests of this rule with the moderation backends, e.g. to stop the jailbreak attempts before they reach
+	// the expensive models. The guardrails of the same phase are evaluated concurrently.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:XValidation:rule="self.all(g, self.exists_one(h, h.name == g.name))",message="guardrail names must be unique"
+	Guardrails []AIGatewayRouteRuleGuardrail `json:"guardrails,omitempty"`
+
+	// StreamModeration screens the completions of the streamed chat completion responses of this rule before they
+	// are sent to the client. The chunks are held back until the window of the completions following the last screened
+	// text is full, and then the window is screened with the deny patterns and the guardrails with the Output phase.
+	// The chunks are released to the client only when the window passes, and the stream is terminated with a final
+	// chunk with the "content_filter" finish reason on a violation.
+	//
+	// This adds the latency of the screening to the time to the first token and every window of the stream.
+	//
+	// +optional
+	StreamModeration *AIGatewayRouteRuleStreamModeration `json:"streamModeration,omitempty"`
+
+	// AuditLog writes the prompts and the completions of the chat completion requests of this rule to the audit log
+	// of the external processor, along with the consumer, the model and the backend of each request. The chunks of
+	// the streamed responses are assembled into a single chat completion object.
+	//
+	// The audit log sink must be configured with the extProcAuditLogSink flag of the controller, and the rules
+	// without the audit log are not affected at all.
+	//
+	// +optional
+	AuditLog *AIGatewayRouteRuleAuditLog `json:"auditLog,omitempty"`
+}
+
+// AIGatewayRouteRuleAuditLog configures the audit log of an AIGatewayRouteRule.
+type AIGatewayRouteRuleAuditLog struct {
+	// SamplingRate is the fraction of the requests written to the audit log as a decimal string between 0 and 1,
+	// e.g. "0.1" for 10% of the requests.
+	//
+	// Default is "1", i.e. every request is written.
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(0(\.[0-9]+)?|1(\.0+)?)$`
+	// +kubebuilder:default="1"
+	SamplingRate *string `json:"samplingRate,omitempty"`
+
+	// RedactFields is the list of the paths of the fields replaced with "[REDACTED]" in the audit records.
+	// Each path starts with either "request" or "response" followed by the keys of the objects and the indexes of
+	// the arrays separated by dots, where "*" matches every element of an array or every field of an object.
+	// For example, "request.messages.*.content" redacts the contents of all the messages of the request, and
+	// "response" redacts the whole response. The response of a streamed request is redacted after it is assembled,
+	// e.g. "response.choices.*.message.content".
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:items:Pattern=`^(request|response)(\.[^.]+)*$`
+	RedactFields []string `json:"redactFields,omitempty"`
+}
+
+// AIGatewayRouteRuleStreamModeration configures the moderation of the streamed completions of an AIGatewayRouteRule.
+type AIGatewayRouteRuleStreamModeration struct {
+	// WindowSize is the number of the characters of the completions of a choice held back before they are screened.
+	// Each window is screened along with the previous one so that the violations across the windows are detected.
+	//
+	// Default is 256.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=16
+	// +kubebuilder:validation:Maximum=8192
+	// +kubebuilder:default=256
+	WindowSize *int32 `json:"windowSize,omitempty"`
+
+	// DenyPatterns is the list of the regular expressions in the RE2 syntax that must not appear in the completions.
+	// See https://github.com/google/re2/wiki/Syntax for the syntax.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:items:MinLength=1
+	DenyPatterns []string `json:"denyPatterns,omitempty"`
+}
+
+// AIGatewayRouteRuleRedaction configures the PII redaction of an AIGatewayRouteRule.
+//
+// +kubebuilder:validation:XValidation:rule="!self.detectors.exists(d, has(d.action) && d.action == 'Hash') || has(self.hashKeyRef)",message="hashKeyRef must be set when a detector uses the Hash action"
+type AIGatewayRouteRuleRedaction struct {
+	// Detectors is the list of the detectors of the PII, which are applied in order.
+	//
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:XValidation:rule="self.all(d, self.exists_one(e, e.name == d.name))",message="detector names must be unique"
+	Detectors []AIGatewayRouteRuleRedactionDetector `json:"detectors"`
+
+	// Responses specifies whether the detectors also redact the completions, e.g. to keep the PII the model has
+	// seen in its training data from reaching the clients. The detectors with the Reject action mask the PII in
+	// the completions, and the ones with the Tokenize action restore the PII of the request instead of redacting.
+	//
+	// This holds back the end of the streamed completions by up to 64 characters until it is known not to be a
+	// part of the PII.
+	//
+	// +optional
+	Responses bool `json:"responses,omitempty"`
+
+	// HashKeyRef is the reference to the Secret in the same namespace as the AIGatewayRoute containing the key of
+	// the HMAC used by the detectors with the Hash action under the "hashKey" data key. This is required when any
+	// detector uses the Hash action, so that the hashed PII cannot be recovered by hashing the candidates of the PII.
+	//
+	// The same key gives the same placeholders across the rules and the AIGatewayRoutes using it, and rotating
+	// the key changes all the placeholders.
+	//
+	// +optional
+	HashKeyRef *gwapiv1.SecretObjectReference `json:"hashKeyRef,omitempty"`
+}
+
+// AIGatewayRouteRuleRedactionDetector is a detector of a kind of PII.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.builtin) != has(self.pattern)",message="exactly one of builtin or pattern must be set"
+type AIGatewayRouteRuleRedactionDetector struct {
+	// Name is the name of the detector, which is used in the placeholders of the redacted PII,
+	// e.g. "[EMAIL]" for the detector named "EMAIL".
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[A-Z][A-Z0-9_]*$`
+	// +kubebuilder:validation:MaxLength=32
+	Name string `json:"name"`
+
+	// Builtin is the builtin detector to use.
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Email;PhoneNumber;CreditCard;USSocialSecurityNumber;IBAN
+	Builtin *AIGatewayRouteRuleRedactionBuiltinDetector `json:"builtin,omitempty"`
+
+	// Pattern is the regular expression in the RE2 syntax that matches the PII.
+	// See https://github.com/google/re2/wiki/Syntax for the syntax.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	Pattern *string `json:"pattern,omitempty"`
+
+	// Validator is the checksum validator of the matches of the pattern, which reduces the false positives.
+	// The builtin detectors validate their matches by themselves.
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Luhn;IBAN
+	Validator *AIGatewayRouteRuleRedactionValidator `json:"validator,omitempty"`
+
+	// Action is the action taken on the detected PII.
+	//
+	// Default is "Mask".
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Mask;Hash;Reject;Tokenize
+	// +kubebuilder:default=Mask
+	Action *AIGatewayRouteRuleRedactionAction `json:"action,omitempty"`
+}
+
+// AIGatewayRouteRuleRedactionBuiltinDetector is a builtin detector of the PII.
+type AIGatewayRouteRuleRedactionBuiltinDetector string
+
+const (
+	// AIGatewayRouteRuleRedactionBuiltinDetectorEmail detects the email addresses.
+	AIGatewayRouteRuleRedactionBuiltinDetectorEmail AIGatewayRouteRuleRedactionBuiltinDetector = "Email"
+	// AIGatewayRouteRuleRedactionBuiltinDetectorPhoneNumber detects the phone numbers with at least 7 digits,
+	// optionally with the country code and the separators.
+	AIGatewayRouteRuleRedactionBuiltinDetectorPhoneNumber AIGatewayRouteRuleRedactionBuiltinDetector = "PhoneNumber"
+	// AIGatewayRouteRuleRedactionBuiltinDetectorCreditCard detects the payment card numbers passing the Luhn check.
+	AIGatewayRouteRuleRedactionBuiltinDetectorCreditCard AIGatewayRouteRuleRedactionBuiltinDetector = "CreditCard"
+	// AIGatewayRouteRuleRedactionBuiltinDetectorUSSocialSecurityNumber detects the valid US social security numbers
+	// in the "123-45-6789" format.
+	AIGatewayRouteRuleRedactionBuiltinDetectorUSSocialSecurityNumber AIGatewayRouteRuleRedactionBuiltinDetector = "USSocialSecurityNumber"
+	// AIGatewayRouteRuleRedactionBuiltinDetectorIBAN detects the international bank account numbers passing the
+	// ISO 7064 MOD 97-10 check.
+	AIGatewayRouteRuleRedactionBuiltinDetectorIBAN AIGatewayRouteRuleRedactionBuiltinDetector = "IBAN"
+)
+
+// AIGatewayRouteRuleRedactionValidator is a checksum validator of the PII.
+type AIGatewayRouteRuleRedactionValidator string
+
+const (
+	// AIGatewayRouteRuleRedactionValidatorLuhn validates the digits of the match with the Luhn algorithm.
+	AIGatewayRouteRuleRedactionValidatorLuhn AIGatewayRouteRuleRedactionValidator = "Luhn"
+	// AIGatewayRouteRuleRedactionValidatorIBAN validates the match with the ISO 7064 MOD 97-10 check of the IBAN.
+	AIGatewayRouteRuleRedactionValidatorIBAN AIGatewayRouteRuleRedactionValidator = "IBAN"
+)
+
+// AIGatewayRouteRuleRedactionAction is the action taken on the detected PII.
+type AIGatewayRouteRuleRedactionAction string
+
+const (
+	// AIGatewayRouteRuleRedactionActionMask replaces the PII with the name of the detector, e.g. "[EMAIL]".
+	AIGatewayRouteRuleRedactionActionMask AIGatewayRouteRuleRedactionAction = "Mask"
+	// AIGatewayRouteRuleRedactionActionHash replaces the PII with the name of the detector and the truncated
+	// HMAC-SHA256 of the PII keyed with the hashKeyRef of the redaction, e.g. "[EMAIL:1f9d3c2b8a7e6d5c]",
+	// so that the same PII is still recognizable as such.
+	AIGatewayRouteRuleRedactionActionHash AIGatewayRouteRuleRedactionAction = "Hash"
+	// AIGatewayRouteRuleRedactionActionReject rejects the request with a 400 Bad Request.
+	AIGatewayRouteRuleRedactionActionReject AIGatewayRouteRuleRedactionAction = "Reject"
+	// AIGatewayRouteRuleRedactionActionTokenize replaces the PII with a numbered placeholder, e.g. "[EMAIL_1]", and
+	// restores the PII in place of the placeholders in the completions, including the streamed ones.
+	AIGatewayRouteRuleRedactionActionTokenize AIGatewayRouteRuleRedactionAction = "Tokenize"
+)
+
+// AIGatewayRouteRuleGuardrail is a guardrail of an AIGatewayRouteRule calling a moderation backend.
+type AIGatewayRouteRuleGuardrail struct {
+	// Name is the name of the guardrail, which is used in the logs, the response headers and the dynamic metadata.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// BackendName is the name of the AIServiceBackend of the moderation backend. It must be in the same namespace
+	// as the AIGatewayRoute. The BackendSecurityPolicy of the AIServiceBackend is used to authenticate the requests.
+	//
+	// The AIServiceBackend must reference an Envoy Gateway Backend with an FQDN or IP endpoint, which the ai-gateway
+	// calls directly. The endpoint is called with "https" when the Backend has the TLS settings, it is targeted by
+	// a BackendTLSPolicy, or its port is 443. The hostname and the CA certificates of the BackendTLSPolicy, if any,
+	// are used to verify the certificate of the endpoint.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	BackendName string `json:"backendName"`
+
+	// Type is the API of the moderation backend.
+	//
+	// Default is "OpenAIModeration".
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=OpenAIModeration;HTTPClassifier
+	// +kubebuilder:default=OpenAIModeration
+	Type *AIGatewayRouteRuleGuardrailType `json:"type,omitempty"`
+
+	// Path is the path of the moderation endpoint.
+	//
+	// Default is "/v1/moderations".
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/`
+	Path *string `json:"path,omitempty"`
+
+	// Model is the moderation model, e.g. "omni-moderation-latest". This is only sent to the OpenAIModeration
+	// backends, which use their default model if not set.
+	//
+	// +optional
+	Model *string `json:"model,omitempty"`
+
+	// Phases is the list of the phases screened by the guardrail.
+	//
+	// Default is ["Input"].
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=2
+	// +kubebuilder:validation:items:Enum=Input;Output
+	// +kubebuilder:default={Input}
+	Phases []AIGatewayRouteRuleGuardrailPhase `json:"phases,omitempty"`
+
+	// Categories is the list of the categories of the moderation backend, e.g. "violence" or "jailbreak", which
+	// trigger the guardrail when their score reaches the threshold. If not set, the guardrail is triggered when the
+	// moderation backend flags the text.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=32
+	Categories []string `json:"categories,omitempty"`
+
+	// Threshold is the score of the categories between 0 and 1 from which the guardrail is triggered.
+	//
+	// Default is "0.5".
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(0(\.[0-9]+)?|1(\.0+)?)$`
+	// +kubebuilder:default="0.5"
+	Threshold *string `json:"threshold,omitempty"`
+
+	// Action is the action taken when the guardrail is triggered.
+	//
+	// Default is "Block".
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Block;Flag;Annotate
+	// +kubebuilder:default=Block
+	Action *AIGatewayRouteRuleGuardrailAction `json:"action,omitempty"`
+
+	// FailureMode specifies what happens when the moderation backend fails or times out.
+	//
+	// Default is "FailOpen".
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=FailOpen;FailClosed
+	// +kubebuilder:default=FailOpen
+	FailureMode *AIGatewayRouteRuleGuardrailFailureMode `json:"failureMode,omitempty"`
+
+	// Timeout is the timeout of the request to the moderation backend.
+	//
+	// The ai-gateway holds the request or the response while it waits for the moderation backend, and Envoy only
+	// waits 200ms for the ai-gateway by default. So, the ai-gateway raises the message timeout of its
+	// EnvoyExtensionPolicy of the Gateway to cover the largest timeouts of the guardrails, the semantic caches and
+	// the embeddings batches of the AIGatewayRoutes attached to the Gateway. Keep the timeouts short since they also
+	// bound how long Envoy waits for the ai-gateway on any other request of the Gateway.
+	//
+	// Default is 5s.
+	//
+	// +optional
+	Timeout *gwapiv1.Duration `json:"timeout,omitempty"`
+}
+
+// AIGatewayRouteRuleGuardrailType is the API of a moderation backend.
+type AIGatewayRouteRuleGuardrailType string
+
+const (
+	// AIGatewayRouteRuleGuardrailTypeOpenAIModeration is the OpenAI "/v1/moderations" compatible API, which receives
+	// {"model": "<model>", "input": "<text>"} and responds with the "results" having the "flagged" field and the
+	// "category_scores".
+	AIGatewayRouteRuleGuardrailTypeOpenAIModeration AIGatewayRouteRuleGuardrailType = "OpenAIModeration"
+	// AIGatewayRouteRuleGuardrailTypeHTTPClassifier is a generic HTTP classifier, which receives {"input": "<text>"}
+	// and responds with {"flagged": <bool>, "category_scores": {"<category>": <score>}}.
+	AIGatewayRouteRuleGuardrailTypeHTTPClassifier AIGatewayRouteRuleGuardrailType = "HTTPClassifier"
+)
+
+// AIGatewayRouteRuleGuardrailPhase is a phase of a request screened by a guardrail.
+type AIGatewayRouteRuleGuardrailPhase string
+
+const (
+	// AIGatewayRouteRuleGuardrailPhaseInput screens the text of the messages of the request except the ones of
+	// the assistant before the request is sent to the backends.
+	AIGatewayRouteRuleGuardrailPhaseInput AIGatewayRouteRuleGuardrailPhase = "Input"
+	// AIGatewayRouteRuleGuardrailPhaseOutput screens the completions before they are sent to the client.
+	// The completions of the streamed responses are screened only when the StreamModeration of the rule is set.
+	AIGatewayRouteRuleGuardrailPhaseOutput AIGatewayRouteRuleGuardrailPhase = "Output"
+)
+
+// AIGatewayRouteRuleGuardrailAction is the action taken when a guardrail is triggered.
+type AIGatewayRouteRuleGuardrailAction string
+
+const (
+	// AIGatewayRouteRuleGuardrailActionBlock responds with a chat completion with the empty content and the
+	// "content_filter" finish reason instead of sending the request to the backends, or replaces the completions
+	// of the response with it.
+	AIGatewayRouteRuleGuardrailActionBlock AIGatewayRouteRuleGuardrailAction = "Block"
+	// AIGatewayRouteRuleGuardrailActionFlag lets the request through, and adds the name of the guardrail to the
+	// "x-ai-eg-guardrail-flagged" response header.
+	AIGatewayRouteRuleGuardrailActionFlag AIGatewayRouteRuleGuardrailAction = "Flag"
+	// AIGatewayRouteRuleGuardrailActionAnnotate lets the request through, and only records the triggered
+	// categories in the dynamic metadata, e.g. for the access logs.
+	AIGatewayRouteRuleGuardrailActionAnnotate AIGatewayRouteRuleGuardrailAction = "Annotate"
+)
+
+// AIGatewayRouteRuleGuardrailFailureMode specifies what happens when the moderation backend of a guardrail fails.
+type AIGatewayRouteRuleGuardrailFailureMode string
+
+const (
+	// AIGatewayRouteRuleGuardrailFailureModeFailOpen lets the request through as if the guardrail was not triggered.
+	AIGatewayRouteRuleGuardrailFailureModeFailOpen AIGatewayRouteRuleGuardrailFailureMode = "FailOpen"
+	// AIGatewayRouteRuleGuardrailFailureModeFailClosed handles the request as if the guardrail was triggered.
+	AIGatewayRouteRuleGuardrailFailureModeFailClosed AIGatewayRouteRuleGuardrailFailureMode = "FailClosed"
+)
+
+// AIGatewayRouteRuleEmbeddings configures the processing of the embeddings requests of an AIGatewayRouteRule.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.maxInputsPerRequest) == has(self.batchURL)",message="maxInputsPerRequest and batchURL must be set together"
+type AIGatewayRouteRuleEmbeddings struct {
+	// MaxInputsPerRequest is the maximum number of inputs sent to the backends in a single request, which is
+	// the batch size limit of the providers, e.g. 1 for Amazon Bedrock Titan or 2048 for OpenAI.
+	//
+	// The requests with more inputs are split into the batches of this size, which are sent concurrently to
+	// BatchURL, and their responses are merged into a single response in the original order with the summed usage.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxInputsPerRequest *int32 `json:"maxInputsPerRequest,omitempty"`
+
+	// BatchURL is the URL of the "/v1/embeddings" endpoint of this Gateway that the batches are sent to, e.g.
+	// "http://envoy-default-my-gateway.envoy-gateway-system.svc:80/v1/embeddings". The batches carry the headers
+	// of the original request so that they are routed, authenticated and accounted for like the original request.
+	// The batches are marked with a header signed by the ai-gateway, so that the clients cannot mark their own
+	// requests as batches to bypass the splitting and the input cache.
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^https?://`
+	BatchURL *string `json:"batchURL,omitempty"`
+
+	// MaxConcurrency is the maximum number of the batches of a request sent concurrently.
+	//
+	// Note that the batches go through the Gateway again while the original request is in flight, so a split request
+	// holds up to 1 + MaxConcurrency requests and connections of the Gateway at once. They count towards the rate
+	// limits, the connection limits and the circuit breakers of the Gateway, which should be sized accordingly.
+	//
+	// Default is 4.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=4
+	MaxConcurrency *int32 `json:"maxConcurrency,omitempty"`
+
+	// BatchTimeout is the timeout of all the batches of a split request. Since the original request is held until
+	// its batches complete, this raises the message timeout of the ai-gateway in Envoy like the timeouts of
+	// the guardrails.
+	//
+	// Default is 30s.
+	//
+	// +optional
+	BatchTimeout *gwapiv1.Duration `json:"batchTimeout,omitempty"`
+
+	// InputCache caches the embedding of each input, so that only the inputs that have not been embedded
+	// recently are sent to the backends. The cached embeddings are keyed by the rule, the model, the encoding
+	// format and the dimensions, so the backends of the rule are expected to serve the same embedding model.
+	//
+	// The cached embeddings share the memory of the response cache of the external processor.
+	//
+	// +optional
+	InputCache *AIGatewayRouteRuleEmbeddingsInputCache `json:"inputCache,omitempty"`
+}
+
+// AIGatewayRouteRuleEmbeddingsInputCache configures the cache of the embeddings of the individual inputs.
+type AIGatewayRouteRuleEmbeddingsInputCache struct {
+	// TTL is how long a cached embedding is served.
+	//
+	// Default is 24h.
+	//
+	// +optional
+	TTL *gwapiv1.Duration `json:"ttl,omitempty"`
+}
+
+// AIGatewayRouteRuleResponseCache configures the response cache of an AIGatewayRouteRule.
+type AIGatewayRouteRuleResponseCache struct {
+	// TTL is how long a cached response is served.
+	//
+	// Default is 5m.
+	//
+	// +optional
+	TTL *gwapiv1.Duration `json:"ttl,omitempty"`
+
+	// ConsumerHeader is the name of the request header that identifies the consumer, e.g. the API key ID.
+	// When set, the cached responses are only served to the requests from the same consumer.
+	//
+	// The header is taken from the request as is, so the client can set it to any value unless it's overwritten
+	// by an authentication filter in front of the AI Gateway. The consumer authenticated with the consumer keys
+	// of the AIGatewayRoute always scopes the cache regardless of this field.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	ConsumerHeader *string `json:"consumerHeader,omitempty"`
+
+	// AllTemperatures specifies whether the requests are cached regardless of their "temperature".
+	// By default, only the requests with "temperature" set to 0 are cached since the others are expected
+	// to produce a different completion each time.
+	//
+	// +optional
+	AllTemperatures bool `json:"allTemperatures,omitempty"`
+
+	// IgnoreCacheControl specifies whether the Cache-Control header of the requests is ignored.
+	// By default, the "no-cache" directive skips the lookup, the "no-store" directive skips storing the response,
+	// and the "max-age" directive limits the age of the served response.
+	//
+	// +optional
+	IgnoreCacheControl bool `json:"ignoreCacheControl,omitempty"`
+
+	// Semantic enables the semantic cache in addition to the exact match, which serves the cached response of
+	// a request whose final user message is similar enough to the one of the request. The final user message is
+	// embedded through the configured embeddings backend, and compared with the ones of the cached responses.
+	//
+	// Only the requests that are identical except for the final user message are compared with each other,
+	// so that the responses are not reused across different system prompts, conversations, parameters or consumers.
+	//
+	// Since a similar prompt is much easier to guess than an identical one, the semantic cache is only used for
+	// the requests of the consumers authenticated with the consumer keys unless allowUnauthenticated is set.
+	//
+	// +optional
+	Semantic *AIGatewayRouteRuleSemanticCache `json:"semantic,omitempty"`
+}
+
+// AIGatewayRouteRuleSemanticCache configures the semantic cache of an AIGatewayRouteRule.
+type AIGatewayRouteRuleSemanticCache struct {
+	// BackendName is the name of the AIServiceBackend of the OpenAI compatible embeddings backend used to embed the
+	// final user messages. It must be in the same namespace as the AIGatewayRoute. The BackendSecurityPolicy of the
+	// AIServiceBackend is used to authenticate the requests.
+	//
+	// The AIServiceBackend must reference an Envoy Gateway Backend with an FQDN or IP endpoint, which the ai-gateway
+	// calls directly. The endpoint is called with "https" when the Backend has the TLS settings, it is targeted by
+	// a BackendTLSPolicy, or its port is 443. The hostname and the CA certificates of the BackendTLSPolicy, if any,
+	// are used to verify the certificate of the endpoint.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	BackendName string `json:"backendName"`
+
+	// Path is the path of the embeddings endpoint of the backend.
+	//
+	// Default is "/v1/embeddings".
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^/`
+	Path *string `json:"path,omitempty"`
+
+	// Model is the name of the embeddings model sent to the embeddings endpoint.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Model string `json:"model"`
+
+	// Threshold is the minimum cosine similarity between the final user messages to serve the cached response,
+	// as a decimal string between 0 and 1, e.g. "0.95". The higher the threshold, the fewer but more accurate hits.
+	//
+	// Default is "0.95".
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^(0(\.[0-9]+)?|1(\.0+)?)$`
+	// +kubebuilder:default="0.95"
+	Threshold *string `json:"threshold,omitempty"`
+
+	// AllowUnauthenticated enables the semantic cache for the requests without a consumer authenticated with
+	// the consumer keys. The cached responses of such requests are only scoped by the consumerHeader of the
+	// response cache if set, so any client can be served the response of a similar prompt of another client
+	// that sends the same header value.
+	//
+	// +optional
+	AllowUnauthenticated bool `json:"allowUnauthenticated,omitempty"`
+
+	// Timeout is the timeout of the request to the embeddings backend. The semantic cache is skipped for the request
+	// when the embeddings backend does not respond in time. Like the timeouts of the guardrails, this raises the
+	// message timeout of the ai-gateway in Envoy.
+	//
+	// Default is 2s.
+	//
+	// +optional
+	Timeout *gwapiv1.Duration `json:"timeout,omitempty"`
+}
+
+// AIGatewayRouteRuleRequestPolicy limits the parameters of the chat completion requests of an AIGatewayRouteRule.
+//
+// +kubebuilder:validation:XValidation:rule="!has(self.maxTokens) || self.maxTokens.max >= 1",message="maxTokens.max must be at least 1"
+// +kubebuilder:validation:XValidation:rule="!has(self.n) || self.n.max >= 1",message="n.max must be at least 1"
+// +kubebuilder:validation:XValidation:rule="!has(self.messages) || self.messages.max >= 1",message="messages.max must be at least 1"
+type AIGatewayRouteRuleRequestPolicy struct {
+	// MaxTokens limits the "max_tokens" and "max_completion_tokens" fields of the request.
+	// The requests without these fields are not affected.
+	//
+	// +optional
+	MaxTokens *AIGatewayRouteRuleRequestLimit `json:"maxTokens,omitempty"`
+
+	// N limits the "n" field of the request, i.e. the number of the choices to generate.
+	//
+	// +optional
+	N *AIGatewayRouteRuleRequestLimit `json:"n,omitempty"`
+
+	// Temperature limits the range of the "temperature" field of the request.
+	//
+	// +optional
+	Temperature *AIGatewayRouteRuleTemperatureLimit `json:"temperature,omitempty"`
+
+	// Tools limits the number of the tools of the request. When clamped, the tools beyond the limit are removed
+	// in the order of the request. Setting the max to 0 disallows the tools entirely.
+	//
+	// +optional
+	Tools *AIGatewayRouteRuleRequestLimit `json:"tools,omitempty"`
+
+	// Messages limits the number of the messages of the request. When clamped, the oldest messages are removed
+	// except for the system and developer messages, so that the conversation keeps its instructions and the most
+	// recent turns.
+	//
+	// +optional
+	Messages *AIGatewayRouteRuleRequestLimit `json:"messages,omitempty"`
+
+	// MaxBodySize is the maximum size of the request body, e.g. "8Mi". The larger requests are rejected.
+	//
+	// +optional
+	MaxBodySize *resource.Quantity `json:"maxBodySize,omitempty"`
+
+	// Images validates the images in the content of the messages of the request. The requests with the images
+	// violating the policy are rejected.
+	//
+	// +optional
+	Images *AIGatewayRouteRuleImagePolicy `json:"images,omitempty"`
+
+	// MaxToolSchemaDepth is the maximum nesting depth of the JSON schema of the parameters of each tool, where the
+	// schema object itself has the depth of 1. The requests with the deeper schemas are rejected.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxToolSchemaDepth *int32 `json:"maxToolSchemaDepth,omitempty"`
+}
+
+// AIGatewayRouteRuleImagePolicy validates the images in the content of the messages of the chat completion requests.
+//
+// The size and the type are only known for the images embedded as the base64 data URLs, so the images referenced
+// by the URLs are only counted towards MaxImages.
+type AIGatewayRouteRuleImagePolicy struct {
+	// MaxImages is the maximum number of the images across all the messages of the request.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxImages *int32 `json:"maxImages,omitempty"`
+
+	// MaxImageSize is the maximum decoded size of each image embedded as a data URL, e.g. "5Mi".
+	//
+	// +optional
+	MaxImageSize *resource.Quantity `json:"maxImageSize,omitempty"`
+
+	// AllowedMIMETypes is the list of the allowed MIME types of the images embedded as data URLs,
+	// e.g. "image/png" and "image/jpeg". When empty, any type is allowed.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=16
+	AllowedMIMETypes []string `json:"allowedMIMETypes,omitempty"`
+}
+
+// AIGatewayRouteRuleRequestLimitAction is the action taken when a request exceeds a limit of the request policy.
+type AIGatewayRouteRuleRequestLimitAction string
+
+const (
+	// AIGatewayRouteRuleRequestLimitActionClamp rewrites the parameter of the request to the limit.
+	AIGatewayRouteRuleRequestLimitActionClamp AIGatewayRouteRuleRequestLimitAction = "Clamp"
+	// AIGatewayRouteRuleRequestLimitActionReject rejects the request with a 400 Bad Request.
+	AIGatewayRouteRuleRequestLimitActionReject AIGatewayRouteRuleRequestLimitAction = "Reject"
+)
+
+// AIGatewayRouteRuleRequestLimit is the upper limit of an integer parameter of the request.
+type AIGatewayRouteRuleRequestLimit struct {
+	// Max is the maximum allowed value.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=0
+	Max int32 `json:"max"`
+
+	// Action is the action taken when the request exceeds the limit.
+	//
+	// Default is "Clamp".
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Clamp;Reject
+	// +kubebuilder:default=Clamp
+	Action *AIGatewayRouteRuleRequestLimitAction `json:"action,omitempty"`
+}
+
+// AIGatewayRouteRuleTemperatureLimit is the allowed range of the "temperature" field of the request.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.min) || has(self.max)",message="either min or max must be set"
+type AIGatewayRouteRuleTemperatureLimit struct {
+	// Min is the minimum allowed temperature as a decimal string, e.g. "0.2".
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Min *string `json:"min,omitempty"`
+
+	// Max is the maximum allowed temperature as a decimal string, e.g. "1.0".
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Max *string `json:"max,omitempty"`
+
+	// Action is the action taken when the temperature of the request is out of the range.
+	//
+	// Default is "Clamp".
+	//
+	// +optional
+	// +kubebuilder:validation:Enum=Clamp;Reject
+	// +kubebuilder:default=Clamp
+	Action *AIGatewayRouteRuleRequestLimitAction `json:"action,omitempty"`
+}
+
+// AIGatewayRouteRuleHedging configures the hedged requests of an AIGatewayRouteRule.
+type AIGatewayRouteRuleHedging struct {
+	// Delay is the time to wait for the response headers of a backend before sending a hedged request
+	// to another backend. This is the time to the response headers, not the time to the first token: once a
+	// backend has sent the response headers, no hedged request is sent even if it stalls before the first token
+	// of a streaming response.
+	//
+	// The delay is implemented as the per-try timeout of the retry policy of the route. When the route already
+	// has a smaller per-try timeout, e.g. configured with a BackendTrafficPolicy, that one is kept and the hedged
+	// request is sent after it instead.
+	//
+	// +kubebuilder:validation:Required
+	Delay gwapiv1.Duration `json:"delay"`
+
+	// MaxHedgedRequests is the maximum number of hedged requests sent in addition to the original request.
+	//
+	// Default is 1.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=3
+	// +kubebuilder:default=1
+	MaxHedgedRequests *int32 `json:"maxHedgedRequests,omitempty"`
+}
+
+// AIGatewayRouteRuleSessionAffinity configures where the session key of a request is extracted from.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.header) || (has(self.userField) && self.userField)",message="either header or userField must be set"
+type AIGatewayRouteRuleSessionAffinity struct {
+	// Header is the name of the request header that carries the session key, e.g. "x-session-id".
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	Header *string `json:"header,omitempty"`
+
+	// UserField specifies whether the "user" field of the chat completion request is used as the session key.
+	// When Header is also set, the "user" field is used only when the header is absent in the request.
+	//
+	// +optional
+	UserField bool `json:"userField,omitempty"`
+}
+
+// AIGatewayRouteRuleShadow configures the shadow traffic mirroring of an AIGatewayRouteRule.
+type AIGatewayRouteRuleShadow struct {
+	// Name is the name of the AIServiceBackend to mirror the traffic to. It must be in the same namespace
+	// as the AIGatewayRoute.
+	//
+	// The AIServiceBackend must reference an Envoy Gateway Backend with an FQDN or IP endpoint, which the ai-gateway
+	// calls directly. The endpoint is called with "https" when the Backend has the TLS settings, it is targeted by
+	// a BackendTLSPolicy, or its port is 443. The hostname and the CA certificates of the BackendTLSPolicy, if any,
+	// are used to verify the certificate of the endpoint.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Name of the model in the shadow backend. If provided this will override the name provided in the request.
+	ModelNameOverride string `json:"modelNameOverride,omitempty"`
+
+	// Percent is the percentage of the requests matching this rule that are mirrored to the shadow backend.
+	//
+	// Default is 100.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=100
+	Percent *int32 `json:"percent,omitempty"`
+
+	// Timeout is the timeout of the shadow request, including the wait for the result of the primary
+	// backend to compare with.
+	//
+	// Default is 60s.
+	//
+	// +optional
+	Timeout *gwapiv1.Duration `json:"timeout,omitempty"`
 }
 
 // AIGatewayRouteRuleBackendRef is a reference to a backend with a weight.
@@ -67,6 +67,110 @@ type AIServiceBackendSpec struct {
 	// +optional
 	BackendSecurityPolicyRef *gwapiv1.LocalObjectReference `json:"backendSecurityPolicyRef,omitempty"`
 
+	// TokenLimits declares the token limits of the model served by this backend.
+	//
+	// When this is set, the ai-gateway estimates the number of prompt tokens of each chat completion request
+	// before routing, and skips the rules whose backends cannot fit the request into their context window.
+	// See AIGatewayRouteRule.LongContextFallbackModel for how such requests can be upgraded to another model.
+	// If no rule can serve the request, the ai-gateway immediately returns an OpenAI-compatible
+	// "context_length_exceeded" error without calling the upstream.
+	//
+	// +optional
+	TokenLimits *AIServiceBackendTokenLimits `json:"tokenLimits,omitempty"`
+
+	// CircuitBreaker configures the circuit breaker of this backend in the ai-gateway.
+	//
+	// The circuit breaker tracks the consecutive failures of the requests sent to this backend, including the
+	// throttling errors of the provider (429 and 529). Once the threshold is reached, the circuit is opened for
+	// the cooldown period, during which the requests assigned to this backend fail immediately with 503 so that
+	// Envoy retries them on the other backends of the rule, without paying the latency of the failing backend.
+	// Unless the retry policy of the route already retries on 503, the ai-gateway configures the routes of the
+	// rules with multiple backends to retry on 503 once per other backend. When all the backends of a rule are
+	// open, the requests fail without calling any upstream. After the cooldown, a single probe request is let
+	// through to decide whether the circuit is closed again.
+	//
+	// +optional
+	CircuitBreaker *AIServiceBackendCircuitBreaker `json:"circuitBreaker,omitempty"`
+
+	// Pricing is the price list of the models served by this backend. The ai-gateway computes the monetary
+	// cost of each request from its token usage and the price of the model sent to this backend.
+	//
+	// The cost is recorded in the "gen_ai.client.request.cost" metric, and can be stored in the dynamic metadata
+	// with the "MicroUSD" type of AIGatewayRoute.spec.llmRequestCosts so that the rate limit can be configured
+	// with the budget in micro US dollars.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=64
+	Pricing []AIServiceBackendModelPricing `json:"pricing,omitempty"`
+
 	// TODO: maybe add backend-level LLMRequestCost configuration that overrides the AIGatewayRoute-level LLMRequestCost.
 	// 	That may be useful for the backend that has a different cost calculation logic.
 }
+
+// AIServiceBackendTokenLimits declares the token limits of the model served by an AIServiceBackend.
+type AIServiceBackendTokenLimits struct {
+	// ContextWindow is the maximum number of tokens, the prompt and the completion combined, that
+	// the model can handle in a single request.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Minimum=1
+	ContextWindow int32 `json:"contextWindow"`
+	// MaxOutputTokens is the maximum number of tokens that the model can generate in a single completion.
+	// Requests whose "max_tokens" exceeds this value are considered not to fit into this backend.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxOutputTokens *int32 `json:"maxOutputTokens,omitempty"`
+}
+
+// AIServiceBackendModelPricing is the price of a model in US dollars per million tokens,
+// which is the unit used by most of the providers in their price lists.
+type AIServiceBackendModelPricing struct {
+	// Model is the name of the model sent to the backend, i.e. after the modelNameOverride of the
+	// AIGatewayRoute is applied. "*" matches all the models that are not listed explicitly.
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Model string `json:"model"`
+
+	// InputPerMillionTokens is the price of a million input tokens in US dollars, e.g. "2.50".
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	InputPerMillionTokens string `json:"inputPerMillionTokens"`
+
+	// OutputPerMillionTokens is the price of a million output tokens in US dollars, e.g. "10".
+	//
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	OutputPerMillionTokens string `json:"outputPerMillionTokens"`
+
+	// CachedInputPerMillionTokens is the price of a million input tokens read from the prompt cache
+	// of the provider in US dollars, e.g. "1.25".
+	//
+	// Default is the same as InputPerMillionTokens.
+	//
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	CachedInputPerMillionTokens *string `json:"cachedInputPerMillionTokens,omitempty"`
+}
+
+// AIServiceBackendCircuitBreaker configures the circuit breaker of an AIServiceBackend.
+type AIServiceBackendCircuitBreaker struct {
+	// ConsecutiveFailures is the number of consecutive failures that opens the circuit.
+	//
+	// Default is 5.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=5
+	ConsecutiveFailures *int32 `json:"consecutiveFailures,omitempty"`
+
+	// Cooldown is the duration for which the circuit stays open. When the provider responds with
+	// the Retry-After header to a throttled request, the circuit stays open at least until then.
+	//
+	// Default is 30s.
+	//
+	// +optional
+	Cooldown *gwapiv1.Duration `json:"cooldown,omitempty"`
+}
@@ -50,7 +50,8 @@ type BackendSecurityPolicySpec struct {
 	// +kubebuilder:validation:Enum=APIKey;AWSCredentials;AzureCredentials;GCPCredentials
 	Type BackendSecurityPolicyType `json:"type"`
 
-	// APIKey is a mechanism to access a backend(s). The API key will be injected into the Authorization header.
+	// APIKey is a mechanism to access a backend(s). The API key will be injected into the Authorization header
+	// by default, or into the header or the query parameter specified in the APIKey.
 	//
 	// +optional
 	APIKey *BackendSecurityPolicyAPIKey `json:"apiKey,omitempty"`
@@ -80,11 +81,43 @@ type BackendSecurityPolicyList struct {
 }
 
 // BackendSecurityPolicyAPIKey specifies the API key.
+//
+// By default, the API key is set in the "Authorization" header with the "Bearer " prefix. The header and the prefix
+// can be changed for the providers using other headers such as "x-api-key", or the API key can be set in a query
+// parameter instead.
+//
+// +kubebuilder:validation:XValidation:rule="!has(self.queryParam) || (!has(self.header) && !has(self.prefix))",message="queryParam cannot be specified with header or prefix"
 type BackendSecurityPolicyAPIKey struct {
 	// SecretRef is the reference to the secret containing the API key.
 	// ai-gateway must be given the permission to read this secret.
 	// The key of the secret should be "apiKey".
 	SecretRef *gwapiv1.SecretObjectReference `json:"secretRef"`
+
+	// Header is the name of the request header to set the API key in, e.g. "x-api-key" or "x-goog-api-key".
+	// Defaults to "Authorization".
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9-]+$`
+	Header *string `json:"header,omitempty"`
+
+	// Prefix is prepended to the API key in the header value. Defaults to "Bearer " when the header is
+	// "Authorization", and to no prefix otherwise.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[^\r\n]*$`
+	Prefix *string `json:"prefix,omitempty"`
+
+	// QueryParam is the name of the query parameter to set the API key in instead of a header, e.g. "key".
+	// This cannot be specified with Header or Prefix.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_.~-]+$`
+	QueryParam *string `json:"queryParam,omitempty"`
 }
 
 // BackendSecurityPolicyOIDC specifies OIDC related fields.
@@ -182,22 +215,25 @@ type BackendSecurityPolicyGCPCredentials struct {
 }
 
 // BackendSecurityPolicyAzureCredentials contains the supported authentication mechanisms to access Azure.
-// Only one of ClientSecretRef or OIDCExchangeToken must be specified. Credentials will not be generated if
+// Only one of ClientSecretRef, OIDCExchangeToken or APIKeyRef must be specified. Credentials will not be generated if
 // neither are set.
 //
-// +kubebuilder:validation:XValidation:rule="(has(self.clientSecretRef) && !has(self.oidcExchangeToken)) || (!has(self.clientSecretRef) && has(self.oidcExchangeToken))",message="Exactly one of clientSecretRef or oidcExchangeToken must be specified"
+// +kubebuilder:validation:XValidation:rule="[has(self.clientSecretRef), has(self.oidcExchangeToken), has(self.apiKeyRef)].filter(x, x).size() == 1",message="Exactly one of clientSecretRef, oidcExchangeToken or apiKeyRef must be specified"
+// +kubebuilder:validation:XValidation:rule="has(self.apiKeyRef) || (has(self.clientID) && has(self.tenantID))",message="clientID and tenantID must be specified unless apiKeyRef is specified"
 type BackendSecurityPolicyAzureCredentials struct {
 	// ClientID is a unique identifier for an application in Azure.
+	// This is required unless APIKeyRef is specified.
 	//
-	// +kubebuilder:validation:Required
+	// +optional
 	// +kubebuilder:validation:MinLength=1
-	ClientID string `json:"clientID"`
+	ClientID string `json:"clientID,omitempty"`
 
 	// TenantId is a unique identifier for an Azure Active Directory instance.
+	// This is required unless APIKeyRef is specified.
 	//
-	// +kubebuilder:validation:Required
+	// +optional
 	// +kubebuilder:validation:MinLength=1
-	TenantID string `json:"tenantID"`
+	TenantID string `json:"tenantID,omitempty"`
 
 	// ClientSecretRef is the reference to the secret containing the Azure client secret.
 	// ai-gateway must be given the permission to read this secret.
@@ -211,6 +247,15 @@ type BackendSecurityPolicyAzureCredentials struct {
 	//
 	// +optional
 	OIDCExchangeToken *AzureOIDCExchangeToken `json:"oidcExchangeToken,omitempty"`
+
+	// APIKeyRef is the reference to the secret containing the API key of the Azure OpenAI resource, which is sent in
+	// the "api-key" header instead of an Entra ID access token. This is for the resources that only allow key
+	// authentication. The API key is used as is without being rotated.
+	// ai-gateway must be given the permission to read this secret.
+	// The key of the secret should be "apiKey".
+	//
+	// +optional
+	APIKeyRef *gwapiv1.SecretObjectReference `json:"apiKeyRef,omitempty"`
 }
 
 // AzureOIDCExchangeToken specifies credentials to obtain oidc token from a sso server.
@@ -68,6 +68,13 @@ const (
 	// AIModelHeaderKey is the header key whose value is extracted from the request by the ai-gateway.
 	// This can be used to describe the routing behavior in HTTPRoute referenced by AIGatewayRoute.
 	AIModelHeaderKey = "x-ai-eg-model"
+	// SessionKeyHeaderKey is the header key populated by the ai-gateway with the hash of the session key of the request
+	// when the matched AIGatewayRouteRule has the session affinity configured. Envoy hashes this header to pin the
+	// session to a backend. See AIGatewayRouteRule.SessionAffinity.
+	SessionKeyHeaderKey = "x-ai-eg-session-key"
+	// ConsumerHeaderKey is the header key populated by the ai-gateway with the consumer of the AIGatewayConsumerKey
+	// of the request to the AIGatewayRoute requiring the consumer keys. See AIGatewayRouteSpec.RequireConsumerKey.
+	ConsumerHeaderKey = "x-ai-eg-consumer"
 )
 
 // LLMRequestCost configures each request cost.
@@ -78,9 +85,25 @@ type LLMRequestCost struct {
 	MetadataKey string `json:"metadataKey"`
 	// Type specifies the type of the request cost. The default is "OutputToken",
 	// and it uses "output token" as the cost. The other types are "InputToken", "TotalToken",
-	// and "CEL".
+	// "EstimatedInputToken", "CachedInputToken", "ReasoningToken", "AudioInputToken", "AudioOutputToken",
+	// "MicroUSD", and "CEL".
 	//
-	// +kubebuilder:validation:Enum=OutputToken;InputToken;TotalToken;CEL
+	// "EstimatedInputToken" is the number of input tokens estimated by the ai-gateway with the tokenizer
+	// of the requested model before the request is sent to the backend. Unlike the other types, this is stored
+	// in the metadata on the request path, so it can be used as the request cost of the rate limit to reject
+	// a request that would exceed the budget before it reaches the backend. This is only available for
+	// the chat completion requests.
+	//
+	// "CachedInputToken" is the number of input tokens read from the prompt cache of the provider, and
+	// "ReasoningToken" is the number of output tokens used for reasoning. "AudioInputToken" and "AudioOutputToken"
+	// are the numbers of audio tokens in the input and output. These are part of the input or output tokens, and
+	// are zero when the backend does not report them.
+	//
+	// "MicroUSD" is the monetary cost of the request in micro US dollars, i.e. one millionth of a dollar,
+	// computed from the token usage and the pricing of the AIServiceBackend. This is zero when the model
+	// sent to the backend is not listed in its pricing.
+	//
+	// +kubebuilder:validation:Enum=OutputToken;InputToken;TotalToken;EstimatedInputToken;CachedInputToken;ReasoningToken;AudioInputToken;AudioOutputToken;MicroUSD;CEL
 	Type LLMRequestCostType `json:"type"`
 	// CEL is the CEL expression to calculate the cost of the request.
 	// The CEL expression must return a signed or unsigned integer. If the
@@ -93,6 +116,28 @@ type LLMRequestCost struct {
 	//	* input_tokens: the number of input tokens. Type: unsigned integer.
 	//	* output_tokens: the number of output tokens. Type: unsigned integer.
 	//	* total_tokens: the total number of tokens. Type: unsigned integer.
+	//	* estimated_input_tokens: the number of input tokens estimated before the request is sent to the backend.
+	//	  See the "EstimatedInputToken" type. Type: unsigned integer.
+	//	* cached_input_tokens: the number of input tokens read from the prompt cache of the provider,
+	//	  which are included in input_tokens. Type: unsigned integer.
+	//	* reasoning_tokens: the number of output tokens used for reasoning, which are included in output_tokens.
+	//	  Type: unsigned integer.
+	//	* audio_input_tokens, audio_output_tokens: the numbers of audio tokens in the input and output.
+	//	  Type: unsigned integer.
+	//	* request_headers: the request headers keyed by the lower-cased header name. Type: map of string to string.
+	//	  A missing header fails the evaluation, so use the optional syntax, e.g. request_headers[?'x-tier'].orValue('').
+	//	* stream: whether the request is a streaming request. Type: bool.
+	//	* latency_ms: the latency of the request to the backend in milliseconds. Type: unsigned integer.
+	//	* time_to_first_token_ms: the time to the first token in milliseconds of a streaming request, and zero
+	//	  otherwise. Type: unsigned integer.
+	//	* backend_schema: the name of the API schema of the backend, e.g. "OpenAI" or "AWSBedrock". Type: string.
+	//
+	// The CEL expression that uses estimated_input_tokens is also evaluated on the request path where the token
+	// usage is not known yet, so that the result can be used as the request cost of the rate limit,
+	// e.g. "input_tokens == uint(0) ? estimated_input_tokens : total_tokens". On the request path, the token usage,
+	// latency_ms, and time_to_first_token_ms are zero, and backend and backend_schema are empty since the backend
+	// is not selected yet. A failure of the evaluation on the request path does not fail the request, and the cost
+	// is only set on the response path in that case. The other CEL expressions are only evaluated on the response path.
 	//
 	// For example, the following expressions are valid:
 	//
@@ -100,6 +145,7 @@ type LLMRequestCost struct {
 	//	* "backend == 'foo.default' ?  input_tokens + output_tokens : total_tokens"
 	//	* "input_tokens + output_tokens + total_tokens"
 	//	* "input_tokens * output_tokens"
+	//	* "request_headers[?'x-tier'].orValue('') == 'free' ? total_tokens * uint(2) : total_tokens"
 	//
 	// +optional
 	CEL *string `json:"cel,omitempty"`
@@ -115,6 +161,19 @@ const (
 	LLMRequestCostTypeOutputToken LLMRequestCostType = "OutputToken"
 	// LLMRequestCostTypeTotalToken is the cost type of the total token.
 	LLMRequestCostTypeTotalToken LLMRequestCostType = "TotalToken"
+	// LLMRequestCostTypeEstimatedInputToken is the cost type of the input token estimated before the request
+	// is sent to the backend.
+	LLMRequestCostTypeEstimatedInputToken LLMRequestCostType = "EstimatedInputToken"
+	// LLMRequestCostTypeCachedInputToken is the cost type of the cached input token.
+	LLMRequestCostTypeCachedInputToken LLMRequestCostType = "CachedInputToken"
+	// LLMRequestCostTypeReasoningToken is the cost type of the reasoning token.
+	LLMRequestCostTypeReasoningToken LLMRequestCostType = "ReasoningToken"
+	// LLMRequestCostTypeAudioInputToken is the cost type of the audio input token.
+	LLMRequestCostTypeAudioInputToken LLMRequestCostType = "AudioInputToken"
+	// LLMRequestCostTypeAudioOutputToken is the cost type of the audio output token.
+	LLMRequestCostTypeAudioOutputToken LLMRequestCostType = "AudioOutputToken"
+	// LLMRequestCostTypeMicroUSD is the cost type of the monetary cost in micro US dollars.
+	LLMRequestCostTypeMicroUSD LLMRequestCostType = "MicroUSD"
 	// LLMRequestCostTypeCEL is for calculating the cost using the CEL expression.
 	LLMRequestCostTypeCEL LLMRequestCostType = "CEL"
 )
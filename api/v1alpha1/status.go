@@ -42,3 +42,12 @@ type BackendSecurityPolicyStatus struct {
 	// Known .status.conditions.type are: "Accepted", "NotAccepted".
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
+
+// AIGatewayConsumerKeyStatus contains the conditions by the reconciliation result.
+type AIGatewayConsumerKeyStatus struct {
+	// Conditions is the list of conditions by the reconciliation result.
+	// Currently, at most one condition is set.
+	//
+	// Known .status.conditions.type are: "Accepted", "NotAccepted".
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
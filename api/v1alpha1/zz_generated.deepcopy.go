@@ -1,8 +1,3 @@
-// Copyright Envoy AI Gateway Authors
-// SPDX-License-Identifier: Apache-2.0
-// The full text of the Apache license is available in the LICENSE file at
-// the root of the repo.
-
 //go:build !ignore_autogenerated
 
 // Code generated by controller-gen. DO NOT EDIT.
@@ -17,6 +12,121 @@ import (
 	"sigs.k8s.io/gateway-api/apis/v1alpha2"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayConsumerKey) DeepCopyInto(out *AIGatewayConsumerKey) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayConsumerKey.
+func (in *AIGatewayConsumerKey) DeepCopy() *AIGatewayConsumerKey {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayConsumerKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AIGatewayConsumerKey) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayConsumerKeyList) DeepCopyInto(out *AIGatewayConsumerKeyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AIGatewayConsumerKey, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayConsumerKeyList.
+func (in *AIGatewayConsumerKeyList) DeepCopy() *AIGatewayConsumerKeyList {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayConsumerKeyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AIGatewayConsumerKeyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayConsumerKeySpec) DeepCopyInto(out *AIGatewayConsumerKeySpec) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(v1.SecretObjectReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Models != nil {
+		in, out := &in.Models, &out.Models
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AIGatewayRouteNames != nil {
+		in, out := &in.AIGatewayRouteNames, &out.AIGatewayRouteNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayConsumerKeySpec.
+func (in *AIGatewayConsumerKeySpec) DeepCopy() *AIGatewayConsumerKeySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayConsumerKeySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayConsumerKeyStatus) DeepCopyInto(out *AIGatewayConsumerKeyStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayConsumerKeyStatus.
+func (in *AIGatewayConsumerKeyStatus) DeepCopy() *AIGatewayConsumerKeyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayConsumerKeyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AIGatewayFilterConfig) DeepCopyInto(out *AIGatewayFilterConfig) {
 	*out = *in
@@ -84,6 +194,26 @@ func (in *AIGatewayRoute) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteAuthorization) DeepCopyInto(out *AIGatewayRouteAuthorization) {
+	*out = *in
+	if in.ClaimsHeader != nil {
+		in, out := &in.ClaimsHeader, &out.ClaimsHeader
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteAuthorization.
+func (in *AIGatewayRouteAuthorization) DeepCopy() *AIGatewayRouteAuthorization {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteAuthorization)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AIGatewayRouteList) DeepCopyInto(out *AIGatewayRouteList) {
 	*out = *in
@@ -147,6 +277,63 @@ func (in *AIGatewayRouteRule) DeepCopyInto(out *AIGatewayRouteRule) {
 		in, out := &in.ModelsCreatedAt, &out.ModelsCreatedAt
 		*out = (*in).DeepCopy()
 	}
+	if in.LongContextFallbackModel != nil {
+		in, out := &in.LongContextFallbackModel, &out.LongContextFallbackModel
+		*out = new(string)
+		**out = **in
+	}
+	if in.Shadow != nil {
+		in, out := &in.Shadow, &out.Shadow
+		*out = new(AIGatewayRouteRuleShadow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SessionAffinity != nil {
+		in, out := &in.SessionAffinity, &out.SessionAffinity
+		*out = new(AIGatewayRouteRuleSessionAffinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hedging != nil {
+		in, out := &in.Hedging, &out.Hedging
+		*out = new(AIGatewayRouteRuleHedging)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RequestPolicy != nil {
+		in, out := &in.RequestPolicy, &out.RequestPolicy
+		*out = new(AIGatewayRouteRuleRequestPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResponseCache != nil {
+		in, out := &in.ResponseCache, &out.ResponseCache
+		*out = new(AIGatewayRouteRuleResponseCache)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Embeddings != nil {
+		in, out := &in.Embeddings, &out.Embeddings
+		*out = new(AIGatewayRouteRuleEmbeddings)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Redaction != nil {
+		in, out := &in.Redaction, &out.Redaction
+		*out = new(AIGatewayRouteRuleRedaction)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Guardrails != nil {
+		in, out := &in.Guardrails, &out.Guardrails
+		*out = make([]AIGatewayRouteRuleGuardrail, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.StreamModeration != nil {
+		in, out := &in.StreamModeration, &out.StreamModeration
+		*out = new(AIGatewayRouteRuleStreamModeration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AuditLog != nil {
+		in, out := &in.AuditLog, &out.AuditLog
+		*out = new(AIGatewayRouteRuleAuditLog)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRule.
@@ -159,6 +346,31 @@ func (in *AIGatewayRouteRule) DeepCopy() *AIGatewayRouteRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleAuditLog) DeepCopyInto(out *AIGatewayRouteRuleAuditLog) {
+	*out = *in
+	if in.SamplingRate != nil {
+		in, out := &in.SamplingRate, &out.SamplingRate
+		*out = new(string)
+		**out = **in
+	}
+	if in.RedactFields != nil {
+		in, out := &in.RedactFields, &out.RedactFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleAuditLog.
+func (in *AIGatewayRouteRuleAuditLog) DeepCopy() *AIGatewayRouteRuleAuditLog {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleAuditLog)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AIGatewayRouteRuleBackendRef) DeepCopyInto(out *AIGatewayRouteRuleBackendRef) {
 	*out = *in
@@ -184,6 +396,176 @@ func (in *AIGatewayRouteRuleBackendRef) DeepCopy() *AIGatewayRouteRuleBackendRef
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleEmbeddings) DeepCopyInto(out *AIGatewayRouteRuleEmbeddings) {
+	*out = *in
+	if in.MaxInputsPerRequest != nil {
+		in, out := &in.MaxInputsPerRequest, &out.MaxInputsPerRequest
+		*out = new(int32)
+		**out = **in
+	}
+	if in.BatchURL != nil {
+		in, out := &in.BatchURL, &out.BatchURL
+		*out = new(string)
+		**out = **in
+	}
+	if in.MaxConcurrency != nil {
+		in, out := &in.MaxConcurrency, &out.MaxConcurrency
+		*out = new(int32)
+		**out = **in
+	}
+	if in.BatchTimeout != nil {
+		in, out := &in.BatchTimeout, &out.BatchTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.InputCache != nil {
+		in, out := &in.InputCache, &out.InputCache
+		*out = new(AIGatewayRouteRuleEmbeddingsInputCache)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleEmbeddings.
+func (in *AIGatewayRouteRuleEmbeddings) DeepCopy() *AIGatewayRouteRuleEmbeddings {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleEmbeddings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleEmbeddingsInputCache) DeepCopyInto(out *AIGatewayRouteRuleEmbeddingsInputCache) {
+	*out = *in
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleEmbeddingsInputCache.
+func (in *AIGatewayRouteRuleEmbeddingsInputCache) DeepCopy() *AIGatewayRouteRuleEmbeddingsInputCache {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleEmbeddingsInputCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleGuardrail) DeepCopyInto(out *AIGatewayRouteRuleGuardrail) {
+	*out = *in
+	if in.Type != nil {
+		in, out := &in.Type, &out.Type
+		*out = new(AIGatewayRouteRuleGuardrailType)
+		**out = **in
+	}
+	if in.Path != nil {
+		in, out := &in.Path, &out.Path
+		*out = new(string)
+		**out = **in
+	}
+	if in.Model != nil {
+		in, out := &in.Model, &out.Model
+		*out = new(string)
+		**out = **in
+	}
+	if in.Phases != nil {
+		in, out := &in.Phases, &out.Phases
+		*out = make([]AIGatewayRouteRuleGuardrailPhase, len(*in))
+		copy(*out, *in)
+	}
+	if in.Categories != nil {
+		in, out := &in.Categories, &out.Categories
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Threshold != nil {
+		in, out := &in.Threshold, &out.Threshold
+		*out = new(string)
+		**out = **in
+	}
+	if in.Action != nil {
+		in, out := &in.Action, &out.Action
+		*out = new(AIGatewayRouteRuleGuardrailAction)
+		**out = **in
+	}
+	if in.FailureMode != nil {
+		in, out := &in.FailureMode, &out.FailureMode
+		*out = new(AIGatewayRouteRuleGuardrailFailureMode)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleGuardrail.
+func (in *AIGatewayRouteRuleGuardrail) DeepCopy() *AIGatewayRouteRuleGuardrail {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleGuardrail)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleHedging) DeepCopyInto(out *AIGatewayRouteRuleHedging) {
+	*out = *in
+	if in.MaxHedgedRequests != nil {
+		in, out := &in.MaxHedgedRequests, &out.MaxHedgedRequests
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleHedging.
+func (in *AIGatewayRouteRuleHedging) DeepCopy() *AIGatewayRouteRuleHedging {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleHedging)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleImagePolicy) DeepCopyInto(out *AIGatewayRouteRuleImagePolicy) {
+	*out = *in
+	if in.MaxImages != nil {
+		in, out := &in.MaxImages, &out.MaxImages
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxImageSize != nil {
+		in, out := &in.MaxImageSize, &out.MaxImageSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.AllowedMIMETypes != nil {
+		in, out := &in.AllowedMIMETypes, &out.AllowedMIMETypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleImagePolicy.
+func (in *AIGatewayRouteRuleImagePolicy) DeepCopy() *AIGatewayRouteRuleImagePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleImagePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AIGatewayRouteRuleMatch) DeepCopyInto(out *AIGatewayRouteRuleMatch) {
 	*out = *in
@@ -206,6 +588,303 @@ func (in *AIGatewayRouteRuleMatch) DeepCopy() *AIGatewayRouteRuleMatch {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleRedaction) DeepCopyInto(out *AIGatewayRouteRuleRedaction) {
+	*out = *in
+	if in.Detectors != nil {
+		in, out := &in.Detectors, &out.Detectors
+		*out = make([]AIGatewayRouteRuleRedactionDetector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.HashKeyRef != nil {
+		in, out := &in.HashKeyRef, &out.HashKeyRef
+		*out = new(v1.SecretObjectReference)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleRedaction.
+func (in *AIGatewayRouteRuleRedaction) DeepCopy() *AIGatewayRouteRuleRedaction {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleRedaction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleRedactionDetector) DeepCopyInto(out *AIGatewayRouteRuleRedactionDetector) {
+	*out = *in
+	if in.Builtin != nil {
+		in, out := &in.Builtin, &out.Builtin
+		*out = new(AIGatewayRouteRuleRedactionBuiltinDetector)
+		**out = **in
+	}
+	if in.Pattern != nil {
+		in, out := &in.Pattern, &out.Pattern
+		*out = new(string)
+		**out = **in
+	}
+	if in.Validator != nil {
+		in, out := &in.Validator, &out.Validator
+		*out = new(AIGatewayRouteRuleRedactionValidator)
+		**out = **in
+	}
+	if in.Action != nil {
+		in, out := &in.Action, &out.Action
+		*out = new(AIGatewayRouteRuleRedactionAction)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleRedactionDetector.
+func (in *AIGatewayRouteRuleRedactionDetector) DeepCopy() *AIGatewayRouteRuleRedactionDetector {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleRedactionDetector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleRequestLimit) DeepCopyInto(out *AIGatewayRouteRuleRequestLimit) {
+	*out = *in
+	if in.Action != nil {
+		in, out := &in.Action, &out.Action
+		*out = new(AIGatewayRouteRuleRequestLimitAction)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleRequestLimit.
+func (in *AIGatewayRouteRuleRequestLimit) DeepCopy() *AIGatewayRouteRuleRequestLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleRequestLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleRequestPolicy) DeepCopyInto(out *AIGatewayRouteRuleRequestPolicy) {
+	*out = *in
+	if in.MaxTokens != nil {
+		in, out := &in.MaxTokens, &out.MaxTokens
+		*out = new(AIGatewayRouteRuleRequestLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.N != nil {
+		in, out := &in.N, &out.N
+		*out = new(AIGatewayRouteRuleRequestLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Temperature != nil {
+		in, out := &in.Temperature, &out.Temperature
+		*out = new(AIGatewayRouteRuleTemperatureLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tools != nil {
+		in, out := &in.Tools, &out.Tools
+		*out = new(AIGatewayRouteRuleRequestLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Messages != nil {
+		in, out := &in.Messages, &out.Messages
+		*out = new(AIGatewayRouteRuleRequestLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxBodySize != nil {
+		in, out := &in.MaxBodySize, &out.MaxBodySize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Images != nil {
+		in, out := &in.Images, &out.Images
+		*out = new(AIGatewayRouteRuleImagePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxToolSchemaDepth != nil {
+		in, out := &in.MaxToolSchemaDepth, &out.MaxToolSchemaDepth
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleRequestPolicy.
+func (in *AIGatewayRouteRuleRequestPolicy) DeepCopy() *AIGatewayRouteRuleRequestPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleRequestPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleResponseCache) DeepCopyInto(out *AIGatewayRouteRuleResponseCache) {
+	*out = *in
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ConsumerHeader != nil {
+		in, out := &in.ConsumerHeader, &out.ConsumerHeader
+		*out = new(string)
+		**out = **in
+	}
+	if in.Semantic != nil {
+		in, out := &in.Semantic, &out.Semantic
+		*out = new(AIGatewayRouteRuleSemanticCache)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleResponseCache.
+func (in *AIGatewayRouteRuleResponseCache) DeepCopy() *AIGatewayRouteRuleResponseCache {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleResponseCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleSemanticCache) DeepCopyInto(out *AIGatewayRouteRuleSemanticCache) {
+	*out = *in
+	if in.Path != nil {
+		in, out := &in.Path, &out.Path
+		*out = new(string)
+		**out = **in
+	}
+	if in.Threshold != nil {
+		in, out := &in.Threshold, &out.Threshold
+		*out = new(string)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleSemanticCache.
+func (in *AIGatewayRouteRuleSemanticCache) DeepCopy() *AIGatewayRouteRuleSemanticCache {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleSemanticCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleSessionAffinity) DeepCopyInto(out *AIGatewayRouteRuleSessionAffinity) {
+	*out = *in
+	if in.Header != nil {
+		in, out := &in.Header, &out.Header
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleSessionAffinity.
+func (in *AIGatewayRouteRuleSessionAffinity) DeepCopy() *AIGatewayRouteRuleSessionAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleSessionAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleShadow) DeepCopyInto(out *AIGatewayRouteRuleShadow) {
+	*out = *in
+	if in.Percent != nil {
+		in, out := &in.Percent, &out.Percent
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleShadow.
+func (in *AIGatewayRouteRuleShadow) DeepCopy() *AIGatewayRouteRuleShadow {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleShadow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleStreamModeration) DeepCopyInto(out *AIGatewayRouteRuleStreamModeration) {
+	*out = *in
+	if in.WindowSize != nil {
+		in, out := &in.WindowSize, &out.WindowSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.DenyPatterns != nil {
+		in, out := &in.DenyPatterns, &out.DenyPatterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleStreamModeration.
+func (in *AIGatewayRouteRuleStreamModeration) DeepCopy() *AIGatewayRouteRuleStreamModeration {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleStreamModeration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteRuleTemperatureLimit) DeepCopyInto(out *AIGatewayRouteRuleTemperatureLimit) {
+	*out = *in
+	if in.Min != nil {
+		in, out := &in.Min, &out.Min
+		*out = new(string)
+		**out = **in
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = new(string)
+		**out = **in
+	}
+	if in.Action != nil {
+		in, out := &in.Action, &out.Action
+		*out = new(AIGatewayRouteRuleRequestLimitAction)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteRuleTemperatureLimit.
+func (in *AIGatewayRouteRuleTemperatureLimit) DeepCopy() *AIGatewayRouteRuleTemperatureLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteRuleTemperatureLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AIGatewayRouteSpec) DeepCopyInto(out *AIGatewayRouteSpec) {
 	*out = *in
@@ -236,6 +915,18 @@ func (in *AIGatewayRouteSpec) DeepCopyInto(out *AIGatewayRouteSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.TokenQuotas != nil {
+		in, out := &in.TokenQuotas, &out.TokenQuotas
+		*out = make([]AIGatewayRouteTokenQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(AIGatewayRouteAuthorization)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteSpec.
@@ -270,6 +961,36 @@ func (in *AIGatewayRouteStatus) DeepCopy() *AIGatewayRouteStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIGatewayRouteTokenQuota) DeepCopyInto(out *AIGatewayRouteTokenQuota) {
+	*out = *in
+	if in.Type != nil {
+		in, out := &in.Type, &out.Type
+		*out = new(LLMRequestCostType)
+		**out = **in
+	}
+	if in.TokensPerMinute != nil {
+		in, out := &in.TokensPerMinute, &out.TokensPerMinute
+		*out = new(int64)
+		**out = **in
+	}
+	if in.TokensPerDay != nil {
+		in, out := &in.TokensPerDay, &out.TokensPerDay
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIGatewayRouteTokenQuota.
+func (in *AIGatewayRouteTokenQuota) DeepCopy() *AIGatewayRouteTokenQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(AIGatewayRouteTokenQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AIServiceBackend) DeepCopyInto(out *AIServiceBackend) {
 	*out = *in
@@ -297,6 +1018,31 @@ func (in *AIServiceBackend) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIServiceBackendCircuitBreaker) DeepCopyInto(out *AIServiceBackendCircuitBreaker) {
+	*out = *in
+	if in.ConsecutiveFailures != nil {
+		in, out := &in.ConsecutiveFailures, &out.ConsecutiveFailures
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Cooldown != nil {
+		in, out := &in.Cooldown, &out.Cooldown
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIServiceBackendCircuitBreaker.
+func (in *AIServiceBackendCircuitBreaker) DeepCopy() *AIServiceBackendCircuitBreaker {
+	if in == nil {
+		return nil
+	}
+	out := new(AIServiceBackendCircuitBreaker)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AIServiceBackendList) DeepCopyInto(out *AIServiceBackendList) {
 	*out = *in
@@ -329,6 +1075,26 @@ func (in *AIServiceBackendList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIServiceBackendModelPricing) DeepCopyInto(out *AIServiceBackendModelPricing) {
+	*out = *in
+	if in.CachedInputPerMillionTokens != nil {
+		in, out := &in.CachedInputPerMillionTokens, &out.CachedInputPerMillionTokens
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIServiceBackendModelPricing.
+func (in *AIServiceBackendModelPricing) DeepCopy() *AIServiceBackendModelPricing {
+	if in == nil {
+		return nil
+	}
+	out := new(AIServiceBackendModelPricing)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AIServiceBackendSpec) DeepCopyInto(out *AIServiceBackendSpec) {
 	*out = *in
@@ -339,6 +1105,23 @@ func (in *AIServiceBackendSpec) DeepCopyInto(out *AIServiceBackendSpec) {
 		*out = new(v1.LocalObjectReference)
 		**out = **in
 	}
+	if in.TokenLimits != nil {
+		in, out := &in.TokenLimits, &out.TokenLimits
+		*out = new(AIServiceBackendTokenLimits)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(AIServiceBackendCircuitBreaker)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Pricing != nil {
+		in, out := &in.Pricing, &out.Pricing
+		*out = make([]AIServiceBackendModelPricing, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIServiceBackendSpec.
@@ -373,6 +1156,26 @@ func (in *AIServiceBackendStatus) DeepCopy() *AIServiceBackendStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIServiceBackendTokenLimits) DeepCopyInto(out *AIServiceBackendTokenLimits) {
+	*out = *in
+	if in.MaxOutputTokens != nil {
+		in, out := &in.MaxOutputTokens, &out.MaxOutputTokens
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIServiceBackendTokenLimits.
+func (in *AIServiceBackendTokenLimits) DeepCopy() *AIServiceBackendTokenLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(AIServiceBackendTokenLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AWSCredentialsFile) DeepCopyInto(out *AWSCredentialsFile) {
 	*out = *in
@@ -460,6 +1263,21 @@ func (in *BackendSecurityPolicyAPIKey) DeepCopyInto(out *BackendSecurityPolicyAP
 		*out = new(v1.SecretObjectReference)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Header != nil {
+		in, out := &in.Header, &out.Header
+		*out = new(string)
+		**out = **in
+	}
+	if in.Prefix != nil {
+		in, out := &in.Prefix, &out.Prefix
+		*out = new(string)
+		**out = **in
+	}
+	if in.QueryParam != nil {
+		in, out := &in.QueryParam, &out.QueryParam
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackendSecurityPolicyAPIKey.
@@ -510,6 +1328,11 @@ func (in *BackendSecurityPolicyAzureCredentials) DeepCopyInto(out *BackendSecuri
 		*out = new(AzureOIDCExchangeToken)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.APIKeyRef != nil {
+		in, out := &in.APIKeyRef, &out.APIKeyRef
+		*out = new(v1.SecretObjectReference)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackendSecurityPolicyAzureCredentials.
@@ -31,17 +31,29 @@ import (
 )
 
 type flags struct {
-	extProcLogLevel        string
-	extProcImage           string
-	extProcImagePullPolicy corev1.PullPolicy
-	enableLeaderElection   bool
-	logLevel               zapcore.Level
-	extensionServerPort    string
-	tlsCertDir             string
-	tlsCertName            string
-	tlsKeyName             string
-	caBundleName           string
-	envoyGatewayNamespace  string
+	extProcLogLevel string
+	// extProcTokenQuotaStoreURL is the URL of the Redis-compatible store of the token quotas for the external processor.
+	extProcTokenQuotaStoreURL string
+	// extProcUsageLedgerSink is the sink of the usage ledger of the external processor.
+	extProcUsageLedgerSink string
+	// extProcAuditLogSink is the sink of the audit log of the external processor.
+	extProcAuditLogSink string
+	// extProcResponseCacheMaxSizeMB is the maximum size of the response cache of the external processor,
+	// or zero to use the default of the external processor.
+	extProcResponseCacheMaxSizeMB int
+	// extProcMountBackendCredentials is true if the backend credentials are mounted into the external processor
+	// as files instead of being inlined in the filter config.
+	extProcMountBackendCredentials bool
+	extProcImage                   string
+	extProcImagePullPolicy         corev1.PullPolicy
+	enableLeaderElection           bool
+	logLevel                       zapcore.Level
+	extensionServerPort            string
+	tlsCertDir                     string
+	tlsCertName                    string
+	tlsKeyName                     string
+	caBundleName                   string
+	envoyGatewayNamespace          string
 }
 
 // parsePullPolicy parses string into a k8s PullPolicy.
@@ -64,6 +76,38 @@ func parseAndValidateFlags(args []string) (flags, error) {
 		"info",
 		"The log level for the external processor. One of 'debug', 'info', 'warn', or 'error'.",
 	)
+	extProcTokenQuotaStoreURLPtr := fs.String(
+		"extProcTokenQuotaStoreURL",
+		"",
+		"The URL of the Redis-compatible store shared by the external processors to keep the token quota usage, "+
+			"e.g. redis://redis.default.svc:6379/0. The usage is kept in memory of each external processor if not set.",
+	)
+	extProcUsageLedgerSinkPtr := fs.String(
+		"extProcUsageLedgerSink",
+		"",
+		"The sink of the usage ledger of the external processor that records the token usage and the cost of every request. "+
+			"One of file:///path/to/usage.jsonl?maxSizeMB=100&maxBackups=5, an http(s):// webhook URL, or 'otlp'. "+
+			"The usage ledger is disabled if not set.",
+	)
+	extProcAuditLogSinkPtr := fs.String(
+		"extProcAuditLogSink",
+		"",
+		"The sink of the audit log of the external processor that records the prompts and the completions of the "+
+			"route rules with the audit log. One of file:///path/to/audit.jsonl?maxSizeMB=100&maxBackups=5 or 'otlp'. "+
+			"The audit log is disabled if not set.",
+	)
+	extProcResponseCacheMaxSizeMBPtr := fs.Int(
+		"extProcResponseCacheMaxSizeMB",
+		0,
+		"The maximum size in megabytes of the in-memory response cache of the external processor. "+
+			"The default of the external processor is used if not set.",
+	)
+	extProcMountBackendCredentialsPtr := fs.Bool(
+		"extProcMountBackendCredentials",
+		false,
+		"Mount the backend credentials into the external processor as files, which are reloaded on rotation, "+
+			"instead of inlining them in the filter config Secret.",
+	)
 	extProcImagePtr := fs.String(
 		"extProcImage",
 		"docker.io/envoyproxy/ai-gateway-extproc:latest",
@@ -138,17 +182,22 @@ func parseAndValidateFlags(args []string) (flags, error) {
 	}
 
 	return flags{
-		extProcLogLevel:        *extProcLogLevelPtr,
-		extProcImage:           *extProcImagePtr,
-		extProcImagePullPolicy: extProcPullPolicy,
-		enableLeaderElection:   *enableLeaderElectionPtr,
-		logLevel:               zapLogLevel,
-		extensionServerPort:    *extensionServerPortPtr,
-		tlsCertDir:             *tlsCertDir,
-		tlsCertName:            *tlsCertName,
-		tlsKeyName:             *tlsKeyName,
-		caBundleName:           *caBundleName,
-		envoyGatewayNamespace:  *envoyGatewayNamespace,
+		extProcLogLevel:                *extProcLogLevelPtr,
+		extProcTokenQuotaStoreURL:      *extProcTokenQuotaStoreURLPtr,
+		extProcUsageLedgerSink:         *extProcUsageLedgerSinkPtr,
+		extProcAuditLogSink:            *extProcAuditLogSinkPtr,
+		extProcResponseCacheMaxSizeMB:  *extProcResponseCacheMaxSizeMBPtr,
+		extProcMountBackendCredentials: *extProcMountBackendCredentialsPtr,
+		extProcImage:                   *extProcImagePtr,
+		extProcImagePullPolicy:         extProcPullPolicy,
+		enableLeaderElection:           *enableLeaderElectionPtr,
+		logLevel:                       zapLogLevel,
+		extensionServerPort:            *extensionServerPortPtr,
+		tlsCertDir:                     *tlsCertDir,
+		tlsCertName:                    *tlsCertName,
+		tlsKeyName:                     *tlsKeyName,
+		caBundleName:                   *caBundleName,
+		envoyGatewayNamespace:          *envoyGatewayNamespace,
 	}, nil
 }
 
@@ -216,12 +265,17 @@ func main() {
 
 	// Start the controller.
 	if err := controller.StartControllers(ctx, mgr, k8sConfig, ctrl.Log.WithName("controller"), controller.Options{
-		ExtProcImage:           flags.extProcImage,
-		ExtProcImagePullPolicy: flags.extProcImagePullPolicy,
-		ExtProcLogLevel:        flags.extProcLogLevel,
-		EnableLeaderElection:   flags.enableLeaderElection,
-		EnvoyGatewayNamespace:  flags.envoyGatewayNamespace,
-		UDSPath:                extProcUDSPath,
+		ExtProcImage:                   flags.extProcImage,
+		ExtProcImagePullPolicy:         flags.extProcImagePullPolicy,
+		ExtProcLogLevel:                flags.extProcLogLevel,
+		ExtProcTokenQuotaStoreURL:      flags.extProcTokenQuotaStoreURL,
+		ExtProcUsageLedgerSink:         flags.extProcUsageLedgerSink,
+		ExtProcAuditLogSink:            flags.extProcAuditLogSink,
+		ExtProcResponseCacheMaxSizeMB:  flags.extProcResponseCacheMaxSizeMB,
+		ExtProcMountBackendCredentials: flags.extProcMountBackendCredentials,
+		EnableLeaderElection:           flags.enableLeaderElection,
+		EnvoyGatewayNamespace:          flags.envoyGatewayNamespace,
+		UDSPath:                        extProcUDSPath,
 	}); err != nil {
 		setupLog.Error(err, "failed to start controller")
 	}
@@ -21,6 +21,11 @@ func Test_parseAndValidateFlags(t *testing.T) {
 	t.Run("no flags", func(t *testing.T) {
 		f, err := parseAndValidateFlags([]string{})
 		require.Equal(t, "info", f.extProcLogLevel)
+		require.Empty(t, f.extProcTokenQuotaStoreURL)
+		require.Empty(t, f.extProcUsageLedgerSink)
+		require.Empty(t, f.extProcAuditLogSink)
+		require.Zero(t, f.extProcResponseCacheMaxSizeMB)
+		require.False(t, f.extProcMountBackendCredentials)
 		require.Equal(t, "docker.io/envoyproxy/ai-gateway-extproc:latest", f.extProcImage)
 		require.Equal(t, corev1.PullIfNotPresent, f.extProcImagePullPolicy)
 		require.True(t, f.enableLeaderElection)
@@ -43,6 +48,11 @@ func Test_parseAndValidateFlags(t *testing.T) {
 			t.Run(tc.name, func(t *testing.T) {
 				args := []string{
 					tc.dash + "extProcLogLevel=debug",
+					tc.dash + "extProcTokenQuotaStoreURL=redis://localhost:6379/0",
+					tc.dash + "extProcUsageLedgerSink=file:///var/log/usage.jsonl",
+					tc.dash + "extProcAuditLogSink=otlp",
+					tc.dash + "extProcResponseCacheMaxSizeMB=256",
+					tc.dash + "extProcMountBackendCredentials",
 					tc.dash + "extProcImage=example.com/extproc:latest",
 					tc.dash + "extProcImagePullPolicy=Always",
 					tc.dash + "enableLeaderElection=false",
@@ -51,6 +61,11 @@ func Test_parseAndValidateFlags(t *testing.T) {
 				}
 				f, err := parseAndValidateFlags(args)
 				require.Equal(t, "debug", f.extProcLogLevel)
+				require.Equal(t, "redis://localhost:6379/0", f.extProcTokenQuotaStoreURL)
+				require.Equal(t, "file:///var/log/usage.jsonl", f.extProcUsageLedgerSink)
+				require.Equal(t, "otlp", f.extProcAuditLogSink)
+				require.Equal(t, 256, f.extProcResponseCacheMaxSizeMB)
+				require.True(t, f.extProcMountBackendCredentials)
 				require.Equal(t, "example.com/extproc:latest", f.extProcImage)
 				require.Equal(t, corev1.PullAlways, f.extProcImagePullPolicy)
 				require.False(t, f.enableLeaderElection)
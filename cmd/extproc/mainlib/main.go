@@ -31,6 +31,9 @@ import (
 
 	"github.com/envoyproxy/ai-gateway/filterapi/x"
 	"github.com/envoyproxy/ai-gateway/internal/extproc"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/audit"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/ledger"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/quota"
 	"github.com/envoyproxy/ai-gateway/internal/metrics"
 	"github.com/envoyproxy/ai-gateway/internal/version"
 )
@@ -42,6 +45,14 @@ type extProcFlags struct {
 	logLevel    slog.Level // log level for the external processor.
 	metricsPort int        // HTTP port for the metrics server.
 	healthPort  int        // HTTP port for the health check server.
+	// tokenQuotaStoreURL is the URL of the Redis-compatible store of the token quotas, or empty to keep them in memory.
+	tokenQuotaStoreURL string
+	// usageLedgerSink is the sink of the usage records, or empty to disable the usage ledger.
+	usageLedgerSink string
+	// auditLogSink is the sink of the audit records, or empty to disable the audit log.
+	auditLogSink string
+	// responseCacheMaxSizeMB is the maximum size of the response cache in megabytes.
+	responseCacheMaxSizeMB int
 }
 
 // parseAndValidateFlags parses and validates the flags passed to the external processor.
@@ -70,6 +81,31 @@ func parseAndValidateFlags(args []string) (extProcFlags, error) {
 	)
 	fs.IntVar(&flags.metricsPort, "metricsPort", 1064, "port for the metrics server.")
 	fs.IntVar(&flags.healthPort, "healthPort", 1065, "port for the health check HTTP server.")
+	fs.StringVar(&flags.tokenQuotaStoreURL,
+		"tokenQuotaStoreURL",
+		"",
+		"URL of the Redis-compatible store shared by the external processors to keep the token quota usage, "+
+			"for example, redis://localhost:6379/0. The usage is kept in memory of each external processor if not set.",
+	)
+	fs.StringVar(&flags.usageLedgerSink,
+		"usageLedgerSink",
+		"",
+		"sink of the usage ledger that records the token usage and the cost of every request. One of "+
+			"file:///path/to/usage.jsonl?maxSizeMB=100&maxBackups=5, an http(s):// webhook URL, or 'otlp' configured "+
+			"with the OTEL_EXPORTER_OTLP_* environment variables. The usage ledger is disabled if not set.",
+	)
+	fs.StringVar(&flags.auditLogSink,
+		"auditLogSink",
+		"",
+		"sink of the audit log that records the prompts and the completions of the route rules with the audit log. "+
+			"One of file:///path/to/audit.jsonl?maxSizeMB=100&maxBackups=5 or 'otlp' configured with the "+
+			"OTEL_EXPORTER_OTLP_* environment variables. The audit log is disabled if not set.",
+	)
+	fs.IntVar(&flags.responseCacheMaxSizeMB,
+		"responseCacheMaxSizeMB",
+		64,
+		"maximum size in megabytes of the in-memory response cache of the route rules with the response cache or the embeddings input cache enabled.",
+	)
 
 	if err := fs.Parse(args); err != nil {
 		return extProcFlags{}, fmt.Errorf("failed to parse extProcFlags: %w", err)
@@ -78,6 +114,9 @@ func parseAndValidateFlags(args []string) (extProcFlags, error) {
 	if flags.configPath == "" {
 		errs = append(errs, fmt.Errorf("configPath must be provided"))
 	}
+	if flags.responseCacheMaxSizeMB <= 0 {
+		errs = append(errs, fmt.Errorf("responseCacheMaxSizeMB must be positive"))
+	}
 	if err := flags.logLevel.UnmarshalText([]byte(*logLevelPtr)); err != nil {
 		errs = append(errs, fmt.Errorf("failed to unmarshal log level: %w", err))
 	}
@@ -129,13 +168,53 @@ func Main(ctx context.Context, args []string, stderr io.Writer) (err error) {
 	metricsServer, meter := startMetricsServer(fmt.Sprintf(":%d", flags.metricsPort), l)
 	chatCompletionMetrics := metrics.NewChatCompletion(meter, x.NewCustomChatCompletionMetrics)
 	embeddingsMetrics := metrics.NewEmbeddings(meter)
+	shadowMetrics := metrics.NewShadow(meter)
+	circuitBreakers := extproc.NewCircuitBreakers(metrics.NewCircuitBreaker(meter))
+	// The state of the circuit breakers is served along with the metrics for the operators.
+	metricsServer.Handler.(*http.ServeMux).Handle("/circuit_breakers", circuitBreakers)
+
+	responseCache := extproc.NewResponseCache(int64(flags.responseCacheMaxSizeMB)<<20, metrics.NewResponseCache(meter))
+
+	quotaStore := quota.NewMemoryStore()
+	if flags.tokenQuotaStoreURL != "" {
+		if quotaStore, err = quota.NewRedisStore(flags.tokenQuotaStoreURL); err != nil {
+			return fmt.Errorf("failed to create token quota store: %w", err)
+		}
+	}
+
+	var usageLedger *ledger.Ledger
+	if flags.usageLedgerSink != "" {
+		sink, err := ledger.NewSink(ctx, flags.usageLedgerSink)
+		if err != nil {
+			return fmt.Errorf("failed to create usage ledger sink: %w", err)
+		}
+		usageLedger = ledger.New(sink, l)
+	}
+
+	var auditLogger *audit.Logger
+	if flags.auditLogSink != "" {
+		sink, err := audit.NewSink(ctx, flags.auditLogSink)
+		if err != nil {
+			return fmt.Errorf("failed to create audit log sink: %w", err)
+		}
+		auditLogger = audit.New(sink, l)
+	}
 
 	server, err := extproc.NewServer(l)
 	if err != nil {
 		return fmt.Errorf("failed to create external processor server: %w", err)
 	}
-	server.Register("/v1/chat/completions", extproc.ChatCompletionProcessorFactory(chatCompletionMetrics))
-	server.Register("/v1/embeddings", extproc.EmbeddingsProcessorFactory(embeddingsMetrics))
+	processorOptions := extproc.ProcessorOptions{
+		ShadowMetrics:    shadowMetrics,
+		CircuitBreakers:  circuitBreakers,
+		Quotas:           quota.New(quotaStore),
+		UsageLedger:      usageLedger,
+		ResponseCache:    responseCache,
+		GuardrailMetrics: metrics.NewGuardrail(meter),
+		AuditLogger:      auditLogger,
+	}
+	server.Register("/v1/chat/completions", extproc.ChatCompletionProcessorFactory(chatCompletionMetrics, processorOptions))
+	server.Register("/v1/embeddings", extproc.EmbeddingsProcessorFactory(embeddingsMetrics, processorOptions))
 	server.Register("/v1/models", extproc.NewModelsProcessor)
 
 	if err := extproc.StartConfigWatcher(ctx, flags.configPath, server, l, time.Second*5); err != nil {
@@ -159,6 +238,16 @@ func Main(ctx context.Context, args []string, stderr io.Writer) (err error) {
 		if err := hs.Shutdown(shutdownCtx); err != nil {
 			l.Error("Failed to shutdown health check server gracefully", "error", err)
 		}
+		if usageLedger != nil {
+			if err := usageLedger.Close(shutdownCtx); err != nil {
+				l.Error("Failed to flush usage ledger", "error", err)
+			}
+		}
+		if auditLogger != nil {
+			if err := auditLogger.Close(shutdownCtx); err != nil {
+				l.Error("Failed to flush audit log", "error", err)
+			}
+		}
 	}()
 	return s.Serve(lis)
 }
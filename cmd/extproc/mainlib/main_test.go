@@ -91,8 +91,9 @@ func Test_parseAndValidateFlags(t *testing.T) {
 	})
 
 	t.Run("invalid extProcFlags", func(t *testing.T) {
-		_, err := parseAndValidateFlags([]string{"-logLevel", "invalid"})
+		_, err := parseAndValidateFlags([]string{"-logLevel", "invalid", "-responseCacheMaxSizeMB", "0"})
 		assert.EqualError(t, err, `configPath must be provided
+responseCacheMaxSizeMB must be positive
 failed to unmarshal log level: slog: level string "invalid": unknown name`)
 	})
 }
@@ -73,10 +73,21 @@ func (m *myCustomChatCompletionMetrics) RecordRequestCompletion(_ context.Contex
 	m.logger.Info("RecordRequestCompletion", "success", success)
 }
 
+// RecordTokenUsageDetails implements the optional [x.TokenUsageDetailsMetrics].
+func (m *myCustomChatCompletionMetrics) RecordTokenUsageDetails(_ context.Context, cachedInputTokens, reasoningTokens, audioInputTokens, audioOutputTokens uint32, _ ...attribute.KeyValue) {
+	m.logger.Info("RecordTokenUsageDetails", "cachedInputTokens", cachedInputTokens, "reasoningTokens", reasoningTokens,
+		"audioInputTokens", audioInputTokens, "audioOutputTokens", audioOutputTokens)
+}
+
 func (m *myCustomChatCompletionMetrics) RecordTokenLatency(_ context.Context, tokens uint32, _ ...attribute.KeyValue) {
 	m.logger.Info("RecordTokenLatency", "tokens", tokens)
 }
 
+// RecordRequestCost implements the optional [x.RequestCostMetrics].
+func (m *myCustomChatCompletionMetrics) RecordRequestCost(_ context.Context, usd float64, _ ...attribute.KeyValue) {
+	m.logger.Info("RecordRequestCost", "usd", usd)
+}
+
 func (m *myCustomChatCompletionMetrics) GetTimeToFirstTokenMs() float64 {
 	// For demonstration, we return a fixed value.
 	return 1.0
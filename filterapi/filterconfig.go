@@ -73,7 +73,8 @@ modelNameHeaderKey: x-ai-eg-model
 // each cluster must configure the upstream filter to talk to the experoc to perform the corresponding authn/z as well as the transformation.
 // See tests/extproc/envoy.yaml for the example configuration.
 //
-// Note that this contains literal credentials inlined.
+// Note that the backend credentials are either inlined as literals or referenced as files mounted into the extproc,
+// in which case this contains no credentials. See [BackendAuth].
 type Config struct {
 	// UUID is the unique identifier of the filter configuration assigned by the AI Gateway when the configuration is updated.
 	UUID string `json:"uuid,omitempty"`
@@ -89,11 +90,43 @@ type Config struct {
 	// SelectedRouteHeaderKey is the header key to be populated with the route name by the filter
 	// **after** the routing decision is made by the filter using Rules.
 	SelectedRouteHeaderKey string `json:"selectedRouteHeaderKey"`
+	// SessionKeyHeaderKey is the header key to be populated with the hash of the session key by the filter
+	// when the selected rule has [RouteRule.SessionAffinity].
+	SessionKeyHeaderKey string `json:"sessionKeyHeaderKey,omitempty"`
+	// ConsumerHeaderKey is the header key to be populated with the consumer of the consumer key by the filter
+	// when the selected rule has [RouteRule.RequireConsumerKey].
+	ConsumerHeaderKey string `json:"consumerHeaderKey,omitempty"`
+	// ConsumerKeys is the list of the virtual API keys of the consumers validated by the filter.
+	ConsumerKeys []ConsumerKey `json:"consumerKeys,omitempty"`
 	// Rules is the routing rules to be used by the filter to make the routing decision.
 	// Inside the routing rules, the header ModelNameHeaderKey may be used to make the routing decision.
 	Rules []RouteRule `json:"rules"`
 }
 
+// Authorization corresponds to AIGatewayRouteAuthorization in api/v1alpha1/api.go.
+type Authorization struct {
+	// Route is the name of the AIGatewayRoute of the rule, which is passed to the CEL expression as the route.
+	Route string `json:"route"`
+	// ClaimsHeader is the request header carrying the JWT claims of the caller.
+	ClaimsHeader string `json:"claimsHeader"`
+	// CEL is the CEL expression that must evaluate to true for the request to be allowed.
+	CEL string `json:"cel"`
+}
+
+// ConsumerKey corresponds to AIGatewayConsumerKey in api/v1alpha1/api.go.
+type ConsumerKey struct {
+	// Hash is the hex-encoded SHA-256 hash of the key.
+	Hash string `json:"hash"`
+	// Consumer is the identity of the consumer the key is issued to.
+	Consumer string `json:"consumer"`
+	// Models is the list of the models the key can be used for, or empty for all the models.
+	Models []string `json:"models,omitempty"`
+	// RouteRules is the list of the rules the key can be used for.
+	RouteRules []RouteRuleName `json:"routeRules"`
+	// ExpiresAt is the time when the key expires, or zero if the key never expires.
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
 // LLMRequestCost specifies "where" the request cost is stored in the filter metadata as well as
 // "how" the cost is calculated. By default, the cost is retrieved from "output token" in the response body.
 //
@@ -121,7 +154,22 @@ const (
 	LLMRequestCostTypeInputToken LLMRequestCostType = "InputToken"
 	// LLMRequestCostTypeTotalToken specifies that the request cost is calculated from the total token.
 	LLMRequestCostTypeTotalToken LLMRequestCostType = "TotalToken"
+	// LLMRequestCostTypeEstimatedInputToken specifies that the request cost is the input token estimated
+	// before the request is sent to the backend. This is set in the metadata on the request path as well.
+	LLMRequestCostTypeEstimatedInputToken LLMRequestCostType = "EstimatedInputToken"
+	// LLMRequestCostTypeCachedInputToken specifies that the request cost is calculated from the cached input token.
+	LLMRequestCostTypeCachedInputToken LLMRequestCostType = "CachedInputToken"
+	// LLMRequestCostTypeReasoningToken specifies that the request cost is calculated from the reasoning token.
+	LLMRequestCostTypeReasoningToken LLMRequestCostType = "ReasoningToken"
+	// LLMRequestCostTypeAudioInputToken specifies that the request cost is calculated from the audio input token.
+	LLMRequestCostTypeAudioInputToken LLMRequestCostType = "AudioInputToken"
+	// LLMRequestCostTypeAudioOutputToken specifies that the request cost is calculated from the audio output token.
+	LLMRequestCostTypeAudioOutputToken LLMRequestCostType = "AudioOutputToken"
+	// LLMRequestCostTypeMicroUSD specifies that the request cost is the monetary cost of the request in micro US dollars
+	// computed from [Backend.Pricing].
+	LLMRequestCostTypeMicroUSD LLMRequestCostType = "MicroUSD"
 	// LLMRequestCostTypeCEL specifies that the request cost is calculated from the CEL expression.
+	// The expression using the estimated input tokens is evaluated on the request path as well with zero token usage.
 	LLMRequestCostTypeCEL LLMRequestCostType = "CEL"
 )
 
@@ -184,6 +232,357 @@ type RouteRule struct {
 	//
 	// Default to the creation timestamp of the AIGatewayRoute if not set.
 	ModelsCreatedAt time.Time `json:"modelsCreatedAt"`
+	// LongContextFallbackModel is the model name to which a request is re-routed when it does not fit into
+	// the context window of the backends of this rule. See [Backend.ContextWindow]. Optional.
+	LongContextFallbackModel string `json:"longContextFallbackModel,omitempty"`
+	// Shadow is the configuration of the shadow traffic mirroring of this rule. Optional.
+	Shadow *ShadowBackend `json:"shadow,omitempty"`
+	// SessionAffinity is the configuration of the session key of the requests of this rule. Optional.
+	SessionAffinity *SessionAffinity `json:"sessionAffinity,omitempty"`
+	// Hedging is the configuration of the hedged requests of this rule. Optional.
+	Hedging *Hedging `json:"hedging,omitempty"`
+	// TokenQuotas are the per-consumer token budgets of the requests of this rule. Optional.
+	TokenQuotas []TokenQuota `json:"tokenQuotas,omitempty"`
+	// RequireConsumerKey is true if the requests of this rule must carry one of [Config.ConsumerKeys] allowing this rule.
+	RequireConsumerKey bool `json:"requireConsumerKey,omitempty"`
+	// Authorization is the authorization of the requests to the models of this rule. Optional.
+	Authorization *Authorization `json:"authorization,omitempty"`
+	// RequestPolicy is the limits of the parameters of the requests of this rule. Optional.
+	RequestPolicy *RequestPolicy `json:"requestPolicy,omitempty"`
+	// ResponseCache is the configuration of the response cache of this rule. Optional.
+	ResponseCache *ResponseCache `json:"responseCache,omitempty"`
+	// Embeddings is the configuration of the embeddings requests of this rule. Optional.
+	Embeddings *Embeddings `json:"embeddings,omitempty"`
+	// Redaction is the configuration of the PII redaction of this rule. Optional.
+	Redaction *Redaction `json:"redaction,omitempty"`
+	// Guardrails is the list of the guardrails of this rule. Optional.
+	Guardrails []Guardrail `json:"guardrails,omitempty"`
+	// StreamModeration is the configuration of the moderation of the streamed completions of this rule. Optional.
+	StreamModeration *StreamModeration `json:"streamModeration,omitempty"`
+	// AuditLog is the configuration of the audit log of this rule. Optional.
+	AuditLog *AuditLog `json:"auditLog,omitempty"`
+}
+
+// AuditLog corresponds to AIGatewayRouteRuleAuditLog in api/v1alpha1/api.go.
+type AuditLog struct {
+	// SamplingRate is the fraction of the requests written to the audit log between 0 and 1.
+	SamplingRate float64 `json:"samplingRate"`
+	// RedactFields is the list of the paths of the fields redacted in the audit records.
+	RedactFields []string `json:"redactFields,omitempty"`
+}
+
+// StreamModeration corresponds to AIGatewayRouteRuleStreamModeration in api/v1alpha1/api.go.
+type StreamModeration struct {
+	// WindowSize is the number of the characters of the completions of a choice held back before they are screened.
+	WindowSize int `json:"windowSize"`
+	// DenyPatterns is the list of the regular expressions that must not appear in the completions.
+	DenyPatterns []string `json:"denyPatterns,omitempty"`
+}
+
+// Guardrail corresponds to AIGatewayRouteRuleGuardrail in api/v1alpha1/api.go.
+type Guardrail struct {
+	// Name is the name of the guardrail.
+	Name string `json:"name"`
+	// Backend is the moderation backend. Only the name and the auth are used.
+	Backend Backend `json:"backend"`
+	// URL is the base URL of the moderation backend, e.g. "https://api.openai.com:443".
+	URL string `json:"url"`
+	// Type is the API of the moderation backend.
+	Type GuardrailType `json:"type"`
+	// Path is the path of the moderation endpoint, e.g. "/v1/moderations".
+	Path string `json:"path"`
+	// Model is the moderation model sent to the OpenAI moderation API, if any.
+	Model string `json:"model,omitempty"`
+	// Input and Output are true if the guardrail screens the prompts and the completions respectively.
+	Input  bool `json:"input,omitempty"`
+	Output bool `json:"output,omitempty"`
+	// Categories is the list of the categories triggering the guardrail. If empty, the guardrail is triggered
+	// when the moderation backend flags the text.
+	Categories []string `json:"categories,omitempty"`
+	// Threshold is the score of the categories from which the guardrail is triggered.
+	Threshold float64 `json:"threshold,omitempty"`
+	// Action is the action taken when the guardrail is triggered.
+	Action GuardrailAction `json:"action"`
+	// FailClosed is true if the failure of the moderation backend triggers the guardrail.
+	FailClosed bool `json:"failClosed,omitempty"`
+	// Timeout is the timeout of the request to the moderation backend. Zero means the default timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// TLS is the TLS configuration of the connection to the moderation backend. Nil means the system defaults.
+	TLS *BackendTLS `json:"tls,omitempty"`
+}
+
+// GuardrailType is the API of a moderation backend.
+type GuardrailType string
+
+const (
+	// GuardrailTypeOpenAIModeration is the OpenAI "/v1/moderations" compatible API.
+	GuardrailTypeOpenAIModeration GuardrailType = "OpenAIModeration"
+	// GuardrailTypeHTTPClassifier is a generic HTTP classifier.
+	GuardrailTypeHTTPClassifier GuardrailType = "HTTPClassifier"
+)
+
+// GuardrailAction is the action taken when a guardrail is triggered.
+type GuardrailAction string
+
+const (
+	// GuardrailActionBlock blocks the request or the completions with the "content_filter" finish reason.
+	GuardrailActionBlock GuardrailAction = "Block"
+	// GuardrailActionFlag adds the name of the guardrail to the response header.
+	GuardrailActionFlag GuardrailAction = "Flag"
+	// GuardrailActionAnnotate records the triggered categories in the dynamic metadata.
+	GuardrailActionAnnotate GuardrailAction = "Annotate"
+)
+
+// Redaction corresponds to AIGatewayRouteRuleRedaction in api/v1alpha1/api.go.
+type Redaction struct {
+	// Detectors is the list of the detectors of the PII.
+	Detectors []RedactionDetector `json:"detectors"`
+	// Responses is true if the completions are also redacted.
+	Responses bool `json:"responses,omitempty"`
+	// HashKey is the key of the HMAC-SHA256 of the PII replaced by the detectors with the Hash action,
+	// which is required if any.
+	HashKey string `json:"hashKey,omitempty"`
+}
+
+// RedactionDetector corresponds to AIGatewayRouteRuleRedactionDetector in api/v1alpha1/api.go.
+//
+// Exactly one of Builtin and Pattern is set.
+type RedactionDetector struct {
+	// Name is the name of the detector used in the placeholders.
+	Name string `json:"name"`
+	// Builtin is the builtin detector.
+	Builtin RedactionBuiltinDetector `json:"builtin,omitempty"`
+	// Pattern is the regular expression matching the PII.
+	Pattern string `json:"pattern,omitempty"`
+	// Validator is the checksum validator of the matches of the pattern. Optional.
+	Validator RedactionValidator `json:"validator,omitempty"`
+	// Action is the action taken on the detected PII.
+	Action RedactionAction `json:"action"`
+}
+
+// RedactionBuiltinDetector is a builtin detector of the PII.
+type RedactionBuiltinDetector string
+
+const (
+	// RedactionBuiltinDetectorEmail detects the email addresses.
+	RedactionBuiltinDetectorEmail RedactionBuiltinDetector = "Email"
+	// RedactionBuiltinDetectorPhoneNumber detects the phone numbers.
+	RedactionBuiltinDetectorPhoneNumber RedactionBuiltinDetector = "PhoneNumber"
+	// RedactionBuiltinDetectorCreditCard detects the payment card numbers.
+	RedactionBuiltinDetectorCreditCard RedactionBuiltinDetector = "CreditCard"
+	// RedactionBuiltinDetectorUSSocialSecurityNumber detects the US social security numbers.
+	RedactionBuiltinDetectorUSSocialSecurityNumber RedactionBuiltinDetector = "USSocialSecurityNumber"
+	// RedactionBuiltinDetectorIBAN detects the international bank account numbers.
+	RedactionBuiltinDetectorIBAN RedactionBuiltinDetector = "IBAN"
+)
+
+// RedactionValidator is a checksum validator of the PII.
+type RedactionValidator string
+
+const (
+	// RedactionValidatorLuhn validates the digits with the Luhn algorithm.
+	RedactionValidatorLuhn RedactionValidator = "Luhn"
+	// RedactionValidatorIBAN validates the IBAN with the MOD 97-10 check.
+	RedactionValidatorIBAN RedactionValidator = "IBAN"
+)
+
+// RedactionAction is the action taken on the detected PII.
+type RedactionAction string
+
+const (
+	// RedactionActionMask replaces the PII with the name of the detector.
+	RedactionActionMask RedactionAction = "Mask"
+	// RedactionActionHash replaces the PII with the name of the detector and the HMAC of the PII keyed with
+	// [Redaction.HashKey].
+	RedactionActionHash RedactionAction = "Hash"
+	// RedactionActionReject rejects the request.
+	RedactionActionReject RedactionAction = "Reject"
+	// RedactionActionTokenize replaces the PII with a placeholder restored in the completions.
+	RedactionActionTokenize RedactionAction = "Tokenize"
+)
+
+// Embeddings corresponds to AIGatewayRouteRuleEmbeddings in api/v1alpha1/api.go.
+type Embeddings struct {
+	// MaxInputsPerRequest is the maximum number of inputs sent in a single request, or zero for no limit.
+	MaxInputsPerRequest int `json:"maxInputsPerRequest,omitempty"`
+	// BatchURL is the URL that the batches are sent to.
+	BatchURL string `json:"batchURL,omitempty"`
+	// MaxConcurrency is the maximum number of the batches of a request sent concurrently.
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// BatchTimeout is the timeout of all the batches of a request. Zero means no timeout.
+	BatchTimeout time.Duration `json:"batchTimeout,omitempty"`
+	// InputCacheTTL is how long a cached embedding of an input is served, or zero to disable the cache.
+	InputCacheTTL time.Duration `json:"inputCacheTTL,omitempty"`
+}
+
+// ResponseCache corresponds to AIGatewayRouteRuleResponseCache in api/v1alpha1/api.go.
+type ResponseCache struct {
+	// TTL is how long a cached response is served.
+	TTL time.Duration `json:"ttl"`
+	// ConsumerHeader is the name of the request header that identifies the consumer. Optional.
+	// This is sent by the client, so it only scopes the cache if it's set by a trusted filter in front of the gateway.
+	ConsumerHeader string `json:"consumerHeader,omitempty"`
+	// AllTemperatures is true if the requests are cached regardless of their temperature.
+	AllTemperatures bool `json:"allTemperatures,omitempty"`
+	// IgnoreCacheControl is true if the Cache-Control header of the requests is ignored.
+	IgnoreCacheControl bool `json:"ignoreCacheControl,omitempty"`
+	// Semantic is the configuration of the semantic cache. Optional.
+	Semantic *SemanticCache `json:"semantic,omitempty"`
+}
+
+// SemanticCache corresponds to AIGatewayRouteRuleSemanticCache in api/v1alpha1/api.go.
+type SemanticCache struct {
+	// Backend is the OpenAI compatible embeddings backend. Only the name and the auth are used.
+	Backend Backend `json:"backend"`
+	// URL is the base URL of the embeddings backend, e.g. "https://api.openai.com:443".
+	URL string `json:"url"`
+	// Path is the path of the embeddings endpoint, e.g. "/v1/embeddings".
+	Path string `json:"path"`
+	// TLS is the TLS configuration of the connection to the embeddings backend. Nil means the system defaults.
+	TLS *BackendTLS `json:"tls,omitempty"`
+	// Model is the name of the embeddings model.
+	Model string `json:"model"`
+	// Threshold is the minimum cosine similarity to serve the cached response.
+	Threshold float64 `json:"threshold"`
+	// AllowUnauthenticated is true if the semantic cache is used for the requests without the consumer
+	// authenticated with the consumer key.
+	AllowUnauthenticated bool `json:"allowUnauthenticated,omitempty"`
+	// Timeout is the timeout of the request to the embeddings backend. Zero means the default timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// RequestPolicy corresponds to AIGatewayRouteRuleRequestPolicy in api/v1alpha1/api.go.
+//
+// Each limit is optional, and nil means no limit.
+type RequestPolicy struct {
+	// MaxTokens is the limit of the "max_tokens" and "max_completion_tokens" fields.
+	MaxTokens *RequestLimit `json:"maxTokens,omitempty"`
+	// N is the limit of the "n" field.
+	N *RequestLimit `json:"n,omitempty"`
+	// Temperature is the allowed range of the "temperature" field.
+	Temperature *TemperatureLimit `json:"temperature,omitempty"`
+	// Tools is the limit of the number of the tools.
+	Tools *RequestLimit `json:"tools,omitempty"`
+	// Messages is the limit of the number of the messages.
+	Messages *RequestLimit `json:"messages,omitempty"`
+	// MaxBodyBytes is the maximum size of the request body in bytes, or 0 if not limited.
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+	// Images is the validation of the images in the messages.
+	Images *ImagePolicy `json:"images,omitempty"`
+	// MaxToolSchemaDepth is the maximum nesting depth of the parameters schema of each tool, or 0 if not limited.
+	MaxToolSchemaDepth int `json:"maxToolSchemaDepth,omitempty"`
+}
+
+// ImagePolicy corresponds to AIGatewayRouteRuleImagePolicy in api/v1alpha1/api.go.
+type ImagePolicy struct {
+	// MaxImages is the maximum number of the images, or nil if not limited.
+	MaxImages *int `json:"maxImages,omitempty"`
+	// MaxImageBytes is the maximum decoded size of each data URL image in bytes, or 0 if not limited.
+	MaxImageBytes int64 `json:"maxImageBytes,omitempty"`
+	// AllowedMIMETypes is the allowed MIME types of the data URL images. Empty means any type is allowed.
+	AllowedMIMETypes []string `json:"allowedMIMETypes,omitempty"`
+}
+
+// RequestLimitAction is the action taken when a request exceeds a limit of the [RequestPolicy].
+type RequestLimitAction string
+
+const (
+	// RequestLimitActionClamp rewrites the parameter of the request to the limit.
+	RequestLimitActionClamp RequestLimitAction = "Clamp"
+	// RequestLimitActionReject rejects the request.
+	RequestLimitActionReject RequestLimitAction = "Reject"
+)
+
+// RequestLimit corresponds to AIGatewayRouteRuleRequestLimit in api/v1alpha1/api.go.
+type RequestLimit struct {
+	// Max is the maximum allowed value.
+	Max int `json:"max"`
+	// Action is the action taken when the request exceeds the limit.
+	Action RequestLimitAction `json:"action"`
+}
+
+// TemperatureLimit corresponds to AIGatewayRouteRuleTemperatureLimit in api/v1alpha1/api.go.
+type TemperatureLimit struct {
+	// Min is the minimum allowed temperature, or nil if not limited.
+	Min *float64 `json:"min,omitempty"`
+	// Max is the maximum allowed temperature, or nil if not limited.
+	Max *float64 `json:"max,omitempty"`
+	// Action is the action taken when the temperature is out of the range.
+	Action RequestLimitAction `json:"action"`
+}
+
+// TokenQuota corresponds to AIGatewayRouteTokenQuota in api/v1alpha1/api.go.
+//
+// The rules of the same AIGatewayRoute share the same quota, which is identified by Name.
+type TokenQuota struct {
+	// Name is the unique name of the quota across the rules, which is used as the key of the quota state.
+	Name string `json:"name"`
+	// ConsumerHeader is the name of the request header that identifies the consumer, which is expected to be set by
+	// a trusted filter in front of the gateway. Config.ConsumerHeaderKey sent by the client is ignored.
+	// When this is empty, the quota is keyed by the consumer authenticated with the consumer key.
+	ConsumerHeader string `json:"consumerHeader,omitempty"`
+	// RequireConsumerHeader rejects the requests without the consumer instead of exempting them from the quota.
+	RequireConsumerHeader bool `json:"requireConsumerHeader,omitempty"`
+	// Type is the type of the tokens counted against the budgets, which is one of
+	// LLMRequestCostTypeInputToken, LLMRequestCostTypeOutputToken, or LLMRequestCostTypeTotalToken.
+	Type LLMRequestCostType `json:"type"`
+	// TokensPerMinute is the budget per minute. Zero means unlimited.
+	TokensPerMinute int64 `json:"tokensPerMinute,omitempty"`
+	// TokensPerDay is the budget per day in UTC. Zero means unlimited.
+	TokensPerDay int64 `json:"tokensPerDay,omitempty"`
+}
+
+// Hedging corresponds to AIGatewayRouteRuleHedging in api/v1alpha1/api.go.
+//
+// The hedged requests are sent by Envoy. The filter identifies the attempt that won the race to process
+// its response, and accounts the estimated prompt tokens of the cancelled attempts in the cost metadata.
+// The completion tokens of the cancelled attempts are never observed by the filter, so they are not accounted.
+type Hedging struct {
+	// Delay is the time to wait for the response headers before sending a hedged request.
+	Delay time.Duration `json:"delay"`
+	// MaxHedgedRequests is the maximum number of hedged requests in addition to the original request.
+	MaxHedgedRequests int `json:"maxHedgedRequests"`
+}
+
+// SessionAffinity corresponds to AIGatewayRouteRuleSessionAffinity in api/v1alpha1/api.go.
+//
+// The filter extracts the session key from the request and populates its hash in [Config.SessionKeyHeaderKey],
+// which Envoy uses to pin the session to a backend of the rule.
+type SessionAffinity struct {
+	// Header is the name of the request header that carries the session key. Optional.
+	Header string `json:"header,omitempty"`
+	// UserField is true if the "user" field of the request body is used as the session key
+	// when Header is not set or absent.
+	UserField bool `json:"userField,omitempty"`
+}
+
+// ShadowBackend corresponds to AIGatewayRouteRuleShadow in api/v1alpha1/api.go.
+//
+// A sampled percentage of the requests of the rule is mirrored to this backend by the filter itself,
+// and the response is only used to compare with the one of the primary backend.
+type ShadowBackend struct {
+	// Backend is the shadow backend. The schema and the auth are used in the same way as the primary backends.
+	Backend Backend `json:"backend"`
+	// URL is the base URL of the shadow backend, e.g. "https://api.openai.com:443". The path of the request is
+	// appended to this URL.
+	URL string `json:"url"`
+	// Percent is the percentage of the requests that are mirrored, from 0 to 100.
+	Percent int `json:"percent"`
+	// Timeout is the timeout of the shadow request, including the wait for the result of the primary backend.
+	// Zero means the default timeout.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// TLS is the TLS configuration of the connection to the shadow backend. Nil means the system defaults.
+	TLS *BackendTLS `json:"tls,omitempty"`
+}
+
+// BackendTLS corresponds to the validation of the BackendTLSPolicy targeting a backend that is called by
+// the external processor itself rather than by Envoy.
+type BackendTLS struct {
+	// Hostname is the server name used for SNI and for the verification of the certificate of the backend.
+	Hostname string `json:"hostname"`
+	// CACertificates is the PEM encoded CA certificates used to verify the certificate of the backend.
+	// Empty means the system CA certificates.
+	CACertificates string `json:"caCertificates,omitempty"`
 }
 
 // RouteRuleName is the name of the route rule.
@@ -201,6 +600,38 @@ type Backend struct {
 	Schema VersionedAPISchema `json:"schema"`
 	// Auth is the authn/z configuration for the backend. Optional.
 	Auth *BackendAuth `json:"auth,omitempty"`
+	// ContextWindow is the maximum number of tokens, the prompt and the completion combined, that the
+	// model served by this backend can handle. Zero means unknown, and no check is performed.
+	ContextWindow int `json:"contextWindow,omitempty"`
+	// MaxOutputTokens is the maximum number of tokens that the model served by this backend can generate.
+	// Zero means unknown, and no check is performed.
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+	// CircuitBreaker is the configuration of the circuit breaker of this backend. Optional.
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty"`
+	// Pricing is the price list of the models served by this backend. Optional.
+	Pricing []ModelPricing `json:"pricing,omitempty"`
+}
+
+// ModelPricing corresponds to AIServiceBackendModelPricing in api/v1alpha1/api.go.
+//
+// The prices are in US dollars per million tokens, which is the same as micro US dollars per token.
+type ModelPricing struct {
+	// Model is the name of the model sent to the backend, or "*" to match all the models not listed.
+	Model string `json:"model"`
+	// InputPerMillionTokens is the price of a million input tokens.
+	InputPerMillionTokens float64 `json:"inputPerMillionTokens"`
+	// OutputPerMillionTokens is the price of a million output tokens.
+	OutputPerMillionTokens float64 `json:"outputPerMillionTokens"`
+	// CachedInputPerMillionTokens is the price of a million cached input tokens.
+	CachedInputPerMillionTokens float64 `json:"cachedInputPerMillionTokens"`
+}
+
+// CircuitBreaker corresponds to AIServiceBackendCircuitBreaker in api/v1alpha1/api.go.
+type CircuitBreaker struct {
+	// ConsecutiveFailures is the number of consecutive failures that opens the circuit.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// Cooldown is the duration for which the circuit stays open.
+	Cooldown time.Duration `json:"cooldown"`
 }
 
 // BackendAuth corresponds partially to BackendSecurityPolicy in api/v1alpha1/api.go.
@@ -220,19 +651,42 @@ type AWSAuth struct {
 	// CredentialFileLiteral is the literal string of the AWS credential file. E.g.
 	// [default]\naws_access_key_id = <access-key-id>\naws_secret_access_key = <secret-access-key>\naws_session_token = <session-token>.
 	CredentialFileLiteral string `json:"credentialFileLiteral,omitempty"`
-	Region                string `json:"region"`
+	// CredentialFile is the path to the AWS credential file, which is used instead of CredentialFileLiteral when set.
+	// The file is reloaded when it changes.
+	CredentialFile string `json:"credentialFile,omitempty"`
+	Region         string `json:"region"`
 }
 
 // APIKeyAuth defines the file that will be mounted to the external proc.
 type APIKeyAuth struct {
 	// Key is the API key as a literal string.
-	Key string `json:"key"`
+	Key string `json:"key,omitempty"`
+	// KeyFile is the path to the file containing the API key, which is used instead of Key when set.
+	// The file is reloaded when it changes.
+	KeyFile string `json:"keyFile,omitempty"`
+	// Header is the name of the request header to set the API key in, with Prefix prepended to it.
+	// The API key is set in the "Authorization" header with the "Bearer " prefix when both Header and QueryParam
+	// are empty.
+	Header string `json:"header,omitempty"`
+	// Prefix is prepended to the API key in the value of Header.
+	Prefix string `json:"prefix,omitempty"`
+	// QueryParam is the name of the query parameter of the path to set the API key in instead of a header.
+	QueryParam string `json:"queryParam,omitempty"`
 }
 
 // AzureAuth defines the file containing azure access token that will be mounted to the external proc.
 type AzureAuth struct {
 	// AccessToken is the access token as a literal string.
-	AccessToken string `json:"accessToken"`
+	AccessToken string `json:"accessToken,omitempty"`
+	// AccessTokenFile is the path to the file containing the access token, which is used instead of AccessToken when set.
+	// The file is reloaded when it changes.
+	AccessTokenFile string `json:"accessTokenFile,omitempty"`
+	// APIKey is the API key of the Azure OpenAI resource as a literal string. When set, the API key is sent in the
+	// "api-key" header instead of the access token in the "Authorization" header.
+	APIKey string `json:"apiKey,omitempty"`
+	// APIKeyFile is the path to the file containing the API key, which is used instead of APIKey when set.
+	// The file is reloaded when it changes.
+	APIKeyFile string `json:"apiKeyFile,omitempty"`
 }
 
 // GCPAuth defines the GCP authentication configuration used to access Google Cloud AI services.
@@ -240,7 +694,10 @@ type GCPAuth struct {
 	// AccessToken is the access token as a literal string.
 	// This token is obtained through GCP Workload Identity Federation and service account impersonation.
 	// The token is automatically rotated by the BackendSecurityPolicy controller before expiration.
-	AccessToken string `json:"accessToken"`
+	AccessToken string `json:"accessToken,omitempty"`
+	// AccessTokenFile is the path to the file containing the access token, which is used instead of AccessToken when set.
+	// The file is reloaded when it changes.
+	AccessTokenFile string `json:"accessTokenFile,omitempty"`
 	// Region is the GCP region to use for the request.
 	// This is used in URL path templates when making requests to GCP Vertex AI endpoints.
 	// Examples: "us-central1", "europe-west4".
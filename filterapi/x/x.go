@@ -44,6 +44,24 @@ type Router interface {
 	Calculate(requestHeaders map[string]string) (route filterapi.RouteRuleName, err error)
 }
 
+// NewCustomTokenizer is the function to return a custom tokenizer for the given model name over the default
+// tokenizers. This is nil by default and can be set by the custom build of external processor.
+//
+// The returned tokenizer is used to estimate the input tokens of the requests before they are sent to the backends.
+// Returning nil falls back to the default tokenizer for the model.
+var NewCustomTokenizer NewCustomTokenizerFn
+
+// NewCustomTokenizerFn is the function signature for [NewCustomTokenizer].
+type NewCustomTokenizerFn func(model string) Tokenizer
+
+// Tokenizer is the interface for the tokenizer of a model.
+//
+// Tokenizer must be goroutine-safe as it is shared across multiple requests.
+type Tokenizer interface {
+	// CountTokens returns the number of tokens of the given text.
+	CountTokens(text string) int
+}
+
 // NewCustomChatCompletionMetrics is the function to create a custom chat completion AI Gateway metrics over
 // the default metrics. This is nil by default and can be set by the custom build of external processor.
 var NewCustomChatCompletionMetrics NewCustomChatCompletionMetricsFn
@@ -88,3 +106,20 @@ type EmbeddingsMetrics interface {
 	// RecordRequestCompletion records latency metrics for the entire request.
 	RecordRequestCompletion(ctx context.Context, success bool, extraAttrs ...attribute.KeyValue)
 }
+
+// RequestCostMetrics is the optional interface for recording the monetary cost of the requests. This is not part
+// of [ChatCompletionMetrics] and [EmbeddingsMetrics] so that the existing custom metrics keep working, and the cost
+// is only recorded when the metrics returned by [NewCustomChatCompletionMetrics] also implement this interface.
+type RequestCostMetrics interface {
+	// RecordRequestCost records the monetary cost of the request in US dollars.
+	RecordRequestCost(ctx context.Context, usd float64, extraAttrs ...attribute.KeyValue)
+}
+
+// TokenUsageDetailsMetrics is the optional interface for recording the breakdown of the input and output tokens.
+// This is not part of [ChatCompletionMetrics] so that the existing custom metrics keep working, and the breakdown
+// is only recorded when the metrics returned by [NewCustomChatCompletionMetrics] also implement this interface.
+type TokenUsageDetailsMetrics interface {
+	// RecordTokenUsageDetails records the breakdown of the input and output tokens as additional token types.
+	// Zero counts are not recorded.
+	RecordTokenUsageDetails(ctx context.Context, cachedInputTokens, reasoningTokens, audioInputTokens, audioOutputTokens uint32, extraAttrs ...attribute.KeyValue)
+}
@@ -359,6 +359,12 @@ type TokenUsage struct {
 	InputTokens  int `json:"inputTokens"`
 	OutputTokens int `json:"outputTokens"`
 	TotalTokens  int `json:"totalTokens"`
+	// CacheReadInputTokens is the number of input tokens read from the prompt cache, which are not
+	// included in InputTokens.
+	CacheReadInputTokens int `json:"cacheReadInputTokens,omitempty"`
+	// CacheWriteInputTokens is the number of input tokens written to the prompt cache, which are not
+	// included in InputTokens.
+	CacheWriteInputTokens int `json:"cacheWriteInputTokens,omitempty"`
 }
 
 // ConverseStreamEvent is the union of all possible event types in the AWS Bedrock API:
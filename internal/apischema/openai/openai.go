@@ -463,6 +463,11 @@ type ChatCompletionRequest struct {
 	// refs: https://platform.openai.com/docs/api-reference/chat/create#chat-create-max_tokens
 	MaxTokens *int64 `json:"max_tokens,omitempty"` //nolint:tagliatelle //follow openai api
 
+	// MaxCompletionTokens An upper bound for the number of tokens that can be generated for a completion,
+	// including visible output tokens and reasoning tokens.
+	// Docs: https://platform.openai.com/docs/api-reference/chat/create#chat-create-max_completion_tokens
+	MaxCompletionTokens *int64 `json:"max_completion_tokens,omitempty"` //nolint:tagliatelle //follow openai api
+
 	// N: LLM Gateway does not support multiple completions.
 	// The only accepted value is 1.
 	// Docs: https://platform.openai.com/docs/api-reference/chat/create#chat-create-n
@@ -700,6 +705,32 @@ type ChatCompletionResponseUsage struct {
 	CompletionTokens int `json:"completion_tokens,omitempty"`
 	PromptTokens     int `json:"prompt_tokens,omitempty"`
 	TotalTokens      int `json:"total_tokens,omitempty"`
+	// CompletionTokensDetails is the breakdown of the tokens used in the completion.
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+	// PromptTokensDetails is the breakdown of the tokens used in the prompt.
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+// CompletionTokensDetails is described in the OpenAI API documentation:
+// https://platform.openai.com/docs/api-reference/chat/object#chat/object-usage
+type CompletionTokensDetails struct {
+	// AcceptedPredictionTokens is the number of tokens in the prediction that appeared in the completion.
+	AcceptedPredictionTokens int `json:"accepted_prediction_tokens,omitempty"`
+	// AudioTokens is the number of audio tokens generated by the model.
+	AudioTokens int `json:"audio_tokens,omitempty"`
+	// ReasoningTokens is the number of tokens generated by the model for reasoning.
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+	// RejectedPredictionTokens is the number of tokens in the prediction that did not appear in the completion.
+	RejectedPredictionTokens int `json:"rejected_prediction_tokens,omitempty"`
+}
+
+// PromptTokensDetails is described in the OpenAI API documentation:
+// https://platform.openai.com/docs/api-reference/chat/object#chat/object-usage
+type PromptTokensDetails struct {
+	// AudioTokens is the number of audio input tokens present in the prompt.
+	AudioTokens int `json:"audio_tokens,omitempty"`
+	// CachedTokens is the number of tokens in the prompt read from the prompt cache.
+	CachedTokens int `json:"cached_tokens,omitempty"`
 }
 
 // ChatCompletionResponseChunk is described in the OpenAI API documentation:
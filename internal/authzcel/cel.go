@@ -0,0 +1,133 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+// Package authzcel provides functions to create and evaluate CEL programs to authorize the requests to the models.
+//
+// This exists as a separate package to be used both in the controller to validate the expression
+// and in the external processor to evaluate the expression.
+package authzcel
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+)
+
+const (
+	celModelNameKey = "model"
+	// celRouteKey is the name of the AIGatewayRoute of the request.
+	celRouteKey = "route"
+	// celConsumerKey is the consumer authenticated with the consumer key, or empty if the route does not require one.
+	celConsumerKey = "consumer"
+	// celClaimsKey is the map of the JWT claims of the caller.
+	celClaimsKey = "claims"
+	// celRequestHeadersKey is the map of the request headers keyed by the lower-cased header name.
+	celRequestHeadersKey = "request_headers"
+)
+
+var env *cel.Env
+
+func init() {
+	var err error
+	env, err = cel.NewEnv(
+		cel.OptionalTypes(),
+		cel.Variable(celModelNameKey, cel.StringType),
+		cel.Variable(celRouteKey, cel.StringType),
+		cel.Variable(celConsumerKey, cel.StringType),
+		cel.Variable(celClaimsKey, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(celRequestHeadersKey, cel.MapType(cel.StringType, cel.StringType)),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("cannot create CEL environment: %v", err))
+	}
+}
+
+// Input is the set of variables available to the CEL expression.
+type Input struct {
+	// Model is the name of the model in the request.
+	Model string
+	// Route is the name of the AIGatewayRoute of the request.
+	Route string
+	// Consumer is the consumer authenticated with the consumer key, if any.
+	Consumer string
+	// Claims are the JWT claims of the caller. See [ParseClaims].
+	Claims map[string]any
+	// RequestHeaders are the request headers keyed by the lower-cased header name.
+	RequestHeaders map[string]string
+}
+
+// NewProgram creates a new CEL program from the given expression.
+//
+// The expression is type-checked against the environment and must evaluate to a boolean. Missing claims and request
+// headers fail the evaluation, so they must be accessed with the optional syntax, e.g. claims[?"team"].orValue("").
+func NewProgram(expr string) (cel.Program, error) {
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("cannot compile CEL expression: %w", issues.Err())
+	}
+	switch ast.OutputType() {
+	case cel.BoolType, cel.DynType:
+	default:
+		return nil, fmt.Errorf("CEL expression must evaluate to a boolean, got %v", ast.OutputType())
+	}
+	prog, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create CEL program: %w", err)
+	}
+	return prog, nil
+}
+
+// EvaluateProgram evaluates the given CEL program with the given variables, and returns true if the request is allowed.
+func EvaluateProgram(prog cel.Program, in *Input) (bool, error) {
+	claims := in.Claims
+	if claims == nil {
+		claims = map[string]any{}
+	}
+	headers := in.RequestHeaders
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	out, _, err := prog.Eval(map[string]any{
+		celModelNameKey:      in.Model,
+		celRouteKey:          in.Route,
+		celConsumerKey:       in.Consumer,
+		celClaimsKey:         claims,
+		celRequestHeadersKey: headers,
+	})
+	if err != nil || out == nil {
+		return false, fmt.Errorf("failed to evaluate CEL expression: %w", err)
+	}
+	allowed, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("CEL expression result is not a boolean, got %v", out.Type())
+	}
+	return allowed, nil
+}
+
+// ParseClaims parses the JWT claims forwarded in a request header, which is either the JSON object of the claims or
+// its base64url encoding as written by the forward_payload_header of the Envoy JWT authentication filter.
+// The empty value is parsed as no claims.
+func ParseClaims(value string) (map[string]any, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return map[string]any{}, nil
+	}
+	raw := []byte(value)
+	if !strings.HasPrefix(value, "{") {
+		var err error
+		raw, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode claims: %w", err)
+		}
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return nil, fmt.Errorf("failed to parse claims: %w", err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,88 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package authzcel
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProgram(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewProgram("model ==")
+		require.Error(t, err)
+	})
+	t.Run("not boolean", func(t *testing.T) {
+		_, err := NewProgram("1 + 1")
+		require.ErrorContains(t, err, "CEL expression must evaluate to a boolean, got int")
+	})
+	t.Run("unknown variable", func(t *testing.T) {
+		_, err := NewProgram("backend == 'foo'")
+		require.Error(t, err)
+	})
+	t.Run("variables", func(t *testing.T) {
+		prog, err := NewProgram(`claims[?"team"].orValue("") == "a" ? model in ["gpt-4o", "gpt-4o-mini"] :
+			"interns" in claims[?"groups"].orValue([]) ? model.endsWith("-mini") :
+			consumer == "batch" && route == "embeddings" || request_headers[?"x-tier"].orValue("") == "gold"`)
+		require.NoError(t, err)
+		for _, tc := range []struct {
+			name  string
+			in    *Input
+			allow bool
+		}{
+			{name: "team a", in: &Input{Model: "gpt-4o", Claims: map[string]any{"team": "a"}}, allow: true},
+			{name: "team a o1", in: &Input{Model: "o1", Claims: map[string]any{"team": "a"}}},
+			{name: "intern mini", in: &Input{Model: "gpt-4o-mini", Claims: map[string]any{"groups": []any{"interns"}}}, allow: true},
+			{name: "intern large", in: &Input{Model: "gpt-4o", Claims: map[string]any{"groups": []any{"interns"}}}},
+			{name: "consumer", in: &Input{Model: "text-embedding-3-small", Route: "embeddings", Consumer: "batch"}, allow: true},
+			{name: "header", in: &Input{Model: "o1", RequestHeaders: map[string]string{"x-tier": "gold"}}, allow: true},
+			{name: "nothing", in: &Input{Model: "o1"}},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				allow, err := EvaluateProgram(prog, tc.in)
+				require.NoError(t, err)
+				require.Equal(t, tc.allow, allow)
+			})
+		}
+	})
+	t.Run("missing claim", func(t *testing.T) {
+		prog, err := NewProgram(`claims.team == "a"`)
+		require.NoError(t, err)
+		_, err = EvaluateProgram(prog, &Input{})
+		require.ErrorContains(t, err, "no such key: team")
+	})
+}
+
+func TestParseClaims(t *testing.T) {
+	const claims = `{"sub":"alice","team":"a","groups":["interns"]}`
+	exp := map[string]any{"sub": "alice", "team": "a", "groups": []any{"interns"}}
+	for _, tc := range []struct {
+		name, value string
+	}{
+		{name: "json", value: claims},
+		{name: "base64url", value: base64.RawURLEncoding.EncodeToString([]byte(claims))},
+		{name: "base64url padded", value: base64.URLEncoding.EncodeToString([]byte(claims))},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParseClaims(tc.value)
+			require.NoError(t, err)
+			require.Equal(t, exp, actual)
+		})
+	}
+	t.Run("empty", func(t *testing.T) {
+		actual, err := ParseClaims("")
+		require.NoError(t, err)
+		require.Empty(t, actual)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ParseClaims("not base64!")
+		require.ErrorContains(t, err, "failed to decode claims")
+		_, err = ParseClaims("{")
+		require.ErrorContains(t, err, "failed to parse claims")
+	})
+}
@@ -0,0 +1,196 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"k8s.io/client-go/kubernetes"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aigv1a1 "github.com/envoyproxy/ai-gateway/api/v1alpha1"
+	"github.com/envoyproxy/ai-gateway/filterapi"
+)
+
+// consumerKeyPrefix is the prefix of the virtual API keys of the consumers.
+const consumerKeyPrefix = "sk-"
+
+// AIGatewayConsumerKeyController implements [reconcile.TypedReconciler] for [aigv1a1.AIGatewayConsumerKey].
+//
+// Exported for testing purposes.
+type AIGatewayConsumerKeyController struct {
+	client             client.Client
+	kube               kubernetes.Interface
+	logger             logr.Logger
+	aiGatewayRouteChan chan event.GenericEvent
+}
+
+// NewAIGatewayConsumerKeyController creates a new [reconcile.TypedReconciler] for [aigv1a1.AIGatewayConsumerKey].
+func NewAIGatewayConsumerKeyController(client client.Client, kube kubernetes.Interface, logger logr.Logger, aiGatewayRouteChan chan event.GenericEvent) *AIGatewayConsumerKeyController {
+	return &AIGatewayConsumerKeyController{
+		client:             client,
+		kube:               kube,
+		logger:             logger,
+		aiGatewayRouteChan: aiGatewayRouteChan,
+	}
+}
+
+// Reconcile implements the [reconcile.TypedReconciler] for [aigv1a1.AIGatewayConsumerKey].
+func (c *AIGatewayConsumerKeyController) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	var key aigv1a1.AIGatewayConsumerKey
+	if err := c.client.Get(ctx, req.NamespacedName, &key); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			c.logger.Info("Deleting AIGatewayConsumerKey", "namespace", req.Namespace, "name", req.Name)
+			// The deleted key needs to be removed from the filter configs.
+			return ctrl.Result{}, c.syncAIGatewayRoutes(ctx, req.Namespace)
+		}
+		return ctrl.Result{}, err
+	}
+	c.logger.Info("Reconciling AIGatewayConsumerKey", "namespace", req.Namespace, "name", req.Name)
+	if err := c.syncAIGatewayConsumerKey(ctx, &key); err != nil {
+		c.logger.Error(err, "failed to sync AIGatewayConsumerKey")
+		c.updateAIGatewayConsumerKeyStatus(ctx, &key, aigv1a1.ConditionTypeNotAccepted, err.Error())
+		return ctrl.Result{}, err
+	}
+	c.updateAIGatewayConsumerKeyStatus(ctx, &key, aigv1a1.ConditionTypeAccepted, "AIGatewayConsumerKey reconciled successfully")
+	return ctrl.Result{}, nil
+}
+
+// syncAIGatewayConsumerKey is the main logic for reconciling the AIGatewayConsumerKey resource.
+// This is decoupled from the Reconcile method to centralize the error handling and status updates.
+func (c *AIGatewayConsumerKeyController) syncAIGatewayConsumerKey(ctx context.Context, key *aigv1a1.AIGatewayConsumerKey) error {
+	// The AIGatewayRoutes are synced even when the key is invalid so that the key previously valid is removed.
+	if err := c.syncAIGatewayRoutes(ctx, key.Namespace); err != nil {
+		return err
+	}
+	_, err := consumerKeyFromSecret(ctx, c.kube, key)
+	return err
+}
+
+// syncAIGatewayRoutes syncs the AIGatewayRoutes requiring the consumer keys in the namespace.
+func (c *AIGatewayConsumerKeyController) syncAIGatewayRoutes(ctx context.Context, namespace string) error {
+	var aiGatewayRoutes aigv1a1.AIGatewayRouteList
+	if err := c.client.List(ctx, &aiGatewayRoutes, client.InNamespace(namespace)); err != nil {
+		return fmt.Errorf("failed to list AIGatewayRouteList: %w", err)
+	}
+	for i := range aiGatewayRoutes.Items {
+		aiGatewayRoute := &aiGatewayRoutes.Items[i]
+		if !aiGatewayRoute.Spec.RequireConsumerKey {
+			continue
+		}
+		c.logger.Info("syncing AIGatewayRoute", "namespace", aiGatewayRoute.Namespace, "name", aiGatewayRoute.Name)
+		c.aiGatewayRouteChan <- event.GenericEvent{Object: aiGatewayRoute}
+	}
+	return nil
+}
+
+// updateAIGatewayConsumerKeyStatus updates the status of the AIGatewayConsumerKey.
+func (c *AIGatewayConsumerKeyController) updateAIGatewayConsumerKeyStatus(ctx context.Context, key *aigv1a1.AIGatewayConsumerKey, conditionType string, message string) {
+	key.Status.Conditions = newConditions(conditionType, message)
+	if err := c.client.Status().Update(ctx, key); err != nil {
+		c.logger.Error(err, "failed to update AIGatewayConsumerKey status")
+	}
+}
+
+// consumerKeyFromSecret returns the key of the AIGatewayConsumerKey read from its secret.
+func consumerKeyFromSecret(ctx context.Context, kube kubernetes.Interface, key *aigv1a1.AIGatewayConsumerKey) (string, error) {
+	ref := key.Spec.SecretRef
+	if ref == nil {
+		return "", fmt.Errorf("secretRef of AIGatewayConsumerKey %s is not set", key.Name)
+	}
+	// The secret of another namespace is never read since no ReferenceGrant is checked for the consumer keys.
+	if ref.Namespace != nil && string(*ref.Namespace) != key.Namespace {
+		return "", fmt.Errorf("secretRef of AIGatewayConsumerKey %s must refer to a secret in namespace %s", key.Name, key.Namespace)
+	}
+	value, err := getSecretData(ctx, kube, key.Namespace, string(ref.Name), apiKeyInSecret)
+	if err != nil {
+		return "", err
+	}
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, consumerKeyPrefix) || len(value) == len(consumerKeyPrefix) {
+		return "", fmt.Errorf("key in secret %s must start with %q", ref.Name, consumerKeyPrefix)
+	}
+	return value, nil
+}
+
+// consumerKeysToFilterAPI returns the consumer keys of the namespaces of the AIGatewayRoutes requiring them, along
+// with the rules each key can be used for. Only the hashes of the keys are included.
+//
+// The revoked keys are left out, and so are the keys failing to read their secrets so that a single invalid key
+// does not fail the configuration of the whole Gateway. The error is reported in the status of the key instead.
+func (c *GatewayController) consumerKeysToFilterAPI(ctx context.Context, aiGatewayRoutes []aigv1a1.AIGatewayRoute) ([]filterapi.ConsumerKey, error) {
+	// rules maps the namespace to the rules of each AIGatewayRoute requiring the consumer keys.
+	rules := map[string]map[string][]filterapi.RouteRuleName{}
+	var namespaces []string
+	for i := range aiGatewayRoutes {
+		aiGatewayRoute := &aiGatewayRoutes[i]
+		if !aiGatewayRoute.Spec.RequireConsumerKey {
+			continue
+		}
+		ns := aiGatewayRoute.Namespace
+		if rules[ns] == nil {
+			rules[ns] = map[string][]filterapi.RouteRuleName{}
+			namespaces = append(namespaces, ns)
+		}
+		for j := range aiGatewayRoute.Spec.Rules {
+			rules[ns][aiGatewayRoute.Name] = append(rules[ns][aiGatewayRoute.Name], routeName(aiGatewayRoute, j))
+		}
+	}
+
+	var ret []filterapi.ConsumerKey
+	for _, ns := range namespaces {
+		var keys aigv1a1.AIGatewayConsumerKeyList
+		if err := c.client.List(ctx, &keys, client.InNamespace(ns)); err != nil {
+			return nil, fmt.Errorf("failed to list AIGatewayConsumerKeyList: %w", err)
+		}
+		for i := range keys.Items {
+			key := &keys.Items[i]
+			if key.Spec.Revoked {
+				continue
+			}
+			routeNames := key.Spec.AIGatewayRouteNames
+			if len(routeNames) == 0 {
+				for name := range rules[ns] {
+					routeNames = append(routeNames, name)
+				}
+				slices.Sort(routeNames)
+			}
+			fk := filterapi.ConsumerKey{Consumer: key.Spec.Consumer, Models: key.Spec.Models}
+			for _, name := range routeNames {
+				fk.RouteRules = append(fk.RouteRules, rules[ns][name]...)
+			}
+			if len(fk.RouteRules) == 0 {
+				continue
+			}
+			if key.Spec.ExpiresAt != nil {
+				fk.ExpiresAt = key.Spec.ExpiresAt.UTC()
+			}
+			value, err := consumerKeyFromSecret(ctx, c.kube, key)
+			if err != nil {
+				c.logger.Error(err, "skipping AIGatewayConsumerKey", "namespace", key.Namespace, "name", key.Name)
+				continue
+			}
+			fk.Hash = hashConsumerKey(value)
+			ret = append(ret, fk)
+		}
+	}
+	return ret, nil
+}
+
+// hashConsumerKey returns the hex-encoded SHA-256 hash of the consumer key.
+func hashConsumerKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
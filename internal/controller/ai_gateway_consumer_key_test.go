@@ -0,0 +1,203 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	fake2 "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+
+	aigv1a1 "github.com/envoyproxy/ai-gateway/api/v1alpha1"
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	internaltesting "github.com/envoyproxy/ai-gateway/internal/testing"
+)
+
+func TestAIGatewayConsumerKeyController_Reconcile(t *testing.T) {
+	eventCh := internaltesting.NewControllerEventChan[*aigv1a1.AIGatewayRoute]()
+	fakeClient := requireNewFakeClientWithIndexes(t)
+	kube := fake2.NewClientset()
+	c := NewAIGatewayConsumerKeyController(fakeClient, kube, ctrl.Log, eventCh.Ch)
+	const namespace = "default"
+
+	route := &aigv1a1.AIGatewayRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "keyed", Namespace: namespace},
+		Spec:       aigv1a1.AIGatewayRouteSpec{RequireConsumerKey: true},
+	}
+	require.NoError(t, fakeClient.Create(t.Context(), route))
+	// The route not requiring the consumer keys is not synced.
+	require.NoError(t, fakeClient.Create(t.Context(), &aigv1a1.AIGatewayRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "open", Namespace: namespace},
+	}))
+	for name, value := range map[string]string{"valid": "sk-alice", "invalid": "alice"} {
+		_, err := kube.CoreV1().Secrets(namespace).Create(t.Context(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       map[string][]byte{apiKeyInSecret: []byte(value)},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	_, err := kube.CoreV1().Secrets("other").Create(t.Context(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "foreign", Namespace: "other"},
+		Data:       map[string][]byte{apiKeyInSecret: []byte("sk-mallory")},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		secret, secretNamespace, expCondition string
+		expErr                                string
+	}{
+		{secret: "valid", expCondition: aigv1a1.ConditionTypeAccepted},
+		{secret: "invalid", expCondition: aigv1a1.ConditionTypeNotAccepted, expErr: `key in secret invalid must start with "sk-"`},
+		{secret: "missing", expCondition: aigv1a1.ConditionTypeNotAccepted, expErr: `secrets "missing" not found`},
+		{
+			secret: "foreign", secretNamespace: "other", expCondition: aigv1a1.ConditionTypeNotAccepted,
+			expErr: "secretRef of AIGatewayConsumerKey foreign must refer to a secret in namespace default",
+		},
+	} {
+		t.Run(tc.secret, func(t *testing.T) {
+			ref := &gwapiv1.SecretObjectReference{Name: gwapiv1.ObjectName(tc.secret)}
+			if tc.secretNamespace != "" {
+				ref.Namespace = ptr.To(gwapiv1.Namespace(tc.secretNamespace))
+			}
+			key := &aigv1a1.AIGatewayConsumerKey{
+				ObjectMeta: metav1.ObjectMeta{Name: tc.secret, Namespace: namespace},
+				Spec:       aigv1a1.AIGatewayConsumerKeySpec{Consumer: "alice", SecretRef: ref},
+			}
+			require.NoError(t, fakeClient.Create(t.Context(), key))
+			_, err := c.Reconcile(t.Context(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: tc.secret}})
+			if tc.expErr != "" {
+				require.ErrorContains(t, err, tc.expErr)
+			} else {
+				require.NoError(t, err)
+			}
+			items := eventCh.RequireItemsEventually(t, 1)
+			require.Equal(t, "keyed", items[0].Name)
+
+			var current aigv1a1.AIGatewayConsumerKey
+			require.NoError(t, fakeClient.Get(t.Context(), types.NamespacedName{Namespace: namespace, Name: tc.secret}, &current))
+			require.Len(t, current.Status.Conditions, 1)
+			require.Equal(t, tc.expCondition, current.Status.Conditions[0].Type)
+		})
+	}
+
+	// The routes are also synced when the key is deleted.
+	require.NoError(t, fakeClient.Delete(t.Context(), &aigv1a1.AIGatewayConsumerKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "valid", Namespace: namespace},
+	}))
+	_, err = c.Reconcile(t.Context(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "valid"}})
+	require.NoError(t, err)
+	items := eventCh.RequireItemsEventually(t, 1)
+	require.Equal(t, "keyed", items[0].Name)
+}
+
+func TestGatewayController_consumerKeysToFilterAPI(t *testing.T) {
+	fakeClient := requireNewFakeClientWithIndexes(t)
+	kube := fake2.NewClientset()
+	c := NewGatewayController(fakeClient, kube, ctrl.Log, "envoy-gateway-system", "/foo/bar/uds.sock", "extproc:latest", false)
+	const namespace = "ns"
+
+	routes := []aigv1a1.AIGatewayRoute{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "chat", Namespace: namespace},
+			Spec: aigv1a1.AIGatewayRouteSpec{
+				RequireConsumerKey: true,
+				Rules:              []aigv1a1.AIGatewayRouteRule{{}, {}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "embeddings", Namespace: namespace},
+			Spec: aigv1a1.AIGatewayRouteSpec{
+				RequireConsumerKey: true,
+				Rules:              []aigv1a1.AIGatewayRouteRule{{}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "open", Namespace: namespace},
+			Spec:       aigv1a1.AIGatewayRouteSpec{Rules: []aigv1a1.AIGatewayRouteRule{{}}},
+		},
+	}
+	for name, value := range map[string]string{"alice": "sk-alice", "bob": " sk-bob\n", "carol": "sk-carol", "invalid": "nope"} {
+		_, err := kube.CoreV1().Secrets(namespace).Create(t.Context(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Data:       map[string][]byte{apiKeyInSecret: []byte(value)},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	expiresAt := metav1.NewTime(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	for _, key := range []*aigv1a1.AIGatewayConsumerKey{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: namespace},
+			Spec: aigv1a1.AIGatewayConsumerKeySpec{
+				Consumer: "alice", SecretRef: &gwapiv1.SecretObjectReference{Name: "alice"},
+				Models: []string{"gpt-4o"}, ExpiresAt: &expiresAt,
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bob", Namespace: namespace},
+			Spec: aigv1a1.AIGatewayConsumerKeySpec{
+				Consumer: "bob", SecretRef: &gwapiv1.SecretObjectReference{Name: "bob"},
+				AIGatewayRouteNames: []string{"embeddings"},
+			},
+		},
+		{
+			// The revoked key is left out.
+			ObjectMeta: metav1.ObjectMeta{Name: "carol", Namespace: namespace},
+			Spec: aigv1a1.AIGatewayConsumerKeySpec{
+				Consumer: "carol", SecretRef: &gwapiv1.SecretObjectReference{Name: "carol"}, Revoked: true,
+			},
+		},
+		{
+			// The key restricted to the route not requiring the keys is left out.
+			ObjectMeta: metav1.ObjectMeta{Name: "dave", Namespace: namespace},
+			Spec: aigv1a1.AIGatewayConsumerKeySpec{
+				Consumer: "dave", SecretRef: &gwapiv1.SecretObjectReference{Name: "alice"}, AIGatewayRouteNames: []string{"open"},
+			},
+		},
+		{
+			// The key with the invalid secret is left out.
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: namespace},
+			Spec: aigv1a1.AIGatewayConsumerKeySpec{
+				Consumer: "invalid", SecretRef: &gwapiv1.SecretObjectReference{Name: "invalid"},
+			},
+		},
+		{
+			// The key in the other namespace is left out.
+			ObjectMeta: metav1.ObjectMeta{Name: "eve", Namespace: "other"},
+			Spec: aigv1a1.AIGatewayConsumerKeySpec{
+				Consumer: "eve", SecretRef: &gwapiv1.SecretObjectReference{Name: "alice", Namespace: ptr.To[gwapiv1.Namespace](namespace)},
+			},
+		},
+	} {
+		require.NoError(t, fakeClient.Create(t.Context(), key))
+	}
+
+	keys, err := c.consumerKeysToFilterAPI(t.Context(), routes)
+	require.NoError(t, err)
+	require.Equal(t, []filterapi.ConsumerKey{
+		{
+			Hash:       hashConsumerKey("sk-alice"),
+			Consumer:   "alice",
+			Models:     []string{"gpt-4o"},
+			RouteRules: []filterapi.RouteRuleName{"chat-rule-0", "chat-rule-1", "embeddings-rule-0"},
+			ExpiresAt:  expiresAt.UTC(),
+		},
+		{
+			Hash:       hashConsumerKey("sk-bob"),
+			Consumer:   "bob",
+			RouteRules: []filterapi.RouteRuleName{"embeddings-rule-0"},
+		},
+	}, keys)
+	// The hash is the hex-encoded SHA-256 of the key.
+	require.Equal(t, "099295a3784e1bd368dc348843a7398c1931b6b8ec2504c73e91ed2040bdc46c", keys[0].Hash)
+}
@@ -40,6 +40,8 @@ const (
 	egOwningGatewayNamespaceLabel            = "gateway.envoyproxy.io/owning-gateway-namespace"
 	// apiKeyInSecret is the key to store OpenAI API key.
 	apiKeyInSecret = "apiKey"
+	// redactionHashKeyInSecret is the key to store the HMAC key of the Hash action of the redaction.
+	redactionHashKeyInSecret = "hashKey"
 )
 
 // AIGatewayRouteController implements [reconcile.TypedReconciler].
@@ -95,6 +97,12 @@ func FilterConfigSecretPerGatewayName(gwName, gwNamespace string) string {
 	return fmt.Sprintf("%s-%s", gwName, gwNamespace)
 }
 
+// BackendCredentialsSecretPerGatewayName returns the name of the Secret of the backend credentials mounted into the
+// external processor of the gateway when the credentials are not inlined in the filter config.
+func BackendCredentialsSecretPerGatewayName(gwName, gwNamespace string) string {
+	return FilterConfigSecretPerGatewayName(gwName, gwNamespace) + "-backend-credentials"
+}
+
 // syncAIGatewayRoute is the main logic for reconciling the AIGatewayRoute resource.
 // This is decoupled from the Reconcile method to centralize the error handling and status updates.
 func (c *AIGatewayRouteController) syncAIGatewayRoute(ctx context.Context, aiGatewayRoute *aigv1a1.AIGatewayRoute) error {
@@ -109,6 +109,10 @@ func (c *BackendSecurityPolicyController) rotateCredential(ctx context.Context,
 			return ctrl.Result{}, nil
 		}
 	case aigv1a1.BackendSecurityPolicyTypeAzureCredentials:
+		if bsp.Spec.AzureCredentials.APIKeyRef != nil {
+			// The API key is used as is, so there is no token to refresh.
+			return ctrl.Result{}, nil
+		}
 		clientID := bsp.Spec.AzureCredentials.ClientID
 		tenantID := bsp.Spec.AzureCredentials.TenantID
 		var provider tokenprovider.TokenProvider
@@ -23,6 +23,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	fake2 "k8s.io/client-go/kubernetes/fake"
@@ -482,6 +483,30 @@ func TestNewBackendSecurityPolicyController_ReconcileAzureMissingSecretData(t *t
 	require.Equal(t, time.Duration(0), res.RequeueAfter)
 }
 
+func TestNewBackendSecurityPolicyController_ReconcileAzureAPIKey(t *testing.T) {
+	eventCh := internaltesting.NewControllerEventChan[*aigv1a1.AIServiceBackend]()
+	cl := requireNewFakeClientWithIndexes(t)
+	c := NewBackendSecurityPolicyController(cl, fake2.NewClientset(), ctrl.Log, eventCh.Ch)
+	bspName := "my-azure-api-key-backend-security-policy"
+
+	bsp := &aigv1a1.BackendSecurityPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: bspName, Namespace: "default"},
+		Spec: aigv1a1.BackendSecurityPolicySpec{
+			Type: aigv1a1.BackendSecurityPolicyTypeAzureCredentials,
+			AzureCredentials: &aigv1a1.BackendSecurityPolicyAzureCredentials{
+				APIKeyRef: &gwapiv1.SecretObjectReference{Name: "some-azure-api-key"},
+			},
+		},
+	}
+	require.NoError(t, cl.Create(t.Context(), bsp))
+	// The API key is not rotated, so neither the token is requested nor the reconciliation is requeued.
+	res, err := c.Reconcile(t.Context(), reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: bspName}})
+	require.NoError(t, err)
+	require.Zero(t, res.RequeueAfter)
+	_, err = rotators.LookupSecret(t.Context(), cl, "default", rotators.GetBSPSecretName(bspName))
+	require.True(t, apierrors.IsNotFound(err))
+}
+
 func TestNewBackendSecurityPolicyController_RotateCredentialInvalidType(t *testing.T) {
 	eventCh := internaltesting.NewControllerEventChan[*aigv1a1.AIServiceBackend]()
 	cl := fake.NewClientBuilder().WithScheme(Scheme).Build()
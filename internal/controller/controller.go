@@ -30,6 +30,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	gwaiev1a2 "sigs.k8s.io/gateway-api-inference-extension/api/v1alpha2"
 	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gwapiv1a3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
 	gwapiv1b1 "sigs.k8s.io/gateway-api/apis/v1beta1"
 
 	aigv1a1 "github.com/envoyproxy/ai-gateway/api/v1alpha1"
@@ -41,6 +42,7 @@ func init() {
 	utilruntime.Must(apiextensionsv1.AddToScheme(Scheme))
 	utilruntime.Must(egv1a1.AddToScheme(Scheme))
 	utilruntime.Must(gwapiv1.Install(Scheme))
+	utilruntime.Must(gwapiv1a3.Install(Scheme))
 	utilruntime.Must(gwapiv1b1.Install(Scheme))
 	utilruntime.Must(gwaiev1a2.Install(Scheme))
 }
@@ -54,6 +56,21 @@ var Scheme = runtime.NewScheme()
 type Options struct {
 	// ExtProcLogLevel is the log level for the external processor, e.g., debug, info, warn, or error.
 	ExtProcLogLevel string
+	// ExtProcTokenQuotaStoreURL is the URL of the Redis-compatible store of the token quotas for the external processor.
+	// The token quota usage is kept in memory of each external processor if empty.
+	ExtProcTokenQuotaStoreURL string
+	// ExtProcUsageLedgerSink is the sink of the usage ledger of the external processor.
+	// The usage ledger is disabled if empty.
+	ExtProcUsageLedgerSink string
+	// ExtProcAuditLogSink is the sink of the audit log of the external processor.
+	// The audit log is disabled if empty.
+	ExtProcAuditLogSink string
+	// ExtProcResponseCacheMaxSizeMB is the maximum size of the response cache of the external processor in megabytes.
+	// The default of the external processor is used if zero.
+	ExtProcResponseCacheMaxSizeMB int
+	// ExtProcMountBackendCredentials is true if the backend credentials are written to a separate Secret mounted into
+	// the external processor, and referenced as files from the filter config instead of being inlined in it.
+	ExtProcMountBackendCredentials bool
 	// ExtProcImage is the image for the external processor set on Deployment.
 	ExtProcImage string
 	// ExtProcImagePullPolicy is the image pull policy for the external processor set on Deployment.
@@ -82,7 +99,8 @@ func StartControllers(ctx context.Context, mgr manager.Manager, config *rest.Con
 
 	gatewayEventChan := make(chan event.GenericEvent, 100)
 	gatewayC := NewGatewayController(c, kubernetes.NewForConfigOrDie(config),
-		logger.WithName("gateway"), options.EnvoyGatewayNamespace, options.UDSPath, options.ExtProcImage)
+		logger.WithName("gateway"), options.EnvoyGatewayNamespace, options.UDSPath, options.ExtProcImage,
+		options.ExtProcMountBackendCredentials)
 	if err = TypedControllerBuilderForCRD(mgr, &gwapiv1.Gateway{}).
 		// We need the annotation change event to reconcile the Gateway referenced by AIGatewayRoutes.
 		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{})).
@@ -121,6 +139,18 @@ func StartControllers(ctx context.Context, mgr manager.Manager, config *rest.Con
 		return fmt.Errorf("failed to create controller for AIServiceBackend: %w", err)
 	}
 
+	consumerKeyEventChan := make(chan event.GenericEvent, 100)
+	consumerKeyC := NewAIGatewayConsumerKeyController(c, kubernetes.NewForConfigOrDie(config), logger.
+		WithName("ai-gateway-consumer-key"), aiGatewayRouteEventChan)
+	if err = TypedControllerBuilderForCRD(mgr, &aigv1a1.AIGatewayConsumerKey{}).
+		WatchesRawSource(source.Channel(
+			consumerKeyEventChan,
+			&handler.EnqueueRequestForObject{},
+		)).
+		Complete(consumerKeyC); err != nil {
+		return fmt.Errorf("failed to create controller for AIGatewayConsumerKey: %w", err)
+	}
+
 	backendSecurityPolicyEventChan := make(chan event.GenericEvent, 100)
 	backendSecurityPolicyC := NewBackendSecurityPolicyController(c, kubernetes.NewForConfigOrDie(config), logger.
 		WithName("backend-security-policy"), aiServiceBackendEventChan)
@@ -134,7 +164,7 @@ func StartControllers(ctx context.Context, mgr manager.Manager, config *rest.Con
 	}
 
 	secretC := NewSecretController(c, kubernetes.NewForConfigOrDie(config), logger.
-		WithName("secret"), backendSecurityPolicyEventChan)
+		WithName("secret"), backendSecurityPolicyEventChan, consumerKeyEventChan, aiGatewayRouteEventChan)
 	// Do not use TypedControllerBuilderForCRD for secret, as changing a secret content doesn't change the generation.
 	if err = ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.Secret{}).
@@ -148,6 +178,11 @@ func StartControllers(ctx context.Context, mgr manager.Manager, config *rest.Con
 			options.ExtProcImage,
 			options.ExtProcImagePullPolicy,
 			options.ExtProcLogLevel,
+			options.ExtProcTokenQuotaStoreURL,
+			options.ExtProcUsageLedgerSink,
+			options.ExtProcAuditLogSink,
+			options.ExtProcResponseCacheMaxSizeMB,
+			options.ExtProcMountBackendCredentials,
 			options.EnvoyGatewayNamespace,
 			options.UDSPath,
 		))
@@ -179,6 +214,12 @@ const (
 	// k8sClientIndexSecretToReferencingBackendSecurityPolicy is the index name that maps
 	// from a Secret to the BackendSecurityPolicy that references it.
 	k8sClientIndexSecretToReferencingBackendSecurityPolicy = "SecretToReferencingBackendSecurityPolicy"
+	// k8sClientIndexSecretToReferencingAIGatewayConsumerKey is the index name that maps
+	// from a Secret to the AIGatewayConsumerKey that references it.
+	k8sClientIndexSecretToReferencingAIGatewayConsumerKey = "SecretToReferencingAIGatewayConsumerKey"
+	// k8sClientIndexSecretToReferencingAIGatewayRoute is the index name that maps from a Secret to the AIGatewayRoute
+	// referencing it, e.g. as the hash key of the redaction.
+	k8sClientIndexSecretToReferencingAIGatewayRoute = "SecretToReferencingAIGatewayRoute"
 	// k8sClientIndexBackendToReferencingAIGatewayRoute is the index name that maps from a Backend to the
 	// AIGatewayRoute that references it.
 	k8sClientIndexBackendToReferencingAIGatewayRoute = "BackendToReferencingAIGatewayRoute"
@@ -209,6 +250,16 @@ func ApplyIndexing(ctx context.Context, indexer func(ctx context.Context, obj cl
 	if err != nil {
 		return fmt.Errorf("failed to create index from Secret to BackendSecurityPolicy: %w", err)
 	}
+	err = indexer(ctx, &aigv1a1.AIGatewayConsumerKey{},
+		k8sClientIndexSecretToReferencingAIGatewayConsumerKey, aiGatewayConsumerKeyIndexFunc)
+	if err != nil {
+		return fmt.Errorf("failed to create index from Secret to AIGatewayConsumerKey: %w", err)
+	}
+	err = indexer(ctx, &aigv1a1.AIGatewayRoute{},
+		k8sClientIndexSecretToReferencingAIGatewayRoute, aiGatewayRouteSecretIndexFunc)
+	if err != nil {
+		return fmt.Errorf("failed to create index from Secret to AIGatewayRoute: %w", err)
+	}
 	return nil
 }
 
@@ -233,6 +284,18 @@ func aiGatewayRouteIndexFunc(o client.Object) []string {
 	return ret
 }
 
+func aiGatewayRouteSecretIndexFunc(o client.Object) []string {
+	aiGatewayRoute := o.(*aigv1a1.AIGatewayRoute)
+	var ret []string
+	for _, rule := range aiGatewayRoute.Spec.Rules {
+		if r := rule.Redaction; r != nil && r.HashKeyRef != nil {
+			// The hash key is always read from the namespace of the AIGatewayRoute.
+			ret = append(ret, fmt.Sprintf("%s.%s", r.HashKeyRef.Name, aiGatewayRoute.Namespace))
+		}
+	}
+	return ret
+}
+
 func aiServiceBackendIndexFunc(o client.Object) []string {
 	aiServiceBackend := o.(*aigv1a1.AIServiceBackend)
 	var ret []string
@@ -256,10 +319,22 @@ func backendSecurityPolicyIndexFunc(o client.Object) []string {
 		} else if awsCreds.OIDCExchangeToken != nil {
 			key = backendSecurityPolicyKey(backendSecurityPolicy.Namespace, backendSecurityPolicy.Name)
 		}
+	case aigv1a1.BackendSecurityPolicyTypeAzureCredentials:
+		if apiKeyRef := backendSecurityPolicy.Spec.AzureCredentials.APIKeyRef; apiKeyRef != nil {
+			key = getSecretNameAndNamespace(apiKeyRef, backendSecurityPolicy.Namespace)
+		}
 	}
 	return []string{key}
 }
 
+func aiGatewayConsumerKeyIndexFunc(o client.Object) []string {
+	key := o.(*aigv1a1.AIGatewayConsumerKey)
+	if key.Spec.SecretRef == nil {
+		return nil
+	}
+	return []string{getSecretNameAndNamespace(key.Spec.SecretRef, key.Namespace)}
+}
+
 func getSecretNameAndNamespace(secretRef *gwapiv1.SecretObjectReference, namespace string) string {
 	if secretRef.Namespace != nil {
 		return fmt.Sprintf("%s.%s", secretRef.Name, *secretRef.Namespace)
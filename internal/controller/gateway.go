@@ -9,6 +9,12 @@ import (
 	"cmp"
 	"context"
 	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	egv1a1 "github.com/envoyproxy/gateway/api/v1alpha1"
 	"github.com/go-logr/logr"
@@ -24,10 +30,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
 	gwapiv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gwapiv1a3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
 	"sigs.k8s.io/yaml"
 
 	aigv1a1 "github.com/envoyproxy/ai-gateway/api/v1alpha1"
 	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/authzcel"
 	"github.com/envoyproxy/ai-gateway/internal/controller/rotators"
 	"github.com/envoyproxy/ai-gateway/internal/llmcostcel"
 )
@@ -37,23 +45,29 @@ const (
 	FilterConfigKeyInSecret = "filter-config.yaml" //nolint: gosec
 	// defaultOwnedBy is the default value for the ModelsOwnedBy field in the filter config.
 	defaultOwnedBy = "Envoy AI Gateway"
+	// backendCredentialsMountPath is the path where the backend credentials Secret is mounted into the extproc.
+	backendCredentialsMountPath = "/etc/backend-credentials"
 )
 
 // NewGatewayController creates a new reconcile.TypedReconciler for gwapiv1.Gateway.
 //
 // extProcImage is the image of the external processor sidecar container which will be used
 // to check if the pods of the gateway deployment need to be rolled out.
+//
+// mountBackendCredentials is true if the backend credentials are written to a separate Secret mounted into the
+// external processor instead of being inlined in the filter config.
 func NewGatewayController(
 	client client.Client, kube kubernetes.Interface, logger logr.Logger,
-	envoyGatewayNamespace, udsPath, extProcImage string,
+	envoyGatewayNamespace, udsPath, extProcImage string, mountBackendCredentials bool,
 ) *GatewayController {
 	return &GatewayController{
-		client:                client,
-		kube:                  kube,
-		logger:                logger,
-		envoyGatewayNamespace: envoyGatewayNamespace,
-		udsPath:               udsPath,
-		extProcImage:          extProcImage,
+		client:                  client,
+		kube:                    kube,
+		logger:                  logger,
+		envoyGatewayNamespace:   envoyGatewayNamespace,
+		udsPath:                 udsPath,
+		extProcImage:            extProcImage,
+		mountBackendCredentials: mountBackendCredentials,
 	}
 }
 
@@ -65,6 +79,8 @@ type GatewayController struct {
 	envoyGatewayNamespace string
 	udsPath               string
 	extProcImage          string // The image of the external processor sidecar container.
+	// mountBackendCredentials is true if the backend credentials are referenced as files from the filter config.
+	mountBackendCredentials bool
 }
 
 // Reconcile implements the reconcile.Reconciler for gwapiv1.Gateway.
@@ -90,7 +106,7 @@ func (c *GatewayController) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		c.logger.Info("No AIGatewayRoute attached to the Gateway", "namespace", gw.Namespace, "name", gw.Name)
 		return ctrl.Result{}, nil
 	}
-	if err := c.ensureExtensionPolicy(ctx, &gw); err != nil {
+	if err := c.ensureExtensionPolicy(ctx, &gw, extProcMessageTimeout(routes.Items)); err != nil {
 		return ctrl.Result{}, err
 	}
 
@@ -112,8 +128,64 @@ func (c *GatewayController) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 const sideCarExtProcBackendName = "envoy-ai-gateway-extproc-backend"
 
-// ensureExtensionPolicy creates or updates the extension policy for the external process running as a sidecar.
-func (c *GatewayController) ensureExtensionPolicy(ctx context.Context, gw *gwapiv1.Gateway) (err error) {
+const (
+	// defaultExtProcMessageTimeout is the default timeout of Envoy for the external processor to respond to a message.
+	defaultExtProcMessageTimeout = 200 * time.Millisecond
+	// defaultGuardrailTimeout is the default timeout of the requests to the moderation backends.
+	defaultGuardrailTimeout = 5 * time.Second
+	// defaultSemanticCacheTimeout is the default timeout of the requests to the embeddings backends of the semantic caches.
+	defaultSemanticCacheTimeout = 2 * time.Second
+	// defaultEmbeddingsBatchTimeout is the default timeout of the batches of a split embeddings request.
+	defaultEmbeddingsBatchTimeout = 30 * time.Second
+)
+
+// extProcMessageTimeout returns the message timeout of the external processor for the AIGatewayRoutes attached to
+// a Gateway.
+//
+// The external processor calls the moderation backends, the embeddings backends of the semantic caches and the
+// batches of the split embeddings requests while Envoy waits for it to respond to the request or the response body.
+// So, the message timeout covers the longest of these calls made for a single message on top of the default message
+// timeout of Envoy. The invalid timeouts are ignored here since they fail the filter config anyway.
+func extProcMessageTimeout(routes []aigv1a1.AIGatewayRoute) time.Duration {
+	timeout := func(d *gwapiv1.Duration, defaultTimeout time.Duration) time.Duration {
+		if d == nil {
+			return defaultTimeout
+		}
+		parsed, _ := time.ParseDuration(string(*d))
+		return parsed
+	}
+	var longest time.Duration
+	for i := range routes {
+		for j := range routes[i].Spec.Rules {
+			rule := &routes[i].Spec.Rules[j]
+			// The guardrails of a phase are called concurrently.
+			var input, output time.Duration
+			for k := range rule.Guardrails {
+				g := &rule.Guardrails[k]
+				t := timeout(g.Timeout, defaultGuardrailTimeout)
+				if len(g.Phases) == 0 || slices.Contains(g.Phases, aigv1a1.AIGatewayRouteRuleGuardrailPhaseInput) {
+					input = max(input, t)
+				}
+				if slices.Contains(g.Phases, aigv1a1.AIGatewayRouteRuleGuardrailPhaseOutput) {
+					output = max(output, t)
+				}
+			}
+			// The semantic cache and the batches are called after the input guardrails with the same request body.
+			if rc := rule.ResponseCache; rc != nil && rc.Semantic != nil {
+				input += timeout(rc.Semantic.Timeout, defaultSemanticCacheTimeout)
+			}
+			if e := rule.Embeddings; e != nil && e.MaxInputsPerRequest != nil {
+				input += timeout(e.BatchTimeout, defaultEmbeddingsBatchTimeout)
+			}
+			longest = max(longest, input, output)
+		}
+	}
+	return defaultExtProcMessageTimeout + longest
+}
+
+// ensureExtensionPolicy creates or updates the extension policy for the external process running as a sidecar
+// with the given message timeout.
+func (c *GatewayController) ensureExtensionPolicy(ctx context.Context, gw *gwapiv1.Gateway, messageTimeout time.Duration) (err error) {
 	// Ensure that the backend that makes Envoy talk to the UDS exists.
 	var backend egv1a1.Backend
 	if err = c.client.Get(ctx, client.ObjectKey{Name: sideCarExtProcBackendName, Namespace: gw.Namespace}, &backend); err != nil {
@@ -136,8 +208,16 @@ func (c *GatewayController) ensureExtensionPolicy(ctx context.Context, gw *gwapi
 	}
 
 	perGatewayEEPName := fmt.Sprintf("ai-eg-eep-%s", gw.Name)
+	timeout := ptr.To(gwapiv1.Duration(messageTimeout.String()))
 	var existingPolicy egv1a1.EnvoyExtensionPolicy
 	if err = c.client.Get(ctx, client.ObjectKey{Name: perGatewayEEPName, Namespace: gw.Namespace}, &existingPolicy); err == nil {
+		if len(existingPolicy.Spec.ExtProc) == 0 || ptr.Equal(existingPolicy.Spec.ExtProc[0].MessageTimeout, timeout) {
+			return
+		}
+		existingPolicy.Spec.ExtProc[0].MessageTimeout = timeout
+		if err = c.client.Update(ctx, &existingPolicy); err != nil {
+			err = fmt.Errorf("failed to update extension policy: %w", err)
+		}
 		return
 	} else if client.IgnoreNotFound(err) != nil {
 		return fmt.Errorf("failed to get extension policy: %w", err)
@@ -156,6 +236,7 @@ func (c *GatewayController) ensureExtensionPolicy(ctx context.Context, gw *gwapi
 				},
 			}},
 			ExtProc: []egv1a1.ExtProc{{
+				MessageTimeout: timeout,
 				ProcessingMode: &egv1a1.ExtProcProcessingMode{
 					AllowModeOverride: true, // Streaming completely overrides the buffered mode.
 					Request:           &egv1a1.ProcessingModeOptions{Body: ptr.To(egv1a1.BufferedExtProcBodyProcessingMode)},
@@ -206,6 +287,386 @@ func schemaToFilterAPI(schema aigv1a1.VersionedAPISchema) filterapi.VersionedAPI
 	return ret
 }
 
+// backendToFilterAPI populates the given filterapi.Backend from the AIServiceBackend with the given name,
+// and returns the AIServiceBackend object.
+func (c *GatewayController) backendToFilterAPI(ctx context.Context, namespace, name, modelNameOverride string, b *filterapi.Backend) (*aigv1a1.AIServiceBackend, error) {
+	b.Name = fmt.Sprintf("%s.%s", name, namespace)
+	b.ModelNameOverride = modelNameOverride
+	backendObj, err := c.backend(ctx, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get AIServiceBackend %s: %w", b.Name, err)
+	}
+	b.Schema = schemaToFilterAPI(backendObj.Spec.APISchema)
+	if limits := backendObj.Spec.TokenLimits; limits != nil {
+		b.ContextWindow = int(limits.ContextWindow)
+		b.MaxOutputTokens = int(ptr.Deref(limits.MaxOutputTokens, 0))
+	}
+	if cb := backendObj.Spec.CircuitBreaker; cb != nil {
+		b.CircuitBreaker = &filterapi.CircuitBreaker{ConsecutiveFailures: int(ptr.Deref(cb.ConsecutiveFailures, 5)), Cooldown: 30 * time.Second}
+		if cb.Cooldown != nil {
+			b.CircuitBreaker.Cooldown, err = time.ParseDuration(string(*cb.Cooldown))
+			if err != nil {
+				return nil, fmt.Errorf("invalid circuit breaker cooldown %q of AIServiceBackend %s: %w", *cb.Cooldown, b.Name, err)
+			}
+		}
+	}
+	for i := range backendObj.Spec.Pricing {
+		p, err := pricingToFilterAPI(&backendObj.Spec.Pricing[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pricing of AIServiceBackend %s: %w", b.Name, err)
+		}
+		b.Pricing = append(b.Pricing, p)
+	}
+	if bspRef := backendObj.Spec.BackendSecurityPolicyRef; bspRef != nil {
+		b.Auth, err = c.bspToFilterAPIBackendAuth(ctx, namespace, string(bspRef.Name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create backend auth: %w", err)
+		}
+	}
+	return backendObj, nil
+}
+
+// pricingToFilterAPI converts the pricing of a model to filterapi.ModelPricing by parsing the decimal prices.
+func pricingToFilterAPI(p *aigv1a1.AIServiceBackendModelPricing) (filterapi.ModelPricing, error) {
+	ret := filterapi.ModelPricing{Model: p.Model}
+	var err error
+	if ret.InputPerMillionTokens, err = strconv.ParseFloat(p.InputPerMillionTokens, 64); err != nil {
+		return ret, fmt.Errorf("invalid input price %q of model %s: %w", p.InputPerMillionTokens, p.Model, err)
+	}
+	if ret.OutputPerMillionTokens, err = strconv.ParseFloat(p.OutputPerMillionTokens, 64); err != nil {
+		return ret, fmt.Errorf("invalid output price %q of model %s: %w", p.OutputPerMillionTokens, p.Model, err)
+	}
+	ret.CachedInputPerMillionTokens = ret.InputPerMillionTokens
+	if p.CachedInputPerMillionTokens != nil {
+		if ret.CachedInputPerMillionTokens, err = strconv.ParseFloat(*p.CachedInputPerMillionTokens, 64); err != nil {
+			return ret, fmt.Errorf("invalid cached input price %q of model %s: %w", *p.CachedInputPerMillionTokens, p.Model, err)
+		}
+	}
+	return ret, nil
+}
+
+// requestPolicyToFilterAPI converts the request policy of a rule to filterapi.RequestPolicy by parsing the decimal
+// temperatures, defaulting the actions and converting the quantities to bytes.
+func requestPolicyToFilterAPI(p *aigv1a1.AIGatewayRouteRuleRequestPolicy) (*filterapi.RequestPolicy, error) {
+	limit := func(l *aigv1a1.AIGatewayRouteRuleRequestLimit) *filterapi.RequestLimit {
+		if l == nil {
+			return nil
+		}
+		return &filterapi.RequestLimit{Max: int(l.Max), Action: requestLimitActionToFilterAPI(l.Action)}
+	}
+	ret := &filterapi.RequestPolicy{
+		MaxTokens: limit(p.MaxTokens),
+		N:         limit(p.N),
+		Tools:     limit(p.Tools),
+		Messages:  limit(p.Messages),
+	}
+	if p.MaxBodySize != nil {
+		ret.MaxBodyBytes = p.MaxBodySize.Value()
+	}
+	if i := p.Images; i != nil {
+		ret.Images = &filterapi.ImagePolicy{AllowedMIMETypes: i.AllowedMIMETypes}
+		if i.MaxImages != nil {
+			ret.Images.MaxImages = ptr.To(int(*i.MaxImages))
+		}
+		if i.MaxImageSize != nil {
+			ret.Images.MaxImageBytes = i.MaxImageSize.Value()
+		}
+	}
+	if p.MaxToolSchemaDepth != nil {
+		ret.MaxToolSchemaDepth = int(*p.MaxToolSchemaDepth)
+	}
+	if t := p.Temperature; t != nil {
+		ret.Temperature = &filterapi.TemperatureLimit{Action: requestLimitActionToFilterAPI(t.Action)}
+		if t.Min != nil {
+			v, err := strconv.ParseFloat(*t.Min, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid min temperature %q: %w", *t.Min, err)
+			}
+			ret.Temperature.Min = &v
+		}
+		if t.Max != nil {
+			v, err := strconv.ParseFloat(*t.Max, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max temperature %q: %w", *t.Max, err)
+			}
+			ret.Temperature.Max = &v
+		}
+		if ret.Temperature.Min != nil && ret.Temperature.Max != nil && *ret.Temperature.Min > *ret.Temperature.Max {
+			return nil, fmt.Errorf("min temperature %s is greater than max temperature %s", *t.Min, *t.Max)
+		}
+	}
+	return ret, nil
+}
+
+func requestLimitActionToFilterAPI(a *aigv1a1.AIGatewayRouteRuleRequestLimitAction) filterapi.RequestLimitAction {
+	if a != nil && *a == aigv1a1.AIGatewayRouteRuleRequestLimitActionReject {
+		return filterapi.RequestLimitActionReject
+	}
+	return filterapi.RequestLimitActionClamp
+}
+
+// redactionToFilterAPI converts the redaction of a rule to filterapi.Redaction, checking that the patterns compile
+// so that an invalid pattern does not fail the whole configuration of the external processor.
+func (c *GatewayController) redactionToFilterAPI(ctx context.Context, namespace string, r *aigv1a1.AIGatewayRouteRuleRedaction) (*filterapi.Redaction, error) {
+	ret := &filterapi.Redaction{Responses: r.Responses}
+	if ref := r.HashKeyRef; ref != nil {
+		var err error
+		if ret.HashKey, err = c.getSecretData(ctx, namespace, string(ref.Name), redactionHashKeyInSecret); err != nil {
+			return nil, fmt.Errorf("failed to get hash key: %w", err)
+		}
+	}
+	for _, d := range r.Detectors {
+		fd := filterapi.RedactionDetector{
+			Name:      d.Name,
+			Builtin:   filterapi.RedactionBuiltinDetector(ptr.Deref(d.Builtin, "")),
+			Pattern:   ptr.Deref(d.Pattern, ""),
+			Validator: filterapi.RedactionValidator(ptr.Deref(d.Validator, "")),
+			Action:    filterapi.RedactionAction(ptr.Deref(d.Action, aigv1a1.AIGatewayRouteRuleRedactionActionMask)),
+		}
+		if fd.Pattern != "" {
+			if _, err := regexp.Compile(fd.Pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern of detector %s: %w", d.Name, err)
+			}
+		}
+		ret.Detectors = append(ret.Detectors, fd)
+	}
+	return ret, nil
+}
+
+// streamModerationToFilterAPI converts the stream moderation of a rule to filterapi.StreamModeration, checking that
+// the deny patterns compile.
+func streamModerationToFilterAPI(m *aigv1a1.AIGatewayRouteRuleStreamModeration) (*filterapi.StreamModeration, error) {
+	for _, p := range m.DenyPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("invalid deny pattern %q: %w", p, err)
+		}
+	}
+	return &filterapi.StreamModeration{WindowSize: int(ptr.Deref(m.WindowSize, 256)), DenyPatterns: m.DenyPatterns}, nil
+}
+
+// auditLogToFilterAPI converts the audit log of a rule to filterapi.AuditLog by parsing the decimal sampling rate.
+func auditLogToFilterAPI(a *aigv1a1.AIGatewayRouteRuleAuditLog) (*filterapi.AuditLog, error) {
+	rate, err := strconv.ParseFloat(ptr.Deref(a.SamplingRate, "1"), 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("invalid sampling rate %q", *a.SamplingRate)
+	}
+	return &filterapi.AuditLog{SamplingRate: rate, RedactFields: a.RedactFields}, nil
+}
+
+// authorizationToFilterAPI converts the authorization of an AIGatewayRoute to filterapi.Authorization, checking that
+// the CEL expression compiles.
+func authorizationToFilterAPI(aiGatewayRoute *aigv1a1.AIGatewayRoute) (*filterapi.Authorization, error) {
+	a := aiGatewayRoute.Spec.Authorization
+	if _, err := authzcel.NewProgram(a.CEL); err != nil {
+		return nil, fmt.Errorf("invalid authorization CEL expression: %w", err)
+	}
+	return &filterapi.Authorization{
+		Route:        aiGatewayRoute.Name,
+		ClaimsHeader: strings.ToLower(ptr.Deref(a.ClaimsHeader, "x-jwt-claims")),
+		CEL:          a.CEL,
+	}, nil
+}
+
+// shadowToFilterAPI converts the shadow configuration of a rule to filterapi.ShadowBackend.
+//
+// Since the shadow requests are sent by the external processor itself, this resolves the URL of the shadow backend
+// from the endpoint of the Envoy Gateway Backend referenced by the AIServiceBackend.
+func (c *GatewayController) shadowToFilterAPI(ctx context.Context, namespace string, shadow *aigv1a1.AIGatewayRouteRuleShadow) (*filterapi.ShadowBackend, error) {
+	ret := &filterapi.ShadowBackend{Percent: int(ptr.Deref(shadow.Percent, 100))}
+	backendObj, err := c.backendToFilterAPI(ctx, namespace, shadow.Name, shadow.ModelNameOverride, &ret.Backend)
+	if err != nil {
+		return nil, err
+	}
+	if shadow.Timeout != nil {
+		ret.Timeout, err = time.ParseDuration(string(*shadow.Timeout))
+		if err != nil {
+			return nil, fmt.Errorf("invalid shadow timeout %q: %w", *shadow.Timeout, err)
+		}
+	}
+
+	if ret.URL, ret.TLS, err = c.directBackendEndpoint(ctx, namespace, backendObj); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// guardrailToFilterAPI converts a guardrail of a rule to filterapi.Guardrail.
+//
+// Like the shadow backends, the moderation backends are called by the external processor itself, so this resolves
+// the URL of the moderation backend from the endpoint of the Envoy Gateway Backend.
+func (c *GatewayController) guardrailToFilterAPI(ctx context.Context, namespace string, g *aigv1a1.AIGatewayRouteRuleGuardrail) (*filterapi.Guardrail, error) {
+	ret := &filterapi.Guardrail{
+		Name:       g.Name,
+		Type:       filterapi.GuardrailType(ptr.Deref(g.Type, aigv1a1.AIGatewayRouteRuleGuardrailTypeOpenAIModeration)),
+		Path:       ptr.Deref(g.Path, "/v1/moderations"),
+		Model:      ptr.Deref(g.Model, ""),
+		Categories: g.Categories,
+		Action:     filterapi.GuardrailAction(ptr.Deref(g.Action, aigv1a1.AIGatewayRouteRuleGuardrailActionBlock)),
+		FailClosed: ptr.Deref(g.FailureMode, aigv1a1.AIGatewayRouteRuleGuardrailFailureModeFailOpen) == aigv1a1.AIGatewayRouteRuleGuardrailFailureModeFailClosed,
+	}
+	phases := g.Phases
+	if len(phases) == 0 {
+		phases = []aigv1a1.AIGatewayRouteRuleGuardrailPhase{aigv1a1.AIGatewayRouteRuleGuardrailPhaseInput}
+	}
+	for _, p := range phases {
+		switch p {
+		case aigv1a1.AIGatewayRouteRuleGuardrailPhaseInput:
+			ret.Input = true
+		case aigv1a1.AIGatewayRouteRuleGuardrailPhaseOutput:
+			ret.Output = true
+		default:
+			return nil, fmt.Errorf("unknown phase %q of guardrail %s", p, g.Name)
+		}
+	}
+	threshold := ptr.Deref(g.Threshold, "0.5")
+	var err error
+	if ret.Threshold, err = strconv.ParseFloat(threshold, 64); err != nil {
+		return nil, fmt.Errorf("invalid threshold %q of guardrail %s: %w", threshold, g.Name, err)
+	}
+	ret.Timeout = defaultGuardrailTimeout
+	if g.Timeout != nil {
+		if ret.Timeout, err = time.ParseDuration(string(*g.Timeout)); err != nil {
+			return nil, fmt.Errorf("invalid timeout %q of guardrail %s: %w", *g.Timeout, g.Name, err)
+		}
+	}
+	backendObj, err := c.backendToFilterAPI(ctx, namespace, g.BackendName, "", &ret.Backend)
+	if err != nil {
+		return nil, err
+	}
+	if ret.URL, ret.TLS, err = c.directBackendEndpoint(ctx, namespace, backendObj); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// semanticCacheToFilterAPI converts the semantic cache of a rule to filterapi.SemanticCache.
+//
+// Like the moderation backends, the embeddings backend is called by the external processor itself, so this resolves
+// the URL of the embeddings backend from the endpoint of the Envoy Gateway Backend.
+func (c *GatewayController) semanticCacheToFilterAPI(ctx context.Context, namespace string, sc *aigv1a1.AIGatewayRouteRuleSemanticCache) (*filterapi.SemanticCache, error) {
+	ret := &filterapi.SemanticCache{
+		Path:                 ptr.Deref(sc.Path, "/v1/embeddings"),
+		Model:                sc.Model,
+		AllowUnauthenticated: sc.AllowUnauthenticated,
+	}
+	threshold := ptr.Deref(sc.Threshold, "0.95")
+	var err error
+	if ret.Threshold, err = strconv.ParseFloat(threshold, 64); err != nil {
+		return nil, fmt.Errorf("invalid semantic cache threshold %q: %w", threshold, err)
+	}
+	ret.Timeout = defaultSemanticCacheTimeout
+	if sc.Timeout != nil {
+		if ret.Timeout, err = time.ParseDuration(string(*sc.Timeout)); err != nil {
+			return nil, fmt.Errorf("invalid semantic cache timeout %q: %w", *sc.Timeout, err)
+		}
+	}
+	backendObj, err := c.backendToFilterAPI(ctx, namespace, sc.BackendName, "", &ret.Backend)
+	if err != nil {
+		return nil, err
+	}
+	if ret.URL, ret.TLS, err = c.directBackendEndpoint(ctx, namespace, backendObj); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// directBackendEndpoint returns the base URL and the TLS configuration of the AIServiceBackend called directly by
+// the external processor. See directBackendURL and directBackendTLS.
+func (c *GatewayController) directBackendEndpoint(ctx context.Context, namespace string, backendObj *aigv1a1.AIServiceBackend) (string, *filterapi.BackendTLS, error) {
+	tlsConfig, err := c.directBackendTLS(ctx, namespace, backendObj)
+	if err != nil {
+		return "", nil, err
+	}
+	url, err := c.directBackendURL(ctx, namespace, backendObj, tlsConfig != nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return url, tlsConfig, nil
+}
+
+// directBackendURL returns the base URL of the AIServiceBackend called directly by the external processor,
+// which is resolved from the first FQDN or IP endpoint of the referenced Envoy Gateway Backend.
+//
+// The URL uses https when the Backend has the TLS settings, its port is 443, or withTLS is true, i.e. the Backend
+// is targeted by a BackendTLSPolicy.
+func (c *GatewayController) directBackendURL(ctx context.Context, namespace string, backendObj *aigv1a1.AIServiceBackend, withTLS bool) (string, error) {
+	ref := backendObj.Spec.BackendRef
+	if kind := ptr.Deref(ref.Kind, "Backend"); kind != "Backend" {
+		return "", fmt.Errorf("unsupported kind %q of the backend ref of the AIServiceBackend %s", kind, backendObj.Name)
+	}
+	var egBackend egv1a1.Backend
+	egBackendKey := client.ObjectKey{Name: string(ref.Name), Namespace: string(ptr.Deref(ref.Namespace, gwapiv1.Namespace(namespace)))}
+	if err := c.client.Get(ctx, egBackendKey, &egBackend); err != nil {
+		return "", fmt.Errorf("failed to get Backend %s: %w", egBackendKey, err)
+	}
+	for _, ep := range egBackend.Spec.Endpoints {
+		var host string
+		var port int32
+		switch {
+		case ep.FQDN != nil:
+			host, port = ep.FQDN.Hostname, ep.FQDN.Port
+		case ep.IP != nil:
+			host, port = ep.IP.Address, ep.IP.Port
+		default:
+			continue
+		}
+		scheme := "http"
+		if withTLS || egBackend.Spec.TLS != nil || port == 443 {
+			scheme = "https"
+		}
+		return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(int(port)))), nil
+	}
+	return "", fmt.Errorf("backend %s has no FQDN or IP endpoint", egBackendKey)
+}
+
+// directBackendTLS returns the TLS configuration of the AIServiceBackend called directly by the external processor,
+// which is resolved from the BackendTLSPolicy targeting the referenced Envoy Gateway Backend. This returns nil
+// if there is no such policy.
+func (c *GatewayController) directBackendTLS(ctx context.Context, namespace string, backendObj *aigv1a1.AIServiceBackend) (*filterapi.BackendTLS, error) {
+	ref := backendObj.Spec.BackendRef
+	egBackendNamespace := string(ptr.Deref(ref.Namespace, gwapiv1.Namespace(namespace)))
+	var policies gwapiv1a3.BackendTLSPolicyList
+	if err := c.client.List(ctx, &policies, client.InNamespace(egBackendNamespace)); err != nil {
+		return nil, fmt.Errorf("failed to list BackendTLSPolicies: %w", err)
+	}
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if !slices.ContainsFunc(policy.Spec.TargetRefs, func(t gwapiv1a2.LocalPolicyTargetReferenceWithSectionName) bool {
+			return t.Group == egv1a1.GroupName && t.Kind == "Backend" && t.Name == ref.Name
+		}) {
+			continue
+		}
+		v := &policy.Spec.Validation
+		ret := &filterapi.BackendTLS{Hostname: string(v.Hostname)}
+		for _, caRef := range v.CACertificateRefs {
+			var data map[string]string
+			switch caRef.Kind {
+			case "ConfigMap":
+				var cm corev1.ConfigMap
+				if err := c.client.Get(ctx, client.ObjectKey{Name: string(caRef.Name), Namespace: egBackendNamespace}, &cm); err != nil {
+					return nil, fmt.Errorf("failed to get ConfigMap %s of BackendTLSPolicy %s: %w", caRef.Name, policy.Name, err)
+				}
+				data = cm.Data
+			case "Secret":
+				var secret corev1.Secret
+				if err := c.client.Get(ctx, client.ObjectKey{Name: string(caRef.Name), Namespace: egBackendNamespace}, &secret); err != nil {
+					return nil, fmt.Errorf("failed to get Secret %s of BackendTLSPolicy %s: %w", caRef.Name, policy.Name, err)
+				}
+				data = map[string]string{"ca.crt": string(secret.Data["ca.crt"])}
+			default:
+				return nil, fmt.Errorf("unsupported kind %q of the CA certificate ref of BackendTLSPolicy %s", caRef.Kind, policy.Name)
+			}
+			ca, ok := data["ca.crt"]
+			if !ok || ca == "" {
+				return nil, fmt.Errorf("missing ca.crt in the CA certificate ref %s of BackendTLSPolicy %s", caRef.Name, policy.Name)
+			}
+			ret.CACertificates += strings.TrimSpace(ca) + "\n"
+		}
+		return ret, nil
+	}
+	return nil, nil
+}
+
 // reconcileFilterConfigSecret updates the filter config secret for the external processor.
 func (c *GatewayController) reconcileFilterConfigSecret(ctx context.Context, gw *gwapiv1.Gateway, aiGatewayRoutes []aigv1a1.AIGatewayRoute, uuid string) error {
 	// Precondition: aiGatewayRoutes is not empty as we early return if it is empty.
@@ -215,6 +676,7 @@ func (c *GatewayController) reconcileFilterConfigSecret(ctx context.Context, gw
 	ec.Schema = schemaToFilterAPI(input)
 	ec.ModelNameHeaderKey = aigv1a1.AIModelHeaderKey
 	ec.SelectedRouteHeaderKey = selectedRouteHeaderKey
+	ec.SessionKeyHeaderKey = aigv1a1.SessionKeyHeaderKey
 	var err error
 	llmCosts := map[string]struct{}{}
 	for i := range aiGatewayRoutes {
@@ -225,24 +687,17 @@ func (c *GatewayController) reconcileFilterConfigSecret(ctx context.Context, gw
 			backends := make([]filterapi.Backend, len(rule.BackendRefs))
 			for j := range rule.BackendRefs {
 				backendRef := &rule.BackendRefs[j]
-				b := &backends[j]
-				b.Name = fmt.Sprintf("%s.%s", backendRef.Name, aiGatewayRoute.Namespace)
-				b.ModelNameOverride = backendRef.ModelNameOverride
-				var backendObj *aigv1a1.AIServiceBackend
-				backendObj, err = c.backend(ctx, aiGatewayRoute.Namespace, backendRef.Name)
-				if err != nil {
-					return fmt.Errorf("failed to get AIServiceBackend %s: %w", b.Name, err)
-				}
-				b.Schema = schemaToFilterAPI(backendObj.Spec.APISchema)
-				if bspRef := backendObj.Spec.BackendSecurityPolicyRef; bspRef != nil {
-					b.Auth, err = c.bspToFilterAPIBackendAuth(ctx, aiGatewayRoute.Namespace, string(bspRef.Name))
-					if err != nil {
-						return fmt.Errorf("failed to create backend auth: %w", err)
-					}
+				if _, err = c.backendToFilterAPI(ctx, aiGatewayRoute.Namespace, backendRef.Name, backendRef.ModelNameOverride, &backends[j]); err != nil {
+					return err
 				}
 			}
-			configRule := filterapi.RouteRule{Backends: backends}
+			configRule := filterapi.RouteRule{Backends: backends, RequireConsumerKey: spec.RequireConsumerKey}
 			configRule.Name = routeName(aiGatewayRoute, i)
+			if spec.Authorization != nil {
+				if configRule.Authorization, err = authorizationToFilterAPI(aiGatewayRoute); err != nil {
+					return fmt.Errorf("invalid authorization for rule %s: %w", configRule.Name, err)
+				}
+			}
 			configRule.Headers = make([]filterapi.HeaderMatch, len(rule.Matches))
 			for j, match := range rule.Matches {
 				configRule.Headers[j].Name = match.Headers[0].Name
@@ -251,6 +706,105 @@ func (c *GatewayController) reconcileFilterConfigSecret(ctx context.Context, gw
 			configRule.ModelsOwnedBy = ptr.Deref(rule.ModelsOwnedBy, defaultOwnedBy)
 			// Convert to UTC time in force to avoid timezone issues.
 			configRule.ModelsCreatedAt = ptr.Deref[metav1.Time](rule.ModelsCreatedAt, aiGatewayRoute.CreationTimestamp).Time.UTC()
+			configRule.LongContextFallbackModel = ptr.Deref(rule.LongContextFallbackModel, "")
+			if sa := rule.SessionAffinity; sa != nil {
+				configRule.SessionAffinity = &filterapi.SessionAffinity{Header: ptr.Deref(sa.Header, ""), UserField: sa.UserField}
+			}
+			if h := rule.Hedging; h != nil {
+				delay, err := time.ParseDuration(string(h.Delay))
+				if err != nil {
+					return fmt.Errorf("invalid hedging delay %q for rule %s: %w", h.Delay, configRule.Name, err)
+				}
+				configRule.Hedging = &filterapi.Hedging{Delay: delay, MaxHedgedRequests: int(ptr.Deref(h.MaxHedgedRequests, 1))}
+			}
+			for _, q := range spec.TokenQuotas {
+				configRule.TokenQuotas = append(configRule.TokenQuotas, filterapi.TokenQuota{
+					Name:            fmt.Sprintf("%s/%s/%s", aiGatewayRoute.Namespace, aiGatewayRoute.Name, q.Name),
+					ConsumerHeader:        q.ConsumerHeader,
+					RequireConsumerHeader: q.RequireConsumerHeader,
+					Type:                  filterapi.LLMRequestCostType(ptr.Deref(q.Type, aigv1a1.LLMRequestCostTypeTotalToken)),
+					TokensPerMinute:       ptr.Deref(q.TokensPerMinute, 0),
+					TokensPerDay:          ptr.Deref(q.TokensPerDay, 0),
+				})
+			}
+			if rule.RequestPolicy != nil {
+				configRule.RequestPolicy, err = requestPolicyToFilterAPI(rule.RequestPolicy)
+				if err != nil {
+					return fmt.Errorf("invalid request policy for rule %s: %w", configRule.Name, err)
+				}
+			}
+			if rc := rule.ResponseCache; rc != nil {
+				ttl := 5 * time.Minute
+				if rc.TTL != nil {
+					if ttl, err = time.ParseDuration(string(*rc.TTL)); err != nil {
+						return fmt.Errorf("invalid response cache ttl %q for rule %s: %w", *rc.TTL, configRule.Name, err)
+					}
+				}
+				configRule.ResponseCache = &filterapi.ResponseCache{
+					TTL:                ttl,
+					ConsumerHeader:     ptr.Deref(rc.ConsumerHeader, ""),
+					AllTemperatures:    rc.AllTemperatures,
+					IgnoreCacheControl: rc.IgnoreCacheControl,
+				}
+				if rc.Semantic != nil {
+					configRule.ResponseCache.Semantic, err = c.semanticCacheToFilterAPI(ctx, aiGatewayRoute.Namespace, rc.Semantic)
+					if err != nil {
+						return fmt.Errorf("failed to create semantic cache for rule %s: %w", configRule.Name, err)
+					}
+				}
+			}
+			if e := rule.Embeddings; e != nil {
+				configRule.Embeddings = &filterapi.Embeddings{
+					MaxInputsPerRequest: int(ptr.Deref(e.MaxInputsPerRequest, 0)),
+					BatchURL:            ptr.Deref(e.BatchURL, ""),
+					MaxConcurrency:      int(ptr.Deref(e.MaxConcurrency, 4)),
+					BatchTimeout:        defaultEmbeddingsBatchTimeout,
+				}
+				if e.BatchTimeout != nil {
+					if configRule.Embeddings.BatchTimeout, err = time.ParseDuration(string(*e.BatchTimeout)); err != nil {
+						return fmt.Errorf("invalid embeddings batch timeout %q for rule %s: %w", *e.BatchTimeout, configRule.Name, err)
+					}
+				}
+				if ic := e.InputCache; ic != nil {
+					configRule.Embeddings.InputCacheTTL = 24 * time.Hour
+					if ic.TTL != nil {
+						if configRule.Embeddings.InputCacheTTL, err = time.ParseDuration(string(*ic.TTL)); err != nil {
+							return fmt.Errorf("invalid embeddings input cache ttl %q for rule %s: %w", *ic.TTL, configRule.Name, err)
+						}
+					}
+				}
+			}
+			if rule.Redaction != nil {
+				configRule.Redaction, err = c.redactionToFilterAPI(ctx, aiGatewayRoute.Namespace, rule.Redaction)
+				if err != nil {
+					return fmt.Errorf("invalid redaction for rule %s: %w", configRule.Name, err)
+				}
+			}
+			for j := range rule.Guardrails {
+				var g *filterapi.Guardrail
+				if g, err = c.guardrailToFilterAPI(ctx, aiGatewayRoute.Namespace, &rule.Guardrails[j]); err != nil {
+					return fmt.Errorf("failed to create guardrail for rule %s: %w", configRule.Name, err)
+				}
+				configRule.Guardrails = append(configRule.Guardrails, *g)
+			}
+			if rule.StreamModeration != nil {
+				configRule.StreamModeration, err = streamModerationToFilterAPI(rule.StreamModeration)
+				if err != nil {
+					return fmt.Errorf("invalid stream moderation for rule %s: %w", configRule.Name, err)
+				}
+			}
+			if rule.AuditLog != nil {
+				configRule.AuditLog, err = auditLogToFilterAPI(rule.AuditLog)
+				if err != nil {
+					return fmt.Errorf("invalid audit log for rule %s: %w", configRule.Name, err)
+				}
+			}
+			if rule.Shadow != nil {
+				configRule.Shadow, err = c.shadowToFilterAPI(ctx, aiGatewayRoute.Namespace, rule.Shadow)
+				if err != nil {
+					return fmt.Errorf("failed to create shadow backend for rule %s: %w", configRule.Name, err)
+				}
+			}
 			ec.Rules = append(ec.Rules, configRule)
 
 			for _, cost := range aiGatewayRoute.Spec.LLMRequestCosts {
@@ -268,6 +822,18 @@ func (c *GatewayController) reconcileFilterConfigSecret(ctx context.Context, gw
 					fc.Type = filterapi.LLMRequestCostTypeOutputToken
 				case aigv1a1.LLMRequestCostTypeTotalToken:
 					fc.Type = filterapi.LLMRequestCostTypeTotalToken
+				case aigv1a1.LLMRequestCostTypeEstimatedInputToken:
+					fc.Type = filterapi.LLMRequestCostTypeEstimatedInputToken
+				case aigv1a1.LLMRequestCostTypeCachedInputToken:
+					fc.Type = filterapi.LLMRequestCostTypeCachedInputToken
+				case aigv1a1.LLMRequestCostTypeReasoningToken:
+					fc.Type = filterapi.LLMRequestCostTypeReasoningToken
+				case aigv1a1.LLMRequestCostTypeAudioInputToken:
+					fc.Type = filterapi.LLMRequestCostTypeAudioInputToken
+				case aigv1a1.LLMRequestCostTypeAudioOutputToken:
+					fc.Type = filterapi.LLMRequestCostTypeAudioOutputToken
+				case aigv1a1.LLMRequestCostTypeMicroUSD:
+					fc.Type = filterapi.LLMRequestCostTypeMicroUSD
 				case aigv1a1.LLMRequestCostTypeCEL:
 					fc.Type = filterapi.LLMRequestCostTypeCEL
 					expr := *cost.CEL
@@ -287,16 +853,31 @@ func (c *GatewayController) reconcileFilterConfigSecret(ctx context.Context, gw
 	}
 
 	ec.MetadataNamespace = aigv1a1.AIGatewayFilterMetadataNamespace
+	ec.ConsumerHeaderKey = aigv1a1.ConsumerHeaderKey
+	if ec.ConsumerKeys, err = c.consumerKeysToFilterAPI(ctx, aiGatewayRoutes); err != nil {
+		return err
+	}
+
+	// We need to create the Secrets in Envoy Gateway system namespace because the sidecar extproc need
+	// to access them.
+	if c.mountBackendCredentials {
+		// The credentials are written first so that the files exist when the extproc loads the filter config.
+		credentials := mountBackendCredentials(ec)
+		if err = c.applySecret(ctx, BackendCredentialsSecretPerGatewayName(gw.Name, gw.Namespace), credentials); err != nil {
+			return err
+		}
+	}
 
 	marshaled, err := yaml.Marshal(ec)
 	if err != nil {
 		return fmt.Errorf("failed to marshal extproc config: %w", err)
 	}
+	return c.applySecret(ctx, FilterConfigSecretPerGatewayName(gw.Name, gw.Namespace),
+		map[string]string{FilterConfigKeyInSecret: string(marshaled)})
+}
 
-	name := FilterConfigSecretPerGatewayName(gw.Name, gw.Namespace)
-	// We need to create the filter config in Envoy Gateway system namespace because the sidecar extproc need
-	// to access it.
-	data := map[string]string{FilterConfigKeyInSecret: string(marshaled)}
+// applySecret creates or updates the Secret of the name in the Envoy Gateway system namespace with the data.
+func (c *GatewayController) applySecret(ctx context.Context, name string, data map[string]string) error {
 	secret, err := c.kube.CoreV1().Secrets(c.envoyGatewayNamespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		if apierrors.IsNotFound(err) {
@@ -312,6 +893,8 @@ func (c *GatewayController) reconcileFilterConfigSecret(ctx context.Context, gw
 		return fmt.Errorf("failed to get secret %s: %w", name, err)
 	}
 
+	// Data is cleared so that the keys of the credentials no longer referenced are removed.
+	secret.Data = nil
 	secret.StringData = data
 	if _, err := c.kube.CoreV1().Secrets(c.envoyGatewayNamespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
 		return fmt.Errorf("failed to update secret %s: %w", secret.Name, err)
@@ -319,6 +902,59 @@ func (c *GatewayController) reconcileFilterConfigSecret(ctx context.Context, gw
 	return nil
 }
 
+// mountBackendCredentials moves the literal credentials of the backends in the filter config to the returned data of
+// the backend credentials Secret, and replaces them with the paths of the files of the Secret mounted into the
+// extproc at backendCredentialsMountPath. The key of each credential is the backend name followed by its kind.
+//
+// This keeps the filter config free of credentials. The extproc reloads the files when the kubelet updates the
+// mounted Secret, e.g. after the credentials are rotated.
+func mountBackendCredentials(ec *filterapi.Config) map[string]string {
+	data := map[string]string{}
+	mounted := map[*filterapi.BackendAuth]struct{}{}
+	mount := func(b *filterapi.Backend) {
+		if b.Auth == nil {
+			return
+		}
+		if _, ok := mounted[b.Auth]; ok {
+			return
+		}
+		mounted[b.Auth] = struct{}{}
+		file := func(kind, literal string) string {
+			key := b.Name + "." + kind
+			data[key] = literal
+			return backendCredentialsMountPath + "/" + key
+		}
+		switch auth := b.Auth; {
+		case auth.APIKey != nil:
+			auth.APIKey.KeyFile, auth.APIKey.Key = file("api-key", auth.APIKey.Key), ""
+		case auth.AWSAuth != nil:
+			auth.AWSAuth.CredentialFile, auth.AWSAuth.CredentialFileLiteral = file("aws-credentials", auth.AWSAuth.CredentialFileLiteral), ""
+		case auth.AzureAuth != nil && auth.AzureAuth.APIKey != "":
+			auth.AzureAuth.APIKeyFile, auth.AzureAuth.APIKey = file("azure-api-key", auth.AzureAuth.APIKey), ""
+		case auth.AzureAuth != nil:
+			auth.AzureAuth.AccessTokenFile, auth.AzureAuth.AccessToken = file("azure-access-token", auth.AzureAuth.AccessToken), ""
+		case auth.GCPAuth != nil:
+			auth.GCPAuth.AccessTokenFile, auth.GCPAuth.AccessToken = file("gcp-access-token", auth.GCPAuth.AccessToken), ""
+		}
+	}
+	for i := range ec.Rules {
+		r := &ec.Rules[i]
+		for j := range r.Backends {
+			mount(&r.Backends[j])
+		}
+		for j := range r.Guardrails {
+			mount(&r.Guardrails[j].Backend)
+		}
+		if r.Shadow != nil {
+			mount(&r.Shadow.Backend)
+		}
+		if r.ResponseCache != nil && r.ResponseCache.Semantic != nil {
+			mount(&r.ResponseCache.Semantic.Backend)
+		}
+	}
+	return data
+}
+
 func (c *GatewayController) bspToFilterAPIBackendAuth(ctx context.Context, namespace, bspName string) (*filterapi.BackendAuth, error) {
 	backendSecurityPolicy, err := c.backendSecurityPolicy(ctx, namespace, bspName)
 	if err != nil {
@@ -331,7 +967,7 @@ func (c *GatewayController) bspToFilterAPIBackendAuth(ctx context.Context, names
 		if err != nil {
 			return nil, fmt.Errorf("failed to get secret %s: %w", secretName, err)
 		}
-		return &filterapi.BackendAuth{APIKey: &filterapi.APIKeyAuth{Key: apiKey}}, nil
+		return &filterapi.BackendAuth{APIKey: apiKeyToFilterAPI(backendSecurityPolicy.Spec.APIKey, apiKey)}, nil
 	case aigv1a1.BackendSecurityPolicyTypeAWSCredentials:
 		if backendSecurityPolicy.Spec.AWSCredentials == nil {
 			return nil, fmt.Errorf("AWSCredentials type selected but not defined %s", backendSecurityPolicy.Name)
@@ -359,6 +995,14 @@ func (c *GatewayController) bspToFilterAPIBackendAuth(ctx context.Context, names
 		if backendSecurityPolicy.Spec.AzureCredentials == nil {
 			return nil, fmt.Errorf("AzureCredentials type selected but not defined %s", backendSecurityPolicy.Name)
 		}
+		if apiKeyRef := backendSecurityPolicy.Spec.AzureCredentials.APIKeyRef; apiKeyRef != nil {
+			secretName := string(apiKeyRef.Name)
+			apiKey, err := c.getSecretData(ctx, string(ptr.Deref(apiKeyRef.Namespace, gwapiv1.Namespace(namespace))), secretName, apiKeyInSecret)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get secret %s: %w", secretName, err)
+			}
+			return &filterapi.BackendAuth{AzureAuth: &filterapi.AzureAuth{APIKey: apiKey}}, nil
+		}
 		secretName := rotators.GetBSPSecretName(backendSecurityPolicy.Name)
 		azureAccessToken, err := c.getSecretData(ctx, namespace, secretName, rotators.AzureAccessTokenKey)
 		if err != nil {
@@ -390,8 +1034,31 @@ func (c *GatewayController) bspToFilterAPIBackendAuth(ctx context.Context, names
 	}
 }
 
+// apiKeyToFilterAPI converts the API key policy to filterapi.APIKeyAuth with the key. The header and the prefix are
+// left empty to use the "Authorization" header with the "Bearer " prefix when none of the placement is specified.
+func apiKeyToFilterAPI(k *aigv1a1.BackendSecurityPolicyAPIKey, key string) *filterapi.APIKeyAuth {
+	ret := &filterapi.APIKeyAuth{Key: key}
+	switch {
+	case k.QueryParam != nil:
+		ret.QueryParam = *k.QueryParam
+	case k.Header != nil || k.Prefix != nil:
+		ret.Header = ptr.Deref(k.Header, "Authorization")
+		var defaultPrefix string
+		if strings.EqualFold(ret.Header, "Authorization") {
+			defaultPrefix = "Bearer "
+		}
+		ret.Prefix = ptr.Deref(k.Prefix, defaultPrefix)
+	}
+	return ret
+}
+
 func (c *GatewayController) getSecretData(ctx context.Context, namespace, name, dataKey string) (string, error) {
-	secret, err := c.kube.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	return getSecretData(ctx, c.kube, namespace, name, dataKey)
+}
+
+// getSecretData returns the value of the data key of the secret.
+func getSecretData(ctx context.Context, kube kubernetes.Interface, namespace, name, dataKey string) (string, error) {
+	secret, err := kube.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
 	if err != nil {
 		return "", fmt.Errorf("failed to get secret %s: %w", name, err)
 	}
@@ -32,23 +32,41 @@ type gatewayMutator struct {
 	extProcImage           string
 	extProcImagePullPolicy corev1.PullPolicy
 	extProcLogLevel        string
-	envoyGatewayNamespace  string
-	udsPath                string
+	// extProcTokenQuotaStoreURL is passed to the external processor when not empty.
+	extProcTokenQuotaStoreURL string
+	// extProcUsageLedgerSink is passed to the external processor when not empty.
+	extProcUsageLedgerSink string
+	// extProcAuditLogSink is passed to the external processor when not empty.
+	extProcAuditLogSink string
+	// extProcResponseCacheMaxSizeMB is passed to the external processor when positive.
+	extProcResponseCacheMaxSizeMB int
+	// extProcMountBackendCredentials is true if the backend credentials Secret is mounted into the external processor.
+	extProcMountBackendCredentials bool
+	envoyGatewayNamespace          string
+	udsPath                        string
 }
 
 func newGatewayMutator(c client.Client, kube kubernetes.Interface, logger logr.Logger,
-	extProcImage string, extProcImagePullPolicy corev1.PullPolicy, extProcLogLevel string, envoyGatewayNamespace string,
+	extProcImage string, extProcImagePullPolicy corev1.PullPolicy, extProcLogLevel string, extProcTokenQuotaStoreURL string,
+	extProcUsageLedgerSink, extProcAuditLogSink string, extProcResponseCacheMaxSizeMB int,
+	extProcMountBackendCredentials bool,
+	envoyGatewayNamespace string,
 	udsPath string,
 ) *gatewayMutator {
 	return &gatewayMutator{
 		c: c, codec: serializer.NewCodecFactory(Scheme),
-		kube:                   kube,
-		extProcImage:           extProcImage,
-		extProcImagePullPolicy: extProcImagePullPolicy,
-		extProcLogLevel:        extProcLogLevel,
-		logger:                 logger,
-		envoyGatewayNamespace:  envoyGatewayNamespace,
-		udsPath:                udsPath,
+		kube:                           kube,
+		extProcImage:                   extProcImage,
+		extProcImagePullPolicy:         extProcImagePullPolicy,
+		extProcLogLevel:                extProcLogLevel,
+		extProcTokenQuotaStoreURL:      extProcTokenQuotaStoreURL,
+		extProcUsageLedgerSink:         extProcUsageLedgerSink,
+		extProcAuditLogSink:            extProcAuditLogSink,
+		extProcResponseCacheMaxSizeMB:  extProcResponseCacheMaxSizeMB,
+		extProcMountBackendCredentials: extProcMountBackendCredentials,
+		logger:                         logger,
+		envoyGatewayNamespace:          envoyGatewayNamespace,
+		udsPath:                        udsPath,
 	}
 }
 
@@ -126,6 +144,25 @@ func (g *gatewayMutator) mutatePod(ctx context.Context, pod *corev1.Pod, gateway
 		filterConfigFullPath  = filterConfigMountPath + "/" + FilterConfigKeyInSecret
 	)
 	udsMountPath := filepath.Dir(g.udsPath)
+	args := []string{
+		"-configPath", filterConfigFullPath,
+		"-logLevel", g.extProcLogLevel,
+		"-extProcAddr", "unix://" + g.udsPath,
+		"-metricsPort", fmt.Sprintf("%d", extProcMetricsPort),
+		"-healthPort", fmt.Sprintf("%d", extProcHealthPort),
+	}
+	if g.extProcTokenQuotaStoreURL != "" {
+		args = append(args, "-tokenQuotaStoreURL", g.extProcTokenQuotaStoreURL)
+	}
+	if g.extProcUsageLedgerSink != "" {
+		args = append(args, "-usageLedgerSink", g.extProcUsageLedgerSink)
+	}
+	if g.extProcAuditLogSink != "" {
+		args = append(args, "-auditLogSink", g.extProcAuditLogSink)
+	}
+	if g.extProcResponseCacheMaxSizeMB > 0 {
+		args = append(args, "-responseCacheMaxSizeMB", fmt.Sprintf("%d", g.extProcResponseCacheMaxSizeMB))
+	}
 	podspec.Containers = append(podspec.Containers, corev1.Container{
 		Name:            extProcContainerName,
 		Image:           g.extProcImage,
@@ -133,13 +170,7 @@ func (g *gatewayMutator) mutatePod(ctx context.Context, pod *corev1.Pod, gateway
 		Ports: []corev1.ContainerPort{
 			{Name: "aigw-metrics", ContainerPort: extProcMetricsPort},
 		},
-		Args: []string{
-			"-configPath", filterConfigFullPath,
-			"-logLevel", g.extProcLogLevel,
-			"-extProcAddr", "unix://" + g.udsPath,
-			"-metricsPort", fmt.Sprintf("%d", extProcMetricsPort),
-			"-healthPort", fmt.Sprintf("%d", extProcHealthPort),
-		},
+		Args: args,
 		VolumeMounts: []corev1.VolumeMount{
 			{
 				Name:      extProcUDSVolumeName,
@@ -184,6 +215,24 @@ func (g *gatewayMutator) mutatePod(ctx context.Context, pod *corev1.Pod, gateway
 		Resources: resources,
 	})
 
+	if g.extProcMountBackendCredentials {
+		backendCredentialsSecretName := BackendCredentialsSecretPerGatewayName(gatewayName, gatewayNamespace)
+		backendCredentialsVolumeName := mutationNamePrefix + backendCredentialsSecretName
+		podspec.Volumes = append(podspec.Volumes, corev1.Volume{
+			Name: backendCredentialsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				// The Secret does not exist until the filter config is reconciled with the credentials.
+				Secret: &corev1.SecretVolumeSource{SecretName: backendCredentialsSecretName, Optional: ptr.To(true)},
+			},
+		})
+		extProc := &podspec.Containers[len(podspec.Containers)-1]
+		extProc.VolumeMounts = append(extProc.VolumeMounts, corev1.VolumeMount{
+			Name:      backendCredentialsVolumeName,
+			MountPath: backendCredentialsMountPath,
+			ReadOnly:  true,
+		})
+	}
+
 	// Lastly, we need to mount the Envoy container with the extproc socket.
 	for i := range podspec.Containers {
 		c := &podspec.Containers[i]
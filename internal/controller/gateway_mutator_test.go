@@ -27,7 +27,7 @@ func TestGatewayMutator_Default(t *testing.T) {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zap.Options{Development: true, Level: zapcore.DebugLevel})))
 	g := newGatewayMutator(
 		fakeClient, fakeKube, ctrl.Log, "docker.io/envoyproxy/ai-gateway-extproc:latest", corev1.PullIfNotPresent,
-		"info", "envoy-gateway-system", "/tmp/extproc.sock",
+		"info", "", "", "", 0, false, "envoy-gateway-system", "/tmp/extproc.sock",
 	)
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "test-namespace"},
@@ -50,7 +50,7 @@ func TestGatewayMutator_mutatePod(t *testing.T) {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zap.Options{Development: true, Level: zapcore.DebugLevel})))
 	g := newGatewayMutator(
 		fakeClient, fakeKube, ctrl.Log, "docker.io/envoyproxy/ai-gateway-extproc:latest", corev1.PullIfNotPresent,
-		"info", "envoy-gateway-system", "/tmp/extproc.sock",
+		"info", "", "file:///var/log/usage.jsonl", "otlp", 256, true, "envoy-gateway-system", "/tmp/extproc.sock",
 	)
 
 	const gwName, gwNamespace = "test-gateway", "test-namespace"
@@ -81,4 +81,17 @@ func TestGatewayMutator_mutatePod(t *testing.T) {
 	}
 	err = g.mutatePod(t.Context(), pod, gwName, gwNamespace)
 	require.NoError(t, err)
+	require.Len(t, pod.Spec.Containers, 2)
+	args := pod.Spec.Containers[1].Args
+	require.Subset(t, args, []string{"-usageLedgerSink", "file:///var/log/usage.jsonl"})
+	require.Subset(t, args, []string{"-auditLogSink", "otlp"})
+	require.Subset(t, args, []string{"-responseCacheMaxSizeMB", "256"})
+	require.NotContains(t, args, "-tokenQuotaStoreURL")
+
+	credentialsVolume := pod.Spec.Volumes[len(pod.Spec.Volumes)-1]
+	require.Equal(t, "test-gateway-test-namespace-backend-credentials", credentialsVolume.Secret.SecretName)
+	require.True(t, *credentialsVolume.Secret.Optional)
+	require.Contains(t, pod.Spec.Containers[1].VolumeMounts, corev1.VolumeMount{
+		Name: credentialsVolume.Name, MountPath: "/etc/backend-credentials", ReadOnly: true,
+	})
 }
@@ -10,12 +10,14 @@ import (
 	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
 	egv1a1 "github.com/envoyproxy/gateway/api/v1alpha1"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zapcore"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	fake2 "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/utils/ptr"
@@ -24,6 +26,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	gwapiv1 "sigs.k8s.io/gateway-api/apis/v1"
 	gwapiv1a2 "sigs.k8s.io/gateway-api/apis/v1alpha2"
+	gwapiv1a3 "sigs.k8s.io/gateway-api/apis/v1alpha3"
 	"sigs.k8s.io/yaml"
 
 	aigv1a1 "github.com/envoyproxy/ai-gateway/api/v1alpha1"
@@ -35,7 +38,7 @@ func TestGatewayController_Reconcile(t *testing.T) {
 	fakeClient := requireNewFakeClientWithIndexes(t)
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zap.Options{Development: true, Level: zapcore.DebugLevel})))
 	c := NewGatewayController(fakeClient, fake2.NewClientset(), ctrl.Log,
-		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest")
+		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest", false)
 
 	const namespace = "ns"
 	t.Run("not found must be non error", func(t *testing.T) {
@@ -132,6 +135,68 @@ func TestGatewayController_Reconcile(t *testing.T) {
 	require.Len(t, extPolicy.Spec.ExtProc, 1)
 	require.Len(t, extPolicy.Spec.ExtProc[0].BackendRefs, 1)
 	require.Equal(t, sideCarExtProcBackendName, string(extPolicy.Spec.ExtProc[0].BackendRefs[0].Name))
+	require.Equal(t, ptr.To[gwapiv1.Duration]("200ms"), extPolicy.Spec.ExtProc[0].MessageTimeout)
+
+	// The message timeout of the existing policy is updated.
+	require.NoError(t, c.ensureExtensionPolicy(t.Context(), &gwapiv1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: okGwName, Namespace: namespace},
+	}, 5200*time.Millisecond))
+	err = fakeClient.Get(t.Context(), client.ObjectKey{Name: fmt.Sprintf("ai-eg-eep-%s", okGwName), Namespace: namespace}, &extPolicy)
+	require.NoError(t, err)
+	require.Equal(t, ptr.To[gwapiv1.Duration]("5.2s"), extPolicy.Spec.ExtProc[0].MessageTimeout)
+}
+
+func Test_extProcMessageTimeout(t *testing.T) {
+	route := func(rules ...aigv1a1.AIGatewayRouteRule) aigv1a1.AIGatewayRoute {
+		return aigv1a1.AIGatewayRoute{Spec: aigv1a1.AIGatewayRouteSpec{Rules: rules}}
+	}
+	for _, tc := range []struct {
+		name   string
+		routes []aigv1a1.AIGatewayRoute
+		exp    time.Duration
+	}{
+		{name: "no calls", routes: []aigv1a1.AIGatewayRoute{route(aigv1a1.AIGatewayRouteRule{})}, exp: 200 * time.Millisecond},
+		{
+			name: "concurrent guardrails",
+			routes: []aigv1a1.AIGatewayRoute{route(aigv1a1.AIGatewayRouteRule{Guardrails: []aigv1a1.AIGatewayRouteRuleGuardrail{
+				{Name: "a"},
+				{Name: "b", Timeout: ptr.To[gwapiv1.Duration]("3s")},
+				{Name: "c", Timeout: ptr.To[gwapiv1.Duration]("8s"), Phases: []aigv1a1.AIGatewayRouteRuleGuardrailPhase{
+					aigv1a1.AIGatewayRouteRuleGuardrailPhaseOutput,
+				}},
+			}})},
+			exp: 8200 * time.Millisecond,
+		},
+		{
+			name: "semantic cache after input guardrails",
+			routes: []aigv1a1.AIGatewayRoute{
+				route(aigv1a1.AIGatewayRouteRule{
+					Guardrails: []aigv1a1.AIGatewayRouteRuleGuardrail{{Name: "a", Timeout: ptr.To[gwapiv1.Duration]("1s")}},
+					ResponseCache: &aigv1a1.AIGatewayRouteRuleResponseCache{
+						Semantic: &aigv1a1.AIGatewayRouteRuleSemanticCache{BackendName: "embeddings", Model: "m"},
+					},
+				}),
+				route(aigv1a1.AIGatewayRouteRule{}),
+			},
+			exp: 3200 * time.Millisecond,
+		},
+		{
+			name: "embeddings batches",
+			routes: []aigv1a1.AIGatewayRoute{route(
+				aigv1a1.AIGatewayRouteRule{Embeddings: &aigv1a1.AIGatewayRouteRuleEmbeddings{MaxInputsPerRequest: ptr.To[int32](1)}},
+				aigv1a1.AIGatewayRouteRule{Embeddings: &aigv1a1.AIGatewayRouteRuleEmbeddings{
+					MaxInputsPerRequest: ptr.To[int32](1), BatchTimeout: ptr.To[gwapiv1.Duration]("1m"),
+				}},
+				// The input cache alone does not send batches.
+				aigv1a1.AIGatewayRouteRule{Embeddings: &aigv1a1.AIGatewayRouteRuleEmbeddings{BatchTimeout: ptr.To[gwapiv1.Duration]("1h")}},
+			)},
+			exp: time.Minute + 200*time.Millisecond,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.exp, extProcMessageTimeout(tc.routes))
+		})
+	}
 }
 
 func TestGatewayController_reconcileFilterConfigSecret(t *testing.T) {
@@ -140,7 +205,7 @@ func TestGatewayController_reconcileFilterConfigSecret(t *testing.T) {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zap.Options{Development: true, Level: zapcore.DebugLevel})))
 	c := NewGatewayController(fakeClient, kube, ctrl.Log,
 		"envoy-gateway-system", "/foo/bar/uds.sock",
-		"docker.io/envoyproxy/ai-gateway-extproc:latest")
+		"docker.io/envoyproxy/ai-gateway-extproc:latest", false)
 
 	const namespace = "ns"
 	routes := []aigv1a1.AIGatewayRoute{
@@ -148,10 +213,31 @@ func TestGatewayController_reconcileFilterConfigSecret(t *testing.T) {
 			ObjectMeta: metav1.ObjectMeta{Name: "route1", Namespace: namespace},
 			Spec: aigv1a1.AIGatewayRouteSpec{
 				Rules: []aigv1a1.AIGatewayRouteRule{
-					{BackendRefs: []aigv1a1.AIGatewayRouteRuleBackendRef{{Name: "apple"}}},
+					{
+						BackendRefs:              []aigv1a1.AIGatewayRouteRuleBackendRef{{Name: "apple"}},
+						LongContextFallbackModel: ptr.To("long-context-model"),
+						SessionAffinity:          &aigv1a1.AIGatewayRouteRuleSessionAffinity{Header: ptr.To("x-session-id"), UserField: true},
+						Hedging:                  &aigv1a1.AIGatewayRouteRuleHedging{Delay: "1s"},
+						Embeddings: &aigv1a1.AIGatewayRouteRuleEmbeddings{
+							MaxInputsPerRequest: ptr.To[int32](1), BatchURL: ptr.To("http://gateway/v1/embeddings"),
+							InputCache: &aigv1a1.AIGatewayRouteRuleEmbeddingsInputCache{},
+						},
+						ResponseCache: &aigv1a1.AIGatewayRouteRuleResponseCache{
+							ConsumerHeader: ptr.To("x-team"), AllTemperatures: true,
+							Semantic: &aigv1a1.AIGatewayRouteRuleSemanticCache{
+								BackendName: "apple", Model: "text-embedding-3-small", Threshold: ptr.To("0.9"),
+								AllowUnauthenticated: true,
+							},
+						},
+					},
+				},
+				APISchema: aigv1a1.VersionedAPISchema{Name: aigv1a1.APISchemaOpenAI, Version: ptr.To("v1")},
+				LLMRequestCosts: []aigv1a1.LLMRequestCost{
+					{MetadataKey: "foo", Type: aigv1a1.LLMRequestCostTypeInputToken},
+					{MetadataKey: "estimated", Type: aigv1a1.LLMRequestCostTypeEstimatedInputToken},
+					{MetadataKey: "cached", Type: aigv1a1.LLMRequestCostTypeCachedInputToken},
+					{MetadataKey: "reasoning", Type: aigv1a1.LLMRequestCostTypeReasoningToken},
 				},
-				APISchema:       aigv1a1.VersionedAPISchema{Name: aigv1a1.APISchemaOpenAI, Version: ptr.To("v1")},
-				LLMRequestCosts: []aigv1a1.LLMRequestCost{{MetadataKey: "foo", Type: aigv1a1.LLMRequestCostTypeInputToken}},
 			},
 		},
 		{
@@ -161,6 +247,10 @@ func TestGatewayController_reconcileFilterConfigSecret(t *testing.T) {
 					{BackendRefs: []aigv1a1.AIGatewayRouteRuleBackendRef{{Name: "orange"}}},
 				},
 				APISchema: aigv1a1.VersionedAPISchema{Name: aigv1a1.APISchemaOpenAI},
+				TokenQuotas: []aigv1a1.AIGatewayRouteTokenQuota{
+					{Name: "team", ConsumerHeader: "x-team", TokensPerMinute: ptr.To[int64](1000)},
+					{Name: "user", ConsumerHeader: "x-user", RequireConsumerHeader: true, Type: ptr.To(aigv1a1.LLMRequestCostTypeOutputToken), TokensPerDay: ptr.To[int64](5000)},
+				},
 				LLMRequestCosts: []aigv1a1.LLMRequestCost{
 					{MetadataKey: "foo", Type: aigv1a1.LLMRequestCostTypeInputToken}, // This should be ignored as it has the duplicate key.
 					{MetadataKey: "bar", Type: aigv1a1.LLMRequestCostTypeCEL, CEL: ptr.To(`backend == 'foo.default' ?  input_tokens + output_tokens : total_tokens`)},
@@ -179,13 +269,26 @@ func TestGatewayController_reconcileFilterConfigSecret(t *testing.T) {
 		{
 			ObjectMeta: metav1.ObjectMeta{Name: "orange", Namespace: namespace},
 			Spec: aigv1a1.AIServiceBackendSpec{
-				BackendRef: gwapiv1.BackendObjectReference{Name: "some-backend1", Namespace: ptr.To[gwapiv1.Namespace](namespace)},
+				BackendRef:     gwapiv1.BackendObjectReference{Name: "some-backend1", Namespace: ptr.To[gwapiv1.Namespace](namespace)},
+				TokenLimits:    &aigv1a1.AIServiceBackendTokenLimits{ContextWindow: 128000, MaxOutputTokens: ptr.To[int32](4096)},
+				CircuitBreaker: &aigv1a1.AIServiceBackendCircuitBreaker{Cooldown: ptr.To[gwapiv1.Duration]("1m")},
+				Pricing: []aigv1a1.AIServiceBackendModelPricing{
+					{Model: "gpt-4o", InputPerMillionTokens: "2.50", OutputPerMillionTokens: "10", CachedInputPerMillionTokens: ptr.To("1.25")},
+					{Model: "*", InputPerMillionTokens: "1", OutputPerMillionTokens: "4"},
+				},
 			},
 		},
 	} {
 		err := fakeClient.Create(t.Context(), aigwRoute)
 		require.NoError(t, err)
 	}
+	// The semantic cache calls its embeddings backend directly, which needs the endpoint of the Backend.
+	require.NoError(t, fakeClient.Create(t.Context(), &egv1a1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Name: "some-backend1", Namespace: namespace},
+		Spec: egv1a1.BackendSpec{Endpoints: []egv1a1.BackendEndpoint{
+			{FQDN: &egv1a1.FQDNEndpoint{Hostname: "api.openai.com", Port: 443}},
+		}},
+	}))
 
 	for range 2 { // Reconcile twice to make sure the secret update path is working.
 		err := c.reconcileFilterConfigSecret(t.Context(), &gwapiv1.Gateway{
@@ -200,23 +303,161 @@ func TestGatewayController_reconcileFilterConfigSecret(t *testing.T) {
 		require.True(t, ok)
 		var fc filterapi.Config
 		require.NoError(t, yaml.Unmarshal([]byte(configStr), &fc))
-		require.Len(t, fc.LLMRequestCosts, 2)
+		require.Len(t, fc.LLMRequestCosts, 5)
 		require.Equal(t, filterapi.LLMRequestCostTypeInputToken, fc.LLMRequestCosts[0].Type)
-		require.Equal(t, filterapi.LLMRequestCostTypeCEL, fc.LLMRequestCosts[1].Type)
-		require.Equal(t, `backend == 'foo.default' ?  input_tokens + output_tokens : total_tokens`, fc.LLMRequestCosts[1].CEL)
+		require.Equal(t, filterapi.LLMRequestCostTypeEstimatedInputToken, fc.LLMRequestCosts[1].Type)
+		require.Equal(t, filterapi.LLMRequestCostTypeCachedInputToken, fc.LLMRequestCosts[2].Type)
+		require.Equal(t, filterapi.LLMRequestCostTypeReasoningToken, fc.LLMRequestCosts[3].Type)
+		require.Equal(t, filterapi.LLMRequestCostTypeCEL, fc.LLMRequestCosts[4].Type)
+		require.Equal(t, `backend == 'foo.default' ?  input_tokens + output_tokens : total_tokens`, fc.LLMRequestCosts[4].CEL)
 		require.Len(t, fc.Rules, 2)
 		require.Equal(t, "route1-rule-0", string(fc.Rules[0].Name))
 		require.Equal(t, "route2-rule-0", string(fc.Rules[1].Name))
+		require.Equal(t, "long-context-model", fc.Rules[0].LongContextFallbackModel)
+		require.Empty(t, fc.Rules[1].LongContextFallbackModel)
+		require.Equal(t, aigv1a1.SessionKeyHeaderKey, fc.SessionKeyHeaderKey)
+		require.Equal(t, aigv1a1.ConsumerHeaderKey, fc.ConsumerHeaderKey)
+		require.Empty(t, fc.ConsumerKeys)
+		require.Equal(t, &filterapi.SessionAffinity{Header: "x-session-id", UserField: true}, fc.Rules[0].SessionAffinity)
+		require.Nil(t, fc.Rules[1].SessionAffinity)
+		require.Equal(t, &filterapi.Hedging{Delay: time.Second, MaxHedgedRequests: 1}, fc.Rules[0].Hedging)
+		require.Nil(t, fc.Rules[1].Hedging)
+		require.Equal(t, &filterapi.ResponseCache{
+			TTL: 5 * time.Minute, ConsumerHeader: "x-team", AllTemperatures: true,
+			Semantic: &filterapi.SemanticCache{
+				Backend: filterapi.Backend{Name: "apple.ns"}, URL: "https://api.openai.com:443", Path: "/v1/embeddings",
+				Model: "text-embedding-3-small", Threshold: 0.9, AllowUnauthenticated: true, Timeout: 2 * time.Second,
+			},
+		}, fc.Rules[0].ResponseCache)
+		require.Nil(t, fc.Rules[1].ResponseCache)
+		require.Equal(t, &filterapi.Embeddings{
+			MaxInputsPerRequest: 1, BatchURL: "http://gateway/v1/embeddings", MaxConcurrency: 4, BatchTimeout: 30 * time.Second,
+			InputCacheTTL: 24 * time.Hour,
+		}, fc.Rules[0].Embeddings)
+		require.Nil(t, fc.Rules[1].Embeddings)
+		require.Empty(t, fc.Rules[0].TokenQuotas)
+		require.Equal(t, []filterapi.TokenQuota{
+			{Name: "ns/route2/team", ConsumerHeader: "x-team", Type: filterapi.LLMRequestCostTypeTotalToken, TokensPerMinute: 1000},
+			{Name: "ns/route2/user", ConsumerHeader: "x-user", RequireConsumerHeader: true, Type: filterapi.LLMRequestCostTypeOutputToken, TokensPerDay: 5000},
+		}, fc.Rules[1].TokenQuotas)
+		require.Zero(t, fc.Rules[0].Backends[0].ContextWindow)
+		require.Equal(t, 128000, fc.Rules[1].Backends[0].ContextWindow)
+		require.Equal(t, 4096, fc.Rules[1].Backends[0].MaxOutputTokens)
+		require.Nil(t, fc.Rules[0].Backends[0].CircuitBreaker)
+		require.Equal(t, &filterapi.CircuitBreaker{ConsecutiveFailures: 5, Cooldown: time.Minute}, fc.Rules[1].Backends[0].CircuitBreaker)
+		require.Empty(t, fc.Rules[0].Backends[0].Pricing)
+		require.Equal(t, []filterapi.ModelPricing{
+			{Model: "gpt-4o", InputPerMillionTokens: 2.5, OutputPerMillionTokens: 10, CachedInputPerMillionTokens: 1.25},
+			{Model: "*", InputPerMillionTokens: 1, OutputPerMillionTokens: 4, CachedInputPerMillionTokens: 1},
+		}, fc.Rules[1].Backends[0].Pricing)
 	}
 }
 
+func TestGatewayController_reconcileFilterConfigSecret_mountBackendCredentials(t *testing.T) {
+	fakeClient := requireNewFakeClientWithIndexes(t)
+	kube := fake2.NewClientset()
+	c := NewGatewayController(fakeClient, kube, ctrl.Log,
+		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest", true)
+
+	const namespace = "ns"
+	require.NoError(t, fakeClient.Create(t.Context(), &aigv1a1.BackendSecurityPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "bsp-apikey", Namespace: namespace},
+		Spec: aigv1a1.BackendSecurityPolicySpec{
+			Type:   aigv1a1.BackendSecurityPolicyTypeAPIKey,
+			APIKey: &aigv1a1.BackendSecurityPolicyAPIKey{SecretRef: &gwapiv1.SecretObjectReference{Name: "api-key-secret"}},
+		},
+	}))
+	_, err := kube.CoreV1().Secrets(namespace).Create(t.Context(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-key-secret", Namespace: namespace},
+		StringData: map[string]string{apiKeyInSecret: "thisisapikey"},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, fakeClient.Create(t.Context(), &aigv1a1.AIServiceBackend{
+		ObjectMeta: metav1.ObjectMeta{Name: "apple", Namespace: namespace},
+		Spec: aigv1a1.AIServiceBackendSpec{
+			BackendRef:               gwapiv1.BackendObjectReference{Name: "some-backend1", Namespace: ptr.To[gwapiv1.Namespace](namespace)},
+			BackendSecurityPolicyRef: &gwapiv1.LocalObjectReference{Name: "bsp-apikey"},
+		},
+	}))
+	routes := []aigv1a1.AIGatewayRoute{{
+		ObjectMeta: metav1.ObjectMeta{Name: "route1", Namespace: namespace},
+		Spec: aigv1a1.AIGatewayRouteSpec{
+			Rules:     []aigv1a1.AIGatewayRouteRule{{BackendRefs: []aigv1a1.AIGatewayRouteRuleBackendRef{{Name: "apple"}}}},
+			APISchema: aigv1a1.VersionedAPISchema{Name: aigv1a1.APISchemaOpenAI, Version: ptr.To("v1")},
+		},
+	}}
+
+	for range 2 { // Reconcile twice to make sure the secret update path is working.
+		require.NoError(t, c.reconcileFilterConfigSecret(t.Context(), &gwapiv1.Gateway{
+			ObjectMeta: metav1.ObjectMeta{Name: "gw", Namespace: namespace},
+		}, routes, "foouuid"))
+
+		secret, err := kube.CoreV1().Secrets("envoy-gateway-system").
+			Get(t.Context(), FilterConfigSecretPerGatewayName("gw", namespace), metav1.GetOptions{})
+		require.NoError(t, err)
+		require.NotContains(t, secret.StringData[FilterConfigKeyInSecret], "thisisapikey")
+		var fc filterapi.Config
+		require.NoError(t, yaml.Unmarshal([]byte(secret.StringData[FilterConfigKeyInSecret]), &fc))
+		require.Equal(t, &filterapi.BackendAuth{APIKey: &filterapi.APIKeyAuth{KeyFile: "/etc/backend-credentials/apple.ns.api-key"}},
+			fc.Rules[0].Backends[0].Auth)
+
+		credentials, err := kube.CoreV1().Secrets("envoy-gateway-system").
+			Get(t.Context(), BackendCredentialsSecretPerGatewayName("gw", namespace), metav1.GetOptions{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"apple.ns.api-key": "thisisapikey"}, credentials.StringData)
+	}
+}
+
+func Test_mountBackendCredentials(t *testing.T) {
+	shared := &filterapi.BackendAuth{AzureAuth: &filterapi.AzureAuth{AccessToken: "azure-token"}}
+	ec := &filterapi.Config{Rules: []filterapi.RouteRule{
+		{
+			Backends: []filterapi.Backend{
+				{Name: "openai.ns", Auth: &filterapi.BackendAuth{APIKey: &filterapi.APIKeyAuth{Key: "key"}}},
+				{Name: "bedrock.ns", Auth: &filterapi.BackendAuth{AWSAuth: &filterapi.AWSAuth{CredentialFileLiteral: "[default]", Region: "us-east-1"}}},
+				{Name: "no-auth.ns"},
+				{Name: "azure-key.ns", Auth: &filterapi.BackendAuth{AzureAuth: &filterapi.AzureAuth{APIKey: "azure-key"}}},
+			},
+			Guardrails: []filterapi.Guardrail{{Backend: filterapi.Backend{Name: "azure.ns", Auth: shared}}},
+			Shadow: &filterapi.ShadowBackend{Backend: filterapi.Backend{
+				Name: "vertex.ns", Auth: &filterapi.BackendAuth{GCPAuth: &filterapi.GCPAuth{AccessToken: "gcp-token", Region: "r", ProjectName: "p"}},
+			}},
+		},
+		// The same auth is mounted only once.
+		{Backends: []filterapi.Backend{{Name: "azure.ns", Auth: shared}}},
+		{ResponseCache: &filterapi.ResponseCache{Semantic: &filterapi.SemanticCache{Backend: filterapi.Backend{
+			Name: "embeddings.ns", Auth: &filterapi.BackendAuth{APIKey: &filterapi.APIKeyAuth{Key: "embeddings-key"}},
+		}}}},
+	}}
+
+	data := mountBackendCredentials(ec)
+	require.Equal(t, map[string]string{
+		"openai.ns.api-key":           "key",
+		"bedrock.ns.aws-credentials":  "[default]",
+		"azure.ns.azure-access-token": "azure-token",
+		"azure-key.ns.azure-api-key":  "azure-key",
+		"vertex.ns.gcp-access-token":  "gcp-token",
+		"embeddings.ns.api-key":       "embeddings-key",
+	}, data)
+	require.Equal(t, &filterapi.APIKeyAuth{KeyFile: "/etc/backend-credentials/openai.ns.api-key"}, ec.Rules[0].Backends[0].Auth.APIKey)
+	require.Equal(t, &filterapi.AWSAuth{CredentialFile: "/etc/backend-credentials/bedrock.ns.aws-credentials", Region: "us-east-1"},
+		ec.Rules[0].Backends[1].Auth.AWSAuth)
+	require.Nil(t, ec.Rules[0].Backends[2].Auth)
+	require.Equal(t, &filterapi.AzureAuth{APIKeyFile: "/etc/backend-credentials/azure-key.ns.azure-api-key"}, ec.Rules[0].Backends[3].Auth.AzureAuth)
+	require.Equal(t, &filterapi.AzureAuth{AccessTokenFile: "/etc/backend-credentials/azure.ns.azure-access-token"}, shared.AzureAuth)
+	require.Equal(t, &filterapi.GCPAuth{AccessTokenFile: "/etc/backend-credentials/vertex.ns.gcp-access-token", Region: "r", ProjectName: "p"},
+		ec.Rules[0].Shadow.Backend.Auth.GCPAuth)
+	require.Equal(t, &filterapi.APIKeyAuth{KeyFile: "/etc/backend-credentials/embeddings.ns.api-key"},
+		ec.Rules[2].ResponseCache.Semantic.Backend.Auth.APIKey)
+}
+
 func TestGatewayController_bspToFilterAPIBackendAuth(t *testing.T) {
 	fakeClient := requireNewFakeClientWithIndexes(t)
 	kube := fake2.NewClientset()
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zap.Options{Development: true, Level: zapcore.DebugLevel})))
 	c := NewGatewayController(fakeClient, kube, ctrl.Log,
 		"envoy-gateway-system", "/foo/bar/uds.sock",
-		"docker.io/envoyproxy/ai-gateway-extproc:latest")
+		"docker.io/envoyproxy/ai-gateway-extproc:latest", false)
 
 	const namespace = "ns"
 	for _, bsp := range []*aigv1a1.BackendSecurityPolicy{
@@ -256,6 +497,15 @@ func TestGatewayController_bspToFilterAPIBackendAuth(t *testing.T) {
 				AzureCredentials: &aigv1a1.BackendSecurityPolicyAzureCredentials{},
 			},
 		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "azure-api-key", Namespace: namespace},
+			Spec: aigv1a1.BackendSecurityPolicySpec{
+				Type: aigv1a1.BackendSecurityPolicyTypeAzureCredentials,
+				AzureCredentials: &aigv1a1.BackendSecurityPolicyAzureCredentials{
+					APIKeyRef: &gwapiv1.SecretObjectReference{Name: "azure-api-key-secret"},
+				},
+			},
+		},
 	} {
 		require.NoError(t, fakeClient.Create(t.Context(), bsp))
 	}
@@ -276,6 +526,10 @@ func TestGatewayController_bspToFilterAPIBackendAuth(t *testing.T) {
 			ObjectMeta: metav1.ObjectMeta{Name: rotators.GetBSPSecretName("azure-oidc"), Namespace: namespace},
 			StringData: map[string]string{rotators.AzureAccessTokenKey: "thisisazurecredentials"},
 		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "azure-api-key-secret", Namespace: namespace},
+			StringData: map[string]string{apiKeyInSecret: "thisisazureapikey"},
+		},
 	} {
 		_, err := kube.CoreV1().Secrets(namespace).Create(t.Context(), s, metav1.CreateOptions{})
 		require.NoError(t, err)
@@ -309,6 +563,10 @@ func TestGatewayController_bspToFilterAPIBackendAuth(t *testing.T) {
 				AzureAuth: &filterapi.AzureAuth{AccessToken: "thisisazurecredentials"},
 			},
 		},
+		{
+			bspName: "azure-api-key",
+			exp:     &filterapi.BackendAuth{AzureAuth: &filterapi.AzureAuth{APIKey: "thisisazureapikey"}},
+		},
 	} {
 		t.Run(tc.bspName, func(t *testing.T) {
 			auth, err := c.bspToFilterAPIBackendAuth(t.Context(), namespace, tc.bspName)
@@ -321,7 +579,7 @@ func TestGatewayController_bspToFilterAPIBackendAuth(t *testing.T) {
 func TestGatewayController_bspToFilterAPIBackendAuth_ErrorCases(t *testing.T) {
 	fakeClient := requireNewFakeClientWithIndexes(t)
 	c := NewGatewayController(fakeClient, fake2.NewClientset(), ctrl.Log,
-		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest")
+		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest", false)
 
 	ctx := context.Background()
 	namespace := "test-namespace"
@@ -410,7 +668,7 @@ func TestGatewayController_bspToFilterAPIBackendAuth_ErrorCases(t *testing.T) {
 func TestGatewayController_GetSecretData_ErrorCases(t *testing.T) {
 	fakeClient := requireNewFakeClientWithIndexes(t)
 	c := NewGatewayController(fakeClient, fake2.NewClientset(), ctrl.Log,
-		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest")
+		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest", false)
 
 	ctx := context.Background()
 	namespace := "test-namespace"
@@ -435,7 +693,7 @@ func TestGatewayController_annotateGatewayPods(t *testing.T) {
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zap.Options{Development: true, Level: zapcore.DebugLevel})))
 	const v2Container = "ai-gateway-extproc:v2"
 	c := NewGatewayController(fakeClient, kube, ctrl.Log,
-		egNamespace, "/foo/bar/uds.sock", v2Container)
+		egNamespace, "/foo/bar/uds.sock", v2Container, false)
 	t.Run("pod with extproc", func(t *testing.T) {
 		_, err := kube.CoreV1().Pods(egNamespace).Create(t.Context(), &corev1.Pod{
 			ObjectMeta: metav1.ObjectMeta{
@@ -572,3 +830,405 @@ func Test_schemaToFilterAPI(t *testing.T) {
 		})
 	}
 }
+
+func TestGatewayController_shadowToFilterAPI(t *testing.T) {
+	fakeClient := requireNewFakeClientWithIndexes(t)
+	c := NewGatewayController(fakeClient, fake2.NewClientset(), ctrl.Log,
+		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest", false)
+
+	const namespace = "ns"
+	for _, obj := range []client.Object{
+		&aigv1a1.AIServiceBackend{
+			ObjectMeta: metav1.ObjectMeta{Name: "shadow", Namespace: namespace},
+			Spec: aigv1a1.AIServiceBackendSpec{
+				APISchema:  aigv1a1.VersionedAPISchema{Name: aigv1a1.APISchemaOpenAI},
+				BackendRef: gwapiv1.BackendObjectReference{Name: "shadow-backend"},
+			},
+		},
+		&egv1a1.Backend{
+			ObjectMeta: metav1.ObjectMeta{Name: "shadow-backend", Namespace: namespace},
+			Spec: egv1a1.BackendSpec{Endpoints: []egv1a1.BackendEndpoint{
+				{FQDN: &egv1a1.FQDNEndpoint{Hostname: "api.openai.com", Port: 443}},
+			}},
+		},
+		&aigv1a1.AIServiceBackend{
+			ObjectMeta: metav1.ObjectMeta{Name: "no-endpoint", Namespace: namespace},
+			Spec: aigv1a1.AIServiceBackendSpec{
+				APISchema:  aigv1a1.VersionedAPISchema{Name: aigv1a1.APISchemaOpenAI},
+				BackendRef: gwapiv1.BackendObjectReference{Name: "unix-backend"},
+			},
+		},
+		&egv1a1.Backend{
+			ObjectMeta: metav1.ObjectMeta{Name: "unix-backend", Namespace: namespace},
+			Spec: egv1a1.BackendSpec{Endpoints: []egv1a1.BackendEndpoint{
+				{Unix: &egv1a1.UnixSocket{Path: "/tmp/foo.sock"}},
+			}},
+		},
+		&gwapiv1a3.BackendTLSPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "shadow-tls", Namespace: namespace},
+			Spec: gwapiv1a3.BackendTLSPolicySpec{
+				TargetRefs: []gwapiv1a2.LocalPolicyTargetReferenceWithSectionName{{
+					LocalPolicyTargetReference: gwapiv1a2.LocalPolicyTargetReference{
+						Group: "gateway.envoyproxy.io", Kind: "Backend", Name: "shadow-backend",
+					},
+				}},
+				Validation: gwapiv1a3.BackendTLSPolicyValidation{
+					Hostname:          "api.openai.com",
+					CACertificateRefs: []gwapiv1.LocalObjectReference{{Kind: "ConfigMap", Name: "shadow-ca"}},
+				},
+			},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "shadow-ca", Namespace: namespace},
+			Data:       map[string]string{"ca.crt": "-----BEGIN CERTIFICATE-----\nfoo\n-----END CERTIFICATE-----\n"},
+		},
+		&aigv1a1.AIServiceBackend{
+			ObjectMeta: metav1.ObjectMeta{Name: "missing-ca", Namespace: namespace},
+			Spec: aigv1a1.AIServiceBackendSpec{
+				APISchema:  aigv1a1.VersionedAPISchema{Name: aigv1a1.APISchemaOpenAI},
+				BackendRef: gwapiv1.BackendObjectReference{Name: "missing-ca-backend"},
+			},
+		},
+		&egv1a1.Backend{
+			ObjectMeta: metav1.ObjectMeta{Name: "missing-ca-backend", Namespace: namespace},
+			Spec: egv1a1.BackendSpec{Endpoints: []egv1a1.BackendEndpoint{
+				{FQDN: &egv1a1.FQDNEndpoint{Hostname: "example.com", Port: 443}},
+			}},
+		},
+		&gwapiv1a3.BackendTLSPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "missing-ca-tls", Namespace: namespace},
+			Spec: gwapiv1a3.BackendTLSPolicySpec{
+				TargetRefs: []gwapiv1a2.LocalPolicyTargetReferenceWithSectionName{{
+					LocalPolicyTargetReference: gwapiv1a2.LocalPolicyTargetReference{
+						Group: "gateway.envoyproxy.io", Kind: "Backend", Name: "missing-ca-backend",
+					},
+				}},
+				Validation: gwapiv1a3.BackendTLSPolicyValidation{
+					Hostname:          "example.com",
+					CACertificateRefs: []gwapiv1.LocalObjectReference{{Kind: "ConfigMap", Name: "nonexistent"}},
+				},
+			},
+		},
+	} {
+		require.NoError(t, fakeClient.Create(t.Context(), obj))
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		shadow, err := c.shadowToFilterAPI(t.Context(), namespace, &aigv1a1.AIGatewayRouteRuleShadow{
+			Name:              "shadow",
+			ModelNameOverride: "gpt-4o",
+			Timeout:           ptr.To[gwapiv1.Duration]("30s"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, &filterapi.ShadowBackend{
+			Backend: filterapi.Backend{
+				Name:              "shadow.ns",
+				ModelNameOverride: "gpt-4o",
+				Schema:            filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI, Version: "v1"},
+			},
+			URL:     "https://api.openai.com:443",
+			Percent: 100,
+			Timeout: 30 * time.Second,
+			TLS: &filterapi.BackendTLS{
+				Hostname:       "api.openai.com",
+				CACertificates: "-----BEGIN CERTIFICATE-----\nfoo\n-----END CERTIFICATE-----\n",
+			},
+		}, shadow)
+	})
+	t.Run("missing ca", func(t *testing.T) {
+		_, err := c.shadowToFilterAPI(t.Context(), namespace, &aigv1a1.AIGatewayRouteRuleShadow{Name: "missing-ca"})
+		require.ErrorContains(t, err, "failed to get ConfigMap nonexistent of BackendTLSPolicy missing-ca-tls")
+	})
+	t.Run("no endpoint", func(t *testing.T) {
+		_, err := c.shadowToFilterAPI(t.Context(), namespace, &aigv1a1.AIGatewayRouteRuleShadow{Name: "no-endpoint", Percent: ptr.To[int32](10)})
+		require.ErrorContains(t, err, "backend ns/unix-backend has no FQDN or IP endpoint")
+	})
+	t.Run("not found", func(t *testing.T) {
+		_, err := c.shadowToFilterAPI(t.Context(), namespace, &aigv1a1.AIGatewayRouteRuleShadow{Name: "nonexistent"})
+		require.ErrorContains(t, err, "failed to get AIServiceBackend nonexistent.ns")
+	})
+}
+
+func TestGatewayController_guardrailToFilterAPI(t *testing.T) {
+	fakeClient := requireNewFakeClientWithIndexes(t)
+	c := NewGatewayController(fakeClient, fake2.NewClientset(), ctrl.Log,
+		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest", false)
+
+	const namespace = "ns"
+	for _, obj := range []client.Object{
+		&aigv1a1.AIServiceBackend{
+			ObjectMeta: metav1.ObjectMeta{Name: "moderation", Namespace: namespace},
+			Spec: aigv1a1.AIServiceBackendSpec{
+				APISchema:  aigv1a1.VersionedAPISchema{Name: aigv1a1.APISchemaOpenAI},
+				BackendRef: gwapiv1.BackendObjectReference{Name: "moderation-backend"},
+			},
+		},
+		&egv1a1.Backend{
+			ObjectMeta: metav1.ObjectMeta{Name: "moderation-backend", Namespace: namespace},
+			Spec: egv1a1.BackendSpec{Endpoints: []egv1a1.BackendEndpoint{
+				{IP: &egv1a1.IPEndpoint{Address: "10.0.0.1", Port: 8080}},
+			}},
+		},
+		&aigv1a1.AIServiceBackend{
+			ObjectMeta: metav1.ObjectMeta{Name: "moderation-tls", Namespace: namespace},
+			Spec: aigv1a1.AIServiceBackendSpec{
+				APISchema:  aigv1a1.VersionedAPISchema{Name: aigv1a1.APISchemaOpenAI},
+				BackendRef: gwapiv1.BackendObjectReference{Name: "moderation-tls-backend"},
+			},
+		},
+		&egv1a1.Backend{
+			ObjectMeta: metav1.ObjectMeta{Name: "moderation-tls-backend", Namespace: namespace},
+			Spec: egv1a1.BackendSpec{Endpoints: []egv1a1.BackendEndpoint{
+				{FQDN: &egv1a1.FQDNEndpoint{Hostname: "moderation.internal", Port: 8443}},
+			}},
+		},
+		&gwapiv1a3.BackendTLSPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "moderation-tls", Namespace: namespace},
+			Spec: gwapiv1a3.BackendTLSPolicySpec{
+				TargetRefs: []gwapiv1a2.LocalPolicyTargetReferenceWithSectionName{{
+					LocalPolicyTargetReference: gwapiv1a2.LocalPolicyTargetReference{
+						Group: "gateway.envoyproxy.io", Kind: "Backend", Name: "moderation-tls-backend",
+					},
+				}},
+				Validation: gwapiv1a3.BackendTLSPolicyValidation{
+					Hostname:          "moderation.internal",
+					CACertificateRefs: []gwapiv1.LocalObjectReference{{Kind: "Secret", Name: "moderation-ca"}},
+				},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "moderation-ca", Namespace: namespace},
+			Data:       map[string][]byte{"ca.crt": []byte("-----BEGIN CERTIFICATE-----\nfoo\n-----END CERTIFICATE-----\n")},
+		},
+	} {
+		require.NoError(t, fakeClient.Create(t.Context(), obj))
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		g, err := c.guardrailToFilterAPI(t.Context(), namespace, &aigv1a1.AIGatewayRouteRuleGuardrail{Name: "g", BackendName: "moderation"})
+		require.NoError(t, err)
+		require.Equal(t, &filterapi.Guardrail{
+			Name: "g",
+			Backend: filterapi.Backend{
+				Name:   "moderation.ns",
+				Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI, Version: "v1"},
+			},
+			URL:       "http://10.0.0.1:8080",
+			Type:      filterapi.GuardrailTypeOpenAIModeration,
+			Path:      "/v1/moderations",
+			Input:     true,
+			Threshold: 0.5,
+			Action:    filterapi.GuardrailActionBlock,
+			Timeout:   5 * time.Second,
+		}, g)
+	})
+	t.Run("backend tls policy", func(t *testing.T) {
+		// The BackendTLSPolicy makes the moderation backend called with https even though the port is not 443.
+		g, err := c.guardrailToFilterAPI(t.Context(), namespace, &aigv1a1.AIGatewayRouteRuleGuardrail{Name: "g", BackendName: "moderation-tls"})
+		require.NoError(t, err)
+		require.Equal(t, "https://moderation.internal:8443", g.URL)
+		require.Equal(t, &filterapi.BackendTLS{
+			Hostname:       "moderation.internal",
+			CACertificates: "-----BEGIN CERTIFICATE-----\nfoo\n-----END CERTIFICATE-----\n",
+		}, g.TLS)
+	})
+	t.Run("all fields", func(t *testing.T) {
+		g, err := c.guardrailToFilterAPI(t.Context(), namespace, &aigv1a1.AIGatewayRouteRuleGuardrail{
+			Name:        "g",
+			BackendName: "moderation",
+			Type:        ptr.To(aigv1a1.AIGatewayRouteRuleGuardrailTypeHTTPClassifier),
+			Path:        ptr.To("/classify"),
+			Phases:      []aigv1a1.AIGatewayRouteRuleGuardrailPhase{aigv1a1.AIGatewayRouteRuleGuardrailPhaseOutput},
+			Categories:  []string{"jailbreak"},
+			Threshold:   ptr.To("0.8"),
+			Action:      ptr.To(aigv1a1.AIGatewayRouteRuleGuardrailActionFlag),
+			FailureMode: ptr.To(aigv1a1.AIGatewayRouteRuleGuardrailFailureModeFailClosed),
+			Timeout:     ptr.To[gwapiv1.Duration]("1s"),
+		})
+		require.NoError(t, err)
+		require.Equal(t, filterapi.GuardrailTypeHTTPClassifier, g.Type)
+		require.Equal(t, "/classify", g.Path)
+		require.False(t, g.Input)
+		require.True(t, g.Output)
+		require.Equal(t, []string{"jailbreak"}, g.Categories)
+		require.Equal(t, 0.8, g.Threshold)
+		require.Equal(t, filterapi.GuardrailActionFlag, g.Action)
+		require.True(t, g.FailClosed)
+		require.Equal(t, time.Second, g.Timeout)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		_, err := c.guardrailToFilterAPI(t.Context(), namespace, &aigv1a1.AIGatewayRouteRuleGuardrail{Name: "g", BackendName: "moderation", Threshold: ptr.To("x")})
+		require.ErrorContains(t, err, `invalid threshold "x" of guardrail g`)
+		_, err = c.guardrailToFilterAPI(t.Context(), namespace, &aigv1a1.AIGatewayRouteRuleGuardrail{Name: "g", BackendName: "nonexistent"})
+		require.ErrorContains(t, err, "failed to get AIServiceBackend nonexistent.ns")
+	})
+}
+
+func Test_pricingToFilterAPI(t *testing.T) {
+	_, err := pricingToFilterAPI(&aigv1a1.AIServiceBackendModelPricing{Model: "m", InputPerMillionTokens: "x", OutputPerMillionTokens: "1"})
+	require.ErrorContains(t, err, `invalid input price "x" of model m`)
+	_, err = pricingToFilterAPI(&aigv1a1.AIServiceBackendModelPricing{Model: "m", InputPerMillionTokens: "1", OutputPerMillionTokens: "x"})
+	require.ErrorContains(t, err, `invalid output price "x" of model m`)
+	_, err = pricingToFilterAPI(&aigv1a1.AIServiceBackendModelPricing{Model: "m", InputPerMillionTokens: "1", OutputPerMillionTokens: "1", CachedInputPerMillionTokens: ptr.To("x")})
+	require.ErrorContains(t, err, `invalid cached input price "x" of model m`)
+}
+
+func Test_requestPolicyToFilterAPI(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		p, err := requestPolicyToFilterAPI(&aigv1a1.AIGatewayRouteRuleRequestPolicy{
+			MaxTokens: &aigv1a1.AIGatewayRouteRuleRequestLimit{Max: 4096},
+			N:         &aigv1a1.AIGatewayRouteRuleRequestLimit{Max: 1, Action: ptr.To(aigv1a1.AIGatewayRouteRuleRequestLimitActionReject)},
+			Temperature: &aigv1a1.AIGatewayRouteRuleTemperatureLimit{
+				Min: ptr.To("0.2"), Max: ptr.To("1.0"), Action: ptr.To(aigv1a1.AIGatewayRouteRuleRequestLimitActionClamp),
+			},
+			Messages:    &aigv1a1.AIGatewayRouteRuleRequestLimit{Max: 100},
+			MaxBodySize: ptr.To(resource.MustParse("8Mi")),
+			Images: &aigv1a1.AIGatewayRouteRuleImagePolicy{
+				MaxImages: ptr.To[int32](4), MaxImageSize: ptr.To(resource.MustParse("1M")), AllowedMIMETypes: []string{"image/png"},
+			},
+			MaxToolSchemaDepth: ptr.To[int32](5),
+		})
+		require.NoError(t, err)
+		require.Equal(t, &filterapi.RequestPolicy{
+			MaxTokens:          &filterapi.RequestLimit{Max: 4096, Action: filterapi.RequestLimitActionClamp},
+			N:                  &filterapi.RequestLimit{Max: 1, Action: filterapi.RequestLimitActionReject},
+			Temperature:        &filterapi.TemperatureLimit{Min: ptr.To(0.2), Max: ptr.To(1.0), Action: filterapi.RequestLimitActionClamp},
+			Messages:           &filterapi.RequestLimit{Max: 100, Action: filterapi.RequestLimitActionClamp},
+			MaxBodyBytes:       8 << 20,
+			Images:             &filterapi.ImagePolicy{MaxImages: ptr.To(4), MaxImageBytes: 1000000, AllowedMIMETypes: []string{"image/png"}},
+			MaxToolSchemaDepth: 5,
+		}, p)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := requestPolicyToFilterAPI(&aigv1a1.AIGatewayRouteRuleRequestPolicy{
+			Temperature: &aigv1a1.AIGatewayRouteRuleTemperatureLimit{Min: ptr.To("x")},
+		})
+		require.ErrorContains(t, err, `invalid min temperature "x"`)
+		_, err = requestPolicyToFilterAPI(&aigv1a1.AIGatewayRouteRuleRequestPolicy{
+			Temperature: &aigv1a1.AIGatewayRouteRuleTemperatureLimit{Max: ptr.To("x")},
+		})
+		require.ErrorContains(t, err, `invalid max temperature "x"`)
+		_, err = requestPolicyToFilterAPI(&aigv1a1.AIGatewayRouteRuleRequestPolicy{
+			Temperature: &aigv1a1.AIGatewayRouteRuleTemperatureLimit{Min: ptr.To("1.5"), Max: ptr.To("1.0")},
+		})
+		require.EqualError(t, err, "min temperature 1.5 is greater than max temperature 1.0")
+	})
+}
+
+func TestGatewayController_redactionToFilterAPI(t *testing.T) {
+	kube := fake2.NewClientset()
+	c := NewGatewayController(requireNewFakeClientWithIndexes(t), kube, ctrl.Log,
+		"envoy-gateway-system", "/foo/bar/uds.sock", "docker.io/envoyproxy/ai-gateway-extproc:latest", false)
+	_, err := kube.CoreV1().Secrets("ns").Create(t.Context(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "hash-key", Namespace: "ns"},
+		Data:       map[string][]byte{"hashKey": []byte("secret")},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	got, err := c.redactionToFilterAPI(t.Context(), "ns", &aigv1a1.AIGatewayRouteRuleRedaction{
+		Responses: true,
+		Detectors: []aigv1a1.AIGatewayRouteRuleRedactionDetector{
+			{Name: "EMAIL", Builtin: ptr.To(aigv1a1.AIGatewayRouteRuleRedactionBuiltinDetectorEmail)},
+			{
+				Name: "EMPLOYEE_ID", Pattern: ptr.To(`E\d{6}`), Validator: ptr.To(aigv1a1.AIGatewayRouteRuleRedactionValidatorLuhn),
+				Action: ptr.To(aigv1a1.AIGatewayRouteRuleRedactionActionTokenize),
+			},
+			{Name: "SSN", Builtin: ptr.To(aigv1a1.AIGatewayRouteRuleRedactionBuiltinDetectorUSSocialSecurityNumber), Action: ptr.To(aigv1a1.AIGatewayRouteRuleRedactionActionHash)},
+		},
+		HashKeyRef: &gwapiv1.SecretObjectReference{Name: "hash-key"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &filterapi.Redaction{
+		Responses: true,
+		Detectors: []filterapi.RedactionDetector{
+			{Name: "EMAIL", Builtin: filterapi.RedactionBuiltinDetectorEmail, Action: filterapi.RedactionActionMask},
+			{Name: "EMPLOYEE_ID", Pattern: `E\d{6}`, Validator: filterapi.RedactionValidatorLuhn, Action: filterapi.RedactionActionTokenize},
+			{Name: "SSN", Builtin: filterapi.RedactionBuiltinDetectorUSSocialSecurityNumber, Action: filterapi.RedactionActionHash},
+		},
+		HashKey: "secret",
+	}, got)
+
+	_, err = c.redactionToFilterAPI(t.Context(), "ns", &aigv1a1.AIGatewayRouteRuleRedaction{
+		Detectors: []aigv1a1.AIGatewayRouteRuleRedactionDetector{{Name: "BAD", Pattern: ptr.To(`(`)}},
+	})
+	require.ErrorContains(t, err, "invalid pattern of detector BAD")
+
+	_, err = c.redactionToFilterAPI(t.Context(), "other", &aigv1a1.AIGatewayRouteRuleRedaction{
+		Detectors:  []aigv1a1.AIGatewayRouteRuleRedactionDetector{{Name: "EMAIL", Builtin: ptr.To(aigv1a1.AIGatewayRouteRuleRedactionBuiltinDetectorEmail)}},
+		HashKeyRef: &gwapiv1.SecretObjectReference{Name: "hash-key"},
+	})
+	require.ErrorContains(t, err, "failed to get hash key")
+}
+
+func Test_streamModerationToFilterAPI(t *testing.T) {
+	got, err := streamModerationToFilterAPI(&aigv1a1.AIGatewayRouteRuleStreamModeration{DenyPatterns: []string{`(?i)api[_-]?key`}})
+	require.NoError(t, err)
+	require.Equal(t, &filterapi.StreamModeration{WindowSize: 256, DenyPatterns: []string{`(?i)api[_-]?key`}}, got)
+
+	got, err = streamModerationToFilterAPI(&aigv1a1.AIGatewayRouteRuleStreamModeration{WindowSize: ptr.To[int32](64)})
+	require.NoError(t, err)
+	require.Equal(t, &filterapi.StreamModeration{WindowSize: 64}, got)
+
+	_, err = streamModerationToFilterAPI(&aigv1a1.AIGatewayRouteRuleStreamModeration{DenyPatterns: []string{`(`}})
+	require.ErrorContains(t, err, "invalid deny pattern")
+}
+
+func Test_apiKeyToFilterAPI(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   aigv1a1.BackendSecurityPolicyAPIKey
+		exp  filterapi.APIKeyAuth
+	}{
+		{name: "default", exp: filterapi.APIKeyAuth{Key: "k"}},
+		{name: "header", in: aigv1a1.BackendSecurityPolicyAPIKey{Header: ptr.To("x-api-key")}, exp: filterapi.APIKeyAuth{Key: "k", Header: "x-api-key"}},
+		{
+			name: "authorization header with default prefix",
+			in:   aigv1a1.BackendSecurityPolicyAPIKey{Header: ptr.To("authorization")},
+			exp:  filterapi.APIKeyAuth{Key: "k", Header: "authorization", Prefix: "Bearer "},
+		},
+		{
+			name: "prefix",
+			in:   aigv1a1.BackendSecurityPolicyAPIKey{Prefix: ptr.To("Token ")},
+			exp:  filterapi.APIKeyAuth{Key: "k", Header: "Authorization", Prefix: "Token "},
+		},
+		{name: "query param", in: aigv1a1.BackendSecurityPolicyAPIKey{QueryParam: ptr.To("key")}, exp: filterapi.APIKeyAuth{Key: "k", QueryParam: "key"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, &tc.exp, apiKeyToFilterAPI(&tc.in, "k"))
+		})
+	}
+}
+
+func Test_auditLogToFilterAPI(t *testing.T) {
+	got, err := auditLogToFilterAPI(&aigv1a1.AIGatewayRouteRuleAuditLog{})
+	require.NoError(t, err)
+	require.Equal(t, &filterapi.AuditLog{SamplingRate: 1}, got)
+
+	got, err = auditLogToFilterAPI(&aigv1a1.AIGatewayRouteRuleAuditLog{
+		SamplingRate: ptr.To("0.25"), RedactFields: []string{"request.messages.*.content"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &filterapi.AuditLog{SamplingRate: 0.25, RedactFields: []string{"request.messages.*.content"}}, got)
+
+	_, err = auditLogToFilterAPI(&aigv1a1.AIGatewayRouteRuleAuditLog{SamplingRate: ptr.To("1.5")})
+	require.EqualError(t, err, `invalid sampling rate "1.5"`)
+}
+
+func Test_authorizationToFilterAPI(t *testing.T) {
+	route := &aigv1a1.AIGatewayRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "route1", Namespace: "ns"},
+		Spec:       aigv1a1.AIGatewayRouteSpec{Authorization: &aigv1a1.AIGatewayRouteAuthorization{CEL: `claims[?"team"].orValue("") == "a"`}},
+	}
+	got, err := authorizationToFilterAPI(route)
+	require.NoError(t, err)
+	require.Equal(t, &filterapi.Authorization{Route: "route1", ClaimsHeader: "x-jwt-claims", CEL: `claims[?"team"].orValue("") == "a"`}, got)
+
+	route.Spec.Authorization.ClaimsHeader = ptr.To("X-Claims")
+	got, err = authorizationToFilterAPI(route)
+	require.NoError(t, err)
+	require.Equal(t, "x-claims", got.ClaimsHeader)
+
+	route.Spec.Authorization.CEL = "model"
+	_, err = authorizationToFilterAPI(route)
+	require.ErrorContains(t, err, "CEL expression must evaluate to a boolean")
+}
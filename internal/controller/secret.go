@@ -27,17 +27,21 @@ type secretController struct {
 	kubeClient                     kubernetes.Interface
 	logger                         logr.Logger
 	backendSecurityPolicyEventChan chan event.GenericEvent
+	consumerKeyEventChan           chan event.GenericEvent
+	aiGatewayRouteEventChan        chan event.GenericEvent
 }
 
 // NewSecretController creates a new reconcile.TypedReconciler[reconcile.Request] for corev1.Secret.
 func NewSecretController(client client.Client, kubeClient kubernetes.Interface,
-	logger logr.Logger, backendSecurityPolicyEventChan chan event.GenericEvent,
+	logger logr.Logger, backendSecurityPolicyEventChan, consumerKeyEventChan, aiGatewayRouteEventChan chan event.GenericEvent,
 ) reconcile.TypedReconciler[reconcile.Request] {
 	return &secretController{
 		client:                         client,
 		kubeClient:                     kubeClient,
 		logger:                         logger,
 		backendSecurityPolicyEventChan: backendSecurityPolicyEventChan,
+		consumerKeyEventChan:           consumerKeyEventChan,
+		aiGatewayRouteEventChan:        aiGatewayRouteEventChan,
 	}
 }
 
@@ -74,5 +78,35 @@ func (c *secretController) syncSecret(ctx context.Context, namespace, name strin
 			"namespace", backendSecurityPolicy.Namespace, "name", backendSecurityPolicy.Name)
 		c.backendSecurityPolicyEventChan <- event.GenericEvent{Object: backendSecurityPolicy}
 	}
+
+	var consumerKeys aigv1a1.AIGatewayConsumerKeyList
+	err = c.client.List(ctx, &consumerKeys,
+		client.MatchingFields{
+			k8sClientIndexSecretToReferencingAIGatewayConsumerKey: fmt.Sprintf("%s.%s", name, namespace),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list AIGatewayConsumerKeyList: %w", err)
+	}
+	for i := range consumerKeys.Items {
+		consumerKey := &consumerKeys.Items[i]
+		c.logger.Info("Syncing AIGatewayConsumerKey", "namespace", consumerKey.Namespace, "name", consumerKey.Name)
+		c.consumerKeyEventChan <- event.GenericEvent{Object: consumerKey}
+	}
+
+	var aiGatewayRoutes aigv1a1.AIGatewayRouteList
+	err = c.client.List(ctx, &aiGatewayRoutes,
+		client.MatchingFields{
+			k8sClientIndexSecretToReferencingAIGatewayRoute: fmt.Sprintf("%s.%s", name, namespace),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to list AIGatewayRouteList: %w", err)
+	}
+	for i := range aiGatewayRoutes.Items {
+		aiGatewayRoute := &aiGatewayRoutes.Items[i]
+		c.logger.Info("Syncing AIGatewayRoute", "namespace", aiGatewayRoute.Namespace, "name", aiGatewayRoute.Name)
+		c.aiGatewayRouteEventChan <- event.GenericEvent{Object: aiGatewayRoute}
+	}
 	return nil
 }
@@ -24,8 +24,10 @@ import (
 
 func TestSecretController_Reconcile(t *testing.T) {
 	eventCh := internaltesting.NewControllerEventChan[*aigv1a1.BackendSecurityPolicy]()
+	consumerKeyEventCh := internaltesting.NewControllerEventChan[*aigv1a1.AIGatewayConsumerKey]()
+	routeEventCh := internaltesting.NewControllerEventChan[*aigv1a1.AIGatewayRoute]()
 	fakeClient := requireNewFakeClientWithIndexes(t)
-	c := NewSecretController(fakeClient, fake2.NewClientset(), ctrl.Log, eventCh.Ch)
+	c := NewSecretController(fakeClient, fake2.NewClientset(), ctrl.Log, eventCh.Ch, consumerKeyEventCh.Ch, routeEventCh.Ch)
 
 	err := fakeClient.Create(t.Context(), &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{Name: "mysecret", Namespace: "default"},
@@ -56,6 +58,24 @@ func TestSecretController_Reconcile(t *testing.T) {
 	for _, bsp := range originals {
 		require.NoError(t, fakeClient.Create(t.Context(), bsp))
 	}
+	// Create a consumer key that references the secret.
+	consumerKey := &aigv1a1.AIGatewayConsumerKey{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "default"},
+		Spec: aigv1a1.AIGatewayConsumerKeySpec{
+			Consumer:  "alice",
+			SecretRef: &gwapiv1.SecretObjectReference{Name: "mysecret"},
+		},
+	}
+	require.NoError(t, fakeClient.Create(t.Context(), consumerKey))
+	// Create a route that references the secret as the hash key of the redaction.
+	route := &aigv1a1.AIGatewayRoute{
+		ObjectMeta: metav1.ObjectMeta{Name: "route", Namespace: "default"},
+		Spec: aigv1a1.AIGatewayRouteSpec{Rules: []aigv1a1.AIGatewayRouteRule{
+			{},
+			{Redaction: &aigv1a1.AIGatewayRouteRuleRedaction{HashKeyRef: &gwapiv1.SecretObjectReference{Name: "mysecret"}}},
+		}},
+	}
+	require.NoError(t, fakeClient.Create(t.Context(), route))
 
 	_, err = c.Reconcile(t.Context(), reconcile.Request{NamespacedName: types.NamespacedName{
 		Namespace: "default", Name: "mysecret",
@@ -69,6 +89,8 @@ func TestSecretController_Reconcile(t *testing.T) {
 		return originals[i].Name < originals[j].Name
 	})
 	require.Equal(t, originals, actual)
+	require.Equal(t, []*aigv1a1.AIGatewayConsumerKey{consumerKey}, consumerKeyEventCh.RequireItemsEventually(t, 1))
+	require.Equal(t, []*aigv1a1.AIGatewayRoute{route}, routeEventCh.RequireItemsEventually(t, 1))
 
 	// Test the case where the Secret is being deleted.
 	err = fakeClient.Delete(t.Context(), &corev1.Secret{
@@ -8,6 +8,8 @@ package extensionserver
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -17,10 +19,12 @@ import (
 	mutation_rulesv3 "github.com/envoyproxy/go-control-plane/envoy/config/common/mutation_rules/v3"
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	extprocv3http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
 	header_mutationv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/header_mutation/v3"
 	upstream_codecv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/upstream_codec/v3"
 	httpconnectionmanagerv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	previous_hostsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/retry/host/previous_hosts/v3"
 	httpv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/upstreams/http/v3"
 	"github.com/go-logr/logr"
 	"google.golang.org/grpc/codes"
@@ -31,6 +35,7 @@ import (
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	aigv1a1 "github.com/envoyproxy/ai-gateway/api/v1alpha1"
@@ -48,6 +53,9 @@ type Server struct {
 
 const serverName = "envoy-gateway-extension-server"
 
+// defaultRetryOn is the retry conditions of the retry policy added to the routes without one.
+const defaultRetryOn = "5xx,reset,connect-failure"
+
 // New creates a new instance of the extension server that implements the EnvoyGatewayExtensionServer interface.
 func New(k8sClient client.Client, logger logr.Logger, udsPath string) *Server {
 	logger = logger.WithName(serverName)
@@ -213,6 +221,13 @@ func (s *Server) maybeModifyCluster(cluster *clusterv3.Cluster) {
 			m.Fields["backend_name"] = structpb.NewStringValue(fmt.Sprintf("%s.%s", name, namespace))
 		}
 	}
+	if httpRouteRule.SessionAffinity != nil {
+		// The route hashes the session key header set by the extproc. See PostVirtualHostModify.
+		// Maglev keeps the mapping of keys to hosts stable across config reloads while respecting the endpoint weights.
+		cluster.LbPolicy = clusterv3.Cluster_MAGLEV
+		cluster.LbConfig = nil
+		cluster.LoadBalancingPolicy = nil
+	}
 
 	if cluster.TypedExtensionProtocolOptions == nil {
 		cluster.TypedExtensionProtocolOptions = make(map[string]*anypb.Any)
@@ -324,6 +339,174 @@ func mustToAny(msg proto.Message) *anypb.Any {
 }
 
 // PostVirtualHostModify allows an extension to modify the virtual hosts in the xDS config.
-func (s *Server) PostVirtualHostModify(context.Context, *egextension.PostVirtualHostModifyRequest) (*egextension.PostVirtualHostModifyResponse, error) {
-	return nil, nil
+//
+// This applies the per-rule configurations of the AIGatewayRoute that are implemented by the route of Envoy,
+// such as session affinity and hedging. See maybeModifyRoute.
+func (s *Server) PostVirtualHostModify(ctx context.Context, req *egextension.PostVirtualHostModifyRequest) (*egextension.PostVirtualHostModifyResponse, error) {
+	if req.VirtualHost == nil {
+		return nil, nil
+	}
+	var modified bool
+	for _, route := range req.VirtualHost.Routes {
+		if s.maybeModifyRoute(ctx, route) {
+			modified = true
+		}
+	}
+	if !modified {
+		return nil, nil
+	}
+	return &egextension.PostVirtualHostModifyResponse{VirtualHost: req.VirtualHost}, nil
+}
+
+// maybeModifyRoute applies the per-rule configurations of the AIGatewayRoute to the corresponding route,
+// and returns true if the route has been modified:
+//   - Session affinity: the route hashes the session key header set by the extproc, and retries once on failures
+//     when it has no retry policy so that a failing pinned backend does not fail the request.
+//   - Hedging: the per-try timeout of the route triggers a hedged request instead of resetting the original one.
+//     Envoy stops the per-try timeout once the response headers arrive, so this hedges on the time to the
+//     response headers rather than the time to the first token.
+//   - Circuit breaker: the route retries on 503, which the extproc returns for a backend whose circuit is open,
+//     so that such requests are sent to the other backends of the rule instead of failing.
+//
+// In all the cases, retries are made to avoid the previously attempted hosts so that a failing, slow, or unavailable
+// backend does not receive the retries, the hedged requests, or the rerouted requests of the requests sent to it.
+func (s *Server) maybeModifyRoute(ctx context.Context, route *routev3.Route) bool {
+	action := route.GetRoute()
+	if action == nil {
+		return false
+	}
+	// The route name is in the format "httproute/<namespace>/<name>/rule/<index_of_rule>/match/<index_of_match>/...".
+	parts := strings.Split(route.Name, "/")
+	if len(parts) < 5 || parts[0] != "httproute" || parts[3] != "rule" {
+		return false
+	}
+	httpRouteRuleIndex, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return false
+	}
+	var aigwRoute aigv1a1.AIGatewayRoute
+	err = s.k8sClient.Get(ctx, client.ObjectKey{Namespace: parts[1], Name: parts[2]}, &aigwRoute)
+	if err != nil {
+		// The route may be a plain HTTPRoute not managed by the AI Gateway.
+		return false
+	}
+	if httpRouteRuleIndex >= len(aigwRoute.Spec.Rules) {
+		return false
+	}
+	rule := &aigwRoute.Spec.Rules[httpRouteRuleIndex]
+
+	var modified bool
+	if rule.SessionAffinity != nil && !hasSessionKeyHashPolicy(action) {
+		action.HashPolicy = append(action.HashPolicy, &routev3.RouteAction_HashPolicy{
+			PolicySpecifier: &routev3.RouteAction_HashPolicy_Header_{
+				Header: &routev3.RouteAction_HashPolicy_Header{HeaderName: aigv1a1.SessionKeyHeaderKey},
+			},
+		})
+		if action.RetryPolicy == nil {
+			// Retry once so that a request failing on the pinned backend falls back to another backend.
+			action.RetryPolicy = &routev3.RetryPolicy{RetryOn: defaultRetryOn, NumRetries: wrapperspb.UInt32(1)}
+		}
+		modified = true
+	}
+	if h := rule.Hedging; h != nil && !action.GetHedgePolicy().GetHedgeOnPerTryTimeout() {
+		delay, err := time.ParseDuration(string(h.Delay))
+		if err != nil {
+			s.log.Error(err, "failed to parse hedging delay", "route_name", route.Name, "delay", h.Delay)
+			return modified
+		}
+		if action.RetryPolicy == nil {
+			action.RetryPolicy = &routev3.RetryPolicy{RetryOn: defaultRetryOn}
+		}
+		if n := uint32(ptr.Deref(h.MaxHedgedRequests, 1)); action.RetryPolicy.GetNumRetries().GetValue() < n { //nolint:gosec
+			action.RetryPolicy.NumRetries = wrapperspb.UInt32(n)
+		}
+		// A smaller per-try timeout configured by the user is kept, in which case the hedged request is sent
+		// after that timeout instead of the delay.
+		if cur := action.RetryPolicy.GetPerTryTimeout(); cur == nil || cur.AsDuration() <= 0 || delay < cur.AsDuration() {
+			action.RetryPolicy.PerTryTimeout = durationpb.New(delay)
+		}
+		action.HedgePolicy = &routev3.HedgePolicy{HedgeOnPerTryTimeout: true}
+		modified = true
+	}
+	if len(rule.BackendRefs) > 1 && !retriesOnServiceUnavailable(action.RetryPolicy) &&
+		s.hasCircuitBreaker(ctx, aigwRoute.Namespace, rule) {
+		if action.RetryPolicy == nil {
+			action.RetryPolicy = &routev3.RetryPolicy{}
+		}
+		rp := action.RetryPolicy
+		if rp.RetryOn == "" {
+			rp.RetryOn = "retriable-status-codes"
+		} else if !slices.Contains(strings.Split(rp.RetryOn, ","), "retriable-status-codes") {
+			rp.RetryOn += ",retriable-status-codes"
+		}
+		rp.RetriableStatusCodes = append(rp.RetriableStatusCodes, http.StatusServiceUnavailable)
+		if rp.NumRetries == nil {
+			// Each of the other backends of the rule can be tried once.
+			rp.NumRetries = wrapperspb.UInt32(uint32(len(rule.BackendRefs) - 1)) //nolint:gosec
+		}
+		modified = true
+	}
+	if modified && action.RetryPolicy != nil {
+		maybeAddPreviousHostsRetryPredicate(action.RetryPolicy)
+	}
+	return modified
+}
+
+// hasCircuitBreaker returns true if any backend of the given rule has the circuit breaker enabled.
+func (s *Server) hasCircuitBreaker(ctx context.Context, namespace string, rule *aigv1a1.AIGatewayRouteRule) bool {
+	for i := range rule.BackendRefs {
+		var backend aigv1a1.AIServiceBackend
+		err := s.k8sClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: rule.BackendRefs[i].Name}, &backend)
+		if err == nil && backend.Spec.CircuitBreaker != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// retriesOnServiceUnavailable returns true if the given retry policy retries the requests failed with 503.
+func retriesOnServiceUnavailable(rp *routev3.RetryPolicy) bool {
+	if rp == nil {
+		return false
+	}
+	for _, on := range strings.Split(rp.RetryOn, ",") {
+		switch strings.TrimSpace(on) {
+		case "5xx", "gateway-error":
+			return true
+		case "retriable-status-codes":
+			if slices.Contains(rp.RetriableStatusCodes, http.StatusServiceUnavailable) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasSessionKeyHashPolicy returns true if the route action already hashes the session key header.
+func hasSessionKeyHashPolicy(action *routev3.RouteAction) bool {
+	for _, hp := range action.HashPolicy {
+		if h := hp.GetHeader(); h != nil && h.HeaderName == aigv1a1.SessionKeyHeaderKey {
+			return true
+		}
+	}
+	return false
+}
+
+// maybeAddPreviousHostsRetryPredicate makes the retries of the given policy avoid the previously attempted hosts.
+func maybeAddPreviousHostsRetryPredicate(rp *routev3.RetryPolicy) {
+	const previousHostsPredicate = "envoy.retry_host_predicates.previous_hosts"
+	for _, p := range rp.RetryHostPredicate {
+		if p.Name == previousHostsPredicate {
+			return
+		}
+	}
+	rp.RetryHostPredicate = append(rp.RetryHostPredicate, &routev3.RetryPolicy_RetryHostPredicate{
+		Name: previousHostsPredicate,
+		ConfigType: &routev3.RetryPolicy_RetryHostPredicate_TypedConfig{
+			TypedConfig: mustToAny(&previous_hostsv3.PreviousHostsPredicate{}),
+		},
+	})
+	if rp.HostSelectionRetryMaxAttempts == 0 {
+		rp.HostSelectionRetryMaxAttempts = 5
+	}
 }
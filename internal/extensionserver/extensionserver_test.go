@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"log/slog"
 	"testing"
+	"time"
 
 	egextension "github.com/envoyproxy/gateway/proto/extension"
 	clusterv3 "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
@@ -16,6 +17,8 @@ import (
 	routev3 "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -94,6 +97,205 @@ func TestServerPostVirtualHostModify(t *testing.T) {
 		require.Nil(t, res)
 		require.NoError(t, err)
 	})
+	t.Run("session affinity", func(t *testing.T) {
+		c := newFakeClient()
+		require.NoError(t, c.Create(t.Context(), &aigv1a1.AIGatewayRoute{
+			ObjectMeta: metav1.ObjectMeta{Name: "myroute", Namespace: "ns"},
+			Spec: aigv1a1.AIGatewayRouteSpec{
+				Rules: []aigv1a1.AIGatewayRouteRule{
+					{BackendRefs: []aigv1a1.AIGatewayRouteRuleBackendRef{{Name: "aaa"}}},
+					{
+						BackendRefs:     []aigv1a1.AIGatewayRouteRuleBackendRef{{Name: "bbb"}},
+						SessionAffinity: &aigv1a1.AIGatewayRouteRuleSessionAffinity{Header: ptr.To("x-session-id")},
+					},
+					{
+						BackendRefs: []aigv1a1.AIGatewayRouteRuleBackendRef{{Name: "ccc"}, {Name: "ddd"}},
+						Hedging:     &aigv1a1.AIGatewayRouteRuleHedging{Delay: "500ms", MaxHedgedRequests: ptr.To[int32](2)},
+					},
+					{BackendRefs: []aigv1a1.AIGatewayRouteRuleBackendRef{{Name: "ccc"}, {Name: "ddd"}}},
+				},
+			},
+		}))
+		s := New(c, logr.Discard(), udsPath)
+
+		newRoute := func(name string) *routev3.Route {
+			return &routev3.Route{Name: name, Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+				RetryPolicy: &routev3.RetryPolicy{},
+			}}}
+		}
+		t.Run("no session affinity", func(t *testing.T) {
+			res, err := s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+				VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{
+					newRoute("httproute/ns/myroute/rule/0/match/0/example_com"),
+					newRoute("httproute/ns/nonexistent/rule/1/match/0/example_com"),
+					newRoute("httproute/ns/myroute/rule/99/match/0/example_com"),
+					newRoute("invalid"),
+				}},
+			})
+			require.NoError(t, err)
+			require.Nil(t, res)
+		})
+		t.Run("ok", func(t *testing.T) {
+			route := newRoute("httproute/ns/myroute/rule/1/match/0/example_com")
+			res, err := s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+				VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{route}},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, res)
+			action := res.VirtualHost.Routes[0].GetRoute()
+			require.Len(t, action.HashPolicy, 1)
+			require.Equal(t, aigv1a1.SessionKeyHeaderKey, action.HashPolicy[0].GetHeader().HeaderName)
+			require.Len(t, action.RetryPolicy.RetryHostPredicate, 1)
+			require.Equal(t, "envoy.retry_host_predicates.previous_hosts", action.RetryPolicy.RetryHostPredicate[0].Name)
+			require.Equal(t, int64(5), action.RetryPolicy.HostSelectionRetryMaxAttempts)
+
+			// Calling it again should be a no-op.
+			res, err = s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+				VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{route}},
+			})
+			require.NoError(t, err)
+			require.Nil(t, res)
+		})
+		t.Run("default retry policy", func(t *testing.T) {
+			// The route without a retry policy retries once so that a failing pinned backend falls back to another.
+			route := &routev3.Route{
+				Name:   "httproute/ns/myroute/rule/1/match/0/example_com",
+				Action: &routev3.Route_Route{Route: &routev3.RouteAction{}},
+			}
+			res, err := s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+				VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{route}},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, res)
+			action := res.VirtualHost.Routes[0].GetRoute()
+			require.Len(t, action.HashPolicy, 1)
+			require.Equal(t, "5xx,reset,connect-failure", action.RetryPolicy.RetryOn)
+			require.Equal(t, uint32(1), action.RetryPolicy.NumRetries.GetValue())
+			require.Len(t, action.RetryPolicy.RetryHostPredicate, 1)
+			require.Equal(t, "envoy.retry_host_predicates.previous_hosts", action.RetryPolicy.RetryHostPredicate[0].Name)
+		})
+		t.Run("hedging", func(t *testing.T) {
+			route := &routev3.Route{
+				Name:   "httproute/ns/myroute/rule/2/match/0/example_com",
+				Action: &routev3.Route_Route{Route: &routev3.RouteAction{}},
+			}
+			res, err := s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+				VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{route}},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, res)
+			action := res.VirtualHost.Routes[0].GetRoute()
+			require.Empty(t, action.HashPolicy)
+			require.True(t, action.HedgePolicy.HedgeOnPerTryTimeout)
+			require.Equal(t, "5xx,reset,connect-failure", action.RetryPolicy.RetryOn)
+			require.Equal(t, uint32(2), action.RetryPolicy.NumRetries.GetValue())
+			require.Equal(t, 500*time.Millisecond, action.RetryPolicy.PerTryTimeout.AsDuration())
+			require.Len(t, action.RetryPolicy.RetryHostPredicate, 1)
+
+			res, err = s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+				VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{route}},
+			})
+			require.NoError(t, err)
+			require.Nil(t, res)
+		})
+		t.Run("circuit breaker", func(t *testing.T) {
+			require.NoError(t, c.Create(t.Context(), &aigv1a1.AIServiceBackend{
+				ObjectMeta: metav1.ObjectMeta{Name: "ddd", Namespace: "ns"},
+				Spec: aigv1a1.AIServiceBackendSpec{
+					CircuitBreaker: &aigv1a1.AIServiceBackendCircuitBreaker{ConsecutiveFailures: ptr.To[int32](3)},
+				},
+			}))
+			defer func() {
+				require.NoError(t, c.Delete(t.Context(), &aigv1a1.AIServiceBackend{ObjectMeta: metav1.ObjectMeta{Name: "ddd", Namespace: "ns"}}))
+			}()
+			for _, tc := range []struct {
+				name           string
+				retryPolicy    *routev3.RetryPolicy
+				expRetryOn     string
+				expStatusCodes []uint32
+				expNumRetries  uint32
+			}{
+				{
+					name:           "no retry policy",
+					expRetryOn:     "retriable-status-codes",
+					expStatusCodes: []uint32{503},
+					expNumRetries:  1,
+				},
+				{
+					name:           "retry on other status codes",
+					retryPolicy:    &routev3.RetryPolicy{RetryOn: "reset,retriable-status-codes", RetriableStatusCodes: []uint32{429}, NumRetries: wrapperspb.UInt32(3)},
+					expRetryOn:     "reset,retriable-status-codes",
+					expStatusCodes: []uint32{429, 503},
+					expNumRetries:  3,
+				},
+				{
+					name:          "already retry on 5xx",
+					retryPolicy:   &routev3.RetryPolicy{RetryOn: "5xx", NumRetries: wrapperspb.UInt32(2)},
+					expRetryOn:    "5xx",
+					expNumRetries: 2,
+				},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					// The rule 3 has the backends "ccc" and "ddd" without hedging.
+					route := &routev3.Route{
+						Name:   "httproute/ns/myroute/rule/3/match/0/example_com",
+						Action: &routev3.Route_Route{Route: &routev3.RouteAction{RetryPolicy: tc.retryPolicy}},
+					}
+					_, err := s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+						VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{route}},
+					})
+					require.NoError(t, err)
+					rp := route.GetRoute().RetryPolicy
+					require.Equal(t, tc.expRetryOn, rp.RetryOn)
+					require.Equal(t, tc.expStatusCodes, rp.RetriableStatusCodes)
+					require.Equal(t, tc.expNumRetries, rp.NumRetries.GetValue())
+
+					// Calling it again should be a no-op.
+					res, err := s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+						VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{route}},
+					})
+					require.NoError(t, err)
+					require.Nil(t, res)
+				})
+			}
+
+			// The rule without multiple backends is not modified.
+			route := &routev3.Route{
+				Name:   "httproute/ns/myroute/rule/0/match/0/example_com",
+				Action: &routev3.Route_Route{Route: &routev3.RouteAction{}},
+			}
+			res, err := s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+				VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{route}},
+			})
+			require.NoError(t, err)
+			require.Nil(t, res)
+		})
+		t.Run("hedging with user per-try timeout", func(t *testing.T) {
+			for _, tc := range []struct {
+				perTryTimeout time.Duration
+				exp           time.Duration
+			}{
+				{perTryTimeout: 200 * time.Millisecond, exp: 200 * time.Millisecond},
+				{perTryTimeout: 10 * time.Second, exp: 500 * time.Millisecond},
+			} {
+				route := &routev3.Route{
+					Name: "httproute/ns/myroute/rule/2/match/0/example_com",
+					Action: &routev3.Route_Route{Route: &routev3.RouteAction{
+						RetryPolicy: &routev3.RetryPolicy{RetryOn: "5xx", PerTryTimeout: durationpb.New(tc.perTryTimeout)},
+					}},
+				}
+				res, err := s.PostVirtualHostModify(t.Context(), &egextension.PostVirtualHostModifyRequest{
+					VirtualHost: &routev3.VirtualHost{Routes: []*routev3.Route{route}},
+				})
+				require.NoError(t, err)
+				require.NotNil(t, res)
+				action := res.VirtualHost.Routes[0].GetRoute()
+				require.True(t, action.HedgePolicy.HedgeOnPerTryTimeout)
+				require.Equal(t, "5xx", action.RetryPolicy.RetryOn)
+				require.Equal(t, tc.exp, action.RetryPolicy.PerTryTimeout.AsDuration())
+			}
+		})
+	})
 }
 
 func Test_maybeModifyCluster(t *testing.T) {
@@ -113,6 +315,10 @@ func Test_maybeModifyCluster(t *testing.T) {
 						{Name: "bbb", Priority: ptr.To[uint32](1)},
 					},
 				},
+				{
+					BackendRefs:     []aigv1a1.AIGatewayRouteRuleBackendRef{{Name: "ccc"}},
+					SessionAffinity: &aigv1a1.AIGatewayRouteRuleSessionAffinity{UserField: true},
+				},
 			},
 		},
 	})
@@ -182,5 +388,22 @@ func Test_maybeModifyCluster(t *testing.T) {
 		require.True(t, ok)
 		require.Len(t, mmd.Fields, 1)
 		require.Equal(t, "aaa.ns", mmd.Fields["backend_name"].GetStringValue())
+		require.Equal(t, clusterv3.Cluster_ROUND_ROBIN, cluster.LbPolicy)
+	})
+	t.Run("session affinity", func(t *testing.T) {
+		cluster := &clusterv3.Cluster{
+			Name:     "httproute/ns/myroute/rule/1",
+			LbPolicy: clusterv3.Cluster_LEAST_REQUEST,
+			LbConfig: &clusterv3.Cluster_LeastRequestLbConfig_{LeastRequestLbConfig: &clusterv3.Cluster_LeastRequestLbConfig{}},
+			LoadAssignment: &endpointv3.ClusterLoadAssignment{
+				Endpoints: []*endpointv3.LocalityLbEndpoints{{LbEndpoints: []*endpointv3.LbEndpoint{{}}}},
+			},
+		}
+		var buf bytes.Buffer
+		s := New(c, logr.FromSlogHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{})), udsPath)
+		s.maybeModifyCluster(cluster)
+		require.Empty(t, buf.String())
+		require.Equal(t, clusterv3.Cluster_MAGLEV, cluster.LbPolicy)
+		require.Nil(t, cluster.LbConfig)
 	})
 }
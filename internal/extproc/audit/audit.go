@@ -0,0 +1,163 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+// Package audit implements the audit log that writes the prompts and the completions of the sampled requests of
+// the route rules with the audit log enabled to a [Sink], which answers who asked what and what the model answered.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/envoyproxy/ai-gateway/internal/extproc/batchwriter"
+)
+
+// Entry is the request and the response captured by the external processor, which is turned into a [Record]
+// by the [Logger] in the background so that assembling and redacting the bodies stay off the request path.
+type Entry struct {
+	// Time is the time when the response is completed.
+	Time time.Time
+	// RequestID is the x-request-id header of the request.
+	RequestID string
+	// Route is the name of the route rule that selected the backend.
+	Route string
+	// Consumer is the consumer authenticated with the consumer key, if any.
+	Consumer string
+	// Model is the name of the model sent to the backend.
+	Model string
+	// Backend is the name of the backend that served the request.
+	Backend string
+	// Status is the HTTP status code of the response.
+	Status int
+	// Stream is true if the response body is the server-sent events of a streaming response.
+	Stream bool
+	// RequestBody and ResponseBody are the raw bodies of the request and the response sent to the client.
+	RequestBody, ResponseBody []byte
+	// RedactFields are the paths of the fields of the [Record] replaced with [Redacted].
+	// See [Redact] for the syntax.
+	RedactFields []string
+}
+
+// Record is the audit record of a request.
+type Record struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Route     string    `json:"route"`
+	Consumer  string    `json:"consumer,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	Backend   string    `json:"backend,omitempty"`
+	Status    int       `json:"status"`
+	Stream    bool      `json:"stream,omitempty"`
+	// Request is the request body.
+	Request json.RawMessage `json:"request,omitempty"`
+	// Response is the response body, where the chunks of the streaming response are assembled into a single
+	// chat completion object. The response body that is not JSON is recorded as a JSON string.
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// Sink is the destination of the audit records.
+//
+// Write is only called by a single goroutine of the [Logger], so the implementations do not need to be
+// safe for concurrent use.
+type Sink interface {
+	// Write writes the batch of the records.
+	Write(ctx context.Context, records []Record) error
+	// Close flushes the pending records and releases the resources.
+	Close(ctx context.Context) error
+}
+
+// NewSink creates a [Sink] from the given specification:
+//
+//   - "file:///path/to/audit.jsonl?maxSizeMB=100&maxBackups=5" writes the records to the JSONL file rotated at maxSizeMB.
+//   - "otlp" exports the records as OTLP logs configured with the standard OTEL_EXPORTER_OTLP_* environment variables.
+func NewSink(ctx context.Context, spec string) (Sink, error) {
+	if spec == "otlp" {
+		return NewOTLPSink(ctx)
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit log sink %q: %w", spec, err)
+	}
+	if u.Scheme != "file" {
+		return nil, fmt.Errorf("unsupported audit log sink %q", spec)
+	}
+	fs, err := batchwriter.ParseFileSpec(u, "audit log sink")
+	if err != nil {
+		return nil, err
+	}
+	return NewFileSink(fs.Path, fs.MaxSize, fs.MaxBackups)
+}
+
+// NewFileSink creates a new [Sink] appending the records to the JSONL file at the path rotated at maxSize.
+func NewFileSink(path string, maxSize int64, maxBackups int) (Sink, error) {
+	return batchwriter.NewJSONLFile[Record](path, maxSize, maxBackups)
+}
+
+const (
+	// defaultQueueSize is smaller than the one of the usage ledger since the entries hold the whole bodies.
+	defaultQueueSize     = 1000
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+// Logger writes the audit records to the [Sink] in the background.
+//
+// The entries are queued in memory and written in batches. When the queue is full because the sink cannot keep up,
+// the entries are dropped instead of blocking the request path, and the number of dropped entries is logged.
+type Logger struct {
+	sink   Sink
+	logger *slog.Logger
+	queue  *batchwriter.Queue[*Entry]
+}
+
+// New creates a new Logger writing to the sink and starts its background goroutine.
+func New(sink Sink, logger *slog.Logger) *Logger {
+	return newLogger(sink, logger, defaultQueueSize, defaultBatchSize, defaultFlushInterval)
+}
+
+func newLogger(sink Sink, logger *slog.Logger, queueSize, batchSize int, flushInterval time.Duration) *Logger {
+	l := &Logger{sink: sink, logger: logger}
+	l.queue = batchwriter.NewQueue("audit records", l.write, logger, queueSize, batchSize, flushInterval)
+	return l
+}
+
+// Log queues the entry without blocking. The entry must not be modified afterwards.
+// This is a no-op on a nil Logger.
+func (l *Logger) Log(e *Entry) {
+	if l == nil {
+		return
+	}
+	l.queue.Add(e)
+}
+
+// Close writes the queued entries and closes the sink.
+func (l *Logger) Close(ctx context.Context) error {
+	if err := l.queue.Close(ctx); err != nil {
+		return err
+	}
+	return l.sink.Close(ctx)
+}
+
+// write turns the batch of the entries into the records and writes them to the sink. The entries failing to be
+// turned into the records are logged and skipped.
+func (l *Logger) write(ctx context.Context, entries []*Entry) error {
+	records := make([]Record, 0, len(entries))
+	for _, e := range entries {
+		rec, err := NewRecord(e)
+		if err != nil {
+			l.logger.Error("failed to create audit record", slog.String("request_id", e.RequestID), slog.String("error", err.Error()))
+			continue
+		}
+		records = append(records, *rec)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	return l.sink.Write(ctx, records)
+}
@@ -0,0 +1,118 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package audit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink is a [Sink] that records the written batches, optionally blocking until unblock is closed.
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]Record
+	unblock chan struct{}
+	closed  bool
+}
+
+func (r *recordingSink) Write(_ context.Context, records []Record) error {
+	if r.unblock != nil {
+		<-r.unblock
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]Record(nil), records...))
+	return nil
+}
+
+func (r *recordingSink) Close(context.Context) error {
+	r.closed = true
+	return nil
+}
+
+func (r *recordingSink) records() (ret []Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.batches {
+		ret = append(ret, b...)
+	}
+	return
+}
+
+func TestLogger(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("batch and close", func(t *testing.T) {
+		sink := &recordingSink{}
+		l := newLogger(sink, logger, 10, 2, time.Hour)
+		l.Log(&Entry{RequestID: "a", RequestBody: []byte(`{"model":"m"}`), RedactFields: []string{"request.model"}})
+		l.Log(&Entry{RequestID: "b"})
+		// The entry failing to be redacted is logged and skipped.
+		l.Log(&Entry{RequestID: "c", RedactFields: []string{"consumer"}})
+		l.Log(&Entry{RequestID: "d"})
+		// The entries are written as the batches of two, where the batch of "c" and "d" only has the record of "d".
+		require.Eventually(t, func() bool { return len(sink.records()) == 3 }, time.Second, 10*time.Millisecond)
+		require.NoError(t, l.Close(t.Context()))
+		require.True(t, sink.closed)
+		require.Len(t, sink.batches, 2)
+		require.Equal(t, []Record{
+			{RequestID: "a", Request: []byte(`{"model":"[REDACTED]"}`)},
+			{RequestID: "b"},
+			{RequestID: "d"},
+		}, sink.records())
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var l *Logger
+		l.Log(&Entry{})
+	})
+}
+
+func TestNewSink(t *testing.T) {
+	for _, tc := range []struct {
+		name, spec, expErr string
+	}{
+		{name: "file", spec: "file://" + filepath.Join(t.TempDir(), "audit.jsonl") + "?maxSizeMB=1&maxBackups=2"},
+		{name: "invalid maxSizeMB", spec: "file:///tmp/audit.jsonl?maxSizeMB=0", expErr: `invalid maxSizeMB "0" of audit log sink`},
+		{name: "invalid maxBackups", spec: "file:///tmp/audit.jsonl?maxBackups=x", expErr: `invalid maxBackups "x" of audit log sink`},
+		{name: "unsupported", spec: "https://example.com/audit", expErr: `unsupported audit log sink "https://example.com/audit"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sink, err := NewSink(t.Context(), tc.spec)
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, sink.Close(t.Context()))
+		})
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	sink, err := NewFileSink(path, 1<<20, 1)
+	require.NoError(t, err)
+	require.NoError(t, sink.Write(t.Context(), []Record{
+		{RequestID: "a", Route: "r", Status: 200, Request: []byte(`{"model":"m"}`), Response: []byte(`"error"`)},
+		{RequestID: "b", Route: "r", Status: 500},
+	}))
+	require.NoError(t, sink.Close(t.Context()))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t,
+		`{"time":"0001-01-01T00:00:00Z","request_id":"a","route":"r","status":200,"request":{"model":"m"},"response":"error"}`+"\n"+
+			`{"time":"0001-01-01T00:00:00Z","request_id":"b","route":"r","status":500}`+"\n",
+		string(content))
+}
@@ -0,0 +1,70 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package audit
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// otlpEventName is the event name of the audit log records.
+const otlpEventName = "aigw.audit"
+
+// otlpSink implements [Sink] by exporting the records as OTLP log records, where the request and the response
+// bodies are the attributes of the JSON strings.
+type otlpSink struct {
+	provider *sdklog.LoggerProvider
+	logger   log.Logger
+}
+
+// NewOTLPSink creates a new [Sink] exporting the records with the OTLP/HTTP log exporter, which is configured
+// with the standard OTEL_EXPORTER_OTLP_* environment variables.
+func NewOTLPSink(ctx context.Context) (Sink, error) {
+	exporter, err := otlploghttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %w", err)
+	}
+	return newOTLPSink(exporter), nil
+}
+
+func newOTLPSink(exporter sdklog.Exporter) *otlpSink {
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)))
+	return &otlpSink{provider: provider, logger: provider.Logger("github.com/envoyproxy/ai-gateway/audit")}
+}
+
+// Write implements [Sink.Write].
+func (o *otlpSink) Write(ctx context.Context, records []Record) error {
+	for i := range records {
+		rec := &records[i]
+		var r log.Record
+		r.SetTimestamp(rec.Time)
+		r.SetEventName(otlpEventName)
+		r.SetSeverity(log.SeverityInfo)
+		r.SetBody(log.StringValue(otlpEventName))
+		r.AddAttributes(
+			log.String("request_id", rec.RequestID),
+			log.String("route", rec.Route),
+			log.String("consumer", rec.Consumer),
+			log.String("gen_ai.request.model", rec.Model),
+			log.String("backend", rec.Backend),
+			log.Int("http.response.status_code", rec.Status),
+			log.Bool("stream", rec.Stream),
+			log.String("request", string(rec.Request)),
+			log.String("response", string(rec.Response)),
+		)
+		o.logger.Emit(ctx, r)
+	}
+	return nil
+}
+
+// Close implements [Sink.Close].
+func (o *otlpSink) Close(ctx context.Context) error {
+	return o.provider.Shutdown(ctx)
+}
@@ -0,0 +1,64 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package audit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// memoryExporter is a [sdklog.Exporter] that keeps the exported records in memory.
+type memoryExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (m *memoryExporter) Export(_ context.Context, records []sdklog.Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range records {
+		m.records = append(m.records, r.Clone())
+	}
+	return nil
+}
+
+func (m *memoryExporter) Shutdown(context.Context) error   { return nil }
+func (m *memoryExporter) ForceFlush(context.Context) error { return nil }
+
+func TestOTLPSink(t *testing.T) {
+	exporter := &memoryExporter{}
+	sink := newOTLPSink(exporter)
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, sink.Write(t.Context(), []Record{{
+		Time: now, RequestID: "a", Route: "r", Consumer: "team-a", Model: "gpt-4o", Backend: "openai", Status: 200,
+		Request: []byte(`{"model":"gpt-4o"}`), Response: []byte(`{"object":"chat.completion"}`),
+	}}))
+	// Shutdown flushes the batch processor.
+	require.NoError(t, sink.Close(t.Context()))
+
+	require.Len(t, exporter.records, 1)
+	r := exporter.records[0]
+	require.Equal(t, now, r.Timestamp())
+	require.Equal(t, otlpEventName, r.EventName())
+	attrs := map[string]log.Value{}
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	require.Equal(t, "a", attrs["request_id"].AsString())
+	require.Equal(t, "r", attrs["route"].AsString())
+	require.Equal(t, "team-a", attrs["consumer"].AsString())
+	require.Equal(t, "gpt-4o", attrs["gen_ai.request.model"].AsString())
+	require.Equal(t, int64(200), attrs["http.response.status_code"].AsInt64())
+	require.JSONEq(t, `{"model":"gpt-4o"}`, attrs["request"].AsString())
+	require.JSONEq(t, `{"object":"chat.completion"}`, attrs["response"].AsString())
+}
@@ -0,0 +1,244 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"k8s.io/utils/ptr"
+)
+
+// Redacted is the value that replaces the redacted fields.
+const Redacted = "[REDACTED]"
+
+// NewRecord creates the audit record of the entry by assembling the chunks of the streaming response and
+// redacting the fields.
+func NewRecord(e *Entry) (*Record, error) {
+	rec := &Record{
+		Time:      e.Time,
+		RequestID: e.RequestID,
+		Route:     e.Route,
+		Consumer:  e.Consumer,
+		Model:     e.Model,
+		Backend:   e.Backend,
+		Status:    e.Status,
+		Stream:    e.Stream,
+		Request:   rawJSON(e.RequestBody),
+		Response:  rawJSON(e.ResponseBody),
+	}
+	if e.Stream {
+		if assembled, ok := AssembleChatCompletion(e.ResponseBody); ok {
+			rec.Response = assembled
+		}
+	}
+	for _, field := range e.RedactFields {
+		var err error
+		switch body, path, _ := strings.Cut(field, "."); body {
+		case "request":
+			rec.Request, err = Redact(rec.Request, path)
+		case "response":
+			rec.Response, err = Redact(rec.Response, path)
+		default:
+			err = fmt.Errorf("unknown field %q", body)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to redact %s: %w", field, err)
+		}
+	}
+	return rec, nil
+}
+
+// rawJSON returns the body as is if it is JSON, or as a JSON string otherwise.
+func rawJSON(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return bytes.Clone(body)
+	}
+	b, _ := json.Marshal(string(body))
+	return b
+}
+
+// Redact replaces the values at the path of the JSON document with [Redacted]. The path is the keys of the objects
+// and the indexes of the arrays separated by dots, where "*" matches every element of an array or every field of an
+// object, e.g. "messages.*.content". The empty path replaces the whole document. The missing fields are ignored.
+func Redact(doc []byte, path string) ([]byte, error) {
+	if len(doc) == 0 {
+		return doc, nil
+	}
+	if path == "" {
+		return json.Marshal(Redacted)
+	}
+	var paths []string
+	expandPath(gjson.ParseBytes(doc), strings.Split(path, "."), "", &paths)
+	var err error
+	for _, p := range paths {
+		if doc, err = sjson.SetBytes(doc, p, Redacted); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// expandPath appends the concrete paths of the values matching the segments under v at the prefix.
+func expandPath(v gjson.Result, segments []string, prefix string, paths *[]string) {
+	if !v.Exists() {
+		return
+	}
+	if len(segments) == 0 {
+		*paths = append(*paths, prefix)
+		return
+	}
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	seg, rest := segments[0], segments[1:]
+	switch {
+	case seg == "*" && v.IsArray():
+		for i, child := range v.Array() {
+			expandPath(child, rest, join(strconv.Itoa(i)), paths)
+		}
+	case seg == "*" && v.IsObject():
+		v.ForEach(func(key, child gjson.Result) bool {
+			expandPath(child, rest, join(gjson.Escape(key.String())), paths)
+			return true
+		})
+	case v.IsArray() || v.IsObject():
+		key := gjson.Escape(seg)
+		expandPath(v.Get(key), rest, join(key), paths)
+	}
+}
+
+// AssembleChatCompletion assembles the server-sent events of a streaming chat completion response into a single
+// chat completion object, and returns false if the body does not contain any chunk.
+//
+// The contents and the tool call arguments of the deltas are concatenated per choice, and the usage is taken from
+// the chunk with the usage, which is the last one when the stream options include the usage.
+func AssembleChatCompletion(body []byte) ([]byte, bool) {
+	type toolCall struct {
+		ID       string `json:"id,omitempty"`
+		Type     string `json:"type,omitempty"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	}
+	type message struct {
+		Role      string      `json:"role"`
+		Content   *string     `json:"content"`
+		ToolCalls []*toolCall `json:"tool_calls,omitempty"`
+	}
+	type choice struct {
+		Index        int64   `json:"index"`
+		Message      message `json:"message"`
+		FinishReason string  `json:"finish_reason,omitempty"`
+		// content is the concatenated contents, and hasContent is true if any delta has the content.
+		content    strings.Builder
+		hasContent bool
+		// toolCalls is the index of the tool calls in the message.
+		toolCalls map[int64]*toolCall
+	}
+	completion := struct {
+		ID                string          `json:"id,omitempty"`
+		Object            string          `json:"object"`
+		Created           int64           `json:"created,omitempty"`
+		Model             string          `json:"model,omitempty"`
+		SystemFingerprint string          `json:"system_fingerprint,omitempty"`
+		Choices           []*choice       `json:"choices"`
+		Usage             json.RawMessage `json:"usage,omitempty"`
+	}{Object: "chat.completion", Choices: []*choice{}}
+
+	choices := map[int64]*choice{}
+	var chunks int
+	for line := range bytes.SplitSeq(body, []byte("\n")) {
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if !gjson.ValidBytes(data) || !gjson.GetBytes(data, "choices").IsArray() {
+			continue // Such as "[DONE]".
+		}
+		chunks++
+		chunk := gjson.ParseBytes(data)
+		if v := chunk.Get("id").String(); v != "" {
+			completion.ID = v
+		}
+		if v := chunk.Get("created").Int(); v != 0 {
+			completion.Created = v
+		}
+		if v := chunk.Get("model").String(); v != "" {
+			completion.Model = v
+		}
+		if v := chunk.Get("system_fingerprint").String(); v != "" {
+			completion.SystemFingerprint = v
+		}
+		if usage := chunk.Get("usage"); usage.IsObject() {
+			completion.Usage = json.RawMessage(usage.Raw)
+		}
+		for _, c := range chunk.Get("choices").Array() {
+			index := c.Get("index").Int()
+			ch, ok := choices[index]
+			if !ok {
+				ch = &choice{Index: index, Message: message{Role: "assistant"}, toolCalls: map[int64]*toolCall{}}
+				choices[index] = ch
+				completion.Choices = append(completion.Choices, ch)
+			}
+			if v := c.Get("delta.role").String(); v != "" {
+				ch.Message.Role = v
+			}
+			if content := c.Get("delta.content"); content.Type == gjson.String {
+				ch.content.WriteString(content.String())
+				ch.hasContent = true
+			}
+			for _, tc := range c.Get("delta.tool_calls").Array() {
+				tcIndex := tc.Get("index").Int()
+				call, ok := ch.toolCalls[tcIndex]
+				if !ok {
+					call = &toolCall{}
+					ch.toolCalls[tcIndex] = call
+					ch.Message.ToolCalls = append(ch.Message.ToolCalls, call)
+				}
+				if v := tc.Get("id").String(); v != "" {
+					call.ID = v
+				}
+				if v := tc.Get("type").String(); v != "" {
+					call.Type = v
+				}
+				call.Function.Name += tc.Get("function.name").String()
+				call.Function.Arguments += tc.Get("function.arguments").String()
+			}
+			if v := c.Get("finish_reason").String(); v != "" {
+				ch.FinishReason = v
+			}
+		}
+	}
+	if chunks == 0 {
+		return nil, false
+	}
+	for _, ch := range completion.Choices {
+		if ch.hasContent {
+			ch.Message.Content = ptr.To(ch.content.String())
+		}
+	}
+	slices.SortFunc(completion.Choices, func(a, b *choice) int { return int(a.Index - b.Index) })
+	b, err := json.Marshal(&completion)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
@@ -0,0 +1,90 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecord(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	t.Run("stream", func(t *testing.T) {
+		rec, err := NewRecord(&Entry{
+			Time: now, RequestID: "id", Route: "route", Consumer: "team-a", Model: "gpt-4o", Backend: "openai",
+			Status: 200, Stream: true,
+			RequestBody: []byte(`{"model":"gpt-4o","stream":true,"messages":[{"role":"system","content":"s"},{"role":"user","content":"secret"}]}`),
+			ResponseBody: []byte("data: {\"id\":\"c\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hel\"}}]}\n\n" +
+				"data: {\"id\":\"c\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n" +
+				"data: [DONE]\n\n"),
+			RedactFields: []string{"request.messages.*.content", "response.choices.0.message.content", "request.missing.*"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, &Record{
+			Time: now, RequestID: "id", Route: "route", Consumer: "team-a", Model: "gpt-4o", Backend: "openai",
+			Status: 200, Stream: true,
+			Request: []byte(`{"model":"gpt-4o","stream":true,"messages":[{"role":"system","content":"[REDACTED]"},{"role":"user","content":"[REDACTED]"}]}`),
+			Response: []byte(`{"id":"c","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"[REDACTED]"},` +
+				`"finish_reason":"stop"}]}`),
+		}, rec)
+	})
+
+	t.Run("error response", func(t *testing.T) {
+		rec, err := NewRecord(&Entry{Status: 503, Stream: true, ResponseBody: []byte("upstream connect error"), RedactFields: []string{"request"}})
+		require.NoError(t, err)
+		require.Nil(t, rec.Request)
+		require.JSONEq(t, `"upstream connect error"`, string(rec.Response))
+	})
+
+	t.Run("whole body", func(t *testing.T) {
+		rec, err := NewRecord(&Entry{RequestBody: []byte(`{"model":"m"}`), RedactFields: []string{"request"}})
+		require.NoError(t, err)
+		require.JSONEq(t, `"[REDACTED]"`, string(rec.Request))
+	})
+}
+
+func TestRedact(t *testing.T) {
+	for _, tc := range []struct {
+		name, doc, path, exp string
+	}{
+		{name: "key", doc: `{"a":{"b":1,"c":2}}`, path: "a.b", exp: `{"a":{"b":"[REDACTED]","c":2}}`},
+		{name: "array wildcard", doc: `{"a":[{"b":1},{"c":2},{"b":3}]}`, path: "a.*.b", exp: `{"a":[{"b":"[REDACTED]"},{"c":2},{"b":"[REDACTED]"}]}`},
+		{name: "object wildcard", doc: `{"a":{"x.y":{"b":1},"z":{"b":2}}}`, path: "a.*.b", exp: `{"a":{"x.y":{"b":"[REDACTED]"},"z":{"b":"[REDACTED]"}}}`},
+		{name: "index", doc: `{"a":[1,2]}`, path: "a.1", exp: `{"a":[1,"[REDACTED]"]}`},
+		{name: "missing", doc: `{"a":1}`, path: "a.b.c", exp: `{"a":1}`},
+		{name: "whole", doc: `{"a":1}`, path: "", exp: `"[REDACTED]"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Redact([]byte(tc.doc), tc.path)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.exp, string(got))
+		})
+	}
+}
+
+func TestAssembleChatCompletion(t *testing.T) {
+	body := "data: {\"id\":\"c\",\"created\":1,\"model\":\"gpt-4o\",\"choices\":[{\"index\":1,\"delta\":{\"role\":\"assistant\",\"content\":\"B\"}}," +
+		"{\"index\":0,\"delta\":{\"role\":\"assistant\",\"tool_calls\":[{\"index\":0,\"id\":\"call\",\"type\":\"function\",\"function\":{\"name\":\"f\",\"arguments\":\"{\\\"a\\\"\"}}]}}]}\n\n" +
+		"data: {\"id\":\"c\",\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\":1}\"}}]},\"finish_reason\":\"tool_calls\"}," +
+		"{\"index\":1,\"delta\":{\"content\":\"C\"},\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: {\"id\":\"c\",\"choices\":[],\"usage\":{\"prompt_tokens\":1,\"completion_tokens\":2,\"total_tokens\":3}}\n\n" +
+		"data: [DONE]\n\n"
+	got, ok := AssembleChatCompletion([]byte(body))
+	require.True(t, ok)
+	require.JSONEq(t, `{
+		"id":"c","object":"chat.completion","created":1,"model":"gpt-4o",
+		"choices":[
+			{"index":0,"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call","type":"function","function":{"name":"f","arguments":"{\"a\":1}"}}]},"finish_reason":"tool_calls"},
+			{"index":1,"message":{"role":"assistant","content":"BC"},"finish_reason":"stop"}
+		],
+		"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3}
+	}`, string(got))
+
+	_, ok = AssembleChatCompletion([]byte(`{"error":{"message":"bad"}}`))
+	require.False(t, ok)
+}
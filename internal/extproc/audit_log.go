@@ -0,0 +1,73 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"math/rand/v2"
+	"time"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/tidwall/gjson"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/audit"
+)
+
+// startAuditLog starts capturing the request and the response for the audit log if the rule has the audit log and
+// the request is sampled. Otherwise, the response is never buffered for the audit log.
+//
+// This is called as soon as the route is known so that the requests answered with an immediate response, such as
+// the rejections and the cache hits, are also recorded. The request body of the entry is replaced as the request is
+// mutated.
+func (c *chatCompletionProcessorRouterFilter) startAuditLog(rule *filterapi.RouteRule, model string, raw []byte) {
+	c.auditEntry = nil
+	if c.auditLogger == nil || rule.AuditLog == nil {
+		return
+	}
+	if rand.Float64() >= rule.AuditLog.SamplingRate { // #nosec G404: Sampling does not need a secure random number.
+		return
+	}
+	c.auditEntry = &audit.Entry{
+		RequestID:    c.requestHeaders["x-request-id"],
+		Route:        string(rule.Name),
+		Model:        model,
+		Stream:       gjson.GetBytes(raw, "stream").Bool(),
+		RequestBody:  raw,
+		RedactFields: rule.AuditLog.RedactFields,
+	}
+}
+
+// finishAuditLog queues the audit log entry with the immediate response returned to the client instead of the
+// response of the backend.
+func (c *chatCompletionProcessorRouterFilter) finishAuditLog(resp *extprocv3.ImmediateResponse) {
+	e := c.auditEntry
+	e.Consumer = c.consumer
+	e.Status = int(resp.GetStatus().GetCode())
+	e.ResponseBody = resp.GetBody()
+	e.Time = time.Now()
+	c.auditLogger.Log(e)
+	c.auditEntry = nil
+}
+
+// appendAuditLogBody appends the chunk of the response body sent to the client, and queues the audit log entry at
+// the end of the stream.
+func (c *chatCompletionProcessorRouterFilter) appendAuditLogBody(resp *extprocv3.ProcessingResponse, body *extprocv3.HttpBody) {
+	e := c.auditEntry
+	if b := resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody(); b != nil {
+		e.ResponseBody = append(e.ResponseBody, b...)
+	} else {
+		e.ResponseBody = append(e.ResponseBody, body.Body...)
+	}
+	if body.EndOfStream {
+		if uf, ok := c.upstreamFilter.(*chatCompletionProcessorUpstreamFilter); ok {
+			e.Backend, e.Model = uf.backendName, uf.backendModel()
+		}
+		e.Consumer = c.consumer
+		e.Time = time.Now()
+		c.auditLogger.Log(e)
+		c.auditEntry = nil
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/audit"
+)
+
+// auditSink is an [audit.Sink] that keeps the written records in memory.
+type auditSink struct {
+	records []audit.Record
+}
+
+func (s *auditSink) Write(_ context.Context, records []audit.Record) error {
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func (s *auditSink) Close(context.Context) error { return nil }
+
+func TestChatCompletion_auditLog(t *testing.T) {
+	// send sends the request through the router filter and the chunks of the response through the upstream filter,
+	// and returns the audit records written.
+	send := func(t *testing.T, auditLog *filterapi.AuditLog, reqBody string, respChunks ...string) []audit.Record {
+		sink := &auditSink{}
+		logger := audit.New(sink, slog.Default())
+		headers := map[string]string{":path": "/v1/chat/completions", "x-request-id": "req"}
+		config := &processorConfig{
+			modelNameHeaderKey:     "x-model-name",
+			selectedRouteHeaderKey: "x-route",
+			router:                 mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+			rules: map[filterapi.RouteRuleName]*filterapi.RouteRule{
+				"some-route": {Name: "some-route", AuditLog: auditLog},
+			},
+		}
+		rp := &chatCompletionProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default(), auditLogger: logger}
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(reqBody)})
+		require.NoError(t, err)
+		// The sampled response must not be compressed by the backend to be recorded as JSON.
+		removeHeaders := resp.GetRequestBody().GetResponse().GetHeaderMutation().GetRemoveHeaders()
+		require.Equal(t, rp.auditEntry != nil, slices.Contains(removeHeaders, "accept-encoding"))
+		uf := &chatCompletionProcessorUpstreamFilter{
+			config:         config,
+			requestHeaders: map[string]string{":path": "/v1/chat/completions"},
+			logger:         slog.Default(),
+			metrics:        &mockChatCompletionMetrics{},
+		}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI}, ModelNameOverride: "override",
+		}, nil, rp))
+		_, err = uf.ProcessRequestHeaders(t.Context(), nil)
+		require.NoError(t, err)
+		_, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "200"}}})
+		require.NoError(t, err)
+		for i, chunk := range respChunks {
+			_, err = rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{Body: []byte(chunk), EndOfStream: i == len(respChunks)-1})
+			require.NoError(t, err)
+		}
+		require.Nil(t, rp.auditEntry)
+		require.NoError(t, logger.Close(t.Context()))
+		return sink.records
+	}
+
+	t.Run("stream", func(t *testing.T) {
+		records := send(t, &filterapi.AuditLog{SamplingRate: 1, RedactFields: []string{"request.messages.*.content"}},
+			`{"model":"m","stream":true,"messages":[{"role":"user","content":"secret"}]}`,
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hello\"}}]}\n\n",
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\" world\"},\"finish_reason\":\"stop\"}]}\n\n",
+			"data: [DONE]\n\n")
+		require.Len(t, records, 1)
+		rec := records[0]
+		require.NotZero(t, rec.Time)
+		require.Equal(t, "req", rec.RequestID)
+		require.Equal(t, "some-route", rec.Route)
+		require.Equal(t, "override", rec.Model)
+		require.Equal(t, "backend", rec.Backend)
+		require.Equal(t, 200, rec.Status)
+		require.True(t, rec.Stream)
+		require.JSONEq(t, `{"model":"m","stream":true,"messages":[{"role":"user","content":"[REDACTED]"}]}`, string(rec.Request))
+		require.JSONEq(t, `{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hello world"},"finish_reason":"stop"}]}`,
+			string(rec.Response))
+	})
+
+	t.Run("not sampled", func(t *testing.T) {
+		require.Empty(t, send(t, &filterapi.AuditLog{SamplingRate: 0}, `{"model":"m","messages":[]}`, `{"choices":[]}`))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		require.Empty(t, send(t, nil, `{"model":"m","messages":[]}`, `{"choices":[]}`))
+	})
+
+	// sendImmediate sends the request answered with an immediate response through the router filter, and returns
+	// the audit records written.
+	sendImmediate := func(t *testing.T, rule *filterapi.RouteRule, rc *ResponseCache, reqBody string) []audit.Record {
+		sink := &auditSink{}
+		logger := audit.New(sink, slog.Default())
+		headers := map[string]string{":path": "/v1/chat/completions", "x-request-id": "req"}
+		config := &processorConfig{
+			modelNameHeaderKey:     "x-model-name",
+			selectedRouteHeaderKey: "x-route",
+			router:                 mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+			rules:                  map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule},
+		}
+		rp := &chatCompletionProcessorRouterFilter{
+			config: config, requestHeaders: headers, logger: slog.Default(), auditLogger: logger, responseCache: rc,
+		}
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(reqBody)})
+		require.NoError(t, err)
+		require.NotNil(t, resp.GetImmediateResponse())
+		require.Nil(t, rp.auditEntry)
+		require.NoError(t, logger.Close(t.Context()))
+		return sink.records
+	}
+
+	t.Run("cache hit", func(t *testing.T) {
+		const reqBody = `{"model":"m","temperature":0,"messages":[{"role":"user","content":"hi"}]}`
+		rule := &filterapi.RouteRule{
+			Name:          "some-route",
+			AuditLog:      &filterapi.AuditLog{SamplingRate: 1},
+			ResponseCache: &filterapi.ResponseCache{TTL: time.Minute},
+		}
+		var body openai.ChatCompletionRequest
+		require.NoError(t, json.Unmarshal([]byte(reqBody), &body))
+		key, _, err := responseCacheKey(rule.ResponseCache, rule.Name, "m", "", map[string]string{}, []byte(reqBody), &body)
+		require.NoError(t, err)
+		rc := NewResponseCache(1<<20, nil)
+		rc.put(t.Context(), key, []byte(`{"choices":[]}`), false, time.Minute, "", nil)
+
+		records := sendImmediate(t, rule, rc, reqBody)
+		require.Len(t, records, 1)
+		require.Equal(t, "some-route", records[0].Route)
+		require.Equal(t, "m", records[0].Model)
+		require.Equal(t, 200, records[0].Status)
+		require.JSONEq(t, reqBody, string(records[0].Request))
+		require.JSONEq(t, `{"choices":[]}`, string(records[0].Response))
+	})
+
+	t.Run("guardrail block", func(t *testing.T) {
+		s, _ := newModerationServer(t, `{"results":[{"flagged":true,"category_scores":{"violence":0.9}}]}`)
+		const reqBody = `{"model":"m","messages":[{"role":"user","content":"bad prompt"}]}`
+		records := sendImmediate(t, &filterapi.RouteRule{
+			Name:     "some-route",
+			AuditLog: &filterapi.AuditLog{SamplingRate: 1},
+			Guardrails: []filterapi.Guardrail{{
+				Name: "g", URL: s.URL, Path: "/v1/moderations", Type: filterapi.GuardrailTypeOpenAIModeration,
+				Input: true, Action: filterapi.GuardrailActionBlock,
+			}},
+		}, nil, reqBody)
+		require.Len(t, records, 1)
+		require.Equal(t, 200, records[0].Status)
+		require.JSONEq(t, reqBody, string(records[0].Request))
+		require.Contains(t, string(records[0].Response), `"finish_reason":"content_filter"`)
+	})
+}
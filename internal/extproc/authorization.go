@@ -0,0 +1,71 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/authzcel"
+)
+
+// authorizeRequest authenticates the consumer of the request with the consumer key if the given rule requires one,
+// and evaluates the authorization of the rule for the model, if any. The authenticated consumer is returned, or
+// the immediate response if the request is rejected.
+func authorizeRequest(config *processorConfig, logger *slog.Logger, headers map[string]string, routeName filterapi.RouteRuleName, model string) (consumer string, resp *extprocv3.ProcessingResponse) {
+	rule, ok := config.rules[routeName]
+	if !ok {
+		return "", nil
+	}
+	if rule.RequireConsumerKey {
+		key, resp := authenticateConsumer(config, headers, routeName, model, time.Now())
+		if resp != nil {
+			logger.Debug("request rejected by the consumer key", "route", routeName, "model", model)
+			return "", resp
+		}
+		consumer = key.Consumer
+	}
+	if allowed, err := modelAllowed(config, headers, rule, model, consumer); err != nil {
+		// The request is denied as the policy cannot be evaluated, e.g. a claim it expects is missing.
+		logger.Info("failed to evaluate authorization", "route", routeName, "model", model, "error", err)
+		return "", modelNotAllowedResponse(model)
+	} else if !allowed {
+		logger.Debug("request rejected by the authorization", "route", routeName, "model", model)
+		return "", modelNotAllowedResponse(model)
+	}
+	return consumer, nil
+}
+
+// modelAllowed evaluates the authorization of the rule for the request to the model by the consumer, and returns
+// true if the rule has no authorization.
+func modelAllowed(config *processorConfig, headers map[string]string, rule *filterapi.RouteRule, model, consumer string) (bool, error) {
+	prog, ok := config.authorizations[rule.Name]
+	if !ok || rule.Authorization == nil {
+		return true, nil
+	}
+	claims, err := authzcel.ParseClaims(headers[rule.Authorization.ClaimsHeader])
+	if err != nil {
+		return false, err
+	}
+	return authzcel.EvaluateProgram(prog, &authzcel.Input{
+		Model:          model,
+		Route:          rule.Authorization.Route,
+		Consumer:       consumer,
+		Claims:         claims,
+		RequestHeaders: headers,
+	})
+}
+
+// modelNotAllowedResponse returns the OpenAI-compatible 403 response for the model the caller may not use.
+func modelNotAllowedResponse(model string) *extprocv3.ProcessingResponse {
+	return openAIErrorResponse(typev3.StatusCode_Forbidden, "invalid_request_error", "model_not_allowed", "model",
+		fmt.Sprintf("You are not allowed to use the model %s.", model))
+}
@@ -0,0 +1,109 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"encoding/base64"
+	"log/slog"
+	"testing"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/authzcel"
+)
+
+func mustNewAuthzProgram(t *testing.T, expr string) cel.Program {
+	prog, err := authzcel.NewProgram(expr)
+	require.NoError(t, err)
+	return prog
+}
+
+func Test_authorizeRequest(t *testing.T) {
+	config := newConsumerKeyConfig()
+	const expr = `route == "chat" && (claims[?"team"].orValue("") == "a" ? model != "o1" :
+		"interns" in claims[?"groups"].orValue([]) ? model.endsWith("-mini") : consumer == "alice")`
+	config.rules = map[filterapi.RouteRuleName]*filterapi.RouteRule{
+		"some-route": {Name: "some-route", RequireConsumerKey: true, Authorization: &filterapi.Authorization{
+			Route: "chat", ClaimsHeader: "x-jwt-claims", CEL: expr,
+		}},
+		"claims-only": {Name: "claims-only", Authorization: &filterapi.Authorization{
+			Route: "chat", ClaimsHeader: "x-jwt-claims", CEL: expr,
+		}},
+	}
+	config.authorizations = map[filterapi.RouteRuleName]cel.Program{
+		"some-route":  mustNewAuthzProgram(t, expr),
+		"claims-only": mustNewAuthzProgram(t, expr),
+	}
+
+	for _, tc := range []struct {
+		name        string
+		route       filterapi.RouteRuleName
+		model       string
+		headers     map[string]string
+		expConsumer string
+		expStatus   typev3.StatusCode
+	}{
+		{name: "unknown rule", route: "unknown", model: "o1"},
+		{name: "team a", route: "claims-only", model: "gpt-4o", headers: map[string]string{"x-jwt-claims": `{"team":"a"}`}},
+		{name: "team a o1", route: "claims-only", model: "o1", headers: map[string]string{"x-jwt-claims": `{"team":"a"}`}, expStatus: typev3.StatusCode_Forbidden},
+		{
+			name: "intern base64url", route: "claims-only", model: "gpt-4o-mini",
+			headers: map[string]string{"x-jwt-claims": base64.RawURLEncoding.EncodeToString([]byte(`{"groups":["interns"]}`))},
+		},
+		{
+			name: "intern large model", route: "claims-only", model: "gpt-4o",
+			headers:   map[string]string{"x-jwt-claims": `{"groups":["interns"]}`},
+			expStatus: typev3.StatusCode_Forbidden,
+		},
+		{name: "no claims", route: "claims-only", model: "gpt-4o", expStatus: typev3.StatusCode_Forbidden},
+		{name: "invalid claims", route: "claims-only", model: "gpt-4o", headers: map[string]string{"x-jwt-claims": "!"}, expStatus: typev3.StatusCode_Forbidden},
+		{name: "consumer", route: "some-route", model: "gpt-4o", headers: map[string]string{"authorization": "Bearer sk-alice"}, expConsumer: "alice"},
+		{name: "consumer key missing", route: "some-route", model: "gpt-4o", headers: map[string]string{"x-jwt-claims": `{"team":"a"}`}, expStatus: typev3.StatusCode_Unauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := tc.headers
+			if headers == nil {
+				headers = map[string]string{}
+			}
+			consumer, resp := authorizeRequest(config, slog.Default(), headers, tc.route, tc.model)
+			if tc.expStatus == 0 {
+				require.Nil(t, resp)
+				require.Equal(t, tc.expConsumer, consumer)
+				return
+			}
+			require.Empty(t, consumer)
+			require.Equal(t, tc.expStatus, resp.GetImmediateResponse().GetStatus().GetCode())
+		})
+	}
+}
+
+func TestChatCompletion_authorization(t *testing.T) {
+	const expr = `claims[?"team"].orValue("") == "a" && model != "o1"`
+	headers := map[string]string{":path": "/v1/chat/completions", "x-jwt-claims": `{"team":"a"}`}
+	config := &processorConfig{
+		modelNameHeaderKey:     "x-model-name",
+		selectedRouteHeaderKey: "x-route",
+		router:                 mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+		rules: map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": {
+			Name: "some-route", Authorization: &filterapi.Authorization{Route: "chat", ClaimsHeader: "x-jwt-claims", CEL: expr},
+		}},
+		authorizations: map[filterapi.RouteRuleName]cel.Program{"some-route": mustNewAuthzProgram(t, expr)},
+	}
+	rp := &chatCompletionProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default()}
+	resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(`{"model":"o1","messages":[]}`)})
+	require.NoError(t, err)
+	require.Equal(t, typev3.StatusCode_Forbidden, resp.GetImmediateResponse().GetStatus().GetCode())
+	require.JSONEq(t, `{"type":"error","error":{"type":"invalid_request_error","code":"model_not_allowed","param":"model",
+		"message":"You are not allowed to use the model o1."}}`, string(resp.GetImmediateResponse().GetBody()))
+
+	resp, err = rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(`{"model":"gpt-4o","messages":[]}`)})
+	require.NoError(t, err)
+	require.NotNil(t, resp.GetRequestBody())
+}
@@ -8,6 +8,7 @@ package backendauth
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strings"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -19,19 +20,74 @@ import (
 // apiKeyHandler implements [Handler] for api key authz.
 type apiKeyHandler struct {
 	apiKey string
+	// apiKeyFile is the file of the api key used instead of apiKey when not nil.
+	apiKeyFile *credentialFile[string]
+	// header and prefix are the header to set the api key in and the prefix of the header value.
+	header, prefix string
+	// queryParam is the query parameter to set the api key in instead of the header when not empty.
+	queryParam string
 }
 
 func newAPIKeyHandler(auth *filterapi.APIKeyAuth) (Handler, error) {
-	return &apiKeyHandler{apiKey: strings.TrimSpace(auth.Key)}, nil
+	h := &apiKeyHandler{header: auth.Header, prefix: auth.Prefix, queryParam: auth.QueryParam}
+	if h.header == "" && h.queryParam == "" {
+		h.header, h.prefix = "Authorization", "Bearer "
+	}
+	if auth.KeyFile != "" {
+		h.apiKeyFile = newTokenFile(auth.KeyFile)
+	} else {
+		h.apiKey = strings.TrimSpace(auth.Key)
+	}
+	return h, nil
 }
 
 // Do implements [Handler.Do].
 //
-// Extracts the api key from the local file and set it as an authorization header.
-func (a *apiKeyHandler) Do(_ context.Context, requestHeaders map[string]string, headerMut *extprocv3.HeaderMutation, _ *extprocv3.BodyMutation) error {
-	requestHeaders["Authorization"] = fmt.Sprintf("Bearer %s", a.apiKey)
+// Extracts the api key from the local file and set it as the configured header, which is the authorization header
+// by default, or as the query parameter of the path.
+func (a *apiKeyHandler) Do(ctx context.Context, requestHeaders map[string]string, headerMut *extprocv3.HeaderMutation, _ *extprocv3.BodyMutation) error {
+	apiKey := a.apiKey
+	if a.apiKeyFile != nil {
+		var err error
+		if apiKey, err = a.apiKeyFile.get(ctx); err != nil {
+			return fmt.Errorf("cannot get api key: %w", err)
+		}
+	}
+	if a.queryParam != "" {
+		return a.setQueryParam(requestHeaders, headerMut, apiKey)
+	}
+	requestHeaders[a.header] = a.prefix + apiKey
 	headerMut.SetHeaders = append(headerMut.SetHeaders, &corev3.HeaderValueOption{
-		Header: &corev3.HeaderValue{Key: "Authorization", RawValue: []byte(requestHeaders["Authorization"])},
+		Header: &corev3.HeaderValue{Key: a.header, RawValue: []byte(requestHeaders[a.header])},
 	})
 	return nil
 }
+
+// setQueryParam sets the api key as the query parameter of the path, which is the one set by the translator if any,
+// or the original path of the request otherwise. The query parameter of the same name is replaced if it exists.
+func (a *apiKeyHandler) setQueryParam(requestHeaders map[string]string, headerMut *extprocv3.HeaderMutation, apiKey string) error {
+	var pathHeader *corev3.HeaderValue
+	for _, hdr := range headerMut.SetHeaders {
+		if hdr.Header != nil && hdr.Header.Key == ":path" {
+			pathHeader = hdr.Header
+			break
+		}
+	}
+	if pathHeader == nil {
+		pathHeader = &corev3.HeaderValue{Key: ":path", RawValue: []byte(requestHeaders[":path"])}
+		headerMut.SetHeaders = append(headerMut.SetHeaders, &corev3.HeaderValueOption{Header: pathHeader})
+	}
+	path := pathHeader.Value
+	if len(pathHeader.RawValue) > 0 {
+		path = string(pathHeader.RawValue)
+	}
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return fmt.Errorf("cannot parse path %q: %w", path, err)
+	}
+	query := u.Query()
+	query.Set(a.queryParam, apiKey)
+	u.RawQuery = query.Encode()
+	pathHeader.Value, pathHeader.RawValue = "", []byte(u.RequestURI())
+	return nil
+}
@@ -6,6 +6,8 @@
 package backendauth
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -55,3 +57,65 @@ func TestApiKeyHandler_Do(t *testing.T) {
 	require.Equal(t, "Authorization", headerMut.SetHeaders[1].Header.Key)
 	require.Equal(t, []byte("Bearer test"), headerMut.SetHeaders[1].Header.GetRawValue())
 }
+
+func TestApiKeyHandler_Do_keyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("from-file\n"), 0o600))
+	handler, err := newAPIKeyHandler(&filterapi.APIKeyAuth{KeyFile: keyFile})
+	require.NoError(t, err)
+
+	requestHeaders := map[string]string{}
+	headerMut := &extprocv3.HeaderMutation{}
+	require.NoError(t, handler.Do(t.Context(), requestHeaders, headerMut, nil))
+	require.Equal(t, "Bearer from-file", requestHeaders["Authorization"])
+	require.Equal(t, []byte("Bearer from-file"), headerMut.SetHeaders[0].Header.GetRawValue())
+
+	require.NoError(t, os.Remove(keyFile))
+	require.ErrorContains(t, handler.Do(t.Context(), requestHeaders, &extprocv3.HeaderMutation{}, nil), "cannot get api key")
+}
+
+func TestApiKeyHandler_Do_placement(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		auth       filterapi.APIKeyAuth
+		setHeaders []*corev3.HeaderValueOption
+		expHeaders map[string]string
+	}{
+		{
+			name:       "header without prefix",
+			auth:       filterapi.APIKeyAuth{Key: "test", Header: "x-api-key"},
+			expHeaders: map[string]string{"x-api-key": "test"},
+		},
+		{
+			name:       "header with prefix",
+			auth:       filterapi.APIKeyAuth{Key: "test", Header: "Authorization", Prefix: "Token "},
+			expHeaders: map[string]string{"Authorization": "Token test"},
+		},
+		{
+			name: "query param of translated path",
+			auth: filterapi.APIKeyAuth{Key: "a b", QueryParam: "key"},
+			setHeaders: []*corev3.HeaderValueOption{
+				{Header: &corev3.HeaderValue{Key: ":path", Value: "/v1beta/models/gemini:generateContent?alt=sse&key=client"}},
+			},
+			expHeaders: map[string]string{":path": "/v1beta/models/gemini:generateContent?alt=sse&key=a+b"},
+		},
+		{
+			name:       "query param of original path",
+			auth:       filterapi.APIKeyAuth{Key: "test", QueryParam: "key"},
+			expHeaders: map[string]string{":path": "/v1/chat/completions?key=test"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, err := newAPIKeyHandler(&tc.auth)
+			require.NoError(t, err)
+			requestHeaders := map[string]string{":path": "/v1/chat/completions"}
+			headerMut := &extprocv3.HeaderMutation{SetHeaders: tc.setHeaders}
+			require.NoError(t, handler.Do(t.Context(), requestHeaders, headerMut, nil))
+			headers := map[string]string{}
+			for _, h := range headerMut.SetHeaders {
+				headers[h.Header.Key] = h.Header.Value + string(h.Header.RawValue)
+			}
+			require.Equal(t, tc.expHeaders, headers)
+		})
+	}
+}
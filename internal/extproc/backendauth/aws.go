@@ -29,17 +29,27 @@ import (
 // awsHandler implements [Handler] for AWS Bedrock authz.
 type awsHandler struct {
 	credentials aws.Credentials
-	signer      *v4.Signer
-	region      string
+	// credentialsFile is the AWS credential file used instead of credentials when not nil.
+	credentialsFile *credentialFile[aws.Credentials]
+	signer          *v4.Signer
+	region          string
 }
 
 func newAWSHandler(ctx context.Context, awsAuth *filterapi.AWSAuth) (Handler, error) {
 	var credentials aws.Credentials
+	var credentialsFile *credentialFile[aws.Credentials]
 	var region string
 
 	if awsAuth != nil {
 		region = awsAuth.Region
-		if len(awsAuth.CredentialFileLiteral) != 0 {
+		if awsAuth.CredentialFile != "" {
+			credentialsFile = &credentialFile[aws.Credentials]{
+				path: awsAuth.CredentialFile,
+				parse: func(ctx context.Context, _ []byte) (aws.Credentials, error) {
+					return loadAWSCredentials(ctx, awsAuth.CredentialFile, region)
+				},
+			}
+		} else if len(awsAuth.CredentialFileLiteral) != 0 {
 			tmpfile, err := os.CreateTemp("", "aws-credentials")
 			if err != nil {
 				return nil, fmt.Errorf("cannot create temp file for AWS credentials: %w", err)
@@ -50,18 +60,9 @@ func newAWSHandler(ctx context.Context, awsAuth *filterapi.AWSAuth) (Handler, er
 			if _, err = tmpfile.WriteString(awsAuth.CredentialFileLiteral); err != nil {
 				return nil, fmt.Errorf("cannot write AWS credentials to temp file: %w", err)
 			}
-			name := tmpfile.Name()
-			cfg, err := config.LoadDefaultConfig(
-				ctx,
-				config.WithSharedCredentialsFiles([]string{name}),
-				config.WithRegion(awsAuth.Region),
-			)
-			if err != nil {
-				return nil, fmt.Errorf("cannot load from credentials file: %w", err)
-			}
-			credentials, err = cfg.Credentials.Retrieve(ctx)
+			credentials, err = loadAWSCredentials(ctx, tmpfile.Name(), region)
 			if err != nil {
-				return nil, fmt.Errorf("cannot retrieve AWS credentials: %w", err)
+				return nil, err
 			}
 		}
 	} else {
@@ -70,7 +71,24 @@ func newAWSHandler(ctx context.Context, awsAuth *filterapi.AWSAuth) (Handler, er
 
 	signer := v4.NewSigner()
 
-	return &awsHandler{credentials: credentials, signer: signer, region: region}, nil
+	return &awsHandler{credentials: credentials, credentialsFile: credentialsFile, signer: signer, region: region}, nil
+}
+
+// loadAWSCredentials loads the credentials from the AWS credential file at the path.
+func loadAWSCredentials(ctx context.Context, path, region string) (aws.Credentials, error) {
+	cfg, err := config.LoadDefaultConfig(
+		ctx,
+		config.WithSharedCredentialsFiles([]string{path}),
+		config.WithRegion(region),
+	)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("cannot load from credentials file: %w", err)
+	}
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("cannot retrieve AWS credentials: %w", err)
+	}
+	return credentials, nil
 }
 
 // Do implements [Handler.Do].
@@ -115,7 +133,14 @@ func (a *awsHandler) Do(ctx context.Context, requestHeaders map[string]string, h
 	// https://github.com/envoyproxy/envoy/blob/60b2b5187cf99db79ecfc54675354997af4765ea/source/extensions/filters/http/ext_proc/processor_state.cc#L180-L183
 	req.ContentLength = -1
 
-	err = a.signer.SignHTTP(ctx, a.credentials, req,
+	credentials := a.credentials
+	if a.credentialsFile != nil {
+		if credentials, err = a.credentialsFile.get(ctx); err != nil {
+			return fmt.Errorf("cannot get AWS credentials: %w", err)
+		}
+	}
+
+	err = a.signer.SignHTTP(ctx, credentials, req,
 		hex.EncodeToString(payloadHash[:]), "bedrock", a.region, time.Now())
 	if err != nil {
 		return fmt.Errorf("cannot sign request: %w", err)
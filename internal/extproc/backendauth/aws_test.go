@@ -6,6 +6,8 @@
 package backendauth
 
 import (
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
@@ -22,6 +24,21 @@ func TestNewAWSHandler(t *testing.T) {
 	require.NotNil(t, handler)
 }
 
+func TestNewAWSHandler_credentialFile(t *testing.T) {
+	credentialFile := filepath.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.WriteFile(credentialFile, []byte("[default]\nAWS_ACCESS_KEY_ID=test\nAWS_SECRET_ACCESS_KEY=secret\n"), 0o600))
+	handler, err := newAWSHandler(t.Context(), &filterapi.AWSAuth{CredentialFile: credentialFile, Region: "us-east-1"})
+	require.NoError(t, err)
+
+	headerMut := &extprocv3.HeaderMutation{
+		SetHeaders: []*corev3.HeaderValueOption{{Header: &corev3.HeaderValue{Key: ":path", Value: "/model/m/converse"}}},
+	}
+	require.NoError(t, handler.Do(t.Context(), map[string]string{":method": "POST"}, headerMut, &extprocv3.BodyMutation{}))
+	credentials, err := handler.(*awsHandler).credentialsFile.get(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "test", credentials.AccessKeyID)
+}
+
 func TestAWSHandler_Do(t *testing.T) {
 	awsFileBody := "[default]\nAWS_ACCESS_KEY_ID=test\nAWS_SECRET_ACCESS_KEY=secret\n"
 	credentialFileHandler, err := newAWSHandler(t.Context(), &filterapi.AWSAuth{
@@ -17,20 +17,46 @@ import (
 )
 
 type azureHandler struct {
+	// azureAccessToken is the access token, or the API key when apiKey is true.
 	azureAccessToken string
+	// azureAccessTokenFile is the file of the access token used instead of azureAccessToken when not nil.
+	azureAccessTokenFile *credentialFile[string]
+	// apiKey is true if the credential is an API key sent in the "api-key" header instead of an access token.
+	apiKey bool
 }
 
 func newAzureHandler(auth *filterapi.AzureAuth) (Handler, error) {
-	return &azureHandler{azureAccessToken: strings.TrimSpace(auth.AccessToken)}, nil
+	switch {
+	case auth.APIKeyFile != "":
+		return &azureHandler{azureAccessTokenFile: newTokenFile(auth.APIKeyFile), apiKey: true}, nil
+	case auth.APIKey != "":
+		return &azureHandler{azureAccessToken: strings.TrimSpace(auth.APIKey), apiKey: true}, nil
+	case auth.AccessTokenFile != "":
+		return &azureHandler{azureAccessTokenFile: newTokenFile(auth.AccessTokenFile)}, nil
+	default:
+		return &azureHandler{azureAccessToken: strings.TrimSpace(auth.AccessToken)}, nil
+	}
 }
 
 // Do implements [Handler.Do].
 //
-// Extracts the azure access token from the local file and set it as an authorization header.
-func (a *azureHandler) Do(_ context.Context, requestHeaders map[string]string, headerMut *extprocv3.HeaderMutation, _ *extprocv3.BodyMutation) error {
-	requestHeaders["Authorization"] = fmt.Sprintf("Bearer %s", a.azureAccessToken)
+// Extracts the azure access token from the local file and set it as an authorization header,
+// or sets the API key as the "api-key" header.
+func (a *azureHandler) Do(ctx context.Context, requestHeaders map[string]string, headerMut *extprocv3.HeaderMutation, _ *extprocv3.BodyMutation) error {
+	accessToken := a.azureAccessToken
+	if a.azureAccessTokenFile != nil {
+		var err error
+		if accessToken, err = a.azureAccessTokenFile.get(ctx); err != nil {
+			return fmt.Errorf("cannot get azure access token: %w", err)
+		}
+	}
+	key, value := "Authorization", fmt.Sprintf("Bearer %s", accessToken)
+	if a.apiKey {
+		key, value = "api-key", accessToken
+	}
+	requestHeaders[key] = value
 	headerMut.SetHeaders = append(headerMut.SetHeaders, &corev3.HeaderValueOption{
-		Header: &corev3.HeaderValue{Key: "Authorization", RawValue: []byte(requestHeaders["Authorization"])},
+		Header: &corev3.HeaderValue{Key: key, RawValue: []byte(value)},
 	})
 	return nil
 }
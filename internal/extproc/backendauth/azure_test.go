@@ -6,6 +6,8 @@
 package backendauth
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -58,3 +60,31 @@ func TestNewAzureHandler_Do(t *testing.T) {
 	require.Equal(t, "Authorization", headerMut.SetHeaders[1].Header.Key)
 	require.Equal(t, []byte("Bearer some-access-token"), headerMut.SetHeaders[1].Header.GetRawValue())
 }
+
+func TestNewAzureHandler_Do_apiKey(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		auth func(t *testing.T) *filterapi.AzureAuth
+	}{
+		{name: "literal", auth: func(*testing.T) *filterapi.AzureAuth { return &filterapi.AzureAuth{APIKey: "some-api-key\n"} }},
+		{name: "file", auth: func(t *testing.T) *filterapi.AzureAuth {
+			apiKeyFile := filepath.Join(t.TempDir(), "api-key")
+			require.NoError(t, os.WriteFile(apiKeyFile, []byte("some-api-key"), 0o600))
+			return &filterapi.AzureAuth{APIKeyFile: apiKeyFile}
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, err := newAzureHandler(tc.auth(t))
+			require.NoError(t, err)
+
+			requestHeaders := map[string]string{":method": "POST"}
+			headerMut := &extprocv3.HeaderMutation{}
+			require.NoError(t, handler.Do(t.Context(), requestHeaders, headerMut, nil))
+			require.Equal(t, "some-api-key", requestHeaders["api-key"])
+			require.NotContains(t, requestHeaders, "Authorization")
+			require.Len(t, headerMut.SetHeaders, 1)
+			require.Equal(t, "api-key", headerMut.SetHeaders[0].Header.Key)
+			require.Equal(t, []byte("some-api-key"), headerMut.SetHeaders[0].Header.GetRawValue())
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package backendauth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// credentialFile is a credential read from a file mounted into the external processor. The file is read lazily and
+// reloaded when it changes, e.g. when the kubelet updates the mounted Secret after the credential is rotated, so
+// that the rotation takes effect without the filter config being reloaded.
+type credentialFile[T any] struct {
+	path  string
+	parse func(ctx context.Context, raw []byte) (T, error)
+
+	mu sync.RWMutex
+	// info is the file info of the file that value is read from, or nil if the file has not been read yet.
+	info  os.FileInfo
+	value T
+}
+
+// newTokenFile returns a [credentialFile] of the token in the file at the path, with the surrounding spaces trimmed.
+func newTokenFile(path string) *credentialFile[string] {
+	return &credentialFile[string]{path: path, parse: func(_ context.Context, raw []byte) (string, error) {
+		token := strings.TrimSpace(string(raw))
+		if token == "" {
+			return "", fmt.Errorf("credential file %s is empty", path)
+		}
+		return token, nil
+	}}
+}
+
+// get returns the credential, reading the file again if it has changed since the last read.
+func (c *credentialFile[T]) get(ctx context.Context) (T, error) {
+	var zero T
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return zero, fmt.Errorf("cannot stat credential file: %w", err)
+	}
+	c.mu.RLock()
+	if c.info != nil && sameFile(c.info, info) {
+		defer c.mu.RUnlock()
+		return c.value, nil
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.info != nil && sameFile(c.info, info) {
+		return c.value, nil
+	}
+	raw, err := os.ReadFile(c.path)
+	if err != nil {
+		return zero, fmt.Errorf("cannot read credential file: %w", err)
+	}
+	value, err := c.parse(ctx, raw)
+	if err != nil {
+		return zero, err
+	}
+	c.info, c.value = info, value
+	return value, nil
+}
+
+// sameFile returns true if a and b describe the same unmodified file. The kubelet updates the mounted Secret by
+// atomically swapping the directory holding the files, so the updated file is a different one.
+func sameFile(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
@@ -0,0 +1,52 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package backendauth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialFile_get(t *testing.T) {
+	dir := t.TempDir()
+	// Mimic the kubelet, which swaps the symlink to the directory holding the files of the mounted Secret.
+	write := func(t *testing.T, version, content string) {
+		versionDir := filepath.Join(dir, version)
+		require.NoError(t, os.Mkdir(versionDir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(versionDir, "token"), []byte(content), 0o600))
+		tmp := filepath.Join(dir, "..data_tmp")
+		require.NoError(t, os.Symlink(versionDir, tmp))
+		require.NoError(t, os.Rename(tmp, filepath.Join(dir, "..data")))
+	}
+	write(t, "v1", "token-1\n")
+	require.NoError(t, os.Symlink(filepath.Join("..data", "token"), filepath.Join(dir, "token")))
+
+	f := newTokenFile(filepath.Join(dir, "token"))
+	token, err := f.get(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	// The file is not read again while it is unchanged.
+	info := f.info
+	token, err = f.get(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	require.Same(t, info, f.info)
+
+	write(t, "v2", "token-2")
+	token, err = f.get(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token)
+
+	write(t, "v3", " \n")
+	_, err = f.get(t.Context())
+	require.ErrorContains(t, err, "is empty")
+
+	_, err = newTokenFile(filepath.Join(dir, "missing")).get(t.Context())
+	require.ErrorContains(t, err, "cannot stat credential file")
+}
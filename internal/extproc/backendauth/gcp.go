@@ -17,8 +17,10 @@ import (
 
 type gcpHandler struct {
 	gcpAccessToken string // The GCP access token used for authentication.
-	region         string // The GCP region to use for requests.
-	projectName    string // The GCP project to use for requests.
+	// gcpAccessTokenFile is the file of the access token used instead of gcpAccessToken when not nil.
+	gcpAccessTokenFile *credentialFile[string]
+	region             string // The GCP region to use for requests.
+	projectName        string // The GCP project to use for requests.
 }
 
 func newGCPHandler(gcpAuth *filterapi.GCPAuth) (Handler, error) {
@@ -26,6 +28,14 @@ func newGCPHandler(gcpAuth *filterapi.GCPAuth) (Handler, error) {
 		return nil, fmt.Errorf("GCP auth configuration cannot be nil")
 	}
 
+	if gcpAuth.AccessTokenFile != "" {
+		return &gcpHandler{
+			gcpAccessTokenFile: newTokenFile(gcpAuth.AccessTokenFile),
+			region:             gcpAuth.Region,
+			projectName:        gcpAuth.ProjectName,
+		}, nil
+	}
+
 	if gcpAuth.AccessToken == "" {
 		return nil, fmt.Errorf("GCP access token cannot be empty")
 	}
@@ -45,7 +55,15 @@ func newGCPHandler(gcpAuth *filterapi.GCPAuth) (Handler, error) {
 //
 // The ":path" header is expected to contain the API-specific suffix, which is injected by translator.requestBody.
 // The suffix is combined with the generated prefix to form the complete path for the GCP API call.
-func (g *gcpHandler) Do(_ context.Context, _ map[string]string, headerMut *extprocv3.HeaderMutation, _ *extprocv3.BodyMutation) error {
+func (g *gcpHandler) Do(ctx context.Context, _ map[string]string, headerMut *extprocv3.HeaderMutation, _ *extprocv3.BodyMutation) error {
+	accessToken := g.gcpAccessToken
+	if g.gcpAccessTokenFile != nil {
+		var err error
+		if accessToken, err = g.gcpAccessTokenFile.get(ctx); err != nil {
+			return fmt.Errorf("cannot get GCP access token: %w", err)
+		}
+	}
+
 	var pathHeaderFound bool
 
 	// Build the GCP URL prefix using the configured region and project name.
@@ -80,7 +98,7 @@ func (g *gcpHandler) Do(_ context.Context, _ map[string]string, headerMut *extpr
 		&corev3.HeaderValueOption{
 			Header: &corev3.HeaderValue{
 				Key:      "Authorization",
-				RawValue: []byte(fmt.Sprintf("Bearer %s", g.gcpAccessToken)),
+				RawValue: []byte(fmt.Sprintf("Bearer %s", accessToken)),
 			},
 		},
 	)
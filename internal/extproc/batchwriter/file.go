@@ -0,0 +1,161 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package batchwriter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// JSONLFile writes the items to a [RotatingFile] as JSON lines.
+type JSONLFile[T any] struct {
+	f *RotatingFile
+}
+
+// NewJSONLFile creates a new JSONLFile at the path. See [NewRotatingFile] for maxSize and maxBackups.
+func NewJSONLFile[T any](path string, maxSize int64, maxBackups int) (*JSONLFile[T], error) {
+	f, err := NewRotatingFile(path, maxSize, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLFile[T]{f: f}, nil
+}
+
+// Write appends the items to the file, one JSON object per line.
+func (s *JSONLFile[T]) Write(_ context.Context, items []T) error {
+	var buf []byte
+	for i := range items {
+		line, err := json.Marshal(&items[i])
+		if err != nil {
+			return fmt.Errorf("failed to marshal record: %w", err)
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+	return s.f.Write(buf)
+}
+
+// Close closes the file.
+func (s *JSONLFile[T]) Close(context.Context) error {
+	return s.f.Close()
+}
+
+// FileSpec is the parsed "file:///path/to/file.jsonl?maxSizeMB=100&maxBackups=5" specification of a sink.
+type FileSpec struct {
+	// Path is the path of the file.
+	Path string
+	// MaxSize is the size in bytes at which the file is rotated. Defaults to 100MB.
+	MaxSize int64
+	// MaxBackups is the number of the rotated files to keep. Defaults to 5.
+	MaxBackups int
+}
+
+// ParseFileSpec parses the file URL of the sink. kind is the name of the sink used in the errors,
+// e.g. "usage ledger sink".
+func ParseFileSpec(u *url.URL, kind string) (FileSpec, error) {
+	maxSizeMB, maxBackups := 100, 5
+	q := u.Query()
+	var err error
+	if v := q.Get("maxSizeMB"); v != "" {
+		if maxSizeMB, err = strconv.Atoi(v); err != nil || maxSizeMB <= 0 {
+			return FileSpec{}, fmt.Errorf("invalid maxSizeMB %q of %s", v, kind)
+		}
+	}
+	if v := q.Get("maxBackups"); v != "" {
+		if maxBackups, err = strconv.Atoi(v); err != nil || maxBackups < 0 {
+			return FileSpec{}, fmt.Errorf("invalid maxBackups %q of %s", v, kind)
+		}
+	}
+	return FileSpec{Path: u.Path, MaxSize: int64(maxSizeMB) << 20, MaxBackups: maxBackups}, nil
+}
+
+// RotatingFile is an append-only file, which is rotated when it exceeds maxSize.
+//
+// The rotated files are renamed to path.1, path.2, ..., up to path.<maxBackups>, where path.1 is the newest.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+// NewRotatingFile opens the file at the path for appending, creating the file and its directory if not exist.
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create the directory of %s: %w", path, err)
+	}
+	s := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *RotatingFile) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", s.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to stat %s: %w", s.path, err)
+	}
+	s.f, s.size = f, info.Size()
+	return nil
+}
+
+// rotate renames the current file to path.1 after shifting the existing backups, and opens a new file.
+func (s *RotatingFile) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", s.path, err)
+	}
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", s.path, err)
+		}
+		return s.open()
+	}
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(backupPath(s.path, i), backupPath(s.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate %s: %w", s.path, err)
+		}
+	}
+	if err := os.Rename(s.path, backupPath(s.path, 1)); err != nil {
+		return fmt.Errorf("failed to rotate %s: %w", s.path, err)
+	}
+	return s.open()
+}
+
+func backupPath(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+// Write appends the buffer to the file as a whole, rotating the file first if the buffer would exceed maxSize.
+func (s *RotatingFile) Write(buf []byte) error {
+	if s.size > 0 && s.size+int64(len(buf)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(buf)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write to %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *RotatingFile) Close() error {
+	return s.f.Close()
+}
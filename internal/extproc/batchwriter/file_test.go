@@ -0,0 +1,53 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package batchwriter
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "out.jsonl")
+	type item struct {
+		ID string `json:"id"`
+	}
+	f, err := NewJSONLFile[item](path, 1<<20, 1)
+	require.NoError(t, err)
+	require.NoError(t, f.Write(t.Context(), []item{{ID: "a"}, {ID: "b"}}))
+	require.NoError(t, f.Close(t.Context()))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `{"id":"a"}`+"\n"+`{"id":"b"}`+"\n", string(content))
+}
+
+func TestParseFileSpec(t *testing.T) {
+	for _, tc := range []struct {
+		name, spec, expErr string
+		exp                FileSpec
+	}{
+		{name: "defaults", spec: "file:///tmp/out.jsonl", exp: FileSpec{Path: "/tmp/out.jsonl", MaxSize: 100 << 20, MaxBackups: 5}},
+		{name: "options", spec: "file:///tmp/out.jsonl?maxSizeMB=1&maxBackups=0", exp: FileSpec{Path: "/tmp/out.jsonl", MaxSize: 1 << 20}},
+		{name: "invalid maxSizeMB", spec: "file:///tmp/out.jsonl?maxSizeMB=0", expErr: `invalid maxSizeMB "0" of test sink`},
+		{name: "invalid maxBackups", spec: "file:///tmp/out.jsonl?maxBackups=-1", expErr: `invalid maxBackups "-1" of test sink`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse(tc.spec)
+			require.NoError(t, err)
+			fs, err := ParseFileSpec(u, "test sink")
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.exp, fs)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+// Package batchwriter implements the building blocks shared by the usage ledger and the audit log: the queue that
+// writes the items in batches in the background, and the JSONL file rotated by size.
+package batchwriter
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// writeTimeout is the timeout of a single write of a batch including the retries.
+const writeTimeout = 30 * time.Second
+
+// Queue writes the items with the write function in the background.
+//
+// The items are queued in memory and written in batches. When the queue is full because the writer cannot keep up,
+// the items are dropped instead of blocking the request path, and the number of dropped items is logged.
+type Queue[T any] struct {
+	// name is the name of the items used in the logs, e.g. "usage records".
+	name          string
+	write         func(ctx context.Context, items []T) error
+	logger        *slog.Logger
+	queue         chan T
+	batchSize     int
+	flushInterval time.Duration
+	dropped       atomic.Uint64
+	stop          chan struct{}
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+// NewQueue creates a new Queue and starts its background goroutine. write is only called by the goroutine,
+// so it does not need to be safe for concurrent use.
+func NewQueue[T any](name string, write func(ctx context.Context, items []T) error, logger *slog.Logger,
+	queueSize, batchSize int, flushInterval time.Duration,
+) *Queue[T] {
+	q := &Queue[T]{
+		name:          name,
+		write:         write,
+		logger:        logger,
+		queue:         make(chan T, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Add queues the item without blocking.
+func (q *Queue[T]) Add(item T) {
+	select {
+	case q.queue <- item:
+	default:
+		q.dropped.Add(1)
+	}
+}
+
+// Close writes the queued items and stops the background goroutine.
+func (q *Queue[T]) Close(ctx context.Context) error {
+	q.closeOnce.Do(func() { close(q.stop) })
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *Queue[T]) run() {
+	defer close(q.done)
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+	batch := make([]T, 0, q.batchSize)
+	flush := func() {
+		if dropped := q.dropped.Swap(0); dropped > 0 {
+			q.logger.Warn("dropped "+q.name+" since the sink cannot keep up", slog.Uint64("count", dropped))
+		}
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		defer cancel()
+		if err := q.write(ctx, batch); err != nil {
+			q.logger.Error("failed to write "+q.name, slog.Int("count", len(batch)), slog.String("error", err.Error()))
+		}
+		clear(batch)
+		batch = batch[:0]
+	}
+	add := func(item T) {
+		batch = append(batch, item)
+		if len(batch) >= q.batchSize {
+			flush()
+		}
+	}
+	for {
+		select {
+		case item := <-q.queue:
+			add(item)
+		case <-ticker.C:
+			flush()
+		case <-q.stop:
+			for {
+				select {
+				case item := <-q.queue:
+					add(item)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
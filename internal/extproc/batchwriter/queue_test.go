@@ -0,0 +1,108 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package batchwriter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter records the written batches, optionally blocking until unblock is closed.
+type recordingWriter struct {
+	mu      sync.Mutex
+	batches [][]string
+	unblock chan struct{}
+	err     error
+}
+
+func (r *recordingWriter) write(_ context.Context, items []string) error {
+	if r.unblock != nil {
+		<-r.unblock
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]string(nil), items...))
+	return r.err
+}
+
+func (r *recordingWriter) items() (ret []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.batches {
+		ret = append(ret, b...)
+	}
+	return
+}
+
+func TestQueue(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("batch and close", func(t *testing.T) {
+		w := &recordingWriter{}
+		q := NewQueue("items", w.write, logger, 10, 2, time.Hour)
+		for _, item := range []string{"a", "b", "c"} {
+			q.Add(item)
+		}
+		// The first two items are written as a full batch, and the last one is written on Close.
+		require.Eventually(t, func() bool { return len(w.items()) == 2 }, time.Second, 10*time.Millisecond)
+		require.NoError(t, q.Close(t.Context()))
+		require.Equal(t, [][]string{{"a", "b"}, {"c"}}, w.batches)
+		// Close is idempotent.
+		require.NoError(t, q.Close(t.Context()))
+	})
+
+	t.Run("flush interval", func(t *testing.T) {
+		w := &recordingWriter{}
+		q := NewQueue("items", w.write, logger, 10, 100, 10*time.Millisecond)
+		q.Add("a")
+		require.Eventually(t, func() bool { return len(w.items()) == 1 }, time.Second, 10*time.Millisecond)
+		require.NoError(t, q.Close(t.Context()))
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		w := &recordingWriter{err: errors.New("boom")}
+		q := NewQueue("items", w.write, logger, 10, 1, time.Hour)
+		q.Add("a")
+		q.Add("b")
+		require.NoError(t, q.Close(t.Context()))
+		// The failed batch is not retried by the queue.
+		require.Equal(t, []string{"a", "b"}, w.items())
+	})
+
+	t.Run("drop on backpressure", func(t *testing.T) {
+		w := &recordingWriter{unblock: make(chan struct{})}
+		q := NewQueue("items", w.write, logger, 1, 1, time.Hour)
+		// The first item is taken by the blocked writer, the second one is queued, and the rest are dropped
+		// without blocking the caller.
+		q.Add("a")
+		require.Eventually(t, func() bool { return len(q.queue) == 0 }, time.Second, time.Millisecond)
+		for range 10 {
+			q.Add("b")
+		}
+		require.Equal(t, uint64(9), q.dropped.Load())
+		close(w.unblock)
+		require.NoError(t, q.Close(t.Context()))
+		require.Equal(t, []string{"a", "b"}, w.items())
+	})
+
+	t.Run("close timeout", func(t *testing.T) {
+		w := &recordingWriter{unblock: make(chan struct{})}
+		q := NewQueue("items", w.write, logger, 1, 1, time.Hour)
+		q.Add("a")
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		require.ErrorIs(t, q.Close(ctx), context.Canceled)
+		close(w.unblock)
+		require.NoError(t, q.Close(t.Context()))
+	})
+}
@@ -14,23 +14,37 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	extprocv3http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
 	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/envoyproxy/ai-gateway/filterapi"
 	"github.com/envoyproxy/ai-gateway/filterapi/x"
 	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/audit"
 	"github.com/envoyproxy/ai-gateway/internal/extproc/backendauth"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/ledger"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/quota"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/redaction"
 	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
 	"github.com/envoyproxy/ai-gateway/internal/llmcostcel"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
+	"github.com/envoyproxy/ai-gateway/internal/tokenizer"
 )
 
 // ChatCompletionProcessorFactory returns a factory method to instantiate the chat completion processor.
-func ChatCompletionProcessorFactory(ccm x.ChatCompletionMetrics) ProcessorFactory {
+func ChatCompletionProcessorFactory(ccm x.ChatCompletionMetrics, opts ProcessorOptions) ProcessorFactory {
 	return func(config *processorConfig, requestHeaders map[string]string, logger *slog.Logger, isUpstreamFilter bool) (Processor, error) {
 		if config.schema.Name != filterapi.APISchemaOpenAI {
 			return nil, fmt.Errorf("unsupported API schema: %s", config.schema.Name)
@@ -38,16 +52,24 @@ func ChatCompletionProcessorFactory(ccm x.ChatCompletionMetrics) ProcessorFactor
 		logger = logger.With("processor", "chat-completion", "isUpstreamFilter", fmt.Sprintf("%v", isUpstreamFilter))
 		if !isUpstreamFilter {
 			return &chatCompletionProcessorRouterFilter{
-				config:         config,
-				requestHeaders: requestHeaders,
-				logger:         logger,
+				config:           config,
+				requestHeaders:   requestHeaders,
+				logger:           logger,
+				shadowMetrics:    opts.ShadowMetrics,
+				circuitBreakers:  opts.CircuitBreakers,
+				quotas:           opts.Quotas,
+				responseCache:    opts.ResponseCache,
+				guardrailMetrics: opts.GuardrailMetrics,
+				auditLogger:      opts.AuditLogger,
 			}, nil
 		}
 		return &chatCompletionProcessorUpstreamFilter{
-			config:         config,
-			requestHeaders: requestHeaders,
-			logger:         logger,
-			metrics:        ccm,
+			config:          config,
+			requestHeaders:  requestHeaders,
+			logger:          logger,
+			metrics:         ccm,
+			circuitBreakers: opts.CircuitBreakers,
+			usageLedger:     opts.UsageLedger,
 		}, nil
 	}
 }
@@ -76,35 +98,218 @@ type chatCompletionProcessorRouterFilter struct {
 	// upstreamFilterCount is the number of upstream filters that have been processed.
 	// This is used to determine if the request is a retry request.
 	upstreamFilterCount int
+	// estimatedInputTokens is the number of input tokens estimated with the tokenizer of the requested model.
+	// This is computed at most once per request as tokenizing a large prompt is not free.
+	estimatedInputTokens int
+	inputTokensEstimated bool
+	// hedge tracks the concurrent attempts when the selected rule has hedging, and is nil otherwise.
+	// In that case, upstreamFilter is set to the attempt that won the race at the response headers.
+	hedge *hedgedRequest
+	// shadow is the request mirrored to the shadow backend of the selected rule, if any.
+	shadow          *shadowRequest
+	shadowMetrics   *metrics.Shadow
+	circuitBreakers *CircuitBreakers
+	quotas          *quota.Quotas
+	// quotaConsumers are the token quotas applied to the request, which are deducted at the end of the response.
+	quotaConsumers []quotaConsumer
+	responseCache  *ResponseCache
+	// cacheKey is the key of the request in the response cache if the response is to be stored, and empty otherwise.
+	cacheKey string
+	cacheTTL time.Duration
+	// cacheNamespace and cacheVector are used to index the response in the semantic cache, if the namespace is not empty.
+	cacheNamespace string
+	cacheVector    []float32
+	// cacheBody is the response body buffered to store in the response cache.
+	cacheBody []byte
+	// redaction is the PII redaction session of the request if the selected rule has the redaction, and nil otherwise.
+	redaction *redaction.Session
+	// redactResponse is true if the completions of the response are processed with the redaction session.
+	redactResponse   bool
+	completionStream *completionStreamRedactor
+	// guardrailRule is the selected rule if the completions of the response are screened by its output guardrails,
+	// or by its stream moderation if streamed.
+	guardrailRule    *filterapi.RouteRule
+	guardrailMetrics *metrics.Guardrail
+	// screenResponse is true if the completions of the non-streamed response are screened by the guardrails.
+	screenResponse bool
+	// streamModerator holds back the streamed completions until they are screened, if the rule has the stream moderation.
+	streamModerator *streamModerator
+	// guardrailFlagged is the names of the triggered guardrails with the Flag action, and guardrailAnnotations is
+	// the dynamic metadata of the triggered guardrails with the Annotate action not yet sent.
+	guardrailFlagged     []string
+	guardrailAnnotations map[string]*structpb.Value
+	// consumer is the consumer authenticated with the consumer key if the selected rule requires one.
+	consumer    string
+	auditLogger *audit.Logger
+	// auditEntry is the audit log entry of the request if the request is sampled for the audit log of the selected
+	// rule, and nil otherwise.
+	auditEntry *audit.Entry
 }
 
 // ProcessResponseHeaders implements [Processor.ProcessResponseHeaders].
 func (c *chatCompletionProcessorRouterFilter) ProcessResponseHeaders(ctx context.Context, headerMap *corev3.HeaderMap) (*extprocv3.ProcessingResponse, error) {
+	headers := headersToMap(headerMap)
+	if c.hedge != nil {
+		if w := c.hedge.winner(headers[hedgeAttemptHeader]); w != nil {
+			c.upstreamFilter = w
+		}
+	}
+	if uf, ok := c.upstreamFilter.(*chatCompletionProcessorUpstreamFilter); ok {
+		if uf.circuitRejected {
+			// The response is the error returned by the upstream filter itself, so there's nothing to translate.
+			c.upstreamFilter = nil
+			c.abortShadow()
+		} else if uf.circuitBreaker != nil {
+			if status, err := strconv.Atoi(headers[":status"]); err == nil {
+				c.circuitBreakers.record(ctx, uf.backendName, uf.circuitBreaker, status, headers["retry-after"])
+			}
+		}
+	}
+	if c.auditEntry != nil {
+		// This includes the error returned by the upstream filter itself.
+		c.auditEntry.Status, _ = strconv.Atoi(headers[":status"])
+	}
 	// If the request failed to route and/or immediate response was returned before the upstream filter was set,
 	// c.upstreamFilter can be nil.
 	if c.upstreamFilter != nil { // See the comment on the "upstreamFilter" field.
-		return c.upstreamFilter.ProcessResponseHeaders(ctx, headerMap)
+		if c.shadow != nil {
+			c.shadow.primaryStatus = headers[":status"]
+		}
+		resp, err := c.upstreamFilter.ProcessResponseHeaders(ctx, headerMap)
+		if err == nil && c.cacheKey != "" {
+			c.checkResponseCacheable(headers)
+		}
+		if err == nil && c.guardrailRule != nil {
+			screen := c.screenableResponse(headers, "guardrails")
+			if screen && c.originalRequestBody.Stream {
+				c.startStreamModeration()
+			} else {
+				c.screenResponse = screen
+			}
+		}
+		if err == nil && len(c.guardrailFlagged) > 0 && !c.screenResponse {
+			// Otherwise, the header is set at the response body after the completions are screened.
+			if rh := resp.GetResponseHeaders(); rh != nil {
+				if rh.Response == nil {
+					rh.Response = &extprocv3.CommonResponse{}
+				}
+				if rh.Response.HeaderMutation == nil {
+					rh.Response.HeaderMutation = &extprocv3.HeaderMutation{}
+				}
+				setHeader(rh.Response.HeaderMutation, guardrailFlaggedHeader, strings.Join(c.guardrailFlagged, ","))
+			}
+		}
+		if err == nil && c.redaction != nil && c.redaction.ProcessesResponses() {
+			c.redactResponse = c.screenableResponse(headers, "redaction")
+		}
+		if err == nil && c.hedge != nil {
+			if rh := resp.GetResponseHeaders(); rh != nil {
+				if rh.Response == nil {
+					rh.Response = &extprocv3.CommonResponse{}
+				}
+				if rh.Response.HeaderMutation == nil {
+					rh.Response.HeaderMutation = &extprocv3.HeaderMutation{}
+				}
+				rh.Response.HeaderMutation.RemoveHeaders = append(rh.Response.HeaderMutation.RemoveHeaders, hedgeAttemptHeader)
+			}
+		}
+		return resp, err
 	}
 	return c.passThroughProcessor.ProcessResponseHeaders(ctx, headerMap)
 }
 
+// screenableResponse returns true if the response with the given headers can be screened by the given feature,
+// i.e. the guardrails or the redaction, which only applies to the successful responses that are not encoded.
+func (c *chatCompletionProcessorRouterFilter) screenableResponse(headers map[string]string, what string) bool {
+	if headers[":status"] != "200" {
+		return false
+	}
+	if enc := headers["content-encoding"]; enc != "" {
+		// The accept-encoding header is removed from the request, so this is unlikely to happen.
+		c.logger.Warn("skipping the "+what+" of the encoded response", "content-encoding", enc)
+		return false
+	}
+	return true
+}
+
 // ProcessResponseBody implements [Processor.ProcessResponseBody].
 func (c *chatCompletionProcessorRouterFilter) ProcessResponseBody(ctx context.Context, body *extprocv3.HttpBody) (*extprocv3.ProcessingResponse, error) {
 	// If the request failed to route and/or immediate response was returned before the upstream filter was set,
 	// c.upstreamFilter can be nil.
 	if c.upstreamFilter != nil { // See the comment on the "upstreamFilter" field.
-		return c.upstreamFilter.ProcessResponseBody(ctx, body)
+		resp, err := c.upstreamFilter.ProcessResponseBody(ctx, body)
+		if err == nil && body.EndOfStream && len(c.quotaConsumers) > 0 {
+			if uf, ok := c.upstreamFilter.(*chatCompletionProcessorUpstreamFilter); ok {
+				usage := uf.costs
+				usage.Add(&uf.hedgeCosts)
+				deductTokenQuotas(ctx, c.quotas, c.logger, c.quotaConsumers, usage)
+				c.quotaConsumers = nil
+			}
+		}
+		if err == nil && c.screenResponse && body.EndOfStream {
+			err = c.applyOutputGuardrails(ctx, resp, body)
+		}
+		if err == nil && c.streamModerator != nil {
+			// This is before the redaction so that the checks never see the PII restored in the completions.
+			err = c.moderateChatCompletionResponseBody(ctx, resp, body)
+		}
+		if err == nil && c.redactResponse {
+			err = c.redactChatCompletionResponseBody(resp, body)
+		}
+		if err == nil && c.cacheKey != "" {
+			c.appendResponseCacheBody(ctx, resp, body)
+		}
+		if err == nil && c.auditEntry != nil {
+			c.appendAuditLogBody(resp, body)
+		}
+		if err == nil && c.shadow != nil {
+			c.shadow.appendPrimaryBody(resp, body)
+			if body.EndOfStream {
+				if uf, ok := c.upstreamFilter.(*chatCompletionProcessorUpstreamFilter); ok {
+					c.shadow.finishPrimary(uf.backendName, uf.costs)
+				}
+				c.shadow = nil
+			}
+		}
+		return resp, err
+	}
+	resp, err := c.passThroughProcessor.ProcessResponseBody(ctx, body)
+	if body.EndOfStream {
+		c.abortShadow()
+	}
+	if err == nil && c.auditEntry != nil {
+		c.appendAuditLogBody(resp, body)
+	}
+	return resp, err
+}
+
+// closeStream implements [streamCloser.closeStream].
+func (c *chatCompletionProcessorRouterFilter) closeStream() {
+	c.abortShadow()
+}
+
+// abortShadow reports to the shadow request, if any, that the result of the primary backend is not available.
+func (c *chatCompletionProcessorRouterFilter) abortShadow() {
+	if c.shadow != nil {
+		c.shadow.abortPrimary()
+		c.shadow = nil
 	}
-	return c.passThroughProcessor.ProcessResponseBody(ctx, body)
 }
 
 // ProcessRequestBody implements [Processor.ProcessRequestBody].
-func (c *chatCompletionProcessorRouterFilter) ProcessRequestBody(_ context.Context, rawBody *extprocv3.HttpBody) (*extprocv3.ProcessingResponse, error) {
-	model, body, err := parseOpenAIChatCompletionBody(rawBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse request body: %w", err)
+func (c *chatCompletionProcessorRouterFilter) ProcessRequestBody(ctx context.Context, rawBody *extprocv3.HttpBody) (*extprocv3.ProcessingResponse, error) {
+	resp, err := c.processRequestBody(ctx, rawBody)
+	if ir := resp.GetImmediateResponse(); err == nil && ir != nil && c.auditEntry != nil {
+		// The request is answered without reaching the backend, so the response never goes through the response path.
+		c.finishAuditLog(ir)
 	}
+	return resp, err
+}
 
+func (c *chatCompletionProcessorRouterFilter) processRequestBody(ctx context.Context, rawBody *extprocv3.HttpBody) (*extprocv3.ProcessingResponse, error) {
+	// The model is extracted without decoding the whole body so that the size limit of the request policy
+	// can be enforced before the potentially large body is parsed.
+	model := gjson.GetBytes(rawBody.Body, "model").String()
 	c.requestHeaders[c.config.modelNameHeaderKey] = model
 	routeName, err := c.config.router.Calculate(c.requestHeaders)
 	if err != nil {
@@ -120,6 +325,130 @@ func (c *chatCompletionProcessorRouterFilter) ProcessRequestBody(_ context.Conte
 		}
 		return nil, fmt.Errorf("failed to calculate route: %w", err)
 	}
+	if rule, ok := c.config.rules[routeName]; ok {
+		c.startAuditLog(rule, model, rawBody.Body)
+	}
+	if resp := c.authorizeRequest(routeName, model); resp != nil {
+		return resp, nil
+	}
+	if rule, ok := c.config.rules[routeName]; ok && rule.RequestPolicy != nil {
+		if rejected := validateBodySize(rule.RequestPolicy, rawBody.Body); rejected != nil {
+			c.logger.Debug("request rejected by the request policy", "route", routeName, "model", model)
+			return rejected, nil
+		}
+	}
+	_, body, err := parseOpenAIChatCompletionBody(rawBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse request body: %w", err)
+	}
+
+	var bodyMutated bool
+	if rule, ok := c.config.rules[routeName]; ok && rule.RequestPolicy != nil {
+		var rejected *extprocv3.ProcessingResponse
+		rawBody.Body, bodyMutated, rejected, err = applyRequestPolicy(rule.RequestPolicy, rawBody.Body, body)
+		if err != nil {
+			return nil, err
+		} else if rejected != nil {
+			c.logger.Debug("request rejected by the request policy", "route", routeName, "model", model)
+			return rejected, nil
+		}
+	}
+
+	originalModel := model
+	originalRouteName := routeName
+	routeName, model, immediateResponse, err := c.selectRouteByContextWindow(routeName, model, body)
+	if err != nil {
+		return nil, err
+	} else if immediateResponse != nil {
+		return immediateResponse, nil
+	}
+	if routeName != originalRouteName || model != originalModel {
+		if rule, ok := c.config.rules[routeName]; ok {
+			c.startAuditLog(rule, model, rawBody.Body)
+		} else {
+			c.auditEntry = nil
+		}
+		// The caller must also be allowed to use the long context fallback model.
+		if resp := c.authorizeRequest(routeName, model); resp != nil {
+			return resp, nil
+		}
+	}
+
+	if rule, ok := c.config.rules[routeName]; ok && !anyBackendAvailable(c.circuitBreakers, rule) {
+		return openAIErrorResponse(typev3.StatusCode_ServiceUnavailable, "server_error", "backends_unavailable", "",
+			fmt.Sprintf("All the backends for the model %s are temporarily unavailable. Please retry later.", model)), nil
+	}
+	if rule, ok := c.config.rules[routeName]; ok {
+		var resp *extprocv3.ProcessingResponse
+		if c.quotaConsumers, resp = checkTokenQuotas(ctx, c.quotas, c.logger, rule, c.requestHeaders, c.consumer); resp != nil {
+			return resp, nil
+		}
+	}
+
+	if model != originalModel {
+		// The request has been upgraded to the long context fallback model, so the model in the body
+		// needs to be rewritten as well so that the upstream receives the consistent request.
+		rawBody.Body, err = sjson.SetBytes(rawBody.Body, "model", model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewrite model in request body: %w", err)
+		}
+		body.Model = model
+		bodyMutated = true
+	}
+	// The request is redacted before the lookup of the response cache so that the request rejected by the redaction
+	// is never served from the cache, and the PII never reaches the embeddings endpoint of the semantic cache.
+	unredactedRaw, unredactedBody := rawBody.Body, body
+	var redacted bool
+	if r, ok := c.config.redactors[routeName]; ok {
+		c.redaction = r.NewSession()
+		rawBody.Body, redacted, err = redactChatCompletionRequest(c.redaction, rawBody.Body)
+		if rejected := (*redaction.RejectedError)(nil); errors.As(err, &rejected) {
+			c.logger.Debug("request rejected by the redaction", "route", routeName, "detector", rejected.Detector)
+			if c.auditEntry != nil {
+				// The request is recorded without the body since the PII rejected by the redaction must not be logged.
+				c.auditEntry.RequestBody = nil
+			}
+			return redactionRejectedResponse(rejected, "messages"), nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to redact request body: %w", err)
+		}
+		if redacted {
+			if _, body, err = parseOpenAIChatCompletionBody(rawBody); err != nil {
+				return nil, fmt.Errorf("failed to parse redacted request body: %w", err)
+			}
+			bodyMutated = true
+		}
+	}
+	if c.auditEntry != nil {
+		c.auditEntry.RequestBody = rawBody.Body
+	}
+	if rule, ok := c.config.rules[routeName]; ok && len(rule.Guardrails) > 0 {
+		// This is after the redaction so that the moderation backends never see the redacted PII, and before the
+		// lookup of the response cache so that a prompt is never served from the cache without being screened.
+		if resp := c.applyInputGuardrails(ctx, rule, rawBody.Body, body); resp != nil {
+			c.logger.Debug("request blocked by the guardrails", "route", routeName, "model", model)
+			return resp, nil
+		}
+	}
+	if rule, ok := c.config.rules[routeName]; ok {
+		// The exact match is keyed on the request before the redaction since the cached responses have the PII of
+		// the request restored, and the semantic cache is skipped for the request with the PII for the same reason.
+		if resp, err := c.lookupResponseCache(ctx, rule, model, unredactedRaw, unredactedBody, !redacted); err != nil {
+			return nil, err
+		} else if resp != nil {
+			c.logger.Debug("serving response from the response cache", "route", routeName, "model", model)
+			if len(c.guardrailFlagged) > 0 {
+				setHeader(resp.GetImmediateResponse().Headers, guardrailFlaggedHeader, strings.Join(c.guardrailFlagged, ","))
+			}
+			resp.DynamicMetadata = mergeDynamicMetadata(resp.DynamicMetadata, c.config.metadataNamespace, c.guardrailAnnotations)
+			c.guardrailAnnotations = nil
+			return resp, nil
+		}
+	}
+	var bodyMutation *extprocv3.BodyMutation
+	if bodyMutated {
+		bodyMutation = &extprocv3.BodyMutation{Mutation: &extprocv3.BodyMutation_Body{Body: rawBody.Body}}
+	}
 
 	var additionalHeaders []*corev3.HeaderValueOption
 	additionalHeaders = append(additionalHeaders, &corev3.HeaderValueOption{
@@ -131,22 +460,209 @@ func (c *chatCompletionProcessorRouterFilter) ProcessRequestBody(_ context.Conte
 	}, &corev3.HeaderValueOption{
 		Header: &corev3.HeaderValue{Key: originalPathHeader, RawValue: []byte(c.requestHeaders[":path"])},
 	})
+	var removeHeaders []string
+	if c.config.sessionKeyHeaderKey != "" {
+		// The session key sent by the client is never used so that the client cannot pick the backend of the request.
+		delete(c.requestHeaders, c.config.sessionKeyHeaderKey)
+		var hash string
+		if rule, ok := c.config.rules[routeName]; ok && rule.SessionAffinity != nil {
+			hash = sessionKeyHash(rule.SessionAffinity, c.requestHeaders, body)
+		}
+		if hash != "" {
+			additionalHeaders = append(additionalHeaders, &corev3.HeaderValueOption{
+				// Envoy hashes this header to pin the session to one of the backends of the rule.
+				Header: &corev3.HeaderValue{Key: c.config.sessionKeyHeaderKey, RawValue: []byte(hash)},
+			})
+		} else {
+			removeHeaders = append(removeHeaders, c.config.sessionKeyHeaderKey)
+		}
+	}
+	if bodyMutation != nil {
+		additionalHeaders = append(additionalHeaders, &corev3.HeaderValueOption{
+			Header: &corev3.HeaderValue{Key: "content-length", RawValue: []byte(strconv.Itoa(len(rawBody.Body)))},
+		})
+	}
+	if c.consumer != "" {
+		set, remove := consumerHeaderMutation(c.config, c.consumer)
+		additionalHeaders = append(additionalHeaders, set...)
+		removeHeaders = append(removeHeaders, remove...)
+	}
 	c.originalRequestBody = body
 	c.originalRequestBodyRaw = rawBody.Body
+	if rule, ok := c.config.rules[routeName]; ok {
+		if body.Stream && rule.StreamModeration != nil {
+			c.guardrailRule = rule
+		} else if !body.Stream && slices.ContainsFunc(rule.Guardrails, func(g filterapi.Guardrail) bool { return g.Output }) {
+			c.guardrailRule = rule
+		}
+	}
+	if (c.redaction != nil && c.redaction.ProcessesResponses()) || c.guardrailRule != nil || c.auditEntry != nil {
+		// The response needs to be decoded to process the completions, or to be recorded as JSON in the audit log.
+		removeHeaders = append(removeHeaders, "accept-encoding")
+	}
+	metadata := c.buildRequestDynamicMetadata(model, body)
+	metadata = mergeDynamicMetadata(metadata, c.config.metadataNamespace, c.guardrailAnnotations)
+	metadata = mergeDynamicMetadata(metadata, c.config.metadataNamespace, consumerDynamicMetadata(c.consumer))
+	c.guardrailAnnotations = nil
+	if rule, ok := c.config.rules[routeName]; ok {
+		c.shadow = maybeStartShadowRequest(c.config, c.shadowMetrics, c.logger, rule, model, c.requestHeaders, rawBody.Body)
+		if rule.Hedging != nil {
+			c.hedge = &hedgedRequest{promptTokens: uint32(c.estimateInputTokens(body))} //nolint:gosec
+		}
+	}
 	return &extprocv3.ProcessingResponse{
 		Response: &extprocv3.ProcessingResponse_RequestBody{
 			RequestBody: &extprocv3.BodyResponse{
 				Response: &extprocv3.CommonResponse{
 					HeaderMutation: &extprocv3.HeaderMutation{
-						SetHeaders: additionalHeaders,
+						SetHeaders:    additionalHeaders,
+						RemoveHeaders: removeHeaders,
 					},
+					BodyMutation:    bodyMutation,
 					ClearRouteCache: true,
 				},
 			},
 		},
+		DynamicMetadata: metadata,
 	}, nil
 }
 
+// estimateInputTokens returns the number of input tokens of the request estimated with the tokenizer of the model.
+func (c *chatCompletionProcessorRouterFilter) estimateInputTokens(body *openai.ChatCompletionRequest) int {
+	if !c.inputTokensEstimated {
+		c.estimatedInputTokens = estimatePromptTokens(body, tokenizer.ForModel(body.Model))
+		c.inputTokensEstimated = true
+	}
+	return c.estimatedInputTokens
+}
+
+// buildRequestDynamicMetadata builds the dynamic metadata of the request costs that are known before the request is
+// sent to the backend, i.e. [filterapi.LLMRequestCostTypeEstimatedInputToken] and [filterapi.LLMRequestCostTypeCEL]
+// using the estimated input tokens, which is evaluated with zero token usage. This allows the rate limit filter to use
+// them as the request cost. The prompt is only tokenized if such a cost is configured.
+//
+// The CEL expression failing on the request path is logged and skipped instead of failing the request, since it is
+// evaluated again with the token usage on the response path.
+//
+// This returns nil if no such cost is configured.
+func (c *chatCompletionProcessorRouterFilter) buildRequestDynamicMetadata(model string, body *openai.ChatCompletionRequest) *structpb.Struct {
+	var metadataCost map[string]*structpb.Value
+	for i := range c.config.requestCosts {
+		rc := &c.config.requestCosts[i]
+		var cost uint32
+		switch {
+		case rc.Type == filterapi.LLMRequestCostTypeEstimatedInputToken:
+			cost = uint32(c.estimateInputTokens(body)) //nolint:gosec
+		case rc.Type == filterapi.LLMRequestCostTypeCEL && rc.celOnRequest:
+			costU64, err := llmcostcel.EvaluateProgram(rc.celProg, &llmcostcel.Input{
+				Model:                model,
+				EstimatedInputTokens: uint32(c.estimateInputTokens(body)), //nolint:gosec
+				RequestHeaders:       c.requestHeaders,
+				Stream:               body.Stream,
+			})
+			if err != nil {
+				c.logger.Warn("failed to evaluate CEL expression of the request cost on the request path",
+					slog.String("metadata_key", rc.MetadataKey), slog.String("error", err.Error()))
+				continue
+			}
+			cost = uint32(costU64) //nolint:gosec
+		default:
+			continue
+		}
+		if metadataCost == nil {
+			metadataCost = make(map[string]*structpb.Value)
+		}
+		metadataCost[rc.MetadataKey] = &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: float64(cost)}}
+	}
+	if metadataCost == nil {
+		return nil
+	}
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		c.config.metadataNamespace: {Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: metadataCost}}},
+	}}
+}
+
+// authorizeRequest authorizes the request to the model of the given rule, and returns the immediate response if
+// the request is rejected. The consumer header is populated with the consumer authenticated with the consumer key
+// so that the token quotas keyed by it are applied to the consumer, and the one sent by the client is ignored
+// otherwise so that it cannot be used to pick the consumer of the quotas.
+func (c *chatCompletionProcessorRouterFilter) authorizeRequest(routeName filterapi.RouteRuleName, model string) *extprocv3.ProcessingResponse {
+	consumer, resp := authorizeRequest(c.config, c.logger, c.requestHeaders, routeName, model)
+	if resp != nil {
+		return resp
+	}
+	if consumer != "" {
+		c.consumer = consumer
+		if c.config.consumerHeaderKey != "" {
+			c.requestHeaders[c.config.consumerHeaderKey] = consumer
+		}
+	} else if c.consumer == "" && c.config.consumerHeaderKey != "" {
+		delete(c.requestHeaders, c.config.consumerHeaderKey)
+	}
+	return nil
+}
+
+// selectRouteByContextWindow checks whether the request fits into the context window of the backends of the
+// given route rule. If it does not, the request is re-routed with the long context fallback model of the rule
+// until a rule that fits the request is found.
+//
+// This returns the final route rule name and model. When no rule can fit the request, this returns the immediate
+// response with the "context_length_exceeded" error.
+//
+// The requested output tokens are max_completion_tokens, or the deprecated max_tokens when it's not set.
+func (c *chatCompletionProcessorRouterFilter) selectRouteByContextWindow(routeName filterapi.RouteRuleName, model string, body *openai.ChatCompletionRequest) (
+	filterapi.RouteRuleName, string, *extprocv3.ProcessingResponse, error,
+) {
+	// Most of the rules do not declare the token limits, so the prompt is only tokenized when the first one does.
+	if rule, ok := c.config.rules[routeName]; !ok || ruleTokenLimits(rule) == (tokenLimits{}) {
+		return routeName, model, nil, nil
+	}
+	promptTokens := c.estimateInputTokens(body)
+	maxTokens, maxTokensParam := 0, "max_tokens"
+	if body.MaxCompletionTokens != nil {
+		maxTokens, maxTokensParam = int(*body.MaxCompletionTokens), "max_completion_tokens"
+	} else if body.MaxTokens != nil {
+		maxTokens = int(*body.MaxTokens)
+	}
+	// Bound the number of upgrades by the number of rules so that a misconfigured cycle of fallbacks terminates.
+	for range len(c.config.rules) + 1 {
+		rule, ok := c.config.rules[routeName]
+		if !ok {
+			return routeName, model, nil, nil
+		}
+		limits := ruleTokenLimits(rule)
+		if limits.fits(promptTokens, maxTokens) {
+			return routeName, model, nil, nil
+		}
+		if rule.LongContextFallbackModel == "" || rule.LongContextFallbackModel == model {
+			c.logger.Info("request does not fit into the context window",
+				"route", routeName, "model", model, "prompt_tokens", promptTokens, maxTokensParam, maxTokens)
+			return "", "", contextLengthExceededResponse(limits, promptTokens, maxTokens, maxTokensParam), nil
+		}
+
+		c.logger.Debug("upgrading request to the long context fallback model",
+			"route", routeName, "model", model, "fallback_model", rule.LongContextFallbackModel, "prompt_tokens", promptTokens)
+		model = rule.LongContextFallbackModel
+		c.requestHeaders[c.config.modelNameHeaderKey] = model
+		var err error
+		routeName, err = c.config.router.Calculate(c.requestHeaders)
+		if err != nil {
+			if errors.Is(err, x.ErrNoMatchingRule) {
+				return "", "", &extprocv3.ProcessingResponse{
+					Response: &extprocv3.ProcessingResponse_ImmediateResponse{
+						ImmediateResponse: &extprocv3.ImmediateResponse{
+							Status: &typev3.HttpStatus{Code: typev3.StatusCode_NotFound},
+							Body:   []byte(err.Error()),
+						},
+					},
+				}, nil
+			}
+			return "", "", nil, fmt.Errorf("failed to calculate route for the long context fallback model: %w", err)
+		}
+	}
+	return "", "", nil, fmt.Errorf("long context fallback models form a cycle starting at model %q", model)
+}
+
 // chatCompletionProcessorUpstreamFilter implements [Processor] for the `/v1/chat/completion` endpoint at the upstream filter.
 //
 // This is created per retry and handles the translation as well as the authentication of the request.
@@ -170,25 +686,56 @@ type chatCompletionProcessorUpstreamFilter struct {
 	metrics x.ChatCompletionMetrics
 	// stream is set to true if the request is a streaming request.
 	stream bool
+	// hedgeAttempt is the attempt number of this upstream filter when the request is hedged, and zero otherwise.
+	hedgeAttempt int
+	// hedgeAttemptTagged is true once the response headers of this attempt have been tagged with [hedgeAttemptHeader].
+	hedgeAttemptTagged bool
+	// hedgeCosts is the estimated token usage of the other attempts of the hedged request, which is added to
+	// the cost metadata when this attempt wins the race.
+	hedgeCosts translator.LLMTokenUsage
+	// estimatedInputTokens is the number of input tokens estimated by the router filter, if any.
+	estimatedInputTokens uint32
+	// circuitBreakers and circuitBreaker are the circuit breakers and the configuration of the backend, if any.
+	circuitBreakers *CircuitBreakers
+	circuitBreaker  *filterapi.CircuitBreaker
+	// circuitRejected is true if the request was not sent to the backend because its circuit breaker is open.
+	circuitRejected bool
+	// pricing is the price list of the models served by the backend, if any.
+	pricing []filterapi.ModelPricing
+	// backendSchema is the name of the API schema of the backend.
+	backendSchema string
+	// requestStart is the time when the request headers are processed at the upstream filter.
+	requestStart time.Time
+	// usageLedger is the usage ledger that the usage record of the request is written to, if any.
+	usageLedger *ledger.Ledger
+	// consumer is the consumer authenticated with the consumer key at the router filter, if any, and metricAttrs
+	// are the extra attributes of the recorded metrics identifying the consumer.
+	consumer    string
+	metricAttrs []attribute.KeyValue
 }
 
 // selectTranslator selects the translator based on the output schema.
-func (c *chatCompletionProcessorUpstreamFilter) selectTranslator(out filterapi.VersionedAPISchema) error {
+func (c *chatCompletionProcessorUpstreamFilter) selectTranslator(out filterapi.VersionedAPISchema) (err error) {
+	c.translator, err = newChatCompletionTranslator(out, c.modelNameOverride)
+	return
+}
+
+// newChatCompletionTranslator returns the translator for the given output schema.
+func newChatCompletionTranslator(out filterapi.VersionedAPISchema, modelNameOverride string) (translator.OpenAIChatCompletionTranslator, error) {
 	switch out.Name {
 	case filterapi.APISchemaOpenAI:
-		c.translator = translator.NewChatCompletionOpenAIToOpenAITranslator(out.Version, c.modelNameOverride)
+		return translator.NewChatCompletionOpenAIToOpenAITranslator(out.Version, modelNameOverride), nil
 	case filterapi.APISchemaAWSBedrock:
-		c.translator = translator.NewChatCompletionOpenAIToAWSBedrockTranslator(c.modelNameOverride)
+		return translator.NewChatCompletionOpenAIToAWSBedrockTranslator(modelNameOverride), nil
 	case filterapi.APISchemaAzureOpenAI:
-		c.translator = translator.NewChatCompletionOpenAIToAzureOpenAITranslator(out.Version, c.modelNameOverride)
+		return translator.NewChatCompletionOpenAIToAzureOpenAITranslator(out.Version, modelNameOverride), nil
 	case filterapi.APISchemaGCPVertexAI:
-		c.translator = translator.NewChatCompletionOpenAIToGCPVertexAITranslator()
+		return translator.NewChatCompletionOpenAIToGCPVertexAITranslator(), nil
 	case filterapi.APISchemaGCPAnthropic:
-		c.translator = translator.NewChatCompletionOpenAIToGCPAnthropicTranslator()
+		return translator.NewChatCompletionOpenAIToGCPAnthropicTranslator(), nil
 	default:
-		return fmt.Errorf("unsupported API schema: backend=%s", out)
+		return nil, fmt.Errorf("unsupported API schema: backend=%s", out)
 	}
-	return nil
 }
 
 // ProcessRequestHeaders implements [Processor.ProcessRequestHeaders].
@@ -198,13 +745,23 @@ func (c *chatCompletionProcessorUpstreamFilter) selectTranslator(out filterapi.V
 // with the status CONTINUE_AND_REPLACE. This will allows Envoy to not send the request body again
 // to the extproc.
 func (c *chatCompletionProcessorUpstreamFilter) ProcessRequestHeaders(ctx context.Context, _ *corev3.HeaderMap) (res *extprocv3.ProcessingResponse, err error) {
+	if !c.circuitBreakers.allow(ctx, c.backendName, c.circuitBreaker) {
+		// Envoy treats the immediate response of the upstream filter as the response of the upstream,
+		// so the request is retried on the other backends by the retry on 503 that the extension server
+		// configures on the routes of the rules with circuit breakers.
+		c.circuitRejected = true
+		return openAIErrorResponse(typev3.StatusCode_ServiceUnavailable, "server_error", "backend_unavailable", "",
+			fmt.Sprintf("The backend %s is temporarily unavailable.", c.backendName)), nil
+	}
+
 	defer func() {
 		if err != nil {
-			c.metrics.RecordRequestCompletion(ctx, false)
+			c.metrics.RecordRequestCompletion(ctx, false, c.metricAttrs...)
 		}
 	}()
 
 	// Start tracking metrics for this request.
+	c.requestStart = time.Now()
 	c.metrics.StartRequest(c.requestHeaders)
 	c.metrics.SetModel(c.requestHeaders[c.config.modelNameHeaderKey])
 
@@ -230,6 +787,18 @@ func (c *chatCompletionProcessorUpstreamFilter) ProcessRequestHeaders(ctx contex
 		dm = buildContentLengthDynamicMetadataOnRequest(c.config, len(bm))
 	}
 
+	var mode *extprocv3http.ProcessingMode
+	if c.hedgeAttempt > 0 {
+		// The response headers of the hedged attempts need to be tagged with the attempt number at this filter.
+		// See [hedgeAttemptHeader].
+		mode = &extprocv3http.ProcessingMode{
+			RequestHeaderMode:  extprocv3http.ProcessingMode_SEND,
+			RequestBodyMode:    extprocv3http.ProcessingMode_NONE,
+			ResponseHeaderMode: extprocv3http.ProcessingMode_SEND,
+			ResponseBodyMode:   extprocv3http.ProcessingMode_NONE,
+		}
+	}
+
 	return &extprocv3.ProcessingResponse{
 		Response: &extprocv3.ProcessingResponse_RequestHeaders{
 			RequestHeaders: &extprocv3.HeadersResponse{
@@ -240,6 +809,7 @@ func (c *chatCompletionProcessorUpstreamFilter) ProcessRequestHeaders(ctx contex
 			},
 		},
 		DynamicMetadata: dm,
+		ModeOverride:    mode,
 	}, nil
 }
 
@@ -250,9 +820,24 @@ func (c *chatCompletionProcessorUpstreamFilter) ProcessRequestBody(context.Conte
 
 // ProcessResponseHeaders implements [Processor.ProcessResponseHeaders].
 func (c *chatCompletionProcessorUpstreamFilter) ProcessResponseHeaders(ctx context.Context, headers *corev3.HeaderMap) (res *extprocv3.ProcessingResponse, err error) {
+	if c.hedgeAttempt > 0 && !c.hedgeAttemptTagged {
+		// This is the upstream filter processing the response headers of its own attempt, which precedes
+		// the processing at the router filter. See [hedgeAttemptHeader].
+		c.hedgeAttemptTagged = true
+		return &extprocv3.ProcessingResponse{Response: &extprocv3.ProcessingResponse_ResponseHeaders{
+			ResponseHeaders: &extprocv3.HeadersResponse{
+				Response: &extprocv3.CommonResponse{HeaderMutation: &extprocv3.HeaderMutation{
+					SetHeaders: []*corev3.HeaderValueOption{{Header: &corev3.HeaderValue{
+						Key: hedgeAttemptHeader, RawValue: []byte(strconv.Itoa(c.hedgeAttempt)),
+					}}},
+				}},
+			},
+		}}, nil
+	}
+
 	defer func() {
 		if err != nil {
-			c.metrics.RecordRequestCompletion(ctx, false)
+			c.metrics.RecordRequestCompletion(ctx, false, c.metricAttrs...)
 		}
 	}()
 
@@ -279,7 +864,7 @@ func (c *chatCompletionProcessorUpstreamFilter) ProcessResponseHeaders(ctx conte
 // ProcessResponseBody implements [Processor.ProcessResponseBody].
 func (c *chatCompletionProcessorUpstreamFilter) ProcessResponseBody(ctx context.Context, body *extprocv3.HttpBody) (res *extprocv3.ProcessingResponse, err error) {
 	defer func() {
-		c.metrics.RecordRequestCompletion(ctx, err == nil)
+		c.metrics.RecordRequestCompletion(ctx, err == nil, c.metricAttrs...)
 	}()
 	var br io.Reader
 	var isGzip bool
@@ -323,20 +908,41 @@ func (c *chatCompletionProcessorUpstreamFilter) ProcessResponseBody(ctx context.
 	}
 
 	// TODO: we need to investigate if we need to accumulate the token usage for streaming responses.
-	c.costs.InputTokens += tokenUsage.InputTokens
-	c.costs.OutputTokens += tokenUsage.OutputTokens
-	c.costs.TotalTokens += tokenUsage.TotalTokens
+	c.costs.Add(&tokenUsage)
 
 	// Update metrics with token usage.
-	c.metrics.RecordTokenUsage(ctx, tokenUsage.InputTokens, tokenUsage.OutputTokens, tokenUsage.TotalTokens)
+	c.metrics.RecordTokenUsage(ctx, tokenUsage.InputTokens, tokenUsage.OutputTokens, tokenUsage.TotalTokens, c.metricAttrs...)
+	if m, ok := c.metrics.(x.TokenUsageDetailsMetrics); ok {
+		m.RecordTokenUsageDetails(ctx, tokenUsage.CachedInputTokens, tokenUsage.ReasoningTokens,
+			tokenUsage.AudioInputTokens, tokenUsage.AudioOutputTokens, c.metricAttrs...)
+	}
 	if c.stream {
 		// Token latency is only recorded for streaming responses, otherwise it doesn't make sense since
 		// these metrics are defined as a difference between the two output events.
-		c.metrics.RecordTokenLatency(ctx, tokenUsage.OutputTokens)
+		c.metrics.RecordTokenLatency(ctx, tokenUsage.OutputTokens, c.metricAttrs...)
 	}
 
-	if body.EndOfStream && len(c.config.requestCosts) > 0 {
-		metadata, err := buildDynamicMetadata(c.config, &c.costs, c.requestHeaders, c.modelNameOverride, c.backendName)
+	if !body.EndOfStream {
+		return resp, nil
+	}
+	costs := c.costs
+	costs.Add(&c.hedgeCosts)
+	var costMicroUSD float64
+	if p := c.modelPricing(); p != nil {
+		costMicroUSD = requestCostMicroUSD(p, &costs)
+		if m, ok := c.metrics.(x.RequestCostMetrics); ok {
+			m.RecordRequestCost(ctx, costMicroUSD/1e6, c.metricAttrs...)
+		}
+	}
+	recordUsage(c.usageLedger, &ledger.Record{
+		Consumer:  c.consumer,
+		Operation: "chat",
+		Model:     c.backendModel(),
+		Backend:   c.backendName,
+		Stream:    c.stream,
+	}, c.requestHeaders, c.responseHeaders, &costs, costMicroUSD, c.requestStart)
+	if len(c.config.requestCosts) > 0 {
+		metadata, err := buildDynamicMetadata(c.config, c.costInput(&costs), costMicroUSD, c.modelNameOverride, c.backendName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to build dynamic metadata: %w", err)
 		}
@@ -346,6 +952,7 @@ func (c *chatCompletionProcessorUpstreamFilter) ProcessResponseBody(ctx context.
 		}
 		resp.DynamicMetadata = metadata
 	}
+	resp.DynamicMetadata = mergeDynamicMetadata(resp.DynamicMetadata, c.config.metadataNamespace, consumerDynamicMetadata(c.consumer))
 
 	return resp, nil
 }
@@ -353,28 +960,91 @@ func (c *chatCompletionProcessorUpstreamFilter) ProcessResponseBody(ctx context.
 // SetBackend implements [Processor.SetBackend].
 func (c *chatCompletionProcessorUpstreamFilter) SetBackend(ctx context.Context, b *filterapi.Backend, backendHandler backendauth.Handler, routeProcessor Processor) (err error) {
 	defer func() {
-		c.metrics.RecordRequestCompletion(ctx, err == nil)
+		c.metrics.RecordRequestCompletion(ctx, err == nil, c.metricAttrs...)
 	}()
 	rp, ok := routeProcessor.(*chatCompletionProcessorRouterFilter)
 	if !ok {
 		panic("BUG: expected routeProcessor to be of type *chatCompletionProcessorRouterFilter")
 	}
-	rp.upstreamFilterCount++
+	if rp.consumer != "" {
+		c.consumer = rp.consumer
+		c.metricAttrs = []attribute.KeyValue{metrics.ConsumerAttribute(rp.consumer)}
+	}
 	c.metrics.SetBackend(b)
 	c.modelNameOverride = b.ModelNameOverride
 	c.backendName = b.Name
+	c.circuitBreaker = b.CircuitBreaker
+	c.pricing = b.Pricing
+	c.backendSchema = string(b.Schema.Name)
+	c.estimatedInputTokens = uint32(rp.estimatedInputTokens) //nolint:gosec
 	if err = c.selectTranslator(b.Schema); err != nil {
 		return fmt.Errorf("failed to select translator: %w", err)
 	}
 	c.handler = backendHandler
 	c.originalRequestBody = rp.originalRequestBody
 	c.originalRequestBodyRaw = rp.originalRequestBodyRaw
-	c.onRetry = rp.upstreamFilterCount > 1
 	c.stream = c.originalRequestBody.Stream
+	if rp.hedge != nil {
+		// The attempts run concurrently, so the router filter picks the upstream filter at the response headers.
+		c.hedgeAttempt = rp.hedge.addAttempt(c)
+		c.onRetry = c.hedgeAttempt > 1
+		return
+	}
+	if prev, ok := rp.upstreamFilter.(*chatCompletionProcessorUpstreamFilter); ok && !prev.circuitRejected {
+		// The previous attempt has failed since Envoy retries the request.
+		c.circuitBreakers.record(ctx, prev.backendName, prev.circuitBreaker, 0, "")
+	}
+	rp.upstreamFilterCount++
+	c.onRetry = rp.upstreamFilterCount > 1
 	rp.upstreamFilter = c
 	return
 }
 
+// costInput returns the variables of the CEL cost expressions for the given token usage.
+func (c *chatCompletionProcessorUpstreamFilter) costInput(costs *translator.LLMTokenUsage) *llmcostcel.Input {
+	in := &llmcostcel.Input{
+		Model:                c.requestHeaders[c.config.modelNameHeaderKey],
+		Backend:              c.requestHeaders[c.config.selectedRouteHeaderKey],
+		BackendSchema:        c.backendSchema,
+		InputTokens:          costs.InputTokens,
+		OutputTokens:         costs.OutputTokens,
+		TotalTokens:          costs.TotalTokens,
+		CachedInputTokens:    costs.CachedInputTokens,
+		ReasoningTokens:      costs.ReasoningTokens,
+		AudioInputTokens:     costs.AudioInputTokens,
+		AudioOutputTokens:    costs.AudioOutputTokens,
+		EstimatedInputTokens: c.estimatedInputTokens,
+		RequestHeaders:       c.requestHeaders,
+		Stream:               c.stream,
+	}
+	if !c.requestStart.IsZero() {
+		in.LatencyMs = uint64(time.Since(c.requestStart).Milliseconds()) //nolint:gosec
+	}
+	if c.stream {
+		in.TimeToFirstTokenMs = uint64(c.metrics.GetTimeToFirstTokenMs())
+	}
+	return in
+}
+
+// modelPricing returns the pricing of the model sent to the backend, or nil if the model is not priced.
+func (c *chatCompletionProcessorUpstreamFilter) modelPricing() *filterapi.ModelPricing {
+	if len(c.pricing) == 0 {
+		return nil
+	}
+	return findModelPricing(c.pricing, c.backendModel())
+}
+
+// backendModel returns the name of the model sent to the backend.
+func (c *chatCompletionProcessorUpstreamFilter) backendModel() string {
+	if c.modelNameOverride != "" {
+		return c.modelNameOverride
+	}
+	if c.originalRequestBody != nil {
+		return c.originalRequestBody.Model
+	}
+	return c.requestHeaders[c.config.modelNameHeaderKey]
+}
+
 func (c *chatCompletionProcessorUpstreamFilter) mergeWithTokenLatencyMetadata(metadata *structpb.Struct) {
 	timeToFirstTokenMs := c.metrics.GetTimeToFirstTokenMs()
 	interTokenLatencyMs := c.metrics.GetInterTokenLatencyMs()
@@ -423,27 +1093,34 @@ func buildContentLengthDynamicMetadataOnRequest(config *processorConfig, content
 	return metadata
 }
 
-func buildDynamicMetadata(config *processorConfig, costs *translator.LLMTokenUsage, requestHeaders map[string]string, modelNameOverride, backendName string) (*structpb.Struct, error) {
+// buildDynamicMetadata builds the dynamic metadata of the request costs and the selected route at the end of the response.
+func buildDynamicMetadata(config *processorConfig, in *llmcostcel.Input, costMicroUSD float64, modelNameOverride, backendName string) (*structpb.Struct, error) {
 	metadataCost := make(map[string]*structpb.Value, len(config.requestCosts))
 	for i := range config.requestCosts {
 		rc := &config.requestCosts[i]
 		var cost uint32
 		switch rc.Type {
 		case filterapi.LLMRequestCostTypeInputToken:
-			cost = costs.InputTokens
+			cost = in.InputTokens
 		case filterapi.LLMRequestCostTypeOutputToken:
-			cost = costs.OutputTokens
+			cost = in.OutputTokens
 		case filterapi.LLMRequestCostTypeTotalToken:
-			cost = costs.TotalTokens
+			cost = in.TotalTokens
+		case filterapi.LLMRequestCostTypeEstimatedInputToken:
+			cost = in.EstimatedInputTokens
+		case filterapi.LLMRequestCostTypeCachedInputToken:
+			cost = in.CachedInputTokens
+		case filterapi.LLMRequestCostTypeReasoningToken:
+			cost = in.ReasoningTokens
+		case filterapi.LLMRequestCostTypeAudioInputToken:
+			cost = in.AudioInputTokens
+		case filterapi.LLMRequestCostTypeAudioOutputToken:
+			cost = in.AudioOutputTokens
+		case filterapi.LLMRequestCostTypeMicroUSD:
+			metadataCost[rc.MetadataKey] = &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: math.Round(costMicroUSD)}}
+			continue
 		case filterapi.LLMRequestCostTypeCEL:
-			costU64, err := llmcostcel.EvaluateProgram(
-				rc.celProg,
-				requestHeaders[config.modelNameHeaderKey],
-				requestHeaders[config.selectedRouteHeaderKey],
-				costs.InputTokens,
-				costs.OutputTokens,
-				costs.TotalTokens,
-			)
+			costU64, err := llmcostcel.EvaluateProgram(rc.celProg, in)
 			if err != nil {
 				return nil, fmt.Errorf("failed to evaluate CEL expression: %w", err)
 			}
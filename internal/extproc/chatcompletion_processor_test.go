@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"strconv"
+	"strings"
 	"testing"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
@@ -23,6 +25,8 @@ import (
 	"github.com/envoyproxy/ai-gateway/filterapi"
 	"github.com/envoyproxy/ai-gateway/filterapi/x"
 	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/quota"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/router"
 	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
 	"github.com/envoyproxy/ai-gateway/internal/llmcostcel"
 )
@@ -30,19 +34,21 @@ import (
 func TestChatCompletion_Schema(t *testing.T) {
 	t.Run("unsupported", func(t *testing.T) {
 		cfg := &processorConfig{schema: filterapi.VersionedAPISchema{Name: "Foo", Version: "v123"}}
-		_, err := ChatCompletionProcessorFactory(nil)(cfg, nil, slog.Default(), false)
+		_, err := ChatCompletionProcessorFactory(nil, ProcessorOptions{})(cfg, nil, slog.Default(), false)
 		require.ErrorContains(t, err, "unsupported API schema: Foo")
 	})
 	t.Run("supported openai / on route", func(t *testing.T) {
 		cfg := &processorConfig{schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI, Version: "v123"}}
-		routeFilter, err := ChatCompletionProcessorFactory(nil)(cfg, nil, slog.Default(), false)
+		qs := quota.New(quota.NewMemoryStore())
+		routeFilter, err := ChatCompletionProcessorFactory(nil, ProcessorOptions{Quotas: qs})(cfg, nil, slog.Default(), false)
 		require.NoError(t, err)
 		require.NotNil(t, routeFilter)
 		require.IsType(t, &chatCompletionProcessorRouterFilter{}, routeFilter)
+		require.Same(t, qs, routeFilter.(*chatCompletionProcessorRouterFilter).quotas)
 	})
 	t.Run("supported openai / on upstream", func(t *testing.T) {
 		cfg := &processorConfig{schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI, Version: "v123"}}
-		routeFilter, err := ChatCompletionProcessorFactory(nil)(cfg, nil, slog.Default(), true)
+		routeFilter, err := ChatCompletionProcessorFactory(nil, ProcessorOptions{})(cfg, nil, slog.Default(), true)
 		require.NoError(t, err)
 		require.NotNil(t, routeFilter)
 		require.IsType(t, &chatCompletionProcessorUpstreamFilter{}, routeFilter)
@@ -74,7 +80,12 @@ func Test_chatCompletionProcessorUpstreamFilter_SelectTranslator(t *testing.T) {
 
 func Test_chatCompletionProcessorRouterFilter_ProcessRequestBody(t *testing.T) {
 	t.Run("body parser error", func(t *testing.T) {
-		p := &chatCompletionProcessorRouterFilter{}
+		headers := map[string]string{":path": "/foo", "x-ai-eg-model": ""}
+		p := &chatCompletionProcessorRouterFilter{
+			config:         &processorConfig{router: mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}, modelNameHeaderKey: "x-ai-eg-model"},
+			requestHeaders: map[string]string{":path": "/foo"},
+			logger:         slog.Default(),
+		}
 		_, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte("nonjson")})
 		require.ErrorContains(t, err, "invalid character 'o' in literal null")
 	})
@@ -132,6 +143,225 @@ func Test_chatCompletionProcessorRouterFilter_ProcessRequestBody(t *testing.T) {
 		require.Equal(t, "x-ai-eg-original-path", setHeaders[2].Header.Key)
 		require.Equal(t, "/foo", string(setHeaders[2].Header.RawValue))
 	})
+
+	t.Run("context window", func(t *testing.T) {
+		const modelKey = "x-ai-gateway-model-key"
+		const modelRouteKey = "x-ai-gateway-route-key"
+		config := &filterapi.Config{Rules: []filterapi.RouteRule{
+			{
+				Name:                     "small",
+				Headers:                  []filterapi.HeaderMatch{{Name: modelKey, Value: "small-model"}},
+				Backends:                 []filterapi.Backend{{Name: "small-backend", ContextWindow: 100}},
+				LongContextFallbackModel: "large-model",
+			},
+			{
+				Name:     "large",
+				Headers:  []filterapi.HeaderMatch{{Name: modelKey, Value: "large-model"}},
+				Backends: []filterapi.Backend{{Name: "large-backend", ContextWindow: 1000, MaxOutputTokens: 100}},
+			},
+		}}
+		rt, err := router.New(config, nil)
+		require.NoError(t, err)
+		rules := map[filterapi.RouteRuleName]*filterapi.RouteRule{}
+		for i := range config.Rules {
+			rules[config.Rules[i].Name] = &config.Rules[i]
+		}
+		newProcessor := func() *chatCompletionProcessorRouterFilter {
+			return &chatCompletionProcessorRouterFilter{
+				config: &processorConfig{
+					router: rt, rules: rules,
+					modelNameHeaderKey: modelKey, selectedRouteHeaderKey: modelRouteKey,
+				},
+				requestHeaders: map[string]string{":path": "/foo"},
+				logger:         slog.Default(),
+			}
+		}
+		bodyWithContent := func(content string, maxTokens int) []byte {
+			return fmt.Appendf(nil, `{"model":"small-model","messages":[{"role":"user","content":%q}],"max_tokens":%d}`, content, maxTokens)
+		}
+
+		t.Run("fits", func(t *testing.T) {
+			p := newProcessor()
+			resp, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: bodyWithContent("hello", 10)})
+			require.NoError(t, err)
+			re := resp.GetRequestBody().GetResponse()
+			require.Nil(t, re.BodyMutation)
+			setHeaders := re.GetHeaderMutation().SetHeaders
+			require.Equal(t, "small-model", string(setHeaders[0].Header.RawValue))
+			require.Equal(t, "small", string(setHeaders[1].Header.RawValue))
+		})
+		t.Run("upgrade", func(t *testing.T) {
+			p := newProcessor()
+			resp, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: bodyWithContent(strings.Repeat("a", 400), 10)})
+			require.NoError(t, err)
+			re := resp.GetRequestBody().GetResponse()
+			setHeaders := re.GetHeaderMutation().SetHeaders
+			require.Len(t, setHeaders, 4)
+			require.Equal(t, "large-model", string(setHeaders[0].Header.RawValue))
+			require.Equal(t, "large", string(setHeaders[1].Header.RawValue))
+			require.Equal(t, "content-length", setHeaders[3].Header.Key)
+			newBody := re.GetBodyMutation().GetBody()
+			require.Equal(t, strconv.Itoa(len(newBody)), string(setHeaders[3].Header.RawValue))
+			var body openai.ChatCompletionRequest
+			require.NoError(t, json.Unmarshal(newBody, &body))
+			require.Equal(t, "large-model", body.Model)
+			require.Equal(t, "large-model", p.originalRequestBody.Model)
+			require.Equal(t, newBody, p.originalRequestBodyRaw)
+		})
+		t.Run("exceeded", func(t *testing.T) {
+			p := newProcessor()
+			resp, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: bodyWithContent(strings.Repeat("a", 4000), 10)})
+			require.NoError(t, err)
+			ir := resp.GetImmediateResponse()
+			require.NotNil(t, ir)
+			require.Equal(t, typev3.StatusCode_BadRequest, ir.GetStatus().GetCode())
+			require.Contains(t, string(ir.Body), "context_length_exceeded")
+			require.Contains(t, string(ir.Body), "maximum context length is 1000 tokens")
+		})
+		t.Run("max tokens exceeded", func(t *testing.T) {
+			p := newProcessor()
+			resp, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: bodyWithContent("hello", 500)})
+			require.NoError(t, err)
+			ir := resp.GetImmediateResponse()
+			require.NotNil(t, ir)
+			require.Contains(t, string(ir.Body), "max_tokens is too large: 500")
+		})
+		t.Run("max completion tokens", func(t *testing.T) {
+			// max_completion_tokens takes precedence over the deprecated max_tokens.
+			p := newProcessor()
+			body := `{"model":"small-model","messages":[{"role":"user","content":"hello"}],"max_tokens":10,"max_completion_tokens":500}`
+			resp, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(body)})
+			require.NoError(t, err)
+			ir := resp.GetImmediateResponse()
+			require.NotNil(t, ir)
+			require.Contains(t, string(ir.Body), `"param":"max_completion_tokens"`)
+			require.Contains(t, string(ir.Body), "max_completion_tokens is too large: 500")
+
+			p = newProcessor()
+			body = `{"model":"small-model","messages":[{"role":"user","content":"hello"}],"max_tokens":500,"max_completion_tokens":10}`
+			resp, err = p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(body)})
+			require.NoError(t, err)
+			require.Nil(t, resp.GetImmediateResponse())
+			require.Equal(t, "small", string(resp.GetRequestBody().GetResponse().GetHeaderMutation().SetHeaders[1].Header.RawValue))
+		})
+	})
+
+	t.Run("session affinity", func(t *testing.T) {
+		const sessionKey = "x-session-key"
+		process := func(t *testing.T, headers map[string]string) *extprocv3.HeaderMutation {
+			rt := mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+			p := &chatCompletionProcessorRouterFilter{
+				config: &processorConfig{
+					router: rt, sessionKeyHeaderKey: sessionKey,
+					rules: map[filterapi.RouteRuleName]*filterapi.RouteRule{
+						"some-route": {Name: "some-route", SessionAffinity: &filterapi.SessionAffinity{Header: "X-Session-Id"}},
+					},
+				},
+				requestHeaders: headers,
+				logger:         slog.Default(),
+			}
+			resp, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: bodyFromModel(t, "some-model", false)})
+			require.NoError(t, err)
+			// The session key sent by the client is never used.
+			require.NotContains(t, headers, sessionKey)
+			return resp.GetRequestBody().GetResponse().GetHeaderMutation()
+		}
+
+		headers := map[string]string{":path": "/foo", "x-session-id": "abc", sessionKey: "spoofed"}
+		hm := process(t, headers)
+		require.Len(t, hm.SetHeaders, 4)
+		require.Equal(t, sessionKey, hm.SetHeaders[3].Header.Key)
+		require.Equal(t, sessionKeyHash(&filterapi.SessionAffinity{Header: "x-session-id"}, headers, nil), string(hm.SetHeaders[3].Header.RawValue))
+
+		// The session key sent by the client is removed when no session key is derived.
+		hm = process(t, map[string]string{":path": "/foo", sessionKey: "spoofed"})
+		require.Len(t, hm.SetHeaders, 3)
+		require.Contains(t, hm.RemoveHeaders, sessionKey)
+	})
+
+	t.Run("estimated input tokens", func(t *testing.T) {
+		headers := map[string]string{":path": "/foo"}
+		celProg, err := llmcostcel.NewProgram("input_tokens == uint(0) ? estimated_input_tokens * uint(2) : total_tokens")
+		require.NoError(t, err)
+		celProgTotal, err := llmcostcel.NewProgram("total_tokens")
+		require.NoError(t, err)
+		// This overflows on the request path where the estimated input tokens are more than one.
+		celProgFail, err := llmcostcel.NewProgram("input_tokens == uint(0) ? uint(1) - estimated_input_tokens : total_tokens")
+		require.NoError(t, err)
+		p := &chatCompletionProcessorRouterFilter{
+			config: &processorConfig{
+				router:            mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+				metadataNamespace: "ns",
+				requestCosts: []processorConfigRequestCost{
+					{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeInputToken, MetadataKey: "input"}},
+					{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeEstimatedInputToken, MetadataKey: "estimated"}},
+					{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeCEL, MetadataKey: "cel"}, celProg: celProg, celOnRequest: true},
+					{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeCEL, MetadataKey: "cel_total"}, celProg: celProgTotal},
+					{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeCEL, MetadataKey: "cel_fail"}, celProg: celProgFail, celOnRequest: true},
+				},
+			},
+			requestHeaders: headers,
+			logger:         slog.Default(),
+		}
+		body := []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hello world"}]}`)
+		resp, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: body})
+		require.NoError(t, err)
+
+		// The text is counted by the tokenizer of the model in addition to the formatting of the messages.
+		exp := tokensReplyPriming + tokensPerMessage + 2
+		require.Equal(t, exp, p.estimatedInputTokens)
+		fields := resp.DynamicMetadata.Fields["ns"].GetStructValue().Fields
+		// The CEL expression not using the estimate is not evaluated, and the one failing is skipped.
+		require.Len(t, fields, 2, "only the costs known on the request path are set")
+		require.Equal(t, float64(exp), fields["estimated"].GetNumberValue())
+		require.Equal(t, float64(2*exp), fields["cel"].GetNumberValue())
+
+		// The estimate is also available in the cost metadata of the response.
+		uf := &chatCompletionProcessorUpstreamFilter{
+			config: p.config, requestHeaders: headers, logger: slog.Default(), metrics: &mockChatCompletionMetrics{},
+		}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, p))
+		resp, err = uf.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{
+			Body: []byte(`{"usage":{"prompt_tokens":9,"completion_tokens":1,"total_tokens":10}}`), EndOfStream: true,
+		})
+		require.NoError(t, err)
+		fields = resp.DynamicMetadata.Fields["ns"].GetStructValue().Fields
+		require.Equal(t, float64(9), fields["input"].GetNumberValue())
+		require.Equal(t, float64(exp), fields["estimated"].GetNumberValue())
+		require.Equal(t, float64(10), fields["cel"].GetNumberValue())
+		require.Equal(t, float64(10), fields["cel_total"].GetNumberValue())
+		require.Equal(t, float64(10), fields["cel_fail"].GetNumberValue())
+	})
+
+	t.Run("no request-time cost", func(t *testing.T) {
+		headers := map[string]string{":path": "/foo"}
+		celProg, err := llmcostcel.NewProgram("total_tokens")
+		require.NoError(t, err)
+		p := &chatCompletionProcessorRouterFilter{
+			config: &processorConfig{
+				router:            mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+				metadataNamespace: "ns",
+				requestCosts: []processorConfigRequestCost{
+					{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeCEL, MetadataKey: "cel"}, celProg: celProg},
+				},
+			},
+			requestHeaders: headers,
+			logger:         slog.Default(),
+		}
+		resp, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: bodyFromModel(t, "some-model", false)})
+		require.NoError(t, err)
+		require.Nil(t, resp.DynamicMetadata)
+		require.False(t, p.inputTokensEstimated, "the prompt must not be tokenized")
+	})
+}
+
+func Test_chatCompletionProcessorRouterFilter_screenableResponse(t *testing.T) {
+	c := &chatCompletionProcessorRouterFilter{logger: slog.Default()}
+	require.True(t, c.screenableResponse(map[string]string{":status": "200"}, "guardrails"))
+	require.False(t, c.screenableResponse(map[string]string{":status": "500"}, "guardrails"))
+	require.False(t, c.screenableResponse(map[string]string{":status": "200", "content-encoding": "gzip"}, "redaction"))
 }
 
 func Test_chatCompletionProcessorUpstreamFilter_ProcessResponseHeaders(t *testing.T) {
@@ -217,13 +447,16 @@ func Test_chatCompletionProcessorUpstreamFilter_ProcessResponseBody(t *testing.T
 		mt := &mockTranslator{
 			t: t, expResponseBody: inBody,
 			retBodyMutation: expBodyMut, retHeaderMutation: expHeadMut,
-			retUsedToken: translator.LLMTokenUsage{OutputTokens: 123, InputTokens: 1},
+			retUsedToken: translator.LLMTokenUsage{OutputTokens: 123, InputTokens: 1, CachedInputTokens: 1, ReasoningTokens: 100},
 		}
 
 		celProgInt, err := llmcostcel.NewProgram("54321")
 		require.NoError(t, err)
 		celProgUint, err := llmcostcel.NewProgram("uint(9999)")
 		require.NoError(t, err)
+		celProgHeaders, err := llmcostcel.NewProgram(
+			"request_headers[?'x-tier'].orValue('') == 'gold' && stream && backend_schema == 'OpenAI' ? output_tokens * uint(2) : uint(0)")
+		require.NoError(t, err)
 		p := &chatCompletionProcessorUpstreamFilter{
 			translator: mt,
 			logger:     slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{})),
@@ -242,10 +475,22 @@ func Test_chatCompletionProcessorUpstreamFilter_ProcessResponseBody(t *testing.T
 						celProg:        celProgUint,
 						LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeCEL, MetadataKey: "cel_uint"},
 					},
+					{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeMicroUSD, MetadataKey: "micro_usd"}},
+					{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeCachedInputToken, MetadataKey: "cached_input_token_usage"}},
+					{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeReasoningToken, MetadataKey: "reasoning_token_usage"}},
+					{
+						celProg:        celProgHeaders,
+						LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeCEL, MetadataKey: "cel_headers"},
+					},
 				},
 			},
+			requestHeaders:    map[string]string{"x-tier": "gold"},
+			backendSchema:     string(filterapi.APISchemaOpenAI),
 			backendName:       "some_backend",
 			modelNameOverride: "ai_gateway_llm",
+			pricing: []filterapi.ModelPricing{
+				{Model: "ai_gateway_llm", InputPerMillionTokens: 2.5, OutputPerMillionTokens: 10, CachedInputPerMillionTokens: 1.25},
+			},
 		}
 		res, err := p.ProcessResponseBody(t.Context(), inBody)
 		require.NoError(t, err)
@@ -265,6 +510,18 @@ func Test_chatCompletionProcessorUpstreamFilter_ProcessResponseBody(t *testing.T
 			GetStructValue().Fields["cel_int"].GetNumberValue())
 		require.Equal(t, float64(9999), md.Fields["ai_gateway_llm_ns"].
 			GetStructValue().Fields["cel_uint"].GetNumberValue())
+		// 1 cached * $1.25/1M + 123 * $10/1M = 1231.25 micro dollars.
+		require.Equal(t, float64(1231), md.Fields["ai_gateway_llm_ns"].
+			GetStructValue().Fields["micro_usd"].GetNumberValue())
+		require.InDelta(t, 0.00123125, mm.requestCost, 1e-12)
+		require.Equal(t, float64(1), md.Fields["ai_gateway_llm_ns"].
+			GetStructValue().Fields["cached_input_token_usage"].GetNumberValue())
+		require.Equal(t, float64(100), md.Fields["ai_gateway_llm_ns"].
+			GetStructValue().Fields["reasoning_token_usage"].GetNumberValue())
+		require.Equal(t, uint32(1), mm.cachedInputTokens)
+		require.Equal(t, uint32(100), mm.reasoningTokens)
+		require.Equal(t, float64(246), md.Fields["ai_gateway_llm_ns"].
+			GetStructValue().Fields["cel_headers"].GetNumberValue())
 		require.Equal(t, "ai_gateway_llm", md.Fields["route"].GetStructValue().Fields["model_name_override"].GetStringValue())
 		require.Equal(t, "some_backend", md.Fields["route"].GetStructValue().Fields["backend_name"].GetStringValue())
 	})
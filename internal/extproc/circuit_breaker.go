@@ -0,0 +1,242 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
+)
+
+// circuitState is the state of a circuit breaker.
+type circuitState int64
+
+const (
+	// circuitClosed is the state where the requests are sent to the backend.
+	circuitClosed circuitState = iota
+	// circuitOpen is the state where the requests are not sent to the backend until the cooldown elapses.
+	circuitOpen
+	// circuitHalfOpen is the state where a single probe request is sent to the backend to decide the next state.
+	circuitHalfOpen
+)
+
+// String implements [fmt.Stringer].
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker is the state of the circuit breaker of a single backend.
+type circuitBreaker struct {
+	state               circuitState
+	consecutiveFailures int
+	// openUntil is the time until which the circuit stays open.
+	openUntil time.Time
+	// probeStartedAt is the time when the probe request was let through in the half-open state.
+	probeStartedAt time.Time
+	// lastFailureStatus is the HTTP status of the last failure, or zero if unknown, e.g. the request was retried.
+	lastFailureStatus int
+}
+
+// CircuitBreakers holds the circuit breakers of the backends keyed by [filterapi.Backend.Name].
+//
+// This is shared across the configuration reloads so that the state of the backends is not lost. Only the backends
+// with [filterapi.Backend.CircuitBreaker] configured are tracked.
+type CircuitBreakers struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+	metrics  *metrics.CircuitBreaker
+	now      func() time.Time
+}
+
+// NewCircuitBreakers creates a new CircuitBreakers. The metrics can be nil to disable the metrics.
+func NewCircuitBreakers(m *metrics.CircuitBreaker) *CircuitBreakers {
+	return &CircuitBreakers{breakers: make(map[string]*circuitBreaker), metrics: m, now: time.Now}
+}
+
+// isFailureStatus returns true if the status of the response means that the backend failed or throttled the request.
+// 529 is used by some providers, e.g. Anthropic, when they are overloaded.
+func isFailureStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// available returns true if a request may be sent to the backend without consuming the probe of the half-open state.
+// This is used to decide whether any backend of a rule can serve the request.
+func (c *CircuitBreakers) available(backend string, cfg *filterapi.CircuitBreaker) bool {
+	if c == nil || cfg == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[backend]
+	if !ok {
+		return true
+	}
+	switch now := c.now(); b.state {
+	case circuitOpen:
+		return !now.Before(b.openUntil)
+	case circuitHalfOpen:
+		return now.Sub(b.probeStartedAt) >= cfg.Cooldown
+	default:
+		return true
+	}
+}
+
+// anyBackendAvailable returns true if the circuit breaker of any backend of the given rule lets requests through.
+func anyBackendAvailable(c *CircuitBreakers, rule *filterapi.RouteRule) bool {
+	if len(rule.Backends) == 0 {
+		return true
+	}
+	for i := range rule.Backends {
+		if b := &rule.Backends[i]; c.available(b.Name, b.CircuitBreaker) {
+			return true
+		}
+	}
+	return false
+}
+
+// allow returns true if the request may be sent to the backend. In the half-open state, this lets through
+// a single probe request. When the result of the probe is never recorded, e.g. the request has been cancelled,
+// another probe is let through after the cooldown.
+func (c *CircuitBreakers) allow(ctx context.Context, backend string, cfg *filterapi.CircuitBreaker) bool {
+	if c == nil || cfg == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[backend]
+	if !ok {
+		return true
+	}
+	now := c.now()
+	switch b.state {
+	case circuitOpen:
+		if now.Before(b.openUntil) {
+			break
+		}
+		c.transition(ctx, backend, b, circuitHalfOpen)
+		b.probeStartedAt = now
+		return true
+	case circuitHalfOpen:
+		if now.Sub(b.probeStartedAt) < cfg.Cooldown {
+			break
+		}
+		b.probeStartedAt = now
+		return true
+	default:
+		return true
+	}
+	if c.metrics != nil {
+		c.metrics.RecordRejected(ctx, backend)
+	}
+	return false
+}
+
+// record records the result of a request sent to the backend with the given response status, where zero means
+// that the request failed without a response, e.g. it was retried by Envoy. retryAfter is the value of the
+// Retry-After response header, which extends the cooldown of the circuit when it is opened by this failure.
+func (c *CircuitBreakers) record(ctx context.Context, backend string, cfg *filterapi.CircuitBreaker, status int, retryAfter string) {
+	if c == nil || cfg == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.breakers[backend]
+	if !ok {
+		b = &circuitBreaker{}
+		c.breakers[backend] = b
+	}
+
+	if status != 0 && !isFailureStatus(status) {
+		b.consecutiveFailures = 0
+		if b.state != circuitClosed {
+			c.transition(ctx, backend, b, circuitClosed)
+		}
+		return
+	}
+
+	b.consecutiveFailures++
+	b.lastFailureStatus = status
+	if b.state == circuitHalfOpen || (b.state == circuitClosed && b.consecutiveFailures >= cfg.ConsecutiveFailures) {
+		now := c.now()
+		b.openUntil = now.Add(cfg.Cooldown)
+		if d := parseRetryAfter(retryAfter, now); now.Add(d).After(b.openUntil) {
+			b.openUntil = now.Add(d)
+		}
+		c.transition(ctx, backend, b, circuitOpen)
+	}
+}
+
+// transition changes the state of the circuit breaker and records it in the metrics. This must be called with c.mu held.
+func (c *CircuitBreakers) transition(ctx context.Context, backend string, b *circuitBreaker, to circuitState) {
+	b.state = to
+	if c.metrics != nil {
+		c.metrics.RecordTransition(ctx, backend, to.String(), int64(to))
+	}
+}
+
+// parseRetryAfter parses the value of the Retry-After header, which is either the number of seconds
+// or an HTTP date. This returns zero if the value is empty or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// circuitBreakerStatus is the JSON representation of the state of a circuit breaker served by [CircuitBreakers.ServeHTTP].
+type circuitBreakerStatus struct {
+	Backend             string     `json:"backend"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastFailureStatus   int        `json:"lastFailureStatus,omitempty"`
+	OpenUntil           *time.Time `json:"openUntil,omitempty"`
+}
+
+// ServeHTTP implements [http.Handler] to serve the state of the circuit breakers as JSON for the admin endpoint.
+func (c *CircuitBreakers) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	c.mu.Lock()
+	statuses := make([]circuitBreakerStatus, 0, len(c.breakers))
+	for name, b := range c.breakers {
+		s := circuitBreakerStatus{
+			Backend:             name,
+			State:               b.state.String(),
+			ConsecutiveFailures: b.consecutiveFailures,
+			LastFailureStatus:   b.lastFailureStatus,
+		}
+		if b.state == circuitOpen {
+			openUntil := b.openUntil
+			s.OpenUntil = &openUntil
+		}
+		statuses = append(statuses, s)
+	}
+	c.mu.Unlock()
+	slices.SortFunc(statuses, func(a, b circuitBreakerStatus) int { return strings.Compare(a.Backend, b.Backend) })
+
+	w.Header().Set("content-type", "application/json")
+	_ = json.NewEncoder(w).Encode(statuses)
+}
@@ -0,0 +1,253 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+)
+
+func TestCircuitBreakers(t *testing.T) {
+	cfg := &filterapi.CircuitBreaker{ConsecutiveFailures: 2, Cooldown: 10 * time.Second}
+	now := time.Unix(1000, 0)
+	cb := NewCircuitBreakers(nil)
+	cb.now = func() time.Time { return now }
+	const backend = "backend.ns"
+
+	t.Run("not configured", func(t *testing.T) {
+		var nilCB *CircuitBreakers
+		require.True(t, nilCB.allow(t.Context(), backend, cfg))
+		require.True(t, cb.allow(t.Context(), backend, nil))
+		cb.record(t.Context(), backend, nil, 500, "")
+		require.Empty(t, cb.breakers)
+	})
+
+	t.Run("open after consecutive failures", func(t *testing.T) {
+		cb.record(t.Context(), backend, cfg, 500, "")
+		cb.record(t.Context(), backend, cfg, 200, "")
+		cb.record(t.Context(), backend, cfg, 429, "")
+		require.True(t, cb.allow(t.Context(), backend, cfg))
+		require.Equal(t, circuitClosed, cb.breakers[backend].state)
+		cb.record(t.Context(), backend, cfg, 0, "")
+		require.Equal(t, circuitOpen, cb.breakers[backend].state)
+		require.False(t, cb.allow(t.Context(), backend, cfg))
+		require.False(t, cb.available(backend, cfg))
+	})
+
+	t.Run("half-open probe", func(t *testing.T) {
+		now = now.Add(10 * time.Second)
+		require.True(t, cb.available(backend, cfg))
+		require.True(t, cb.allow(t.Context(), backend, cfg))
+		require.Equal(t, circuitHalfOpen, cb.breakers[backend].state)
+		// Only a single probe is let through.
+		require.False(t, cb.allow(t.Context(), backend, cfg))
+		require.False(t, cb.available(backend, cfg))
+
+		// The probe fails, so the circuit is opened again.
+		cb.record(t.Context(), backend, cfg, 529, "")
+		require.Equal(t, circuitOpen, cb.breakers[backend].state)
+		require.Equal(t, now.Add(10*time.Second), cb.breakers[backend].openUntil)
+	})
+
+	t.Run("lost probe", func(t *testing.T) {
+		now = now.Add(10 * time.Second)
+		require.True(t, cb.allow(t.Context(), backend, cfg))
+		require.False(t, cb.allow(t.Context(), backend, cfg))
+		now = now.Add(10 * time.Second)
+		require.True(t, cb.allow(t.Context(), backend, cfg))
+	})
+
+	t.Run("closed after successful probe", func(t *testing.T) {
+		cb.record(t.Context(), backend, cfg, 200, "")
+		require.Equal(t, circuitClosed, cb.breakers[backend].state)
+		require.Zero(t, cb.breakers[backend].consecutiveFailures)
+		require.True(t, cb.allow(t.Context(), backend, cfg))
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		cb.record(t.Context(), backend, cfg, 429, "")
+		cb.record(t.Context(), backend, cfg, 429, "120")
+		require.Equal(t, now.Add(2*time.Minute), cb.breakers[backend].openUntil)
+	})
+
+	t.Run("admin endpoint", func(t *testing.T) {
+		cb.record(t.Context(), "another.ns", cfg, 200, "")
+		rec := httptest.NewRecorder()
+		cb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/circuit_breakers", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "application/json", rec.Header().Get("content-type"))
+		var statuses []circuitBreakerStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &statuses))
+		require.Len(t, statuses, 2)
+		require.Equal(t, "another.ns", statuses[0].Backend)
+		require.Equal(t, "closed", statuses[0].State)
+		require.Nil(t, statuses[0].OpenUntil)
+		require.Equal(t, backend, statuses[1].Backend)
+		require.Equal(t, "open", statuses[1].State)
+		require.Equal(t, 2, statuses[1].ConsecutiveFailures)
+		require.Equal(t, 429, statuses[1].LastFailureStatus)
+		require.True(t, now.Add(2*time.Minute).Equal(*statuses[1].OpenUntil))
+	})
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Zero(t, parseRetryAfter("", now))
+	require.Zero(t, parseRetryAfter("invalid", now))
+	require.Zero(t, parseRetryAfter("-1", now))
+	require.Equal(t, 30*time.Second, parseRetryAfter(" 30 ", now))
+	require.Equal(t, time.Minute, parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now))
+	require.Zero(t, parseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now))
+}
+
+func TestChatCompletion_circuitBreaker(t *testing.T) {
+	cfg := &filterapi.CircuitBreaker{ConsecutiveFailures: 1, Cooldown: time.Minute}
+	cb := NewCircuitBreakers(nil)
+	rule := &filterapi.RouteRule{Name: "some-route", Backends: []filterapi.Backend{
+		{Name: "a.ns", CircuitBreaker: cfg}, {Name: "b.ns", CircuitBreaker: cfg},
+	}}
+	headers := map[string]string{":path": "/foo"}
+	rp := &chatCompletionProcessorRouterFilter{
+		config: &processorConfig{
+			router: mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+			rules:  map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule},
+		},
+		requestHeaders:  headers,
+		logger:          slog.Default(),
+		circuitBreakers: cb,
+	}
+	_, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: bodyFromModel(t, "some-model", false)})
+	require.NoError(t, err)
+
+	newAttempt := func(backend string) *chatCompletionProcessorUpstreamFilter {
+		c := &chatCompletionProcessorUpstreamFilter{
+			config:          rp.config,
+			requestHeaders:  map[string]string{},
+			logger:          slog.Default(),
+			metrics:         &mockChatCompletionMetrics{},
+			circuitBreakers: cb,
+		}
+		require.NoError(t, c.SetBackend(t.Context(), &filterapi.Backend{
+			Name: backend, Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI}, CircuitBreaker: cfg,
+		}, nil, rp))
+		return c
+	}
+
+	// The first attempt to "a.ns" is retried by Envoy, which means that it failed.
+	newAttempt("a.ns")
+	newAttempt("b.ns")
+	require.Equal(t, circuitOpen, cb.breakers["a.ns"].state)
+
+	// The response of "b.ns" is throttled.
+	_, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "429"}}})
+	require.NoError(t, err)
+	require.Equal(t, circuitOpen, cb.breakers["b.ns"].state)
+
+	t.Run("upstream rejected", func(t *testing.T) {
+		c := newAttempt("a.ns")
+		resp, err := c.ProcessRequestHeaders(t.Context(), nil)
+		require.NoError(t, err)
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_ServiceUnavailable, ir.Status.Code)
+		require.True(t, c.circuitRejected)
+
+		// The response of the rejected attempt is passed through as is.
+		resp, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "503"}}})
+		require.NoError(t, err)
+		require.IsType(t, &extprocv3.ProcessingResponse_ResponseHeaders{}, resp.Response)
+		require.Nil(t, rp.upstreamFilter)
+	})
+
+	t.Run("all backends open", func(t *testing.T) {
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: bodyFromModel(t, "some-model", false)})
+		require.NoError(t, err)
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_ServiceUnavailable, ir.Status.Code)
+		require.Contains(t, string(ir.Body), "backends_unavailable")
+	})
+}
+
+func TestEmbeddings_circuitBreaker(t *testing.T) {
+	cfg := &filterapi.CircuitBreaker{ConsecutiveFailures: 1, Cooldown: time.Minute}
+	cb := NewCircuitBreakers(nil)
+	rule := &filterapi.RouteRule{Name: "some-route", Backends: []filterapi.Backend{
+		{Name: "a.ns", CircuitBreaker: cfg}, {Name: "b.ns", CircuitBreaker: cfg},
+	}}
+	headers := map[string]string{":path": "/v1/embeddings"}
+	rp := &embeddingsProcessorRouterFilter{
+		config: &processorConfig{
+			router: mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+			rules:  map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule},
+		},
+		requestHeaders:  headers,
+		logger:          slog.Default(),
+		circuitBreakers: cb,
+	}
+	_, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: embeddingBodyFromModel(t, "some-model")})
+	require.NoError(t, err)
+
+	newAttempt := func(backend string) *embeddingsProcessorUpstreamFilter {
+		e := &embeddingsProcessorUpstreamFilter{
+			config:          rp.config,
+			requestHeaders:  map[string]string{},
+			logger:          slog.Default(),
+			metrics:         &mockEmbeddingsMetrics{},
+			circuitBreakers: cb,
+		}
+		require.NoError(t, e.SetBackend(t.Context(), &filterapi.Backend{
+			Name: backend, Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI}, CircuitBreaker: cfg,
+		}, nil, rp))
+		return e
+	}
+
+	// The first attempt to "a.ns" is retried by Envoy, which means that it failed.
+	newAttempt("a.ns")
+	newAttempt("b.ns")
+	require.Equal(t, circuitOpen, cb.breakers["a.ns"].state)
+
+	// The response of "b.ns" is throttled.
+	_, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "429"}}})
+	require.NoError(t, err)
+	require.Equal(t, circuitOpen, cb.breakers["b.ns"].state)
+
+	t.Run("upstream rejected", func(t *testing.T) {
+		e := newAttempt("a.ns")
+		resp, err := e.ProcessRequestHeaders(t.Context(), nil)
+		require.NoError(t, err)
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_ServiceUnavailable, ir.Status.Code)
+		require.True(t, e.circuitRejected)
+
+		// The response of the rejected attempt is passed through as is.
+		resp, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "503"}}})
+		require.NoError(t, err)
+		require.IsType(t, &extprocv3.ProcessingResponse_ResponseHeaders{}, resp.Response)
+		require.Nil(t, rp.upstreamFilter)
+	})
+
+	t.Run("all backends open", func(t *testing.T) {
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: embeddingBodyFromModel(t, "some-model")})
+		require.NoError(t, err)
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_ServiceUnavailable, ir.Status.Code)
+		require.Contains(t, string(ir.Body), "backends_unavailable")
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+)
+
+// consumerMetadataKey is the key of the dynamic metadata of the consumer authenticated with the consumer key.
+const consumerMetadataKey = "consumer"
+
+// authenticateConsumer validates the consumer key in the "authorization" header of the request against the keys of
+// the config, and returns the consumer the key is issued to. The immediate response is returned instead if the key
+// is missing, unknown or expired, or does not allow the given rule or model.
+func authenticateConsumer(config *processorConfig, headers map[string]string, routeName filterapi.RouteRuleName, model string, now time.Time) (*filterapi.ConsumerKey, *extprocv3.ProcessingResponse) {
+	token, ok := strings.CutPrefix(headers["authorization"], "Bearer ")
+	token = strings.TrimSpace(token)
+	if !ok || token == "" {
+		return nil, invalidConsumerKeyResponse("You didn't provide an API key. You need to provide your API key in an Authorization header using Bearer auth.")
+	}
+	sum := sha256.Sum256([]byte(token))
+	key, ok := config.consumerKeys[hex.EncodeToString(sum[:])]
+	if !ok {
+		return nil, invalidConsumerKeyResponse("Incorrect API key provided.")
+	}
+	if !key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt) {
+		return nil, invalidConsumerKeyResponse("The API key provided has expired.")
+	}
+	if resp := authorizeConsumer(key, routeName, model); resp != nil {
+		return nil, resp
+	}
+	return key, nil
+}
+
+// authorizeConsumer returns the immediate response if the consumer key does not allow the given rule or model.
+func authorizeConsumer(key *filterapi.ConsumerKey, routeName filterapi.RouteRuleName, model string) *extprocv3.ProcessingResponse {
+	if len(key.Models) > 0 && !slices.Contains(key.Models, model) {
+		return openAIErrorResponse(typev3.StatusCode_Forbidden, "invalid_request_error", "model_not_allowed", "model",
+			fmt.Sprintf("The API key provided is not allowed to use the model %s.", model))
+	}
+	if !slices.Contains(key.RouteRules, routeName) {
+		return openAIErrorResponse(typev3.StatusCode_Forbidden, "invalid_request_error", "permission_denied", "",
+			"The API key provided is not allowed to use this route.")
+	}
+	return nil
+}
+
+// invalidConsumerKeyResponse returns the OpenAI-compatible 401 response with the given message.
+func invalidConsumerKeyResponse(message string) *extprocv3.ProcessingResponse {
+	return openAIErrorResponse(typev3.StatusCode_Unauthorized, "invalid_request_error", "invalid_api_key", "", message)
+}
+
+// consumerHeaderMutation returns the header mutations setting the consumer header to the given consumer.
+// The "authorization" header carrying the consumer key is removed so that it never reaches the backends.
+func consumerHeaderMutation(config *processorConfig, consumer string) (set []*corev3.HeaderValueOption, remove []string) {
+	if config.consumerHeaderKey != "" {
+		set = append(set, &corev3.HeaderValueOption{
+			Header: &corev3.HeaderValue{Key: config.consumerHeaderKey, RawValue: []byte(consumer)},
+		})
+	}
+	return set, []string{"authorization"}
+}
+
+// consumerDynamicMetadata returns the dynamic metadata of the given consumer, or nil if the consumer is empty.
+func consumerDynamicMetadata(consumer string) map[string]*structpb.Value {
+	if consumer == "" {
+		return nil
+	}
+	return map[string]*structpb.Value{consumerMetadataKey: structpb.NewStringValue(consumer)}
+}
@@ -0,0 +1,129 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"testing"
+	"time"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
+)
+
+// newConsumerKeyConfig returns the config with the consumer keys of alice restricted to gpt-4o, and of bob expired.
+func newConsumerKeyConfig() *processorConfig {
+	hash := hashConsumerKeyForTest
+	return &processorConfig{
+		consumerHeaderKey: "x-ai-eg-consumer",
+		consumerKeys: map[string]*filterapi.ConsumerKey{
+			hash("sk-alice"): {
+				Consumer: "alice", Models: []string{"gpt-4o"}, RouteRules: []filterapi.RouteRuleName{"some-route"},
+				ExpiresAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			hash("sk-bob"): {
+				Consumer: "bob", RouteRules: []filterapi.RouteRuleName{"some-route"},
+				ExpiresAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+}
+
+// hashConsumerKeyForTest returns the hash of the consumer key as written in the filter config by the controller.
+func hashConsumerKeyForTest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func Test_authenticateConsumer(t *testing.T) {
+	config := newConsumerKeyConfig()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name          string
+		authorization string
+		route         filterapi.RouteRuleName
+		model         string
+		expConsumer   string
+		expStatus     typev3.StatusCode
+		expCode       string
+	}{
+		{name: "valid", authorization: "Bearer sk-alice", route: "some-route", model: "gpt-4o", expConsumer: "alice"},
+		{name: "missing", route: "some-route", model: "gpt-4o", expStatus: typev3.StatusCode_Unauthorized, expCode: "invalid_api_key"},
+		{name: "not bearer", authorization: "Basic sk-alice", route: "some-route", model: "gpt-4o", expStatus: typev3.StatusCode_Unauthorized, expCode: "invalid_api_key"},
+		{name: "unknown", authorization: "Bearer sk-carol", route: "some-route", model: "gpt-4o", expStatus: typev3.StatusCode_Unauthorized, expCode: "invalid_api_key"},
+		{name: "expired", authorization: "Bearer sk-bob", route: "some-route", model: "gpt-4o", expStatus: typev3.StatusCode_Unauthorized, expCode: "invalid_api_key"},
+		{name: "model not allowed", authorization: "Bearer sk-alice", route: "some-route", model: "o3", expStatus: typev3.StatusCode_Forbidden, expCode: "model_not_allowed"},
+		{name: "route not allowed", authorization: "Bearer sk-alice", route: "other-route", model: "gpt-4o", expStatus: typev3.StatusCode_Forbidden, expCode: "permission_denied"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			key, resp := authenticateConsumer(config, map[string]string{"authorization": tc.authorization}, tc.route, tc.model, now)
+			if tc.expConsumer != "" {
+				require.Nil(t, resp)
+				require.Equal(t, tc.expConsumer, key.Consumer)
+				return
+			}
+			require.Nil(t, key)
+			require.Equal(t, tc.expStatus, resp.GetImmediateResponse().GetStatus().GetCode())
+			require.Contains(t, string(resp.GetImmediateResponse().GetBody()), `"code":"`+tc.expCode+`"`)
+		})
+	}
+}
+
+func TestChatCompletion_consumerKey(t *testing.T) {
+	config := newConsumerKeyConfig()
+	config.modelNameHeaderKey = "x-model-name"
+	config.selectedRouteHeaderKey = "x-route"
+	config.metadataNamespace = "ns"
+	config.rules = map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": {Name: "some-route", RequireConsumerKey: true}}
+
+	t.Run("rejected", func(t *testing.T) {
+		headers := map[string]string{":path": "/v1/chat/completions", "authorization": "Bearer sk-bob"}
+		config.router = mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		rp := &chatCompletionProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default()}
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(`{"model":"gpt-4o","messages":[]}`)})
+		require.NoError(t, err)
+		require.Equal(t, typev3.StatusCode_Unauthorized, resp.GetImmediateResponse().GetStatus().GetCode())
+	})
+
+	t.Run("authenticated", func(t *testing.T) {
+		headers := map[string]string{":path": "/v1/chat/completions", "authorization": "Bearer sk-alice", "x-ai-eg-consumer": "spoofed"}
+		config.router = mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		rp := &chatCompletionProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default()}
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(`{"model":"gpt-4o","messages":[]}`)})
+		require.NoError(t, err)
+		// The consumer header sent by the client is overwritten with the authenticated consumer.
+		require.Equal(t, "alice", headers["x-ai-eg-consumer"])
+		mutation := resp.GetRequestBody().GetResponse().GetHeaderMutation()
+		require.Contains(t, mutation.GetRemoveHeaders(), "authorization")
+		var consumerHeader string
+		for _, h := range mutation.GetSetHeaders() {
+			if h.Header.Key == "x-ai-eg-consumer" {
+				consumerHeader = string(h.Header.RawValue)
+			}
+		}
+		require.Equal(t, "alice", consumerHeader)
+		require.Equal(t, "alice", resp.DynamicMetadata.Fields["ns"].GetStructValue().Fields["consumer"].GetStringValue())
+
+		uf := &chatCompletionProcessorUpstreamFilter{
+			config:         config,
+			requestHeaders: map[string]string{":path": "/v1/chat/completions"},
+			logger:         slog.Default(),
+			metrics:        &mockChatCompletionMetrics{},
+		}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, rp))
+		require.Equal(t, "alice", uf.consumer)
+		require.Equal(t, []attribute.KeyValue{metrics.ConsumerAttribute("alice")}, uf.metricAttrs)
+	})
+}
@@ -0,0 +1,161 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/filterapi/x"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+)
+
+const (
+	// tokensPerMessage is the number of tokens every message is wrapped with in the prompt.
+	// See https://github.com/openai/openai-cookbook/blob/main/examples/How_to_count_tokens_with_tiktoken.ipynb
+	tokensPerMessage = 3
+	// tokensReplyPriming is the number of tokens every reply is primed with.
+	tokensReplyPriming = 3
+	// tokensPerLowDetailImage is the number of tokens a low detail image costs regardless of its size.
+	tokensPerLowDetailImage = 85
+	// tokensPerImage is a rough upper bound of the tokens of an image with a detail other than "low".
+	tokensPerImage = 765
+)
+
+// estimatePromptTokens returns an estimate of the number of prompt tokens of the given chat completion request
+// with the given tokenizer of the model.
+//
+// The text of the messages is counted by the tokenizer, while the images and the formatting of the messages are
+// rough upper bounds, so that requests close to the limit are rather rejected by the gateway than by the provider
+// after a wasted round trip.
+func estimatePromptTokens(body *openai.ChatCompletionRequest, tok x.Tokenizer) int {
+	tokens := tokensReplyPriming
+	for i := range body.Messages {
+		tokens += tokensPerMessage
+		switch msg := body.Messages[i].Value.(type) {
+		case openai.ChatCompletionUserMessageParam:
+			tokens += tok.CountTokens(msg.Name)
+			switch content := msg.Content.Value.(type) {
+			case string:
+				tokens += tok.CountTokens(content)
+			case []openai.ChatCompletionContentPartUserUnionParam:
+				for j := range content {
+					part := &content[j]
+					switch {
+					case part.TextContent != nil:
+						tokens += tok.CountTokens(part.TextContent.Text)
+					case part.ImageContent != nil:
+						if part.ImageContent.ImageURL.Detail == openai.ChatCompletionContentPartImageImageURLDetailLow {
+							tokens += tokensPerLowDetailImage
+						} else {
+							tokens += tokensPerImage
+						}
+					}
+				}
+			}
+		case openai.ChatCompletionSystemMessageParam:
+			tokens += tok.CountTokens(msg.Name) + estimateStringOrArrayTokens(msg.Content, tok)
+		case openai.ChatCompletionDeveloperMessageParam:
+			tokens += tok.CountTokens(msg.Name) + estimateStringOrArrayTokens(msg.Content, tok)
+		case openai.ChatCompletionToolMessageParam:
+			tokens += estimateStringOrArrayTokens(msg.Content, tok)
+		case openai.ChatCompletionAssistantMessageParam:
+			tokens += tok.CountTokens(msg.Name) + tok.CountTokens(msg.Refusal)
+			switch content := msg.Content.Value.(type) {
+			case string:
+				tokens += tok.CountTokens(content)
+			case openai.ChatCompletionAssistantMessageParamContent:
+				if content.Text != nil {
+					tokens += tok.CountTokens(*content.Text)
+				}
+			}
+			for j := range msg.ToolCalls {
+				tokens += tok.CountTokens(msg.ToolCalls[j].Function.Name) + tok.CountTokens(msg.ToolCalls[j].Function.Arguments)
+			}
+		}
+	}
+	if len(body.Tools) > 0 {
+		// Tool definitions are rendered into the prompt by the provider. Their JSON representation is
+		// a good enough approximation of what ends up in the prompt.
+		if raw, err := json.Marshal(body.Tools); err == nil {
+			tokens += tok.CountTokens(string(raw))
+		}
+	}
+	return tokens
+}
+
+// estimateStringOrArrayTokens returns the number of tokens of the text content of the given [openai.StringOrArray].
+func estimateStringOrArrayTokens(s openai.StringOrArray, tok x.Tokenizer) (tokens int) {
+	switch content := s.Value.(type) {
+	case string:
+		tokens = tok.CountTokens(content)
+	case []string:
+		for _, c := range content {
+			tokens += tok.CountTokens(c)
+		}
+	case []openai.ChatCompletionContentPartTextParam:
+		for i := range content {
+			tokens += tok.CountTokens(content[i].Text)
+		}
+	}
+	return
+}
+
+// tokenLimits is the effective token limits of a route rule, i.e. the smallest limits among its backends.
+// Zero means that none of the backends declares the limit.
+type tokenLimits struct {
+	contextWindow, maxOutputTokens int
+}
+
+// ruleTokenLimits returns the effective token limits of the given rule. The smallest limits are used since
+// the backend is chosen by Envoy only after the route rule has been selected, so the request must fit into all of them.
+func ruleTokenLimits(rule *filterapi.RouteRule) (limits tokenLimits) {
+	for i := range rule.Backends {
+		b := &rule.Backends[i]
+		if b.ContextWindow > 0 && (limits.contextWindow == 0 || b.ContextWindow < limits.contextWindow) {
+			limits.contextWindow = b.ContextWindow
+		}
+		if b.MaxOutputTokens > 0 && (limits.maxOutputTokens == 0 || b.MaxOutputTokens < limits.maxOutputTokens) {
+			limits.maxOutputTokens = b.MaxOutputTokens
+		}
+	}
+	return
+}
+
+// fits reports whether a request with the given prompt tokens and requested output tokens fits into the limits.
+func (l tokenLimits) fits(promptTokens, maxTokens int) bool {
+	if l.maxOutputTokens > 0 && maxTokens > l.maxOutputTokens {
+		return false
+	}
+	return l.contextWindow == 0 || promptTokens+maxTokens <= l.contextWindow
+}
+
+// contextLengthExceededResponse returns the immediate response sent to the client when the request
+// cannot fit into the given limits of the last candidate route rule. maxTokensParam is the name of the
+// request field that maxTokens is taken from.
+func contextLengthExceededResponse(limits tokenLimits, promptTokens, maxTokens int, maxTokensParam string) *extprocv3.ProcessingResponse {
+	var msg, param string
+	switch {
+	case limits.maxOutputTokens > 0 && maxTokens > limits.maxOutputTokens:
+		param = maxTokensParam
+		msg = fmt.Sprintf("%s is too large: %d. This model supports at most %d completion tokens, whereas you provided %d.",
+			maxTokensParam, maxTokens, limits.maxOutputTokens, maxTokens)
+	case maxTokens > 0:
+		param = "messages"
+		msg = fmt.Sprintf("This model's maximum context length is %d tokens. However, you requested an estimated %d tokens "+
+			"(%d in the messages, %d in the completion). Please reduce the length of the messages or completion.",
+			limits.contextWindow, promptTokens+maxTokens, promptTokens, maxTokens)
+	default:
+		param = "messages"
+		msg = fmt.Sprintf("This model's maximum context length is %d tokens. However, your messages resulted in an estimated %d tokens. "+
+			"Please reduce the length of the messages.", limits.contextWindow, promptTokens)
+	}
+	return openAIErrorResponse(typev3.StatusCode_BadRequest, "invalid_request_error", "context_length_exceeded", param, msg)
+}
@@ -0,0 +1,123 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"encoding/json"
+	"testing"
+
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/tokenizer"
+)
+
+func Test_estimatePromptTokens(t *testing.T) {
+	var body openai.ChatCompletionRequest
+	err := json.Unmarshal([]byte(`{
+  "model": "some-model",
+  "messages": [
+    {"role": "system", "content": "abcdabcd"},
+    {"role": "user", "content": [
+      {"type": "text", "text": "abcd"},
+      {"type": "image_url", "image_url": {"url": "https://example.com/a.png", "detail": "low"}},
+      {"type": "image_url", "image_url": {"url": "https://example.com/b.png"}}
+    ]},
+    {"role": "assistant", "content": "abcd", "tool_calls": [
+      {"id": "call_1", "type": "function", "function": {"name": "abcd", "arguments": "{}"}}
+    ]},
+    {"role": "tool", "tool_call_id": "call_1", "content": "abcdabcdabcd"}
+  ]
+}`), &body)
+	require.NoError(t, err)
+	exp := tokensReplyPriming + 4*tokensPerMessage +
+		2 + // system.
+		1 + tokensPerLowDetailImage + tokensPerImage + // user.
+		1 + 1 + 1 + // assistant.
+		3 // tool.
+	require.Equal(t, exp, estimatePromptTokens(&body, tokenizer.Default))
+}
+
+func Test_ruleTokenLimits(t *testing.T) {
+	require.Equal(t, tokenLimits{}, ruleTokenLimits(&filterapi.RouteRule{Backends: []filterapi.Backend{{Name: "a"}}}))
+	require.Equal(t, tokenLimits{contextWindow: 8000, maxOutputTokens: 1000}, ruleTokenLimits(&filterapi.RouteRule{
+		Backends: []filterapi.Backend{
+			{Name: "a", ContextWindow: 128000, MaxOutputTokens: 1000},
+			{Name: "b", ContextWindow: 8000},
+			{Name: "c"},
+		},
+	}))
+}
+
+func Test_tokenLimits_fits(t *testing.T) {
+	l := tokenLimits{contextWindow: 100, maxOutputTokens: 10}
+	require.True(t, l.fits(90, 10))
+	require.False(t, l.fits(91, 10))
+	require.False(t, l.fits(10, 11))
+	require.True(t, tokenLimits{maxOutputTokens: 10}.fits(100000, 10))
+	require.True(t, tokenLimits{}.fits(100000, 100000))
+}
+
+func Test_contextLengthExceededResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		limits         tokenLimits
+		promptTokens   int
+		maxTokens      int
+		maxTokensParam string
+		expParam       string
+		expMsgContain  string
+	}{
+		{
+			name:           "max tokens",
+			limits:         tokenLimits{contextWindow: 100, maxOutputTokens: 10},
+			promptTokens:   10,
+			maxTokens:      20,
+			maxTokensParam: "max_tokens",
+			expParam:       "max_tokens",
+			expMsgContain:  "max_tokens is too large: 20. This model supports at most 10 completion tokens",
+		},
+		{
+			name:           "max completion tokens",
+			limits:         tokenLimits{contextWindow: 100, maxOutputTokens: 10},
+			promptTokens:   10,
+			maxTokens:      20,
+			maxTokensParam: "max_completion_tokens",
+			expParam:       "max_completion_tokens",
+			expMsgContain:  "max_completion_tokens is too large: 20. This model supports at most 10 completion tokens",
+		},
+		{
+			name:          "prompt and completion",
+			limits:        tokenLimits{contextWindow: 100},
+			promptTokens:  95,
+			maxTokens:     10,
+			expParam:      "messages",
+			expMsgContain: "requested an estimated 105 tokens (95 in the messages, 10 in the completion)",
+		},
+		{
+			name:          "prompt only",
+			limits:        tokenLimits{contextWindow: 100},
+			promptTokens:  120,
+			expParam:      "messages",
+			expMsgContain: "your messages resulted in an estimated 120 tokens",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := contextLengthExceededResponse(tc.limits, tc.promptTokens, tc.maxTokens, tc.maxTokensParam)
+			ir := resp.GetImmediateResponse()
+			require.NotNil(t, ir)
+			require.Equal(t, typev3.StatusCode_BadRequest, ir.GetStatus().GetCode())
+			var openAIErr openai.Error
+			require.NoError(t, json.Unmarshal(ir.Body, &openAIErr))
+			require.Equal(t, "invalid_request_error", openAIErr.Error.Type)
+			require.Equal(t, "context_length_exceeded", *openAIErr.Error.Code)
+			require.Equal(t, tc.expParam, *openAIErr.Error.Param)
+			require.Contains(t, openAIErr.Error.Message, tc.expMsgContain)
+		})
+	}
+}
@@ -0,0 +1,398 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/tidwall/sjson"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
+)
+
+const (
+	// embeddingsBatchHeader is the request header set on the batches of a split embeddings request to the signature
+	// of the batch, so that the batches are neither split again nor served from the input cache.
+	// See [isEmbeddingsBatch].
+	embeddingsBatchHeader = "x-ai-eg-embeddings-batch"
+	// maxEmbeddingsBatchResponseBodySize is the maximum size of the response body of a batch.
+	maxEmbeddingsBatchResponseBodySize = 50 << 20
+)
+
+var (
+	// embeddingsBatchHTTPClient is the HTTP client used to send the batches of the split embeddings requests.
+	embeddingsBatchHTTPClient = &http.Client{}
+	// embeddingsBatchKey is the key of the signatures of the batches, which is generated per process.
+	embeddingsBatchKey = func() []byte {
+		key := make([]byte, 32)
+		_, _ = rand.Read(key)
+		return key
+	}()
+)
+
+// embeddingsBatchSignature returns the value of [embeddingsBatchHeader] of the batch with the given body, which is
+// the HMAC of the body with [embeddingsBatchKey].
+func embeddingsBatchSignature(body []byte) string {
+	mac := hmac.New(sha256.New, embeddingsBatchKey)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isEmbeddingsBatch returns true if the request is a batch sent by this external processor, i.e. it carries
+// [embeddingsBatchHeader] with the valid signature of the body. The header set by the clients is ignored, so that
+// they cannot bypass the splitting and the input cache.
+//
+// A batch routed to another replica of the external processor is processed as a regular request. That is harmless
+// since a batch never has more inputs than the limit, but its inputs are looked up in the input cache again.
+func isEmbeddingsBatch(headers map[string]string, body []byte) bool {
+	v, ok := headers[embeddingsBatchHeader]
+	return ok && hmac.Equal([]byte(v), []byte(embeddingsBatchSignature(body)))
+}
+
+// embeddingsResponse is the subset of [openai.EmbeddingResponse] whose embeddings are kept as they are,
+// so that both the float and the base64 encoding formats are merged and cached without decoding.
+type embeddingsResponse struct {
+	Object string                   `json:"object"`
+	Data   []embeddingsResponseData `json:"data"`
+	Model  string                   `json:"model"`
+	Usage  openai.EmbeddingUsage    `json:"usage"`
+}
+
+type embeddingsResponseData struct {
+	Object    string          `json:"object"`
+	Embedding json.RawMessage `json:"embedding"`
+	Index     int             `json:"index"`
+}
+
+// embeddingsInputState is the state of the inputs of an embeddings request of a rule with [filterapi.Embeddings].
+type embeddingsInputState struct {
+	// embeddings is the embeddings of the inputs of the original request, which are nil for the inputs sent to the backend
+	// until the response is received.
+	embeddings []json.RawMessage
+	// sent is the indexes of the original inputs sent to the backend, in the order of the request.
+	sent []int
+	// cacheKeys is the keys of the original inputs in the input cache, or nil if the cache is disabled.
+	cacheKeys []string
+	cacheTTL  time.Duration
+}
+
+// embeddingsInputs returns the inputs of the request, or false if they are not texts.
+func embeddingsInputs(body *openai.EmbeddingRequest) ([]string, bool) {
+	switch input := body.Input.Value.(type) {
+	case string:
+		return []string{input}, true
+	case []string:
+		return input, true
+	}
+	return nil, false
+}
+
+// embeddingCacheKey returns the key of the input in the input cache, which is the hash of the route rule, the
+// consumer authenticated with the consumer key if any, and the parameters of the request affecting the embedding
+// together with the input.
+func embeddingCacheKey(routeName filterapi.RouteRuleName, authenticatedConsumer string, body *openai.EmbeddingRequest, input string) string {
+	format, dimensions := "float", ""
+	if body.EncodingFormat != nil {
+		format = *body.EncodingFormat
+	}
+	if body.Dimensions != nil {
+		dimensions = strconv.Itoa(*body.Dimensions)
+	}
+	h := sha256.New()
+	for _, s := range []string{"embedding", string(routeName), authenticatedConsumer, body.Model, format, dimensions, input} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// processEmbeddingsInputs serves the inputs of the request from the input cache, and splits the rest into batches
+// as configured in the rule. This returns the immediate response if the request is served without sending it to the
+// backend. Otherwise, this returns the request body with only the inputs to send if it differs from the original one.
+func (e *embeddingsProcessorRouterFilter) processEmbeddingsInputs(ctx context.Context, rule *filterapi.RouteRule,
+	raw []byte, body *openai.EmbeddingRequest,
+) (resp *extprocv3.ProcessingResponse, newRaw []byte, err error) {
+	cfg := rule.Embeddings
+	inputs, ok := embeddingsInputs(body)
+	if !ok || len(inputs) == 0 || e.embeddingsBatch {
+		return nil, nil, nil
+	}
+	state := &embeddingsInputState{embeddings: make([]json.RawMessage, len(inputs))}
+	if cfg.InputCacheTTL > 0 && e.responseCache != nil {
+		state.cacheKeys, state.cacheTTL = make([]string, len(inputs)), cfg.InputCacheTTL
+	}
+	for i, input := range inputs {
+		if state.cacheKeys == nil {
+			state.sent = append(state.sent, i)
+			continue
+		}
+		state.cacheKeys[i] = embeddingCacheKey(rule.Name, e.consumer, body, input)
+		result := metrics.ResponseCacheMiss
+		if cr := e.responseCache.get(state.cacheKeys[i], 0); cr != nil {
+			state.embeddings[i], result = cr.body, metrics.ResponseCacheHit
+		} else {
+			state.sent = append(state.sent, i)
+		}
+		if e.responseCache.metrics != nil {
+			e.responseCache.metrics.RecordLookup(ctx, string(rule.Name), result)
+		}
+	}
+	if len(state.sent) == 0 {
+		return embeddingsToImmediateResponse(state.embeddings, body.Model, openai.EmbeddingUsage{}, "hit"), nil, nil
+	}
+
+	sentInputs := make([]string, len(state.sent))
+	for i, idx := range state.sent {
+		sentInputs[i] = inputs[idx]
+	}
+	if cfg.MaxInputsPerRequest > 0 && len(sentInputs) > cfg.MaxInputsPerRequest {
+		res, errResp, err := e.sendEmbeddingsBatches(ctx, cfg, raw, sentInputs)
+		if err != nil || errResp != nil {
+			return errResp, nil, err
+		}
+		state.setSentEmbeddings(ctx, e.responseCache, res.Data)
+		return embeddingsToImmediateResponse(state.embeddings, res.Model, res.Usage, ""), nil, nil
+	}
+
+	e.embeddingsInputs = state
+	if len(sentInputs) == len(inputs) {
+		return nil, nil, nil
+	}
+	if newRaw, err = sjson.SetBytes(raw, "input", sentInputs); err != nil {
+		return nil, nil, fmt.Errorf("failed to set inputs: %w", err)
+	}
+	body.Input.Value = sentInputs
+	return nil, newRaw, nil
+}
+
+// setSentEmbeddings sets the embeddings of the inputs sent to the backend, and stores them in the input cache.
+func (s *embeddingsInputState) setSentEmbeddings(ctx context.Context, rc *ResponseCache, data []embeddingsResponseData) {
+	for _, d := range data {
+		if d.Index < 0 || d.Index >= len(s.sent) {
+			continue
+		}
+		i := s.sent[d.Index]
+		s.embeddings[i] = d.Embedding
+		if s.cacheKeys != nil {
+			rc.put(ctx, s.cacheKeys[i], d.Embedding, false, s.cacheTTL, "", nil)
+		}
+	}
+}
+
+// processEmbeddingsResponse stores the embeddings of the response in the input cache, and adds the cached embeddings
+// to the response body if only some of the inputs were sent to the backend. The response body not mutated by the
+// upstream filter is decoded if it is gzip encoded, and the request fails if it is encoded otherwise since the
+// cached embeddings cannot be added to it.
+func (e *embeddingsProcessorRouterFilter) processEmbeddingsResponse(ctx context.Context, resp *extprocv3.ProcessingResponse, body *extprocv3.HttpBody) error {
+	state := e.embeddingsInputs
+	uf, ok := e.upstreamFilter.(*embeddingsProcessorUpstreamFilter)
+	if !ok || !body.EndOfStream || uf.responseHeaders[":status"] != "200" {
+		return nil
+	}
+	common := resp.GetResponseBody().GetResponse()
+	respBody := common.GetBodyMutation().GetBody()
+	var decoded bool
+	if respBody == nil {
+		switch uf.responseEncoding {
+		case "":
+			respBody = body.Body
+		case "gzip":
+			gr, err := gzip.NewReader(bytes.NewReader(body.Body))
+			if err != nil {
+				return fmt.Errorf("failed to decode gzip: %w", err)
+			}
+			if respBody, err = io.ReadAll(gr); err != nil {
+				return fmt.Errorf("failed to decode gzip: %w", err)
+			}
+			decoded = true
+		default:
+			if len(state.sent) == len(state.embeddings) {
+				return nil
+			}
+			return fmt.Errorf("unsupported content-encoding of embeddings response: %s", uf.responseEncoding)
+		}
+	}
+	var res embeddingsResponse
+	if err := json.Unmarshal(respBody, &res); err != nil {
+		return fmt.Errorf("failed to unmarshal embeddings response: %w", err)
+	}
+	state.setSentEmbeddings(ctx, e.responseCache, res.Data)
+	if len(state.sent) == len(state.embeddings) {
+		return nil
+	}
+	merged, err := mergedEmbeddingsResponseBody(state.embeddings, res.Model, res.Usage)
+	if err != nil {
+		return err
+	}
+	if common.HeaderMutation == nil {
+		common.HeaderMutation = &extprocv3.HeaderMutation{}
+	}
+	setHeader(common.HeaderMutation, "content-length", strconv.Itoa(len(merged)))
+	if decoded {
+		common.HeaderMutation.RemoveHeaders = append(common.HeaderMutation.RemoveHeaders, "content-encoding")
+	}
+	common.BodyMutation = &extprocv3.BodyMutation{Mutation: &extprocv3.BodyMutation_Body{Body: merged}}
+	return nil
+}
+
+// sendEmbeddingsBatches sends the inputs in batches of the configured size concurrently to the batch URL, and returns
+// the merged response. If any of the batches fails, this returns the immediate response with its error instead.
+func (e *embeddingsProcessorRouterFilter) sendEmbeddingsBatches(ctx context.Context, cfg *filterapi.Embeddings, raw []byte,
+	inputs []string,
+) (*embeddingsResponse, *extprocv3.ProcessingResponse, error) {
+	type batchResult struct {
+		res     *embeddingsResponse
+		errResp *extprocv3.ProcessingResponse
+	}
+	if cfg.BatchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.BatchTimeout)
+		defer cancel()
+	}
+	size := cfg.MaxInputsPerRequest
+	results := make([]batchResult, (len(inputs)+size-1)/size)
+	sem := make(chan struct{}, max(cfg.MaxConcurrency, 1))
+	var wg sync.WaitGroup
+	for b := range results {
+		batchBody, err := sjson.SetBytes(raw, "input", inputs[b*size:min((b+1)*size, len(inputs))])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to set inputs of batch: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[b].res, results[b].errResp = e.sendEmbeddingsBatch(ctx, cfg.BatchURL, b, batchBody)
+		}()
+	}
+	wg.Wait()
+
+	merged := &embeddingsResponse{}
+	for b, r := range results {
+		if r.errResp != nil {
+			return nil, r.errResp, nil
+		}
+		if merged.Model == "" {
+			merged.Model = r.res.Model
+		}
+		for _, d := range r.res.Data {
+			d.Index += b * size
+			merged.Data = append(merged.Data, d)
+		}
+		merged.Usage.PromptTokens += r.res.Usage.PromptTokens
+		merged.Usage.TotalTokens += r.res.Usage.TotalTokens
+	}
+	return merged, nil, nil
+}
+
+// sendEmbeddingsBatch sends a batch with the headers of the original request, and returns its response. If the batch
+// fails, this returns the immediate response relaying the error to the client.
+func (e *embeddingsProcessorRouterFilter) sendEmbeddingsBatch(ctx context.Context, url string, index int, body []byte) (*embeddingsResponse, *extprocv3.ProcessingResponse) {
+	batchError := func(err error) *extprocv3.ProcessingResponse {
+		e.logger.Error("failed to send embeddings batch", "batch", index, "error", err)
+		return openAIErrorResponse(typev3.StatusCode_BadGateway, "server_error", "embeddings_batch_failed", "",
+			fmt.Sprintf("Failed to send the batch %d of the embeddings request.", index))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, batchError(err)
+	}
+	for k, v := range e.requestHeaders {
+		if strings.HasPrefix(k, ":") || strings.EqualFold(k, "content-length") || strings.EqualFold(k, "accept-encoding") {
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(embeddingsBatchHeader, embeddingsBatchSignature(body))
+	resp, err := embeddingsBatchHTTPClient.Do(req)
+	if err != nil {
+		return nil, batchError(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxEmbeddingsBatchResponseBodySize))
+	if err != nil {
+		return nil, batchError(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Relay the error of the batch as is, e.g. the authentication failures and the rate limits.
+		headers := &extprocv3.HeaderMutation{}
+		setHeader(headers, "content-type", resp.Header.Get("content-type"))
+		setHeader(headers, "content-length", strconv.Itoa(len(respBody)))
+		return nil, &extprocv3.ProcessingResponse{
+			Response: &extprocv3.ProcessingResponse_ImmediateResponse{
+				ImmediateResponse: &extprocv3.ImmediateResponse{
+					Status:  &typev3.HttpStatus{Code: typev3.StatusCode(resp.StatusCode)}, //nolint:gosec
+					Headers: headers,
+					Body:    respBody,
+				},
+			},
+		}
+	}
+	var res embeddingsResponse
+	if err = json.Unmarshal(respBody, &res); err != nil {
+		return nil, batchError(fmt.Errorf("failed to unmarshal response: %w", err))
+	}
+	return &res, nil
+}
+
+// mergedEmbeddingsResponseBody returns the response body with the embeddings of all the inputs in the original order.
+func mergedEmbeddingsResponseBody(embeddings []json.RawMessage, model string, usage openai.EmbeddingUsage) ([]byte, error) {
+	res := embeddingsResponse{Object: "list", Data: make([]embeddingsResponseData, len(embeddings)), Model: model, Usage: usage}
+	for i, emb := range embeddings {
+		if emb == nil {
+			return nil, fmt.Errorf("missing embedding of input %d", i)
+		}
+		res.Data[i] = embeddingsResponseData{Object: "embedding", Embedding: emb, Index: i}
+	}
+	body, err := json.Marshal(res)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embeddings response: %w", err)
+	}
+	return body, nil
+}
+
+// embeddingsToImmediateResponse returns the immediate response with the embeddings of all the inputs, with the
+// given value of the [responseCacheHeader] if not empty.
+func embeddingsToImmediateResponse(embeddings []json.RawMessage, model string, usage openai.EmbeddingUsage, hit string) *extprocv3.ProcessingResponse {
+	body, err := mergedEmbeddingsResponseBody(embeddings, model, usage)
+	if err != nil {
+		return openAIErrorResponse(typev3.StatusCode_BadGateway, "server_error", "embeddings_batch_failed", "", err.Error())
+	}
+	headers := &extprocv3.HeaderMutation{}
+	setHeader(headers, "content-type", "application/json")
+	setHeader(headers, "content-length", strconv.Itoa(len(body)))
+	if hit != "" {
+		setHeader(headers, responseCacheHeader, hit)
+	}
+	return &extprocv3.ProcessingResponse{
+		Response: &extprocv3.ProcessingResponse_ImmediateResponse{
+			ImmediateResponse: &extprocv3.ImmediateResponse{
+				Status:  &typev3.HttpStatus{Code: typev3.StatusCode_OK},
+				Headers: headers,
+				Body:    body,
+			},
+		},
+	}
+}
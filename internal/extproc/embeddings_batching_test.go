@@ -0,0 +1,258 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+)
+
+func Test_embeddingCacheKey(t *testing.T) {
+	body := &openai.EmbeddingRequest{Model: "m"}
+	base := embeddingCacheKey("route", "", body, "hello")
+	require.Len(t, base, 64)
+	require.Equal(t, base, embeddingCacheKey("route", "", &openai.EmbeddingRequest{Model: "m", EncodingFormat: ptr.To("float")}, "hello"))
+	for _, other := range []string{
+		embeddingCacheKey("other", "", body, "hello"),
+		embeddingCacheKey("route", "", body, "hello!"),
+		embeddingCacheKey("route", "alice", body, "hello"),
+		embeddingCacheKey("route", "", &openai.EmbeddingRequest{Model: "other"}, "hello"),
+		embeddingCacheKey("route", "", &openai.EmbeddingRequest{Model: "m", EncodingFormat: ptr.To("base64")}, "hello"),
+		embeddingCacheKey("route", "", &openai.EmbeddingRequest{Model: "m", Dimensions: ptr.To(256)}, "hello"),
+	} {
+		require.NotEqual(t, base, other)
+	}
+}
+
+// fakeEmbeddingsResponse returns the embeddings response whose embedding of each input is its length.
+func fakeEmbeddingsResponse(inputs []string) string {
+	res := embeddingsResponse{Object: "list", Model: "m", Usage: openai.EmbeddingUsage{PromptTokens: len(inputs), TotalTokens: len(inputs)}}
+	for i, in := range inputs {
+		res.Data = append(res.Data, embeddingsResponseData{Object: "embedding", Embedding: json.RawMessage(fmt.Sprintf("[%d]", len(in))), Index: i})
+	}
+	body, _ := json.Marshal(res)
+	return string(body)
+}
+
+func requireEmbeddingsBody(t *testing.T, body []byte, expEmbeddings []string, expTokens int) {
+	var res embeddingsResponse
+	require.NoError(t, json.Unmarshal(body, &res))
+	require.Len(t, res.Data, len(expEmbeddings))
+	for i, d := range res.Data {
+		require.Equal(t, i, d.Index)
+		require.Equal(t, "embedding", d.Object)
+		require.Equal(t, expEmbeddings[i], string(d.Embedding))
+	}
+	require.Equal(t, openai.EmbeddingUsage{PromptTokens: expTokens, TotalTokens: expTokens}, res.Usage)
+}
+
+func TestEmbeddings_inputCache(t *testing.T) {
+	rule := &filterapi.RouteRule{Name: "some-route", Embeddings: &filterapi.Embeddings{InputCacheTTL: time.Hour}}
+	config := &processorConfig{modelNameHeaderKey: "x-ai-eg-model", rules: map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule}}
+	rc := NewResponseCache(1<<20, nil)
+
+	// send sends the request through the router filter, and the response with the embeddings of the inputs of the
+	// request sent to the backend through the upstream filter, and returns the responses of both. The response is
+	// encoded with the given encoding, and its error is set to respErr.
+	var encoding string
+	var respErr error
+	send := func(t *testing.T, inputs ...string) (reqResp, respResp *extprocv3.ProcessingResponse, sent []string) {
+		headers := map[string]string{":path": "/v1/embeddings"}
+		config.router = mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		rp := &embeddingsProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default(), responseCache: rc}
+		reqBody, err := json.Marshal(map[string]any{"model": "m", "input": inputs})
+		require.NoError(t, err)
+		reqResp, err = rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: reqBody})
+		require.NoError(t, err)
+		if reqResp.GetImmediateResponse() != nil {
+			return
+		}
+		var sentBody openai.EmbeddingRequest
+		require.NoError(t, json.Unmarshal(rp.originalRequestBodyRaw, &sentBody))
+		sent, _ = embeddingsInputs(&sentBody)
+
+		uf := &embeddingsProcessorUpstreamFilter{config: config, requestHeaders: headers, logger: slog.Default(), metrics: &mockEmbeddingsMetrics{}}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, rp))
+		respHeaders := []*corev3.HeaderValue{{Key: ":status", Value: "200"}}
+		respBody := []byte(fakeEmbeddingsResponse(sent))
+		if encoding != "" {
+			respHeaders = append(respHeaders, &corev3.HeaderValue{Key: "content-encoding", Value: encoding})
+		}
+		if encoding == "gzip" {
+			var buf bytes.Buffer
+			gw := gzip.NewWriter(&buf)
+			_, err = gw.Write(respBody)
+			require.NoError(t, err)
+			require.NoError(t, gw.Close())
+			respBody = buf.Bytes()
+		}
+		_, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: respHeaders})
+		require.NoError(t, err)
+		respResp, respErr = rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{Body: respBody, EndOfStream: true})
+		return
+	}
+
+	reqResp, respResp, sent := send(t, "a", "bb")
+	require.NoError(t, respErr)
+	require.Equal(t, []string{"a", "bb"}, sent)
+	require.Nil(t, reqResp.GetRequestBody().GetResponse().GetBodyMutation())
+	require.Nil(t, respResp.GetResponseBody().GetResponse().GetBodyMutation())
+
+	// Only the new input is sent, and the response has the cached embeddings in the original order.
+	reqResp, respResp, sent = send(t, "bb", "ccc", "a")
+	require.NoError(t, respErr)
+	require.Equal(t, []string{"ccc"}, sent)
+	reqMutation := reqResp.GetRequestBody().GetResponse()
+	require.JSONEq(t, `{"model":"m","input":["ccc"]}`, string(reqMutation.GetBodyMutation().GetBody()))
+	require.Equal(t, []string{"accept-encoding"}, reqMutation.GetHeaderMutation().GetRemoveHeaders())
+	requireEmbeddingsBody(t, respResp.GetResponseBody().GetResponse().GetBodyMutation().GetBody(), []string{"[2]", "[3]", "[1]"}, 1)
+
+	// All the inputs are served from the cache.
+	reqResp, _, _ = send(t, "ccc", "a")
+	ir := reqResp.GetImmediateResponse()
+	require.NotNil(t, ir)
+	require.Equal(t, typev3.StatusCode_OK, ir.GetStatus().GetCode())
+	requireEmbeddingsBody(t, ir.GetBody(), []string{"[3]", "[1]"}, 0)
+	require.Contains(t, ir.GetHeaders().GetSetHeaders(), &corev3.HeaderValueOption{
+		Header: &corev3.HeaderValue{Key: responseCacheHeader, RawValue: []byte("hit")},
+	})
+
+	// The gzip encoded response is decoded to add the cached embeddings.
+	encoding = "gzip"
+	_, respResp, sent = send(t, "a", "dddd")
+	require.NoError(t, respErr)
+	require.Equal(t, []string{"dddd"}, sent)
+	respMutation := respResp.GetResponseBody().GetResponse()
+	requireEmbeddingsBody(t, respMutation.GetBodyMutation().GetBody(), []string{"[1]", "[4]"}, 1)
+	require.Contains(t, respMutation.GetHeaderMutation().GetRemoveHeaders(), "content-encoding")
+
+	// The response with an unsupported encoding cannot have the cached embeddings added.
+	encoding = "br"
+	_, _, sent = send(t, "a", "eeeee")
+	require.Equal(t, []string{"eeeee"}, sent)
+	require.ErrorContains(t, respErr, "unsupported content-encoding of embeddings response: br")
+}
+
+func TestEmbeddings_batching(t *testing.T) {
+	var inflight, maxInflight atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for cur := maxInflight.Load(); n > cur && !maxInflight.CompareAndSwap(cur, n); cur = maxInflight.Load() {
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		require.Equal(t, "Bearer token", r.Header.Get("authorization"))
+		require.NotEmpty(t, r.Header.Get(embeddingsBatchHeader))
+		var req openai.EmbeddingRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "m", req.Model)
+		inputs, _ := embeddingsInputs(&req)
+		require.LessOrEqual(t, len(inputs), 2)
+		if inputs[0] == "fail" {
+			w.Header().Set("content-type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":{"message":"slow down"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(fakeEmbeddingsResponse(inputs)))
+	}))
+	defer srv.Close()
+
+	rule := &filterapi.RouteRule{Name: "some-route", Embeddings: &filterapi.Embeddings{
+		MaxInputsPerRequest: 2, BatchURL: srv.URL + "/v1/embeddings", MaxConcurrency: 2, InputCacheTTL: time.Hour,
+	}}
+	config := &processorConfig{modelNameHeaderKey: "x-ai-eg-model", rules: map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule}}
+	rc := NewResponseCache(1<<20, nil)
+	body := func(inputs ...string) []byte {
+		reqBody, err := json.Marshal(map[string]any{"model": "m", "input": inputs})
+		require.NoError(t, err)
+		return reqBody
+	}
+	send := func(t *testing.T, headers map[string]string, inputs ...string) *extprocv3.ProcessingResponse {
+		headers[":path"] = "/v1/embeddings"
+		headers["authorization"] = "Bearer token"
+		config.router = mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		rp := &embeddingsProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default(), responseCache: rc}
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: body(inputs...)})
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("split", func(t *testing.T) {
+		ir := send(t, map[string]string{}, "a", "bb", "ccc", "dddd", "eeeee", "ffffff").GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_OK, ir.GetStatus().GetCode())
+		requireEmbeddingsBody(t, ir.GetBody(), []string{"[1]", "[2]", "[3]", "[4]", "[5]", "[6]"}, 6)
+		require.Equal(t, int32(2), maxInflight.Load())
+
+		// The cached inputs are not sent again.
+		ir = send(t, map[string]string{}, "ffffff", "x", "a", "yy", "zzz").GetImmediateResponse()
+		require.NotNil(t, ir)
+		requireEmbeddingsBody(t, ir.GetBody(), []string{"[6]", "[1]", "[1]", "[2]", "[3]"}, 3)
+	})
+
+	t.Run("not split", func(t *testing.T) {
+		// Within the limit.
+		require.Nil(t, send(t, map[string]string{}, "g", "hh").GetImmediateResponse())
+		// A batch of a split request.
+		resp := send(t, map[string]string{embeddingsBatchHeader: embeddingsBatchSignature(body("i", "jj", "kkk"))}, "i", "jj", "kkk")
+		require.Nil(t, resp.GetImmediateResponse())
+		// The header never reaches the backend.
+		require.Contains(t, resp.GetRequestBody().GetResponse().GetHeaderMutation().GetRemoveHeaders(), embeddingsBatchHeader)
+	})
+
+	t.Run("forged batch header", func(t *testing.T) {
+		ir := send(t, map[string]string{embeddingsBatchHeader: "0"}, "i", "jj", "kkk").GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_OK, ir.GetStatus().GetCode())
+		requireEmbeddingsBody(t, ir.GetBody(), []string{"[1]", "[2]", "[3]"}, 3)
+	})
+
+	t.Run("batch failure", func(t *testing.T) {
+		ir := send(t, map[string]string{}, "l", "mm", "fail", "n").GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_TooManyRequests, ir.GetStatus().GetCode())
+		require.JSONEq(t, `{"error":{"message":"slow down"}}`, string(ir.GetBody()))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		// The server takes 10ms per batch.
+		rule.Embeddings.BatchTimeout = time.Millisecond
+		defer func() { rule.Embeddings.BatchTimeout = 0 }()
+		ir := send(t, map[string]string{}, "r", "ss", "ttt").GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_BadGateway, ir.GetStatus().GetCode())
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		rule.Embeddings.BatchURL = "http://127.0.0.1:1/v1/embeddings"
+		ir := send(t, map[string]string{}, "o", "pp", "qqq").GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_BadGateway, ir.GetStatus().GetCode())
+		require.True(t, strings.Contains(string(ir.GetBody()), "embeddings_batch_failed"))
+	})
+}
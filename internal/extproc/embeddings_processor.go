@@ -14,21 +14,29 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"strconv"
+	"time"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/envoyproxy/ai-gateway/filterapi"
 	"github.com/envoyproxy/ai-gateway/filterapi/x"
 	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
 	"github.com/envoyproxy/ai-gateway/internal/extproc/backendauth"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/ledger"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/quota"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/redaction"
 	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+	"github.com/envoyproxy/ai-gateway/internal/llmcostcel"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
 )
 
 // EmbeddingsProcessorFactory returns a factory method to instantiate the embeddings processor.
-func EmbeddingsProcessorFactory(em x.EmbeddingsMetrics) ProcessorFactory {
+func EmbeddingsProcessorFactory(em x.EmbeddingsMetrics, opts ProcessorOptions) ProcessorFactory {
 	return func(config *processorConfig, requestHeaders map[string]string, logger *slog.Logger, isUpstreamFilter bool) (Processor, error) {
 		if config.schema.Name != filterapi.APISchemaOpenAI {
 			return nil, fmt.Errorf("unsupported API schema: %s", config.schema.Name)
@@ -36,16 +44,21 @@ func EmbeddingsProcessorFactory(em x.EmbeddingsMetrics) ProcessorFactory {
 		logger = logger.With("processor", "embeddings", "isUpstreamFilter", fmt.Sprintf("%v", isUpstreamFilter))
 		if !isUpstreamFilter {
 			return &embeddingsProcessorRouterFilter{
-				config:         config,
-				requestHeaders: requestHeaders,
-				logger:         logger,
+				config:          config,
+				requestHeaders:  requestHeaders,
+				logger:          logger,
+				responseCache:   opts.ResponseCache,
+				quotas:          opts.Quotas,
+				circuitBreakers: opts.CircuitBreakers,
 			}, nil
 		}
 		return &embeddingsProcessorUpstreamFilter{
-			config:         config,
-			requestHeaders: requestHeaders,
-			logger:         logger,
-			metrics:        em,
+			config:          config,
+			requestHeaders:  requestHeaders,
+			logger:          logger,
+			metrics:         em,
+			usageLedger:     opts.UsageLedger,
+			circuitBreakers: opts.CircuitBreakers,
 		}, nil
 	}
 }
@@ -74,10 +87,36 @@ type embeddingsProcessorRouterFilter struct {
 	// upstreamFilterCount is the number of upstream filters that have been processed.
 	// This is used to determine if the request is a retry request.
 	upstreamFilterCount int
+	// responseCache is the cache of the embeddings of the inputs, if any.
+	responseCache *ResponseCache
+	// embeddingsInputs is the state of the inputs of the request if the rule has [filterapi.Embeddings] configured.
+	embeddingsInputs *embeddingsInputState
+	// embeddingsBatch is true if the request is a batch of a split request sent by this external processor.
+	// See [isEmbeddingsBatch].
+	embeddingsBatch bool
+	// consumer is the consumer authenticated with the consumer key if the selected rule requires one.
+	consumer string
+	// quotas is the token quotas shared across the requests, if any.
+	quotas *quota.Quotas
+	// quotaConsumers are the token quotas applied to the request, which are deducted at the end of the response.
+	quotaConsumers []quotaConsumer
+	// circuitBreakers is the circuit breakers of the backends shared across the requests, if any.
+	circuitBreakers *CircuitBreakers
 }
 
 // ProcessResponseHeaders implements [Processor.ProcessResponseHeaders].
 func (e *embeddingsProcessorRouterFilter) ProcessResponseHeaders(ctx context.Context, headerMap *corev3.HeaderMap) (*extprocv3.ProcessingResponse, error) {
+	if uf, ok := e.upstreamFilter.(*embeddingsProcessorUpstreamFilter); ok {
+		if uf.circuitRejected {
+			// The response is the error returned by the upstream filter itself, so there's nothing to translate.
+			e.upstreamFilter = nil
+		} else if uf.circuitBreaker != nil {
+			headers := headersToMap(headerMap)
+			if status, err := strconv.Atoi(headers[":status"]); err == nil {
+				e.circuitBreakers.record(ctx, uf.backendName, uf.circuitBreaker, status, headers["retry-after"])
+			}
+		}
+	}
 	// If the request failed to route and/or immediate response was returned before the upstream filter was set,
 	// e.upstreamFilter can be nil.
 	if e.upstreamFilter != nil { // See the comment on the "upstreamFilter" field.
@@ -91,17 +130,29 @@ func (e *embeddingsProcessorRouterFilter) ProcessResponseBody(ctx context.Contex
 	// If the request failed to route and/or immediate response was returned before the upstream filter was set,
 	// e.upstreamFilter can be nil.
 	if e.upstreamFilter != nil { // See the comment on the "upstreamFilter" field.
-		return e.upstreamFilter.ProcessResponseBody(ctx, body)
+		resp, err := e.upstreamFilter.ProcessResponseBody(ctx, body)
+		if err == nil && body.EndOfStream && len(e.quotaConsumers) > 0 {
+			if uf, ok := e.upstreamFilter.(*embeddingsProcessorUpstreamFilter); ok {
+				deductTokenQuotas(ctx, e.quotas, e.logger, e.quotaConsumers, uf.costs)
+				e.quotaConsumers = nil
+			}
+		}
+		if err == nil && e.embeddingsInputs != nil {
+			err = e.processEmbeddingsResponse(ctx, resp, body)
+		}
+		return resp, err
 	}
 	return e.passThroughProcessor.ProcessResponseBody(ctx, body)
 }
 
 // ProcessRequestBody implements [Processor.ProcessRequestBody].
-func (e *embeddingsProcessorRouterFilter) ProcessRequestBody(_ context.Context, rawBody *extprocv3.HttpBody) (*extprocv3.ProcessingResponse, error) {
+func (e *embeddingsProcessorRouterFilter) ProcessRequestBody(ctx context.Context, rawBody *extprocv3.HttpBody) (*extprocv3.ProcessingResponse, error) {
 	model, body, err := parseOpenAIEmbeddingBody(rawBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse request body: %w", err)
 	}
+	// This is checked against the body as received before it is modified below.
+	e.embeddingsBatch = isEmbeddingsBatch(e.requestHeaders, rawBody.Body)
 
 	e.requestHeaders[e.config.modelNameHeaderKey] = model
 	routeName, err := e.config.router.Calculate(e.requestHeaders)
@@ -118,6 +169,58 @@ func (e *embeddingsProcessorRouterFilter) ProcessRequestBody(_ context.Context,
 		}
 		return nil, fmt.Errorf("failed to calculate route: %w", err)
 	}
+	consumer, resp := authorizeRequest(e.config, e.logger, e.requestHeaders, routeName, model)
+	if resp != nil {
+		return resp, nil
+	} else if consumer != "" {
+		e.consumer = consumer
+		if e.config.consumerHeaderKey != "" {
+			e.requestHeaders[e.config.consumerHeaderKey] = consumer
+		}
+	} else if e.config.consumerHeaderKey != "" {
+		delete(e.requestHeaders, e.config.consumerHeaderKey)
+	}
+	if rule, ok := e.config.rules[routeName]; ok && !anyBackendAvailable(e.circuitBreakers, rule) {
+		return openAIErrorResponse(typev3.StatusCode_ServiceUnavailable, "server_error", "backends_unavailable", "",
+			fmt.Sprintf("All the backends for the model %s are temporarily unavailable. Please retry later.", model)), nil
+	}
+	if rule, ok := e.config.rules[routeName]; ok {
+		if e.quotaConsumers, resp = checkTokenQuotas(ctx, e.quotas, e.logger, rule, e.requestHeaders, e.consumer); resp != nil {
+			return resp, nil
+		}
+	}
+
+	var bodyMutation *extprocv3.BodyMutation
+	var removeHeaders []string
+	if r, ok := e.config.redactors[routeName]; ok {
+		// Nothing needs to be restored in the embeddings, so the session is only used for the request.
+		var redacted bool
+		rawBody.Body, redacted, err = redactEmbeddingsRequest(r.NewSession(), rawBody.Body)
+		if rejected := (*redaction.RejectedError)(nil); errors.As(err, &rejected) {
+			e.logger.Debug("request rejected by the redaction", "route", routeName, "detector", rejected.Detector)
+			return redactionRejectedResponse(rejected, "input"), nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to redact request body: %w", err)
+		}
+		if redacted {
+			if _, body, err = parseOpenAIEmbeddingBody(rawBody); err != nil {
+				return nil, fmt.Errorf("failed to parse redacted request body: %w", err)
+			}
+			bodyMutation = &extprocv3.BodyMutation{Mutation: &extprocv3.BodyMutation_Body{Body: rawBody.Body}}
+		}
+	}
+	if rule, ok := e.config.rules[routeName]; ok && rule.Embeddings != nil {
+		resp, newRaw, err := e.processEmbeddingsInputs(ctx, rule, rawBody.Body, body)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+		if newRaw != nil {
+			rawBody.Body = newRaw
+			bodyMutation = &extprocv3.BodyMutation{Mutation: &extprocv3.BodyMutation_Body{Body: newRaw}}
+			// The response needs to be decoded to add the cached embeddings.
+			removeHeaders = append(removeHeaders, "accept-encoding")
+		}
+	}
 
 	var additionalHeaders []*corev3.HeaderValueOption
 	additionalHeaders = append(additionalHeaders, &corev3.HeaderValueOption{
@@ -129,6 +232,26 @@ func (e *embeddingsProcessorRouterFilter) ProcessRequestBody(_ context.Context,
 	}, &corev3.HeaderValueOption{
 		Header: &corev3.HeaderValue{Key: originalPathHeader, RawValue: []byte(e.requestHeaders[":path"])},
 	})
+	if e.consumer != "" {
+		set, remove := consumerHeaderMutation(e.config, e.consumer)
+		additionalHeaders = append(additionalHeaders, set...)
+		removeHeaders = append(removeHeaders, remove...)
+	}
+	if e.config.sessionKeyHeaderKey != "" {
+		// The session affinity of the rule also applies to the embeddings requests, so the session key sent by
+		// the client is removed so that the client cannot pick the backend of the request.
+		delete(e.requestHeaders, e.config.sessionKeyHeaderKey)
+		removeHeaders = append(removeHeaders, e.config.sessionKeyHeaderKey)
+	}
+	if _, ok := e.requestHeaders[embeddingsBatchHeader]; ok {
+		// The header is only meaningful to the external processor, so it never reaches the backends.
+		removeHeaders = append(removeHeaders, embeddingsBatchHeader)
+	}
+	if bodyMutation != nil {
+		additionalHeaders = append(additionalHeaders, &corev3.HeaderValueOption{
+			Header: &corev3.HeaderValue{Key: "content-length", RawValue: []byte(strconv.Itoa(len(rawBody.Body)))},
+		})
+	}
 	e.originalRequestBody = body
 	e.originalRequestBodyRaw = rawBody.Body
 	return &extprocv3.ProcessingResponse{
@@ -136,8 +259,10 @@ func (e *embeddingsProcessorRouterFilter) ProcessRequestBody(_ context.Context,
 			RequestBody: &extprocv3.BodyResponse{
 				Response: &extprocv3.CommonResponse{
 					HeaderMutation: &extprocv3.HeaderMutation{
-						SetHeaders: additionalHeaders,
+						SetHeaders:    additionalHeaders,
+						RemoveHeaders: removeHeaders,
 					},
+					BodyMutation:    bodyMutation,
 					ClearRouteCache: true,
 				},
 			},
@@ -166,6 +291,23 @@ type embeddingsProcessorUpstreamFilter struct {
 	costs translator.LLMTokenUsage
 	// metrics tracking.
 	metrics x.EmbeddingsMetrics
+	// pricing is the price list of the models served by the backend, if any.
+	pricing []filterapi.ModelPricing
+	// backendSchema is the name of the API schema of the backend.
+	backendSchema string
+	// requestStart is the time when the request headers are processed at the upstream filter.
+	requestStart time.Time
+	// usageLedger is the usage ledger that the usage record of the request is written to, if any.
+	usageLedger *ledger.Ledger
+	// consumer is the consumer authenticated with the consumer key at the router filter, if any, and metricAttrs
+	// are the extra attributes of the recorded metrics identifying the consumer.
+	consumer    string
+	metricAttrs []attribute.KeyValue
+	// circuitBreakers and circuitBreaker are the circuit breakers and the configuration of the backend, if any.
+	circuitBreakers *CircuitBreakers
+	circuitBreaker  *filterapi.CircuitBreaker
+	// circuitRejected is true if the request was not sent to the backend because its circuit breaker is open.
+	circuitRejected bool
 }
 
 // selectTranslator selects the translator based on the output schema.
@@ -186,13 +328,21 @@ func (e *embeddingsProcessorUpstreamFilter) selectTranslator(out filterapi.Versi
 // with the status CONTINUE_AND_REPLACE. This will allows Envoy to not send the request body again
 // to the extproc.
 func (e *embeddingsProcessorUpstreamFilter) ProcessRequestHeaders(ctx context.Context, _ *corev3.HeaderMap) (res *extprocv3.ProcessingResponse, err error) {
+	if !e.circuitBreakers.allow(ctx, e.backendName, e.circuitBreaker) {
+		// Retried on the other backends by Envoy as in [chatCompletionProcessorUpstreamFilter.ProcessRequestHeaders].
+		e.circuitRejected = true
+		return openAIErrorResponse(typev3.StatusCode_ServiceUnavailable, "server_error", "backend_unavailable", "",
+			fmt.Sprintf("The backend %s is temporarily unavailable.", e.backendName)), nil
+	}
+
 	defer func() {
 		if err != nil {
-			e.metrics.RecordRequestCompletion(ctx, false)
+			e.metrics.RecordRequestCompletion(ctx, false, e.metricAttrs...)
 		}
 	}()
 
 	// Start tracking metrics for this request.
+	e.requestStart = time.Now()
 	e.metrics.StartRequest(e.requestHeaders)
 	e.metrics.SetModel(e.requestHeaders[e.config.modelNameHeaderKey])
 
@@ -240,7 +390,7 @@ func (e *embeddingsProcessorUpstreamFilter) ProcessRequestBody(context.Context,
 func (e *embeddingsProcessorUpstreamFilter) ProcessResponseHeaders(ctx context.Context, headers *corev3.HeaderMap) (res *extprocv3.ProcessingResponse, err error) {
 	defer func() {
 		if err != nil {
-			e.metrics.RecordRequestCompletion(ctx, false)
+			e.metrics.RecordRequestCompletion(ctx, false, e.metricAttrs...)
 		}
 	}()
 
@@ -262,7 +412,7 @@ func (e *embeddingsProcessorUpstreamFilter) ProcessResponseHeaders(ctx context.C
 // ProcessResponseBody implements [Processor.ProcessResponseBody].
 func (e *embeddingsProcessorUpstreamFilter) ProcessResponseBody(ctx context.Context, body *extprocv3.HttpBody) (res *extprocv3.ProcessingResponse, err error) {
 	defer func() {
-		e.metrics.RecordRequestCompletion(ctx, err == nil)
+		e.metrics.RecordRequestCompletion(ctx, err == nil, e.metricAttrs...)
 	}()
 	var br io.Reader
 	var isGzip bool
@@ -310,14 +460,42 @@ func (e *embeddingsProcessorUpstreamFilter) ProcessResponseBody(ctx context.Cont
 	e.costs.TotalTokens += tokenUsage.TotalTokens
 
 	// Update metrics with token usage.
-	e.metrics.RecordTokenUsage(ctx, tokenUsage.InputTokens, tokenUsage.TotalTokens)
+	e.metrics.RecordTokenUsage(ctx, tokenUsage.InputTokens, tokenUsage.TotalTokens, e.metricAttrs...)
 
-	if body.EndOfStream && len(e.config.requestCosts) > 0 {
-		resp.DynamicMetadata, err = buildDynamicMetadata(e.config, &e.costs, e.requestHeaders, e.modelNameOverride, e.backendName)
+	if !body.EndOfStream {
+		return resp, nil
+	}
+	var costMicroUSD float64
+	if p := e.modelPricing(); p != nil {
+		costMicroUSD = requestCostMicroUSD(p, &e.costs)
+		if m, ok := e.metrics.(x.RequestCostMetrics); ok {
+			m.RecordRequestCost(ctx, costMicroUSD/1e6, e.metricAttrs...)
+		}
+	}
+	recordUsage(e.usageLedger, &ledger.Record{
+		Consumer:  e.consumer,
+		Operation: "embedding",
+		Model:     e.backendModel(),
+		Backend:   e.backendName,
+	}, e.requestHeaders, e.responseHeaders, &e.costs, costMicroUSD, e.requestStart)
+	if len(e.config.requestCosts) > 0 {
+		in := &llmcostcel.Input{
+			Model:          e.requestHeaders[e.config.modelNameHeaderKey],
+			Backend:        e.requestHeaders[e.config.selectedRouteHeaderKey],
+			BackendSchema:  e.backendSchema,
+			InputTokens:    e.costs.InputTokens,
+			TotalTokens:    e.costs.TotalTokens,
+			RequestHeaders: e.requestHeaders,
+		}
+		if !e.requestStart.IsZero() {
+			in.LatencyMs = uint64(time.Since(e.requestStart).Milliseconds()) //nolint:gosec
+		}
+		resp.DynamicMetadata, err = buildDynamicMetadata(e.config, in, costMicroUSD, e.modelNameOverride, e.backendName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to build dynamic metadata: %w", err)
 		}
 	}
+	resp.DynamicMetadata = mergeDynamicMetadata(resp.DynamicMetadata, e.config.metadataNamespace, consumerDynamicMetadata(e.consumer))
 
 	return resp, nil
 }
@@ -325,16 +503,23 @@ func (e *embeddingsProcessorUpstreamFilter) ProcessResponseBody(ctx context.Cont
 // SetBackend implements [Processor.SetBackend].
 func (e *embeddingsProcessorUpstreamFilter) SetBackend(ctx context.Context, b *filterapi.Backend, backendHandler backendauth.Handler, routeProcessor Processor) (err error) {
 	defer func() {
-		e.metrics.RecordRequestCompletion(ctx, err == nil)
+		e.metrics.RecordRequestCompletion(ctx, err == nil, e.metricAttrs...)
 	}()
 	rp, ok := routeProcessor.(*embeddingsProcessorRouterFilter)
 	if !ok {
 		panic("BUG: expected routeProcessor to be of type *embeddingsProcessorRouterFilter")
 	}
+	if rp.consumer != "" {
+		e.consumer = rp.consumer
+		e.metricAttrs = []attribute.KeyValue{metrics.ConsumerAttribute(rp.consumer)}
+	}
 	rp.upstreamFilterCount++
 	e.metrics.SetBackend(b)
 	e.modelNameOverride = b.ModelNameOverride
 	e.backendName = b.Name
+	e.circuitBreaker = b.CircuitBreaker
+	e.pricing = b.Pricing
+	e.backendSchema = string(b.Schema.Name)
 	if err = e.selectTranslator(b.Schema); err != nil {
 		return fmt.Errorf("failed to select translator: %w", err)
 	}
@@ -342,10 +527,33 @@ func (e *embeddingsProcessorUpstreamFilter) SetBackend(ctx context.Context, b *f
 	e.originalRequestBody = rp.originalRequestBody
 	e.originalRequestBodyRaw = rp.originalRequestBodyRaw
 	e.onRetry = rp.upstreamFilterCount > 1
+	if prev, ok := rp.upstreamFilter.(*embeddingsProcessorUpstreamFilter); ok && !prev.circuitRejected {
+		// The previous attempt has failed since Envoy retries the request.
+		e.circuitBreakers.record(ctx, prev.backendName, prev.circuitBreaker, 0, "")
+	}
 	rp.upstreamFilter = e
 	return
 }
 
+// modelPricing returns the pricing of the model sent to the backend, or nil if the model is not priced.
+func (e *embeddingsProcessorUpstreamFilter) modelPricing() *filterapi.ModelPricing {
+	if len(e.pricing) == 0 {
+		return nil
+	}
+	return findModelPricing(e.pricing, e.backendModel())
+}
+
+// backendModel returns the name of the model sent to the backend.
+func (e *embeddingsProcessorUpstreamFilter) backendModel() string {
+	if e.modelNameOverride != "" {
+		return e.modelNameOverride
+	}
+	if e.originalRequestBody != nil {
+		return e.originalRequestBody.Model
+	}
+	return e.requestHeaders[e.config.modelNameHeaderKey]
+}
+
 func parseOpenAIEmbeddingBody(body *extprocv3.HttpBody) (modelName string, rb *openai.EmbeddingRequest, err error) {
 	var openAIReq openai.EmbeddingRequest
 	if err := json.Unmarshal(body.Body, &openAIReq); err != nil {
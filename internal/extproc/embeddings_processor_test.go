@@ -28,19 +28,21 @@ import (
 func TestEmbeddings_Schema(t *testing.T) {
 	t.Run("unsupported", func(t *testing.T) {
 		cfg := &processorConfig{schema: filterapi.VersionedAPISchema{Name: "Foo", Version: "v123"}}
-		_, err := EmbeddingsProcessorFactory(nil)(cfg, nil, slog.Default(), false)
+		_, err := EmbeddingsProcessorFactory(nil, ProcessorOptions{})(cfg, nil, slog.Default(), false)
 		require.ErrorContains(t, err, "unsupported API schema: Foo")
 	})
 	t.Run("supported openai / on route", func(t *testing.T) {
 		cfg := &processorConfig{schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI, Version: "v123"}}
-		routeFilter, err := EmbeddingsProcessorFactory(nil)(cfg, nil, slog.Default(), false)
+		rc := NewResponseCache(1, nil)
+		routeFilter, err := EmbeddingsProcessorFactory(nil, ProcessorOptions{ResponseCache: rc})(cfg, nil, slog.Default(), false)
 		require.NoError(t, err)
 		require.NotNil(t, routeFilter)
 		require.IsType(t, &embeddingsProcessorRouterFilter{}, routeFilter)
+		require.Same(t, rc, routeFilter.(*embeddingsProcessorRouterFilter).responseCache)
 	})
 	t.Run("supported openai / on upstream", func(t *testing.T) {
 		cfg := &processorConfig{schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI, Version: "v123"}}
-		routeFilter, err := EmbeddingsProcessorFactory(nil)(cfg, nil, slog.Default(), true)
+		routeFilter, err := EmbeddingsProcessorFactory(nil, ProcessorOptions{})(cfg, nil, slog.Default(), true)
 		require.NoError(t, err)
 		require.NotNil(t, routeFilter)
 		require.IsType(t, &embeddingsProcessorUpstreamFilter{}, routeFilter)
@@ -120,6 +122,26 @@ func Test_embeddingsProcessorRouterFilter_ProcessRequestBody(t *testing.T) {
 		require.Equal(t, "x-ai-eg-original-path", setHeaders[2].Header.Key)
 		require.Equal(t, "/foo", string(setHeaders[2].Header.RawValue))
 	})
+
+	t.Run("session key", func(t *testing.T) {
+		// The session key sent by the client is never passed through to the backends.
+		const sessionKey = "x-session-key"
+		headers := map[string]string{":path": "/foo", sessionKey: "spoofed"}
+		rt := mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		p := &embeddingsProcessorRouterFilter{
+			config:         &processorConfig{router: rt, sessionKeyHeaderKey: sessionKey},
+			requestHeaders: headers,
+			logger:         slog.Default(),
+		}
+		resp, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: embeddingBodyFromModel(t, "some-model")})
+		require.NoError(t, err)
+		hm := resp.GetRequestBody().GetResponse().GetHeaderMutation()
+		require.Contains(t, hm.RemoveHeaders, sessionKey)
+		for _, h := range hm.SetHeaders {
+			require.NotEqual(t, sessionKey, h.Header.Key)
+		}
+		require.NotContains(t, headers, sessionKey)
+	})
 }
 
 func Test_embeddingsProcessorUpstreamFilter_ProcessResponseHeaders(t *testing.T) {
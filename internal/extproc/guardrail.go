@@ -0,0 +1,383 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/google/uuid"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+)
+
+const (
+	// guardrailFlaggedHeader is the response header listing the triggered guardrails with the Flag action.
+	guardrailFlaggedHeader = "x-ai-eg-guardrail-flagged"
+	// defaultGuardrailTimeout is the default timeout of the requests to the moderation backends.
+	defaultGuardrailTimeout = 5 * time.Second
+	// maxGuardrailResponseBodySize is the maximum size of the response body of the moderation backends.
+	maxGuardrailResponseBodySize = 1 << 20
+)
+
+// guardrailHTTPClient is the HTTP client used to call the moderation backends without TLS configuration.
+var guardrailHTTPClient = &http.Client{}
+
+// guardrailPhase is the phase of a request screened by the guardrails.
+type guardrailPhase string
+
+const (
+	guardrailPhaseInput  guardrailPhase = "input"
+	guardrailPhaseOutput guardrailPhase = "output"
+)
+
+// guardrailVerdict is the result of a guardrail on a text.
+type guardrailVerdict struct {
+	guardrail *filterapi.Guardrail
+	// categories are the categories triggering the guardrail, or "flagged" if the guardrail has no categories.
+	// This is empty if the guardrail is not triggered.
+	categories []string
+	err        error
+}
+
+// triggered returns true if the guardrail is triggered, including the failure of the moderation backend
+// with the fail closed mode.
+func (v *guardrailVerdict) triggered() bool {
+	return len(v.categories) > 0 || (v.err != nil && v.guardrail.FailClosed)
+}
+
+// moderationResponse is the response of a moderation backend. The OpenAI moderation API has the results, and the
+// generic HTTP classifiers have the single result at the top level.
+type moderationResponse struct {
+	Results []moderationResult `json:"results"`
+	moderationResult
+}
+
+type moderationResult struct {
+	Flagged        bool               `json:"flagged"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// evaluateGuardrails evaluates the guardrails screening the phase concurrently on the text.
+func evaluateGuardrails(ctx context.Context, config *processorConfig, guardrails []filterapi.Guardrail, phase guardrailPhase, text string) []guardrailVerdict {
+	var verdicts []guardrailVerdict
+	for i := range guardrails {
+		g := &guardrails[i]
+		if (phase == guardrailPhaseInput && g.Input) || (phase == guardrailPhaseOutput && g.Output) {
+			verdicts = append(verdicts, guardrailVerdict{guardrail: g})
+		}
+	}
+	var wg sync.WaitGroup
+	for i := range verdicts {
+		v := &verdicts[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v.categories, v.err = moderate(ctx, config, v.guardrail, text)
+		}()
+	}
+	wg.Wait()
+	return verdicts
+}
+
+// moderate calls the moderation backend of the guardrail with the text, and returns the categories triggering the
+// guardrail, if any.
+func moderate(ctx context.Context, config *processorConfig, g *filterapi.Guardrail, text string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(g.Timeout, defaultGuardrailTimeout))
+	defer cancel()
+
+	reqBody := map[string]string{"input": text}
+	if g.Type == filterapi.GuardrailTypeOpenAIModeration && g.Model != "" {
+		reqBody["model"] = g.Model
+	}
+	raw, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal moderation request: %w", err)
+	}
+	headers := map[string]string{":method": http.MethodPost, ":path": g.Path, "content-type": "application/json"}
+	if b, ok := config.backends[g.Backend.Name]; ok && b.handler != nil {
+		headerMutation := &extprocv3.HeaderMutation{}
+		bodyMutation := &extprocv3.BodyMutation{Mutation: &extprocv3.BodyMutation_Body{Body: raw}}
+		if err = b.handler.Do(ctx, headers, headerMutation, bodyMutation); err != nil {
+			return nil, fmt.Errorf("failed to do auth request: %w", err)
+		}
+		applyHeaderMutation(headers, headerMutation)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.URL+headers[":path"], bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create moderation request: %w", err)
+	}
+	for k, v := range headers {
+		if strings.HasPrefix(k, ":") || strings.EqualFold(k, "content-length") {
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	resp, err := cmp.Or(config.guardrailClients[g.Backend.Name], guardrailHTTPClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send moderation request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxGuardrailResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read moderation response: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("unexpected status code %d of moderation response", resp.StatusCode)
+	}
+	var mr moderationResponse
+	if err = json.Unmarshal(respBody, &mr); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal moderation response: %w", err)
+	}
+	results := mr.Results
+	if g.Type == filterapi.GuardrailTypeHTTPClassifier {
+		results = []moderationResult{mr.moderationResult}
+	}
+
+	var categories []string
+	for _, r := range results {
+		if len(g.Categories) == 0 {
+			if r.Flagged {
+				return []string{"flagged"}, nil
+			}
+			continue
+		}
+		for _, c := range g.Categories {
+			if score, ok := r.CategoryScores[c]; ok && score >= g.Threshold && !slices.Contains(categories, c) {
+				categories = append(categories, c)
+			}
+		}
+	}
+	return categories, nil
+}
+
+// guardrailInputText returns the text of the messages of the chat completion request screened by the guardrails,
+// which are all the messages except the ones of the assistant.
+func guardrailInputText(raw []byte) string {
+	var texts []string
+	gjson.GetBytes(raw, "messages").ForEach(func(_, m gjson.Result) bool {
+		if m.Get("role").String() == "assistant" {
+			return true
+		}
+		if content := m.Get("content"); content.IsArray() {
+			content.ForEach(func(_, part gjson.Result) bool {
+				if part.Get("type").String() == "text" {
+					texts = append(texts, part.Get("text").String())
+				}
+				return true
+			})
+		} else if content.Type == gjson.String {
+			texts = append(texts, content.String())
+		}
+		return true
+	})
+	return strings.Join(texts, "\n")
+}
+
+// guardrailOutputText returns the completions of the non-streamed chat completion response, including the arguments
+// of the tool calls.
+func guardrailOutputText(raw []byte) string {
+	var texts []string
+	gjson.GetBytes(raw, "choices").ForEach(func(_, choice gjson.Result) bool {
+		if content := choice.Get("message.content"); content.Type == gjson.String {
+			texts = append(texts, content.String())
+		}
+		choice.Get("message.tool_calls").ForEach(func(_, tc gjson.Result) bool {
+			texts = append(texts, tc.Get("function.arguments").String())
+			return true
+		})
+		return true
+	})
+	return strings.Join(texts, "\n")
+}
+
+// handleGuardrailVerdicts records the verdicts of the guardrails, and returns the name of the first triggered
+// guardrail with the Block action, if any.
+func (c *chatCompletionProcessorRouterFilter) handleGuardrailVerdicts(ctx context.Context, rule *filterapi.RouteRule,
+	phase guardrailPhase, verdicts []guardrailVerdict,
+) (blocked string) {
+	for i := range verdicts {
+		v := &verdicts[i]
+		g := v.guardrail
+		if v.err != nil {
+			c.logger.Warn("guardrail failed", "guardrail", g.Name, "phase", phase, "fail_closed", g.FailClosed, "error", v.err)
+		}
+		if !v.triggered() {
+			continue
+		}
+		c.logger.Info("guardrail triggered", "guardrail", g.Name, "phase", phase, "action", g.Action, "categories", v.categories)
+		if c.guardrailMetrics != nil {
+			c.guardrailMetrics.RecordViolation(ctx, string(rule.Name), g.Name, string(phase))
+		}
+		switch g.Action {
+		case filterapi.GuardrailActionBlock:
+			if blocked == "" {
+				blocked = g.Name
+			}
+		case filterapi.GuardrailActionFlag:
+			if !slices.Contains(c.guardrailFlagged, g.Name) {
+				c.guardrailFlagged = append(c.guardrailFlagged, g.Name)
+			}
+		case filterapi.GuardrailActionAnnotate:
+			if c.guardrailAnnotations == nil {
+				c.guardrailAnnotations = map[string]*structpb.Value{}
+			}
+			categories := strings.Join(v.categories, ",")
+			if v.err != nil && len(v.categories) == 0 {
+				categories = "moderation_failed"
+			}
+			c.guardrailAnnotations["guardrail_"+string(phase)+"_"+g.Name] = structpb.NewStringValue(categories)
+		}
+	}
+	return
+}
+
+// applyInputGuardrails screens the prompt of the request with the guardrails of the rule, and returns the
+// immediate response if the request is blocked.
+func (c *chatCompletionProcessorRouterFilter) applyInputGuardrails(ctx context.Context, rule *filterapi.RouteRule,
+	raw []byte, body *openai.ChatCompletionRequest,
+) *extprocv3.ProcessingResponse {
+	verdicts := evaluateGuardrails(ctx, c.config, rule.Guardrails, guardrailPhaseInput, guardrailInputText(raw))
+	if blocked := c.handleGuardrailVerdicts(ctx, rule, guardrailPhaseInput, verdicts); blocked != "" {
+		return guardrailBlockedResponse(body.Model, body.Stream, time.Now())
+	}
+	return nil
+}
+
+// applyOutputGuardrails screens the completions of the non-streamed response with the guardrails of the rule, and
+// replaces the completions with the empty ones with the "content_filter" finish reason if blocked.
+func (c *chatCompletionProcessorRouterFilter) applyOutputGuardrails(ctx context.Context, resp *extprocv3.ProcessingResponse, body *extprocv3.HttpBody) error {
+	common := resp.GetResponseBody().GetResponse()
+	if common == nil {
+		return nil
+	}
+	raw := body.Body
+	if b := common.GetBodyMutation().GetBody(); b != nil {
+		raw = b
+	}
+	verdicts := evaluateGuardrails(ctx, c.config, c.guardrailRule.Guardrails, guardrailPhaseOutput, guardrailOutputText(raw))
+	blocked := c.handleGuardrailVerdicts(ctx, c.guardrailRule, guardrailPhaseOutput, verdicts)
+	if blocked != "" || len(c.guardrailFlagged) > 0 {
+		// The blocked completions, e.g. by the fail closed mode during an outage of the moderation backend, must not
+		// be served from the response cache, nor the flagged ones which are served without the flagged header.
+		c.cacheKey = ""
+	}
+	if common.HeaderMutation == nil {
+		common.HeaderMutation = &extprocv3.HeaderMutation{}
+	}
+	if len(c.guardrailFlagged) > 0 {
+		setHeader(common.HeaderMutation, guardrailFlaggedHeader, strings.Join(c.guardrailFlagged, ","))
+	}
+	resp.DynamicMetadata = mergeDynamicMetadata(resp.DynamicMetadata, c.config.metadataNamespace, c.guardrailAnnotations)
+	if blocked == "" {
+		return nil
+	}
+
+	var err error
+	for i := range gjson.GetBytes(raw, "choices").Array() {
+		prefix := "choices." + strconv.Itoa(i)
+		if raw, err = sjson.SetBytes(raw, prefix+".message.content", ""); err == nil {
+			if raw, err = sjson.DeleteBytes(raw, prefix+".message.tool_calls"); err == nil {
+				raw, err = sjson.SetBytes(raw, prefix+".finish_reason", openai.ChatCompletionChoicesFinishReasonContentFilter)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to block completions: %w", err)
+		}
+	}
+	common.HeaderMutation.SetHeaders = slices.DeleteFunc(common.HeaderMutation.SetHeaders, func(h *corev3.HeaderValueOption) bool {
+		return h.GetHeader().GetKey() == "content-length"
+	})
+	setHeader(common.HeaderMutation, "content-length", strconv.Itoa(len(raw)))
+	common.BodyMutation = &extprocv3.BodyMutation{Mutation: &extprocv3.BodyMutation_Body{Body: raw}}
+	return nil
+}
+
+// guardrailBlockedChoice is the choice of the chat completion or the chunk returned when a request is blocked.
+type guardrailBlockedChoice struct {
+	Index        int                                            `json:"index"`
+	Message      *openai.ChatCompletionResponseChoiceMessage    `json:"message,omitempty"`
+	Delta        *openai.ChatCompletionResponseChunkChoiceDelta `json:"delta,omitempty"`
+	FinishReason openai.ChatCompletionChoicesFinishReason       `json:"finish_reason"`
+}
+
+// guardrailBlockedResponse returns the immediate response with the chat completion, or its chunk if streamed,
+// with the empty content and the "content_filter" finish reason.
+func guardrailBlockedResponse(model string, stream bool, now time.Time) *extprocv3.ProcessingResponse {
+	empty := ""
+	choice := guardrailBlockedChoice{FinishReason: openai.ChatCompletionChoicesFinishReasonContentFilter}
+	completion := map[string]any{
+		"id":      "chatcmpl-" + uuid.NewString(),
+		"created": now.Unix(),
+		"model":   model,
+	}
+	contentType := "application/json"
+	if stream {
+		choice.Delta = &openai.ChatCompletionResponseChunkChoiceDelta{Role: "assistant", Content: &empty}
+		completion["object"] = "chat.completion.chunk"
+		contentType = "text/event-stream"
+	} else {
+		choice.Message = &openai.ChatCompletionResponseChoiceMessage{Role: "assistant", Content: &empty}
+		completion["object"] = "chat.completion"
+		completion["usage"] = map[string]int{"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0}
+	}
+	completion["choices"] = []guardrailBlockedChoice{choice}
+	body, _ := json.Marshal(completion)
+	if stream {
+		body = []byte("data: " + string(body) + "\n\ndata: [DONE]\n\n")
+	}
+
+	headers := &extprocv3.HeaderMutation{}
+	setHeader(headers, "content-type", contentType)
+	setHeader(headers, "content-length", strconv.Itoa(len(body)))
+	return &extprocv3.ProcessingResponse{
+		Response: &extprocv3.ProcessingResponse_ImmediateResponse{
+			ImmediateResponse: &extprocv3.ImmediateResponse{
+				Status:  &typev3.HttpStatus{Code: typev3.StatusCode_OK},
+				Headers: headers,
+				Body:    body,
+			},
+		},
+	}
+}
+
+// mergeDynamicMetadata merges the fields into the namespace of the dynamic metadata, and returns the result.
+func mergeDynamicMetadata(metadata *structpb.Struct, namespace string, fields map[string]*structpb.Value) *structpb.Struct {
+	if len(fields) == 0 {
+		return metadata
+	}
+	if metadata == nil {
+		metadata = &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	}
+	ns := metadata.Fields[namespace].GetStructValue()
+	if ns == nil {
+		ns = &structpb.Struct{Fields: map[string]*structpb.Value{}}
+		metadata.Fields[namespace] = structpb.NewStructValue(ns)
+	}
+	for k, v := range fields {
+		ns.Fields[k] = v
+	}
+	return metadata
+}
@@ -0,0 +1,334 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+)
+
+// newModerationServer returns the moderation server responding with the given body to the requests whose input
+// contains "bad", and with the unflagged result otherwise. The inputs received are sent to the returned channel.
+func newModerationServer(t *testing.T, flagged string) (*httptest.Server, chan map[string]string) {
+	inputs := make(chan map[string]string, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req map[string]string
+		require.NoError(t, json.Unmarshal(body, &req))
+		req["path"] = r.URL.Path
+		inputs <- req
+		switch {
+		case strings.Contains(req["input"], "fail"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.Contains(req["input"], "bad"):
+			_, _ = w.Write([]byte(flagged))
+		default:
+			_, _ = w.Write([]byte(`{"results":[{"flagged":false,"category_scores":{"violence":0.01}}]}`))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s, inputs
+}
+
+func Test_moderate(t *testing.T) {
+	const openAIFlagged = `{"results":[{"flagged":true,"category_scores":{"violence":0.9,"hate":0.4}}]}`
+	s, inputs := newModerationServer(t, openAIFlagged)
+	config := &processorConfig{}
+
+	t.Run("flagged", func(t *testing.T) {
+		g := &filterapi.Guardrail{URL: s.URL, Path: "/v1/moderations", Type: filterapi.GuardrailTypeOpenAIModeration, Model: "omni-moderation-latest"}
+		categories, err := moderate(t.Context(), config, g, "bad")
+		require.NoError(t, err)
+		require.Equal(t, []string{"flagged"}, categories)
+		require.Equal(t, map[string]string{"input": "bad", "model": "omni-moderation-latest", "path": "/v1/moderations"}, <-inputs)
+
+		categories, err = moderate(t.Context(), config, g, "good")
+		require.NoError(t, err)
+		require.Empty(t, categories)
+		<-inputs
+	})
+	t.Run("categories", func(t *testing.T) {
+		g := &filterapi.Guardrail{URL: s.URL, Path: "/v1/moderations", Type: filterapi.GuardrailTypeOpenAIModeration,
+			Categories: []string{"hate", "violence", "sexual"}, Threshold: 0.4}
+		categories, err := moderate(t.Context(), config, g, "bad")
+		require.NoError(t, err)
+		require.Equal(t, []string{"hate", "violence"}, categories)
+		<-inputs
+
+		g.Threshold = 0.95
+		categories, err = moderate(t.Context(), config, g, "bad")
+		require.NoError(t, err)
+		require.Empty(t, categories)
+		<-inputs
+	})
+	t.Run("http classifier", func(t *testing.T) {
+		s, inputs := newModerationServer(t, `{"flagged":true,"category_scores":{"jailbreak":0.99}}`)
+		g := &filterapi.Guardrail{URL: s.URL, Path: "/classify", Type: filterapi.GuardrailTypeHTTPClassifier, Model: "ignored",
+			Categories: []string{"jailbreak"}, Threshold: 0.5}
+		categories, err := moderate(t.Context(), config, g, "bad")
+		require.NoError(t, err)
+		require.Equal(t, []string{"jailbreak"}, categories)
+		require.Equal(t, map[string]string{"input": "bad", "path": "/classify"}, <-inputs)
+	})
+	t.Run("tls", func(t *testing.T) {
+		tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(openAIFlagged))
+		}))
+		defer tlsServer.Close()
+		caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}))
+		g := &filterapi.Guardrail{Backend: filterapi.Backend{Name: "moderation"}, URL: tlsServer.URL, Path: "/v1/moderations",
+			Type: filterapi.GuardrailTypeOpenAIModeration}
+		// The default client does not trust the private CA of the moderation backend.
+		_, err := moderate(t.Context(), config, g, "bad")
+		require.ErrorContains(t, err, "certificate signed by unknown authority")
+
+		// The certificate of the test server is valid for example.com.
+		client, err := newDirectBackendHTTPClient(&filterapi.BackendTLS{Hostname: "example.com", CACertificates: caCert}, guardrailHTTPClient)
+		require.NoError(t, err)
+		tlsConfig := &processorConfig{guardrailClients: map[string]*http.Client{"moderation": client}}
+		categories, err := moderate(t.Context(), tlsConfig, g, "bad")
+		require.NoError(t, err)
+		require.Equal(t, []string{"flagged"}, categories)
+	})
+	t.Run("errors", func(t *testing.T) {
+		g := &filterapi.Guardrail{URL: s.URL, Path: "/v1/moderations", Type: filterapi.GuardrailTypeOpenAIModeration}
+		_, err := moderate(t.Context(), config, g, "fail")
+		require.ErrorContains(t, err, "unexpected status code 500 of moderation response")
+		<-inputs
+
+		slow := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { time.Sleep(time.Second) }))
+		defer slow.Close()
+		g = &filterapi.Guardrail{URL: slow.URL, Path: "/v1/moderations", Timeout: 10 * time.Millisecond}
+		_, err = moderate(t.Context(), config, g, "slow")
+		require.ErrorContains(t, err, "context deadline exceeded")
+	})
+}
+
+func Test_guardrailInputText(t *testing.T) {
+	require.Equal(t, "be nice\nhello\nlook\nresult", guardrailInputText([]byte(`{"messages":[`+
+		`{"role":"system","content":"be nice"},`+
+		`{"role":"user","content":"hello"},`+
+		`{"role":"assistant","content":"hi"},`+
+		`{"role":"user","content":[{"type":"text","text":"look"},{"type":"image_url","image_url":{"url":"x"}}]},`+
+		`{"role":"tool","tool_call_id":"1","content":"result"}]}`)))
+}
+
+func Test_guardrailOutputText(t *testing.T) {
+	require.Equal(t, "hello\n{\"a\":1}\nbye", guardrailOutputText([]byte(`{"choices":[`+
+		`{"message":{"content":"hello","tool_calls":[{"function":{"name":"f","arguments":"{\"a\":1}"}}]}},`+
+		`{"message":{"content":"bye"}},{"message":{"content":null}}]}`)))
+}
+
+func Test_guardrailBlockedResponse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, tc := range []struct {
+		stream          bool
+		expContentType  string
+		expBodyTemplate string
+	}{
+		{
+			expContentType: "application/json",
+			expBodyTemplate: `{"id":"%s","object":"chat.completion","created":1700000000,"model":"m",` +
+				`"choices":[{"index":0,"message":{"content":"","role":"assistant"},"finish_reason":"content_filter"}],` +
+				`"usage":{"completion_tokens":0,"prompt_tokens":0,"total_tokens":0}}`,
+		},
+		{
+			stream:         true,
+			expContentType: "text/event-stream",
+			expBodyTemplate: `{"id":"%s","object":"chat.completion.chunk","created":1700000000,"model":"m",` +
+				`"choices":[{"index":0,"delta":{"content":"","role":"assistant"},"finish_reason":"content_filter"}]}`,
+		},
+	} {
+		ir := guardrailBlockedResponse("m", tc.stream, now).GetImmediateResponse()
+		require.Equal(t, typev3.StatusCode_OK, ir.GetStatus().GetCode())
+		require.Equal(t, tc.expContentType, headersOf(ir.GetHeaders())["content-type"])
+		body := string(ir.GetBody())
+		if tc.stream {
+			var ok bool
+			body, ok = strings.CutPrefix(body, "data: ")
+			require.True(t, ok)
+			body, ok = strings.CutSuffix(body, "\n\ndata: [DONE]\n\n")
+			require.True(t, ok)
+		}
+		var completion struct {
+			ID string `json:"id"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(body), &completion))
+		require.True(t, strings.HasPrefix(completion.ID, "chatcmpl-"))
+		require.JSONEq(t, strings.Replace(tc.expBodyTemplate, "%s", completion.ID, 1), body)
+	}
+}
+
+func headersOf(m *extprocv3.HeaderMutation) map[string]string {
+	ret := map[string]string{}
+	for _, h := range m.GetSetHeaders() {
+		ret[h.Header.Key] = string(h.Header.RawValue)
+	}
+	return ret
+}
+
+func TestChatCompletion_guardrails(t *testing.T) {
+	s, inputs := newModerationServer(t, `{"results":[{"flagged":true,"category_scores":{"violence":0.9}}]}`)
+	newGuardrail := func(name string, action filterapi.GuardrailAction, input, output bool) filterapi.Guardrail {
+		return filterapi.Guardrail{
+			Name: name, URL: s.URL, Path: "/v1/moderations", Type: filterapi.GuardrailTypeOpenAIModeration,
+			Input: input, Output: output, Action: action,
+		}
+	}
+
+	// responseCache is the response cache of the route if set.
+	var responseCache *ResponseCache
+	// send sends the request through the router filter, and the response through the upstream filter if the request
+	// is not blocked. This returns the responses of the request body, the response headers and the response body.
+	send := func(t *testing.T, guardrails []filterapi.Guardrail, reqBody, respBody string) (req, respHeaders, resp *extprocv3.ProcessingResponse) {
+		headers := map[string]string{":path": "/v1/chat/completions"}
+		rule := &filterapi.RouteRule{Name: "some-route", Guardrails: guardrails}
+		if responseCache != nil {
+			rule.ResponseCache = &filterapi.ResponseCache{TTL: time.Minute, AllTemperatures: true}
+		}
+		config := &processorConfig{
+			modelNameHeaderKey:     "x-model-name",
+			selectedRouteHeaderKey: "x-route",
+			metadataNamespace:      "ns",
+			router:                 mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+			rules:                  map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule},
+		}
+		rp := &chatCompletionProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default(), responseCache: responseCache}
+		req, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(reqBody)})
+		require.NoError(t, err)
+		if req.GetImmediateResponse() != nil {
+			return req, nil, nil
+		}
+		uf := &chatCompletionProcessorUpstreamFilter{
+			config:         config,
+			requestHeaders: map[string]string{":path": "/v1/chat/completions"},
+			logger:         slog.Default(),
+			metrics:        &mockChatCompletionMetrics{},
+		}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, rp))
+		_, err = uf.ProcessRequestHeaders(t.Context(), nil)
+		require.NoError(t, err)
+		respHeaders, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "200"}}})
+		require.NoError(t, err)
+		resp, err = rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{Body: []byte(respBody), EndOfStream: true})
+		require.NoError(t, err)
+		return
+	}
+	const jsonResp = `{"choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}],"usage":{"total_tokens":3}}`
+
+	t.Run("input block", func(t *testing.T) {
+		guardrails := []filterapi.Guardrail{newGuardrail("jailbreak", filterapi.GuardrailActionBlock, true, false)}
+		req, _, _ := send(t, guardrails, `{"model":"m","messages":[{"role":"user","content":"bad prompt"}]}`, jsonResp)
+		ir := req.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Contains(t, string(ir.GetBody()), `"finish_reason":"content_filter"`)
+		require.Equal(t, "bad prompt", (<-inputs)["input"])
+
+		req, _, _ = send(t, guardrails, `{"model":"m","messages":[{"role":"user","content":"good prompt"}]}`, jsonResp)
+		require.Nil(t, req.GetImmediateResponse())
+		// The response is not decoded as no guardrail screens the output.
+		require.Empty(t, req.GetRequestBody().GetResponse().GetHeaderMutation().GetRemoveHeaders())
+		<-inputs
+	})
+
+	t.Run("flag and annotate", func(t *testing.T) {
+		guardrails := []filterapi.Guardrail{
+			newGuardrail("flag", filterapi.GuardrailActionFlag, true, false),
+			newGuardrail("annotate", filterapi.GuardrailActionAnnotate, true, false),
+		}
+		req, respHeaders, _ := send(t, guardrails, `{"model":"m","messages":[{"role":"user","content":"bad prompt"}]}`, jsonResp)
+		require.Nil(t, req.GetImmediateResponse())
+		require.Equal(t, "flagged", req.GetDynamicMetadata().GetFields()["ns"].GetStructValue().GetFields()["guardrail_input_annotate"].GetStringValue())
+		require.Equal(t, "flag", headersOf(respHeaders.GetResponseHeaders().GetResponse().GetHeaderMutation())[guardrailFlaggedHeader])
+		<-inputs
+		<-inputs
+	})
+
+	t.Run("failure mode", func(t *testing.T) {
+		open := newGuardrail("open", filterapi.GuardrailActionBlock, true, false)
+		req, _, _ := send(t, []filterapi.Guardrail{open}, `{"model":"m","messages":[{"role":"user","content":"fail"}]}`, jsonResp)
+		require.Nil(t, req.GetImmediateResponse())
+		<-inputs
+
+		closed := newGuardrail("closed", filterapi.GuardrailActionBlock, true, false)
+		closed.FailClosed = true
+		req, _, _ = send(t, []filterapi.Guardrail{closed}, `{"model":"m","messages":[{"role":"user","content":"fail"}]}`, jsonResp)
+		require.NotNil(t, req.GetImmediateResponse())
+		<-inputs
+	})
+
+	t.Run("output", func(t *testing.T) {
+		guardrails := []filterapi.Guardrail{
+			newGuardrail("output", filterapi.GuardrailActionBlock, false, true),
+			newGuardrail("flag", filterapi.GuardrailActionFlag, false, true),
+		}
+		const badResp = `{"choices":[{"index":0,"message":{"role":"assistant","content":"bad answer","tool_calls":[{"id":"1","type":"function","function":{"name":"f","arguments":"{}"}}]},"finish_reason":"tool_calls"}],"usage":{"total_tokens":3}}`
+		req, _, resp := send(t, guardrails, `{"model":"m","messages":[{"role":"user","content":"prompt"}]}`, badResp)
+		require.Equal(t, []string{"accept-encoding"}, req.GetRequestBody().GetResponse().GetHeaderMutation().GetRemoveHeaders())
+		common := resp.GetResponseBody().GetResponse()
+		const expBody = `{"choices":[{"index":0,"message":{"role":"assistant","content":""},"finish_reason":"content_filter"}],"usage":{"total_tokens":3}}`
+		require.JSONEq(t, expBody, string(common.GetBodyMutation().GetBody()))
+		headers := headersOf(common.GetHeaderMutation())
+		require.Equal(t, "flag", headers[guardrailFlaggedHeader])
+		require.Equal(t, strconv.Itoa(len(common.GetBodyMutation().GetBody())), headers["content-length"])
+		require.Equal(t, "bad answer\n{}", (<-inputs)["input"])
+		<-inputs
+
+		_, _, resp = send(t, guardrails, `{"model":"m","messages":[{"role":"user","content":"prompt"}]}`, jsonResp)
+		require.Nil(t, resp.GetResponseBody().GetResponse().GetBodyMutation())
+		<-inputs
+		<-inputs
+
+		// The streamed completions are not screened.
+		_, _, _ = send(t, guardrails, `{"model":"m","stream":true,"messages":[{"role":"user","content":"prompt"}]}`, "data: [DONE]\n\n")
+		require.Empty(t, inputs)
+	})
+
+	t.Run("output not cached", func(t *testing.T) {
+		responseCache = NewResponseCache(1<<20, nil)
+		defer func() { responseCache = nil }()
+		closed := newGuardrail("closed", filterapi.GuardrailActionBlock, false, true)
+		closed.FailClosed = true
+		flag := newGuardrail("flag", filterapi.GuardrailActionFlag, false, true)
+
+		// The moderation backend fails, so the fail closed guardrail blocks the completion.
+		const failResp = `{"choices":[{"index":0,"message":{"role":"assistant","content":"fail"},"finish_reason":"stop"}]}`
+		_, _, resp := send(t, []filterapi.Guardrail{closed}, `{"model":"m","messages":[{"role":"user","content":"first"}]}`, failResp)
+		require.Contains(t, string(resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody()), `"finish_reason":"content_filter"`)
+		<-inputs
+		require.Empty(t, responseCache.entries)
+
+		const badResp = `{"choices":[{"index":0,"message":{"role":"assistant","content":"bad answer"},"finish_reason":"stop"}]}`
+		_, _, resp = send(t, []filterapi.Guardrail{flag}, `{"model":"m","messages":[{"role":"user","content":"second"}]}`, badResp)
+		require.Equal(t, "flag", headersOf(resp.GetResponseBody().GetResponse().GetHeaderMutation())[guardrailFlaggedHeader])
+		<-inputs
+		require.Empty(t, responseCache.entries)
+
+		// The completions passing the guardrails are cached.
+		_, _, _ = send(t, []filterapi.Guardrail{closed, flag}, `{"model":"m","messages":[{"role":"user","content":"third"}]}`, jsonResp)
+		<-inputs
+		<-inputs
+		require.Len(t, responseCache.entries, 1)
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+)
+
+// hedgeAttemptHeader is the response header populated by the upstream filter of a hedged request with the
+// attempt number, so that the router filter can tell which of the concurrent attempts won the race.
+// This is removed from the response before it is sent to the client.
+const hedgeAttemptHeader = "x-ai-eg-hedge-attempt"
+
+// hedgedRequest tracks the attempts of a request of a rule with hedging.
+//
+// With hedging, Envoy keeps the original attempt in flight when it sends the hedged one, so the upstream
+// filters of the attempts run concurrently, and the last one to start is not necessarily the one whose
+// response is returned to the client.
+type hedgedRequest struct {
+	mu       sync.Mutex
+	attempts []*chatCompletionProcessorUpstreamFilter
+	// promptTokens is the estimated number of prompt tokens of the request. See [estimatePromptTokens].
+	promptTokens uint32
+}
+
+// addAttempt registers the upstream filter of a new attempt and returns its attempt number starting from 1.
+func (h *hedgedRequest) addAttempt(c *chatCompletionProcessorUpstreamFilter) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.attempts = append(h.attempts, c)
+	return len(h.attempts)
+}
+
+// winner returns the upstream filter of the attempt in the given value of [hedgeAttemptHeader], or nil if no attempt
+// has been made. When the value does not refer to a known attempt, e.g. when the response is generated by Envoy
+// after all the attempts timed out, the last attempt is returned as is the case without hedging.
+//
+// The estimated prompt tokens of the other attempts are accounted to the winner since they have been sent to
+// the providers, which usually bill the prompt regardless of the cancellation. This is only an estimate of their
+// usage: Envoy resets the other attempts without passing their responses to the filter, so the actual usage
+// reported by the providers, including the completion tokens generated before the cancellation, is unknown.
+func (h *hedgedRequest) winner(attemptHeader string) *chatCompletionProcessorUpstreamFilter {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.attempts) == 0 {
+		return nil
+	}
+	n, err := strconv.Atoi(attemptHeader)
+	if err != nil || n < 1 || n > len(h.attempts) {
+		n = len(h.attempts)
+	}
+	w := h.attempts[n-1]
+	cancelled := uint32(len(h.attempts) - 1) //nolint:gosec
+	w.hedgeCosts = translator.LLMTokenUsage{
+		InputTokens: cancelled * h.promptTokens,
+		TotalTokens: cancelled * h.promptTokens,
+	}
+	return w
+}
@@ -0,0 +1,107 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3http "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_proc/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+)
+
+func Test_hedgedRequest_winner(t *testing.T) {
+	h := &hedgedRequest{promptTokens: 10}
+	require.Nil(t, h.winner("1"))
+
+	a1, a2, a3 := &chatCompletionProcessorUpstreamFilter{}, &chatCompletionProcessorUpstreamFilter{}, &chatCompletionProcessorUpstreamFilter{}
+	require.Equal(t, 1, h.addAttempt(a1))
+	require.Equal(t, 2, h.addAttempt(a2))
+	require.Equal(t, 3, h.addAttempt(a3))
+
+	t.Run("known attempt", func(t *testing.T) {
+		require.Same(t, a2, h.winner("2"))
+		require.Equal(t, translator.LLMTokenUsage{InputTokens: 20, TotalTokens: 20}, a2.hedgeCosts)
+	})
+	t.Run("unknown attempt", func(t *testing.T) {
+		for _, v := range []string{"", "0", "4", "foo"} {
+			require.Same(t, a3, h.winner(v))
+		}
+	})
+}
+
+func TestChatCompletion_hedging(t *testing.T) {
+	const modelKey = "x-ai-gateway-model-key"
+	config := &processorConfig{
+		modelNameHeaderKey: modelKey,
+		metadataNamespace:  "ns",
+		requestCosts: []processorConfigRequestCost{
+			{LLMRequestCost: &filterapi.LLMRequestCost{Type: filterapi.LLMRequestCostTypeInputToken, MetadataKey: "input_token_usage"}},
+		},
+	}
+	rp := &chatCompletionProcessorRouterFilter{
+		config:                 config,
+		logger:                 slog.Default(),
+		originalRequestBodyRaw: bodyFromModel(t, "some-model", false),
+		hedge:                  &hedgedRequest{promptTokens: 100},
+	}
+	require.NoError(t, json.Unmarshal(rp.originalRequestBodyRaw, &rp.originalRequestBody))
+
+	newAttempt := func() *chatCompletionProcessorUpstreamFilter {
+		c := &chatCompletionProcessorUpstreamFilter{
+			config:         config,
+			requestHeaders: map[string]string{":path": "/v1/chat/completions", modelKey: "some-model"},
+			logger:         slog.Default(),
+			metrics:        &mockChatCompletionMetrics{},
+		}
+		err := c.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, rp)
+		require.NoError(t, err)
+		return c
+	}
+	a1, a2 := newAttempt(), newAttempt()
+	require.Equal(t, 1, a1.hedgeAttempt)
+	require.False(t, a1.onRetry)
+	require.Equal(t, 2, a2.hedgeAttempt)
+	require.True(t, a2.onRetry)
+	require.Nil(t, rp.upstreamFilter)
+
+	// The response headers of the attempts are sent to the upstream filters.
+	resp, err := a1.ProcessRequestHeaders(t.Context(), nil)
+	require.NoError(t, err)
+	require.Equal(t, extprocv3http.ProcessingMode_SEND, resp.ModeOverride.ResponseHeaderMode)
+	require.Equal(t, extprocv3http.ProcessingMode_NONE, resp.ModeOverride.ResponseBodyMode)
+
+	// The first attempt wins the race.
+	resp, err = a1.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{})
+	require.NoError(t, err)
+	setHeaders := resp.GetResponseHeaders().GetResponse().GetHeaderMutation().GetSetHeaders()
+	require.Len(t, setHeaders, 1)
+	require.Equal(t, hedgeAttemptHeader, setHeaders[0].Header.Key)
+	require.Equal(t, "1", string(setHeaders[0].Header.RawValue))
+
+	resp, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{
+		{Key: ":status", Value: "200"}, {Key: hedgeAttemptHeader, RawValue: []byte("1")},
+	}})
+	require.NoError(t, err)
+	require.Same(t, a1, rp.upstreamFilter)
+	require.Contains(t, resp.GetResponseHeaders().GetResponse().GetHeaderMutation().GetRemoveHeaders(), hedgeAttemptHeader)
+
+	// The prompt tokens of the cancelled attempt are accounted in the cost metadata.
+	resp, err = rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{
+		Body: []byte(`{"usage":{"prompt_tokens":90,"completion_tokens":5,"total_tokens":95}}`), EndOfStream: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, float64(190), resp.DynamicMetadata.Fields["ns"].GetStructValue().Fields["input_token_usage"].GetNumberValue())
+	require.Equal(t, uint32(90), a1.costs.InputTokens)
+}
@@ -0,0 +1,60 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger", "usage.jsonl")
+	// Each line below is 110 bytes or so, so every write except the first one rotates the file.
+	sink, err := NewFileSink(path, 150, 2)
+	require.NoError(t, err)
+
+	for _, id := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, sink.Write(t.Context(), []Record{{RequestID: id, Operation: "chat", Status: 200}}))
+	}
+	require.NoError(t, sink.Close(t.Context()))
+
+	for file, expID := range map[string]string{path: "d", path + ".1": "c", path + ".2": "b"} {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.Equal(t,
+			`{"time":"0001-01-01T00:00:00Z","request_id":"`+expID+`","operation":"chat","status":200,"input_tokens":0,"output_tokens":0,"total_tokens":0,"latency_ms":0}`+"\n",
+			string(content))
+	}
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err))
+
+	t.Run("append to existing", func(t *testing.T) {
+		sink, err := NewFileSink(path, 1<<20, 2)
+		require.NoError(t, err)
+		require.NoError(t, sink.Write(t.Context(), []Record{{RequestID: "e"}, {RequestID: "f"}}))
+		require.NoError(t, sink.Close(t.Context()))
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Len(t, strings.Split(strings.TrimSpace(string(content)), "\n"), 3)
+	})
+
+	t.Run("no backups", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "usage.jsonl")
+		sink, err := NewFileSink(path, 1, 0)
+		require.NoError(t, err)
+		require.NoError(t, sink.Write(t.Context(), []Record{{RequestID: "a"}}))
+		require.NoError(t, sink.Write(t.Context(), []Record{{RequestID: "b"}}))
+		require.NoError(t, sink.Close(t.Context()))
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Contains(t, string(content), `"request_id":"b"`)
+		require.NotContains(t, string(content), `"request_id":"a"`)
+	})
+}
@@ -0,0 +1,138 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+// Package ledger implements the usage ledger that writes one usage record per request to a [Sink],
+// which is meant to be the audit-grade source of the chargeback unlike the aggregated metrics.
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/envoyproxy/ai-gateway/internal/extproc/batchwriter"
+)
+
+// Record is the usage record of a request.
+type Record struct {
+	// Time is the time when the response is completed.
+	Time time.Time `json:"time"`
+	// RequestID is the x-request-id header of the request.
+	RequestID string `json:"request_id,omitempty"`
+	// Consumer is the consumer authenticated with the consumer key, if any. The headers sent by the client are
+	// never used since the records are used for the chargeback.
+	Consumer string `json:"consumer,omitempty"`
+	// Operation is the kind of the request, e.g. "chat" or "embedding".
+	Operation string `json:"operation"`
+	// Model is the name of the model sent to the backend.
+	Model string `json:"model,omitempty"`
+	// Backend is the name of the backend that served the request.
+	Backend string `json:"backend,omitempty"`
+	// Status is the HTTP status code of the response from the backend.
+	Status int `json:"status"`
+	// Stream is true if the request is a streaming request.
+	Stream bool `json:"stream,omitempty"`
+
+	InputTokens       uint32 `json:"input_tokens"`
+	OutputTokens      uint32 `json:"output_tokens"`
+	TotalTokens       uint32 `json:"total_tokens"`
+	CachedInputTokens uint32 `json:"cached_input_tokens,omitempty"`
+	ReasoningTokens   uint32 `json:"reasoning_tokens,omitempty"`
+	AudioInputTokens  uint32 `json:"audio_input_tokens,omitempty"`
+	AudioOutputTokens uint32 `json:"audio_output_tokens,omitempty"`
+
+	// CostUSD is the cost of the request in US dollars computed from the pricing of the backend, if any.
+	CostUSD float64 `json:"cost_usd,omitempty"`
+	// LatencyMs is the latency of the request to the backend in milliseconds.
+	LatencyMs int64 `json:"latency_ms"`
+}
+
+// Sink is the destination of the usage records.
+//
+// Write is only called by a single goroutine of the [Ledger], so the implementations do not need to be
+// safe for concurrent use.
+type Sink interface {
+	// Write writes the batch of the records. Any retry is up to the implementation.
+	Write(ctx context.Context, records []Record) error
+	// Close flushes the pending records and releases the resources.
+	Close(ctx context.Context) error
+}
+
+// NewSink creates a [Sink] from the given specification:
+//
+//   - "file:///path/to/usage.jsonl?maxSizeMB=100&maxBackups=5" writes the records to the JSONL file rotated at maxSizeMB.
+//   - "http://..." or "https://..." posts the batches of the records to the webhook as a JSON array.
+//   - "otlp" exports the records as OTLP logs configured with the standard OTEL_EXPORTER_OTLP_* environment variables.
+func NewSink(ctx context.Context, spec string) (Sink, error) {
+	if spec == "otlp" {
+		return NewOTLPSink(ctx)
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid usage ledger sink %q: %w", spec, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return NewWebhookSink(spec), nil
+	case "file":
+		fs, err := batchwriter.ParseFileSpec(u, "usage ledger sink")
+		if err != nil {
+			return nil, err
+		}
+		return NewFileSink(fs.Path, fs.MaxSize, fs.MaxBackups)
+	default:
+		return nil, fmt.Errorf("unsupported usage ledger sink %q", spec)
+	}
+}
+
+// NewFileSink creates a new [Sink] appending the records to the JSONL file at the path rotated at maxSize.
+func NewFileSink(path string, maxSize int64, maxBackups int) (Sink, error) {
+	return batchwriter.NewJSONLFile[Record](path, maxSize, maxBackups)
+}
+
+const (
+	defaultQueueSize     = 10000
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+// Ledger writes the usage records to the [Sink] in the background.
+//
+// The records are queued in memory and written in batches. When the queue is full because the sink cannot keep up,
+// the records are dropped instead of blocking the request path, and the number of dropped records is logged.
+type Ledger struct {
+	sink  Sink
+	queue *batchwriter.Queue[Record]
+}
+
+// New creates a new Ledger writing to the sink and starts its background goroutine.
+func New(sink Sink, logger *slog.Logger) *Ledger {
+	return newLedger(sink, logger, defaultQueueSize, defaultBatchSize, defaultFlushInterval)
+}
+
+func newLedger(sink Sink, logger *slog.Logger, queueSize, batchSize int, flushInterval time.Duration) *Ledger {
+	return &Ledger{
+		sink:  sink,
+		queue: batchwriter.NewQueue("usage records", sink.Write, logger, queueSize, batchSize, flushInterval),
+	}
+}
+
+// Record queues the usage record without blocking. This is a no-op on a nil Ledger.
+func (l *Ledger) Record(rec *Record) {
+	if l == nil {
+		return
+	}
+	l.queue.Add(*rec)
+}
+
+// Close writes the queued records and closes the sink.
+func (l *Ledger) Close(ctx context.Context) error {
+	if err := l.queue.Close(ctx); err != nil {
+		return err
+	}
+	return l.sink.Close(ctx)
+}
@@ -0,0 +1,103 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package ledger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink is a [Sink] that records the written batches, optionally blocking until unblock is closed.
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]Record
+	unblock chan struct{}
+	closed  bool
+}
+
+func (r *recordingSink) Write(_ context.Context, records []Record) error {
+	if r.unblock != nil {
+		<-r.unblock
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]Record(nil), records...))
+	return nil
+}
+
+func (r *recordingSink) Close(context.Context) error {
+	r.closed = true
+	return nil
+}
+
+func (r *recordingSink) records() (ret []Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.batches {
+		ret = append(ret, b...)
+	}
+	return
+}
+
+func TestLedger(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("batch and close", func(t *testing.T) {
+		sink := &recordingSink{}
+		l := newLedger(sink, logger, 10, 2, time.Hour)
+		for i := range 3 {
+			l.Record(&Record{RequestID: string(rune('a' + i))})
+		}
+		// The first two records are written as a full batch, and the last one is written on Close.
+		require.Eventually(t, func() bool { return len(sink.records()) == 2 }, time.Second, 10*time.Millisecond)
+		require.NoError(t, l.Close(t.Context()))
+		require.True(t, sink.closed)
+		require.Len(t, sink.batches, 2)
+		require.Equal(t, []Record{{RequestID: "a"}, {RequestID: "b"}, {RequestID: "c"}}, sink.records())
+	})
+
+	t.Run("flush interval", func(t *testing.T) {
+		sink := &recordingSink{}
+		l := newLedger(sink, logger, 10, 100, 10*time.Millisecond)
+		l.Record(&Record{RequestID: "a"})
+		require.Eventually(t, func() bool { return len(sink.records()) == 1 }, time.Second, 10*time.Millisecond)
+		require.NoError(t, l.Close(t.Context()))
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		var l *Ledger
+		l.Record(&Record{})
+	})
+}
+
+func TestNewSink(t *testing.T) {
+	for _, tc := range []struct {
+		name, spec, expErr string
+	}{
+		{name: "file", spec: "file://" + filepath.Join(t.TempDir(), "usage.jsonl") + "?maxSizeMB=1&maxBackups=2"},
+		{name: "webhook", spec: "https://example.com/usage"},
+		{name: "invalid maxSizeMB", spec: "file:///tmp/usage.jsonl?maxSizeMB=0", expErr: `invalid maxSizeMB "0" of usage ledger sink`},
+		{name: "invalid maxBackups", spec: "file:///tmp/usage.jsonl?maxBackups=x", expErr: `invalid maxBackups "x" of usage ledger sink`},
+		{name: "unsupported", spec: "kafka://broker", expErr: `unsupported usage ledger sink "kafka://broker"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sink, err := NewSink(t.Context(), tc.spec)
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, sink.Close(t.Context()))
+		})
+	}
+}
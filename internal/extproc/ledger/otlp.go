@@ -0,0 +1,76 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package ledger
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// otlpEventName is the event name of the usage log records.
+const otlpEventName = "aigw.usage"
+
+// otlpSink implements [Sink] by exporting the records as OTLP log records.
+type otlpSink struct {
+	provider *sdklog.LoggerProvider
+	logger   log.Logger
+}
+
+// NewOTLPSink creates a new [Sink] exporting the records with the OTLP/HTTP log exporter, which is configured
+// with the standard OTEL_EXPORTER_OTLP_* environment variables.
+func NewOTLPSink(ctx context.Context) (Sink, error) {
+	exporter, err := otlploghttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %w", err)
+	}
+	return newOTLPSink(exporter), nil
+}
+
+func newOTLPSink(exporter sdklog.Exporter) *otlpSink {
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)))
+	return &otlpSink{provider: provider, logger: provider.Logger("github.com/envoyproxy/ai-gateway/ledger")}
+}
+
+// Write implements [Sink.Write].
+func (o *otlpSink) Write(ctx context.Context, records []Record) error {
+	for i := range records {
+		rec := &records[i]
+		var r log.Record
+		r.SetTimestamp(rec.Time)
+		r.SetEventName(otlpEventName)
+		r.SetSeverity(log.SeverityInfo)
+		r.SetBody(log.StringValue(otlpEventName))
+		r.AddAttributes(
+			log.String("request_id", rec.RequestID),
+			log.String("consumer", rec.Consumer),
+			log.String("gen_ai.operation.name", rec.Operation),
+			log.String("gen_ai.request.model", rec.Model),
+			log.String("backend", rec.Backend),
+			log.Int("http.response.status_code", rec.Status),
+			log.Bool("stream", rec.Stream),
+			log.Int64("gen_ai.usage.input_tokens", int64(rec.InputTokens)),
+			log.Int64("gen_ai.usage.output_tokens", int64(rec.OutputTokens)),
+			log.Int64("gen_ai.usage.total_tokens", int64(rec.TotalTokens)),
+			log.Int64("gen_ai.usage.cached_input_tokens", int64(rec.CachedInputTokens)),
+			log.Int64("gen_ai.usage.reasoning_tokens", int64(rec.ReasoningTokens)),
+			log.Int64("gen_ai.usage.audio_input_tokens", int64(rec.AudioInputTokens)),
+			log.Int64("gen_ai.usage.audio_output_tokens", int64(rec.AudioOutputTokens)),
+			log.Float64("cost_usd", rec.CostUSD),
+			log.Int64("latency_ms", rec.LatencyMs),
+		)
+		o.logger.Emit(ctx, r)
+	}
+	return nil
+}
+
+// Close implements [Sink.Close].
+func (o *otlpSink) Close(ctx context.Context) error {
+	return o.provider.Shutdown(ctx)
+}
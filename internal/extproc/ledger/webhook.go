@@ -0,0 +1,86 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhookSink implements [Sink] by posting the batches of the records to an HTTP endpoint as a JSON array.
+//
+// The request is retried with an exponential backoff on the network errors, 429 and 5xx responses.
+type webhookSink struct {
+	url        string
+	client     *http.Client
+	maxRetries int
+	backoff    time.Duration
+}
+
+// NewWebhookSink creates a new [Sink] posting the records to the URL.
+func NewWebhookSink(url string) Sink {
+	return &webhookSink{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		maxRetries: 3,
+		backoff:    500 * time.Millisecond,
+	}
+}
+
+// Write implements [Sink.Write].
+func (w *webhookSink) Write(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage records: %w", err)
+	}
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= w.maxRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", err, ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// post sends the body once, and returns whether the error is worth retrying.
+func (w *webhookSink) post(ctx context.Context, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create the webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to post usage records: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
+
+// Close implements [Sink.Close].
+func (w *webhookSink) Close(context.Context) error {
+	w.client.CloseIdleConnections()
+	return nil
+}
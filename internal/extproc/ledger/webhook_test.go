@@ -0,0 +1,69 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSink(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		statuses    []int
+		expAttempts int32
+		expErr      string
+	}{
+		{name: "ok", statuses: []int{http.StatusOK}, expAttempts: 1},
+		{name: "retry on 5xx and 429", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusAccepted}, expAttempts: 3},
+		{name: "no retry on 4xx", statuses: []int{http.StatusBadRequest}, expAttempts: 1, expErr: "webhook responded with status 400"},
+		{name: "retries exhausted", statuses: []int{500, 500, 500, 500, 500}, expAttempts: 4, expErr: "webhook responded with status 500"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := attempts.Add(1)
+				require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				var records []Record
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&records))
+				require.Equal(t, []Record{{RequestID: "a", CostUSD: 0.5}, {RequestID: "b"}}, records)
+				w.WriteHeader(tc.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			sink := NewWebhookSink(srv.URL).(*webhookSink)
+			sink.backoff = time.Millisecond
+			err := sink.Write(t.Context(), []Record{{RequestID: "a", CostUSD: 0.5}, {RequestID: "b"}})
+			if tc.expErr != "" {
+				require.EqualError(t, err, tc.expErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.expAttempts, attempts.Load())
+			require.NoError(t, sink.Close(t.Context()))
+		})
+	}
+
+	t.Run("context canceled during backoff", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+		sink := NewWebhookSink(srv.URL).(*webhookSink)
+		sink.backoff = time.Hour
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+		err := sink.Write(ctx, []Record{{RequestID: "a"}})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -183,6 +183,9 @@ type mockChatCompletionMetrics struct {
 	requestErrorCount   int
 	tokenUsageCount     int
 	tokenLatencyCount   int
+	requestCost         float64
+	cachedInputTokens   uint32
+	reasoningTokens     uint32
 	timeToFirstToken    float64
 	interTokenLatency   float64
 }
@@ -201,11 +204,22 @@ func (m *mockChatCompletionMetrics) RecordTokenUsage(_ context.Context, _, _, _
 	m.tokenUsageCount++
 }
 
+// RecordTokenUsageDetails implements [x.TokenUsageDetailsMetrics].
+func (m *mockChatCompletionMetrics) RecordTokenUsageDetails(_ context.Context, cachedInputTokens, reasoningTokens, _, _ uint32, _ ...attribute.KeyValue) {
+	m.cachedInputTokens += cachedInputTokens
+	m.reasoningTokens += reasoningTokens
+}
+
 // RecordTokenLatency implements [metrics.ChatCompletion].
 func (m *mockChatCompletionMetrics) RecordTokenLatency(_ context.Context, _ uint32, _ ...attribute.KeyValue) {
 	m.tokenLatencyCount++
 }
 
+// RecordRequestCost implements [x.RequestCostMetrics].
+func (m *mockChatCompletionMetrics) RecordRequestCost(_ context.Context, usd float64, _ ...attribute.KeyValue) {
+	m.requestCost += usd
+}
+
 // GetTimeToFirstTokenMs implements [metrics.ChatCompletion].
 func (m *mockChatCompletionMetrics) GetTimeToFirstTokenMs() float64 {
 	m.timeToFirstToken = 1.0
@@ -261,7 +275,11 @@ func (m *mockChatCompletionMetrics) RequireTokensRecorded(t *testing.T, count in
 	require.Equal(t, count, m.tokenLatencyCount)
 }
 
-var _ x.ChatCompletionMetrics = &mockChatCompletionMetrics{}
+var (
+	_ x.ChatCompletionMetrics    = &mockChatCompletionMetrics{}
+	_ x.RequestCostMetrics       = &mockChatCompletionMetrics{}
+	_ x.TokenUsageDetailsMetrics = &mockChatCompletionMetrics{}
+)
 
 // mockEmbeddingTranslator implements [translator.OpenAIEmbeddingTranslator] for testing.
 type mockEmbeddingTranslator struct {
@@ -305,6 +323,7 @@ type mockEmbeddingsMetrics struct {
 	requestSuccessCount int
 	requestErrorCount   int
 	tokenUsageCount     int
+	requestCost         float64
 }
 
 // StartRequest implements [x.EmbeddingsMetrics].
@@ -321,6 +340,11 @@ func (m *mockEmbeddingsMetrics) RecordTokenUsage(_ context.Context, _, _ uint32,
 	m.tokenUsageCount++
 }
 
+// RecordRequestCost implements [x.EmbeddingsMetrics].
+func (m *mockEmbeddingsMetrics) RecordRequestCost(_ context.Context, usd float64, _ ...attribute.KeyValue) {
+	m.requestCost += usd
+}
+
 // RecordRequestCompletion implements [x.EmbeddingsMetrics].
 func (m *mockEmbeddingsMetrics) RecordRequestCompletion(_ context.Context, success bool, _ ...attribute.KeyValue) {
 	if success {
@@ -363,4 +387,7 @@ func (m *mockEmbeddingsMetrics) RequireTokensRecorded(t *testing.T, count int) {
 	require.Equal(t, count, m.tokenUsageCount)
 }
 
-var _ x.EmbeddingsMetrics = &mockEmbeddingsMetrics{}
+var (
+	_ x.EmbeddingsMetrics  = &mockEmbeddingsMetrics{}
+	_ x.RequestCostMetrics = &mockEmbeddingsMetrics{}
+)
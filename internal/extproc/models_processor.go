@@ -33,8 +33,8 @@ type modelsProcessor struct {
 
 var _ Processor = (*modelsProcessor)(nil)
 
-// NewModelsProcessor creates a new processor that returns the list of declared models.
-func NewModelsProcessor(config *processorConfig, _ map[string]string, logger *slog.Logger, isUpstreamFilter bool) (Processor, error) {
+// NewModelsProcessor creates a new processor that returns the list of declared models the caller may use.
+func NewModelsProcessor(config *processorConfig, requestHeaders map[string]string, logger *slog.Logger, isUpstreamFilter bool) (Processor, error) {
 	if isUpstreamFilter {
 		return passThroughProcessor{}, nil
 	}
@@ -43,6 +43,11 @@ func NewModelsProcessor(config *processorConfig, _ map[string]string, logger *sl
 		Data:   make([]openai.Model, 0, len(config.declaredModels)),
 	}
 	for _, m := range config.declaredModels {
+		// The models of the rules requiring the consumer key or the authorization are listed only if the caller
+		// may use them, i.e. the request to the model would not be rejected.
+		if _, resp := authorizeRequest(config, logger, requestHeaders, m.rule, m.name); resp != nil {
+			continue
+		}
 		models.Data = append(models.Data, openai.Model{
 			ID:      m.name,
 			Object:  "model",
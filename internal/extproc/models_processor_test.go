@@ -14,8 +14,10 @@ import (
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/google/cel-go/cel"
 	"github.com/stretchr/testify/require"
 
+	"github.com/envoyproxy/ai-gateway/filterapi"
 	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
 )
 
@@ -60,6 +62,50 @@ func TestModels_ProcessRequestHeaders(t *testing.T) {
 	}
 }
 
+func TestModels_authorization(t *testing.T) {
+	cfg := newConsumerKeyConfig()
+	cfg.rules = map[filterapi.RouteRuleName]*filterapi.RouteRule{
+		"open":  {Name: "open"},
+		"keyed": {Name: "keyed", RequireConsumerKey: true},
+		"teams": {Name: "teams", Authorization: &filterapi.Authorization{
+			Route: "teams", ClaimsHeader: "x-jwt-claims", CEL: `claims[?"team"].orValue("") == "a" && model != "o1"`,
+		}},
+	}
+	cfg.consumerKeys[hashConsumerKeyForTest("sk-keyed")] = &filterapi.ConsumerKey{Consumer: "keyed", RouteRules: []filterapi.RouteRuleName{"keyed"}}
+	cfg.authorizations = map[filterapi.RouteRuleName]cel.Program{"teams": mustNewAuthzProgram(t, cfg.rules["teams"].Authorization.CEL)}
+	cfg.declaredModels = []model{
+		{name: "llama", rule: "open"},
+		{name: "gpt-4o-mini", rule: "keyed"},
+		{name: "gpt-4o", rule: "teams"},
+		{name: "o1", rule: "teams"},
+	}
+
+	for _, tc := range []struct {
+		name      string
+		headers   map[string]string
+		expModels []string
+	}{
+		{name: "anonymous", headers: map[string]string{}, expModels: []string{"llama"}},
+		{name: "consumer key", headers: map[string]string{"authorization": "Bearer sk-keyed"}, expModels: []string{"llama", "gpt-4o-mini"}},
+		{name: "team a", headers: map[string]string{"x-jwt-claims": `{"team":"a"}`}, expModels: []string{"llama", "gpt-4o"}},
+		{name: "team b", headers: map[string]string{"x-jwt-claims": `{"team":"b"}`}, expModels: []string{"llama"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := NewModelsProcessor(cfg, tc.headers, slog.Default(), false)
+			require.NoError(t, err)
+			res, err := p.ProcessRequestHeaders(t.Context(), &corev3.HeaderMap{})
+			require.NoError(t, err)
+			var models openai.ModelList
+			require.NoError(t, json.Unmarshal(res.GetImmediateResponse().Body, &models))
+			var ids []string
+			for _, m := range models.Data {
+				ids = append(ids, m.ID)
+			}
+			require.Equal(t, tc.expModels, ids)
+		})
+	}
+}
+
 func TestModels_UnimplementedMethods(t *testing.T) {
 	p := &modelsProcessor{}
 	_, err := p.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{})
@@ -0,0 +1,339 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/envoyproxy/ai-gateway/internal/extproc/redaction"
+)
+
+// redactChatCompletionRequest redacts the PII in the text of the messages and the arguments of the tool calls
+// of the chat completion request, and returns the redacted body along with whether it has been modified.
+func redactChatCompletionRequest(s *redaction.Session, raw []byte) ([]byte, bool, error) {
+	var paths []string
+	gjson.GetBytes(raw, "messages").ForEach(func(i, m gjson.Result) bool {
+		prefix := "messages." + i.String()
+		if content := m.Get("content"); content.IsArray() {
+			content.ForEach(func(j, part gjson.Result) bool {
+				if part.Get("type").String() == "text" {
+					paths = append(paths, prefix+".content."+j.String()+".text")
+				}
+				return true
+			})
+		} else {
+			paths = append(paths, prefix+".content")
+		}
+		m.Get("tool_calls").ForEach(func(j, _ gjson.Result) bool {
+			paths = append(paths, prefix+".tool_calls."+j.String()+".function.arguments")
+			return true
+		})
+		return true
+	})
+	return redactJSONStrings(s, raw, paths)
+}
+
+// redactEmbeddingsRequest redacts the PII in the text inputs of the embeddings request, and returns the redacted
+// body along with whether it has been modified.
+func redactEmbeddingsRequest(s *redaction.Session, raw []byte) ([]byte, bool, error) {
+	var paths []string
+	if input := gjson.GetBytes(raw, "input"); input.IsArray() {
+		input.ForEach(func(i, _ gjson.Result) bool {
+			paths = append(paths, "input."+i.String())
+			return true
+		})
+	} else {
+		paths = append(paths, "input")
+	}
+	return redactJSONStrings(s, raw, paths)
+}
+
+// redactJSONStrings redacts the string values at the paths of the JSON body. The values of the other types are ignored.
+func redactJSONStrings(s *redaction.Session, raw []byte, paths []string) ([]byte, bool, error) {
+	var mutated bool
+	for _, path := range paths {
+		v := gjson.GetBytes(raw, path)
+		if v.Type != gjson.String {
+			continue
+		}
+		redacted, err := s.Redact(v.String())
+		if err != nil {
+			return nil, false, err
+		}
+		if redacted == v.String() {
+			continue
+		}
+		if raw, err = sjson.SetBytes(raw, path, redacted); err != nil {
+			return nil, false, fmt.Errorf("failed to redact %s: %w", path, err)
+		}
+		mutated = true
+	}
+	return raw, mutated, nil
+}
+
+// redactionRejectedResponse returns the immediate response to the request containing the PII to reject.
+func redactionRejectedResponse(err *redaction.RejectedError, param string) *extprocv3.ProcessingResponse {
+	return openAIErrorResponse(typev3.StatusCode_BadRequest, "invalid_request_error", "pii_detected", param,
+		fmt.Sprintf("The request was rejected because it contains the personally identifiable information of type %s.", err.Detector))
+}
+
+// redactChatCompletionResponseBody processes the completions of the chunk of the response body sent to the client
+// with the redaction session of the request, i.e. restores the tokenized PII and redacts the PII if configured.
+func (c *chatCompletionProcessorRouterFilter) redactChatCompletionResponseBody(resp *extprocv3.ProcessingResponse, body *extprocv3.HttpBody) error {
+	common := resp.GetResponseBody().GetResponse()
+	if common == nil {
+		return nil
+	}
+	raw := body.Body
+	if b := common.GetBodyMutation().GetBody(); b != nil {
+		raw = b
+	}
+
+	var err error
+	if c.originalRequestBody.Stream {
+		if c.completionStream == nil {
+			c.completionStream = &completionStreamRedactor{s: c.redaction, choices: map[int64]*choiceStreams{}}
+		}
+		if raw, err = c.completionStream.write(raw, body.EndOfStream); err != nil {
+			return err
+		}
+	} else {
+		var paths []string
+		gjson.GetBytes(raw, "choices").ForEach(func(i, choice gjson.Result) bool {
+			prefix := "choices." + i.String() + ".message"
+			paths = append(paths, prefix+".content")
+			choice.Get("message.tool_calls").ForEach(func(j, _ gjson.Result) bool {
+				paths = append(paths, prefix+".tool_calls."+j.String()+".function.arguments")
+				return true
+			})
+			return true
+		})
+		var mutated bool
+		for _, path := range paths {
+			v := gjson.GetBytes(raw, path)
+			if v.Type != gjson.String {
+				continue
+			}
+			if processed := c.redaction.Response(v.String()); processed != v.String() {
+				if raw, err = sjson.SetBytes(raw, path, processed); err != nil {
+					return fmt.Errorf("failed to redact %s: %w", path, err)
+				}
+				mutated = true
+			}
+		}
+		if !mutated {
+			return nil
+		}
+		if common.HeaderMutation == nil {
+			common.HeaderMutation = &extprocv3.HeaderMutation{}
+		}
+		common.HeaderMutation.SetHeaders = slices.DeleteFunc(common.HeaderMutation.SetHeaders, func(h *corev3.HeaderValueOption) bool {
+			return h.GetHeader().GetKey() == "content-length"
+		})
+		setHeader(common.HeaderMutation, "content-length", strconv.Itoa(len(raw)))
+	}
+	common.BodyMutation = &extprocv3.BodyMutation{Mutation: &extprocv3.BodyMutation_Body{Body: raw}}
+	return nil
+}
+
+// completionStreamRedactor processes the completions in the server-sent events of a streamed chat completion with
+// a [redaction.Stream] per the content and the tool call arguments of each choice. The events split across the
+// chunks of the response body are buffered until complete.
+type completionStreamRedactor struct {
+	s *redaction.Session
+	// buf is the incomplete event at the end of the last chunk.
+	buf     []byte
+	choices map[int64]*choiceStreams
+	// lastChunk is the data of the last chat completion chunk, which is used as the template of the chunk carrying
+	// the completions held back when the stream ends without the finish reason.
+	lastChunk []byte
+}
+
+type choiceStreams struct {
+	content       *redaction.Stream
+	toolArguments map[int64]*redaction.Stream
+}
+
+// write processes the chunk of the response body, and returns the events that can be sent to the client.
+func (r *completionStreamRedactor) write(chunk []byte, endOfStream bool) ([]byte, error) {
+	r.buf = append(r.buf, chunk...)
+	// This is never nil so that the empty body mutation clears the chunk held back.
+	out := []byte{}
+	for {
+		i := bytes.Index(r.buf, []byte("\n\n"))
+		if i < 0 {
+			break
+		}
+		event, err := r.processEvent(r.buf[:i+2])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, event...)
+		r.buf = r.buf[i+2:]
+	}
+	if endOfStream {
+		rest, err := r.flush()
+		if err != nil {
+			return nil, err
+		}
+		out = append(append(out, rest...), r.buf...)
+		r.buf = nil
+	}
+	return out, nil
+}
+
+// processEvent processes a single event including the trailing blank line.
+func (r *completionStreamRedactor) processEvent(event []byte) ([]byte, error) {
+	lines := bytes.Split(event, []byte("\n"))
+	for i, line := range lines {
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		if string(data) == "[DONE]" {
+			rest, err := r.flush()
+			return append(rest, event...), err
+		}
+		if !gjson.ValidBytes(data) || !gjson.GetBytes(data, "choices").IsArray() {
+			return event, nil
+		}
+		data, err := r.processChunk(bytes.Clone(data))
+		if err != nil {
+			return nil, err
+		}
+		lines[i] = append([]byte("data: "), data...)
+		return bytes.Join(lines, []byte("\n")), nil
+	}
+	return event, nil
+}
+
+// processChunk processes the completions in the data of a chat completion chunk.
+func (r *completionStreamRedactor) processChunk(data []byte) ([]byte, error) {
+	r.lastChunk = data
+	var err error
+	for i, choice := range gjson.GetBytes(data, "choices").Array() {
+		prefix := "choices." + strconv.Itoa(i)
+		index := choice.Get("index").Int()
+		cs := r.choices[index]
+		if cs == nil {
+			cs = &choiceStreams{content: r.s.NewStream(), toolArguments: map[int64]*redaction.Stream{}}
+			r.choices[index] = cs
+		}
+		if content := choice.Get("delta.content"); content.Type == gjson.String {
+			if data, err = sjson.SetBytes(data, prefix+".delta.content", cs.content.Write(content.String())); err != nil {
+				return nil, err
+			}
+		}
+		for j, tc := range choice.Get("delta.tool_calls").Array() {
+			args := tc.Get("function.arguments")
+			if args.Type != gjson.String {
+				continue
+			}
+			toolIndex := tc.Get("index").Int()
+			st := cs.toolArguments[toolIndex]
+			if st == nil {
+				st = r.s.NewStream()
+				cs.toolArguments[toolIndex] = st
+			}
+			path := prefix + ".delta.tool_calls." + strconv.Itoa(j) + ".function.arguments"
+			if data, err = sjson.SetBytes(data, path, st.Write(args.String())); err != nil {
+				return nil, err
+			}
+		}
+		if fr := choice.Get("finish_reason"); fr.Exists() && fr.Type != gjson.Null {
+			// The completions held back need to be sent before the choice finishes.
+			if data, err = r.appendHeldBack(data, prefix, index); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return data, nil
+}
+
+// flush returns the event carrying the completions held back of the choices not finished yet, if any.
+func (r *completionStreamRedactor) flush() ([]byte, error) {
+	if len(r.choices) == 0 || r.lastChunk == nil {
+		return nil, nil
+	}
+	data, err := sjson.SetBytes(r.lastChunk, "choices", []any{})
+	if err == nil {
+		data, err = sjson.DeleteBytes(data, "usage")
+	}
+	indexes := make([]int64, 0, len(r.choices))
+	for index := range r.choices {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+	for i, index := range indexes {
+		if err != nil {
+			break
+		}
+		data, err = sjson.SetRawBytes(data, "choices.-1", fmt.Appendf(nil, `{"index":%d,"delta":{}}`, index))
+		if err == nil {
+			data, err = r.appendHeldBack(data, "choices."+strconv.Itoa(i), index)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte("data: "), data...), "\n\n"...), nil
+}
+
+// appendHeldBack appends the completions held back of the choice with the index to the delta of the choice at the path.
+func (r *completionStreamRedactor) appendHeldBack(data []byte, prefix string, index int64) ([]byte, error) {
+	cs := r.choices[index]
+	if cs == nil {
+		return data, nil
+	}
+	delete(r.choices, index)
+	var err error
+	if rest := cs.content.Flush(); rest != "" {
+		path := prefix + ".delta.content"
+		if data, err = sjson.SetBytes(data, path, gjson.GetBytes(data, path).String()+rest); err != nil {
+			return nil, err
+		}
+	}
+	toolIndexes := make([]int64, 0, len(cs.toolArguments))
+	for toolIndex := range cs.toolArguments {
+		toolIndexes = append(toolIndexes, toolIndex)
+	}
+	slices.Sort(toolIndexes)
+	for _, toolIndex := range toolIndexes {
+		rest := cs.toolArguments[toolIndex].Flush()
+		if rest == "" {
+			continue
+		}
+		pos := -1
+		for j, tc := range gjson.GetBytes(data, prefix+".delta.tool_calls").Array() {
+			if tc.Get("index").Int() == toolIndex {
+				pos = j
+			}
+		}
+		if pos < 0 {
+			arguments, _ := json.Marshal(rest)
+			data, err = sjson.SetRawBytes(data, prefix+".delta.tool_calls.-1",
+				fmt.Appendf(nil, `{"index":%d,"function":{"arguments":%s}}`, toolIndex, arguments))
+		} else {
+			path := prefix + ".delta.tool_calls." + strconv.Itoa(pos) + ".function.arguments"
+			data, err = sjson.SetBytes(data, path, gjson.GetBytes(data, path).String()+rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,239 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/redaction"
+)
+
+func newTestRedactor(t *testing.T, responses bool) *redaction.Redactor {
+	r, err := redaction.New(&filterapi.Redaction{Responses: responses, Detectors: []filterapi.RedactionDetector{
+		{Name: "EMAIL", Builtin: filterapi.RedactionBuiltinDetectorEmail, Action: filterapi.RedactionActionTokenize},
+		{Name: "CARD", Builtin: filterapi.RedactionBuiltinDetectorCreditCard, Action: filterapi.RedactionActionReject},
+		{Name: "SSN", Builtin: filterapi.RedactionBuiltinDetectorUSSocialSecurityNumber, Action: filterapi.RedactionActionMask},
+	}})
+	require.NoError(t, err)
+	return r
+}
+
+func Test_redactChatCompletionRequest(t *testing.T) {
+	s := newTestRedactor(t, false).NewSession()
+	raw, mutated, err := redactChatCompletionRequest(s, []byte(`{"model":"m","messages":[`+
+		`{"role":"system","content":"SSN 123-45-6789"},`+
+		`{"role":"user","content":[{"type":"text","text":"mail a@example.com"},{"type":"image_url","image_url":{"url":"a@example.com"}}]},`+
+		`{"role":"assistant","content":null,"tool_calls":[{"id":"1","type":"function","function":{"name":"f","arguments":"{\"to\":\"a@example.com\"}"}}]}]}`))
+	require.NoError(t, err)
+	require.True(t, mutated)
+	require.JSONEq(t, `{"model":"m","messages":[`+
+		`{"role":"system","content":"SSN [SSN]"},`+
+		`{"role":"user","content":[{"type":"text","text":"mail [EMAIL_1]"},{"type":"image_url","image_url":{"url":"a@example.com"}}]},`+
+		`{"role":"assistant","content":null,"tool_calls":[{"id":"1","type":"function","function":{"name":"f","arguments":"{\"to\":\"[EMAIL_1]\"}"}}]}]}`, string(raw))
+
+	_, mutated, err = redactChatCompletionRequest(s, []byte(`{"messages":[{"role":"user","content":"hello"}]}`))
+	require.NoError(t, err)
+	require.False(t, mutated)
+
+	_, _, err = redactChatCompletionRequest(s, []byte(`{"messages":[{"role":"user","content":"4111 1111 1111 1111"}]}`))
+	require.ErrorAs(t, err, new(*redaction.RejectedError))
+}
+
+func Test_redactEmbeddingsRequest(t *testing.T) {
+	s := newTestRedactor(t, false).NewSession()
+	raw, mutated, err := redactEmbeddingsRequest(s, []byte(`{"model":"m","input":["a@example.com","hello"]}`))
+	require.NoError(t, err)
+	require.True(t, mutated)
+	require.JSONEq(t, `{"model":"m","input":["[EMAIL_1]","hello"]}`, string(raw))
+
+	raw, mutated, err = redactEmbeddingsRequest(s, []byte(`{"model":"m","input":"SSN 123-45-6789"}`))
+	require.NoError(t, err)
+	require.True(t, mutated)
+	require.JSONEq(t, `{"model":"m","input":"SSN [SSN]"}`, string(raw))
+
+	_, mutated, err = redactEmbeddingsRequest(s, []byte(`{"model":"m","input":[1,2,3]}`))
+	require.NoError(t, err)
+	require.False(t, mutated)
+}
+
+func Test_completionStreamRedactor(t *testing.T) {
+	newStream := func(t *testing.T, responses bool) *completionStreamRedactor {
+		s := newTestRedactor(t, responses).NewSession()
+		_, err := s.Redact("a@example.com b@example.com")
+		require.NoError(t, err)
+		return &completionStreamRedactor{s: s, choices: map[int64]*choiceStreams{}}
+	}
+	// write writes the body to the stream in the chunks of the given size, and returns the concatenated output.
+	write := func(t *testing.T, r *completionStreamRedactor, body string, size int) string {
+		var out strings.Builder
+		for i := 0; i < len(body); i += size {
+			end := min(i+size, len(body))
+			b, err := r.write([]byte(body[i:end]), end == len(body))
+			require.NoError(t, err)
+			out.Write(b)
+		}
+		return out.String()
+	}
+
+	t.Run("finish reason", func(t *testing.T) {
+		const body = "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hi [EMA\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"IL_1] and [EMAIL_2\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"]\"},\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: [DONE]\n\n"
+		for size := 1; size <= len(body); size++ {
+			require.Equal(t, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hi \"}}]}\n\n"+
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"a@example.com and \"}}]}\n\n"+
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"b@example.com\"},\"finish_reason\":\"stop\"}]}\n\n"+
+				"data: [DONE]\n\n", write(t, newStream(t, false), body, size), size)
+		}
+	})
+
+	t.Run("tool calls", func(t *testing.T) {
+		const body = "data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"1\",\"function\":{\"name\":\"f\",\"arguments\":\"{\\\"to\\\":\\\"[EMAIL\"}}]}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"tool_calls\"}]}\n\n"
+		require.Equal(t, "data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"1\",\"function\":{\"name\":\"f\",\"arguments\":\"{\\\"to\\\":\\\"\"}}]}}]}\n\n"+
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"[EMAIL\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\n",
+			write(t, newStream(t, false), body, len(body)))
+	})
+
+	t.Run("no finish reason", func(t *testing.T) {
+		const body = "data: {\"id\":\"x\",\"choices\":[{\"index\":1,\"delta\":{\"content\":\"[EMAIL_1\"}}],\"usage\":{\"total_tokens\":1}}\n\n" +
+			"data: [DONE]\n\n"
+		require.Equal(t, "data: {\"id\":\"x\",\"choices\":[{\"index\":1,\"delta\":{\"content\":\"\"}}],\"usage\":{\"total_tokens\":1}}\n\n"+
+			"data: {\"id\":\"x\",\"choices\":[{\"index\":1,\"delta\":{\"content\":\"[EMAIL_1\"}}]}\n\n"+
+			"data: [DONE]\n\n", write(t, newStream(t, false), body, len(body)))
+	})
+
+	t.Run("responses", func(t *testing.T) {
+		const body = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"SSN 123-4\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"5-6789 of [EMAIL_1]\"}}]}\n\n" +
+			": comment\n\n"
+		require.Equal(t, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"\"}}]}\n\n"+
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"\"}}]}\n\n"+
+			": comment\n\n"+
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"SSN [SSN] of a@example.com\"}}]}\n\n",
+			write(t, newStream(t, true), body, len(body)))
+	})
+}
+
+func TestChatCompletion_redaction(t *testing.T) {
+	config := &processorConfig{
+		modelNameHeaderKey:     "x-model-name",
+		selectedRouteHeaderKey: "x-route",
+		rules:                  map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": {Name: "some-route"}},
+		redactors:              map[filterapi.RouteRuleName]*redaction.Redactor{"some-route": newTestRedactor(t, false)},
+	}
+
+	// send sends the request through the router filter, and returns the request body sent upstream along with the
+	// response body sent to the client.
+	send := func(t *testing.T, reqBody, respBody string, chunkSize int) (upstreamReq, clientResp string) {
+		headers := map[string]string{":path": "/v1/chat/completions"}
+		config.router = mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		rp := &chatCompletionProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default()}
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(reqBody)})
+		require.NoError(t, err)
+		require.Nil(t, resp.GetImmediateResponse())
+		mutation := resp.GetRequestBody().GetResponse()
+		require.Equal(t, []string{"accept-encoding"}, mutation.GetHeaderMutation().GetRemoveHeaders())
+		upstreamReq = string(mutation.GetBodyMutation().GetBody())
+
+		uf := &chatCompletionProcessorUpstreamFilter{
+			config:         config,
+			requestHeaders: map[string]string{":path": "/v1/chat/completions"},
+			logger:         slog.Default(),
+			metrics:        &mockChatCompletionMetrics{},
+		}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, rp))
+		_, err = uf.ProcessRequestHeaders(t.Context(), nil)
+		require.NoError(t, err)
+		_, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "200"}}})
+		require.NoError(t, err)
+		var out strings.Builder
+		for i := 0; i < len(respBody); i += chunkSize {
+			end := min(i+chunkSize, len(respBody))
+			resp, err := rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{Body: []byte(respBody[i:end]), EndOfStream: end == len(respBody)})
+			require.NoError(t, err)
+			if b := resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody(); b != nil {
+				out.Write(b)
+			} else {
+				out.WriteString(respBody[i:end])
+			}
+			if !rp.originalRequestBody.Stream {
+				require.Equal(t, []string{"content-length"}, func() (keys []string) {
+					for _, h := range resp.GetResponseBody().GetResponse().GetHeaderMutation().GetSetHeaders() {
+						keys = append(keys, h.Header.Key)
+					}
+					return
+				}())
+			}
+		}
+		return upstreamReq, out.String()
+	}
+
+	t.Run("json", func(t *testing.T) {
+		const respBody = `{"choices":[{"index":0,"message":{"role":"assistant","content":"Sent to [EMAIL_1]."}}]}`
+		upstreamReq, clientResp := send(t, `{"model":"m","messages":[{"role":"user","content":"Mail a@example.com"}]}`, respBody, len(respBody))
+		require.JSONEq(t, `{"model":"m","messages":[{"role":"user","content":"Mail [EMAIL_1]"}]}`, upstreamReq)
+		require.JSONEq(t, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Sent to a@example.com."}}]}`, clientResp)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		const respBody = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Sent to [EMAIL_1]\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\".\"},\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: [DONE]\n\n"
+		for _, size := range []int{1, 7, 40, len(respBody)} {
+			upstreamReq, clientResp := send(t, `{"model":"m","stream":true,"messages":[{"role":"user","content":"Mail a@example.com"}]}`, respBody, size)
+			require.JSONEq(t, `{"model":"m","stream":true,"messages":[{"role":"user","content":"Mail [EMAIL_1]"}]}`, upstreamReq)
+			require.Equal(t, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Sent to a@example.com\"}}]}\n\n"+
+				"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\".\"},\"finish_reason\":\"stop\"}]}\n\n"+
+				"data: [DONE]\n\n", clientResp, size)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		headers := map[string]string{":path": "/v1/chat/completions"}
+		config.router = mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		rp := &chatCompletionProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default()}
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{
+			Body: []byte(`{"model":"m","messages":[{"role":"user","content":"Pay with 4111 1111 1111 1111"}]}`),
+		})
+		require.NoError(t, err)
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_BadRequest, ir.GetStatus().GetCode())
+		require.JSONEq(t, `{"type":"error","error":{"type":"invalid_request_error","code":"pii_detected","param":"messages",`+
+			`"message":"The request was rejected because it contains the personally identifiable information of type CARD."}}`, string(ir.GetBody()))
+	})
+}
+
+func TestEmbeddings_redaction(t *testing.T) {
+	headers := map[string]string{":path": "/v1/embeddings"}
+	config := &processorConfig{
+		modelNameHeaderKey:     "x-model-name",
+		selectedRouteHeaderKey: "x-route",
+		router:                 mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+		redactors:              map[filterapi.RouteRuleName]*redaction.Redactor{"some-route": newTestRedactor(t, false)},
+	}
+	rp := &embeddingsProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default()}
+	resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(`{"model":"m","input":["a@example.com"]}`)})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"model":"m","input":["[EMAIL_1]"]}`, string(resp.GetRequestBody().GetResponse().GetBodyMutation().GetBody()))
+	require.Equal(t, []string{"[EMAIL_1]"}, rp.originalRequestBody.Input.Value)
+
+	resp, err = rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(`{"model":"m","input":"4111 1111 1111 1111"}`)})
+	require.NoError(t, err)
+	require.Equal(t, typev3.StatusCode_BadRequest, resp.GetImmediateResponse().GetStatus().GetCode())
+}
@@ -0,0 +1,39 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+)
+
+// modelPricingWildcard is the model name of the pricing that matches all the models not listed explicitly.
+const modelPricingWildcard = "*"
+
+// findModelPricing returns the pricing of the model in the price list of a backend, or nil if the model is not priced.
+func findModelPricing(pricing []filterapi.ModelPricing, model string) *filterapi.ModelPricing {
+	var wildcard *filterapi.ModelPricing
+	for i := range pricing {
+		switch pricing[i].Model {
+		case model:
+			return &pricing[i]
+		case modelPricingWildcard:
+			wildcard = &pricing[i]
+		}
+	}
+	return wildcard
+}
+
+// requestCostMicroUSD returns the cost of the token usage in micro US dollars.
+//
+// The prices are per million tokens in US dollars, which is the same as micro US dollars per token.
+// The cached input tokens are included in the input tokens, so they are charged with the cached price instead.
+func requestCostMicroUSD(p *filterapi.ModelPricing, usage *translator.LLMTokenUsage) float64 {
+	cached := min(usage.CachedInputTokens, usage.InputTokens)
+	return float64(usage.InputTokens-cached)*p.InputPerMillionTokens +
+		float64(cached)*p.CachedInputPerMillionTokens +
+		float64(usage.OutputTokens)*p.OutputPerMillionTokens
+}
@@ -0,0 +1,44 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+)
+
+func Test_findModelPricing(t *testing.T) {
+	pricing := []filterapi.ModelPricing{
+		{Model: "*", InputPerMillionTokens: 1},
+		{Model: "gpt-4o", InputPerMillionTokens: 2.5},
+	}
+	t.Run("exact", func(t *testing.T) {
+		require.Equal(t, &pricing[1], findModelPricing(pricing, "gpt-4o"))
+	})
+	t.Run("wildcard", func(t *testing.T) {
+		require.Equal(t, &pricing[0], findModelPricing(pricing, "gpt-4o-mini"))
+	})
+	t.Run("not found", func(t *testing.T) {
+		require.Nil(t, findModelPricing(pricing[1:], "gpt-4o-mini"))
+		require.Nil(t, findModelPricing(nil, "gpt-4o"))
+	})
+}
+
+func Test_requestCostMicroUSD(t *testing.T) {
+	p := &filterapi.ModelPricing{InputPerMillionTokens: 2.5, OutputPerMillionTokens: 10}
+	cost := requestCostMicroUSD(p, &translator.LLMTokenUsage{InputTokens: 1000, OutputTokens: 200, TotalTokens: 1200})
+	// 1000 * $2.5/1M + 200 * $10/1M = $0.0045.
+	require.InDelta(t, 4500, cost, 1e-9)
+
+	p.CachedInputPerMillionTokens = 1.25
+	cost = requestCostMicroUSD(p, &translator.LLMTokenUsage{InputTokens: 1000, CachedInputTokens: 800, OutputTokens: 200, TotalTokens: 1200})
+	// 200 * $2.5/1M + 800 * $1.25/1M + 200 * $10/1M = $0.0035.
+	require.InDelta(t, 3500, cost, 1e-9)
+}
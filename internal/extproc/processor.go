@@ -7,16 +7,27 @@ package extproc
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
 	"time"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
 	"github.com/google/cel-go/cel"
 
 	"github.com/envoyproxy/ai-gateway/filterapi"
 	"github.com/envoyproxy/ai-gateway/filterapi/x"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/audit"
 	"github.com/envoyproxy/ai-gateway/internal/extproc/backendauth"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/ledger"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/quota"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/redaction"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
 )
 
 type model struct {
@@ -26,6 +37,8 @@ type model struct {
 	ownedBy string
 	// createdAt will be exported as the field of "Created" in OpenAI-compatible API "/models".
 	createdAt time.Time
+	// rule is the name of the rule declaring the model, which is used to filter the models the caller may use.
+	rule filterapi.RouteRuleName
 }
 
 // processorConfig is the configuration for the processor.
@@ -35,10 +48,31 @@ type processorConfig struct {
 	schema                                     filterapi.VersionedAPISchema
 	router                                     x.Router
 	modelNameHeaderKey, selectedRouteHeaderKey string
-	metadataNamespace                          string
-	requestCosts                               []processorConfigRequestCost
-	declaredModels                             []model
-	backends                                   map[string]*processorConfigBackend
+	// sessionKeyHeaderKey is the header key populated with the hash of the session key. See [sessionKeyHash].
+	sessionKeyHeaderKey string
+	metadataNamespace   string
+	requestCosts        []processorConfigRequestCost
+	declaredModels      []model
+	backends            map[string]*processorConfigBackend
+	// rules maps the route rule name to the rule so that per-rule configuration can be looked up
+	// after the routing decision is made.
+	rules map[filterapi.RouteRuleName]*filterapi.RouteRule
+	// redactors maps the route rule name to the PII redactor of the rule, if any.
+	redactors map[filterapi.RouteRuleName]*redaction.Redactor
+	// denyPatterns maps the route rule name to the compiled deny patterns of the stream moderation of the rule, if any.
+	denyPatterns map[filterapi.RouteRuleName][]*regexp.Regexp
+	// consumerHeaderKey is the header key populated with the consumer authenticated with the consumer key.
+	consumerHeaderKey string
+	// consumerKeys maps the hash of the consumer key to the key. See [authenticateConsumer].
+	consumerKeys map[string]*filterapi.ConsumerKey
+	// authorizations maps the route rule name to the compiled CEL program of the authorization of the rule, if any.
+	authorizations map[filterapi.RouteRuleName]cel.Program
+	// shadowClients maps the route rule name to the HTTP client of the shadow backend of the rule, if any.
+	shadowClients map[filterapi.RouteRuleName]*http.Client
+	// guardrailClients maps the name of a moderation backend to its HTTP client.
+	guardrailClients map[string]*http.Client
+	// semanticCacheClients maps the name of an embeddings backend of the semantic caches to its HTTP client.
+	semanticCacheClients map[string]*http.Client
 }
 
 type processorConfigBackend struct {
@@ -50,6 +84,28 @@ type processorConfigBackend struct {
 type processorConfigRequestCost struct {
 	*filterapi.LLMRequestCost
 	celProg cel.Program
+	// celOnRequest is true if the CEL expression uses the estimated input tokens, in which case it is evaluated
+	// on the request path as well.
+	celOnRequest bool
+}
+
+// ProcessorOptions are the optional dependencies shared by the processors across the requests.
+// A nil field disables the corresponding feature.
+type ProcessorOptions struct {
+	// ShadowMetrics is the metrics of the shadow traffic mirroring.
+	ShadowMetrics *metrics.Shadow
+	// CircuitBreakers is the circuit breakers of the backends.
+	CircuitBreakers *CircuitBreakers
+	// Quotas is the token quotas enforced by the processors.
+	Quotas *quota.Quotas
+	// UsageLedger is the usage ledger recording the usage of each request.
+	UsageLedger *ledger.Ledger
+	// ResponseCache is the response cache, which also holds the embeddings of the inputs.
+	ResponseCache *ResponseCache
+	// GuardrailMetrics is the metrics of the guardrails.
+	GuardrailMetrics *metrics.Guardrail
+	// AuditLogger is the audit log recording the prompts and the completions.
+	AuditLogger *audit.Logger
 }
 
 // ProcessorFactory is the factory function used to create new instances of a processor.
@@ -76,6 +132,13 @@ type Processor interface {
 	SetBackend(ctx context.Context, backend *filterapi.Backend, handler backendauth.Handler, routerProcessor Processor) error
 }
 
+// streamCloser is optionally implemented by a [Processor] holding the resources of the request that must be released
+// when the stream of the request ends, including when the client resets the stream before the end of the response.
+type streamCloser interface {
+	// closeStream is called once after the last message of the stream is processed.
+	closeStream()
+}
+
 // passThroughProcessor implements the Processor interface.
 type passThroughProcessor struct{}
 
@@ -103,3 +166,34 @@ func (p passThroughProcessor) ProcessResponseBody(context.Context, *extprocv3.Ht
 func (p passThroughProcessor) SetBackend(context.Context, *filterapi.Backend, backendauth.Handler, Processor) error {
 	return nil
 }
+
+// openAIErrorResponse returns an immediate response whose body is the OpenAI-compatible error with the given details.
+// This is used when the request is rejected by the filter itself without calling the upstream.
+func openAIErrorResponse(status typev3.StatusCode, errType, errCode, param, message string) *extprocv3.ProcessingResponse {
+	openAIErr := openai.Error{
+		Type: "error",
+		Error: openai.ErrorType{
+			Type:    errType,
+			Message: message,
+		},
+	}
+	if errCode != "" {
+		openAIErr.Error.Code = &errCode
+	}
+	if param != "" {
+		openAIErr.Error.Param = &param
+	}
+	body, _ := json.Marshal(openAIErr)
+	headerMutation := &extprocv3.HeaderMutation{}
+	setHeader(headerMutation, "content-type", "application/json")
+	setHeader(headerMutation, "content-length", strconv.Itoa(len(body)))
+	return &extprocv3.ProcessingResponse{
+		Response: &extprocv3.ProcessingResponse_ImmediateResponse{
+			ImmediateResponse: &extprocv3.ImmediateResponse{
+				Status:  &typev3.HttpStatus{Code: status},
+				Headers: headerMutation,
+				Body:    body,
+			},
+		},
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryStore implements [Store] in the memory of the process.
+type memoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	// nextSweep is the time when the expired entries are removed next time.
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	tokens    int64
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates a new [Store] that keeps the usage in memory. The usage is not shared
+// across the processes, so each replica of the external processor enforces the quotas on its own.
+func NewMemoryStore() Store {
+	return &memoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+// Get implements [Store.Get].
+func (m *memoryStore) Get(_ context.Context, keys ...string) ([]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	ret := make([]int64, len(keys))
+	for i, k := range keys {
+		if e, ok := m.entries[k]; ok && now.Before(e.expiresAt) {
+			ret[i] = e.tokens
+		}
+	}
+	return ret, nil
+}
+
+// Add implements [Store.Add].
+func (m *memoryStore) Add(_ context.Context, key string, tokens int64, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.After(m.nextSweep) {
+		for k, e := range m.entries {
+			if !now.Before(e.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+		m.nextSweep = now.Add(time.Minute)
+	}
+	e, ok := m.entries[key]
+	if !ok || !now.Before(e.expiresAt) {
+		e = memoryEntry{expiresAt: now.Add(ttl)}
+	}
+	e.tokens += tokens
+	m.entries[key] = e
+	return nil
+}
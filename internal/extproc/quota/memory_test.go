@@ -0,0 +1,40 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := NewMemoryStore().(*memoryStore)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.Add(t.Context(), "a", 10, time.Minute))
+	require.NoError(t, s.Add(t.Context(), "a", 5, time.Hour))
+	require.NoError(t, s.Add(t.Context(), "b", 1, 2*time.Minute))
+	values, err := s.Get(t.Context(), "a", "b", "c")
+	require.NoError(t, err)
+	require.Equal(t, []int64{15, 1, 0}, values)
+
+	// The expiry is fixed at the creation of the key.
+	now = now.Add(time.Minute)
+	values, err = s.Get(t.Context(), "a", "b")
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 1}, values)
+
+	// The expired keys are swept on the update.
+	now = now.Add(time.Minute)
+	require.NoError(t, s.Add(t.Context(), "a", 3, time.Minute))
+	require.Len(t, s.entries, 1)
+	values, err = s.Get(t.Context(), "a")
+	require.NoError(t, err)
+	require.Equal(t, []int64{3}, values)
+}
@@ -0,0 +1,136 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+// Package quota implements the per-consumer token quotas enforced by the external processor.
+// See [filterapi.TokenQuota].
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+)
+
+// Store is the storage of the token usage of the consumers per time window.
+//
+// The implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the token usage of each of the given keys, where the missing keys are zero.
+	Get(ctx context.Context, keys ...string) ([]int64, error)
+	// Add adds the tokens to the usage of the key. The key is removed after the ttl elapses.
+	Add(ctx context.Context, key string, tokens int64, ttl time.Duration) error
+}
+
+// Window is the time window of a token budget.
+type Window string
+
+const (
+	// WindowMinute is the window of [filterapi.TokenQuota.TokensPerMinute].
+	WindowMinute Window = "minute"
+	// WindowDay is the window of [filterapi.TokenQuota.TokensPerDay].
+	WindowDay Window = "day"
+)
+
+// Exceeded describes the budget of a quota that the consumer has exhausted.
+type Exceeded struct {
+	// Window is the window of the exhausted budget.
+	Window Window
+	// Limit is the budget of the window.
+	Limit int64
+	// Used is the number of tokens used in the current window.
+	Used int64
+	// ResetAfter is the time until the current window ends.
+	ResetAfter time.Duration
+}
+
+// Quotas enforces the token quotas with the usage kept in the [Store].
+//
+// The budgets are counted in fixed windows aligned to the minute and to the day in UTC.
+type Quotas struct {
+	store Store
+	now   func() time.Time
+}
+
+// New creates a new Quotas backed by the given store.
+func New(store Store) *Quotas {
+	return &Quotas{store: store, now: time.Now}
+}
+
+// budget is a budget of a quota in the current window.
+type budget struct {
+	window Window
+	limit  int64
+	key    string
+	// end is the end of the current window.
+	end time.Time
+}
+
+// budgets returns the configured budgets of the quota for the consumer in the current windows.
+func (q *Quotas) budgets(quota *filterapi.TokenQuota, consumer string) []budget {
+	now := q.now().UTC()
+	var ret []budget
+	if quota.TokensPerMinute > 0 {
+		start := now.Truncate(time.Minute)
+		ret = append(ret, budget{
+			window: WindowMinute, limit: quota.TokensPerMinute,
+			key: key(quota, consumer, WindowMinute, start), end: start.Add(time.Minute),
+		})
+	}
+	if quota.TokensPerDay > 0 {
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		ret = append(ret, budget{
+			window: WindowDay, limit: quota.TokensPerDay,
+			key: key(quota, consumer, WindowDay, start), end: start.AddDate(0, 0, 1),
+		})
+	}
+	return ret
+}
+
+// key returns the key of the usage of the consumer in the window starting at start.
+func key(quota *filterapi.TokenQuota, consumer string, w Window, start time.Time) string {
+	return fmt.Sprintf("aigw:quota:%s:%s:%s:%d", quota.Name, consumer, w, start.Unix())
+}
+
+// Check returns the first budget of the quota that the consumer has exhausted, or nil if the consumer
+// can still make requests.
+func (q *Quotas) Check(ctx context.Context, quota *filterapi.TokenQuota, consumer string) (*Exceeded, error) {
+	budgets := q.budgets(quota, consumer)
+	if len(budgets) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(budgets))
+	for i := range budgets {
+		keys[i] = budgets[i].key
+	}
+	used, err := q.store.Get(ctx, keys...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the token usage of quota %s: %w", quota.Name, err)
+	}
+	now := q.now()
+	for i, b := range budgets {
+		if used[i] >= b.limit {
+			return &Exceeded{Window: b.window, Limit: b.limit, Used: used[i], ResetAfter: b.end.Sub(now)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// Deduct deducts the tokens used by the consumer from the budgets of the quota.
+func (q *Quotas) Deduct(ctx context.Context, quota *filterapi.TokenQuota, consumer string, tokens int64) error {
+	if tokens <= 0 {
+		return nil
+	}
+	now := q.now()
+	for _, b := range q.budgets(quota, consumer) {
+		// Keep the usage a little longer than the window so that the clock skew across the processors
+		// sharing the store does not lose the usage.
+		if err := q.store.Add(ctx, b.key, tokens, b.end.Sub(now)+time.Minute); err != nil {
+			return fmt.Errorf("failed to deduct the token usage of quota %s: %w", quota.Name, err)
+		}
+	}
+	return nil
+}
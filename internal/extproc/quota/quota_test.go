@@ -0,0 +1,105 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+)
+
+func TestQuotas(t *testing.T) {
+	now := time.Date(2025, 1, 1, 23, 59, 30, 0, time.UTC)
+	q := New(NewMemoryStore())
+	q.now = func() time.Time { return now }
+	q.store.(*memoryStore).now = q.now
+	quota := &filterapi.TokenQuota{Name: "ns/route/team", TokensPerMinute: 100, TokensPerDay: 150}
+
+	t.Run("under budget", func(t *testing.T) {
+		exceeded, err := q.Check(t.Context(), quota, "team-a")
+		require.NoError(t, err)
+		require.Nil(t, exceeded)
+		require.NoError(t, q.Deduct(t.Context(), quota, "team-a", 99))
+		require.NoError(t, q.Deduct(t.Context(), quota, "team-a", 0))
+		exceeded, err = q.Check(t.Context(), quota, "team-a")
+		require.NoError(t, err)
+		require.Nil(t, exceeded)
+	})
+
+	t.Run("minute budget exhausted", func(t *testing.T) {
+		require.NoError(t, q.Deduct(t.Context(), quota, "team-a", 2))
+		exceeded, err := q.Check(t.Context(), quota, "team-a")
+		require.NoError(t, err)
+		require.Equal(t, &Exceeded{Window: WindowMinute, Limit: 100, Used: 101, ResetAfter: 30 * time.Second}, exceeded)
+
+		// Other consumers are not affected.
+		exceeded, err = q.Check(t.Context(), quota, "team-b")
+		require.NoError(t, err)
+		require.Nil(t, exceeded)
+	})
+
+	t.Run("window rollover", func(t *testing.T) {
+		now = now.Add(20 * time.Second)
+		exceeded, err := q.Check(t.Context(), quota, "team-a")
+		require.NoError(t, err)
+		require.Equal(t, WindowMinute, exceeded.Window)
+
+		// Both the minute and the day budgets are reset at midnight UTC.
+		now = now.Add(10 * time.Second)
+		exceeded, err = q.Check(t.Context(), quota, "team-a")
+		require.NoError(t, err)
+		require.Nil(t, exceeded)
+		require.NoError(t, q.Deduct(t.Context(), quota, "team-a", 50))
+		exceeded, err = q.Check(t.Context(), quota, "team-a")
+		require.NoError(t, err)
+		require.Nil(t, exceeded)
+	})
+
+	t.Run("no budget", func(t *testing.T) {
+		exceeded, err := q.Check(t.Context(), &filterapi.TokenQuota{Name: "unlimited"}, "team-a")
+		require.NoError(t, err)
+		require.Nil(t, exceeded)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		q := New(errorStore{})
+		_, err := q.Check(t.Context(), quota, "team-a")
+		require.ErrorContains(t, err, "failed to get the token usage of quota ns/route/team: store is down")
+		err = q.Deduct(t.Context(), quota, "team-a", 1)
+		require.ErrorContains(t, err, "failed to deduct the token usage of quota ns/route/team: store is down")
+	})
+}
+
+func TestQuotas_dayBudget(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	q := New(NewMemoryStore())
+	q.now = func() time.Time { return now }
+	quota := &filterapi.TokenQuota{Name: "q", TokensPerMinute: 100, TokensPerDay: 150}
+
+	require.NoError(t, q.Deduct(t.Context(), quota, "c", 90))
+	now = now.Add(time.Minute)
+	require.NoError(t, q.Deduct(t.Context(), quota, "c", 90))
+	now = now.Add(time.Minute)
+	exceeded, err := q.Check(t.Context(), quota, "c")
+	require.NoError(t, err)
+	require.Equal(t, &Exceeded{Window: WindowDay, Limit: 150, Used: 180, ResetAfter: 12*time.Hour - 2*time.Minute}, exceeded)
+}
+
+// errorStore is a [Store] that always fails.
+type errorStore struct{}
+
+func (errorStore) Get(context.Context, ...string) ([]int64, error) {
+	return nil, errors.New("store is down")
+}
+
+func (errorStore) Add(context.Context, string, int64, time.Duration) error {
+	return errors.New("store is down")
+}
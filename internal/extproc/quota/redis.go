@@ -0,0 +1,60 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisStore implements [Store] with a Redis-compatible store shared by the external processors.
+type redisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore creates a new [Store] backed by the Redis-compatible server at the given URL,
+// e.g. "redis://localhost:6379/0". See [redis.ParseURL] for the format.
+func NewRedisStore(url string) (Store, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	return &redisStore{client: redis.NewClient(opts)}, nil
+}
+
+// Get implements [Store.Get].
+func (r *redisStore) Get(ctx context.Context, keys ...string) ([]int64, error) {
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]int64, len(keys))
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			continue // Missing key.
+		}
+		if ret[i], err = strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid token usage %q of key %s: %w", s, keys[i], err)
+		}
+	}
+	return ret, nil
+}
+
+// Add implements [Store.Add].
+func (r *redisStore) Add(ctx context.Context, key string, tokens int64, ttl time.Duration) error {
+	_, err := r.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.IncrBy(ctx, key, tokens)
+		// The key contains the start of the window, so refreshing the expiry on each update is harmless.
+		p.Expire(ctx, key, ttl)
+		return nil
+	})
+	return err
+}
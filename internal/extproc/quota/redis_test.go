@@ -0,0 +1,51 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package quota
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStore(t *testing.T) {
+	t.Run("invalid url", func(t *testing.T) {
+		_, err := NewRedisStore("http://localhost")
+		require.ErrorContains(t, err, "invalid redis URL")
+	})
+
+	mr := miniredis.RunT(t)
+	s, err := NewRedisStore("redis://" + mr.Addr() + "/0")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Add(t.Context(), "a", 10, time.Minute))
+	require.NoError(t, s.Add(t.Context(), "a", 5, time.Minute))
+	require.NoError(t, s.Add(t.Context(), "b", 1, time.Hour))
+	values, err := s.Get(t.Context(), "a", "b", "c")
+	require.NoError(t, err)
+	require.Equal(t, []int64{15, 1, 0}, values)
+	require.Equal(t, time.Minute, mr.TTL("a"))
+
+	mr.FastForward(time.Minute)
+	values, err = s.Get(t.Context(), "a", "b")
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 1}, values)
+
+	t.Run("invalid value", func(t *testing.T) {
+		require.NoError(t, mr.Set("c", "foo"))
+		_, err := s.Get(t.Context(), "c")
+		require.ErrorContains(t, err, `invalid token usage "foo" of key c`)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		mr.Close()
+		_, err := s.Get(t.Context(), "a")
+		require.Error(t, err)
+		require.Error(t, s.Add(t.Context(), "a", 1, time.Minute))
+	})
+}
@@ -0,0 +1,329 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+// Package redaction implements the detection and the redaction of the personally identifiable information (PII)
+// in the requests and the completions configured by [filterapi.Redaction].
+package redaction
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+)
+
+// builtinPatterns is the patterns of the builtin detectors.
+var builtinPatterns = map[filterapi.RedactionBuiltinDetector]string{
+	filterapi.RedactionBuiltinDetectorEmail: `[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`,
+	// The optional country code and area code followed by the subscriber number ending with 4 digits,
+	// e.g. "+1 (555) 123-4567", "+44 20 7946 0958" or "555.123.4567".
+	filterapi.RedactionBuiltinDetectorPhoneNumber: `(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?|\b\d{2,4}[ .-]?|\b)\d{3,4}[ .-]?\d{4}\b`,
+	// 13 to 19 digits optionally separated by spaces or hyphens.
+	filterapi.RedactionBuiltinDetectorCreditCard:             `\b\d(?:[ -]?\d){12,18}\b`,
+	filterapi.RedactionBuiltinDetectorUSSocialSecurityNumber: `\b\d{3}-\d{2}-\d{4}\b`,
+	filterapi.RedactionBuiltinDetectorIBAN:                   `\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`,
+}
+
+// builtinValidators is the validators of the matches of the builtin detectors.
+var builtinValidators = map[filterapi.RedactionBuiltinDetector]func(string) bool{
+	filterapi.RedactionBuiltinDetectorPhoneNumber:            validPhoneNumber,
+	filterapi.RedactionBuiltinDetectorCreditCard:             validLuhn,
+	filterapi.RedactionBuiltinDetectorUSSocialSecurityNumber: validUSSocialSecurityNumber,
+	filterapi.RedactionBuiltinDetectorIBAN:                   validIBAN,
+}
+
+// placeholderPattern matches the placeholders of the tokenized PII, e.g. "[EMAIL_1]".
+var placeholderPattern = regexp.MustCompile(`\[[A-Z][A-Z0-9_]*_\d+\]`)
+
+// Redactor redacts the PII with the detectors of a [filterapi.Redaction]. This is safe for concurrent use.
+type Redactor struct {
+	detectors []detector
+	responses bool
+	// hashKey is the key of the HMAC of the Hash action.
+	hashKey []byte
+}
+
+type detector struct {
+	name     string
+	re       *regexp.Regexp
+	validate func(string) bool
+	action   filterapi.RedactionAction
+}
+
+// New creates a new Redactor with the configuration.
+func New(cfg *filterapi.Redaction) (*Redactor, error) {
+	r := &Redactor{responses: cfg.Responses, hashKey: []byte(cfg.HashKey)}
+	for _, d := range cfg.Detectors {
+		if d.Action == filterapi.RedactionActionHash && cfg.HashKey == "" {
+			return nil, fmt.Errorf("hash key is required for the Hash action of detector %s", d.Name)
+		}
+		pattern, validate := d.Pattern, validatorFuncs[d.Validator]
+		if d.Builtin != "" {
+			var ok bool
+			if pattern, ok = builtinPatterns[d.Builtin]; !ok {
+				return nil, fmt.Errorf("unknown builtin detector %q of detector %s", d.Builtin, d.Name)
+			}
+			validate = builtinValidators[d.Builtin]
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of detector %s: %w", d.Name, err)
+		}
+		r.detectors = append(r.detectors, detector{name: d.Name, re: re, validate: validate, action: d.Action})
+	}
+	return r, nil
+}
+
+// RejectedError is returned when the PII detected by a detector with the Reject action is found.
+type RejectedError struct {
+	// Detector is the name of the detector.
+	Detector string
+}
+
+// Error implements [error.Error].
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("the request contains %s", e.Detector)
+}
+
+// Session is the redaction state of a single request, which remembers the tokenized PII to restore them in the
+// completions. This is not safe for concurrent use.
+type Session struct {
+	r *Redactor
+	// originals maps the placeholders to the tokenized PII, and placeholders is the reverse per detector.
+	originals    map[string]string
+	placeholders map[string]string
+	counts       map[string]int
+	// maxPlaceholderLen is the length of the longest placeholder.
+	maxPlaceholderLen int
+}
+
+// NewSession creates a new Session of a request.
+func (r *Redactor) NewSession() *Session {
+	return &Session{r: r, originals: map[string]string{}, placeholders: map[string]string{}, counts: map[string]int{}}
+}
+
+// Redact returns the text of the request with the PII redacted. This returns a [*RejectedError] if the text
+// contains the PII detected by a detector with the Reject action.
+func (s *Session) Redact(text string) (string, error) {
+	for i := range s.r.detectors {
+		d := &s.r.detectors[i]
+		var rejected bool
+		text = d.replace(text, func(match string) string {
+			switch d.action {
+			case filterapi.RedactionActionReject:
+				rejected = true
+			case filterapi.RedactionActionTokenize:
+				return s.tokenize(d.name, match)
+			case filterapi.RedactionActionHash:
+				return hashPlaceholder(s.r.hashKey, d.name, match)
+			}
+			return "[" + d.name + "]"
+		})
+		if rejected {
+			return "", &RejectedError{Detector: d.name}
+		}
+	}
+	return text, nil
+}
+
+func (s *Session) tokenize(name, match string) string {
+	key := name + "\x00" + match
+	if p, ok := s.placeholders[key]; ok {
+		return p
+	}
+	s.counts[name]++
+	p := "[" + name + "_" + strconv.Itoa(s.counts[name]) + "]"
+	s.placeholders[key], s.originals[p] = p, match
+	s.maxPlaceholderLen = max(s.maxPlaceholderLen, len(p))
+	return p
+}
+
+// ProcessesResponses returns true if the completions need to be processed, i.e. there are the placeholders to restore
+// or the completions are redacted.
+func (s *Session) ProcessesResponses() bool {
+	return s.r.responses || len(s.originals) > 0
+}
+
+// Response returns the text of the completion with the PII redacted if configured, and the placeholders of the
+// tokenized PII restored.
+func (s *Session) Response(text string) string {
+	if s.r.responses {
+		for i := range s.r.detectors {
+			d := &s.r.detectors[i]
+			switch d.action {
+			case filterapi.RedactionActionTokenize:
+				// The PII of the client is restored rather than redacted.
+			case filterapi.RedactionActionHash:
+				text = d.replace(text, func(match string) string { return hashPlaceholder(s.r.hashKey, d.name, match) })
+			default:
+				text = d.replace(text, func(string) string { return "[" + d.name + "]" })
+			}
+		}
+	}
+	if len(s.originals) > 0 {
+		text = placeholderPattern.ReplaceAllStringFunc(text, func(p string) string {
+			if original, ok := s.originals[p]; ok {
+				return original
+			}
+			return p
+		})
+	}
+	return text
+}
+
+// replace replaces the valid matches of the detector in the text with the result of the function.
+func (d *detector) replace(text string, f func(string) string) string {
+	return d.re.ReplaceAllStringFunc(text, func(match string) string {
+		if d.validate != nil && !d.validate(match) {
+			return match
+		}
+		return f(match)
+	})
+}
+
+// hashPlaceholder returns the placeholder of the PII with its truncated HMAC-SHA256 keyed with the hash key.
+// Unlike a plain hash, this cannot be reversed by hashing the candidates of the PII, e.g. all the phone numbers,
+// without the key.
+func hashPlaceholder(key []byte, name, match string) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(match))
+	return "[" + name + ":" + hex.EncodeToString(h.Sum(nil)[:8]) + "]"
+}
+
+// responseHoldback is the number of the characters at the end of a streamed completion held back when the
+// completions are redacted, since they might be the beginning of the PII continued in the next chunk.
+const responseHoldback = 64
+
+// Stream processes a streamed completion with [Session.Response], holding back the end of the text received so far
+// while it can be a part of the PII or a placeholder continued in the next chunk.
+type Stream struct {
+	s   *Session
+	buf string
+}
+
+// NewStream creates a new Stream of a streamed completion, e.g. the content of a choice.
+func (s *Session) NewStream() *Stream { return &Stream{s: s} }
+
+// Write processes the next chunk of the completion, and returns the text that can be sent to the client.
+func (st *Stream) Write(text string) string {
+	st.buf += text
+	cut := len(st.buf)
+	if st.s.r.responses {
+		cut -= max(st.s.maxPlaceholderLen, responseHoldback)
+	} else if i := strings.LastIndexByte(st.buf, '['); i >= 0 && len(st.buf)-i < st.s.maxPlaceholderLen &&
+		strings.IndexByte(st.buf[i:], ']') < 0 {
+		// Only the text that might be the beginning of a placeholder needs to be held back.
+		cut = i
+	}
+	if cut <= 0 {
+		return ""
+	}
+	// Never cut in the middle of a match, which might continue in the next chunk.
+	for moved := true; moved && cut > 0; {
+		moved = false
+		for _, m := range st.matches() {
+			if m[0] < cut && cut < m[1] {
+				cut, moved = m[0], true
+			}
+		}
+	}
+	for cut > 0 && cut < len(st.buf) && !utf8RuneStart(st.buf[cut]) {
+		cut--
+	}
+	out := st.s.Response(st.buf[:cut])
+	st.buf = st.buf[cut:]
+	return out
+}
+
+// Flush returns the rest of the completion held back at the end of the stream.
+func (st *Stream) Flush() string {
+	out := st.s.Response(st.buf)
+	st.buf = ""
+	return out
+}
+
+// matches returns the ranges of the placeholders and the matches of the detectors in the held text.
+func (st *Stream) matches() (ret [][]int) {
+	if len(st.s.originals) > 0 {
+		ret = append(ret, placeholderPattern.FindAllStringIndex(st.buf, -1)...)
+	}
+	if st.s.r.responses {
+		for i := range st.s.r.detectors {
+			ret = append(ret, st.s.r.detectors[i].re.FindAllStringIndex(st.buf, -1)...)
+		}
+	}
+	return
+}
+
+func utf8RuneStart(b byte) bool { return b&0xC0 != 0x80 }
+
+// validatorFuncs is the validators by the name.
+var validatorFuncs = map[filterapi.RedactionValidator]func(string) bool{
+	filterapi.RedactionValidatorLuhn: validLuhn,
+	filterapi.RedactionValidatorIBAN: validIBAN,
+}
+
+// validLuhn returns true if the digits in the string pass the Luhn check.
+func validLuhn(s string) bool {
+	var sum, n int
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 1 && sum%10 == 0
+}
+
+// validIBAN returns true if the string passes the ISO 7064 MOD 97-10 check of the IBAN.
+func validIBAN(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	// Move the country code and the check digits to the end, and convert the letters to the numbers from 10 to 35.
+	var rem int
+	for _, c := range s[4:] + s[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			rem = (rem*100 + int(c-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
+
+// validUSSocialSecurityNumber returns true if the "AAA-GG-SSSS" string is not one of the numbers never issued.
+func validUSSocialSecurityNumber(s string) bool {
+	area, group, serial := s[:3], s[4:6], s[7:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validPhoneNumber returns true if the string has 7 to 15 digits, the maximum length of the E.164 numbers.
+func validPhoneNumber(s string) bool {
+	var n int
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			n++
+		}
+	}
+	return n >= 7 && n <= 15
+}
@@ -0,0 +1,191 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package redaction
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+)
+
+func newRedactor(t *testing.T, responses bool, detectors ...filterapi.RedactionDetector) *Redactor {
+	r, err := New(&filterapi.Redaction{Detectors: detectors, Responses: responses, HashKey: "key"})
+	require.NoError(t, err)
+	return r
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(&filterapi.Redaction{Detectors: []filterapi.RedactionDetector{{Name: "X", Builtin: "Unknown"}}})
+	require.ErrorContains(t, err, `unknown builtin detector "Unknown" of detector X`)
+	_, err = New(&filterapi.Redaction{Detectors: []filterapi.RedactionDetector{{Name: "X", Pattern: "("}}})
+	require.ErrorContains(t, err, "invalid pattern of detector X")
+	_, err = New(&filterapi.Redaction{Detectors: []filterapi.RedactionDetector{{Name: "X", Pattern: "x", Action: filterapi.RedactionActionHash}}})
+	require.ErrorContains(t, err, "hash key is required for the Hash action of detector X")
+}
+
+func TestBuiltinDetectors(t *testing.T) {
+	for _, tc := range []struct {
+		builtin     filterapi.RedactionBuiltinDetector
+		matches     []string
+		nonMatching []string
+	}{
+		{
+			builtin:     filterapi.RedactionBuiltinDetectorEmail,
+			matches:     []string{"john.doe@example.com", "a+b@mail.example.co.uk"},
+			nonMatching: []string{"john@localhost", "@example.com"},
+		},
+		{
+			builtin:     filterapi.RedactionBuiltinDetectorPhoneNumber,
+			matches:     []string{"+1 (555) 123-4567", "+44 20 7946 0958", "555.123.4567", "5551234567", "123-4567"},
+			nonMatching: []string{"2024-01-15", "12:30", "version 1.2.3"},
+		},
+		{
+			builtin:     filterapi.RedactionBuiltinDetectorCreditCard,
+			matches:     []string{"4111 1111 1111 1111", "4111-1111-1111-1111", "378282246310005"},
+			nonMatching: []string{"4111 1111 1111 1112", "1234567890123"},
+		},
+		{
+			builtin:     filterapi.RedactionBuiltinDetectorUSSocialSecurityNumber,
+			matches:     []string{"123-45-6789"},
+			nonMatching: []string{"000-12-3456", "666-12-3456", "900-12-3456", "123-00-4567", "123-45-0000", "123456789"},
+		},
+		{
+			builtin:     filterapi.RedactionBuiltinDetectorIBAN,
+			matches:     []string{"GB82 WEST 1234 5698 7654 32", "DE89370400440532013000"},
+			nonMatching: []string{"GB82 WEST 1234 5698 7654 33", "DE00370400440532013000"},
+		},
+	} {
+		t.Run(string(tc.builtin), func(t *testing.T) {
+			s := newRedactor(t, false, filterapi.RedactionDetector{Name: "PII", Builtin: tc.builtin, Action: filterapi.RedactionActionMask}).NewSession()
+			for _, m := range tc.matches {
+				got, err := s.Redact("a " + m + " b")
+				require.NoError(t, err)
+				require.Equal(t, "a [PII] b", got, m)
+			}
+			for _, m := range tc.nonMatching {
+				got, err := s.Redact("a " + m + " b")
+				require.NoError(t, err)
+				require.Equal(t, "a "+m+" b", got, m)
+			}
+		})
+	}
+}
+
+func TestSession(t *testing.T) {
+	r := newRedactor(t, false,
+		filterapi.RedactionDetector{Name: "EMAIL", Builtin: filterapi.RedactionBuiltinDetectorEmail, Action: filterapi.RedactionActionTokenize},
+		filterapi.RedactionDetector{Name: "CARD", Builtin: filterapi.RedactionBuiltinDetectorCreditCard, Action: filterapi.RedactionActionReject},
+		filterapi.RedactionDetector{Name: "SSN", Builtin: filterapi.RedactionBuiltinDetectorUSSocialSecurityNumber, Action: filterapi.RedactionActionHash},
+		filterapi.RedactionDetector{Name: "EMPLOYEE", Pattern: `\bE\d{4}\b`, Validator: filterapi.RedactionValidatorLuhn},
+	)
+
+	t.Run("tokenize", func(t *testing.T) {
+		s := r.NewSession()
+		require.False(t, s.ProcessesResponses())
+		got, err := s.Redact("Mail a@example.com and b@example.com.")
+		require.NoError(t, err)
+		require.Equal(t, "Mail [EMAIL_1] and [EMAIL_2].", got)
+		// The same PII gets the same placeholder across the texts of the request.
+		got, err = s.Redact("Again a@example.com")
+		require.NoError(t, err)
+		require.Equal(t, "Again [EMAIL_1]", got)
+
+		require.True(t, s.ProcessesResponses())
+		require.Equal(t, "Sent to a@example.com, not [EMAIL_3].", s.Response("Sent to [EMAIL_1], not [EMAIL_3]."))
+	})
+
+	t.Run("hash and mask", func(t *testing.T) {
+		s := r.NewSession()
+		got, err := s.Redact("SSN 123-45-6789, employee E1230 and E1234")
+		require.NoError(t, err)
+		require.Equal(t, "SSN [SSN:440e40f8f8408832], employee [EMPLOYEE] and E1234", got)
+		require.Equal(t, hashPlaceholder([]byte("key"), "SSN", "123-45-6789"), "[SSN:440e40f8f8408832]")
+		// The placeholder depends on the key.
+		require.NotEqual(t, hashPlaceholder([]byte("other"), "SSN", "123-45-6789"), "[SSN:440e40f8f8408832]")
+		require.False(t, s.ProcessesResponses())
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		_, err := r.NewSession().Redact("card 4111 1111 1111 1111")
+		var rejected *RejectedError
+		require.ErrorAs(t, err, &rejected)
+		require.Equal(t, "CARD", rejected.Detector)
+		require.EqualError(t, err, "the request contains CARD")
+	})
+
+	t.Run("responses", func(t *testing.T) {
+		r := newRedactor(t, true,
+			filterapi.RedactionDetector{Name: "EMAIL", Builtin: filterapi.RedactionBuiltinDetectorEmail, Action: filterapi.RedactionActionTokenize},
+			filterapi.RedactionDetector{Name: "CARD", Builtin: filterapi.RedactionBuiltinDetectorCreditCard, Action: filterapi.RedactionActionReject},
+			filterapi.RedactionDetector{Name: "SSN", Builtin: filterapi.RedactionBuiltinDetectorUSSocialSecurityNumber, Action: filterapi.RedactionActionHash},
+		)
+		s := r.NewSession()
+		require.True(t, s.ProcessesResponses())
+		_, err := s.Redact("I am a@example.com")
+		require.NoError(t, err)
+		require.Equal(t, "Hi a@example.com, c@example.com, [CARD], [SSN:440e40f8f8408832]",
+			s.Response("Hi [EMAIL_1], c@example.com, 4111 1111 1111 1111, 123-45-6789"))
+	})
+}
+
+func TestStream(t *testing.T) {
+	// write writes the text to the stream in the chunks of the given size, and returns the concatenated output.
+	write := func(st *Stream, text string, size int) string {
+		var out strings.Builder
+		for i := 0; i < len(text); i += size {
+			out.WriteString(st.Write(text[i:min(i+size, len(text))]))
+		}
+		out.WriteString(st.Flush())
+		return out.String()
+	}
+
+	t.Run("restore", func(t *testing.T) {
+		s := newRedactor(t, false,
+			filterapi.RedactionDetector{Name: "EMAIL", Builtin: filterapi.RedactionBuiltinDetectorEmail, Action: filterapi.RedactionActionTokenize},
+		).NewSession()
+		_, err := s.Redact("a@example.com b@example.com")
+		require.NoError(t, err)
+		const completion = "Write to [EMAIL_1] or [EMAIL_2], but not [EMAIL_9]. Thanks! 日本語"
+		for size := 1; size <= len(completion); size++ {
+			require.Equal(t, "Write to a@example.com or b@example.com, but not [EMAIL_9]. Thanks! 日本語",
+				write(s.NewStream(), completion, size), size)
+		}
+
+		// The text far enough from a possible placeholder is sent right away.
+		st := s.NewStream()
+		require.Equal(t, "Hello, ", st.Write("Hello, [EM"))
+		require.Equal(t, "a@example.com and more text", st.Write("AIL_1] and more text"))
+	})
+
+	t.Run("responses", func(t *testing.T) {
+		s := newRedactor(t, true,
+			filterapi.RedactionDetector{Name: "EMAIL", Builtin: filterapi.RedactionBuiltinDetectorEmail, Action: filterapi.RedactionActionMask},
+		).NewSession()
+		completion := strings.Repeat("lorem ipsum ", 10) + "contact someone.with.a.long.name@example.com " + strings.Repeat("dolor ", 10)
+		exp := strings.Repeat("lorem ipsum ", 10) + "contact [EMAIL] " + strings.Repeat("dolor ", 10)
+		for size := 1; size <= len(completion); size++ {
+			require.Equal(t, exp, write(s.NewStream(), completion, size), size)
+		}
+	})
+
+	t.Run("pass through", func(t *testing.T) {
+		s := newRedactor(t, false,
+			filterapi.RedactionDetector{Name: "EMAIL", Builtin: filterapi.RedactionBuiltinDetectorEmail, Action: filterapi.RedactionActionMask},
+		).NewSession()
+		st := s.NewStream()
+		require.Equal(t, "a@example.com", st.Write("a@example.com"))
+	})
+}
+
+func Test_validLuhn(t *testing.T) {
+	require.True(t, validLuhn("4111111111111111"))
+	require.True(t, validLuhn("79927398713"))
+	require.False(t, validLuhn("79927398710"))
+	require.False(t, validLuhn("0"))
+}
@@ -0,0 +1,286 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/tidwall/sjson"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+)
+
+// applyRequestPolicy enforces the request policy of a rule on the chat completion request.
+//
+// The parameters exceeding the limits with the clamp action are rewritten both in the raw body and in the parsed
+// body, and this returns the rewritten raw body along with whether it has been modified. When a limit with the
+// reject action is exceeded, or the request fails the validation of the policy, this returns the immediate response
+// with the OpenAI-style invalid request error.
+func applyRequestPolicy(p *filterapi.RequestPolicy, raw []byte, body *openai.ChatCompletionRequest) (
+	[]byte, bool, *extprocv3.ProcessingResponse, error,
+) {
+	if rejected := validateRequest(p, body); rejected != nil {
+		return raw, false, rejected, nil
+	}
+	e := requestPolicyEnforcer{raw: raw}
+	if l := p.MaxTokens; l != nil {
+		e.int64Limit(l, "max_tokens", body.MaxTokens)
+		e.int64Limit(l, "max_completion_tokens", body.MaxCompletionTokens)
+	}
+	if l := p.N; l != nil && body.N != nil && *body.N > l.Max {
+		if e.exceeded(l.Action, "n", "integer_above_max_value",
+			fmt.Sprintf("integer above maximum value. Expected a value <= %d, but got %d instead.", l.Max, *body.N)) {
+			*body.N = l.Max
+			e.set("n", l.Max)
+		}
+	}
+	if l := p.Temperature; l != nil && body.Temperature != nil {
+		t := *body.Temperature
+		if l.Min != nil && t < *l.Min {
+			if e.exceeded(l.Action, "temperature", "decimal_below_min_value",
+				fmt.Sprintf("decimal below minimum value. Expected a value >= %g, but got %g instead.", *l.Min, t)) {
+				*body.Temperature = *l.Min
+				e.set("temperature", *l.Min)
+			}
+		} else if l.Max != nil && t > *l.Max {
+			if e.exceeded(l.Action, "temperature", "decimal_above_max_value",
+				fmt.Sprintf("decimal above maximum value. Expected a value <= %g, but got %g instead.", *l.Max, t)) {
+				*body.Temperature = *l.Max
+				e.set("temperature", *l.Max)
+			}
+		}
+	}
+	if l := p.Tools; l != nil && len(body.Tools) > l.Max {
+		if e.exceeded(l.Action, "tools", "array_above_max_length", arrayTooLongMessage(l.Max, len(body.Tools))) {
+			if l.Max == 0 {
+				// The backends reject the tool choice without the tools.
+				body.Tools, body.ToolChoice = nil, nil
+				e.delete("tools")
+				e.delete("tool_choice")
+			} else {
+				for i := len(body.Tools) - 1; i >= l.Max; i-- {
+					e.delete("tools." + strconv.Itoa(i))
+				}
+				body.Tools = body.Tools[:l.Max]
+			}
+		}
+	}
+	if l := p.Messages; l != nil && len(body.Messages) > l.Max {
+		if e.exceeded(l.Action, "messages", "array_above_max_length", arrayTooLongMessage(l.Max, len(body.Messages))) {
+			drop := messagesToDrop(body.Messages, l.Max)
+			kept := body.Messages[:0:0]
+			for i, m := range body.Messages {
+				if !drop[i] {
+					kept = append(kept, m)
+				}
+			}
+			for i := len(body.Messages) - 1; i >= 0; i-- {
+				if drop[i] {
+					e.delete("messages." + strconv.Itoa(i))
+				}
+			}
+			body.Messages = kept
+		}
+	}
+	if e.err != nil {
+		return nil, false, nil, fmt.Errorf("failed to apply request policy: %w", e.err)
+	}
+	return e.raw, e.mutated, e.rejected, nil
+}
+
+// validateBodySize validates the size of the raw request body against the policy. This is separate from
+// [validateRequest] since it must run before the body is parsed.
+func validateBodySize(p *filterapi.RequestPolicy, raw []byte) *extprocv3.ProcessingResponse {
+	if p.MaxBodyBytes > 0 && int64(len(raw)) > p.MaxBodyBytes {
+		return openAIErrorResponse(typev3.StatusCode_BadRequest, "invalid_request_error", "request_too_large", "",
+			fmt.Sprintf("Request body too large. Expected a body of at most %d bytes, but got %d bytes instead.",
+				p.MaxBodyBytes, len(raw)))
+	}
+	return nil
+}
+
+// validateRequest validates the images in the messages and the schemas of the tools against the policy, and
+// returns the immediate response with the invalid request error if the request is invalid.
+func validateRequest(p *filterapi.RequestPolicy, body *openai.ChatCompletionRequest) *extprocv3.ProcessingResponse {
+	if p.Images != nil {
+		if rejected := validateImages(p.Images, body.Messages); rejected != nil {
+			return rejected
+		}
+	}
+	if p.MaxToolSchemaDepth > 0 {
+		for i := range body.Tools {
+			if body.Tools[i].Function == nil {
+				continue
+			}
+			if depth := jsonDepth(body.Tools[i].Function.Parameters); depth > p.MaxToolSchemaDepth {
+				return invalidRequestResponse(fmt.Sprintf("tools[%d].function.parameters", i), "schema_too_deep",
+					fmt.Sprintf("schema too deep. Expected a schema with maximum depth %d, but got a schema with depth %d instead.",
+						p.MaxToolSchemaDepth, depth))
+			}
+		}
+	}
+	return nil
+}
+
+// validateImages validates the images in the content of the user messages. The size and the type are only checked
+// for the images embedded as the data URLs since the others are fetched by the backends.
+func validateImages(p *filterapi.ImagePolicy, messages []openai.ChatCompletionMessageParamUnion) *extprocv3.ProcessingResponse {
+	var images int
+	for i := range messages {
+		msg, ok := messages[i].Value.(openai.ChatCompletionUserMessageParam)
+		if !ok {
+			continue
+		}
+		parts, ok := msg.Content.Value.([]openai.ChatCompletionContentPartUserUnionParam)
+		if !ok {
+			continue
+		}
+		for j := range parts {
+			image := parts[j].ImageContent
+			if image == nil {
+				continue
+			}
+			images++
+			url := image.ImageURL.URL
+			if !strings.HasPrefix(url, "data:") || (p.MaxImageBytes == 0 && len(p.AllowedMIMETypes) == 0) {
+				continue
+			}
+			param := fmt.Sprintf("messages[%d].content[%d].image_url.url", i, j)
+			contentType, data, err := translator.ParseDataURI(url)
+			if err != nil {
+				return invalidRequestResponse(param, "invalid_image_url", fmt.Sprintf("invalid data URL: %v.", err))
+			}
+			if len(p.AllowedMIMETypes) > 0 && !slices.ContainsFunc(p.AllowedMIMETypes, func(t string) bool {
+				return strings.EqualFold(t, contentType)
+			}) {
+				return invalidRequestResponse(param, "invalid_image_format",
+					fmt.Sprintf("unsupported image format %q. Expected one of: %s.", contentType, strings.Join(p.AllowedMIMETypes, ", ")))
+			}
+			if p.MaxImageBytes > 0 && int64(len(data)) > p.MaxImageBytes {
+				return invalidRequestResponse(param, "image_too_large",
+					fmt.Sprintf("image too large. Expected an image of at most %d bytes, but got %d bytes instead.",
+						p.MaxImageBytes, len(data)))
+			}
+		}
+	}
+	if p.MaxImages != nil && images > *p.MaxImages {
+		return invalidRequestResponse("messages", "too_many_images",
+			fmt.Sprintf("too many images. Expected at most %d images, but got %d images instead.", *p.MaxImages, images))
+	}
+	return nil
+}
+
+// jsonDepth returns the nesting depth of the objects and the arrays of the decoded JSON value, where a scalar has
+// the depth of 0.
+func jsonDepth(v any) int {
+	var depth int
+	switch v := v.(type) {
+	case map[string]any:
+		for _, child := range v {
+			depth = max(depth, jsonDepth(child))
+		}
+	case []any:
+		for _, child := range v {
+			depth = max(depth, jsonDepth(child))
+		}
+	default:
+		return 0
+	}
+	return depth + 1
+}
+
+func invalidRequestResponse(param, code, reason string) *extprocv3.ProcessingResponse {
+	return openAIErrorResponse(typev3.StatusCode_BadRequest, "invalid_request_error", code, param,
+		fmt.Sprintf("Invalid '%s': %s", param, reason))
+}
+
+// requestPolicyEnforcer accumulates the rewrites of the raw body and the first rejection while a request policy
+// is applied.
+type requestPolicyEnforcer struct {
+	raw      []byte
+	mutated  bool
+	rejected *extprocv3.ProcessingResponse
+	err      error
+}
+
+// exceeded handles a parameter exceeding the limit of the given action, and returns true if it must be clamped.
+func (e *requestPolicyEnforcer) exceeded(action filterapi.RequestLimitAction, param, code, reason string) bool {
+	if e.rejected != nil || e.err != nil {
+		return false
+	}
+	if action == filterapi.RequestLimitActionReject {
+		e.rejected = invalidRequestResponse(param, code, reason)
+		return false
+	}
+	return true
+}
+
+func (e *requestPolicyEnforcer) int64Limit(l *filterapi.RequestLimit, param string, v *int64) {
+	if v == nil || *v <= int64(l.Max) {
+		return
+	}
+	if e.exceeded(l.Action, param, "integer_above_max_value",
+		fmt.Sprintf("integer above maximum value. Expected a value <= %d, but got %d instead.", l.Max, *v)) {
+		*v = int64(l.Max)
+		e.set(param, l.Max)
+	}
+}
+
+func (e *requestPolicyEnforcer) set(path string, value any) {
+	e.raw, e.err = sjson.SetBytes(e.raw, path, value)
+	e.mutated = true
+}
+
+func (e *requestPolicyEnforcer) delete(path string) {
+	if e.err != nil {
+		return
+	}
+	e.raw, e.err = sjson.DeleteBytes(e.raw, path)
+	e.mutated = true
+}
+
+func arrayTooLongMessage(maxLen, length int) string {
+	return fmt.Sprintf("array too long. Expected an array with maximum length %d, but got an array with length %d instead.",
+		maxLen, length)
+}
+
+// messagesToDrop returns the indexes of the messages to drop to keep at most maxMessages messages.
+//
+// The system and developer messages are always kept, and the oldest of the other messages are dropped so that at
+// least the last message is kept. The tool results at the head of the kept messages are dropped as well since
+// the assistant messages with the corresponding tool calls have been dropped, which the backends would reject.
+func messagesToDrop(messages []openai.ChatCompletionMessageParamUnion, maxMessages int) map[int]bool {
+	var conversation []int
+	for i := range messages {
+		switch messages[i].Type {
+		case openai.ChatMessageRoleSystem, openai.ChatMessageRoleDeveloper:
+		default:
+			conversation = append(conversation, i)
+		}
+	}
+	keep := max(maxMessages-(len(messages)-len(conversation)), 1)
+	drop := make(map[int]bool)
+	if keep >= len(conversation) {
+		return drop
+	}
+	for _, i := range conversation[:len(conversation)-keep] {
+		drop[i] = true
+	}
+	for _, i := range conversation[len(conversation)-keep : len(conversation)-1] {
+		if messages[i].Type != openai.ChatMessageRoleTool {
+			break
+		}
+		drop[i] = true
+	}
+	return drop
+}
@@ -0,0 +1,241 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+)
+
+func Test_applyRequestPolicy(t *testing.T) {
+	// images returns the request with a user message containing a text and the images of the given URLs.
+	images := func(urls ...string) string {
+		content := `{"type":"text","text":"describe"}`
+		for _, url := range urls {
+			content += `,{"type":"image_url","image_url":{"url":"` + url + `"}}`
+		}
+		return `{"model":"m","messages":[{"role":"user","content":[` + content + `]}]}`
+	}
+	clamp := func(maxValue int) *filterapi.RequestLimit {
+		return &filterapi.RequestLimit{Max: maxValue, Action: filterapi.RequestLimitActionClamp}
+	}
+	reject := func(maxValue int) *filterapi.RequestLimit {
+		return &filterapi.RequestLimit{Max: maxValue, Action: filterapi.RequestLimitActionReject}
+	}
+	for _, tc := range []struct {
+		name    string
+		policy  filterapi.RequestPolicy
+		body    string
+		expBody string
+		expErr  string
+	}{
+		{
+			name:    "within limits",
+			policy:  filterapi.RequestPolicy{MaxTokens: clamp(100), N: reject(2), Tools: reject(1), Messages: reject(2)},
+			body:    `{"model":"m","messages":[{"role":"user","content":"hi"}],"max_tokens":100,"n":2}`,
+			expBody: `{"model":"m","messages":[{"role":"user","content":"hi"}],"max_tokens":100,"n":2}`,
+		},
+		{
+			name:    "clamp max tokens and n",
+			policy:  filterapi.RequestPolicy{MaxTokens: clamp(100), N: clamp(1)},
+			body:    `{"model":"m","messages":[],"max_tokens":100000,"max_completion_tokens":200,"n":20}`,
+			expBody: `{"model":"m","messages":[],"max_tokens":100,"max_completion_tokens":100,"n":1}`,
+		},
+		{
+			name:   "reject max tokens",
+			policy: filterapi.RequestPolicy{MaxTokens: reject(100)},
+			body:   `{"model":"m","messages":[],"max_completion_tokens":200}`,
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"integer_above_max_value","message":"Invalid 'max_completion_tokens': integer above maximum value. Expected a value <= 100, but got 200 instead.","param":"max_completion_tokens"}}`,
+		},
+		{
+			name:   "reject n",
+			policy: filterapi.RequestPolicy{N: reject(1)},
+			body:   `{"model":"m","messages":[],"n":20}`,
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"integer_above_max_value","message":"Invalid 'n': integer above maximum value. Expected a value <= 1, but got 20 instead.","param":"n"}}`,
+		},
+		{
+			name:    "clamp temperature",
+			policy:  filterapi.RequestPolicy{Temperature: &filterapi.TemperatureLimit{Max: ptr.To(1.0), Action: filterapi.RequestLimitActionClamp}},
+			body:    `{"model":"m","messages":[],"temperature":1.7}`,
+			expBody: `{"model":"m","messages":[],"temperature":1}`,
+		},
+		{
+			name:   "reject temperature",
+			policy: filterapi.RequestPolicy{Temperature: &filterapi.TemperatureLimit{Min: ptr.To(0.2), Action: filterapi.RequestLimitActionReject}},
+			body:   `{"model":"m","messages":[],"temperature":0.1}`,
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"decimal_below_min_value","message":"Invalid 'temperature': decimal below minimum value. Expected a value >= 0.2, but got 0.1 instead.","param":"temperature"}}`,
+		},
+		{
+			name:    "clamp tools",
+			policy:  filterapi.RequestPolicy{Tools: clamp(1)},
+			body:    `{"model":"m","messages":[],"tools":[{"type":"function","function":{"name":"a"}},{"type":"function","function":{"name":"b"}}]}`,
+			expBody: `{"model":"m","messages":[],"tools":[{"type":"function","function":{"name":"a"}}]}`,
+		},
+		{
+			name:    "clamp tools to none",
+			policy:  filterapi.RequestPolicy{Tools: clamp(0)},
+			body:    `{"model":"m","messages":[],"tools":[{"type":"function","function":{"name":"a"}}],"tool_choice":"required"}`,
+			expBody: `{"model":"m","messages":[]}`,
+		},
+		{
+			name:   "reject tools",
+			policy: filterapi.RequestPolicy{Tools: reject(0)},
+			body:   `{"model":"m","messages":[],"tools":[{"type":"function","function":{"name":"a"}}]}`,
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"array_above_max_length","message":"Invalid 'tools': array too long. Expected an array with maximum length 0, but got an array with length 1 instead.","param":"tools"}}`,
+		},
+		{
+			name:   "clamp messages",
+			policy: filterapi.RequestPolicy{Messages: clamp(3)},
+			body: `{"model":"m","messages":[{"role":"system","content":"s"},{"role":"user","content":"1"},` +
+				`{"role":"assistant","tool_calls":[{"id":"c","type":"function","function":{"name":"f","arguments":"{}"}}]},` +
+				`{"role":"tool","tool_call_id":"c","content":"r"},{"role":"user","content":"2"}]}`,
+			// The tool result is dropped along with the assistant message that called the tool.
+			expBody: `{"model":"m","messages":[{"role":"system","content":"s"},{"role":"user","content":"2"}]}`,
+		},
+		{
+			name:   "reject messages",
+			policy: filterapi.RequestPolicy{Messages: reject(1)},
+			body:   `{"model":"m","messages":[{"role":"system","content":"s"},{"role":"user","content":"1"}]}`,
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"array_above_max_length","message":"Invalid 'messages': array too long. Expected an array with maximum length 1, but got an array with length 2 instead.","param":"messages"}}`,
+		},
+		{
+			name:    "valid images",
+			policy:  filterapi.RequestPolicy{Images: &filterapi.ImagePolicy{MaxImages: ptr.To(2), MaxImageBytes: 5, AllowedMIMETypes: []string{"image/png"}}},
+			body:    images(`data:image/png;base64,aGVsbG8=`, `https://example.com/a.jpg`),
+			expBody: images(`data:image/png;base64,aGVsbG8=`, `https://example.com/a.jpg`),
+		},
+		{
+			name:   "reject too many images",
+			policy: filterapi.RequestPolicy{Images: &filterapi.ImagePolicy{MaxImages: ptr.To(1)}},
+			body:   images(`data:image/png;base64,aGVsbG8=`, `https://example.com/a.jpg`),
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"too_many_images","message":"Invalid 'messages': too many images. Expected at most 1 images, but got 2 images instead.","param":"messages"}}`,
+		},
+		{
+			name:   "reject image size",
+			policy: filterapi.RequestPolicy{Images: &filterapi.ImagePolicy{MaxImageBytes: 4}},
+			body:   images(`https://example.com/a.jpg`, `data:image/png;base64,aGVsbG8=`),
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"image_too_large","message":"Invalid 'messages[0].content[2].image_url.url': image too large. Expected an image of at most 4 bytes, but got 5 bytes instead.","param":"messages[0].content[2].image_url.url"}}`,
+		},
+		{
+			name:   "reject image format",
+			policy: filterapi.RequestPolicy{Images: &filterapi.ImagePolicy{AllowedMIMETypes: []string{"image/png", "image/jpeg"}}},
+			body:   images(`data:image/gif;base64,aGVsbG8=`),
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"invalid_image_format","message":"Invalid 'messages[0].content[1].image_url.url': unsupported image format \"image/gif\". Expected one of: image/png, image/jpeg.","param":"messages[0].content[1].image_url.url"}}`,
+		},
+		{
+			name:   "reject invalid data url",
+			policy: filterapi.RequestPolicy{Images: &filterapi.ImagePolicy{MaxImageBytes: 4}},
+			body:   images(`data:image/png;base64,!!!`),
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"invalid_image_url","message":"Invalid 'messages[0].content[1].image_url.url': invalid data URL: illegal base64 data at input byte 0.","param":"messages[0].content[1].image_url.url"}}`,
+		},
+		{
+			name:    "valid tool schema",
+			policy:  filterapi.RequestPolicy{MaxToolSchemaDepth: 3},
+			body:    `{"model":"m","messages":[],"tools":[{"type":"function","function":{"name":"a","parameters":{"type":"object","properties":{"x":{"type":"string"}}}}}]}`,
+			expBody: `{"model":"m","messages":[],"tools":[{"type":"function","function":{"name":"a","parameters":{"type":"object","properties":{"x":{"type":"string"}}}}}]}`,
+		},
+		{
+			name:   "reject tool schema depth",
+			policy: filterapi.RequestPolicy{MaxToolSchemaDepth: 3},
+			body: `{"model":"m","messages":[],"tools":[{"type":"function","function":{"name":"a"}},` +
+				`{"type":"function","function":{"name":"b","parameters":{"type":"object","properties":{"x":{"type":"array","items":{"type":"string"}}}}}}]}`,
+			expErr: `{"type":"error","error":{"type":"invalid_request_error","code":"schema_too_deep","message":"Invalid 'tools[1].function.parameters': schema too deep. Expected a schema with maximum depth 3, but got a schema with depth 4 instead.","param":"tools[1].function.parameters"}}`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var body openai.ChatCompletionRequest
+			require.NoError(t, json.Unmarshal([]byte(tc.body), &body))
+			raw, mutated, rejected, err := applyRequestPolicy(&tc.policy, []byte(tc.body), &body)
+			require.NoError(t, err)
+			if tc.expErr != "" {
+				require.NotNil(t, rejected)
+				require.Equal(t, typev3.StatusCode_BadRequest, rejected.GetImmediateResponse().GetStatus().GetCode())
+				require.JSONEq(t, tc.expErr, string(rejected.GetImmediateResponse().GetBody()))
+				return
+			}
+			require.Nil(t, rejected)
+			require.Equal(t, tc.body != tc.expBody, mutated)
+			require.JSONEq(t, tc.expBody, string(raw))
+
+			// The parsed body must be consistent with the raw body as it is used by the translators.
+			var expBody openai.ChatCompletionRequest
+			require.NoError(t, json.Unmarshal(raw, &expBody))
+			require.Equal(t, expBody, body)
+		})
+	}
+}
+
+func Test_validateBodySize(t *testing.T) {
+	p := &filterapi.RequestPolicy{MaxBodyBytes: 16}
+	require.Nil(t, validateBodySize(p, []byte(`{"model":"m"}`)))
+	require.Nil(t, validateBodySize(&filterapi.RequestPolicy{}, []byte(`{"model":"m","messages":[]}`)))
+	rejected := validateBodySize(p, []byte(`{"model":"m","messages":[]}`))
+	require.NotNil(t, rejected)
+	require.Equal(t, typev3.StatusCode_BadRequest, rejected.GetImmediateResponse().GetStatus().GetCode())
+	require.JSONEq(t, `{"type":"error","error":{"type":"invalid_request_error","code":"request_too_large","message":"Request body too large. Expected a body of at most 16 bytes, but got 27 bytes instead."}}`,
+		string(rejected.GetImmediateResponse().GetBody()))
+}
+
+func TestChatCompletion_requestPolicy(t *testing.T) {
+	rule := &filterapi.RouteRule{Name: "some-route", RequestPolicy: &filterapi.RequestPolicy{
+		MaxTokens:    &filterapi.RequestLimit{Max: 100, Action: filterapi.RequestLimitActionClamp},
+		N:            &filterapi.RequestLimit{Max: 1, Action: filterapi.RequestLimitActionReject},
+		MaxBodyBytes: 128,
+	}}
+	newRouterFilter := func() *chatCompletionProcessorRouterFilter {
+		headers := map[string]string{":path": "/foo"}
+		return &chatCompletionProcessorRouterFilter{
+			config: &processorConfig{
+				router: mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+				rules:  map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule},
+			},
+			requestHeaders: headers,
+			logger:         slog.Default(),
+		}
+	}
+
+	t.Run("clamp", func(t *testing.T) {
+		rp := newRouterFilter()
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(`{"model":"some-model","messages":[],"max_tokens":100000}`)})
+		require.NoError(t, err)
+		re := resp.GetRequestBody().GetResponse()
+		newBody := re.GetBodyMutation().GetBody()
+		require.JSONEq(t, `{"model":"some-model","messages":[],"max_tokens":100}`, string(newBody))
+		setHeaders := re.GetHeaderMutation().SetHeaders
+		require.Equal(t, "content-length", setHeaders[len(setHeaders)-1].Header.Key)
+		require.Equal(t, strconv.Itoa(len(newBody)), string(setHeaders[len(setHeaders)-1].Header.RawValue))
+		require.Equal(t, int64(100), *rp.originalRequestBody.MaxTokens)
+		require.Equal(t, newBody, rp.originalRequestBodyRaw)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		rp := newRouterFilter()
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(`{"model":"some-model","messages":[],"n":20}`)})
+		require.NoError(t, err)
+		require.Equal(t, typev3.StatusCode_BadRequest, resp.GetImmediateResponse().GetStatus().GetCode())
+		require.Nil(t, rp.originalRequestBody)
+	})
+	t.Run("reject body size before parsing", func(t *testing.T) {
+		rp := newRouterFilter()
+		// The body is not even a valid JSON, so this would fail if the body were parsed before the size check.
+		body := []byte(`{"model":"some-model","messages":[` + strings.Repeat("x", 128))
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: body})
+		require.NoError(t, err)
+		require.Equal(t, typev3.StatusCode_BadRequest, resp.GetImmediateResponse().GetStatus().GetCode())
+		require.Contains(t, string(resp.GetImmediateResponse().GetBody()), "request_too_large")
+	})
+}
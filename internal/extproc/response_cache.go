@@ -0,0 +1,363 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/vectorindex"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
+)
+
+// responseCacheHeader is the response header set to "hit" when the response is served from the response cache,
+// or to "semantic-hit" when the response of a similar request is served from the semantic cache.
+const responseCacheHeader = "x-ai-eg-cache"
+
+// ResponseCache is the in-memory LRU cache of the chat completion responses of the rules with
+// [filterapi.RouteRule.ResponseCache] configured.
+//
+// This is shared across the configuration reloads so that the cached responses are not lost.
+type ResponseCache struct {
+	mu      sync.Mutex
+	maxSize int64
+	size    int64
+	entries map[string]*list.Element
+	// lru is the list of the *cachedResponse ordered from the most recently used one.
+	lru *list.List
+	// indexes is the vector indexes of the semantic cache by the namespace of the requests.
+	indexes  map[string]vectorindex.Index
+	newIndex func() vectorindex.Index
+	embed    func(ctx context.Context, config *processorConfig, sc *filterapi.SemanticCache, input string) ([]float32, error)
+	metrics  *metrics.ResponseCache
+	now      func() time.Time
+}
+
+// cachedResponse is a response body stored in the [ResponseCache].
+type cachedResponse struct {
+	key string
+	// namespace is the namespace of the semantic cache the response is indexed in, or empty if not indexed.
+	namespace string
+	body      []byte
+	stream    bool
+	createdAt time.Time
+	expiresAt time.Time
+}
+
+// NewResponseCache creates a new ResponseCache holding up to maxSize bytes of the response bodies.
+// The metrics can be nil to disable the metrics.
+func NewResponseCache(maxSize int64, m *metrics.ResponseCache) *ResponseCache {
+	return &ResponseCache{
+		maxSize:  maxSize,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		indexes:  make(map[string]vectorindex.Index),
+		newIndex: func() vectorindex.Index { return vectorindex.NewHNSW() },
+		embed:    embed,
+		metrics:  m,
+		now:      time.Now,
+	}
+}
+
+// get returns the response cached for the key that is not older than maxAge, or nil if there's none.
+// A non-positive maxAge means no limit other than the TTL.
+func (r *ResponseCache) get(key string, maxAge time.Duration) *cachedResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.getLocked(key, maxAge)
+}
+
+// searchSemantic returns the response cached for the request whose final user message is the most similar to
+// the vector in the namespace, if the similarity is at least the threshold.
+func (r *ResponseCache) searchSemantic(namespace string, vector []float32, threshold float64, maxAge time.Duration) *cachedResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	idx, ok := r.indexes[namespace]
+	if !ok {
+		return nil
+	}
+	key, similarity := idx.Search(vector)
+	if key == "" || float64(similarity) < threshold {
+		return nil
+	}
+	return r.getLocked(key, maxAge)
+}
+
+func (r *ResponseCache) getLocked(key string, maxAge time.Duration) *cachedResponse {
+	e, ok := r.entries[key]
+	if !ok {
+		return nil
+	}
+	cr := e.Value.(*cachedResponse)
+	now := r.now()
+	if now.After(cr.expiresAt) {
+		r.removeLocked(e)
+		return nil
+	}
+	if maxAge > 0 && now.Sub(cr.createdAt) > maxAge {
+		return nil
+	}
+	r.lru.MoveToFront(e)
+	return cr
+}
+
+// put stores the response for the key, evicting the least recently used responses to stay within the maximum size.
+// When the namespace is not empty, the response is also indexed by the vector in the namespace of the semantic cache.
+func (r *ResponseCache) put(ctx context.Context, key string, body []byte, stream bool, ttl time.Duration, namespace string, vector []float32) {
+	size := entrySize(key, body)
+	if size > r.maxSize {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.entries[key]; ok {
+		r.removeLocked(e)
+	}
+	for r.size+size > r.maxSize {
+		r.removeLocked(r.lru.Back())
+	}
+	now := r.now()
+	r.entries[key] = r.lru.PushFront(&cachedResponse{
+		key: key, namespace: namespace, body: body, stream: stream, createdAt: now, expiresAt: now.Add(ttl),
+	})
+	r.size += size
+	if namespace != "" {
+		idx, ok := r.indexes[namespace]
+		if !ok {
+			idx = r.newIndex()
+			r.indexes[namespace] = idx
+		}
+		idx.Add(key, vector)
+	}
+	if r.metrics != nil {
+		r.metrics.RecordSize(ctx, r.size)
+	}
+}
+
+func (r *ResponseCache) removeLocked(e *list.Element) {
+	cr := r.lru.Remove(e).(*cachedResponse)
+	delete(r.entries, cr.key)
+	r.size -= entrySize(cr.key, cr.body)
+	if idx, ok := r.indexes[cr.namespace]; ok {
+		idx.Remove(cr.key)
+		if idx.Len() == 0 {
+			delete(r.indexes, cr.namespace)
+		}
+	}
+}
+
+func entrySize(key string, body []byte) int64 {
+	return int64(len(key) + len(body))
+}
+
+// cacheControl is the directives of the Cache-Control request header relevant to the response cache.
+type cacheControl struct {
+	noCache, noStore bool
+	maxAge           time.Duration
+}
+
+func parseCacheControl(v string) (cc cacheControl) {
+	for _, d := range strings.Split(v, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		switch {
+		case d == "no-cache":
+			cc.noCache = true
+		case d == "no-store":
+			cc.noStore = true
+		case strings.HasPrefix(d, "max-age="):
+			if secs, err := strconv.Atoi(strings.TrimPrefix(d, "max-age=")); err == nil {
+				if secs == 0 {
+					// max-age=0 means the client does not accept any cached response.
+					cc.noCache = true
+				}
+				cc.maxAge = time.Duration(secs) * time.Second
+			}
+		}
+	}
+	return
+}
+
+// responseCacheKey returns the key of the request in the response cache, which is the hash of the route rule,
+// the model, the consumer and the normalized request body. The consumer is the one authenticated with the consumer
+// key if any, and the value of the consumer header of the rule if configured. This returns an empty key if
+// the request is not cacheable under the given configuration.
+//
+// When the semantic cache is configured and the request ends with a text user message, this also returns the
+// namespace of the request in the semantic cache, which is the hash of the same except for the final user message.
+// The semantic cache is only used for the authenticated consumer unless [filterapi.SemanticCache.AllowUnauthenticated]
+// is set, since a similar prompt is much easier to guess than an identical one.
+func responseCacheKey(rc *filterapi.ResponseCache, routeName filterapi.RouteRuleName, model, authenticatedConsumer string,
+	requestHeaders map[string]string, raw []byte, body *openai.ChatCompletionRequest,
+) (key, namespace string, err error) {
+	if !rc.AllTemperatures && (body.Temperature == nil || *body.Temperature != 0) {
+		return "", "", nil
+	}
+	// Re-encoding the body sorts the keys of the objects and removes the insignificant whitespaces,
+	// while json.Number preserves the numbers as they are.
+	var v map[string]any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err = dec.Decode(&v); err != nil {
+		return "", "", fmt.Errorf("failed to normalize request body: %w", err)
+	}
+	var consumer string
+	if rc.ConsumerHeader != "" {
+		consumer = requestHeaders[strings.ToLower(rc.ConsumerHeader)]
+	}
+	if key, err = hashRequest(v, string(routeName), model, authenticatedConsumer, consumer); err != nil {
+		return "", "", err
+	}
+	if rc.Semantic != nil && (authenticatedConsumer != "" || rc.Semantic.AllowUnauthenticated) && semanticCacheInput(body) != "" {
+		messages, _ := v["messages"].([]any)
+		v["messages"] = messages[:len(messages)-1]
+		if namespace, err = hashRequest(v, string(routeName), model, authenticatedConsumer, consumer, rc.Semantic.Model); err != nil {
+			return "", "", err
+		}
+	}
+	return
+}
+
+// hashRequest returns the hash of the request body together with the given strings.
+func hashRequest(body map[string]any, strs ...string) (string, error) {
+	normalized, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to normalize request body: %w", err)
+	}
+	h := sha256.New()
+	for _, s := range strs {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	h.Write(normalized)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// lookupResponseCache looks up the response cache of the rule for the request, and returns the immediate response
+// with the cached response if any. Otherwise, the key of the request is remembered to store the response.
+// The semantic cache is only used if semantic is true.
+func (c *chatCompletionProcessorRouterFilter) lookupResponseCache(ctx context.Context, rule *filterapi.RouteRule, model string,
+	raw []byte, body *openai.ChatCompletionRequest, semantic bool,
+) (*extprocv3.ProcessingResponse, error) {
+	c.cacheKey = ""
+	rc := rule.ResponseCache
+	if c.responseCache == nil || rc == nil {
+		return nil, nil
+	}
+	key, namespace, err := responseCacheKey(rc, rule.Name, model, c.consumer, c.requestHeaders, raw, body)
+	if err != nil || key == "" {
+		return nil, err
+	}
+	if !semantic {
+		namespace = ""
+	}
+	var cc cacheControl
+	if !rc.IgnoreCacheControl {
+		cc = parseCacheControl(c.requestHeaders["cache-control"])
+	}
+	if !cc.noCache {
+		if cr := c.responseCache.get(key, cc.maxAge); cr != nil {
+			return c.responseCacheHit(ctx, rule, cr, metrics.ResponseCacheHit), nil
+		}
+	}
+	var vector []float32
+	if namespace != "" && (!cc.noCache || !cc.noStore) {
+		// The semantic cache is skipped if the embeddings backend is not available, not to fail the request.
+		vector, err = c.responseCache.embed(ctx, c.config, rc.Semantic, semanticCacheInput(body))
+		if err != nil {
+			c.logger.Warn("failed to embed the request for the semantic cache", "route", rule.Name, "error", err)
+			namespace = ""
+		} else if !cc.noCache {
+			if cr := c.responseCache.searchSemantic(namespace, vector, rc.Semantic.Threshold, cc.maxAge); cr != nil {
+				return c.responseCacheHit(ctx, rule, cr, metrics.ResponseCacheSemanticHit), nil
+			}
+		}
+	}
+	if !cc.noCache && c.responseCache.metrics != nil {
+		c.responseCache.metrics.RecordLookup(ctx, string(rule.Name), metrics.ResponseCacheMiss)
+	}
+	if !cc.noStore {
+		c.cacheKey, c.cacheTTL, c.cacheBody = key, rc.TTL, nil
+		c.cacheNamespace, c.cacheVector = namespace, vector
+	}
+	return nil, nil
+}
+
+// responseCacheHit records the hit of the response cache, and returns the immediate response replaying the cached response.
+func (c *chatCompletionProcessorRouterFilter) responseCacheHit(ctx context.Context, rule *filterapi.RouteRule,
+	cr *cachedResponse, result metrics.ResponseCacheResult,
+) *extprocv3.ProcessingResponse {
+	if c.responseCache.metrics != nil {
+		c.responseCache.metrics.RecordLookup(ctx, string(rule.Name), result)
+	}
+	hit := "hit"
+	if result == metrics.ResponseCacheSemanticHit {
+		hit = "semantic-hit"
+	}
+	return cachedResponseToImmediateResponse(cr, c.responseCache.now(), hit)
+}
+
+// checkResponseCacheable stops storing the response if it is not successful, is encoded, or must not be stored.
+func (c *chatCompletionProcessorRouterFilter) checkResponseCacheable(headers map[string]string) {
+	if headers[":status"] != "200" || headers["content-encoding"] != "" || parseCacheControl(headers["cache-control"]).noStore {
+		c.cacheKey = ""
+	}
+}
+
+// appendResponseCacheBody appends the chunk of the response body sent to the client, and stores the response to
+// the cache at the end of the stream.
+func (c *chatCompletionProcessorRouterFilter) appendResponseCacheBody(ctx context.Context, resp *extprocv3.ProcessingResponse, body *extprocv3.HttpBody) {
+	if b := resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody(); b != nil {
+		c.cacheBody = append(c.cacheBody, b...)
+	} else {
+		c.cacheBody = append(c.cacheBody, body.Body...)
+	}
+	if int64(len(c.cacheBody)) > c.responseCache.maxSize {
+		// The response would never fit into the cache, so stop buffering it.
+		c.cacheKey, c.cacheBody = "", nil
+		return
+	}
+	if body.EndOfStream {
+		c.responseCache.put(ctx, c.cacheKey, c.cacheBody, c.originalRequestBody.Stream, c.cacheTTL, c.cacheNamespace, c.cacheVector)
+		c.cacheKey, c.cacheBody = "", nil
+	}
+}
+
+// cachedResponseToImmediateResponse returns the immediate response that replays the cached response
+// with the given value of the [responseCacheHeader].
+func cachedResponseToImmediateResponse(cr *cachedResponse, now time.Time, hit string) *extprocv3.ProcessingResponse {
+	contentType := "application/json"
+	if cr.stream {
+		contentType = "text/event-stream"
+	}
+	headers := &extprocv3.HeaderMutation{}
+	setHeader(headers, "content-type", contentType)
+	setHeader(headers, "content-length", strconv.Itoa(len(cr.body)))
+	setHeader(headers, responseCacheHeader, hit)
+	setHeader(headers, "age", strconv.Itoa(int(now.Sub(cr.createdAt).Seconds())))
+	return &extprocv3.ProcessingResponse{
+		Response: &extprocv3.ProcessingResponse_ImmediateResponse{
+			ImmediateResponse: &extprocv3.ImmediateResponse{
+				Status:  &typev3.HttpStatus{Code: typev3.StatusCode_OK},
+				Headers: headers,
+				Body:    cr.body,
+			},
+		},
+	}
+}
@@ -0,0 +1,435 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/backendauth"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/redaction"
+)
+
+func TestResponseCache(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rc := NewResponseCache(100, nil)
+	rc.now = func() time.Time { return now }
+
+	t.Run("ttl and max age", func(t *testing.T) {
+		rc.put(t.Context(), "a", []byte("0123456789"), false, time.Minute, "", nil)
+		require.Equal(t, []byte("0123456789"), rc.get("a", 0).body)
+		now = now.Add(30 * time.Second)
+		require.Nil(t, rc.get("a", 10*time.Second))
+		require.NotNil(t, rc.get("a", time.Minute))
+		now = now.Add(31 * time.Second)
+		require.Nil(t, rc.get("a", 0))
+		require.Zero(t, rc.size)
+		require.Empty(t, rc.entries)
+	})
+
+	t.Run("lru eviction", func(t *testing.T) {
+		body := make([]byte, 39)
+		rc.put(t.Context(), "a", body, false, time.Minute, "", nil)
+		rc.put(t.Context(), "b", body, false, time.Minute, "", nil)
+		// Using "a" makes "b" the least recently used one.
+		require.NotNil(t, rc.get("a", 0))
+		rc.put(t.Context(), "c", body, true, time.Minute, "", nil)
+		require.NotNil(t, rc.get("a", 0))
+		require.Nil(t, rc.get("b", 0))
+		require.True(t, rc.get("c", 0).stream)
+		require.Equal(t, int64(80), rc.size)
+
+		// Replacing an entry does not double count its size.
+		rc.put(t.Context(), "c", body, true, time.Minute, "", nil)
+		require.Equal(t, int64(80), rc.size)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		rc.put(t.Context(), "d", make([]byte, 100), false, time.Minute, "", nil)
+		require.Nil(t, rc.get("d", 0))
+		require.NotNil(t, rc.get("a", 0))
+	})
+
+	t.Run("semantic", func(t *testing.T) {
+		rc := NewResponseCache(100, nil)
+		rc.now = func() time.Time { return now }
+		rc.put(t.Context(), "x", make([]byte, 30), false, time.Minute, "ns1", []float32{1, 0})
+		rc.put(t.Context(), "y", make([]byte, 30), false, 2*time.Minute, "ns1", []float32{0, 1})
+		rc.put(t.Context(), "z", make([]byte, 30), false, time.Minute, "ns2", []float32{1, 0})
+		require.Len(t, rc.indexes, 2)
+
+		require.Equal(t, "x", rc.searchSemantic("ns1", []float32{0.9, 0.1}, 0.9, 0).key)
+		require.Nil(t, rc.searchSemantic("ns1", []float32{0.5, 0.5}, 0.9, 0))
+		require.Equal(t, "z", rc.searchSemantic("ns2", []float32{0.9, 0.1}, 0.9, 0).key)
+		require.Nil(t, rc.searchSemantic("ns3", []float32{0.9, 0.1}, 0.9, 0))
+
+		// The expired responses are removed from the index.
+		now = now.Add(90 * time.Second)
+		require.Nil(t, rc.searchSemantic("ns1", []float32{0.9, 0.1}, 0.9, 0))
+		require.Equal(t, 1, rc.indexes["ns1"].Len())
+		// The evicted responses are removed from the index, and so are the empty indexes.
+		require.NotNil(t, rc.get("y", 0))
+		rc.put(t.Context(), "w", make([]byte, 60), false, time.Minute, "", nil)
+		require.Nil(t, rc.get("z", 0))
+		require.NotContains(t, rc.indexes, "ns2")
+		require.Equal(t, "y", rc.searchSemantic("ns1", []float32{0, 1}, 0.9, 0).key)
+	})
+}
+
+func Test_parseCacheControl(t *testing.T) {
+	require.Equal(t, cacheControl{}, parseCacheControl(""))
+	require.Equal(t, cacheControl{noCache: true}, parseCacheControl("No-Cache"))
+	require.Equal(t, cacheControl{noStore: true, maxAge: time.Minute}, parseCacheControl("no-store, max-age=60"))
+	require.Equal(t, cacheControl{noCache: true}, parseCacheControl("max-age=0"))
+	require.Equal(t, cacheControl{}, parseCacheControl("max-age=invalid"))
+}
+
+func Test_responseCacheKey(t *testing.T) {
+	consumerKeys := func(rc *filterapi.ResponseCache, model, consumer string, headers map[string]string, raw string) (string, string) {
+		var body openai.ChatCompletionRequest
+		require.NoError(t, json.Unmarshal([]byte(raw), &body))
+		k, ns, err := responseCacheKey(rc, "route", model, consumer, headers, []byte(raw), &body)
+		require.NoError(t, err)
+		return k, ns
+	}
+	keys := func(rc *filterapi.ResponseCache, model string, headers map[string]string, raw string) (string, string) {
+		return consumerKeys(rc, model, "", headers, raw)
+	}
+	key := func(rc *filterapi.ResponseCache, model string, headers map[string]string, raw string) string {
+		k, _ := keys(rc, model, headers, raw)
+		return k
+	}
+	rc := &filterapi.ResponseCache{ConsumerHeader: "X-Team"}
+	const body = `{"model":"m","temperature":0,"messages":[{"role":"user","content":"hi"}]}`
+	base := key(rc, "m", map[string]string{"x-team": "a"}, body)
+	require.Len(t, base, 64)
+
+	// The order of the keys and the whitespaces do not matter.
+	require.Equal(t, base, key(rc, "m", map[string]string{"x-team": "a"},
+		`{ "messages": [{"content": "hi", "role": "user"}], "temperature": 0, "model": "m" }`))
+	require.NotEqual(t, base, key(rc, "m", map[string]string{"x-team": "b"}, body))
+	require.NotEqual(t, base, key(rc, "other", map[string]string{"x-team": "a"}, body))
+	require.NotEqual(t, base, key(rc, "m", map[string]string{"x-team": "a"},
+		`{"model":"m","temperature":0,"messages":[{"role":"user","content":"hello"}]}`))
+
+	t.Run("authenticated consumer", func(t *testing.T) {
+		// The authenticated consumer always scopes the key even without the consumer header.
+		rc := &filterapi.ResponseCache{}
+		k1, _ := consumerKeys(rc, "m", "alice", nil, body)
+		k2, _ := consumerKeys(rc, "m", "bob", nil, body)
+		require.NotEqual(t, k1, k2)
+		require.NotEqual(t, k1, key(rc, "m", nil, body))
+		// The consumer header cannot be used to impersonate the authenticated consumer.
+		k3, _ := consumerKeys(&filterapi.ResponseCache{ConsumerHeader: "x-team"}, "m", "", map[string]string{"x-team": "alice"}, body)
+		require.NotEqual(t, k1, k3)
+	})
+
+	t.Run("temperature", func(t *testing.T) {
+		const nonZero = `{"model":"m","temperature":0.7,"messages":[]}`
+		require.Empty(t, key(rc, "m", nil, nonZero))
+		require.Empty(t, key(rc, "m", nil, `{"model":"m","messages":[]}`))
+		require.NotEmpty(t, key(&filterapi.ResponseCache{AllTemperatures: true}, "m", nil, nonZero))
+	})
+
+	t.Run("semantic namespace", func(t *testing.T) {
+		rc := &filterapi.ResponseCache{ConsumerHeader: "x-team", Semantic: &filterapi.SemanticCache{Model: "embed", AllowUnauthenticated: true}}
+		const prefix = `{"model":"m","temperature":0,"messages":[{"role":"system","content":"be brief"},`
+		k1, ns1 := keys(rc, "m", map[string]string{"x-team": "a"}, prefix+`{"role":"user","content":"hi"}]}`)
+		k2, ns2 := keys(rc, "m", map[string]string{"x-team": "a"}, prefix+`{"role":"user","content":"hello"}]}`)
+		require.NotEqual(t, k1, k2)
+		require.Len(t, ns1, 64)
+		// Only the final user message may differ in the same namespace.
+		require.Equal(t, ns1, ns2)
+		_, ns := keys(rc, "m", map[string]string{"x-team": "b"}, prefix+`{"role":"user","content":"hi"}]}`)
+		require.NotEqual(t, ns1, ns)
+		_, ns = keys(rc, "m", map[string]string{"x-team": "a"},
+			`{"model":"m","temperature":0,"messages":[{"role":"system","content":"be verbose"},{"role":"user","content":"hi"}]}`)
+		require.NotEqual(t, ns1, ns)
+		_, ns = keys(rc, "m", map[string]string{"x-team": "a"}, prefix+`{"role":"user","content":"hi"}],"max_tokens":10}`)
+		require.NotEqual(t, ns1, ns)
+		// The namespace is different from the exact key of the request without the final user message.
+		require.NotEqual(t, ns1, key(rc, "m", map[string]string{"x-team": "a"}, prefix[:len(prefix)-1]+`]}`))
+
+		_, ns = keys(rc, "m", nil, prefix+`{"role":"assistant","content":"hi"}]}`)
+		require.Empty(t, ns)
+		_, ns = keys(&filterapi.ResponseCache{}, "m", nil, prefix+`{"role":"user","content":"hi"}]}`)
+		require.Empty(t, ns)
+		// The semantic cache requires the authenticated consumer unless explicitly allowed.
+		rc = &filterapi.ResponseCache{ConsumerHeader: "x-team", Semantic: &filterapi.SemanticCache{Model: "embed"}}
+		_, ns = keys(rc, "m", map[string]string{"x-team": "a"}, prefix+`{"role":"user","content":"hi"}]}`)
+		require.Empty(t, ns)
+		_, nsAlice := consumerKeys(rc, "m", "alice", nil, prefix+`{"role":"user","content":"hi"}]}`)
+		require.Len(t, nsAlice, 64)
+		_, nsBob := consumerKeys(rc, "m", "bob", nil, prefix+`{"role":"user","content":"hi"}]}`)
+		require.NotEqual(t, nsAlice, nsBob)
+	})
+}
+
+func Test_semanticCacheInput(t *testing.T) {
+	for _, tc := range []struct {
+		name, messages, exp string
+	}{
+		{name: "empty", messages: `[]`},
+		{name: "string", messages: `[{"role":"system","content":"s"},{"role":"user","content":"hi"}]`, exp: "hi"},
+		{name: "text parts", messages: `[{"role":"user","content":[{"type":"text","text":"a"},{"type":"text","text":"b"}]}]`, exp: "a\nb"},
+		{name: "image", messages: `[{"role":"user","content":[{"type":"text","text":"a"},{"type":"image_url","image_url":{"url":"https://x"}}]}]`},
+		{name: "not user", messages: `[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"}]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var body openai.ChatCompletionRequest
+			require.NoError(t, json.Unmarshal([]byte(`{"model":"m","messages":`+tc.messages+`}`), &body))
+			require.Equal(t, tc.exp, semanticCacheInput(&body))
+		})
+	}
+}
+
+func Test_embed(t *testing.T) {
+	var status int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"model":"embed","input":"hi"}`, string(body))
+		require.Equal(t, "/v1/embeddings", r.URL.Path)
+		require.Equal(t, "Bearer sk-embed", r.Header.Get("Authorization"))
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.5,-0.25]}]}`))
+	}))
+	defer srv.Close()
+	b := &filterapi.Backend{Name: "embeddings", Auth: &filterapi.BackendAuth{APIKey: &filterapi.APIKeyAuth{Key: "sk-embed"}}}
+	h, err := backendauth.NewHandler(t.Context(), b.Auth)
+	require.NoError(t, err)
+	config := &processorConfig{backends: map[string]*processorConfigBackend{b.Name: {b: b, handler: h}}}
+	sc := &filterapi.SemanticCache{Backend: *b, URL: srv.URL, Path: "/v1/embeddings", Model: "embed"}
+
+	status = http.StatusOK
+	vector, err := embed(t.Context(), config, sc, "hi")
+	require.NoError(t, err)
+	require.Equal(t, []float32{0.5, -0.25}, vector)
+
+	status = http.StatusUnauthorized
+	_, err = embed(t.Context(), config, sc, "hi")
+	require.ErrorContains(t, err, "embeddings request failed with status 401")
+
+	t.Run("timeout", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { time.Sleep(time.Second) }))
+		defer slow.Close()
+		sc := &filterapi.SemanticCache{URL: slow.URL, Path: "/v1/embeddings", Model: "embed", Timeout: 10 * time.Millisecond}
+		_, err := embed(t.Context(), &processorConfig{}, sc, "hi")
+		require.ErrorContains(t, err, "context deadline exceeded")
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[1]}]}`))
+		}))
+		defer tlsServer.Close()
+		caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}))
+		sc := &filterapi.SemanticCache{Backend: filterapi.Backend{Name: "embeddings"}, URL: tlsServer.URL, Path: "/v1/embeddings", Model: "embed"}
+		// The default client does not trust the private CA of the embeddings backend.
+		_, err := embed(t.Context(), &processorConfig{}, sc, "hi")
+		require.ErrorContains(t, err, "certificate signed by unknown authority")
+
+		// The certificate of the test server is valid for example.com.
+		client, err := newDirectBackendHTTPClient(&filterapi.BackendTLS{Hostname: "example.com", CACertificates: caCert}, semanticCacheHTTPClient)
+		require.NoError(t, err)
+		vector, err := embed(t.Context(), &processorConfig{semanticCacheClients: map[string]*http.Client{"embeddings": client}}, sc, "hi")
+		require.NoError(t, err)
+		require.Equal(t, []float32{1}, vector)
+	})
+}
+
+func TestChatCompletion_responseCache(t *testing.T) {
+	rule := &filterapi.RouteRule{Name: "some-route", ResponseCache: &filterapi.ResponseCache{TTL: time.Minute}}
+	config := &processorConfig{
+		rules: map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule},
+	}
+	rc := NewResponseCache(1<<20, nil)
+
+	// send sends the request through the router filter, and the response through the upstream filter
+	// if the request is not served from the cache.
+	send := func(t *testing.T, headers map[string]string, reqBody, status, respBody string) *extprocv3.ProcessingResponse {
+		headers[":path"] = "/v1/chat/completions"
+		config.router = mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		rp := &chatCompletionProcessorRouterFilter{
+			config:         config,
+			requestHeaders: headers,
+			logger:         slog.Default(),
+			responseCache:  rc,
+		}
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(reqBody)})
+		require.NoError(t, err)
+		if resp.GetImmediateResponse() != nil {
+			return resp
+		}
+		uf := &chatCompletionProcessorUpstreamFilter{
+			config:         config,
+			requestHeaders: map[string]string{":path": "/v1/chat/completions"},
+			logger:         slog.Default(),
+			metrics:        &mockChatCompletionMetrics{},
+		}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, rp))
+		_, err = uf.ProcessRequestHeaders(t.Context(), nil)
+		require.NoError(t, err)
+		_, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: status}}})
+		require.NoError(t, err)
+		// Split the streamed body into two chunks to make sure that the whole body is cached.
+		half := 0
+		if rp.originalRequestBody.Stream {
+			half = len(respBody) / 2
+		}
+		if half > 0 {
+			_, err = rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{Body: []byte(respBody[:half])})
+			require.NoError(t, err)
+		}
+		_, err = rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{Body: []byte(respBody[half:]), EndOfStream: true})
+		require.NoError(t, err)
+		return resp
+	}
+	requireHit := func(t *testing.T, resp *extprocv3.ProcessingResponse, expBody, expContentType, expHit string) {
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_OK, ir.GetStatus().GetCode())
+		require.Equal(t, expBody, string(ir.GetBody()))
+		headers := map[string]string{}
+		for _, h := range ir.GetHeaders().GetSetHeaders() {
+			headers[h.Header.Key] = string(h.Header.RawValue)
+		}
+		require.Equal(t, expContentType, headers["content-type"])
+		require.Equal(t, expHit, headers[responseCacheHeader])
+		require.Equal(t, "0", headers["age"])
+	}
+	const jsonResp = `{"choices":[{"message":{"content":"hello"}}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`
+
+	t.Run("json", func(t *testing.T) {
+		const req = `{"model":"m","temperature":0,"messages":[{"role":"user","content":"json"}]}`
+		require.Nil(t, send(t, map[string]string{}, req, "200", jsonResp).GetImmediateResponse())
+		requireHit(t, send(t, map[string]string{}, req, "200", jsonResp), jsonResp, "application/json", "hit")
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		const req = `{"model":"m","temperature":0,"stream":true,"messages":[{"role":"user","content":"stream"}]}`
+		const sse = "data: {\"choices\":[{\"delta\":{\"content\":\"hello\"}}]}\n\ndata: [DONE]\n\n"
+		require.Nil(t, send(t, map[string]string{}, req, "200", sse).GetImmediateResponse())
+		requireHit(t, send(t, map[string]string{}, req, "200", sse), sse, "text/event-stream", "hit")
+	})
+
+	t.Run("not cached", func(t *testing.T) {
+		const errorReq = `{"model":"m","temperature":0,"messages":[{"role":"user","content":"error"}]}`
+		send(t, map[string]string{}, errorReq, "500", `{"error":{}}`)
+		require.Nil(t, send(t, map[string]string{}, errorReq, "200", jsonResp).GetImmediateResponse())
+
+		const noStoreReq = `{"model":"m","temperature":0,"messages":[{"role":"user","content":"no-store"}]}`
+		send(t, map[string]string{"cache-control": "no-store"}, noStoreReq, "200", jsonResp)
+		require.Nil(t, send(t, map[string]string{"cache-control": "no-cache"}, noStoreReq, "200", jsonResp).GetImmediateResponse())
+		// The response of the "no-cache" request is still stored.
+		requireHit(t, send(t, map[string]string{}, noStoreReq, "200", jsonResp), jsonResp, "application/json", "hit")
+
+		const nonZeroReq = `{"model":"m","temperature":1,"messages":[{"role":"user","content":"non-zero"}]}`
+		send(t, map[string]string{}, nonZeroReq, "200", jsonResp)
+		require.Nil(t, send(t, map[string]string{}, nonZeroReq, "200", jsonResp).GetImmediateResponse())
+	})
+	t.Run("semantic", func(t *testing.T) {
+		rule.ResponseCache.Semantic = &filterapi.SemanticCache{Model: "embed", Threshold: 0.9, AllowUnauthenticated: true}
+		t.Cleanup(func() { rule.ResponseCache.Semantic = nil })
+		rc.embed = func(_ context.Context, _ *processorConfig, sc *filterapi.SemanticCache, input string) ([]float32, error) {
+			require.Equal(t, "embed", sc.Model)
+			switch input {
+			case "What is the capital of France?":
+				return []float32{1, 0, 0}, nil
+			case "what's the capital of france":
+				return []float32{0.95, 0.1, 0}, nil
+			case "How tall is the Eiffel Tower?":
+				return []float32{0.5, 0.8, 0}, nil
+			}
+			return nil, errors.New("embeddings endpoint is down")
+		}
+		req := func(system, user string) string {
+			return `{"model":"m","temperature":0,"messages":[{"role":"system","content":"` + system + `"},{"role":"user","content":"` + user + `"}]}`
+		}
+		team := func(name string) map[string]string { return map[string]string{"x-team": name} }
+		rule.ResponseCache.ConsumerHeader = "x-team"
+		t.Cleanup(func() { rule.ResponseCache.ConsumerHeader = "" })
+
+		require.Nil(t, send(t, team("a"), req("s", "What is the capital of France?"), "200", jsonResp).GetImmediateResponse())
+		requireHit(t, send(t, team("a"), req("s", "what's the capital of france"), "200", jsonResp), jsonResp, "application/json", "semantic-hit")
+		// Dissimilar questions, other system prompts and other consumers do not hit.
+		require.Nil(t, send(t, team("a"), req("s", "How tall is the Eiffel Tower?"), "200", `{}`).GetImmediateResponse())
+		require.Nil(t, send(t, team("a"), req("other", "what's the capital of france"), "200", `{}`).GetImmediateResponse())
+		require.Nil(t, send(t, team("b"), req("s", "what's the capital of france"), "200", `{}`).GetImmediateResponse())
+		// The request is still served when the embeddings endpoint fails.
+		require.Nil(t, send(t, team("a"), req("s", "unknown"), "200", jsonResp).GetImmediateResponse())
+		requireHit(t, send(t, team("a"), req("s", "unknown"), "200", jsonResp), jsonResp, "application/json", "hit")
+	})
+
+	t.Run("redaction", func(t *testing.T) {
+		rule.ResponseCache.Semantic = &filterapi.SemanticCache{Model: "embed", Threshold: 0.9, AllowUnauthenticated: true}
+		config.redactors = map[filterapi.RouteRuleName]*redaction.Redactor{"some-route": newTestRedactor(t, false)}
+		t.Cleanup(func() { rule.ResponseCache.Semantic, config.redactors = nil, nil })
+		var embedded []string
+		rc.embed = func(_ context.Context, _ *processorConfig, _ *filterapi.SemanticCache, input string) ([]float32, error) {
+			embedded = append(embedded, input)
+			return []float32{1, 0}, nil
+		}
+		req := func(user string) string {
+			return `{"model":"m","temperature":0,"messages":[{"role":"user","content":"` + user + `"}]}`
+		}
+
+		// The request with the PII skips the semantic cache, but is still served from the exact match.
+		require.Nil(t, send(t, map[string]string{}, req("mail a@example.com"), "200", jsonResp).GetImmediateResponse())
+		requireHit(t, send(t, map[string]string{}, req("mail a@example.com"), "200", jsonResp), jsonResp, "application/json", "hit")
+		require.Nil(t, send(t, map[string]string{}, req("mail b@example.com"), "200", jsonResp).GetImmediateResponse())
+		require.Empty(t, embedded)
+
+		// The request rejected by the redaction is never served from the cache.
+		ir := send(t, map[string]string{}, req("card 4111 1111 1111 1111"), "200", jsonResp).GetImmediateResponse()
+		require.Equal(t, typev3.StatusCode_BadRequest, ir.GetStatus().GetCode())
+
+		// The request without the PII uses the semantic cache as usual.
+		require.Nil(t, send(t, map[string]string{}, req("hello"), "200", jsonResp).GetImmediateResponse())
+		require.Equal(t, []string{"hello"}, embedded)
+	})
+	t.Run("guardrails", func(t *testing.T) {
+		s, inputs := newModerationServer(t, `{"results":[{"flagged":true,"category_scores":{"violence":0.9}}]}`)
+		t.Cleanup(func() { rule.Guardrails = nil })
+		const req = `{"model":"m","temperature":0,"messages":[{"role":"user","content":"bad prompt"}]}`
+		// The response is cached before the guardrail exists.
+		require.Nil(t, send(t, map[string]string{}, req, "200", jsonResp).GetImmediateResponse())
+
+		// The prompt is screened before the lookup, so the blocked prompt is never served from the cache.
+		rule.Guardrails = []filterapi.Guardrail{{
+			Name: "g", URL: s.URL, Path: "/v1/moderations", Type: filterapi.GuardrailTypeOpenAIModeration,
+			Input: true, Action: filterapi.GuardrailActionBlock,
+		}}
+		ir := send(t, map[string]string{}, req, "200", jsonResp).GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Contains(t, string(ir.GetBody()), `"finish_reason":"content_filter"`)
+		require.Equal(t, "bad prompt", (<-inputs)["input"])
+
+		// The flagged prompt is served from the cache with the flagged header.
+		rule.Guardrails[0].Action = filterapi.GuardrailActionFlag
+		resp := send(t, map[string]string{}, req, "200", jsonResp)
+		requireHit(t, resp, jsonResp, "application/json", "hit")
+		require.Equal(t, "g", headersOf(resp.GetImmediateResponse().GetHeaders())[guardrailFlaggedHeader])
+		<-inputs
+	})
+}
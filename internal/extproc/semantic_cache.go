@@ -0,0 +1,116 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+)
+
+const (
+	// defaultSemanticCacheTimeout is the default timeout of the embeddings requests of the semantic cache, which is
+	// kept short since the chat completion request waits for it.
+	defaultSemanticCacheTimeout = 2 * time.Second
+	// maxEmbeddingsResponseBodySize is the maximum size of the response body of the embeddings backends.
+	maxEmbeddingsResponseBodySize = 1 << 20
+)
+
+// semanticCacheHTTPClient is the HTTP client used to call the embeddings backends without TLS configuration.
+var semanticCacheHTTPClient = &http.Client{}
+
+// embed returns the embedding vector of the input through the embeddings backend configured in
+// [filterapi.SemanticCache], authenticated in the same way as the backends of the rules.
+func embed(ctx context.Context, config *processorConfig, sc *filterapi.SemanticCache, input string) ([]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, cmp.Or(sc.Timeout, defaultSemanticCacheTimeout))
+	defer cancel()
+
+	raw, err := json.Marshal(map[string]string{"model": sc.Model, "input": input})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embeddings request: %w", err)
+	}
+	headers := map[string]string{":method": http.MethodPost, ":path": sc.Path, "content-type": "application/json"}
+	if b, ok := config.backends[sc.Backend.Name]; ok && b.handler != nil {
+		headerMutation := &extprocv3.HeaderMutation{}
+		bodyMutation := &extprocv3.BodyMutation{Mutation: &extprocv3.BodyMutation_Body{Body: raw}}
+		if err = b.handler.Do(ctx, headers, headerMutation, bodyMutation); err != nil {
+			return nil, fmt.Errorf("failed to do auth request: %w", err)
+		}
+		applyHeaderMutation(headers, headerMutation)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sc.URL+headers[":path"], bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings request: %w", err)
+	}
+	for k, v := range headers {
+		if strings.HasPrefix(k, ":") || strings.EqualFold(k, "content-length") {
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	resp, err := cmp.Or(config.semanticCacheClients[sc.Backend.Name], semanticCacheHTTPClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send embeddings request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxEmbeddingsResponseBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embeddings response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embeddings request failed with status %d: %s", resp.StatusCode, respBody)
+	}
+	var embeddings openai.EmbeddingResponse
+	if err = json.Unmarshal(respBody, &embeddings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal embeddings response: %w", err)
+	}
+	if len(embeddings.Data) == 0 || len(embeddings.Data[0].Embedding) == 0 {
+		return nil, fmt.Errorf("embeddings response has no embedding")
+	}
+	vector := make([]float32, len(embeddings.Data[0].Embedding))
+	for i, v := range embeddings.Data[0].Embedding {
+		vector[i] = float32(v)
+	}
+	return vector, nil
+}
+
+// semanticCacheInput returns the text of the final message of the request if it is a user message,
+// which is what the semantic cache compares. This returns an empty string if the final message is not
+// a user message or has a content other than text, e.g. images.
+func semanticCacheInput(body *openai.ChatCompletionRequest) string {
+	if len(body.Messages) == 0 {
+		return ""
+	}
+	msg, ok := body.Messages[len(body.Messages)-1].Value.(openai.ChatCompletionUserMessageParam)
+	if !ok {
+		return ""
+	}
+	switch content := msg.Content.Value.(type) {
+	case string:
+		return content
+	case []openai.ChatCompletionContentPartUserUnionParam:
+		texts := make([]string, 0, len(content))
+		for i := range content {
+			if content[i].TextContent == nil {
+				return ""
+			}
+			texts = append(texts, content[i].TextContent.Text)
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
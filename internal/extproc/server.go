@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
+	"regexp"
 	"slices"
 	"strings"
 	"sync"
@@ -27,7 +29,9 @@ import (
 
 	"github.com/envoyproxy/ai-gateway/filterapi"
 	"github.com/envoyproxy/ai-gateway/filterapi/x"
+	"github.com/envoyproxy/ai-gateway/internal/authzcel"
 	"github.com/envoyproxy/ai-gateway/internal/extproc/backendauth"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/redaction"
 	"github.com/envoyproxy/ai-gateway/internal/extproc/router"
 	"github.com/envoyproxy/ai-gateway/internal/llmcostcel"
 )
@@ -65,9 +69,40 @@ func (s *Server) LoadConfig(ctx context.Context, config *filterapi.Config) error
 
 	var (
 		backends       = make(map[string]*processorConfigBackend)
-		declaredModels []model
+		rules          = make(map[filterapi.RouteRuleName]*filterapi.RouteRule, len(config.Rules))
+		redactors      = make(map[filterapi.RouteRuleName]*redaction.Redactor)
+		denyPatterns   = make(map[filterapi.RouteRuleName][]*regexp.Regexp)
+		authorizations = make(map[filterapi.RouteRuleName]cel.Program)
+		shadowClients  = make(map[filterapi.RouteRuleName]*http.Client)
+		// guardrailClients is keyed by the name of the moderation backend since its TLS configuration is that of
+		// the backend.
+		guardrailClients = make(map[string]*http.Client)
+		// semanticCacheClients is keyed by the name of the embeddings backend in the same way.
+		semanticCacheClients = make(map[string]*http.Client)
+		declaredModels       []model
 	)
-	for _, r := range config.Rules {
+	for i := range config.Rules {
+		r := &config.Rules[i]
+		rules[r.Name] = r
+		if r.Redaction != nil {
+			if redactors[r.Name], err = redaction.New(r.Redaction); err != nil {
+				return fmt.Errorf("cannot create redactor for rule %s: %w", r.Name, err)
+			}
+		}
+		if r.Authorization != nil {
+			if authorizations[r.Name], err = authzcel.NewProgram(r.Authorization.CEL); err != nil {
+				return fmt.Errorf("cannot create CEL program for authorization of rule %s: %w", r.Name, err)
+			}
+		}
+		if r.StreamModeration != nil {
+			for _, p := range r.StreamModeration.DenyPatterns {
+				re, err := regexp.Compile(p)
+				if err != nil {
+					return fmt.Errorf("invalid deny pattern of rule %s: %w", r.Name, err)
+				}
+				denyPatterns[r.Name] = append(denyPatterns[r.Name], re)
+			}
+		}
 		ownedBy := r.ModelsOwnedBy
 		createdAt := r.ModelsCreatedAt
 
@@ -87,6 +122,7 @@ func (s *Server) LoadConfig(ctx context.Context, config *filterapi.Config) error
 				name:      h.Value,
 				createdAt: createdAt,
 				ownedBy:   ownedBy,
+				rule:      r.Name,
 			})
 		}
 
@@ -101,19 +137,80 @@ func (s *Server) LoadConfig(ctx context.Context, config *filterapi.Config) error
 			}
 			backends[b.Name] = &processorConfigBackend{b: &b, handler: h}
 		}
+		for j := range r.Guardrails {
+			g := &r.Guardrails[j]
+			b := &g.Backend
+			if _, ok := guardrailClients[b.Name]; !ok {
+				if guardrailClients[b.Name], err = newDirectBackendHTTPClient(g.TLS, guardrailHTTPClient); err != nil {
+					return fmt.Errorf("cannot create guardrail backend HTTP client: %w", err)
+				}
+			}
+			if _, ok := backends[b.Name]; ok || b.Auth == nil {
+				continue
+			}
+			h, err := backendauth.NewHandler(ctx, b.Auth)
+			if err != nil {
+				return fmt.Errorf("cannot create guardrail backend auth handler: %w", err)
+			}
+			backends[b.Name] = &processorConfigBackend{b: b, handler: h}
+		}
+		if r.ResponseCache != nil && r.ResponseCache.Semantic != nil {
+			sc := r.ResponseCache.Semantic
+			b := &sc.Backend
+			if _, ok := semanticCacheClients[b.Name]; !ok {
+				if semanticCacheClients[b.Name], err = newDirectBackendHTTPClient(sc.TLS, semanticCacheHTTPClient); err != nil {
+					return fmt.Errorf("cannot create semantic cache backend HTTP client: %w", err)
+				}
+			}
+			if _, ok := backends[b.Name]; !ok && b.Auth != nil {
+				h, err := backendauth.NewHandler(ctx, b.Auth)
+				if err != nil {
+					return fmt.Errorf("cannot create semantic cache backend auth handler: %w", err)
+				}
+				backends[b.Name] = &processorConfigBackend{b: b, handler: h}
+			}
+		}
+		if r.Shadow != nil {
+			// The shadow backend has its own model name override, so it must not replace the backend of the same
+			// name which serves the primary traffic of the other rules.
+			b := &r.Shadow.Backend
+			if _, ok := backends[b.Name]; !ok {
+				var h backendauth.Handler
+				if b.Auth != nil {
+					h, err = backendauth.NewHandler(ctx, b.Auth)
+					if err != nil {
+						return fmt.Errorf("cannot create shadow backend auth handler: %w", err)
+					}
+				}
+				backends[b.Name] = &processorConfigBackend{b: b, handler: h}
+			}
+			if shadowClients[r.Name], err = newDirectBackendHTTPClient(r.Shadow.TLS, shadowHTTPClient); err != nil {
+				return fmt.Errorf("cannot create shadow backend HTTP client: %w", err)
+			}
+		}
+	}
+
+	consumerKeys := make(map[string]*filterapi.ConsumerKey, len(config.ConsumerKeys))
+	for i := range config.ConsumerKeys {
+		k := &config.ConsumerKeys[i]
+		consumerKeys[k.Hash] = k
 	}
 
 	costs := make([]processorConfigRequestCost, 0, len(config.LLMRequestCosts))
 	for i := range config.LLMRequestCosts {
 		c := &config.LLMRequestCosts[i]
 		var prog cel.Program
+		var onRequest bool
 		if c.CEL != "" {
 			prog, err = llmcostcel.NewProgram(c.CEL)
 			if err != nil {
 				return fmt.Errorf("cannot create CEL program for cost: %w", err)
 			}
+			if onRequest, err = llmcostcel.UsesEstimatedInputTokens(c.CEL); err != nil {
+				return fmt.Errorf("cannot create CEL program for cost: %w", err)
+			}
 		}
-		costs = append(costs, processorConfigRequestCost{LLMRequestCost: c, celProg: prog})
+		costs = append(costs, processorConfigRequestCost{LLMRequestCost: c, celProg: prog, celOnRequest: onRequest})
 	}
 
 	newConfig := &processorConfig{
@@ -121,8 +218,18 @@ func (s *Server) LoadConfig(ctx context.Context, config *filterapi.Config) error
 		schema:                 config.Schema,
 		router:                 rt,
 		selectedRouteHeaderKey: config.SelectedRouteHeaderKey,
+		sessionKeyHeaderKey:    config.SessionKeyHeaderKey,
 		modelNameHeaderKey:     config.ModelNameHeaderKey,
 		backends:               backends,
+		rules:                  rules,
+		redactors:              redactors,
+		denyPatterns:           denyPatterns,
+		consumerHeaderKey:      config.ConsumerHeaderKey,
+		consumerKeys:           consumerKeys,
+		authorizations:         authorizations,
+		shadowClients:          shadowClients,
+		guardrailClients:       guardrailClients,
+		semanticCacheClients:   semanticCacheClients,
 		metadataNamespace:      config.MetadataNamespace,
 		requestCosts:           costs,
 		declaredModels:         declaredModels,
@@ -172,6 +279,9 @@ func (s *Server) Process(stream extprocv3.ExternalProcessor_ProcessServer) error
 	var reqID string
 	var logger *slog.Logger
 	defer func() {
+		if sc, ok := p.(streamCloser); ok {
+			sc.closeStream()
+		}
 		if !isUpstreamFilter {
 			s.routerProcessorsPerReqIDMutex.Lock()
 			defer s.routerProcessorsPerReqIDMutex.Unlock()
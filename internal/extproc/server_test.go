@@ -82,6 +82,20 @@ func TestServer_LoadConfig(t *testing.T) {
 					},
 					ModelsOwnedBy:   "openai",
 					ModelsCreatedAt: now,
+					Shadow: &filterapi.ShadowBackend{
+						Backend: filterapi.Backend{Name: "shadow", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI}},
+						URL:     "http://localhost:8080",
+						Percent: 10,
+					},
+					Guardrails: []filterapi.Guardrail{
+						{Name: "g", Backend: filterapi.Backend{Name: "moderation"}, URL: "https://localhost:8443",
+							TLS: &filterapi.BackendTLS{Hostname: "localhost"}},
+					},
+					ResponseCache: &filterapi.ResponseCache{Semantic: &filterapi.SemanticCache{
+						Backend: filterapi.Backend{Name: "embeddings", Auth: &filterapi.BackendAuth{APIKey: &filterapi.APIKeyAuth{Key: "key"}}},
+						URL:     "https://localhost:8443",
+						TLS:     &filterapi.BackendTLS{Hostname: "localhost"},
+					}},
 				},
 			},
 		}
@@ -95,6 +109,14 @@ func TestServer_LoadConfig(t *testing.T) {
 		require.Equal(t, s.config.schema, config.Schema)
 		require.Equal(t, "x-ai-eg-selected-route", s.config.selectedRouteHeaderKey)
 		require.Equal(t, "x-model-name", s.config.modelNameHeaderKey)
+		require.Len(t, s.config.backends, 5)
+		require.NotNil(t, s.config.backends["embeddings"].handler)
+		require.Equal(t, "shadow", s.config.backends["shadow"].b.Name)
+		require.Len(t, s.config.shadowClients, 1)
+		require.NotNil(t, s.config.guardrailClients["moderation"])
+		require.NotSame(t, guardrailHTTPClient, s.config.guardrailClients["moderation"])
+		require.NotNil(t, s.config.semanticCacheClients["embeddings"])
+		require.NotSame(t, semanticCacheHTTPClient, s.config.semanticCacheClients["embeddings"])
 
 		require.Len(t, s.config.requestCosts, 2)
 		require.Equal(t, filterapi.LLMRequestCostTypeOutputToken, s.config.requestCosts[0].Type)
@@ -103,7 +125,7 @@ func TestServer_LoadConfig(t *testing.T) {
 		require.Equal(t, "1 + 1", s.config.requestCosts[1].CEL)
 		prog := s.config.requestCosts[1].celProg
 		require.NotNil(t, prog)
-		val, err := llmcostcel.EvaluateProgram(prog, "", "", 1, 1, 1)
+		val, err := llmcostcel.EvaluateProgram(prog, &llmcostcel.Input{InputTokens: 1, OutputTokens: 1, TotalTokens: 1})
 		require.NoError(t, err)
 		require.Equal(t, uint64(2), val)
 		require.Equal(t, []model{
@@ -119,6 +141,37 @@ func TestServer_LoadConfig(t *testing.T) {
 			},
 		}, s.config.declaredModels)
 	})
+
+	t.Run("primary backend as shadow", func(t *testing.T) {
+		config := &filterapi.Config{
+			Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+			Rules: []filterapi.RouteRule{
+				{
+					Name:    "primary",
+					Headers: []filterapi.HeaderMatch{{Name: "x-model-name", Value: "gpt-4o"}},
+					Backends: []filterapi.Backend{
+						{Name: "openai", ModelNameOverride: "gpt-4o-2024-08-06", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI}},
+					},
+				},
+				{
+					Name:     "shadowed",
+					Headers:  []filterapi.HeaderMatch{{Name: "x-model-name", Value: "llama3"}},
+					Backends: []filterapi.Backend{{Name: "kserve", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI}}},
+					Shadow: &filterapi.ShadowBackend{
+						Backend: filterapi.Backend{Name: "openai", ModelNameOverride: "gpt-4o-mini", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI}},
+						URL:     "http://localhost:8080",
+						Percent: 10,
+					},
+				},
+			},
+		}
+		s, _ := requireNewServerWithMockProcessor(t)
+		require.NoError(t, s.LoadConfig(t.Context(), config))
+		require.Len(t, s.config.backends, 2)
+		// The primary backend is not replaced by the shadow backend of the same name.
+		require.Equal(t, "gpt-4o-2024-08-06", s.config.backends["openai"].b.ModelNameOverride)
+		require.Len(t, s.config.shadowClients, 1)
+	})
 }
 
 func TestServer_Check(t *testing.T) {
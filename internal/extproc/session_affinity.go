@@ -0,0 +1,35 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+)
+
+// sessionKeyHash returns the hash of the session key of the request based on the given session affinity configuration,
+// or an empty string if the request does not have a session key.
+//
+// The key is hashed so that the raw value, such as the user identifier, is not exposed to the upstream
+// in the request header populated with this value.
+func sessionKeyHash(sa *filterapi.SessionAffinity, requestHeaders map[string]string, body *openai.ChatCompletionRequest) string {
+	var key string
+	if sa.Header != "" {
+		key = requestHeaders[strings.ToLower(sa.Header)]
+	}
+	if key == "" && sa.UserField {
+		key = body.User
+	}
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
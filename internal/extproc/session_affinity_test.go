@@ -0,0 +1,36 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+)
+
+func Test_sessionKeyHash(t *testing.T) {
+	headers := map[string]string{"x-session-id": "session-1"}
+	body := &openai.ChatCompletionRequest{User: "user-1"}
+
+	t.Run("header", func(t *testing.T) {
+		h := sessionKeyHash(&filterapi.SessionAffinity{Header: "X-Session-Id", UserField: true}, headers, body)
+		require.Len(t, h, 32)
+		require.Equal(t, h, sessionKeyHash(&filterapi.SessionAffinity{Header: "x-session-id"}, headers, nil))
+	})
+	t.Run("user field fallback", func(t *testing.T) {
+		h := sessionKeyHash(&filterapi.SessionAffinity{Header: "x-conversation-id", UserField: true}, headers, body)
+		require.Len(t, h, 32)
+		require.NotEqual(t, h, sessionKeyHash(&filterapi.SessionAffinity{Header: "x-session-id"}, headers, nil))
+		require.Equal(t, h, sessionKeyHash(&filterapi.SessionAffinity{UserField: true}, nil, body))
+	})
+	t.Run("no key", func(t *testing.T) {
+		require.Empty(t, sessionKeyHash(&filterapi.SessionAffinity{Header: "x-conversation-id"}, headers, body))
+		require.Empty(t, sessionKeyHash(&filterapi.SessionAffinity{UserField: true}, headers, &openai.ChatCompletionRequest{}))
+	})
+}
@@ -0,0 +1,416 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/backendauth"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
+)
+
+const (
+	// defaultShadowTimeout is the timeout of the shadow requests when not configured.
+	defaultShadowTimeout = 60 * time.Second
+	// maxShadowResponseBodySize is the maximum size of the response body read from the shadow backend, and of the
+	// response body of the primary backend buffered for the comparison.
+	maxShadowResponseBodySize = 50 << 20
+	// maxInFlightShadowRequests is the maximum number of the shadow requests in flight in the external processor.
+	maxInFlightShadowRequests = 256
+)
+
+var (
+	// shadowHTTPClient is the HTTP client used to send the shadow requests to the backends without TLS configuration.
+	shadowHTTPClient = &http.Client{}
+	// shadowSlots limits the number of the shadow requests in flight. The sampled requests are not mirrored when
+	// this is full so that a slow shadow backend never piles up goroutines and memory in the external processor.
+	shadowSlots = make(chan struct{}, maxInFlightShadowRequests)
+)
+
+// newDirectBackendHTTPClient returns the HTTP client to call a backend directly from the external processor, such as
+// a shadow or a moderation backend, with the given TLS configuration. defaultClient is returned if cfg is nil.
+func newDirectBackendHTTPClient(cfg *filterapi.BackendTLS, defaultClient *http.Client) (*http.Client, error) {
+	if cfg == nil {
+		return defaultClient, nil
+	}
+	tlsConfig := &tls.Config{ServerName: cfg.Hostname, MinVersion: tls.VersionTLS12}
+	if cfg.CACertificates != "" {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM([]byte(cfg.CACertificates)) {
+			return nil, errors.New("no valid CA certificate found")
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// shadowRequest is a chat completion request mirrored to the shadow backend of a route rule.
+//
+// The shadow request is sent in its own goroutine so that it never delays the primary request, and its response
+// never reaches the client. Its result is paired with the result of the primary backend reported by the router
+// filter via [shadowRequest.finishPrimary], and the pair is recorded as metrics and a structured log. When the result
+// of the primary backend is not available, the router filter calls [shadowRequest.abortPrimary] instead so that the
+// shadow request is recorded without waiting for the timeout.
+type shadowRequest struct {
+	logger    *slog.Logger
+	metrics   *metrics.Shadow
+	client    *http.Client
+	config    *filterapi.ShadowBackend
+	handler   backendauth.Handler
+	routeRule filterapi.RouteRuleName
+	model     string
+	// start is the time when the request was routed, which is the origin of the latency of both sides.
+	start time.Time
+	// primary receives the result of the primary backend.
+	primary chan *shadowResult
+
+	// primaryStatus and primaryBody are the response status and the (translated) response body of the primary
+	// backend. These are only accessed by the router filter.
+	primaryStatus string
+	primaryBody   []byte
+	// primaryBodyTooLarge is true if the response body of the primary backend exceeded maxShadowResponseBodySize,
+	// in which case the body is dropped and the completions are not compared.
+	primaryBodyTooLarge bool
+}
+
+// shadowResult is the result of either the primary or the shadow backend.
+type shadowResult struct {
+	metrics.ShadowResult
+	// content is the text content of the first choice of the completion, used to compute the similarity.
+	content string
+	err     error
+}
+
+// maybeStartShadowRequest starts mirroring the request to the shadow backend of the given rule if the rule has one
+// and the request is sampled. This returns nil if the request is not mirrored, including when too many shadow
+// requests are already in flight.
+func maybeStartShadowRequest(config *processorConfig, shadowMetrics *metrics.Shadow, logger *slog.Logger,
+	rule *filterapi.RouteRule, model string, requestHeaders map[string]string, raw []byte,
+) *shadowRequest {
+	shadow := rule.Shadow
+	if shadow == nil || rand.IntN(100) >= shadow.Percent { // #nosec G404: Sampling does not need a secure random number.
+		return nil
+	}
+	select {
+	case shadowSlots <- struct{}{}:
+	default:
+		logger.Debug("dropping shadow request since too many shadow requests are in flight",
+			"shadow_backend", shadow.Backend.Name)
+		return nil
+	}
+	s := &shadowRequest{
+		logger:    logger.With("shadow_backend", shadow.Backend.Name),
+		metrics:   shadowMetrics,
+		client:    cmp.Or(config.shadowClients[rule.Name], shadowHTTPClient),
+		config:    shadow,
+		routeRule: rule.Name,
+		model:     model,
+		start:     time.Now(),
+		primary:   make(chan *shadowResult, 1),
+	}
+	if b, ok := config.backends[shadow.Backend.Name]; ok {
+		s.handler = b.handler
+	}
+	// Only the headers needed for the translation and the auth are passed to the shadow backend so that
+	// the client's credentials never leak to the shadow backend.
+	headers := map[string]string{
+		":method":      cmp.Or(requestHeaders[":method"], http.MethodPost),
+		":path":        requestHeaders[":path"],
+		"content-type": cmp.Or(requestHeaders["content-type"], "application/json"),
+	}
+	go func() {
+		defer func() { <-shadowSlots }()
+		s.run(headers, raw)
+	}()
+	return s
+}
+
+// run sends the shadow request, waits for the result of the primary backend, and records the pair.
+func (s *shadowRequest) run(headers map[string]string, raw []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), cmp.Or(s.config.Timeout, defaultShadowTimeout))
+	defer cancel()
+
+	shadow := s.send(ctx, headers, raw)
+	var primary *shadowResult
+	select {
+	case primary = <-s.primary:
+	case <-ctx.Done():
+	}
+	s.record(primary, shadow)
+}
+
+// send sends the request to the shadow backend and returns its result.
+func (s *shadowRequest) send(ctx context.Context, headers map[string]string, raw []byte) (res *shadowResult) {
+	res = &shadowResult{}
+	res.Backend = s.config.Backend.Name
+	defer func() {
+		res.Latency = time.Since(s.start)
+	}()
+
+	// The body is parsed again since translators might modify it while the router filter is still using it.
+	var body openai.ChatCompletionRequest
+	if res.err = json.Unmarshal(raw, &body); res.err != nil {
+		return
+	}
+	t, err := newChatCompletionTranslator(s.config.Backend.Schema, s.config.Backend.ModelNameOverride)
+	if err != nil {
+		res.err = err
+		return
+	}
+	headerMutation, bodyMutation, err := t.RequestBody(raw, &body, false)
+	if err != nil {
+		res.err = fmt.Errorf("failed to transform request: %w", err)
+		return
+	}
+	if headerMutation == nil {
+		headerMutation = &extprocv3.HeaderMutation{}
+	}
+	applyHeaderMutation(headers, headerMutation)
+	if s.handler != nil {
+		if err = s.handler.Do(ctx, headers, headerMutation, bodyMutation); err != nil {
+			res.err = fmt.Errorf("failed to do auth request: %w", err)
+			return
+		}
+		applyHeaderMutation(headers, headerMutation)
+	}
+	reqBody := raw
+	if b := bodyMutation.GetBody(); b != nil {
+		reqBody = b
+	}
+
+	req, err := http.NewRequestWithContext(ctx, headers[":method"], s.config.URL+headers[":path"], bytes.NewReader(reqBody))
+	if err != nil {
+		res.err = fmt.Errorf("failed to create request: %w", err)
+		return
+	}
+	for k, v := range headers {
+		if strings.HasPrefix(k, ":") || strings.EqualFold(k, "content-length") {
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		res.err = fmt.Errorf("failed to send request: %w", err)
+		return
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxShadowResponseBodySize))
+	if err != nil {
+		res.err = fmt.Errorf("failed to read response body: %w", err)
+		return
+	}
+	if resp.StatusCode/100 != 2 {
+		res.err = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+		return
+	}
+
+	respHeaders := map[string]string{":status": strconv.Itoa(resp.StatusCode)}
+	for k, v := range resp.Header {
+		respHeaders[strings.ToLower(k)] = v[0]
+	}
+	// The HTTP client transparently decompresses the body, so the translator must see it as not encoded.
+	delete(respHeaders, "content-encoding")
+	if _, err = t.ResponseHeaders(respHeaders); err != nil {
+		res.err = fmt.Errorf("failed to transform response headers: %w", err)
+		return
+	}
+	_, respBodyMutation, usage, err := t.ResponseBody(respHeaders, bytes.NewReader(respBody), true)
+	if err != nil {
+		res.err = fmt.Errorf("failed to transform response: %w", err)
+		return
+	}
+	if b := respBodyMutation.GetBody(); b != nil {
+		respBody = b
+	}
+	res.Success = true
+	res.InputTokens, res.OutputTokens = usage.InputTokens, usage.OutputTokens
+	res.FinishReason, res.content = summarizeChatCompletion(respBody)
+	return
+}
+
+// appendPrimaryBody appends the chunk of the response body of the primary backend.
+func (s *shadowRequest) appendPrimaryBody(resp *extprocv3.ProcessingResponse, body *extprocv3.HttpBody) {
+	if s.primaryBodyTooLarge {
+		return
+	}
+	chunk := body.Body
+	if b := resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody(); b != nil {
+		chunk = b
+	}
+	if len(s.primaryBody)+len(chunk) > maxShadowResponseBodySize {
+		s.primaryBody, s.primaryBodyTooLarge = nil, true
+		return
+	}
+	s.primaryBody = append(s.primaryBody, chunk...)
+}
+
+// finishPrimary reports the result of the primary backend. This or abortPrimary must be called at most once by
+// the router filter.
+func (s *shadowRequest) finishPrimary(backend string, usage translator.LLMTokenUsage) {
+	res := &shadowResult{}
+	res.Backend = backend
+	res.Latency = time.Since(s.start)
+	res.Success = strings.HasPrefix(s.primaryStatus, "2")
+	if res.Success {
+		res.InputTokens, res.OutputTokens = usage.InputTokens, usage.OutputTokens
+		if !s.primaryBodyTooLarge {
+			res.FinishReason, res.content = summarizeChatCompletion(s.primaryBody)
+		}
+	} else {
+		res.err = fmt.Errorf("unexpected status code %s", s.primaryStatus)
+	}
+	s.primaryBody = nil
+	s.primary <- res
+}
+
+// abortPrimary reports that the result of the primary backend is not available, such as when the request is answered
+// with an immediate response or the stream is closed before the end of the response. The shadow request is then
+// recorded without the primary result as soon as its own response is received. This or finishPrimary must be called
+// at most once by the router filter.
+func (s *shadowRequest) abortPrimary() {
+	s.primaryBody = nil
+	s.primary <- nil
+}
+
+// record records the paired results as metrics and a structured log.
+func (s *shadowRequest) record(primary, shadow *shadowResult) {
+	similarity := -1.0
+	if primary != nil && primary.Success && shadow.Success {
+		similarity = completionSimilarity(primary.content, shadow.content)
+	}
+	if s.metrics != nil {
+		var primaryResult *metrics.ShadowResult
+		if primary != nil {
+			primaryResult = &primary.ShadowResult
+		}
+		s.metrics.RecordComparison(context.Background(), string(s.routeRule), s.model, primaryResult, &shadow.ShadowResult, similarity)
+	}
+
+	attrs := []any{
+		slog.String("route_rule", string(s.routeRule)),
+		slog.String("model", s.model),
+		slog.Group("shadow", shadowResultLogAttrs(shadow)...),
+	}
+	if primary != nil {
+		attrs = append(attrs, slog.Group("primary", shadowResultLogAttrs(primary)...))
+	}
+	if similarity >= 0 {
+		attrs = append(attrs, slog.Float64("similarity", similarity))
+	}
+	s.logger.Info("shadow request comparison", attrs...)
+}
+
+// shadowResultLogAttrs returns the structured log attributes of the given result.
+func shadowResultLogAttrs(r *shadowResult) []any {
+	attrs := []any{
+		slog.String("backend", r.Backend),
+		slog.Int64("latency_ms", r.Latency.Milliseconds()),
+		slog.Bool("success", r.Success),
+		slog.Uint64("input_tokens", uint64(r.InputTokens)),
+		slog.Uint64("output_tokens", uint64(r.OutputTokens)),
+		slog.String("finish_reason", r.FinishReason),
+	}
+	if r.err != nil {
+		attrs = append(attrs, slog.String("error", r.err.Error()))
+	}
+	return attrs
+}
+
+// applyHeaderMutation applies the set headers of the header mutation to the given headers.
+func applyHeaderMutation(headers map[string]string, headerMutation *extprocv3.HeaderMutation) {
+	for _, h := range headerMutation.SetHeaders {
+		if len(h.Header.RawValue) > 0 {
+			headers[h.Header.Key] = string(h.Header.RawValue)
+		} else {
+			headers[h.Header.Key] = h.Header.Value
+		}
+	}
+	for _, k := range headerMutation.RemoveHeaders {
+		delete(headers, k)
+	}
+}
+
+// summarizeChatCompletion returns the finish reason and the text content of the first choice of the given
+// OpenAI chat completion response body, which is either a JSON object or the server-sent events of a streaming response.
+func summarizeChatCompletion(body []byte) (finishReason, content string) {
+	var resp openai.ChatCompletionResponse
+	if err := json.Unmarshal(body, &resp); err == nil {
+		if len(resp.Choices) > 0 {
+			finishReason = string(resp.Choices[0].FinishReason)
+			if c := resp.Choices[0].Message.Content; c != nil {
+				content = *c
+			}
+		}
+		return
+	}
+
+	var sb strings.Builder
+	for line := range bytes.SplitSeq(body, []byte("\n")) {
+		data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+		if !ok {
+			continue
+		}
+		var chunk openai.ChatCompletionResponseChunk
+		if err := json.Unmarshal(bytes.TrimSpace(data), &chunk); err != nil || len(chunk.Choices) == 0 {
+			continue // Such as "[DONE]" or the usage-only chunk.
+		}
+		choice := chunk.Choices[0]
+		if choice.Delta != nil && choice.Delta.Content != nil {
+			sb.WriteString(*choice.Delta.Content)
+		}
+		if choice.FinishReason != "" {
+			finishReason = string(choice.FinishReason)
+		}
+	}
+	return finishReason, sb.String()
+}
+
+// completionSimilarity returns the Jaccard index of the sets of the lower-cased words of the given completions,
+// which is a cheap approximation of how similar the two completions are. This returns -1 if both are empty,
+// e.g. when both completions only consist of tool calls.
+func completionSimilarity(a, b string) float64 {
+	wordsA, wordsB := strings.Fields(strings.ToLower(a)), strings.Fields(strings.ToLower(b))
+	if len(wordsA) == 0 && len(wordsB) == 0 {
+		return -1
+	}
+	set := make(map[string]uint8, len(wordsA)+len(wordsB))
+	for _, w := range wordsA {
+		set[w] |= 1
+	}
+	for _, w := range wordsB {
+		set[w] |= 2
+	}
+	var intersection int
+	for _, v := range set {
+		if v == 3 {
+			intersection++
+		}
+	}
+	return float64(intersection) / float64(len(set))
+}
@@ -0,0 +1,247 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
+)
+
+func Test_maybeStartShadowRequest(t *testing.T) {
+	config := &processorConfig{}
+	t.Run("no shadow", func(t *testing.T) {
+		s := maybeStartShadowRequest(config, nil, slog.Default(), &filterapi.RouteRule{Name: "rule"}, "model", nil, nil)
+		require.Nil(t, s)
+	})
+	t.Run("not sampled", func(t *testing.T) {
+		rule := &filterapi.RouteRule{Name: "rule", Shadow: &filterapi.ShadowBackend{Percent: 0}}
+		s := maybeStartShadowRequest(config, nil, slog.Default(), rule, "model", nil, nil)
+		require.Nil(t, s)
+	})
+	t.Run("too many in flight", func(t *testing.T) {
+		for range maxInFlightShadowRequests {
+			shadowSlots <- struct{}{}
+		}
+		defer func() {
+			for range maxInFlightShadowRequests {
+				<-shadowSlots
+			}
+		}()
+		rule := &filterapi.RouteRule{Name: "rule", Shadow: &filterapi.ShadowBackend{Percent: 100}}
+		s := maybeStartShadowRequest(config, nil, slog.Default(), rule, "model", nil, nil)
+		require.Nil(t, s)
+	})
+}
+
+func Test_newDirectBackendHTTPClient(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	caCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+
+	t.Run("no tls", func(t *testing.T) {
+		c, err := newDirectBackendHTTPClient(nil, shadowHTTPClient)
+		require.NoError(t, err)
+		require.Same(t, shadowHTTPClient, c)
+	})
+	t.Run("invalid ca", func(t *testing.T) {
+		_, err := newDirectBackendHTTPClient(&filterapi.BackendTLS{Hostname: "example.com", CACertificates: "invalid"}, shadowHTTPClient)
+		require.ErrorContains(t, err, "no valid CA certificate found")
+	})
+	t.Run("ca", func(t *testing.T) {
+		// The certificate of the test server is valid for example.com.
+		c, err := newDirectBackendHTTPClient(&filterapi.BackendTLS{Hostname: "example.com", CACertificates: caCert}, shadowHTTPClient)
+		require.NoError(t, err)
+		resp, err := c.Get(srv.URL)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+	t.Run("hostname mismatch", func(t *testing.T) {
+		c, err := newDirectBackendHTTPClient(&filterapi.BackendTLS{Hostname: "api.openai.com", CACertificates: caCert}, shadowHTTPClient)
+		require.NoError(t, err)
+		_, err = c.Get(srv.URL) //nolint:bodyclose
+		require.ErrorContains(t, err, "certificate is valid for")
+	})
+	t.Run("system ca", func(t *testing.T) {
+		c, err := newDirectBackendHTTPClient(&filterapi.BackendTLS{Hostname: "example.com"}, shadowHTTPClient)
+		require.NoError(t, err)
+		_, err = c.Get(srv.URL) //nolint:bodyclose
+		require.ErrorContains(t, err, "certificate signed by unknown authority")
+	})
+}
+
+func TestShadowRequest(t *testing.T) {
+	const reqBody = `{"model":"some-model","messages":[{"role":"user","content":"hello"}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/chat/completions", r.URL.Path)
+		require.Empty(t, r.Header.Get("authorization"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"model":"shadow-model","messages":[{"role":"user","content":"hello"}]}`, string(body))
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"finish_reason":"stop","message":{"role":"assistant","content":"Hello there, friend"}}],
+"usage":{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	mr := sdkmetric.NewManualReader()
+	sm := metrics.NewShadow(sdkmetric.NewMeterProvider(sdkmetric.WithReader(mr)).Meter("test"))
+	s := &shadowRequest{
+		logger:  slog.Default(),
+		metrics: sm,
+		client:  shadowHTTPClient,
+		config: &filterapi.ShadowBackend{
+			Backend: filterapi.Backend{
+				Name:              "shadow.ns",
+				ModelNameOverride: "shadow-model",
+				Schema:            filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI, Version: "v1"},
+			},
+			URL:     srv.URL,
+			Timeout: 10 * time.Second,
+		},
+		routeRule: "rule",
+		model:     "some-model",
+		start:     time.Now(),
+		primary:   make(chan *shadowResult, 1),
+	}
+
+	t.Run("send", func(t *testing.T) {
+		res := s.send(t.Context(), map[string]string{":method": "POST", ":path": "/v1/chat/completions"}, []byte(reqBody))
+		require.NoError(t, res.err)
+		require.True(t, res.Success)
+		require.Equal(t, "shadow.ns", res.Backend)
+		require.Equal(t, uint32(12), res.InputTokens)
+		require.Equal(t, uint32(7), res.OutputTokens)
+		require.Equal(t, "stop", res.FinishReason)
+		require.Equal(t, "Hello there, friend", res.content)
+	})
+
+	t.Run("send error status", func(t *testing.T) {
+		errSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		t.Cleanup(errSrv.Close)
+		errShadow := *s
+		errShadow.config = &filterapi.ShadowBackend{Backend: s.config.Backend, URL: errSrv.URL}
+		res := errShadow.send(t.Context(), map[string]string{":method": "POST", ":path": "/v1/chat/completions"}, []byte(reqBody))
+		require.ErrorContains(t, res.err, "unexpected status code 429")
+		require.False(t, res.Success)
+	})
+
+	t.Run("run", func(t *testing.T) {
+		s.primaryStatus = "200"
+		s.appendPrimaryBody(&extprocv3.ProcessingResponse{}, &extprocv3.HttpBody{Body: []byte(`{"choices":[{"finish_reason":"stop",`)})
+		s.appendPrimaryBody(&extprocv3.ProcessingResponse{}, &extprocv3.HttpBody{Body: []byte(`"message":{"content":"hello there"}}]}`)})
+		s.finishPrimary("primary.ns", translator.LLMTokenUsage{InputTokens: 10, OutputTokens: 2})
+		require.Nil(t, s.primaryBody)
+
+		s.run(map[string]string{":method": "POST", ":path": "/v1/chat/completions"}, []byte(reqBody))
+
+		var data metricdata.ResourceMetrics
+		require.NoError(t, mr.Collect(t.Context(), &data))
+		var similarity *metricdata.HistogramDataPoint[float64]
+		for _, m := range data.ScopeMetrics[0].Metrics {
+			if m.Name == "aigw.shadow.response.similarity" {
+				similarity = &m.Data.(metricdata.Histogram[float64]).DataPoints[0]
+			}
+		}
+		require.NotNil(t, similarity)
+		require.Equal(t, uint64(1), similarity.Count)
+		// {"hello", "there"} / {"hello", "there,", "friend"} = 1 / 4.
+		require.InDelta(t, 0.25, similarity.Sum, 1e-9)
+	})
+
+	t.Run("primary body too large", func(t *testing.T) {
+		large := &shadowRequest{start: time.Now(), primaryStatus: "200", primary: make(chan *shadowResult, 1)}
+		large.appendPrimaryBody(&extprocv3.ProcessingResponse{}, &extprocv3.HttpBody{Body: make([]byte, maxShadowResponseBodySize)})
+		large.appendPrimaryBody(&extprocv3.ProcessingResponse{}, &extprocv3.HttpBody{Body: []byte(`{}`)})
+		require.True(t, large.primaryBodyTooLarge)
+		require.Nil(t, large.primaryBody)
+		large.finishPrimary("primary.ns", translator.LLMTokenUsage{InputTokens: 10, OutputTokens: 2})
+		res := <-large.primary
+		require.True(t, res.Success)
+		require.Equal(t, uint32(2), res.OutputTokens)
+		require.Empty(t, res.content)
+	})
+}
+
+func TestChatCompletion_abortShadow(t *testing.T) {
+	newRouterFilter := func() *chatCompletionProcessorRouterFilter {
+		return &chatCompletionProcessorRouterFilter{
+			logger: slog.Default(),
+			shadow: &shadowRequest{primary: make(chan *shadowResult, 1)},
+		}
+	}
+
+	t.Run("stream closed", func(t *testing.T) {
+		rp := newRouterFilter()
+		primary := rp.shadow.primary
+		rp.closeStream()
+		require.Nil(t, rp.shadow)
+		require.Nil(t, <-primary)
+		// Closing the stream again is a no-op.
+		rp.closeStream()
+	})
+
+	t.Run("upstream filter error", func(t *testing.T) {
+		rp := newRouterFilter()
+		primary := rp.shadow.primary
+		rp.upstreamFilter = &chatCompletionProcessorUpstreamFilter{circuitRejected: true}
+		_, err := rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "503"}}})
+		require.NoError(t, err)
+		require.Nil(t, rp.shadow)
+		require.Nil(t, <-primary)
+	})
+}
+
+func Test_summarizeChatCompletion(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		finishReason, content := summarizeChatCompletion([]byte(`{"choices":[{"finish_reason":"length","message":{"content":"abc"}}]}`))
+		require.Equal(t, "length", finishReason)
+		require.Equal(t, "abc", content)
+	})
+	t.Run("sse", func(t *testing.T) {
+		var buf bytes.Buffer
+		buf.WriteString("data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"Hel\"}}]}\n\n")
+		buf.WriteString("data: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\n")
+		buf.WriteString("data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		buf.WriteString("data: {\"usage\":{\"prompt_tokens\":1}}\n\n")
+		buf.WriteString("data: [DONE]\n\n")
+		finishReason, content := summarizeChatCompletion(buf.Bytes())
+		require.Equal(t, "stop", finishReason)
+		require.Equal(t, "Hello", content)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		finishReason, content := summarizeChatCompletion([]byte("not a completion"))
+		require.Empty(t, finishReason)
+		require.Empty(t, content)
+	})
+}
+
+func Test_completionSimilarity(t *testing.T) {
+	require.Equal(t, -1.0, completionSimilarity("", " "))
+	require.Equal(t, 0.0, completionSimilarity("a b", ""))
+	require.Equal(t, 1.0, completionSimilarity("Hello World", "world hello"))
+	require.InDelta(t, 1.0/3.0, completionSimilarity("a b", "b c"), 1e-9)
+}
@@ -0,0 +1,290 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
+)
+
+const (
+	// streamModerationDenyList is the name of the violation of the deny patterns of the stream moderation.
+	streamModerationDenyList = "deny_list"
+	// defaultStreamModerationWindowSize is the default number of the characters held back per choice.
+	defaultStreamModerationWindowSize = 256
+)
+
+// streamModerator holds back the server-sent events of a streamed chat completion until the window of the
+// completions of a choice is full, and releases them to the client only after the window passes the check.
+// The events split across the chunks of the response body are buffered until complete.
+type streamModerator struct {
+	windowSize int
+	// check screens the text of each choice, which is the tail of the previously screened window followed by the
+	// completions held back, and returns the name of the violation, if any.
+	check func(ctx context.Context, texts map[int64]string) (violation string)
+	// holdback is called with the time the released events were held back. Optional.
+	holdback func(ctx context.Context, d time.Duration)
+	now      func() time.Time
+
+	// buf is the incomplete event at the end of the last chunk.
+	buf []byte
+	// pending is the complete events held back, and pendingSince is when the first of them was received.
+	pending      []byte
+	pendingSince time.Time
+	// unreleased is the completions of each choice in the pending events, and screened is the tail of the last
+	// screened window of each choice.
+	unreleased map[int64]string
+	screened   map[int64]string
+	// lastChunk is the data of the last chat completion chunk, which is used as the template of the chunk
+	// terminating the stream.
+	lastChunk []byte
+	// violation is the name of the violation that terminated the stream, if any.
+	violation string
+}
+
+func newStreamModerator(windowSize int, check func(context.Context, map[int64]string) string) *streamModerator {
+	return &streamModerator{
+		windowSize: windowSize,
+		check:      check,
+		now:        time.Now,
+		unreleased: map[int64]string{},
+		screened:   map[int64]string{},
+	}
+}
+
+// write processes the chunk of the response body, and returns the events that can be sent to the client.
+func (m *streamModerator) write(ctx context.Context, chunk []byte, endOfStream bool) ([]byte, error) {
+	// This is never nil so that the empty body mutation clears the chunk held back.
+	out := []byte{}
+	if m.violation != "" {
+		// The stream has already been terminated, so the rest of the upstream response is discarded.
+		return out, nil
+	}
+	m.buf = append(m.buf, chunk...)
+	for {
+		i := bytes.Index(m.buf, []byte("\n\n"))
+		if i < 0 {
+			break
+		}
+		event := m.buf[:i+2]
+		m.buf = m.buf[i+2:]
+		released, err := m.processEvent(ctx, event)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, released...)
+		if m.violation != "" {
+			m.buf = nil
+			return out, nil
+		}
+	}
+	if endOfStream {
+		released, err := m.release(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, released...)
+		if m.violation == "" {
+			out = append(out, m.buf...)
+		}
+		m.buf = nil
+	}
+	return out, nil
+}
+
+// processEvent processes a single event including the trailing blank line, and returns the events released.
+func (m *streamModerator) processEvent(ctx context.Context, event []byte) ([]byte, error) {
+	var data []byte
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		if d, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = bytes.TrimSpace(d)
+			break
+		}
+	}
+	if string(data) == "[DONE]" {
+		released, err := m.release(ctx)
+		if err != nil || m.violation != "" {
+			return released, err
+		}
+		return append(released, event...), nil
+	}
+	if data != nil && gjson.ValidBytes(data) && gjson.GetBytes(data, "choices").IsArray() {
+		m.lastChunk = bytes.Clone(data)
+		for _, choice := range gjson.GetBytes(data, "choices").Array() {
+			index := choice.Get("index").Int()
+			var text strings.Builder
+			text.WriteString(choice.Get("delta.content").String())
+			for _, tc := range choice.Get("delta.tool_calls").Array() {
+				text.WriteString(tc.Get("function.arguments").String())
+			}
+			if _, ok := m.screened[index]; !ok {
+				m.screened[index] = ""
+			}
+			m.unreleased[index] += text.String()
+		}
+	}
+	if len(m.pending) == 0 {
+		if !m.holdsBack() {
+			// Nothing needs to be screened, e.g. the role of the first chunk.
+			return bytes.Clone(event), nil
+		}
+		m.pendingSince = m.now()
+	}
+	m.pending = append(m.pending, event...)
+	for _, text := range m.unreleased {
+		if utf8.RuneCountInString(text) >= m.windowSize {
+			return m.release(ctx)
+		}
+	}
+	return nil, nil
+}
+
+// holdsBack returns true if any completions are not screened yet.
+func (m *streamModerator) holdsBack() bool {
+	for _, text := range m.unreleased {
+		if text != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// release screens the completions held back, and returns the pending events if they pass the check, or the chunk
+// terminating the stream otherwise.
+func (m *streamModerator) release(ctx context.Context) ([]byte, error) {
+	if len(m.pending) == 0 {
+		return nil, nil
+	}
+	if m.holdsBack() {
+		texts := make(map[int64]string, len(m.unreleased))
+		for index, text := range m.unreleased {
+			texts[index] = m.screened[index] + text
+		}
+		if violation := m.check(ctx, texts); violation != "" {
+			m.violation = violation
+			m.pending = nil
+			return m.terminate()
+		}
+		for index, text := range texts {
+			// The tail is kept so that the violations across the windows are detected with the next window.
+			if n := utf8.RuneCountInString(text); n > m.windowSize {
+				for range n - m.windowSize {
+					_, size := utf8.DecodeRuneInString(text)
+					text = text[size:]
+				}
+			}
+			m.screened[index] = text
+			m.unreleased[index] = ""
+		}
+	}
+	if m.holdback != nil {
+		m.holdback(ctx, m.now().Sub(m.pendingSince))
+	}
+	released := m.pending
+	m.pending = nil
+	return released, nil
+}
+
+// terminate returns the chunk finishing all the choices with the "content_filter" finish reason followed by [DONE].
+func (m *streamModerator) terminate() ([]byte, error) {
+	data, err := sjson.SetBytes(m.lastChunk, "choices", []any{})
+	if err == nil {
+		data, err = sjson.DeleteBytes(data, "usage")
+	}
+	indexes := make([]int64, 0, len(m.screened))
+	for index := range m.screened {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+	for _, index := range indexes {
+		if err != nil {
+			break
+		}
+		data, err = sjson.SetRawBytes(data, "choices.-1", fmt.Appendf(nil, `{"index":%d,"delta":{},"finish_reason":%q}`,
+			index, openai.ChatCompletionChoicesFinishReasonContentFilter))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to terminate stream: %w", err)
+	}
+	return append(append([]byte("data: "), data...), "\n\ndata: [DONE]\n\n"...), nil
+}
+
+// startStreamModeration starts holding back the streamed completions with the stream moderation of the rule.
+func (c *chatCompletionProcessorRouterFilter) startStreamModeration() {
+	rule := c.guardrailRule
+	c.streamModerator = newStreamModerator(cmp.Or(rule.StreamModeration.WindowSize, defaultStreamModerationWindowSize), c.checkStreamWindow)
+	if c.guardrailMetrics != nil {
+		c.streamModerator.holdback = func(ctx context.Context, d time.Duration) {
+			c.guardrailMetrics.RecordHoldback(ctx, string(rule.Name), d)
+		}
+	}
+}
+
+// checkStreamWindow screens the texts of the choices of the streamed completions with the deny patterns and the
+// output guardrails of the rule, and returns the name of the violation, if any.
+func (c *chatCompletionProcessorRouterFilter) checkStreamWindow(ctx context.Context, texts map[int64]string) string {
+	rule := c.guardrailRule
+	indexes := make([]int64, 0, len(texts))
+	for index := range texts {
+		indexes = append(indexes, index)
+	}
+	slices.Sort(indexes)
+	joined := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		joined = append(joined, texts[index])
+	}
+	text := strings.Join(joined, "\n")
+	for _, re := range c.config.denyPatterns[rule.Name] {
+		if re.MatchString(text) {
+			c.logger.Info("stream moderation deny pattern matched", "pattern", re.String())
+			if c.guardrailMetrics != nil {
+				c.guardrailMetrics.RecordViolation(ctx, string(rule.Name), streamModerationDenyList, string(guardrailPhaseOutput))
+			}
+			return streamModerationDenyList
+		}
+	}
+	verdicts := evaluateGuardrails(ctx, c.config, rule.Guardrails, guardrailPhaseOutput, text)
+	return c.handleGuardrailVerdicts(ctx, rule, guardrailPhaseOutput, verdicts)
+}
+
+// moderateChatCompletionResponseBody passes the chunk of the streamed response body through the stream moderator,
+// and adds the annotations of the guardrails to the dynamic metadata at the end of the stream.
+func (c *chatCompletionProcessorRouterFilter) moderateChatCompletionResponseBody(ctx context.Context, resp *extprocv3.ProcessingResponse, body *extprocv3.HttpBody) error {
+	common := resp.GetResponseBody().GetResponse()
+	if common == nil {
+		return nil
+	}
+	raw := body.Body
+	if b := common.GetBodyMutation().GetBody(); b != nil {
+		raw = b
+	}
+	out, err := c.streamModerator.write(ctx, raw, body.EndOfStream)
+	if err != nil {
+		return err
+	}
+	common.BodyMutation = &extprocv3.BodyMutation{Mutation: &extprocv3.BodyMutation_Body{Body: out}}
+	if c.streamModerator.violation != "" || len(c.guardrailFlagged) > 0 {
+		// The terminated or flagged stream must not be served from the response cache as in applyOutputGuardrails.
+		c.cacheKey, c.cacheBody = "", nil
+	}
+	if body.EndOfStream {
+		resp.DynamicMetadata = mergeDynamicMetadata(resp.DynamicMetadata, c.config.metadataNamespace, c.guardrailAnnotations)
+		c.guardrailAnnotations = nil
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/metrics"
+)
+
+func Test_streamModerator(t *testing.T) {
+	// newModerator returns the moderator flagging the texts containing "bad", along with the texts checked.
+	newModerator := func() (*streamModerator, *[]map[int64]string) {
+		var checked []map[int64]string
+		m := newStreamModerator(16, func(_ context.Context, texts map[int64]string) string {
+			checked = append(checked, texts)
+			for _, text := range texts {
+				if strings.Contains(text, "bad") {
+					return "bad"
+				}
+			}
+			return ""
+		})
+		return m, &checked
+	}
+	// write writes the body to the moderator in the chunks of the given size, and returns the output of each chunk.
+	write := func(t *testing.T, m *streamModerator, body string, size int) []string {
+		var outs []string
+		for i := 0; i < len(body); i += size {
+			end := min(i+size, len(body))
+			b, err := m.write(t.Context(), []byte(body[i:end]), end == len(body))
+			require.NoError(t, err)
+			require.NotNil(t, b)
+			outs = append(outs, string(b))
+		}
+		return outs
+	}
+	const (
+		role   = "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n"
+		first  = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"The quick \"}}]}\n\n"
+		second = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"brown fox \"}}]}\n\n"
+		third  = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"jumps\"},\"finish_reason\":\"stop\"}]}\n\n"
+		done   = "data: [DONE]\n\n"
+	)
+
+	t.Run("window", func(t *testing.T) {
+		m, checked := newModerator()
+		now := time.Unix(0, 0)
+		m.now = func() time.Time { return now }
+		var holdbacks []time.Duration
+		m.holdback = func(_ context.Context, d time.Duration) { holdbacks = append(holdbacks, d) }
+
+		// step writes the event to the moderator before the end of the stream.
+		step := func(event string) string {
+			b, err := m.write(t.Context(), []byte(event), false)
+			require.NoError(t, err)
+			return string(b)
+		}
+		require.Equal(t, role, step(role))
+		require.Empty(t, step(first))
+		now = now.Add(time.Second)
+		// The window is full with the second chunk, so both chunks are released.
+		require.Equal(t, first+second, step(second))
+		require.Empty(t, step(third))
+		now = now.Add(time.Second)
+		require.Equal(t, []string{third + done}, write(t, m, done, len(done)))
+		require.Equal(t, []map[int64]string{
+			{0: "The quick brown fox "},
+			// The tail of the previous window is screened along with the next window.
+			{0: "quick brown fox jumps"},
+		}, *checked)
+		require.Equal(t, []time.Duration{time.Second, time.Second}, holdbacks)
+	})
+
+	t.Run("split events", func(t *testing.T) {
+		const body = role + first + second + third + done
+		for size := 1; size <= len(body); size++ {
+			m, _ := newModerator()
+			require.Equal(t, body, strings.Join(write(t, m, body, size), ""), size)
+		}
+	})
+
+	t.Run("end of stream without done", func(t *testing.T) {
+		m, _ := newModerator()
+		require.Equal(t, []string{first + ": partial"}, write(t, m, first+": partial", len(first)+9))
+	})
+
+	t.Run("violation", func(t *testing.T) {
+		const body = "data: {\"id\":\"x\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"This is a ba\"}}]}\n\n" +
+			"data: {\"id\":\"x\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"d answer\"}}],\"usage\":{\"total_tokens\":3}}\n\n" +
+			"data: {\"id\":\"x\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\" and more\"}}]}\n\n" +
+			done
+		for size := 1; size <= len(body); size++ {
+			m, _ := newModerator()
+			require.Equal(t, "data: {\"id\":\"x\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"content_filter\"}]}\n\n"+done,
+				strings.Join(write(t, m, body, size), ""), size)
+			require.Equal(t, "bad", m.violation)
+		}
+	})
+
+	t.Run("violation across windows", func(t *testing.T) {
+		m, _ := newModerator()
+		const body = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"sixteen chars ba\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":1,\"delta\":{\"content\":\"other\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"d\"}}]}\n\n"
+		out := write(t, m, body, len(body))
+		require.Equal(t, []string{"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"sixteen chars ba\"}}]}\n\n" +
+			"data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"content_filter\"},{\"index\":1,\"delta\":{},\"finish_reason\":\"content_filter\"}]}\n\n" +
+			done}, out)
+		// The rest of the stream is discarded.
+		require.Equal(t, []string{""}, write(t, m, second+done, len(second+done)))
+	})
+}
+
+func TestChatCompletion_streamModeration(t *testing.T) {
+	s, inputs := newModerationServer(t, `{"results":[{"flagged":true,"category_scores":{"violence":0.9}}]}`)
+	mr := metric.NewManualReader()
+	gm := metrics.NewGuardrail(metric.NewMeterProvider(metric.WithReader(mr)).Meter("test"))
+	rule := &filterapi.RouteRule{
+		Name: "some-route",
+		Guardrails: []filterapi.Guardrail{{
+			Name: "output", URL: s.URL, Path: "/v1/moderations", Type: filterapi.GuardrailTypeOpenAIModeration,
+			Output: true, Action: filterapi.GuardrailActionBlock,
+		}},
+		StreamModeration: &filterapi.StreamModeration{WindowSize: 64, DenyPatterns: []string{`sk-[a-z0-9]{8}`}},
+	}
+
+	// responseCache is the response cache of the route if set.
+	var responseCache *ResponseCache
+	// send sends the streamed request through the router filter and the response through the upstream filter, and
+	// returns the response body sent to the client.
+	send := func(t *testing.T, respBody string) string {
+		headers := map[string]string{":path": "/v1/chat/completions"}
+		config := &processorConfig{
+			modelNameHeaderKey:     "x-model-name",
+			selectedRouteHeaderKey: "x-route",
+			metadataNamespace:      "ns",
+			router:                 mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"},
+			rules:                  map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule},
+			denyPatterns:           map[filterapi.RouteRuleName][]*regexp.Regexp{"some-route": {regexp.MustCompile(`sk-[a-z0-9]{8}`)}},
+		}
+		rp := &chatCompletionProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default(), guardrailMetrics: gm,
+			responseCache: responseCache}
+		req, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: []byte(`{"model":"m","stream":true,"messages":[{"role":"user","content":"prompt"}]}`)})
+		require.NoError(t, err)
+		require.Equal(t, []string{"accept-encoding"}, req.GetRequestBody().GetResponse().GetHeaderMutation().GetRemoveHeaders())
+		uf := &chatCompletionProcessorUpstreamFilter{
+			config:         config,
+			requestHeaders: map[string]string{":path": "/v1/chat/completions"},
+			logger:         slog.Default(),
+			metrics:        &mockChatCompletionMetrics{},
+		}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, rp))
+		_, err = uf.ProcessRequestHeaders(t.Context(), nil)
+		require.NoError(t, err)
+		_, err = rp.ProcessResponseHeaders(t.Context(), &corev3.HeaderMap{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "200"}}})
+		require.NoError(t, err)
+		resp, err := rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{Body: []byte(respBody), EndOfStream: true})
+		require.NoError(t, err)
+		return string(resp.GetResponseBody().GetResponse().GetBodyMutation().GetBody())
+	}
+
+	t.Run("pass", func(t *testing.T) {
+		const respBody = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hello\"},\"finish_reason\":\"stop\"}]}\n\n" +
+			"data: [DONE]\n\n"
+		require.Equal(t, respBody, send(t, respBody))
+		require.Equal(t, "hello", (<-inputs)["input"])
+	})
+
+	t.Run("deny pattern", func(t *testing.T) {
+		const respBody = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"the key is sk-abcd1234\"}}]}\n\n" +
+			"data: [DONE]\n\n"
+		require.Equal(t, "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"content_filter\"}]}\n\n"+
+			"data: [DONE]\n\n", send(t, respBody))
+		// The moderation backend is not called once a deny pattern matches.
+		require.Empty(t, inputs)
+	})
+
+	t.Run("guardrail", func(t *testing.T) {
+		const respBody = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"a bad answer\"}}]}\n\n" +
+			"data: [DONE]\n\n"
+		require.Equal(t, "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"content_filter\"}]}\n\n"+
+			"data: [DONE]\n\n", send(t, respBody))
+		require.Equal(t, "a bad answer", (<-inputs)["input"])
+	})
+
+	t.Run("terminated stream not cached", func(t *testing.T) {
+		responseCache = NewResponseCache(1<<20, nil)
+		rule.ResponseCache = &filterapi.ResponseCache{TTL: time.Minute, AllTemperatures: true}
+		defer func() { responseCache, rule.ResponseCache = nil, nil }()
+		const respBody = "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"a bad answer\"}}]}\n\n" +
+			"data: [DONE]\n\n"
+		require.Contains(t, send(t, respBody), `"finish_reason":"content_filter"`)
+		<-inputs
+		require.Empty(t, responseCache.entries)
+	})
+
+	var data metricdata.ResourceMetrics
+	require.NoError(t, mr.Collect(t.Context(), &data))
+	counts := map[string]int{}
+	for _, m := range data.ScopeMetrics[0].Metrics {
+		switch d := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			for _, dp := range d.DataPoints {
+				counts[m.Name] += int(dp.Value)
+			}
+		case metricdata.Histogram[float64]:
+			for _, dp := range d.DataPoints {
+				counts[m.Name] += int(dp.Count) //nolint:gosec
+			}
+		}
+	}
+	require.Equal(t, map[string]int{"aigw.guardrail.violations": 3, "aigw.guardrail.holdback.duration": 1}, counts)
+}
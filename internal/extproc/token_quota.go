@@ -0,0 +1,116 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/quota"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+)
+
+// quotaConsumer is a token quota applied to a request along with the consumer of the request.
+type quotaConsumer struct {
+	quota    *filterapi.TokenQuota
+	consumer string
+}
+
+// checkTokenQuotas checks the token quotas of the rule for the consumers of the request, and returns the quotas that
+// apply to the request, or the immediate response with the OpenAI-style rate limit error if any of the budgets has
+// been exhausted. authenticated is the consumer authenticated with the consumer key, if any.
+//
+// The quotas with the consumer header are keyed by it, which is expected to be set by a trusted filter in front of
+// the gateway, e.g. per team, and the others are keyed by the authenticated consumer. The requests without the
+// consumer are exempted from the quota, or rejected when the quota requires the consumer header.
+//
+// The quotas that apply to the request are returned to deduct the token usage at the end of the response.
+// The failures of the quota store are logged and the request is let through so that the store is not a single
+// point of failure of the gateway.
+func checkTokenQuotas(ctx context.Context, quotas *quota.Quotas, logger *slog.Logger, rule *filterapi.RouteRule,
+	headers map[string]string, authenticated string,
+) ([]quotaConsumer, *extprocv3.ProcessingResponse) {
+	if quotas == nil {
+		return nil, nil
+	}
+	var ret []quotaConsumer
+	for i := range rule.TokenQuotas {
+		q := &rule.TokenQuotas[i]
+		consumer := authenticated
+		if q.ConsumerHeader != "" {
+			consumer = headers[strings.ToLower(q.ConsumerHeader)]
+		}
+		if consumer == "" {
+			if q.RequireConsumerHeader {
+				logger.Debug("token quota consumer missing", "quota", q.Name)
+				return nil, missingQuotaConsumerResponse(q)
+			}
+			continue
+		}
+		exceeded, err := quotas.Check(ctx, q, consumer)
+		if err != nil {
+			logger.Error("failed to check token quota", "quota", q.Name, "error", err)
+		} else if exceeded != nil {
+			logger.Debug("token quota exceeded", "quota", q.Name, "consumer", consumer, "window", exceeded.Window)
+			return nil, tokenQuotaExceededResponse(q, exceeded)
+		}
+		ret = append(ret, quotaConsumer{quota: q, consumer: consumer})
+	}
+	return ret, nil
+}
+
+// deductTokenQuotas deducts the token usage of the response from the quotas applied to the request.
+func deductTokenQuotas(ctx context.Context, quotas *quota.Quotas, logger *slog.Logger, qcs []quotaConsumer, usage translator.LLMTokenUsage) {
+	for _, qc := range qcs {
+		if err := quotas.Deduct(ctx, qc.quota, qc.consumer, quotaTokens(qc.quota.Type, usage)); err != nil {
+			logger.Error("failed to deduct token quota", "quota", qc.quota.Name, "error", err)
+		}
+	}
+}
+
+// quotaTokens returns the number of tokens of the usage counted against a quota of the given type.
+func quotaTokens(t filterapi.LLMRequestCostType, usage translator.LLMTokenUsage) int64 {
+	switch t {
+	case filterapi.LLMRequestCostTypeInputToken:
+		return int64(usage.InputTokens)
+	case filterapi.LLMRequestCostTypeOutputToken:
+		return int64(usage.OutputTokens)
+	default:
+		return int64(usage.TotalTokens)
+	}
+}
+
+// tokenQuotaExceededResponse returns the immediate response in the same format as the rate limit error of OpenAI.
+func tokenQuotaExceededResponse(q *filterapi.TokenQuota, e *quota.Exceeded) *extprocv3.ProcessingResponse {
+	unit := "min (TPM)"
+	if e.Window == quota.WindowDay {
+		unit = "day (TPD)"
+	}
+	retryAfter := int64(math.Ceil(e.ResetAfter.Seconds()))
+	resp := openAIErrorResponse(typev3.StatusCode_TooManyRequests, "tokens", "rate_limit_exceeded", "",
+		fmt.Sprintf("Rate limit reached for quota %s on tokens per %s: Limit %d, Used %d. Please try again in %ds.",
+			q.Name, unit, e.Limit, e.Used, retryAfter))
+	headers := resp.GetImmediateResponse().Headers
+	setHeader(headers, "retry-after", strconv.FormatInt(retryAfter, 10))
+	setHeader(headers, "x-ratelimit-limit-tokens", strconv.FormatInt(e.Limit, 10))
+	setHeader(headers, "x-ratelimit-remaining-tokens", "0")
+	return resp
+}
+
+// missingQuotaConsumerResponse returns the immediate response for the request without the consumer of a quota
+// that requires it.
+func missingQuotaConsumerResponse(q *filterapi.TokenQuota) *extprocv3.ProcessingResponse {
+	return openAIErrorResponse(typev3.StatusCode_BadRequest, "invalid_request_error", "missing_consumer", "",
+		fmt.Sprintf("The request must have the %s header for quota %s.", q.ConsumerHeader, q.Name))
+}
@@ -0,0 +1,207 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"fmt"
+	"log/slog"
+	"testing"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/quota"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+)
+
+func Test_quotaTokens(t *testing.T) {
+	usage := translator.LLMTokenUsage{InputTokens: 1, OutputTokens: 2, TotalTokens: 3}
+	require.Equal(t, int64(1), quotaTokens(filterapi.LLMRequestCostTypeInputToken, usage))
+	require.Equal(t, int64(2), quotaTokens(filterapi.LLMRequestCostTypeOutputToken, usage))
+	require.Equal(t, int64(3), quotaTokens(filterapi.LLMRequestCostTypeTotalToken, usage))
+}
+
+func TestChatCompletion_tokenQuotas(t *testing.T) {
+	rule := &filterapi.RouteRule{Name: "some-route", TokenQuotas: []filterapi.TokenQuota{
+		{Name: "ns/route/team", ConsumerHeader: "X-Team", Type: filterapi.LLMRequestCostTypeOutputToken, TokensPerMinute: 10},
+		{Name: "ns/route/user", ConsumerHeader: "x-user", Type: filterapi.LLMRequestCostTypeTotalToken, TokensPerDay: 1000},
+		{Name: "ns/route/key", Type: filterapi.LLMRequestCostTypeTotalToken, TokensPerMinute: 150},
+	}}
+	qs := quota.New(quota.NewMemoryStore())
+	config := &processorConfig{
+		consumerHeaderKey: "x-ai-eg-consumer",
+		rules:             map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule},
+	}
+	newRouterFilter := func(headers map[string]string) *chatCompletionProcessorRouterFilter {
+		config.router = mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		return &chatCompletionProcessorRouterFilter{
+			config:         config,
+			requestHeaders: headers,
+			logger:         slog.Default(),
+			quotas:         qs,
+		}
+	}
+
+	send := func(t *testing.T, headers map[string]string, outputTokens int) *extprocv3.ProcessingResponse {
+		rp := newRouterFilter(headers)
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: bodyFromModel(t, "some-model", false)})
+		require.NoError(t, err)
+		if resp.GetImmediateResponse() != nil {
+			return resp
+		}
+		uf := &chatCompletionProcessorUpstreamFilter{
+			config:         config,
+			requestHeaders: map[string]string{":path": "/v1/chat/completions"},
+			logger:         slog.Default(),
+			metrics:        &mockChatCompletionMetrics{},
+		}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, rp))
+
+		body := fmt.Sprintf(`{"usage":{"prompt_tokens":100,"completion_tokens":%d,"total_tokens":%d}}`, outputTokens, 100+outputTokens)
+		_, err = rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{Body: []byte(body), EndOfStream: true})
+		require.NoError(t, err)
+		require.Empty(t, rp.quotaConsumers)
+		return resp
+	}
+
+	t.Run("no consumer", func(t *testing.T) {
+		for range 3 {
+			resp := send(t, map[string]string{":path": "/foo"}, 100)
+			require.Nil(t, resp.GetImmediateResponse())
+		}
+	})
+
+	t.Run("unauthenticated consumer", func(t *testing.T) {
+		// The consumer header of the gateway sent by the client is ignored when no consumer is authenticated.
+		for range 3 {
+			headers := map[string]string{":path": "/foo", "x-ai-eg-consumer": "spoofed"}
+			resp := send(t, headers, 100)
+			require.Nil(t, resp.GetImmediateResponse())
+			require.NotContains(t, headers, "x-ai-eg-consumer")
+		}
+	})
+
+	t.Run("minute budget", func(t *testing.T) {
+		headers := map[string]string{":path": "/foo", "x-team": "team-a"}
+		resp := send(t, headers, 9)
+		require.Nil(t, resp.GetImmediateResponse())
+		// The last request is let through even though it exceeds the remaining budget.
+		resp = send(t, headers, 5)
+		require.Nil(t, resp.GetImmediateResponse())
+
+		resp = send(t, headers, 0)
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_TooManyRequests, ir.Status.Code)
+		require.Contains(t, string(ir.Body), `"type":"tokens"`)
+		require.Contains(t, string(ir.Body), `"code":"rate_limit_exceeded"`)
+		require.Contains(t, string(ir.Body), "Rate limit reached for quota ns/route/team on tokens per min (TPM): Limit 10, Used 14.")
+		headerValues := map[string]string{}
+		for _, h := range ir.Headers.SetHeaders {
+			headerValues[h.Header.Key] = string(h.Header.RawValue)
+		}
+		require.NotEmpty(t, headerValues["retry-after"])
+		require.Equal(t, "10", headerValues["x-ratelimit-limit-tokens"])
+		require.Equal(t, "0", headerValues["x-ratelimit-remaining-tokens"])
+
+		// Other consumers are not affected.
+		resp = send(t, map[string]string{":path": "/foo", "x-team": "team-b"}, 0)
+		require.Nil(t, resp.GetImmediateResponse())
+	})
+
+	t.Run("day budget", func(t *testing.T) {
+		headers := map[string]string{":path": "/foo", "x-user": "alice"}
+		resp := send(t, headers, 900)
+		require.Nil(t, resp.GetImmediateResponse())
+		resp = send(t, headers, 0)
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Contains(t, string(ir.Body), "Rate limit reached for quota ns/route/user on tokens per day (TPD): Limit 1000, Used 1000.")
+	})
+
+	t.Run("consumer key", func(t *testing.T) {
+		// The quota without the consumer header is keyed by the authenticated consumer, so changing the consumer
+		// headers never evades it, while the quotas with the consumer header are still keyed by it.
+		rule.RequireConsumerKey = true
+		config.consumerKeys = map[string]*filterapi.ConsumerKey{
+			hashConsumerKeyForTest("sk-carol"): {Consumer: "carol", RouteRules: []filterapi.RouteRuleName{"some-route"}},
+		}
+		t.Cleanup(func() { rule.RequireConsumerKey, config.consumerKeys = false, nil })
+		resp := send(t, map[string]string{":path": "/foo", "authorization": "Bearer sk-carol", "x-team": "team-e"}, 10)
+		require.Nil(t, resp.GetImmediateResponse())
+		resp = send(t, map[string]string{":path": "/foo", "authorization": "Bearer sk-carol", "x-team": "team-e"}, 0)
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Contains(t, string(ir.Body), "Rate limit reached for quota ns/route/team on tokens per min (TPM): Limit 10, Used 10.")
+
+		resp = send(t, map[string]string{":path": "/foo", "authorization": "Bearer sk-carol", "x-team": "team-f"}, 0)
+		require.Nil(t, resp.GetImmediateResponse())
+		resp = send(t, map[string]string{":path": "/foo", "authorization": "Bearer sk-carol", "x-team": "team-g"}, 0)
+		ir = resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Contains(t, string(ir.Body), "Rate limit reached for quota ns/route/key on tokens per min (TPM): Limit 150, Used 210.")
+	})
+
+	t.Run("require consumer header", func(t *testing.T) {
+		rule.TokenQuotas[0].RequireConsumerHeader = true
+		t.Cleanup(func() { rule.TokenQuotas[0].RequireConsumerHeader = false })
+		resp := send(t, map[string]string{":path": "/foo"}, 0)
+		ir := resp.GetImmediateResponse()
+		require.NotNil(t, ir)
+		require.Equal(t, typev3.StatusCode_BadRequest, ir.Status.Code)
+		require.Contains(t, string(ir.Body), `"code":"missing_consumer"`)
+		require.Contains(t, string(ir.Body), "The request must have the X-Team header for quota ns/route/team.")
+
+		resp = send(t, map[string]string{":path": "/foo", "x-team": "team-h"}, 0)
+		require.Nil(t, resp.GetImmediateResponse())
+	})
+}
+
+func TestEmbeddings_tokenQuotas(t *testing.T) {
+	rule := &filterapi.RouteRule{Name: "some-route", TokenQuotas: []filterapi.TokenQuota{
+		{Name: "ns/route/team", ConsumerHeader: "x-team", Type: filterapi.LLMRequestCostTypeInputToken, TokensPerMinute: 10},
+	}}
+	qs := quota.New(quota.NewMemoryStore())
+	config := &processorConfig{rules: map[filterapi.RouteRuleName]*filterapi.RouteRule{"some-route": rule}}
+
+	send := func(t *testing.T, headers map[string]string, inputTokens int) *extprocv3.ProcessingResponse {
+		config.router = mockRouter{t: t, expHeaders: headers, retRouteName: "some-route"}
+		rp := &embeddingsProcessorRouterFilter{config: config, requestHeaders: headers, logger: slog.Default(), quotas: qs}
+		resp, err := rp.ProcessRequestBody(t.Context(), &extprocv3.HttpBody{Body: embeddingBodyFromModel(t, "some-model")})
+		require.NoError(t, err)
+		if resp.GetImmediateResponse() != nil {
+			return resp
+		}
+		uf := &embeddingsProcessorUpstreamFilter{
+			config:         config,
+			requestHeaders: map[string]string{":path": "/v1/embeddings"},
+			logger:         slog.Default(),
+			metrics:        &mockEmbeddingsMetrics{},
+		}
+		require.NoError(t, uf.SetBackend(t.Context(), &filterapi.Backend{
+			Name: "backend", Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI},
+		}, nil, rp))
+
+		body := fmt.Sprintf(`{"data":[],"usage":{"prompt_tokens":%d,"total_tokens":%d}}`, inputTokens, inputTokens)
+		_, err = rp.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{Body: []byte(body), EndOfStream: true})
+		require.NoError(t, err)
+		require.Empty(t, rp.quotaConsumers)
+		return resp
+	}
+
+	headers := map[string]string{":path": "/foo", "x-team": "team-a"}
+	require.Nil(t, send(t, headers, 10).GetImmediateResponse())
+	ir := send(t, headers, 0).GetImmediateResponse()
+	require.NotNil(t, ir)
+	require.Equal(t, typev3.StatusCode_TooManyRequests, ir.Status.Code)
+	require.Contains(t, string(ir.Body), "Rate limit reached for quota ns/route/team on tokens per min (TPM): Limit 10, Used 10.")
+	// Other consumers are not affected.
+	require.Nil(t, send(t, map[string]string{":path": "/foo", "x-team": "team-b"}, 0).GetImmediateResponse())
+}
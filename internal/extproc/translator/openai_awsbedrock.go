@@ -164,8 +164,9 @@ func (o *openAIToAWSBedrockTranslatorV1ChatCompletion) openAIToolsToBedrockToolC
 // https://developer.mozilla.org/en-US/docs/Web/URI/Schemes/data#syntax
 var regDataURI = regexp.MustCompile(`\Adata:(.+?)?(;base64)?,`)
 
-// parseDataURI parse data uri example: data:image/jpeg;base64,/9j/4AAQSkZJRgABAgAAZABkAAD.
-func parseDataURI(uri string) (string, []byte, error) {
+// ParseDataURI parses the data uri, e.g. data:image/jpeg;base64,/9j/4AAQSkZJRgABAgAAZABkAAD, and returns the content
+// type and the decoded data.
+func ParseDataURI(uri string) (string, []byte, error) {
 	matches := regDataURI.FindStringSubmatch(uri)
 	if len(matches) != 3 {
 		return "", nil, fmt.Errorf("data uri does not have a valid format")
@@ -202,7 +203,7 @@ func (o *openAIToAWSBedrockTranslatorV1ChatCompletion) openAIMessageToBedrockMes
 				})
 			} else if contentPart.ImageContent != nil {
 				imageContentPart := contentPart.ImageContent
-				contentType, b, err := parseDataURI(imageContentPart.ImageURL.URL)
+				contentType, b, err := ParseDataURI(imageContentPart.ImageURL.URL)
 				if err != nil {
 					return nil, fmt.Errorf("failed to parse image URL: %s %w", imageContentPart.ImageURL.URL, err)
 				}
@@ -545,11 +546,8 @@ func (o *openAIToAWSBedrockTranslatorV1ChatCompletion) ResponseBody(respHeaders
 		for i := range o.events {
 			event := &o.events[i]
 			if usage := event.Usage; usage != nil {
-				tokenUsage = LLMTokenUsage{
-					InputTokens:  uint32(usage.InputTokens),  //nolint:gosec
-					OutputTokens: uint32(usage.OutputTokens), //nolint:gosec
-					TotalTokens:  uint32(usage.TotalTokens),  //nolint:gosec
-				}
+				oaiUsage := openAIUsageFromBedrock(usage)
+				tokenUsage = tokenUsageFromOpenAI(&oaiUsage)
 			}
 			oaiEvent, ok := o.convertEvent(event)
 			if !ok {
@@ -581,16 +579,8 @@ func (o *openAIToAWSBedrockTranslatorV1ChatCompletion) ResponseBody(respHeaders
 	}
 	// Convert token usage.
 	if bedrockResp.Usage != nil {
-		tokenUsage = LLMTokenUsage{
-			InputTokens:  uint32(bedrockResp.Usage.InputTokens),  //nolint:gosec
-			OutputTokens: uint32(bedrockResp.Usage.OutputTokens), //nolint:gosec
-			TotalTokens:  uint32(bedrockResp.Usage.TotalTokens),  //nolint:gosec
-		}
-		openAIResp.Usage = openai.ChatCompletionResponseUsage{
-			TotalTokens:      bedrockResp.Usage.TotalTokens,
-			PromptTokens:     bedrockResp.Usage.InputTokens,
-			CompletionTokens: bedrockResp.Usage.OutputTokens,
-		}
+		openAIResp.Usage = openAIUsageFromBedrock(bedrockResp.Usage)
+		tokenUsage = tokenUsageFromOpenAI(&openAIResp.Usage)
 	}
 
 	// AWS Bedrock does not support N(multiple choices) > 0, so there could be only one choice.
@@ -657,11 +647,8 @@ func (o *openAIToAWSBedrockTranslatorV1ChatCompletion) convertEvent(event *awsbe
 
 	switch {
 	case event.Usage != nil:
-		chunk.Usage = &openai.ChatCompletionResponseUsage{
-			TotalTokens:      event.Usage.TotalTokens,
-			PromptTokens:     event.Usage.InputTokens,
-			CompletionTokens: event.Usage.OutputTokens,
-		}
+		usage := openAIUsageFromBedrock(event.Usage)
+		chunk.Usage = &usage
 	case event.Role != nil:
 		chunk.Choices = append(chunk.Choices, openai.ChatCompletionResponseChunkChoice{
 			Delta: &openai.ChatCompletionResponseChunkChoiceDelta{
@@ -723,3 +710,19 @@ func (o *openAIToAWSBedrockTranslatorV1ChatCompletion) convertEvent(event *awsbe
 	}
 	return chunk, true
 }
+
+// openAIUsageFromBedrock converts the token usage of AWS Bedrock to the OpenAI format.
+//
+// AWS Bedrock reports the input tokens read from or written to the prompt cache separately from the input tokens,
+// while OpenAI includes them in the prompt tokens, so they are added to the prompt tokens.
+func openAIUsageFromBedrock(usage *awsbedrock.TokenUsage) openai.ChatCompletionResponseUsage {
+	ret := openai.ChatCompletionResponseUsage{
+		TotalTokens:      usage.TotalTokens,
+		PromptTokens:     usage.InputTokens + usage.CacheReadInputTokens + usage.CacheWriteInputTokens,
+		CompletionTokens: usage.OutputTokens,
+	}
+	if usage.CacheReadInputTokens > 0 {
+		ret.PromptTokensDetails = &openai.PromptTokensDetails{CachedTokens: usage.CacheReadInputTokens}
+	}
+	return ret
+}
@@ -929,12 +929,14 @@ func TestOpenAIToAWSBedrockTranslatorV1ChatCompletion_ResponseBody(t *testing.T)
 		require.Error(t, err)
 	})
 	tests := []struct {
-		name   string
-		input  awsbedrock.ConverseResponse
-		output openai.ChatCompletionResponse
+		name     string
+		input    awsbedrock.ConverseResponse
+		output   openai.ChatCompletionResponse
+		expUsage LLMTokenUsage
 	}{
 		{
-			name: "basic_testing",
+			name:     "basic_testing",
+			expUsage: LLMTokenUsage{InputTokens: 10, OutputTokens: 20, TotalTokens: 30},
 			input: awsbedrock.ConverseResponse{
 				Usage: &awsbedrock.TokenUsage{
 					InputTokens:  10,
@@ -972,7 +974,46 @@ func TestOpenAIToAWSBedrockTranslatorV1ChatCompletion_ResponseBody(t *testing.T)
 			},
 		},
 		{
-			name: "test stop reason",
+			name:     "prompt cache",
+			expUsage: LLMTokenUsage{InputTokens: 115, OutputTokens: 20, TotalTokens: 135, CachedInputTokens: 100},
+			input: awsbedrock.ConverseResponse{
+				Usage: &awsbedrock.TokenUsage{
+					InputTokens:           10,
+					OutputTokens:          20,
+					TotalTokens:           135,
+					CacheReadInputTokens:  100,
+					CacheWriteInputTokens: 5,
+				},
+				Output: &awsbedrock.ConverseOutput{
+					Message: awsbedrock.Message{
+						Role:    awsbedrock.ConversationRoleAssistant,
+						Content: []*awsbedrock.ContentBlock{{Text: ptr.To("response")}},
+					},
+				},
+			},
+			output: openai.ChatCompletionResponse{
+				Object: "chat.completion",
+				Usage: openai.ChatCompletionResponseUsage{
+					TotalTokens:         135,
+					PromptTokens:        115,
+					CompletionTokens:    20,
+					PromptTokensDetails: &openai.PromptTokensDetails{CachedTokens: 100},
+				},
+				Choices: []openai.ChatCompletionResponseChoice{
+					{
+						Index: 0,
+						Message: openai.ChatCompletionResponseChoiceMessage{
+							Content: ptr.To("response"),
+							Role:    awsbedrock.ConversationRoleAssistant,
+						},
+						FinishReason: openai.ChatCompletionChoicesFinishReasonStop,
+					},
+				},
+			},
+		},
+		{
+			name:     "test stop reason",
+			expUsage: LLMTokenUsage{InputTokens: 10, OutputTokens: 20, TotalTokens: 30},
 			input: awsbedrock.ConverseResponse{
 				Usage: &awsbedrock.TokenUsage{
 					InputTokens:  10,
@@ -1056,7 +1097,8 @@ func TestOpenAIToAWSBedrockTranslatorV1ChatCompletion_ResponseBody(t *testing.T)
 			},
 		},
 		{
-			name: "merge content",
+			name:     "merge content",
+			expUsage: LLMTokenUsage{InputTokens: 10, OutputTokens: 20, TotalTokens: 30},
 			input: awsbedrock.ConverseResponse{
 				Usage: &awsbedrock.TokenUsage{
 					InputTokens:  10,
@@ -1130,12 +1172,7 @@ func TestOpenAIToAWSBedrockTranslatorV1ChatCompletion_ResponseBody(t *testing.T)
 			var openAIResp openai.ChatCompletionResponse
 			err = json.Unmarshal(newBody, &openAIResp)
 			require.NoError(t, err)
-			require.Equal(t,
-				LLMTokenUsage{
-					InputTokens:  uint32(tt.output.Usage.PromptTokens),     //nolint:gosec
-					OutputTokens: uint32(tt.output.Usage.CompletionTokens), //nolint:gosec
-					TotalTokens:  uint32(tt.output.Usage.TotalTokens),      //nolint:gosec
-				}, usedToken)
+			require.Equal(t, tt.expUsage, usedToken)
 			if !cmp.Equal(openAIResp, tt.output) {
 				t.Errorf("ConvertOpenAIToBedrock(), diff(got, expected) = %s\n", cmp.Diff(openAIResp, tt.output))
 			}
@@ -6,6 +6,7 @@
 package translator
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
@@ -50,3 +51,14 @@ func TestOpenAIToAzureOpenAITranslatorV1ChatCompletion_RequestBody(t *testing.T)
 		require.Equal(t, "/openai/deployments/"+modelName+"/chat/completions?api-version=some-version", string(hm.SetHeaders[0].Header.RawValue))
 	})
 }
+
+func TestOpenAIToAzureOpenAITranslatorV1ChatCompletion_ResponseBody(t *testing.T) {
+	o := NewChatCompletionOpenAIToAzureOpenAITranslator("some-version", "")
+	body := `{"usage":{"prompt_tokens":100,"completion_tokens":50,"total_tokens":150,` +
+		`"prompt_tokens_details":{"cached_tokens":60},"completion_tokens_details":{"reasoning_tokens":30}}}`
+	_, _, usedToken, err := o.ResponseBody(nil, bytes.NewBufferString(body), false)
+	require.NoError(t, err)
+	require.Equal(t, LLMTokenUsage{
+		InputTokens: 100, OutputTokens: 50, TotalTokens: 150, CachedInputTokens: 60, ReasoningTokens: 30,
+	}, usedToken)
+}
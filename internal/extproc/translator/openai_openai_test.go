@@ -203,6 +203,17 @@ data: [DONE]
 			require.NoError(t, err)
 			require.Equal(t, LLMTokenUsage{TotalTokens: 42}, usedToken)
 		})
+		t.Run("token details", func(t *testing.T) {
+			body := `{"usage":{"prompt_tokens":100,"completion_tokens":50,"total_tokens":150,` +
+				`"prompt_tokens_details":{"cached_tokens":60,"audio_tokens":0},` +
+				`"completion_tokens_details":{"reasoning_tokens":30,"audio_tokens":0}}}`
+			o := &openAIToOpenAITranslatorV1ChatCompletion{}
+			_, _, usedToken, err := o.ResponseBody(nil, bytes.NewBufferString(body), false)
+			require.NoError(t, err)
+			require.Equal(t, LLMTokenUsage{
+				InputTokens: 100, OutputTokens: 50, TotalTokens: 150, CachedInputTokens: 60, ReasoningTokens: 30,
+			}, usedToken)
+		})
 	})
 }
 
@@ -216,6 +227,14 @@ func TestExtractUsageFromBufferEvent(t *testing.T) {
 		require.Nil(t, o.buffered)
 	})
 
+	t.Run("token details", func(t *testing.T) {
+		o := &openAIToOpenAITranslatorV1ChatCompletion{}
+		o.buffered = []byte(`data: {"usage": {"prompt_tokens": 13, "total_tokens": 25, "completion_tokens": 12, ` +
+			`"prompt_tokens_details": {"cached_tokens": 8}, "completion_tokens_details": {"reasoning_tokens": 4}}}` + "\n")
+		usedToken := o.extractUsageFromBufferEvent()
+		require.Equal(t, LLMTokenUsage{InputTokens: 13, OutputTokens: 12, TotalTokens: 25, CachedInputTokens: 8, ReasoningTokens: 4}, usedToken)
+	})
+
 	t.Run("valid usage data after invalid", func(t *testing.T) {
 		o := &openAIToOpenAITranslatorV1ChatCompletion{}
 		o.buffered = []byte("data: invalid\ndata: {\"usage\": {\"total_tokens\": 42}}\n")
@@ -120,4 +120,41 @@ type LLMTokenUsage struct {
 	OutputTokens uint32
 	// TotalTokens is the total number of tokens consumed.
 	TotalTokens uint32
+	// CachedInputTokens is the number of input tokens read from the prompt cache, which are included in InputTokens.
+	CachedInputTokens uint32
+	// ReasoningTokens is the number of output tokens used for reasoning, which are included in OutputTokens.
+	ReasoningTokens uint32
+	// AudioInputTokens is the number of audio input tokens, which are included in InputTokens.
+	AudioInputTokens uint32
+	// AudioOutputTokens is the number of audio output tokens, which are included in OutputTokens.
+	AudioOutputTokens uint32
+}
+
+// Add adds the token usage of other to u.
+func (u *LLMTokenUsage) Add(other *LLMTokenUsage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.TotalTokens += other.TotalTokens
+	u.CachedInputTokens += other.CachedInputTokens
+	u.ReasoningTokens += other.ReasoningTokens
+	u.AudioInputTokens += other.AudioInputTokens
+	u.AudioOutputTokens += other.AudioOutputTokens
+}
+
+// tokenUsageFromOpenAI converts the usage in the OpenAI format to LLMTokenUsage.
+func tokenUsageFromOpenAI(usage *openai.ChatCompletionResponseUsage) LLMTokenUsage {
+	ret := LLMTokenUsage{
+		InputTokens:  uint32(usage.PromptTokens),     //nolint:gosec
+		OutputTokens: uint32(usage.CompletionTokens), //nolint:gosec
+		TotalTokens:  uint32(usage.TotalTokens),      //nolint:gosec
+	}
+	if d := usage.PromptTokensDetails; d != nil {
+		ret.CachedInputTokens = uint32(d.CachedTokens) //nolint:gosec
+		ret.AudioInputTokens = uint32(d.AudioTokens)   //nolint:gosec
+	}
+	if d := usage.CompletionTokensDetails; d != nil {
+		ret.ReasoningTokens = uint32(d.ReasoningTokens) //nolint:gosec
+		ret.AudioOutputTokens = uint32(d.AudioTokens)   //nolint:gosec
+	}
+	return ret
 }
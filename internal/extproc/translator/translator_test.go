@@ -10,6 +10,8 @@ import (
 
 	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
 	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/internal/apischema/openai"
 )
 
 func TestIsGoodStatusCode(t *testing.T) {
@@ -32,3 +34,25 @@ func TestSetContentLength(t *testing.T) {
 	require.Len(t, hm.SetHeaders, 1)
 	require.Equal(t, "4", string(hm.SetHeaders[0].Header.RawValue))
 }
+
+func TestLLMTokenUsage_Add(t *testing.T) {
+	u := LLMTokenUsage{InputTokens: 1, OutputTokens: 2, TotalTokens: 3, CachedInputTokens: 4, ReasoningTokens: 5}
+	u.Add(&LLMTokenUsage{InputTokens: 10, OutputTokens: 20, TotalTokens: 30, AudioInputTokens: 6, AudioOutputTokens: 7})
+	require.Equal(t, LLMTokenUsage{
+		InputTokens: 11, OutputTokens: 22, TotalTokens: 33, CachedInputTokens: 4, ReasoningTokens: 5,
+		AudioInputTokens: 6, AudioOutputTokens: 7,
+	}, u)
+}
+
+func TestTokenUsageFromOpenAI(t *testing.T) {
+	require.Equal(t, LLMTokenUsage{InputTokens: 1, OutputTokens: 2, TotalTokens: 3},
+		tokenUsageFromOpenAI(&openai.ChatCompletionResponseUsage{PromptTokens: 1, CompletionTokens: 2, TotalTokens: 3}))
+	require.Equal(t, LLMTokenUsage{
+		InputTokens: 100, OutputTokens: 50, TotalTokens: 150,
+		CachedInputTokens: 60, AudioInputTokens: 10, ReasoningTokens: 30, AudioOutputTokens: 5,
+	}, tokenUsageFromOpenAI(&openai.ChatCompletionResponseUsage{
+		PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150,
+		PromptTokensDetails:     &openai.PromptTokensDetails{CachedTokens: 60, AudioTokens: 10},
+		CompletionTokensDetails: &openai.CompletionTokensDetails{ReasoningTokens: 30, AudioTokens: 5},
+	}))
+}
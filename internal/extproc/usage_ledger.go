@@ -0,0 +1,40 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/envoyproxy/ai-gateway/internal/extproc/ledger"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+)
+
+// recordUsage fills in the fields of the usage record common to all the endpoints and queues it to the ledger.
+// This is a no-op when the ledger is not configured.
+func recordUsage(l *ledger.Ledger, rec *ledger.Record, requestHeaders, responseHeaders map[string]string,
+	usage *translator.LLMTokenUsage, costMicroUSD float64, requestStart time.Time,
+) {
+	if l == nil {
+		return
+	}
+	now := time.Now()
+	rec.Time = now
+	rec.RequestID = requestHeaders["x-request-id"]
+	rec.Status, _ = strconv.Atoi(responseHeaders[":status"])
+	rec.InputTokens = usage.InputTokens
+	rec.OutputTokens = usage.OutputTokens
+	rec.TotalTokens = usage.TotalTokens
+	rec.CachedInputTokens = usage.CachedInputTokens
+	rec.ReasoningTokens = usage.ReasoningTokens
+	rec.AudioInputTokens = usage.AudioInputTokens
+	rec.AudioOutputTokens = usage.AudioOutputTokens
+	rec.CostUSD = costMicroUSD / 1e6
+	if !requestStart.IsZero() {
+		rec.LatencyMs = now.Sub(requestStart).Milliseconds()
+	}
+	l.Record(rec)
+}
@@ -0,0 +1,102 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package extproc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/ledger"
+	"github.com/envoyproxy/ai-gateway/internal/extproc/translator"
+)
+
+// chanSink is a [ledger.Sink] that sends the written records to the channel.
+type chanSink chan ledger.Record
+
+func (c chanSink) Write(_ context.Context, records []ledger.Record) error {
+	for _, r := range records {
+		c <- r
+	}
+	return nil
+}
+
+func (c chanSink) Close(context.Context) error { return nil }
+
+func Test_recordUsage(t *testing.T) {
+	sink := make(chanSink, 10)
+	l := ledger.New(sink, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer func() { require.NoError(t, l.Close(t.Context())) }()
+
+	t.Run("chat", func(t *testing.T) {
+		mt := &mockTranslator{
+			t: t, retUsedToken: translator.LLMTokenUsage{InputTokens: 10, OutputTokens: 20, TotalTokens: 30, CachedInputTokens: 4},
+		}
+		// The consumer is the one authenticated with the consumer key, not the header sent by the client.
+		p := &chatCompletionProcessorUpstreamFilter{
+			translator:        mt,
+			logger:            slog.New(slog.NewTextHandler(io.Discard, nil)),
+			metrics:           &mockChatCompletionMetrics{},
+			config:            &processorConfig{},
+			stream:            true,
+			requestHeaders:    map[string]string{"x-request-id": "req-1", "x-team-id": "team-b"},
+			consumer:          "team-a",
+			responseHeaders:   map[string]string{":status": "200"},
+			backendName:       "openai",
+			modelNameOverride: "gpt-4o",
+			pricing:           []filterapi.ModelPricing{{Model: "*", InputPerMillionTokens: 1e6, OutputPerMillionTokens: 2e6, CachedInputPerMillionTokens: 1e6}},
+			requestStart:      time.Now().Add(-time.Second),
+			usageLedger:       l,
+		}
+		// Records are only written at the end of the stream.
+		_, err := p.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{})
+		require.NoError(t, err)
+		_, err = p.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{EndOfStream: true})
+		require.NoError(t, err)
+
+		rec := <-sink
+		require.NotZero(t, rec.Time)
+		require.GreaterOrEqual(t, rec.LatencyMs, int64(1000))
+		rec.Time, rec.LatencyMs = time.Time{}, 0
+		require.Equal(t, ledger.Record{
+			RequestID: "req-1", Consumer: "team-a", Operation: "chat", Model: "gpt-4o", Backend: "openai",
+			Status: 200, Stream: true, InputTokens: 20, OutputTokens: 40, TotalTokens: 60, CachedInputTokens: 8,
+			CostUSD: 100,
+		}, rec)
+	})
+
+	t.Run("embedding", func(t *testing.T) {
+		p := &embeddingsProcessorUpstreamFilter{
+			translator:      &mockEmbeddingTranslator{t: t, retUsedToken: translator.LLMTokenUsage{InputTokens: 5, TotalTokens: 5}},
+			logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+			metrics:         &mockEmbeddingsMetrics{},
+			config:          &processorConfig{modelNameHeaderKey: "x-model"},
+			requestHeaders:  map[string]string{"x-model": "text-embedding-3-small"},
+			responseHeaders: map[string]string{":status": "503"},
+			backendName:     "openai",
+			usageLedger:     l,
+		}
+		_, err := p.ProcessResponseBody(t.Context(), &extprocv3.HttpBody{EndOfStream: true})
+		require.NoError(t, err)
+
+		rec := <-sink
+		rec.Time, rec.LatencyMs = time.Time{}, 0
+		require.Equal(t, ledger.Record{
+			Operation: "embedding", Model: "text-embedding-3-small", Backend: "openai", Status: 503,
+			InputTokens: 5, TotalTokens: 5,
+		}, rec)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		recordUsage(nil, &ledger.Record{}, nil, nil, &translator.LLMTokenUsage{}, 0, time.Time{})
+	})
+}
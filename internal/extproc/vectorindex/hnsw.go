@@ -0,0 +1,256 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package vectorindex
+
+import (
+	"container/heap"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+const (
+	// hnswM is the number of the neighbors of a node on each layer except for the bottom one, which has twice as many.
+	hnswM = 16
+	// hnswEFConstruction is the number of the candidates considered when connecting a new node.
+	hnswEFConstruction = 100
+	// hnswEFSearch is the number of the candidates considered when searching.
+	hnswEFSearch = 50
+)
+
+// HNSW is an in-memory [Index] implementing the Hierarchical Navigable Small World graph, which finds the
+// approximate nearest neighbor in logarithmic time. See https://arxiv.org/abs/1603.09320.
+//
+// The removed vectors are only marked as deleted so that the graph stays connected, and the graph is rebuilt
+// from the remaining vectors once the deleted ones outnumber them.
+type HNSW struct {
+	nodes []*hnswNode
+	ids   map[string]int
+	// entry is the id of the node the searches start from, which is on the top layer, or -1 if the graph is empty.
+	entry    int
+	maxLevel int
+	dim      int
+	deleted  int
+	rng      *rand.Rand
+}
+
+type hnswNode struct {
+	key    string
+	vector []float32
+	// neighbors is the ids of the neighbors on each layer the node is on.
+	neighbors [][]int
+	deleted   bool
+}
+
+// NewHNSW creates a new empty HNSW index.
+func NewHNSW() *HNSW {
+	return &HNSW{ids: make(map[string]int), entry: -1, rng: rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))} //nolint:gosec
+}
+
+// Add implements [Index.Add].
+func (h *HNSW) Add(key string, vector []float32) {
+	if h.entry >= 0 && len(vector) != h.dim {
+		return
+	}
+	vector = normalize(vector)
+	if vector == nil {
+		return
+	}
+	if _, ok := h.ids[key]; ok {
+		h.Remove(key)
+	}
+	h.insert(&hnswNode{key: key, vector: vector})
+}
+
+func (h *HNSW) insert(n *hnswNode) {
+	// The level is exponentially distributed so that each layer has 1/M of the nodes of the layer below.
+	level := int(-math.Log(1-h.rng.Float64()) / math.Log(hnswM))
+	n.neighbors = make([][]int, level+1)
+	id := len(h.nodes)
+	h.nodes = append(h.nodes, n)
+	h.ids[n.key] = id
+	if h.entry < 0 {
+		h.entry, h.maxLevel, h.dim = id, level, len(n.vector)
+		return
+	}
+
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(n.vector, ep, l)
+	}
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(n.vector, ep, hnswEFConstruction, l)
+		maxNeighbors := hnswM
+		if l == 0 {
+			maxNeighbors = 2 * hnswM
+		}
+		for _, c := range candidates[:min(hnswM, len(candidates))] {
+			n.neighbors[l] = append(n.neighbors[l], c.id)
+			neighbor := h.nodes[c.id]
+			neighbor.neighbors[l] = append(neighbor.neighbors[l], id)
+			if len(neighbor.neighbors[l]) > maxNeighbors {
+				h.prune(neighbor, l, maxNeighbors)
+			}
+		}
+		ep = candidates[0].id
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = id, level
+	}
+}
+
+// prune keeps the given number of the neighbors of the node on the layer closest to it.
+func (h *HNSW) prune(n *hnswNode, level, keep int) {
+	candidates := make([]candidate, len(n.neighbors[level]))
+	for i, id := range n.neighbors[level] {
+		candidates[i] = candidate{id: id, similarity: dot(n.vector, h.nodes[id].vector)}
+	}
+	slices.SortFunc(candidates, compareCandidates)
+	n.neighbors[level] = n.neighbors[level][:0]
+	for _, c := range candidates[:keep] {
+		n.neighbors[level] = append(n.neighbors[level], c.id)
+	}
+}
+
+// greedy returns the id of the node closest to the vector on the layer reachable from the entry point
+// by always moving to the closest neighbor.
+func (h *HNSW) greedy(vector []float32, ep, level int) int {
+	best := dot(vector, h.nodes[ep].vector)
+	for changed := true; changed; {
+		changed = false
+		for _, id := range h.nodes[ep].neighbors[level] {
+			if s := dot(vector, h.nodes[id].vector); s > best {
+				ep, best, changed = id, s, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns up to ef nodes closest to the vector on the layer reachable from the entry point,
+// ordered from the closest one.
+func (h *HNSW) searchLayer(vector []float32, ep, ef, level int) []candidate {
+	visited := map[int]struct{}{ep: {}}
+	first := candidate{id: ep, similarity: dot(vector, h.nodes[ep].vector)}
+	// candidates pops the closest node first to explore, while results pops the farthest one first to evict.
+	candidates := &candidateHeap{items: []candidate{first}, closestFirst: true}
+	results := &candidateHeap{items: []candidate{first}}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.similarity < results.items[0].similarity {
+			break
+		}
+		for _, id := range h.nodes[c.id].neighbors[level] {
+			if _, ok := visited[id]; ok {
+				continue
+			}
+			visited[id] = struct{}{}
+			s := dot(vector, h.nodes[id].vector)
+			if results.Len() < ef || s > results.items[0].similarity {
+				heap.Push(candidates, candidate{id: id, similarity: s})
+				heap.Push(results, candidate{id: id, similarity: s})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	slices.SortFunc(results.items, compareCandidates)
+	return results.items
+}
+
+// Remove implements [Index.Remove].
+func (h *HNSW) Remove(key string) {
+	id, ok := h.ids[key]
+	if !ok {
+		return
+	}
+	delete(h.ids, key)
+	h.nodes[id].deleted = true
+	h.deleted++
+	if h.deleted > len(h.ids) {
+		h.rebuild()
+	}
+}
+
+// rebuild builds the graph from scratch with the nodes that are not deleted.
+func (h *HNSW) rebuild() {
+	nodes := h.nodes
+	h.nodes, h.ids, h.entry, h.maxLevel, h.deleted = nil, make(map[string]int), -1, 0, 0
+	for _, n := range nodes {
+		if !n.deleted {
+			h.insert(&hnswNode{key: n.key, vector: n.vector})
+		}
+	}
+}
+
+// Search implements [Index.Search].
+func (h *HNSW) Search(vector []float32) (key string, similarity float32) {
+	if len(h.ids) == 0 || len(vector) != h.dim {
+		return "", 0
+	}
+	vector = normalize(vector)
+	if vector == nil {
+		return "", 0
+	}
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(vector, ep, l)
+	}
+	// The deleted nodes are still traversed to keep the graph connected, but never returned.
+	for _, c := range h.searchLayer(vector, ep, hnswEFSearch, 0) {
+		if n := h.nodes[c.id]; !n.deleted {
+			return n.key, c.similarity
+		}
+	}
+	return "", 0
+}
+
+// Len implements [Index.Len].
+func (h *HNSW) Len() int { return len(h.ids) }
+
+type candidate struct {
+	id         int
+	similarity float32
+}
+
+// compareCandidates orders the candidates from the most similar one.
+func compareCandidates(a, b candidate) int {
+	switch {
+	case a.similarity > b.similarity:
+		return -1
+	case a.similarity < b.similarity:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// candidateHeap implements [heap.Interface] of the candidates.
+type candidateHeap struct {
+	items []candidate
+	// closestFirst is true if the most similar candidate is popped first, otherwise the least similar one.
+	closestFirst bool
+}
+
+func (c *candidateHeap) Len() int { return len(c.items) }
+
+func (c *candidateHeap) Less(i, j int) bool {
+	if c.closestFirst {
+		return c.items[i].similarity > c.items[j].similarity
+	}
+	return c.items[i].similarity < c.items[j].similarity
+}
+
+func (c *candidateHeap) Swap(i, j int) { c.items[i], c.items[j] = c.items[j], c.items[i] }
+
+func (c *candidateHeap) Push(x any) { c.items = append(c.items, x.(candidate)) }
+
+func (c *candidateHeap) Pop() any {
+	last := c.items[len(c.items)-1]
+	c.items = c.items[:len(c.items)-1]
+	return last
+}
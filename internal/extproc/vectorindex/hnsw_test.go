@@ -0,0 +1,108 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package vectorindex
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHNSW(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		h := NewHNSW()
+		key, _ := h.Search([]float32{1, 0})
+		require.Empty(t, key)
+		h.Add("zero", []float32{0, 0})
+		require.Zero(t, h.Len())
+	})
+
+	t.Run("add remove", func(t *testing.T) {
+		h := NewHNSW()
+		h.Add("x", []float32{1, 0})
+		h.Add("y", []float32{0, 2})
+		// The vectors with a different dimension are ignored.
+		h.Add("z", []float32{1, 0, 0})
+		require.Equal(t, 2, h.Len())
+
+		key, similarity := h.Search([]float32{3, 1})
+		require.Equal(t, "x", key)
+		require.InDelta(t, 0.9487, similarity, 1e-4)
+		key, _ = h.Search([]float32{1, 0, 0})
+		require.Empty(t, key)
+
+		// Replacing the vector of a key.
+		h.Add("x", []float32{0, -1})
+		require.Equal(t, 2, h.Len())
+		key, _ = h.Search([]float32{3, 1})
+		require.Equal(t, "y", key)
+
+		h.Remove("y")
+		h.Remove("unknown")
+		require.Equal(t, 1, h.Len())
+		key, similarity = h.Search([]float32{3, 1})
+		require.Equal(t, "x", key)
+		require.InDelta(t, -0.3162, similarity, 1e-4)
+
+		h.Remove("x")
+		require.Zero(t, h.Len())
+		key, _ = h.Search([]float32{3, 1})
+		require.Empty(t, key)
+		// The dimension can change once the index is empty.
+		h.Add("z", []float32{1, 0, 0})
+		require.Equal(t, 1, h.Len())
+	})
+
+	t.Run("recall", func(t *testing.T) {
+		rng := rand.New(rand.NewPCG(1, 2)) //nolint:gosec
+		random := func() []float32 {
+			v := make([]float32, 32)
+			for i := range v {
+				v[i] = rng.Float32()*2 - 1
+			}
+			return v
+		}
+		h := NewHNSW()
+		vectors := map[string][]float32{}
+		for i := range 2000 {
+			key := strconv.Itoa(i)
+			vectors[key] = random()
+			h.Add(key, vectors[key])
+		}
+		// Removing the half of the vectors triggers the rebuild of the graph.
+		for i := range 1001 {
+			// Removes the even keys and then "1".
+			key := strconv.Itoa(i*2%2000 + i/1000)
+			h.Remove(key)
+			delete(vectors, key)
+		}
+		require.Equal(t, len(vectors), h.Len())
+		require.Zero(t, h.deleted)
+		require.Len(t, h.nodes, 999)
+
+		const queries = 200
+		var found int
+		for range queries {
+			q := random()
+			var exactKey string
+			var exact float32 = -2
+			for key, v := range vectors {
+				if s := dot(normalize(q), normalize(v)); s > exact {
+					exactKey, exact = key, s
+				}
+			}
+			key, similarity := h.Search(q)
+			require.Contains(t, vectors, key)
+			if key == exactKey {
+				require.InDelta(t, exact, similarity, 1e-5)
+				found++
+			}
+		}
+		require.GreaterOrEqual(t, found, queries*95/100)
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+// Package vectorindex implements the indexes of the embedding vectors, which the semantic cache uses to find
+// the cached request most similar to a new one.
+package vectorindex
+
+import "math"
+
+// Index is an index of the embedding vectors keyed by strings, which finds the most similar vector by the cosine
+// similarity.
+//
+// The implementations are not required to be safe for concurrent use.
+type Index interface {
+	// Add adds the vector with the key, replacing the existing vector of the key if any.
+	// The vectors with a different dimension from the ones already in the index are ignored.
+	Add(key string, vector []float32)
+	// Remove removes the vector of the key if any.
+	Remove(key string)
+	// Search returns the key of the vector most similar to the given vector and their cosine similarity.
+	// The key is empty if there's no vector to compare with.
+	Search(vector []float32) (key string, similarity float32)
+	// Len returns the number of the vectors in the index.
+	Len() int
+}
+
+// normalize returns the copy of the vector scaled to the unit length so that the cosine similarity is the dot
+// product, or nil if the vector is zero.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return nil
+	}
+	norm := math.Sqrt(sum)
+	ret := make([]float32, len(v))
+	for i, x := range v {
+		ret[i] = float32(float64(x) / norm)
+	}
+	return ret
+}
+
+func dot(a, b []float32) (ret float32) {
+	for i := range a {
+		ret += a[i] * b[i]
+	}
+	return
+}
@@ -21,6 +21,24 @@ const (
 	celInputTokensKey  = "input_tokens"
 	celOutputTokensKey = "output_tokens"
 	celTotalTokensKey  = "total_tokens"
+	// celEstimatedInputTokensKey is the number of input tokens estimated before the request is sent to the backend.
+	celEstimatedInputTokensKey = "estimated_input_tokens"
+	// celCachedInputTokensKey is the number of input tokens read from the prompt cache of the provider.
+	celCachedInputTokensKey = "cached_input_tokens"
+	// celReasoningTokensKey is the number of output tokens used for reasoning.
+	celReasoningTokensKey = "reasoning_tokens"
+	// celAudioInputTokensKey and celAudioOutputTokensKey are the numbers of audio tokens in the input and output.
+	celAudioInputTokensKey  = "audio_input_tokens"
+	celAudioOutputTokensKey = "audio_output_tokens"
+	// celRequestHeadersKey is the map of the request headers keyed by the lower-cased header name.
+	celRequestHeadersKey = "request_headers"
+	celStreamKey         = "stream"
+	// celLatencyMsKey is the latency of the request to the backend in milliseconds.
+	celLatencyMsKey = "latency_ms"
+	// celTimeToFirstTokenMsKey is the time to the first token in milliseconds of a streaming request.
+	celTimeToFirstTokenMsKey = "time_to_first_token_ms"
+	// celBackendSchemaKey is the name of the API schema of the backend, e.g. "OpenAI" or "AWSBedrock".
+	celBackendSchemaKey = "backend_schema"
 )
 
 var env *cel.Env
@@ -28,45 +46,125 @@ var env *cel.Env
 func init() {
 	var err error
 	env, err = cel.NewEnv(
+		cel.OptionalTypes(),
 		cel.Variable(celModelNameKey, cel.StringType),
 		cel.Variable(celBackendKey, cel.StringType),
 		cel.Variable(celInputTokensKey, cel.UintType),
 		cel.Variable(celOutputTokensKey, cel.UintType),
 		cel.Variable(celTotalTokensKey, cel.UintType),
+		cel.Variable(celEstimatedInputTokensKey, cel.UintType),
+		cel.Variable(celCachedInputTokensKey, cel.UintType),
+		cel.Variable(celReasoningTokensKey, cel.UintType),
+		cel.Variable(celAudioInputTokensKey, cel.UintType),
+		cel.Variable(celAudioOutputTokensKey, cel.UintType),
+		cel.Variable(celRequestHeadersKey, cel.MapType(cel.StringType, cel.StringType)),
+		cel.Variable(celStreamKey, cel.BoolType),
+		cel.Variable(celLatencyMsKey, cel.UintType),
+		cel.Variable(celTimeToFirstTokenMsKey, cel.UintType),
+		cel.Variable(celBackendSchemaKey, cel.StringType),
 	)
 	if err != nil {
 		panic(fmt.Sprintf("cannot create CEL environment: %v", err))
 	}
 }
 
+// Input is the set of variables available to the CEL expression.
+type Input struct {
+	// Model is the name of the model in the request.
+	Model string
+	// Backend is the name of the backend that served the request, which is empty before the request is sent to
+	// the backend.
+	Backend string
+	// BackendSchema is the name of the API schema of the backend.
+	BackendSchema string
+	// InputTokens, OutputTokens and TotalTokens are the token usage reported by the backend.
+	InputTokens, OutputTokens, TotalTokens uint32
+	// EstimatedInputTokens is the number of input tokens estimated before the request is sent to the backend.
+	EstimatedInputTokens uint32
+	// CachedInputTokens is the number of input tokens read from the prompt cache of the provider.
+	CachedInputTokens uint32
+	// ReasoningTokens is the number of output tokens used for reasoning.
+	ReasoningTokens uint32
+	// AudioInputTokens and AudioOutputTokens are the numbers of audio tokens in the input and output.
+	AudioInputTokens, AudioOutputTokens uint32
+	// RequestHeaders are the request headers keyed by the lower-cased header name.
+	RequestHeaders map[string]string
+	// Stream is true if the request is a streaming request.
+	Stream bool
+	// LatencyMs is the latency of the request to the backend in milliseconds.
+	LatencyMs uint64
+	// TimeToFirstTokenMs is the time to the first token in milliseconds, which is zero for non-streaming requests.
+	TimeToFirstTokenMs uint64
+}
+
 // NewProgram creates a new CEL program from the given expression.
+//
+// The expression is type-checked against the environment and must evaluate to an integer. Missing request headers
+// fail the evaluation, so they must be accessed with the optional syntax, e.g. request_headers[?"x-tier"].orValue("").
 func NewProgram(expr string) (prog cel.Program, err error) {
 	ast, issues := env.Compile(expr)
 	if issues != nil && issues.Err() != nil {
 		err = issues.Err()
 		return nil, fmt.Errorf("cannot compile CEL expression: %w", err)
 	}
+	switch ast.OutputType() {
+	case cel.IntType, cel.UintType, cel.DynType:
+	default:
+		return nil, fmt.Errorf("CEL expression must evaluate to an integer, got %v", ast.OutputType())
+	}
 	prog, err = env.Program(ast)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create CEL program: %w", err)
 	}
 
 	// Sanity check by evaluating the expression with some dummy values.
-	_, err = EvaluateProgram(prog, "dummy", "dummy", 0, 0, 0)
+	_, err = EvaluateProgram(prog, &Input{Model: "dummy", Backend: "dummy", BackendSchema: "dummy", RequestHeaders: map[string]string{}})
 	if err != nil {
 		return nil, fmt.Errorf("failed to evaluate CEL expression: %w", err)
 	}
 	return prog, nil
 }
 
+// UsesEstimatedInputTokens returns true if the expression references estimated_input_tokens. Only such expressions
+// are evaluated before the request is sent to the backend, since the others cannot be known until the response.
+func UsesEstimatedInputTokens(expr string) (bool, error) {
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return false, fmt.Errorf("cannot compile CEL expression: %w", issues.Err())
+	}
+	for _, ref := range ast.NativeRep().ReferenceMap() {
+		if ref.Name == celEstimatedInputTokensKey {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // EvaluateProgram evaluates the given CEL program with the given variables.
-func EvaluateProgram(prog cel.Program, modelName, backend string, inputTokens, outputTokens, totalTokens uint32) (uint64, error) {
+//
+// Before the request is sent to the backend, the expression using the estimated input tokens is evaluated with
+// zero token usage and no backend, so that the cost can be used by the request-time rate limit.
+func EvaluateProgram(prog cel.Program, in *Input) (uint64, error) {
+	headers := in.RequestHeaders
+	if headers == nil {
+		headers = map[string]string{}
+	}
 	out, _, err := prog.Eval(map[string]interface{}{
-		celModelNameKey:    modelName,
-		celBackendKey:      backend,
-		celInputTokensKey:  inputTokens,
-		celOutputTokensKey: outputTokens,
-		celTotalTokensKey:  totalTokens,
+		celModelNameKey:            in.Model,
+		celBackendKey:              in.Backend,
+		celInputTokensKey:          in.InputTokens,
+		celOutputTokensKey:         in.OutputTokens,
+		celTotalTokensKey:          in.TotalTokens,
+		celEstimatedInputTokensKey: in.EstimatedInputTokens,
+		celCachedInputTokensKey:    in.CachedInputTokens,
+		celReasoningTokensKey:      in.ReasoningTokens,
+		celAudioInputTokensKey:     in.AudioInputTokens,
+		celAudioOutputTokensKey:    in.AudioOutputTokens,
+		celRequestHeadersKey:       headers,
+		celStreamKey:               in.Stream,
+		celLatencyMsKey:            in.LatencyMs,
+		celTimeToFirstTokenMsKey:   in.TimeToFirstTokenMs,
+		celBackendSchemaKey:        in.BackendSchema,
 	})
 	if err != nil || out == nil {
 		return 0, fmt.Errorf("failed to evaluate CEL expression: %w", err)
@@ -28,15 +28,63 @@ func TestNewProgram(t *testing.T) {
 	t.Run("variables", func(t *testing.T) {
 		prog, err := NewProgram("model == 'cool_model' ?  input_tokens * output_tokens : total_tokens")
 		require.NoError(t, err)
-		v, err := EvaluateProgram(prog, "cool_model", "cool_backend", 100, 2, 3)
+		v, err := EvaluateProgram(prog, &Input{Model: "cool_model", Backend: "cool_backend", InputTokens: 100, OutputTokens: 2, TotalTokens: 3})
 		require.NoError(t, err)
 		require.Equal(t, uint64(200), v)
 
-		v, err = EvaluateProgram(prog, "not_cool_model", "cool_backend", 100, 2, 3)
+		v, err = EvaluateProgram(prog, &Input{Model: "not_cool_model", Backend: "cool_backend", InputTokens: 100, OutputTokens: 2, TotalTokens: 3})
 		require.NoError(t, err)
 		require.Equal(t, uint64(3), v)
 	})
 
+	t.Run("estimated input tokens", func(t *testing.T) {
+		prog, err := NewProgram("input_tokens == uint(0) ? estimated_input_tokens : input_tokens + output_tokens")
+		require.NoError(t, err)
+		v, err := EvaluateProgram(prog, &Input{Model: "cool_model", Backend: "cool_backend", EstimatedInputTokens: 150})
+		require.NoError(t, err)
+		require.Equal(t, uint64(150), v)
+
+		v, err = EvaluateProgram(prog, &Input{Model: "cool_model", Backend: "cool_backend", InputTokens: 100, OutputTokens: 2, TotalTokens: 102, EstimatedInputTokens: 150})
+		require.NoError(t, err)
+		require.Equal(t, uint64(102), v)
+	})
+
+	t.Run("richer variables", func(t *testing.T) {
+		prog, err := NewProgram(`(request_headers[?'x-tier'].orValue('free') == 'gold' ? uint(0) : input_tokens - cached_input_tokens) +
+			(stream ? uint(2) : uint(1)) * (output_tokens + reasoning_tokens) +
+			(latency_ms > uint(1000) || time_to_first_token_ms > uint(500) ? uint(0) : uint(10)) +
+			(backend_schema == 'AWSBedrock' ? uint(100) : uint(0))`)
+		require.NoError(t, err)
+		v, err := EvaluateProgram(prog, &Input{
+			InputTokens: 100, CachedInputTokens: 40, OutputTokens: 10, ReasoningTokens: 5,
+			RequestHeaders: map[string]string{"x-tier": "silver"}, Stream: true, LatencyMs: 200, TimeToFirstTokenMs: 50,
+			BackendSchema: "AWSBedrock",
+		})
+		require.NoError(t, err)
+		require.Equal(t, uint64(60+30+10+100), v)
+
+		v, err = EvaluateProgram(prog, &Input{
+			InputTokens: 100, OutputTokens: 10,
+			RequestHeaders: map[string]string{"x-tier": "gold"}, LatencyMs: 2000, BackendSchema: "OpenAI",
+		})
+		require.NoError(t, err)
+		require.Equal(t, uint64(10), v)
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		_, err := NewProgram("request_headers['x-tier'] == 'gold' ? 1 : 2")
+		require.ErrorContains(t, err, "no such key: x-tier")
+	})
+
+	t.Run("type check", func(t *testing.T) {
+		_, err := NewProgram("stream + 1")
+		require.ErrorContains(t, err, "cannot compile CEL expression")
+		_, err = NewProgram("unknown_variable")
+		require.ErrorContains(t, err, "undeclared reference to 'unknown_variable'")
+		_, err = NewProgram("backend_schema")
+		require.ErrorContains(t, err, "CEL expression must evaluate to an integer, got string")
+	})
+
 	t.Run("uint", func(t *testing.T) {
 		_, err := NewProgram("uint(1)-uint(1200)")
 		require.ErrorContains(t, err, "failed to evaluate CEL expression: failed to evaluate CEL expression: unsigned integer overflow")
@@ -61,13 +109,13 @@ func TestEvaluateProgram(t *testing.T) {
 	t.Run("signed integer negative", func(t *testing.T) {
 		prog, err := NewProgram("int(input_tokens) - int(output_tokens)")
 		require.NoError(t, err)
-		_, err = EvaluateProgram(prog, "cool_model", "cool_backend", 100, 2000, 3)
+		_, err = EvaluateProgram(prog, &Input{Model: "cool_model", Backend: "cool_backend", InputTokens: 100, OutputTokens: 2000, TotalTokens: 3})
 		require.ErrorContains(t, err, "CEL expression result is negative (-1900)")
 	})
 	t.Run("unsigned integer overflow", func(t *testing.T) {
 		prog, err := NewProgram("input_tokens - output_tokens")
 		require.NoError(t, err)
-		_, err = EvaluateProgram(prog, "cool_model", "cool_backend", 100, 2000, 3)
+		_, err = EvaluateProgram(prog, &Input{Model: "cool_model", Backend: "cool_backend", InputTokens: 100, OutputTokens: 2000, TotalTokens: 3})
 		require.ErrorContains(t, err, "failed to evaluate CEL expression: unsigned integer overflow")
 	})
 	t.Run("ensure concurrency safety", func(t *testing.T) {
@@ -80,7 +128,7 @@ func TestEvaluateProgram(t *testing.T) {
 		for i := 0; i < 100; i++ {
 			go func() {
 				defer wg.Done()
-				v, err := EvaluateProgram(prog, "cool_model", "cool_backend", 100, 2, 3)
+				v, err := EvaluateProgram(prog, &Input{Model: "cool_model", Backend: "cool_backend", InputTokens: 100, OutputTokens: 2, TotalTokens: 3})
 				require.NoError(t, err)
 				require.Equal(t, uint64(200), v)
 			}()
@@ -88,3 +136,23 @@ func TestEvaluateProgram(t *testing.T) {
 		wg.Wait()
 	})
 }
+
+func TestUsesEstimatedInputTokens(t *testing.T) {
+	for _, tc := range []struct {
+		expr string
+		exp  bool
+	}{
+		{expr: "total_tokens", exp: false},
+		{expr: "input_tokens == uint(0) ? estimated_input_tokens : total_tokens", exp: true},
+		// The variable in a string literal or a header name is not a reference.
+		{expr: "request_headers[?'estimated_input_tokens'].orValue('') == 'estimated_input_tokens' ? uint(1) : uint(0)", exp: false},
+	} {
+		t.Run(tc.expr, func(t *testing.T) {
+			uses, err := UsesEstimatedInputTokens(tc.expr)
+			require.NoError(t, err)
+			require.Equal(t, tc.exp, uses)
+		})
+	}
+	_, err := UsesEstimatedInputTokens("invalid +")
+	require.ErrorContains(t, err, "cannot compile CEL expression")
+}
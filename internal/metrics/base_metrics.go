@@ -15,6 +15,15 @@ import (
 	"github.com/envoyproxy/ai-gateway/filterapi"
 )
 
+// aigwAttributeConsumer is the attribute of the consumer authenticated with the consumer key.
+const aigwAttributeConsumer = "aigw.consumer"
+
+// ConsumerAttribute returns the attribute of the given consumer, which is passed as the extra attribute of the
+// recorded metrics when the request is authenticated with a consumer key.
+func ConsumerAttribute(consumer string) attribute.KeyValue {
+	return attribute.Key(aigwAttributeConsumer).String(consumer)
+}
+
 // baseMetrics provides shared functionality for AI Gateway metrics implementations.
 type baseMetrics struct {
 	metrics      *genAI
@@ -85,3 +94,8 @@ func (b *baseMetrics) RecordRequestCompletion(ctx context.Context, success bool,
 		)
 	}
 }
+
+// RecordRequestCost records the monetary cost of the request in US dollars.
+func (b *baseMetrics) RecordRequestCost(ctx context.Context, usd float64, extraAttrs ...attribute.KeyValue) {
+	b.metrics.requestCost.Record(ctx, usd, metric.WithAttributes(b.buildBaseAttributes(extraAttrs...)...))
+}
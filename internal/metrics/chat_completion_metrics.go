@@ -63,6 +63,28 @@ func (c *chatCompletion) RecordTokenUsage(ctx context.Context, inputTokens, outp
 	)
 }
 
+// RecordTokenUsageDetails implements [x.TokenUsageDetailsMetrics.RecordTokenUsageDetails].
+func (c *chatCompletion) RecordTokenUsageDetails(ctx context.Context, cachedInputTokens, reasoningTokens, audioInputTokens, audioOutputTokens uint32, extraAttrs ...attribute.KeyValue) {
+	attrs := c.buildBaseAttributes(extraAttrs...)
+	for _, d := range []struct {
+		tokenType string
+		tokens    uint32
+	}{
+		{genaiTokenTypeCachedInput, cachedInputTokens},
+		{genaiTokenTypeReasoning, reasoningTokens},
+		{genaiTokenTypeAudioInput, audioInputTokens},
+		{genaiTokenTypeAudioOutput, audioOutputTokens},
+	} {
+		if d.tokens == 0 {
+			continue
+		}
+		c.metrics.tokenUsage.Record(ctx, float64(d.tokens),
+			metric.WithAttributes(attrs...),
+			metric.WithAttributes(attribute.Key(genaiAttributeTokenType).String(d.tokenType)),
+		)
+	}
+}
+
 // RecordTokenLatency implements [ChatCompletion.RecordTokenLatency].
 func (c *chatCompletion) RecordTokenLatency(ctx context.Context, tokens uint32, extraAttrs ...attribute.KeyValue) {
 	attrs := c.buildBaseAttributes(extraAttrs...)
@@ -16,6 +16,7 @@ import (
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 
 	"github.com/envoyproxy/ai-gateway/filterapi"
+	"github.com/envoyproxy/ai-gateway/filterapi/x"
 )
 
 func TestNewProcessorMetrics(t *testing.T) {
@@ -45,6 +46,66 @@ func TestStartRequest(t *testing.T) {
 	assert.LessOrEqual(t, pm.requestStart, after)
 }
 
+func TestRecordRequestCost(t *testing.T) {
+	var (
+		mr    = metric.NewManualReader()
+		meter = metric.NewMeterProvider(metric.WithReader(mr)).Meter("test")
+		pm    = DefaultChatCompletion(meter).(*chatCompletion)
+
+		attrs = attribute.NewSet(
+			attribute.Key(genaiAttributeOperationName).String(genaiOperationChat),
+			attribute.Key(genaiAttributeSystemName).String(genaiSystemOpenAI),
+			attribute.Key(genaiAttributeRequestModel).String("test-model"),
+		)
+	)
+
+	require.Implements(t, (*x.RequestCostMetrics)(nil), pm)
+	require.Implements(t, (*x.RequestCostMetrics)(nil), NewEmbeddings(meter))
+
+	pm.SetModel("test-model")
+	pm.SetBackend(&filterapi.Backend{Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI}})
+	pm.RecordRequestCost(t.Context(), 0.0045)
+
+	count, sum := getHistogramValues(t, mr, genaiMetricClientRequestCost, attrs)
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 0.0045, sum)
+}
+
+func TestRecordTokenUsageDetails(t *testing.T) {
+	var (
+		mr    = metric.NewManualReader()
+		meter = metric.NewMeterProvider(metric.WithReader(mr)).Meter("test")
+		pm    = DefaultChatCompletion(meter).(*chatCompletion)
+
+		attrs = []attribute.KeyValue{
+			attribute.Key(genaiAttributeOperationName).String(genaiOperationChat),
+			attribute.Key(genaiAttributeSystemName).String(genaiSystemOpenAI),
+			attribute.Key(genaiAttributeRequestModel).String("test-model"),
+		}
+		cachedAttrs    = attribute.NewSet(append(attrs, attribute.Key(genaiAttributeTokenType).String(genaiTokenTypeCachedInput))...)
+		reasoningAttrs = attribute.NewSet(append(attrs, attribute.Key(genaiAttributeTokenType).String(genaiTokenTypeReasoning))...)
+	)
+
+	require.Implements(t, (*x.TokenUsageDetailsMetrics)(nil), pm)
+
+	pm.SetModel("test-model")
+	pm.SetBackend(&filterapi.Backend{Schema: filterapi.VersionedAPISchema{Name: filterapi.APISchemaOpenAI}})
+	pm.RecordTokenUsageDetails(t.Context(), 8, 4, 0, 0)
+
+	count, sum := getHistogramValues(t, mr, genaiMetricClientTokenUsage, cachedAttrs)
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 8.0, sum)
+
+	count, sum = getHistogramValues(t, mr, genaiMetricClientTokenUsage, reasoningAttrs)
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 4.0, sum)
+
+	// Zero counts are not recorded.
+	var data metricdata.ResourceMetrics
+	require.NoError(t, mr.Collect(t.Context(), &data))
+	require.Len(t, data.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64]).DataPoints, 2)
+}
+
 func TestRecordTokenUsage(t *testing.T) {
 	var (
 		mr    = metric.NewManualReader()
@@ -0,0 +1,70 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package metrics
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	circuitBreakerMetricState            = "aigw.circuit_breaker.state"
+	circuitBreakerMetricTransitions      = "aigw.circuit_breaker.transitions"
+	circuitBreakerMetricRejectedRequests = "aigw.circuit_breaker.rejected_requests"
+
+	circuitBreakerAttributeBackend = "aigw.backend.name"
+	circuitBreakerAttributeState   = "aigw.circuit_breaker.state"
+)
+
+// CircuitBreaker holds the metrics of the per-backend circuit breakers.
+type CircuitBreaker struct {
+	state       metric.Int64Gauge
+	transitions metric.Int64Counter
+	rejected    metric.Int64Counter
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker metrics instance.
+func NewCircuitBreaker(meter metric.Meter) *CircuitBreaker {
+	state, err := meter.Int64Gauge(circuitBreakerMetricState,
+		metric.WithDescription("State of the circuit breaker of the backend: 0 for closed, 1 for open and 2 for half-open."),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	transitions, err := meter.Int64Counter(circuitBreakerMetricTransitions,
+		metric.WithDescription("Number of the state transitions of the circuit breaker of the backend."),
+		metric.WithUnit("{transition}"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	rejected, err := meter.Int64Counter(circuitBreakerMetricRejectedRequests,
+		metric.WithDescription("Number of the requests not sent to the backend because its circuit breaker is open."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return &CircuitBreaker{state: state, transitions: transitions, rejected: rejected}
+}
+
+// RecordTransition records the transition of the circuit breaker of the backend to the given state,
+// where value is the numeric representation of the state as described in the state metric.
+func (c *CircuitBreaker) RecordTransition(ctx context.Context, backend, state string, value int64) {
+	c.state.Record(ctx, value, metric.WithAttributes(attribute.Key(circuitBreakerAttributeBackend).String(backend)))
+	c.transitions.Add(ctx, 1, metric.WithAttributes(
+		attribute.Key(circuitBreakerAttributeBackend).String(backend),
+		attribute.Key(circuitBreakerAttributeState).String(state),
+	))
+}
+
+// RecordRejected records a request not sent to the backend because its circuit breaker is open.
+func (c *CircuitBreaker) RecordRejected(ctx context.Context, backend string) {
+	c.rejected.Add(ctx, 1, metric.WithAttributes(attribute.Key(circuitBreakerAttributeBackend).String(backend)))
+}
@@ -0,0 +1,51 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var (
+		mr    = metric.NewManualReader()
+		meter = metric.NewMeterProvider(metric.WithReader(mr)).Meter("test")
+		cb    = NewCircuitBreaker(meter)
+	)
+	cb.RecordTransition(t.Context(), "backend.ns", "open", 1)
+	cb.RecordTransition(t.Context(), "backend.ns", "half-open", 2)
+	cb.RecordRejected(t.Context(), "backend.ns")
+	cb.RecordRejected(t.Context(), "backend.ns")
+
+	var data metricdata.ResourceMetrics
+	require.NoError(t, mr.Collect(t.Context(), &data))
+	got := map[string]metricdata.Aggregation{}
+	for _, m := range data.ScopeMetrics[0].Metrics {
+		got[m.Name] = m.Data
+	}
+
+	backend := attribute.NewSet(attribute.Key(circuitBreakerAttributeBackend).String("backend.ns"))
+	state := got[circuitBreakerMetricState].(metricdata.Gauge[int64]).DataPoints
+	require.Len(t, state, 1)
+	require.Equal(t, backend, state[0].Attributes)
+	require.Equal(t, int64(2), state[0].Value)
+
+	transitions := got[circuitBreakerMetricTransitions].(metricdata.Sum[int64]).DataPoints
+	require.Len(t, transitions, 2)
+	for _, dp := range transitions {
+		require.Equal(t, int64(1), dp.Value)
+	}
+
+	rejected := got[circuitBreakerMetricRejectedRequests].(metricdata.Sum[int64]).DataPoints
+	require.Len(t, rejected, 1)
+	require.Equal(t, backend, rejected[0].Attributes)
+	require.Equal(t, int64(2), rejected[0].Value)
+}
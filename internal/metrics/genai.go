@@ -15,6 +15,8 @@ const (
 	genaiMetricServerRequestDuration    = "gen_ai.server.request.duration"
 	genaiMetricServerTimeToFirstToken   = "gen_ai.server.time_to_first_token"   // #nosec G101: Potential hardcoded credentials
 	genaiMetricServerTimePerOutputToken = "gen_ai.server.time_per_output_token" // #nosec G101: Potential hardcoded credentials
+	// genaiMetricClientRequestCost is not part of the Semantic Conventions, but follows its naming.
+	genaiMetricClientRequestCost = "gen_ai.client.request.cost"
 
 	genaiAttributeOperationName = "gen_ai.operation.name"
 	genaiAttributeSystemName    = "gen_ai.system.name"
@@ -29,7 +31,13 @@ const (
 	genaiTokenTypeInput     = "input"
 	genaiTokenTypeOutput    = "output"
 	genaiTokenTypeTotal     = "total"
-	genaiErrorTypeFallback  = "_OTHER"
+	// The following token types are not part of the Semantic Conventions, and are the breakdown of the input
+	// and output tokens.
+	genaiTokenTypeCachedInput = "cached_input"
+	genaiTokenTypeReasoning   = "reasoning"
+	genaiTokenTypeAudioInput  = "audio_input"
+	genaiTokenTypeAudioOutput = "audio_output"
+	genaiErrorTypeFallback    = "_OTHER"
 )
 
 // genAI holds metrics according to the Semantic Conventions for Generative AI Metrics.
@@ -49,6 +57,8 @@ type genAI struct {
 	// outputTokenLatency is the latency between consecutive tokens, if supported, or by chunks/tokens otherwise, by backend, model.
 	// See: https://opentelemetry.io/docs/specs/semconv/gen-ai/gen-ai-metrics/#metric-gen_aiservertime_per_output_token
 	outputTokenLatency metric.Float64Histogram
+	// requestCost is the monetary cost of the request in US dollars computed from the pricing of the backend.
+	requestCost metric.Float64Histogram
 }
 
 // newGenAI creates a new genAI metrics instance.
@@ -78,6 +88,12 @@ func newGenAI(meter metric.Meter) *genAI {
 			metric.WithUnit("s"),
 			metric.WithExplicitBucketBoundaries(0.01, 0.025, 0.05, 0.075, 0.1, 0.15, 0.2, 0.3, 0.4, 0.5, 0.75, 1.0, 2.5),
 		),
+		requestCost: mustRegisterHistogram(meter,
+			genaiMetricClientRequestCost,
+			metric.WithDescription("Monetary cost of the request computed from the token usage."),
+			metric.WithUnit("{USD}"),
+			metric.WithExplicitBucketBoundaries(0.00001, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10),
+		),
 	}
 }
 
@@ -0,0 +1,66 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	guardrailMetricViolations       = "aigw.guardrail.violations"
+	guardrailMetricHoldbackDuration = "aigw.guardrail.holdback.duration"
+
+	guardrailAttributeRouteRule = "aigw.route_rule.name"
+	guardrailAttributeGuardrail = "aigw.guardrail.name"
+	guardrailAttributePhase     = "aigw.guardrail.phase"
+)
+
+// Guardrail holds the metrics of the guardrails and the moderation of the streamed completions.
+type Guardrail struct {
+	violations       metric.Int64Counter
+	holdbackDuration metric.Float64Histogram
+}
+
+// NewGuardrail creates a new Guardrail metrics instance.
+func NewGuardrail(meter metric.Meter) *Guardrail {
+	violations, err := meter.Int64Counter(guardrailMetricViolations,
+		metric.WithDescription("Number of the triggered guardrails by the guardrail and the phase, either input or output."),
+		metric.WithUnit("{violation}"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	holdbackDuration, err := meter.Float64Histogram(guardrailMetricHoldbackDuration,
+		metric.WithDescription("Time the chunks of the streamed completions are held back until their window is screened."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.01, 0.02, 0.04, 0.08, 0.16, 0.32, 0.64, 1.28, 2.56, 5.12, 10.24),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return &Guardrail{violations: violations, holdbackDuration: holdbackDuration}
+}
+
+// RecordViolation records a triggered guardrail of the route rule. The guardrail is "deny_list" for the deny
+// patterns of the streamed completions.
+func (g *Guardrail) RecordViolation(ctx context.Context, routeRule, guardrail, phase string) {
+	g.violations.Add(ctx, 1, metric.WithAttributes(
+		attribute.Key(guardrailAttributeRouteRule).String(routeRule),
+		attribute.Key(guardrailAttributeGuardrail).String(guardrail),
+		attribute.Key(guardrailAttributePhase).String(phase),
+	))
+}
+
+// RecordHoldback records the time the chunks of a streamed completion of the route rule were held back.
+func (g *Guardrail) RecordHoldback(ctx context.Context, routeRule string, d time.Duration) {
+	g.holdbackDuration.Record(ctx, d.Seconds(), metric.WithAttributes(
+		attribute.Key(guardrailAttributeRouteRule).String(routeRule),
+	))
+}
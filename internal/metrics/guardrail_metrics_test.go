@@ -0,0 +1,51 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestGuardrail(t *testing.T) {
+	var (
+		mr    = metric.NewManualReader()
+		meter = metric.NewMeterProvider(metric.WithReader(mr)).Meter("test")
+		g     = NewGuardrail(meter)
+	)
+	g.RecordViolation(t.Context(), "ns/route/rule/0", "jailbreak", "input")
+	g.RecordViolation(t.Context(), "ns/route/rule/0", "deny_list", "output")
+	g.RecordViolation(t.Context(), "ns/route/rule/0", "deny_list", "output")
+	g.RecordHoldback(t.Context(), "ns/route/rule/0", 50*time.Millisecond)
+	g.RecordHoldback(t.Context(), "ns/route/rule/0", 150*time.Millisecond)
+
+	var data metricdata.ResourceMetrics
+	require.NoError(t, mr.Collect(t.Context(), &data))
+	got := map[string]metricdata.Aggregation{}
+	for _, m := range data.ScopeMetrics[0].Metrics {
+		got[m.Name] = m.Data
+	}
+
+	violations := map[attribute.Set]int64{}
+	for _, dp := range got[guardrailMetricViolations].(metricdata.Sum[int64]).DataPoints {
+		violations[dp.Attributes] = dp.Value
+	}
+	route := attribute.Key(guardrailAttributeRouteRule).String("ns/route/rule/0")
+	require.Equal(t, map[attribute.Set]int64{
+		attribute.NewSet(route, attribute.Key(guardrailAttributeGuardrail).String("jailbreak"), attribute.Key(guardrailAttributePhase).String("input")):  1,
+		attribute.NewSet(route, attribute.Key(guardrailAttributeGuardrail).String("deny_list"), attribute.Key(guardrailAttributePhase).String("output")): 2,
+	}, violations)
+
+	holdback := got[guardrailMetricHoldbackDuration].(metricdata.Histogram[float64]).DataPoints
+	require.Len(t, holdback, 1)
+	require.Equal(t, uint64(2), holdback[0].Count)
+	require.InDelta(t, 0.2, holdback[0].Sum, 1e-9)
+}
@@ -0,0 +1,71 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package metrics
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	responseCacheMetricLookups = "aigw.response_cache.lookups"
+	responseCacheMetricSize    = "aigw.response_cache.size"
+
+	responseCacheAttributeRouteRule = "aigw.route_rule.name"
+	responseCacheAttributeResult    = "aigw.response_cache.result"
+)
+
+// ResponseCacheResult is the result of a lookup of the response cache.
+type ResponseCacheResult string
+
+const (
+	// ResponseCacheHit is the result of a lookup that found the response of an identical request.
+	ResponseCacheHit ResponseCacheResult = "hit"
+	// ResponseCacheSemanticHit is the result of a lookup that found the response of a similar request.
+	ResponseCacheSemanticHit ResponseCacheResult = "semantic_hit"
+	// ResponseCacheMiss is the result of a lookup that found no response.
+	ResponseCacheMiss ResponseCacheResult = "miss"
+)
+
+// ResponseCache holds the metrics of the response cache.
+type ResponseCache struct {
+	lookups metric.Int64Counter
+	size    metric.Int64Gauge
+}
+
+// NewResponseCache creates a new ResponseCache metrics instance.
+func NewResponseCache(meter metric.Meter) *ResponseCache {
+	lookups, err := meter.Int64Counter(responseCacheMetricLookups,
+		metric.WithDescription("Number of the lookups of the response cache by the result, either hit, semantic_hit or miss."),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	size, err := meter.Int64Gauge(responseCacheMetricSize,
+		metric.WithDescription("Total size of the cached responses."),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return &ResponseCache{lookups: lookups, size: size}
+}
+
+// RecordLookup records a lookup of the response cache for the route rule.
+func (r *ResponseCache) RecordLookup(ctx context.Context, routeRule string, result ResponseCacheResult) {
+	r.lookups.Add(ctx, 1, metric.WithAttributes(
+		attribute.Key(responseCacheAttributeRouteRule).String(routeRule),
+		attribute.Key(responseCacheAttributeResult).String(string(result)),
+	))
+}
+
+// RecordSize records the total size of the cached responses in bytes.
+func (r *ResponseCache) RecordSize(ctx context.Context, size int64) {
+	r.size.Record(ctx, size)
+}
@@ -0,0 +1,50 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestResponseCache(t *testing.T) {
+	var (
+		mr    = metric.NewManualReader()
+		meter = metric.NewMeterProvider(metric.WithReader(mr)).Meter("test")
+		rc    = NewResponseCache(meter)
+	)
+	rc.RecordLookup(t.Context(), "ns/route/rule/0", ResponseCacheHit)
+	rc.RecordLookup(t.Context(), "ns/route/rule/0", ResponseCacheSemanticHit)
+	rc.RecordLookup(t.Context(), "ns/route/rule/0", ResponseCacheMiss)
+	rc.RecordLookup(t.Context(), "ns/route/rule/0", ResponseCacheMiss)
+	rc.RecordSize(t.Context(), 1024)
+
+	var data metricdata.ResourceMetrics
+	require.NoError(t, mr.Collect(t.Context(), &data))
+	got := map[string]metricdata.Aggregation{}
+	for _, m := range data.ScopeMetrics[0].Metrics {
+		got[m.Name] = m.Data
+	}
+
+	lookups := map[attribute.Set]int64{}
+	for _, dp := range got[responseCacheMetricLookups].(metricdata.Sum[int64]).DataPoints {
+		lookups[dp.Attributes] = dp.Value
+	}
+	route := attribute.Key(responseCacheAttributeRouteRule).String("ns/route/rule/0")
+	require.Equal(t, map[attribute.Set]int64{
+		attribute.NewSet(route, attribute.Key(responseCacheAttributeResult).String("hit")):          1,
+		attribute.NewSet(route, attribute.Key(responseCacheAttributeResult).String("semantic_hit")): 1,
+		attribute.NewSet(route, attribute.Key(responseCacheAttributeResult).String("miss")):         2,
+	}, lookups)
+
+	size := got[responseCacheMetricSize].(metricdata.Gauge[int64]).DataPoints
+	require.Len(t, size, 1)
+	require.Equal(t, int64(1024), size[0].Value)
+}
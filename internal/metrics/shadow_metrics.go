@@ -0,0 +1,116 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	shadowMetricRequestDuration    = "aigw.shadow.request.duration"
+	shadowMetricTokenUsage         = "aigw.shadow.token.usage" // #nosec G101: Potential hardcoded credentials
+	shadowMetricResponseSimilarity = "aigw.shadow.response.similarity"
+
+	shadowAttributeRole              = "aigw.shadow.role"
+	shadowAttributeRouteRule         = "aigw.route_rule.name"
+	shadowAttributeBackend           = "aigw.backend.name"
+	shadowAttributeShadowBackend     = "aigw.shadow.backend.name"
+	shadowAttributeFinishReasonMatch = "aigw.shadow.finish_reason.match"
+
+	shadowRolePrimary = "primary"
+	shadowRoleShadow  = "shadow"
+)
+
+// ShadowResult is the result of either the primary or the shadow backend of a mirrored request.
+type ShadowResult struct {
+	// Backend is the name of the backend that served the request.
+	Backend string
+	// Latency is the time from the routing decision to the end of the response.
+	Latency time.Duration
+	// Success is true if the backend responded with a successful status.
+	Success bool
+	// InputTokens and OutputTokens are the token usage reported by the backend.
+	InputTokens, OutputTokens uint32
+	// FinishReason is the finish reason of the first choice of the response, if any.
+	FinishReason string
+}
+
+// Shadow holds the metrics of the shadow traffic mirroring, which pair the results of the primary
+// and the shadow backends of the same request so that they can be compared side by side.
+type Shadow struct {
+	requestLatency metric.Float64Histogram
+	tokenUsage     metric.Float64Histogram
+	similarity     metric.Float64Histogram
+}
+
+// NewShadow creates a new Shadow metrics instance.
+func NewShadow(meter metric.Meter) *Shadow {
+	return &Shadow{
+		requestLatency: mustRegisterHistogram(meter,
+			shadowMetricRequestDuration,
+			metric.WithDescription("Time spent by the primary and the shadow backends of mirrored requests."),
+			metric.WithUnit("s"),
+			metric.WithExplicitBucketBoundaries(0.01, 0.02, 0.04, 0.08, 0.16, 0.32, 0.64, 1.28, 2.56, 5.12, 10.24, 20.48, 40.96, 81.92),
+		),
+		tokenUsage: mustRegisterHistogram(meter,
+			shadowMetricTokenUsage,
+			metric.WithDescription("Number of tokens processed by the primary and the shadow backends of mirrored requests."),
+			metric.WithUnit("{token}"),
+			metric.WithExplicitBucketBoundaries(1, 4, 16, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216, 67108864),
+		),
+		similarity: mustRegisterHistogram(meter,
+			shadowMetricResponseSimilarity,
+			metric.WithDescription("Similarity between the completions of the primary and the shadow backends, from 0 to 1."),
+			metric.WithUnit("1"),
+			metric.WithExplicitBucketBoundaries(0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 0.95, 1),
+		),
+	}
+}
+
+// RecordComparison records the paired results of a mirrored request.
+//
+// The primary result can be nil when it was not available before the shadow request timed out. The similarity
+// is recorded only when it is not negative, and is only meaningful when both results are successful.
+func (s *Shadow) RecordComparison(ctx context.Context, routeRule, model string, primary, shadow *ShadowResult, similarity float64) {
+	if primary != nil {
+		s.recordResult(ctx, shadowRolePrimary, routeRule, model, primary)
+	}
+	s.recordResult(ctx, shadowRoleShadow, routeRule, model, shadow)
+	if primary == nil || similarity < 0 {
+		return
+	}
+	s.similarity.Record(ctx, similarity, metric.WithAttributes(
+		attribute.Key(shadowAttributeRouteRule).String(routeRule),
+		attribute.Key(genaiAttributeRequestModel).String(model),
+		attribute.Key(shadowAttributeBackend).String(primary.Backend),
+		attribute.Key(shadowAttributeShadowBackend).String(shadow.Backend),
+		attribute.Key(shadowAttributeFinishReasonMatch).Bool(primary.FinishReason == shadow.FinishReason),
+	))
+}
+
+// recordResult records the result of one side of a mirrored request.
+func (s *Shadow) recordResult(ctx context.Context, role, routeRule, model string, r *ShadowResult) {
+	attrs := []attribute.KeyValue{
+		attribute.Key(shadowAttributeRole).String(role),
+		attribute.Key(shadowAttributeRouteRule).String(routeRule),
+		attribute.Key(genaiAttributeRequestModel).String(model),
+		attribute.Key(shadowAttributeBackend).String(r.Backend),
+	}
+	if !r.Success {
+		s.requestLatency.Record(ctx, r.Latency.Seconds(), metric.WithAttributes(attrs...),
+			metric.WithAttributes(attribute.Key(genaiAttributeErrorType).String(genaiErrorTypeFallback)))
+		return
+	}
+	s.requestLatency.Record(ctx, r.Latency.Seconds(), metric.WithAttributes(attrs...))
+	s.tokenUsage.Record(ctx, float64(r.InputTokens), metric.WithAttributes(attrs...),
+		metric.WithAttributes(attribute.Key(genaiAttributeTokenType).String(genaiTokenTypeInput)))
+	s.tokenUsage.Record(ctx, float64(r.OutputTokens), metric.WithAttributes(attrs...),
+		metric.WithAttributes(attribute.Key(genaiAttributeTokenType).String(genaiTokenTypeOutput)))
+}
@@ -0,0 +1,62 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestShadow_RecordComparison(t *testing.T) {
+	var (
+		mr    = metric.NewManualReader()
+		meter = metric.NewMeterProvider(metric.WithReader(mr)).Meter("test")
+		s     = NewShadow(meter)
+
+		primary = &ShadowResult{Backend: "primary.ns", Latency: 2 * time.Second, Success: true, InputTokens: 10, OutputTokens: 5, FinishReason: "stop"}
+		shadow  = &ShadowResult{Backend: "shadow.ns", Latency: time.Second, Success: true, InputTokens: 12, OutputTokens: 7, FinishReason: "stop"}
+	)
+	attrsFor := func(role, backend string, extra ...attribute.KeyValue) attribute.Set {
+		return attribute.NewSet(append([]attribute.KeyValue{
+			attribute.Key(shadowAttributeRole).String(role),
+			attribute.Key(shadowAttributeRouteRule).String("rule"),
+			attribute.Key(genaiAttributeRequestModel).String("model"),
+			attribute.Key(shadowAttributeBackend).String(backend),
+		}, extra...)...)
+	}
+
+	s.RecordComparison(t.Context(), "rule", "model", primary, shadow, 0.5)
+
+	count, sum := getHistogramValues(t, mr, shadowMetricRequestDuration, attrsFor(shadowRolePrimary, "primary.ns"))
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 2.0, sum)
+	count, sum = getHistogramValues(t, mr, shadowMetricRequestDuration, attrsFor(shadowRoleShadow, "shadow.ns"))
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 1.0, sum)
+	_, sum = getHistogramValues(t, mr, shadowMetricTokenUsage,
+		attrsFor(shadowRoleShadow, "shadow.ns", attribute.Key(genaiAttributeTokenType).String(genaiTokenTypeOutput)))
+	assert.Equal(t, 7.0, sum)
+	count, sum = getHistogramValues(t, mr, shadowMetricResponseSimilarity, attribute.NewSet(
+		attribute.Key(shadowAttributeRouteRule).String("rule"),
+		attribute.Key(genaiAttributeRequestModel).String("model"),
+		attribute.Key(shadowAttributeBackend).String("primary.ns"),
+		attribute.Key(shadowAttributeShadowBackend).String("shadow.ns"),
+		attribute.Key(shadowAttributeFinishReasonMatch).Bool(true),
+	))
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 0.5, sum)
+
+	// Without the primary result, only the shadow side is recorded.
+	failed := &ShadowResult{Backend: "shadow.ns", Latency: time.Second}
+	s.RecordComparison(t.Context(), "rule", "model", nil, failed, -1)
+	count, _ = getHistogramValues(t, mr, shadowMetricRequestDuration,
+		attrsFor(shadowRoleShadow, "shadow.ns", attribute.Key(genaiAttributeErrorType).String(genaiErrorTypeFallback)))
+	assert.Equal(t, uint64(1), count)
+}
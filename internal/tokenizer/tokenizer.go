@@ -0,0 +1,135 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+// Package tokenizer provides the tokenizers used to estimate the input tokens of the requests
+// before they are sent to the backends.
+package tokenizer
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+
+	"github.com/envoyproxy/ai-gateway/filterapi/x"
+)
+
+var (
+	// Default is the tokenizer for the models whose tokenizer is unknown. This counts four ASCII characters
+	// as one token and every other character as one token, which overestimates most prose and code.
+	Default x.Tokenizer = approximate{asciiCharsPerToken: 4}
+	// claude approximates the tokenizer of the Anthropic Claude models, which is not public.
+	claude x.Tokenizer = approximate{asciiCharsPerToken: 3.5}
+	// gemini approximates the SentencePiece tokenizer of the Google Gemini models.
+	gemini x.Tokenizer = approximate{asciiCharsPerToken: 4}
+	// titan approximates the tokenizer of the Amazon Titan models.
+	titan x.Tokenizer = approximate{asciiCharsPerToken: 4.5}
+	// o200k and cl100k are the BPE tokenizers of the OpenAI models, which are loaded on the first use
+	// since building the ranks of the vocabulary takes a while.
+	o200k  = lazyTiktoken(tiktoken.MODEL_O200K_BASE)
+	cl100k = lazyTiktoken(tiktoken.MODEL_CL100K_BASE)
+)
+
+// openAIModelPrefixes maps the prefixes of the OpenAI model names to their tokenizers.
+// The longer prefixes come first so that, e.g., "gpt-4o" is not matched by "gpt-4".
+var openAIModelPrefixes = []struct {
+	prefix    string
+	tokenizer x.Tokenizer
+}{
+	{"gpt-4o", o200k},
+	{"gpt-4.1", o200k},
+	{"gpt-4.5", o200k},
+	{"gpt-5", o200k},
+	{"chatgpt-4o", o200k},
+	{"o1", o200k},
+	{"o3", o200k},
+	{"o4", o200k},
+	{"gpt-4", cl100k},
+	{"gpt-3.5-turbo", cl100k},
+	{"text-embedding-", cl100k},
+}
+
+// ForModel returns the tokenizer for the given model name. [x.NewCustomTokenizer] takes precedence over
+// the built-in tokenizers, and [Default] is returned when the model is unknown.
+//
+// The model names of the cloud providers are also recognized, e.g. "anthropic.claude-3-haiku-20240307-v1:0"
+// on AWS Bedrock or "gpt-4o" deployed on Azure OpenAI.
+func ForModel(model string) x.Tokenizer {
+	if x.NewCustomTokenizer != nil {
+		if t := x.NewCustomTokenizer(model); t != nil {
+			return t
+		}
+	}
+	model = strings.ToLower(model)
+	for _, p := range openAIModelPrefixes {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.tokenizer
+		}
+	}
+	switch {
+	case strings.Contains(model, "claude"):
+		return claude
+	case strings.Contains(model, "gemini"):
+		return gemini
+	case strings.Contains(model, "titan"):
+		return titan
+	default:
+		return Default
+	}
+}
+
+// approximate implements [x.Tokenizer] by the ratio of the characters to the tokens. The non-ASCII characters
+// are counted as one token each since they are usually split into multiple byte-level tokens.
+type approximate struct {
+	asciiCharsPerToken float64
+}
+
+// CountTokens implements [x.Tokenizer.CountTokens].
+func (a approximate) CountTokens(s string) int {
+	var ascii, others int
+	for i := 0; i < len(s); {
+		if s[i] < utf8.RuneSelf {
+			ascii++
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		others++
+		i += size
+	}
+	return int(math.Ceil(float64(ascii)/a.asciiCharsPerToken)) + others
+}
+
+// bpe implements [x.Tokenizer] with the tiktoken-compatible BPE encoding.
+type bpe struct {
+	load func() (*tiktoken.Tiktoken, error)
+}
+
+// loadOfflineOnce makes the tiktoken loader read the vocabularies embedded in the binary instead of downloading them.
+var loadOfflineOnce sync.Once
+
+// lazyTiktoken returns the [bpe] tokenizer of the given encoding which is loaded on the first use.
+func lazyTiktoken(encoding string) *bpe {
+	return &bpe{load: sync.OnceValues(func() (*tiktoken.Tiktoken, error) {
+		loadOfflineOnce.Do(func() { tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader()) })
+		return tiktoken.GetEncoding(encoding)
+	})}
+}
+
+// CountTokens implements [x.Tokenizer.CountTokens].
+func (b *bpe) CountTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+	enc, err := b.load()
+	if err != nil {
+		// This never happens as the vocabularies are embedded, but the estimate must not fail the request.
+		return Default.CountTokens(s)
+	}
+	return len(enc.EncodeOrdinary(s))
+}
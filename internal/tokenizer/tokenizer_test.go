@@ -0,0 +1,82 @@
+// Copyright Envoy AI Gateway Authors
+// SPDX-License-Identifier: Apache-2.0
+// The full text of the Apache license is available in the LICENSE file at
+// the root of the repo.
+
+package tokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/envoyproxy/ai-gateway/filterapi/x"
+)
+
+func TestForModel(t *testing.T) {
+	for _, tc := range []struct {
+		model string
+		exp   x.Tokenizer
+	}{
+		{"gpt-4o-mini", o200k},
+		{"GPT-4.1", o200k},
+		{"o3-mini", o200k},
+		{"gpt-4-turbo", cl100k},
+		{"gpt-3.5-turbo-0125", cl100k},
+		{"text-embedding-3-small", cl100k},
+		{"claude-3-5-sonnet-latest", claude},
+		{"us.anthropic.claude-3-haiku-20240307-v1:0", claude},
+		{"gemini-2.0-flash", gemini},
+		{"amazon.titan-text-express-v1", titan},
+		{"llama3.2", Default},
+		{"", Default},
+	} {
+		t.Run(tc.model, func(t *testing.T) {
+			require.Equal(t, tc.exp, ForModel(tc.model))
+		})
+	}
+
+	t.Run("custom", func(t *testing.T) {
+		custom := approximate{asciiCharsPerToken: 1}
+		x.NewCustomTokenizer = func(model string) x.Tokenizer {
+			if model == "llama3.2" {
+				return custom
+			}
+			return nil
+		}
+		t.Cleanup(func() { x.NewCustomTokenizer = nil })
+		require.Equal(t, custom, ForModel("llama3.2"))
+		require.Equal(t, o200k, ForModel("gpt-4o"))
+	})
+}
+
+func Test_approximate(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		exp int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"こんにちは", 5},
+		{"hi こんにちは", 6},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			require.Equal(t, tc.exp, Default.CountTokens(tc.in))
+		})
+	}
+	require.Equal(t, 2, claude.CountTokens("abcdefg"))
+	require.Equal(t, 3, claude.CountTokens("abcdefgh"))
+}
+
+func Test_bpe(t *testing.T) {
+	require.Zero(t, o200k.CountTokens(""))
+	require.Equal(t, 2, o200k.CountTokens("hello world"))
+	require.Equal(t, 2, cl100k.CountTokens("hello world"))
+	// The vocabularies differ in the handling of non-English texts.
+	require.Equal(t, 1, o200k.CountTokens("こんにちは"))
+	require.Equal(t, 1, cl100k.CountTokens("こんにちは"))
+	// The special tokens are counted as ordinary texts.
+	require.Greater(t, o200k.CountTokens("<|endoftext|>"), 1)
+}
@@ -587,7 +587,8 @@ func TestSecretController(t *testing.T) {
 	require.NoError(t, err)
 
 	eventCh := internaltesting.NewControllerEventChan[*aigv1a1.BackendSecurityPolicy]()
-	sc := controller.NewSecretController(mgr.GetClient(), k, defaultLogger(), eventCh.Ch)
+	sc := controller.NewSecretController(mgr.GetClient(), k, defaultLogger(), eventCh.Ch,
+		internaltesting.NewControllerEventChan[*aigv1a1.AIGatewayConsumerKey]().Ch, internaltesting.NewControllerEventChan[*aigv1a1.AIGatewayRoute]().Ch)
 	const secretName, secretNamespace = "mysecret", "default"
 
 	err = ctrl.NewControllerManagedBy(mgr).For(&corev1.Secret{}).Complete(sc)
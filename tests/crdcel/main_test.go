@@ -48,6 +48,14 @@ func TestAIGatewayRoutes(t *testing.T) {
 			name:   "no_target_refs.yaml",
 			expErr: `spec.targetRefs: Invalid value: 0: spec.targetRefs in body should have at least 1 items`,
 		},
+		{
+			name:   "redaction_hash_without_key.yaml",
+			expErr: `spec.rules[0].redaction: Invalid value: "object": hashKeyRef must be set when a detector uses the Hash action`,
+		},
+		{
+			name:   "token_quota_without_consumer.yaml",
+			expErr: `spec: Invalid value: "object": consumerHeader must be set on the tokenQuotas unless requireConsumerKey is set`,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			data, err := testdata.ReadFile(path.Join("testdata/aigatewayroutes", tc.name))
@@ -124,19 +132,19 @@ func TestBackendSecurityPolicies(t *testing.T) {
 		},
 		{
 			name:   "azure_credentials_missing_client_id.yaml",
-			expErr: "spec.azureCredentials.clientID in body should be at least 1 chars long",
+			expErr: "clientID and tenantID must be specified unless apiKeyRef is specified",
 		},
 		{
 			name:   "azure_credentials_missing_tenant_id.yaml",
-			expErr: "spec.azureCredentials.tenantID in body should be at least 1 chars long",
+			expErr: "clientID and tenantID must be specified unless apiKeyRef is specified",
 		},
 		{
 			name:   "azure_missing_auth.yaml",
-			expErr: "Exactly one of clientSecretRef or oidcExchangeToken must be specified",
+			expErr: "Exactly one of clientSecretRef, oidcExchangeToken or apiKeyRef must be specified",
 		},
 		{
 			name:   "azure_multiple_auth.yaml",
-			expErr: "Exactly one of clientSecretRef or oidcExchangeToken must be specified",
+			expErr: "Exactly one of clientSecretRef, oidcExchangeToken or apiKeyRef must be specified",
 		},
 		// CEL validation test cases - these should fail due to type mismatch
 		{
@@ -151,6 +159,11 @@ func TestBackendSecurityPolicies(t *testing.T) {
 			name:   "apikey_with_gcp_credentials.yaml",
 			expErr: "When type is APIKey, only apiKey field should be set",
 		},
+		{name: "apikey_header.yaml"},
+		{
+			name:   "apikey_query_param_with_header.yaml",
+			expErr: "queryParam cannot be specified with header or prefix",
+		},
 		{
 			name:   "apikey_with_nil_configuration.yaml",
 			expErr: "When type is APIKey, only apiKey field should be set",
@@ -170,6 +183,7 @@ func TestBackendSecurityPolicies(t *testing.T) {
 		// Valid test cases - these should pass
 		{name: "azure_oidc.yaml"},
 		{name: "azure_valid_credentials.yaml"},
+		{name: "azure_api_key.yaml"},
 		{name: "aws_credential_file.yaml"},
 		{name: "aws_oidc.yaml"},
 		{name: "gcp_oidc.yaml"},